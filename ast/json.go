@@ -0,0 +1,284 @@
+package ast
+
+import (
+	"encoding/json"
+	"fmt"
+)
+
+// Nodes are encoded as JSON objects holding their fields and a "type" field
+// naming their Go type, such as "JSXElement", so that the nodes of a tree
+// decode back to the same types. Positions are as in the tree: 1-indexed,
+// with rune columns and byte offsets.
+
+// The plain types have the fields of the nodes without their methods, so
+// that encoding them does not recurse.
+type (
+	plainFile                GoxFile
+	plainElement             JSXElement
+	plainFragment            JSXFragment
+	plainStringAttribute     StringAttribute
+	plainExpressionAttribute ExpressionAttribute
+	plainSpreadAttribute     SpreadAttribute
+	plainText                JSXText
+	plainExpression          JSXExpression
+	plainComment             JSXComment
+	plainGoCode              GoCode
+)
+
+// marshalNode encodes v, the plain fields of a node, adding the "type"
+// field first.
+func marshalNode(typ string, v any) ([]byte, error) {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return nil, err
+	}
+	out := []byte(`{"type":"` + typ + `"`)
+	if len(data) > 2 {
+		out = append(out, ',')
+	}
+	return append(out, data[1:]...), nil
+}
+
+// MarshalJSON implements json.Marshaler.
+func (f *GoxFile) MarshalJSON() ([]byte, error) {
+	return marshalNode("GoxFile", (*plainFile)(f))
+}
+
+// MarshalJSON implements json.Marshaler.
+func (e *JSXElement) MarshalJSON() ([]byte, error) {
+	return marshalNode("JSXElement", (*plainElement)(e))
+}
+
+// MarshalJSON implements json.Marshaler.
+func (f *JSXFragment) MarshalJSON() ([]byte, error) {
+	return marshalNode("JSXFragment", (*plainFragment)(f))
+}
+
+// MarshalJSON implements json.Marshaler.
+func (a *StringAttribute) MarshalJSON() ([]byte, error) {
+	return marshalNode("StringAttribute", (*plainStringAttribute)(a))
+}
+
+// MarshalJSON implements json.Marshaler.
+func (a *ExpressionAttribute) MarshalJSON() ([]byte, error) {
+	return marshalNode("ExpressionAttribute", (*plainExpressionAttribute)(a))
+}
+
+// MarshalJSON implements json.Marshaler.
+func (a *SpreadAttribute) MarshalJSON() ([]byte, error) {
+	return marshalNode("SpreadAttribute", (*plainSpreadAttribute)(a))
+}
+
+// MarshalJSON implements json.Marshaler.
+func (t *JSXText) MarshalJSON() ([]byte, error) {
+	return marshalNode("JSXText", (*plainText)(t))
+}
+
+// MarshalJSON implements json.Marshaler.
+func (e *JSXExpression) MarshalJSON() ([]byte, error) {
+	return marshalNode("JSXExpression", (*plainExpression)(e))
+}
+
+// MarshalJSON implements json.Marshaler.
+func (c *JSXComment) MarshalJSON() ([]byte, error) {
+	return marshalNode("JSXComment", (*plainComment)(c))
+}
+
+// MarshalJSON implements json.Marshaler.
+func (c *GoCode) MarshalJSON() ([]byte, error) {
+	return marshalNode("GoCode", (*plainGoCode)(c))
+}
+
+// UnmarshalJSON implements json.Unmarshaler.
+func (f *GoxFile) UnmarshalJSON(data []byte) error {
+	v := struct {
+		*plainFile
+		Nodes []json.RawMessage `json:"nodes"`
+	}{plainFile: (*plainFile)(f)}
+	if err := json.Unmarshal(data, &v); err != nil {
+		return err
+	}
+	nodes, err := unmarshalNodes(v.Nodes)
+	f.Nodes = nodes
+	return err
+}
+
+// UnmarshalJSON implements json.Unmarshaler.
+func (e *JSXElement) UnmarshalJSON(data []byte) error {
+	v := struct {
+		*plainElement
+		Attributes []json.RawMessage `json:"attributes"`
+		Children   []json.RawMessage `json:"children"`
+	}{plainElement: (*plainElement)(e)}
+	if err := json.Unmarshal(data, &v); err != nil {
+		return err
+	}
+	attrs, err := unmarshalAttributes(v.Attributes)
+	if err != nil {
+		return err
+	}
+	e.Attributes = attrs
+	children, err := unmarshalChildren(v.Children)
+	e.Children = children
+	return err
+}
+
+// UnmarshalJSON implements json.Unmarshaler.
+func (f *JSXFragment) UnmarshalJSON(data []byte) error {
+	v := struct {
+		*plainFragment
+		Attributes []json.RawMessage `json:"attributes"`
+		Children   []json.RawMessage `json:"children"`
+	}{plainFragment: (*plainFragment)(f)}
+	if err := json.Unmarshal(data, &v); err != nil {
+		return err
+	}
+	attrs, err := unmarshalAttributes(v.Attributes)
+	if err != nil {
+		return err
+	}
+	f.Attributes = attrs
+	children, err := unmarshalChildren(v.Children)
+	f.Children = children
+	return err
+}
+
+// unmarshalAttributes decodes the attributes of an element or fragment.
+func unmarshalAttributes(raws []json.RawMessage) ([]Attribute, error) {
+	var attrs []Attribute
+	for _, raw := range raws {
+		node, err := UnmarshalNode(raw)
+		if err != nil {
+			return nil, err
+		}
+		attr, ok := node.(Attribute)
+		if !ok {
+			return nil, fmt.Errorf("ast: %T is not an attribute", node)
+		}
+		attrs = append(attrs, attr)
+	}
+	return attrs, nil
+}
+
+// UnmarshalJSON implements json.Unmarshaler.
+func (a *ExpressionAttribute) UnmarshalJSON(data []byte) error {
+	v := struct {
+		*plainExpressionAttribute
+		Parts []json.RawMessage `json:"parts"`
+	}{plainExpressionAttribute: (*plainExpressionAttribute)(a)}
+	if err := json.Unmarshal(data, &v); err != nil {
+		return err
+	}
+	parts, err := unmarshalNodes(v.Parts)
+	a.Parts = parts
+	return err
+}
+
+// UnmarshalJSON implements json.Unmarshaler.
+func (e *JSXExpression) UnmarshalJSON(data []byte) error {
+	v := struct {
+		*plainExpression
+		Parts []json.RawMessage `json:"parts"`
+	}{plainExpression: (*plainExpression)(e)}
+	if err := json.Unmarshal(data, &v); err != nil {
+		return err
+	}
+	parts, err := unmarshalNodes(v.Parts)
+	e.Parts = parts
+	return err
+}
+
+// UnmarshalJSON implements json.Unmarshaler.
+func (a *StringAttribute) UnmarshalJSON(data []byte) error {
+	return json.Unmarshal(data, (*plainStringAttribute)(a))
+}
+
+// UnmarshalJSON implements json.Unmarshaler.
+func (a *SpreadAttribute) UnmarshalJSON(data []byte) error {
+	return json.Unmarshal(data, (*plainSpreadAttribute)(a))
+}
+
+// UnmarshalJSON implements json.Unmarshaler.
+func (t *JSXText) UnmarshalJSON(data []byte) error {
+	return json.Unmarshal(data, (*plainText)(t))
+}
+
+// UnmarshalJSON implements json.Unmarshaler.
+func (c *JSXComment) UnmarshalJSON(data []byte) error {
+	return json.Unmarshal(data, (*plainComment)(c))
+}
+
+// UnmarshalJSON implements json.Unmarshaler.
+func (c *GoCode) UnmarshalJSON(data []byte) error {
+	return json.Unmarshal(data, (*plainGoCode)(c))
+}
+
+// UnmarshalNode decodes a node encoded as JSON, of the type its "type"
+// field names.
+func UnmarshalNode(data []byte) (Node, error) {
+	var header struct {
+		Type string `json:"type"`
+	}
+	if err := json.Unmarshal(data, &header); err != nil {
+		return nil, err
+	}
+	var node Node
+	switch header.Type {
+	case "GoxFile":
+		node = &GoxFile{}
+	case "JSXElement":
+		node = &JSXElement{}
+	case "JSXFragment":
+		node = &JSXFragment{}
+	case "StringAttribute":
+		node = &StringAttribute{}
+	case "ExpressionAttribute":
+		node = &ExpressionAttribute{}
+	case "SpreadAttribute":
+		node = &SpreadAttribute{}
+	case "JSXText":
+		node = &JSXText{}
+	case "JSXExpression":
+		node = &JSXExpression{}
+	case "JSXComment":
+		node = &JSXComment{}
+	case "GoCode":
+		node = &GoCode{}
+	case "":
+		return nil, fmt.Errorf("ast: node without a type")
+	default:
+		return nil, fmt.Errorf("ast: unknown node type %q", header.Type)
+	}
+	if err := json.Unmarshal(data, node); err != nil {
+		return nil, err
+	}
+	return node, nil
+}
+
+func unmarshalNodes(raws []json.RawMessage) ([]Node, error) {
+	var nodes []Node
+	for _, raw := range raws {
+		node, err := UnmarshalNode(raw)
+		if err != nil {
+			return nil, err
+		}
+		nodes = append(nodes, node)
+	}
+	return nodes, nil
+}
+
+func unmarshalChildren(raws []json.RawMessage) ([]JSXChild, error) {
+	var children []JSXChild
+	for _, raw := range raws {
+		node, err := UnmarshalNode(raw)
+		if err != nil {
+			return nil, err
+		}
+		child, ok := node.(JSXChild)
+		if !ok {
+			return nil, fmt.Errorf("ast: %T is not a JSX child", node)
+		}
+		children = append(children, child)
+	}
+	return children, nil
+}
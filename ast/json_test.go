@@ -0,0 +1,58 @@
+package ast
+
+import (
+	"encoding/json"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestJSONRoundTrip(t *testing.T) {
+	file := walkTree()
+	file.Package = "ui"
+	file.Imports = []Import{{Alias: "g", Path: "github.com/germtb/gox", Range: NewRange(NewPosition(11, 2, 1), NewPosition(35, 2, 25))}}
+	file.Nodes[1].(*JSXElement).Range = NewRange(NewPosition(8, 1, 9), NewPosition(60, 1, 61))
+
+	data, err := json.Marshal(file)
+	if err != nil {
+		t.Fatalf("Marshal: %v", err)
+	}
+	for _, want := range []string{
+		`{"type":"GoxFile","package":"ui",`,
+		`{"type":"JSXElement","range":{"start":{"offset":8,"line":1,"column":9},`,
+		`{"type":"StringAttribute","key":"id","value":"a",`,
+		`{"type":"SpreadAttribute","expression":"p",`,
+		`{"type":"JSXFragment","range":`,
+	} {
+		if !strings.Contains(string(data), want) {
+			t.Errorf("JSON does not contain %s:\n%s", want, data)
+		}
+	}
+
+	var got GoxFile
+	if err := json.Unmarshal(data, &got); err != nil {
+		t.Fatalf("Unmarshal: %v", err)
+	}
+	if !reflect.DeepEqual(&got, file) {
+		again, _ := json.Marshal(&got)
+		t.Errorf("round trip changed the tree:\n%s\nwant\n%s", again, data)
+	}
+}
+
+func TestUnmarshalNode(t *testing.T) {
+	node, err := UnmarshalNode([]byte(`{"type":"JSXText","value":"hi"}`))
+	if text, ok := node.(*JSXText); err != nil || !ok || text.Value != "hi" {
+		t.Errorf("UnmarshalNode = %#v, %v", node, err)
+	}
+
+	for _, bad := range []string{
+		`{"value":"hi"}`,
+		`{"type":"JSXSpread"}`,
+		`{"type":"JSXElement","attributes":[{"type":"JSXText"}]}`,
+		`{"type":"JSXFragment","children":[{"type":"GoCode"}]}`,
+	} {
+		if _, err := UnmarshalNode([]byte(bad)); err == nil {
+			t.Errorf("UnmarshalNode(%s) should fail", bad)
+		}
+	}
+}
@@ -1,16 +1,20 @@
 package ast
 
-// Position represents a position in source code.
+import "unicode/utf8"
+
+// Position represents a position in source code. Column counts runes, as
+// an editor counts characters; Offset is in bytes, for slicing the source.
+// The \r of a \r\n line break takes no column.
 type Position struct {
-	Offset int // Byte offset from start of file
-	Line   int // 1-indexed line number
-	Column int // 1-indexed column number (in bytes)
+	Offset int `json:"offset"` // Byte offset from start of file
+	Line   int `json:"line"`   // 1-indexed line number
+	Column int `json:"column"` // 1-indexed column number (in runes)
 }
 
 // Range represents a span of source code.
 type Range struct {
-	Start Position
-	End   Position
+	Start Position `json:"start"`
+	End   Position `json:"end"`
 }
 
 // NewPosition creates a new Position.
@@ -22,6 +26,30 @@ func NewPosition(offset, line, column int) Position {
 	}
 }
 
+// Advance returns the position just past s, the source text starting at p.
+func (p Position) Advance(s string) Position {
+	for i := 0; i < len(s); {
+		c := s[i]
+		switch {
+		case c == '\n':
+			p.Line++
+			p.Column = 1
+			i++
+		case c == '\r' && i+1 < len(s) && s[i+1] == '\n':
+			i++
+		case c < utf8.RuneSelf:
+			p.Column++
+			i++
+		default:
+			_, size := utf8.DecodeRuneInString(s[i:])
+			p.Column++
+			i += size
+		}
+	}
+	p.Offset += len(s)
+	return p
+}
+
 // NewRange creates a new Range from start and end positions.
 func NewRange(start, end Position) Range {
 	return Range{
@@ -0,0 +1,112 @@
+package ast
+
+import "fmt"
+
+// Rewrite returns the tree of node with f applied to every node, bottom up:
+// f receives each node once its children have been rewritten, and returns
+// the node to put in its place, which is the node itself to keep it, or nil
+// to remove it from the list that holds it. The children of a node are as
+// for Walk.
+//
+// node is not modified. A node is copied only if one of its children was
+// replaced, so the result shares every unchanged subtree with node; f must
+// not modify the nodes it receives, and returns a modified copy instead:
+//
+//	// Rename <Button> to <ui.Button>
+//	file = ast.Rewrite(file, func(n ast.Node) ast.Node {
+//		if elem, ok := n.(*ast.JSXElement); ok && elem.Tag == "Button" {
+//			renamed := *elem
+//			renamed.Tag = "ui.Button"
+//			return &renamed
+//		}
+//		return n
+//	}).(*ast.GoxFile)
+//
+// Rewrite panics if f replaces an attribute with a node that is not an
+// attribute, or a child of an element with a node that cannot be one.
+func Rewrite(node Node, f func(Node) Node) Node {
+	if node == nil {
+		return nil
+	}
+	return f(rewriteChildren(node, f))
+}
+
+// rewriteChildren returns node with its children rewritten, copying it if
+// any of them was replaced.
+func rewriteChildren(node Node, f func(Node) Node) Node {
+	switch n := node.(type) {
+	case *GoxFile:
+		if nodes, changed := rewriteList(n.Nodes, f, "a node"); changed {
+			c := *n
+			c.Nodes = nodes
+			return &c
+		}
+
+	case *JSXElement:
+		attrs, attrsChanged := rewriteList(n.Attributes, f, "an attribute")
+		children, childrenChanged := rewriteList(n.Children, f, "a JSX child")
+		if attrsChanged || childrenChanged {
+			c := *n
+			c.Attributes, c.Children = attrs, children
+			return &c
+		}
+
+	case *JSXFragment:
+		attrs, attrsChanged := rewriteList(n.Attributes, f, "an attribute")
+		children, childrenChanged := rewriteList(n.Children, f, "a JSX child")
+		if attrsChanged || childrenChanged {
+			c := *n
+			c.Attributes, c.Children = attrs, children
+			return &c
+		}
+
+	case *ExpressionAttribute:
+		if parts, changed := rewriteList(n.Parts, f, "a node"); changed {
+			c := *n
+			c.Parts = parts
+			return &c
+		}
+
+	case *JSXExpression:
+		if parts, changed := rewriteList(n.Parts, f, "a node"); changed {
+			c := *n
+			c.Parts = parts
+			return &c
+		}
+
+	case *GoCode, *JSXText, *JSXComment, *StringAttribute, *SpreadAttribute:
+		// nothing to do
+
+	default:
+		panic(fmt.Sprintf("ast.Rewrite: unexpected node type %T", n))
+	}
+	return node
+}
+
+// rewriteList rewrites each node of list, and reports whether any was
+// replaced; if none was, it returns list itself. what describes the nodes
+// list may hold, for the panic of a replacement of the wrong type.
+func rewriteList[T Node](list []T, f func(Node) Node, what string) ([]T, bool) {
+	var out []T // Allocated at the first replacement
+	for i, item := range list {
+		r := Rewrite(item, f)
+		if out == nil && r == Node(item) {
+			continue
+		}
+		if out == nil {
+			out = append(make([]T, 0, len(list)), list[:i]...)
+		}
+		if r == nil {
+			continue
+		}
+		t, ok := r.(T)
+		if !ok {
+			panic(fmt.Sprintf("ast.Rewrite: %T cannot replace %s", r, what))
+		}
+		out = append(out, t)
+	}
+	if out == nil {
+		return list, false
+	}
+	return out, true
+}
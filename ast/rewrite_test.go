@@ -0,0 +1,78 @@
+package ast
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+)
+
+func TestRewrite(t *testing.T) {
+	file := walkTree()
+	before, _ := json.Marshal(file)
+
+	// Rename <i> and drop comments
+	got := Rewrite(file, func(n Node) Node {
+		switch n := n.(type) {
+		case *JSXElement:
+			if n.Tag == "i" {
+				renamed := *n
+				renamed.Tag = "em"
+				return &renamed
+			}
+		case *JSXComment:
+			return nil
+		}
+		return n
+	}).(*GoxFile)
+
+	if after, _ := json.Marshal(file); string(after) != string(before) {
+		t.Errorf("Rewrite modified its input:\n%s", after)
+	}
+
+	var tags []string
+	Inspect(got, func(n Node) bool {
+		switch n := n.(type) {
+		case *JSXElement:
+			tags = append(tags, n.Tag)
+		case *JSXComment:
+			t.Error("Expected comments to be removed")
+		}
+		return true
+	})
+	if s := strings.Join(tags, " "); s != "div b em" {
+		t.Errorf("Rewritten tags %q, want \"div b em\"", s)
+	}
+
+	// Only the path to the changes is copied
+	div, oldDiv := got.Nodes[1].(*JSXElement), file.Nodes[1].(*JSXElement)
+	if got == file || div == oldDiv {
+		t.Error("Expected the file and <div> to be copied")
+	}
+	if got.Nodes[0] != file.Nodes[0] || div.Attributes[1] != oldDiv.Attributes[1] || div.Children[0] != oldDiv.Children[0] {
+		t.Error("Expected unchanged nodes to be shared")
+	}
+	if len(div.Children) != 3 || len(oldDiv.Children) != 4 {
+		t.Errorf("Expected the comment to be removed from a copy of the children, got %d and %d", len(div.Children), len(oldDiv.Children))
+	}
+}
+
+func TestRewriteUnchanged(t *testing.T) {
+	file := walkTree()
+	if got := Rewrite(file, func(n Node) Node { return n }); got != file {
+		t.Error("Expected the same tree when f replaces nothing")
+	}
+}
+
+func TestRewriteWrongType(t *testing.T) {
+	defer func() {
+		if recover() == nil {
+			t.Error("Expected a panic replacing an attribute with Go code")
+		}
+	}()
+	Rewrite(walkTree(), func(n Node) Node {
+		if _, ok := n.(*StringAttribute); ok {
+			return &GoCode{Value: "x"}
+		}
+		return n
+	})
+}
@@ -1,22 +1,60 @@
 // Package ast defines the AST types for gox files.
 package ast
 
+import (
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
 // GoxFile represents a complete .gox file.
 type GoxFile struct {
-	Package    string
-	Imports    []Import
-	Nodes      []Node // Go code + JSX intermixed
-	SourcePath string
+	Package      string      `json:"package"`
+	PackageRange Range       `json:"packageRange"` // Package name in the package clause
+	Imports      []Import    `json:"imports"`
+	Components   []Component `json:"components"`
+	Nodes        []Node      `json:"nodes"` // Go code + JSX intermixed
+	SourcePath   string      `json:"sourcePath"`
+	Directives   Directives  `json:"directives"`
+}
+
+// Directives are the settings of a file made by //gox: line comments before
+// its package clause, such as //gox:runtime example.com/tui/gox.
+type Directives struct {
+	Runtime            string `json:"runtime,omitempty"`            // Import path of //gox:runtime
+	PreserveWhitespace bool   `json:"preserveWhitespace,omitempty"` // //gox:preserve-whitespace: JSX text is kept as written
+	NoFormat           bool   `json:"noFormat,omitempty"`           // //gox:no-format: gox fmt leaves the file as it is
+}
+
+func (*GoxFile) node() {}
+
+// GetRange returns the range from the start of the first node to the end of
+// the last, which is the whole file.
+func (f *GoxFile) GetRange() Range {
+	if len(f.Nodes) == 0 {
+		return Range{}
+	}
+	return NewRange(f.Nodes[0].GetRange().Start, f.Nodes[len(f.Nodes)-1].GetRange().End)
+}
+
+// Component is a top-level function of a file whose name can be used as a
+// tag, as in func Card(props CardProps) gox.VNode.
+type Component struct {
+	Name  string `json:"name"`
+	Doc   string `json:"doc,omitempty"` // Text of the // comments just before the function, as go/ast's CommentGroup.Text
+	Range Range  `json:"range"`         // Name in the func declaration
 }
 
 // Import represents a Go import statement.
 type Import struct {
-	Alias string // Optional alias (e.g., "ui" in `import ui "myapp/ui"`)
-	Path  string // Import path (e.g., "myapp/ui")
-	Range Range
+	Alias string `json:"alias,omitempty"` // Optional alias (e.g., "ui" in `import ui "myapp/ui"`)
+	Path  string `json:"path"`            // Import path (e.g., "myapp/ui")
+	Range Range  `json:"range"`
 }
 
-// Node is the interface for all nodes in a gox file.
+// Node is the interface for all nodes in a gox file. Every syntax type
+// implements it, so that Walk and Inspect can visit it; the top-level nodes
+// of a file are GoCode, JSXElement and JSXFragment.
 type Node interface {
 	node()
 	GetRange() Range
@@ -24,80 +62,244 @@ type Node interface {
 
 // JSXElement represents <tag ...>...</tag> or <tag ... />.
 type JSXElement struct {
-	Range       Range
-	Tag         string // "box", "text", or component name
-	Attributes  []Attribute
-	Children    []JSXChild
-	SelfClosing bool
+	Range         Range       `json:"range"`
+	Tag           string      `json:"tag"`                // "box", "text", or component name
+	TagRange      Range       `json:"tagRange"`           // Tag name in the opening tag
+	CloseTagRange Range       `json:"closeTagRange"`      // Tag name in the closing tag; unset if self-closing or missing
+	TypeArgs      string      `json:"typeArgs,omitempty"` // Type arguments of a generic component: "string" for <List[string]>
+	Attributes    []Attribute `json:"attributes"`
+	Children      []JSXChild  `json:"children"`
+	SelfClosing   bool        `json:"selfClosing,omitempty"`
+	Doc           string      `json:"doc,omitempty"`  // Text of the // comments on the lines just before the element, in Go code
+	Void          bool        `json:"void,omitempty"` // Named by a //gox:void directive: never has children
+	Trivia        *Trivia     `json:"trivia,omitempty"`
 }
 
 func (*JSXElement) node()             {}
 func (e *JSXElement) GetRange() Range { return e.Range }
 func (*JSXElement) jsxChildNode()     {}
 
-// Attribute can be string or expression.
+// Trivia is what a lossless parse (parser.Trivia) keeps of the source of a
+// node beyond its other fields: the whitespace between its tokens, and the
+// spelling of what those fields normalize, so that the node can be written
+// back byte for byte. Each field is as written, and set only for the nodes
+// it names; a node parsed without the mode has no Trivia. A tool that
+// changes what a field spells, such as the type arguments of an element,
+// drops or updates the Trivia of the node.
+type Trivia struct {
+	// Of elements, and of fragments in the long form <Fragment>
+	Tag           string `json:"tag,omitempty"`           // Between < and the tag name
+	TypeArgs      string `json:"typeArgs,omitempty"`      // Type arguments of the opening tag, brackets included
+	End           string `json:"end,omitempty"`           // Before the /> or > of the opening tag
+	Slash         string `json:"slash,omitempty"`         // Between the / and > of a self-closing tag
+	Content       string `json:"content,omitempty"`       // Of a void element: the whitespace between its tags, which is no child
+	CloseTag      string `json:"closeTag,omitempty"`      // Between </ and the tag name
+	CloseTypeArgs string `json:"closeTypeArgs,omitempty"` // Type arguments of the closing tag, brackets included, if it has them
+	CloseEnd      string `json:"closeEnd,omitempty"`      // Before the > of the closing tag
+
+	// Of attributes
+	Leading string `json:"leading,omitempty"` // Before the attribute, after the tag name or the attribute before it
+	Equals  string `json:"equals,omitempty"`  // Between the key and = or ?=
+	Value   string `json:"value,omitempty"`   // Between = or ?= and the value
+	Quote   string `json:"quote,omitempty"`   // Of a string attribute: ", ' or `
+
+	// Of comments and spread attributes
+	Text string `json:"text,omitempty"` // Between the braces
+}
+
+// IsComponentTag reports whether tag names a component rather than an
+// intrinsic element: it starts with an upper case letter, as in <Card>, or
+// is qualified with a package or fields, as in <ui.Card>.
+func IsComponentTag(tag string) bool {
+	if tag == "" {
+		return false
+	}
+	r, _ := utf8.DecodeRuneInString(tag)
+	return unicode.IsUpper(r) || strings.Contains(tag, ".")
+}
+
+// Attribute can be string, expression or spread.
 type Attribute interface {
+	Node
 	attributeNode()
-	GetRange() Range
 }
 
-// StringAttribute represents key="value".
+// StringAttribute represents key="value", key='value' or key=`value`.
 type StringAttribute struct {
-	Key   string
-	Value string
-	Range Range
+	Key         string  `json:"key"`
+	Value       string  `json:"value"`
+	Range       Range   `json:"range"` // From the key to the closing quote
+	KeyRange    Range   `json:"keyRange"`
+	EqualsRange Range   `json:"equalsRange"`
+	ValueRange  Range   `json:"valueRange"`    // The quoted string, quotes included
+	Raw         bool    `json:"raw,omitempty"` // In backquotes, which gox fmt keeps: no escapes, and may span lines
+	Trivia      *Trivia `json:"trivia,omitempty"`
 }
 
+func (*StringAttribute) node()             {}
 func (*StringAttribute) attributeNode()    {}
 func (a *StringAttribute) GetRange() Range { return a.Range }
 
 // ExpressionAttribute represents key={expression}.
 type ExpressionAttribute struct {
-	Key        string
-	Expression string
-	Range      Range
+	Key        string `json:"key"`
+	Expression string `json:"expression"`
+	// Parts is Expression split into Go code and the JSX elements and
+	// fragments it holds, as in header={<Title />}, or nil if it holds no
+	// JSX.
+	Parts       []Node `json:"parts,omitempty"`
+	Range       Range  `json:"range"` // From the key to the closing brace
+	KeyRange    Range  `json:"keyRange"`
+	EqualsRange Range  `json:"equalsRange"` // Unset for boolean shorthand, as in <input disabled />
+	ValueRange  Range  `json:"valueRange"`  // The expression, braces included; unset for boolean shorthand
+	// Optional is set for name?={value}, which leaves the prop out when the
+	// value is nil.
+	Optional bool    `json:"optional,omitempty"`
+	Trivia   *Trivia `json:"trivia,omitempty"`
 }
 
+func (*ExpressionAttribute) node()             {}
 func (*ExpressionAttribute) attributeNode()    {}
 func (a *ExpressionAttribute) GetRange() Range { return a.Range }
 
+// SpreadAttribute represents {...expression}, which sets the props of a
+// gox.Props value. Attributes after it override its props, and it
+// overrides the attributes before it.
+type SpreadAttribute struct {
+	Expression      string  `json:"expression"`      // After the dots
+	Range           Range   `json:"range"`           // The braces and what they hold
+	ExpressionRange Range   `json:"expressionRange"` // Expression, in the source
+	Trivia          *Trivia `json:"trivia,omitempty"`
+}
+
+func (*SpreadAttribute) node()             {}
+func (*SpreadAttribute) attributeNode()    {}
+func (a *SpreadAttribute) GetRange() Range { return a.Range }
+
 // JSXChild can be text, expression, or nested element.
 type JSXChild interface {
+	Node
 	jsxChildNode()
-	GetRange() Range
 }
 
 // JSXText represents text content between tags.
 type JSXText struct {
-	Value string
-	Range Range
+	Value string `json:"value"`
+	Raw   bool   `json:"raw,omitempty"` // Content of a raw element, such as <script>, kept verbatim
+	Range Range  `json:"range"`
 }
 
+func (*JSXText) node()             {}
 func (*JSXText) jsxChildNode()     {}
 func (t *JSXText) GetRange() Range { return t.Range }
 
+// Content returns the text as rendered, by JSX whitespace rules: whitespace
+// that contains a line break is removed, and the lines are joined with a
+// space. Any other run of whitespace collapses to one space, so the space
+// in "Hello <b>world</b>" is kept. Content is empty for text that renders
+// nothing, such as the indentation between elements. Raw text is returned
+// as written, but with \r\n line breaks as \n.
+func (t *JSXText) Content() string {
+	if t.Raw {
+		return strings.ReplaceAll(t.Value, "\r\n", "\n")
+	}
+	var b strings.Builder
+	lines := strings.Split(t.Value, "\n")
+	for i, line := range lines {
+		if i > 0 {
+			line = strings.TrimLeft(line, jsxSpace)
+		}
+		if i < len(lines)-1 {
+			line = strings.TrimRight(line, jsxSpace)
+		}
+		if line == "" {
+			continue
+		}
+		if b.Len() > 0 {
+			b.WriteByte(' ')
+		}
+		space := false
+		for _, r := range line {
+			if strings.ContainsRune(jsxSpace, r) {
+				space = true
+				continue
+			}
+			if space {
+				b.WriteByte(' ')
+				space = false
+			}
+			b.WriteRune(r)
+		}
+		if space {
+			b.WriteByte(' ')
+		}
+	}
+	return b.String()
+}
+
+// jsxSpace is the whitespace JSX text rules apply to. Other spaces, such as
+// U+00A0 NO-BREAK SPACE, are content.
+const jsxSpace = " \t\r\n"
+
 // JSXExpression represents {expression} within JSX.
 type JSXExpression struct {
-	Expression string
-	Range      Range
+	Expression string `json:"expression"`
+	// Parts is Expression split into Go code and the JSX it holds, as for
+	// ExpressionAttribute, or nil if it holds no JSX.
+	Parts []Node `json:"parts,omitempty"`
+	Range Range  `json:"range"`
 }
 
+func (*JSXExpression) node()             {}
 func (*JSXExpression) jsxChildNode()     {}
 func (e *JSXExpression) GetRange() Range { return e.Range }
 
+// JSXComment represents {/* comment */} within JSX: braces holding only
+// comments, which render nothing.
+type JSXComment struct {
+	Text   string  `json:"text"` // The comments, without the whitespace around them
+	Range  Range   `json:"range"`
+	Trivia *Trivia `json:"trivia,omitempty"`
+}
+
+func (*JSXComment) node()             {}
+func (*JSXComment) jsxChildNode()     {}
+func (c *JSXComment) GetRange() Range { return c.Range }
+
 // GoCode represents pass-through Go code.
 type GoCode struct {
-	Value string
-	Range Range
+	Value string `json:"value"`
+	Range Range  `json:"range"`
 }
 
 func (*GoCode) node()             {}
 func (c *GoCode) GetRange() Range { return c.Range }
 
-// JSXFragment represents <>...</> (fragment without tag).
+// JSXFragment represents <>...</> (fragment without tag), or its long form
+// <Fragment key={...}>...</Fragment>, which can carry a key.
 type JSXFragment struct {
-	Range    Range
-	Children []JSXChild
+	Range      Range       `json:"range"`
+	Long       bool        `json:"long,omitempty"`       // Written <Fragment>...</Fragment>
+	Attributes []Attribute `json:"attributes,omitempty"` // Of the long form: key is the only one allowed
+	Children   []JSXChild  `json:"children"`
+	Trivia     *Trivia     `json:"trivia,omitempty"` // Of the long form
+}
+
+// Key returns the key attribute of a long-form fragment, or nil.
+func (f *JSXFragment) Key() Attribute {
+	for _, attr := range f.Attributes {
+		switch a := attr.(type) {
+		case *StringAttribute:
+			if a.Key == "key" {
+				return a
+			}
+		case *ExpressionAttribute:
+			if a.Key == "key" {
+				return a
+			}
+		}
+	}
+	return nil
 }
 
 func (*JSXFragment) node()             {}
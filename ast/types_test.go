@@ -22,6 +22,27 @@ func TestPositionIsValid(t *testing.T) {
 	}
 }
 
+func TestPositionAdvance(t *testing.T) {
+	start := Position{Offset: 3, Line: 2, Column: 4}
+	tests := []struct {
+		s    string
+		want Position
+	}{
+		{"", start},
+		{"abc", Position{Offset: 6, Line: 2, Column: 7}},
+		{"héllo 😀", Position{Offset: 14, Line: 2, Column: 11}},
+		{"a\nbé", Position{Offset: 8, Line: 3, Column: 3}},
+		{"a\r\n", Position{Offset: 6, Line: 3, Column: 1}},
+		{"a\rb", Position{Offset: 6, Line: 2, Column: 7}},
+		{"\xff", Position{Offset: 4, Line: 2, Column: 5}},
+	}
+	for _, tt := range tests {
+		if got := start.Advance(tt.s); got != tt.want {
+			t.Errorf("Advance(%q) = %+v, want %+v", tt.s, got, tt.want)
+		}
+	}
+}
+
 func TestRangeIsValid(t *testing.T) {
 	tests := []struct {
 		name     string
@@ -63,6 +84,7 @@ func TestAttributeTypes(t *testing.T) {
 	// Verify all attribute types implement Attribute interface
 	var _ Attribute = &StringAttribute{Key: "id", Value: "test"}
 	var _ Attribute = &ExpressionAttribute{Key: "onClick", Expression: "handleClick"}
+	var _ Attribute = &SpreadAttribute{Expression: "props"}
 }
 
 func TestJSXChildTypes(t *testing.T) {
@@ -77,3 +99,53 @@ func TestGoCodeImplementsNode(t *testing.T) {
 	code := &GoCode{Value: "func main() {}"}
 	var _ Node = code
 }
+
+func TestIsComponentTag(t *testing.T) {
+	tests := []struct {
+		tag      string
+		expected bool
+	}{
+		{"div", false},
+		{"my-element", false},
+		{"", false},
+		{"Card", true},
+		{"Écran", true},
+		{"ui.Card", true},
+		{"widgets.forms.Input", true},
+	}
+
+	for _, tt := range tests {
+		if got := IsComponentTag(tt.tag); got != tt.expected {
+			t.Errorf("IsComponentTag(%q) = %v, want %v", tt.tag, got, tt.expected)
+		}
+	}
+}
+
+func TestJSXTextContent(t *testing.T) {
+	tests := []struct {
+		value, want string
+	}{
+		{"Hello", "Hello"},
+		{"Hello ", "Hello "},
+		{" world", " world"},
+		{"a  \t b", "a b"},
+		{" ", " "},
+		{"\n\t\t", ""},
+		{"\n\t\tHello,\n\t\tworld \n\t", "Hello, world"},
+		{"Hello \n\n world", "Hello world"},
+		{"\r\n\tHello\r\n", "Hello"},
+		// No-break spaces are content
+		{"a\u00a0 \u00a0b", "a\u00a0 \u00a0b"},
+	}
+	for _, tt := range tests {
+		text := &JSXText{Value: tt.value}
+		if got := text.Content(); got != tt.want {
+			t.Errorf("Content of %q = %q, want %q", tt.value, got, tt.want)
+		}
+	}
+
+	raw := &JSXText{Value: "a\r\n  b ", Raw: true}
+	if got := raw.Content(); got != "a\n  b " {
+		t.Errorf("Content of raw %q = %q, want \"a\\n  b \"", raw.Value, got)
+	}
+}
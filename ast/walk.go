@@ -0,0 +1,103 @@
+package ast
+
+import "fmt"
+
+// A Visitor's Visit method is invoked for each node encountered by Walk. If
+// the result visitor w is not nil, Walk visits each of the children of node
+// with w, followed by a call of w.Visit(nil).
+type Visitor interface {
+	Visit(node Node) (w Visitor)
+}
+
+// Walk traverses a gox syntax tree in depth-first order, in source order:
+// it starts by calling v.Visit(node); node must not be nil. If the visitor
+// w returned by v.Visit(node) is not nil, Walk is invoked recursively with
+// w for each of the non-nil children of node, followed by a call of
+// w.Visit(nil).
+//
+// The children of an element are its attributes, then its children. The
+// JSX held in Go expressions, as in header={<Title />}, is visited through
+// their Parts.
+func Walk(v Visitor, node Node) {
+	if v = v.Visit(node); v == nil {
+		return
+	}
+
+	switch n := node.(type) {
+	case *GoxFile:
+		walkList(v, n.Nodes)
+
+	case *JSXElement:
+		for _, attr := range n.Attributes {
+			if attr != nil {
+				Walk(v, attr)
+			}
+		}
+		walkChildren(v, n.Children)
+
+	case *JSXFragment:
+		for _, attr := range n.Attributes {
+			if attr != nil {
+				Walk(v, attr)
+			}
+		}
+		walkChildren(v, n.Children)
+
+	case *ExpressionAttribute:
+		walkList(v, n.Parts)
+
+	case *JSXExpression:
+		walkList(v, n.Parts)
+
+	case *GoCode, *JSXText, *JSXComment, *StringAttribute, *SpreadAttribute:
+		// nothing to do
+
+	default:
+		panic(fmt.Sprintf("ast.Walk: unexpected node type %T", n))
+	}
+
+	v.Visit(nil)
+}
+
+func walkList(v Visitor, nodes []Node) {
+	for _, node := range nodes {
+		if node != nil {
+			Walk(v, node)
+		}
+	}
+}
+
+func walkChildren(v Visitor, children []JSXChild) {
+	for _, child := range children {
+		if child != nil {
+			Walk(v, child)
+		}
+	}
+}
+
+type inspector func(Node) bool
+
+func (f inspector) Visit(node Node) Visitor {
+	if f(node) {
+		return f
+	}
+	return nil
+}
+
+// Inspect traverses a gox syntax tree in depth-first order: it starts by
+// calling f(node); node must not be nil. If f returns true, Inspect invokes
+// f recursively for each of the non-nil children of node, followed by a
+// call of f(nil).
+//
+// To visit every element of a file, including those nested in Go
+// expressions:
+//
+//	ast.Inspect(file, func(n ast.Node) bool {
+//		if elem, ok := n.(*ast.JSXElement); ok {
+//			fmt.Println(elem.Tag)
+//		}
+//		return true
+//	})
+func Inspect(node Node, f func(Node) bool) {
+	Walk(inspector(f), node)
+}
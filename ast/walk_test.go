@@ -0,0 +1,109 @@
+package ast
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+)
+
+// walkTree is the tree of
+//
+//	var x = <div id="a" {...p} title={<b />}>hi{/* c */}{f(<i />)}<Fragment key="k"></Fragment></div>
+func walkTree() *GoxFile {
+	return &GoxFile{Nodes: []Node{
+		&GoCode{Value: "var x = "},
+		&JSXElement{
+			Tag: "div",
+			Attributes: []Attribute{
+				&StringAttribute{Key: "id", Value: "a"},
+				&SpreadAttribute{Expression: "p"},
+				&ExpressionAttribute{Key: "title", Expression: "<b />", Parts: []Node{&JSXElement{Tag: "b", SelfClosing: true}}},
+			},
+			Children: []JSXChild{
+				&JSXText{Value: "hi"},
+				&JSXComment{Text: "/* c */"},
+				&JSXExpression{Expression: "f(<i />)", Parts: []Node{
+					&GoCode{Value: "f("},
+					&JSXElement{Tag: "i", SelfClosing: true},
+					&GoCode{Value: ")"},
+				}},
+				&JSXFragment{Long: true, Attributes: []Attribute{&StringAttribute{Key: "key", Value: "k"}}},
+			},
+		},
+	}}
+}
+
+// describe names a node for comparing traversal orders.
+func describe(n Node) string {
+	switch n := n.(type) {
+	case nil:
+		return "end"
+	case *GoxFile:
+		return "file"
+	case *GoCode:
+		return fmt.Sprintf("go(%s)", n.Value)
+	case *JSXElement:
+		return "<" + n.Tag + ">"
+	case *JSXFragment:
+		return "<>"
+	case *StringAttribute:
+		return n.Key + "="
+	case *ExpressionAttribute:
+		return n.Key + "={}"
+	case *SpreadAttribute:
+		return "{..." + n.Expression + "}"
+	case *JSXText:
+		return "text(" + n.Value + ")"
+	case *JSXComment:
+		return "comment"
+	case *JSXExpression:
+		return "{}"
+	}
+	return fmt.Sprintf("%T", n)
+}
+
+func TestInspect(t *testing.T) {
+	var got []string
+	Inspect(walkTree(), func(n Node) bool {
+		got = append(got, describe(n))
+		return true
+	})
+	want := "file go(var x = ) end <div> id= end {...p} end title={} <b> end end text(hi) end comment end " +
+		"{} go(f() end <i> end go()) end end <> key= end end end end"
+	if s := strings.Join(got, " "); s != want {
+		t.Errorf("Inspect visited\n%s\nwant\n%s", s, want)
+	}
+}
+
+func TestInspectPrunes(t *testing.T) {
+	var tags []string
+	Inspect(walkTree(), func(n Node) bool {
+		if _, ok := n.(*ExpressionAttribute); ok {
+			return false
+		}
+		if elem, ok := n.(*JSXElement); ok {
+			tags = append(tags, elem.Tag)
+		}
+		return true
+	})
+	if got := strings.Join(tags, " "); got != "div i" {
+		t.Errorf("Inspect visited elements %q, want \"div i\"", got)
+	}
+}
+
+type countingVisitor map[string]int
+
+func (v countingVisitor) Visit(n Node) Visitor {
+	if n != nil {
+		v[fmt.Sprintf("%T", n)]++
+	}
+	return v
+}
+
+func TestWalk(t *testing.T) {
+	v := countingVisitor{}
+	Walk(v, walkTree())
+	if v["*ast.JSXElement"] != 3 || v["*ast.GoCode"] != 3 || v["*ast.JSXFragment"] != 1 {
+		t.Errorf("Walk counted %v", v)
+	}
+}
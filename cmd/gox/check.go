@@ -0,0 +1,316 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"os/signal"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/germtb/gox/generator"
+)
+
+// ANSI escape sequences used for check output.
+const (
+	ansiReset       = "\033[0m"
+	ansiBold        = "\033[1m"
+	ansiDim         = "\033[2m"
+	ansiRed         = "\033[31m"
+	ansiGreen       = "\033[32m"
+	ansiCyan        = "\033[36m"
+	ansiClearScreen = "\033[2J\033[H"
+)
+
+// diagnosticPattern matches the "file:line:col: " prefix of a diagnostic.
+var diagnosticPattern = regexp.MustCompile(`^(\S+:\d+(?::\d+)?):\s`)
+
+// checkConfig holds configuration for the check command.
+type checkConfig struct {
+	watch      bool
+	interval   time.Duration
+	color      bool // Colorize output
+	clear      bool // Clear the screen between watch runs
+	runtimePkg string
+	staged     bool // Check the packages of the staged .gox files
+	paths      []string
+}
+
+// runCheck runs the check command: parse, generate and type-check .gox files
+// without writing anything to the source tree, printing diagnostics with
+// positions remapped to the .gox sources.
+func runCheck(args []string) error {
+	cfg := &checkConfig{}
+	noColor := false
+
+	fs := flag.NewFlagSet("check", flag.ExitOnError)
+	fs.BoolVar(&cfg.watch, "watch", false, "keep running and re-check as files change")
+	fs.DurationVar(&cfg.interval, "interval", 500*time.Millisecond, "polling interval for -watch")
+	fs.BoolVar(&noColor, "no-color", false, "disable colored output")
+	fs.StringVar(&cfg.runtimePkg, "runtime", "", "runtime package path")
+	fs.BoolVar(&cfg.staged, "staged", false, "check the packages of the staged .gox files (paths from stdin with -)")
+
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	cfg.paths = fs.Args()
+	if cfg.staged {
+		if cfg.watch {
+			return fmt.Errorf("cannot use -watch with -staged")
+		}
+		files, err := stagedPaths(".", cfg.paths, os.Stdin)
+		if err != nil {
+			return err
+		}
+		if len(files) == 0 {
+			return nil
+		}
+		cfg.paths = packageDirs(files)
+	}
+	if len(cfg.paths) == 0 {
+		cfg.paths = []string{"./..."}
+	}
+
+	tty := isTerminal(os.Stdout)
+	cfg.color = tty && !noColor && os.Getenv("NO_COLOR") == ""
+	cfg.clear = tty
+
+	if cfg.watch {
+		return watchCheck(cfg, os.Stdout)
+	}
+
+	count, err := checkOnce(cfg, os.Stdout)
+	if err != nil {
+		return err
+	}
+	if count > 0 {
+		return fmt.Errorf("found %d error(s)", count)
+	}
+	return nil
+}
+
+// watchCheck re-runs the check whenever a .gox or .go file changes, until the
+// process is interrupted.
+func watchCheck(cfg *checkConfig, out io.Writer) error {
+	state := &watchState{find: findCheckFiles}
+
+	interrupt := make(chan os.Signal, 1)
+	signal.Notify(interrupt, os.Interrupt)
+	defer signal.Stop(interrupt)
+
+	first := true
+	for {
+		changed, removed, err := state.scan(cfg.paths)
+		if err != nil {
+			return fmt.Errorf("finding files: %w", err)
+		}
+
+		if first || len(changed) > 0 || len(removed) > 0 {
+			if cfg.clear {
+				fmt.Fprint(out, ansiClearScreen)
+			}
+			status := "File change detected. Starting incremental check..."
+			if first {
+				status = "Starting check in watch mode..."
+			}
+			fmt.Fprintf(out, "%s %s\n\n", cfg.paint(ansiDim, "["+time.Now().Format("15:04:05")+"]"), status)
+			first = false
+
+			count, err := checkOnce(cfg, out)
+			if err != nil {
+				fmt.Fprintf(out, "%s %v\n", cfg.paint(ansiRed, "error:"), err)
+			}
+			if count >= 0 {
+				fmt.Fprintf(out, "\n%s Watching for file changes.\n", cfg.summary(count))
+			}
+		}
+
+		select {
+		case <-interrupt:
+			return nil
+		case <-time.After(cfg.interval):
+		}
+	}
+}
+
+// checkOnce runs a single check and prints its diagnostics to out. It returns
+// the number of diagnostics, or -1 with an error if the check itself could not
+// run, or if go build failed without reporting a positioned diagnostic.
+func checkOnce(cfg *checkConfig, out io.Writer) (int, error) {
+	files, err := findGoxFiles(cfg.paths)
+	if err != nil {
+		return -1, fmt.Errorf("finding files: %w", err)
+	}
+	ctxt := goxBuildContext("")
+	files = filterGoxFiles(ctxt, files)
+
+	tempDir, err := os.MkdirTemp("", "gox-check-*")
+	if err != nil {
+		return -1, fmt.Errorf("creating temp dir: %w", err)
+	}
+	defer os.RemoveAll(tempDir)
+
+	genCfg := &generateConfig{
+		runtimePkg:       cfg.runtimePkg,
+		overlay:          true,
+		overlayFile:      filepath.Join(tempDir, "overlay.json"),
+		tempDir:          tempDir,
+		inMemoryMaps:     true,
+		sourceMapsOutput: make(map[string]*generator.SourceMap),
+		cache:            openOverlayCache(),
+	}
+
+	// Parse and generate every file so all syntax errors are reported at once
+	var diagnostics []string
+	buildFailed := false
+	overlay := Overlay{Replace: make(map[string]string)}
+	for _, f := range files {
+		targetPath, tempFile, err := generateOverlayFile(f, genCfg)
+		if err != nil {
+			diagnostics = append(diagnostics, diagnosticText(f, err))
+			continue
+		}
+		overlay.Replace[targetPath] = tempFile
+	}
+
+	for _, err := range packageConflicts(ctxt, files) {
+		diagnostics = append(diagnostics, err.Error())
+	}
+
+	// Type-checking a package with a broken .gox file would only add noise
+	if len(diagnostics) == 0 {
+		if err := writeOverlay(overlay, genCfg); err != nil {
+			return -1, err
+		}
+		output, failed, err := typeCheck(genCfg.overlayFile, filepath.Join(tempDir, "bin")+string(filepath.Separator), goPackagePaths(cfg.paths))
+		if err != nil {
+			return -1, err
+		}
+		buildFailed = failed
+		remapped := remapErrors(output, genCfg.sourceMapsOutput)
+		for _, line := range strings.Split(strings.TrimRight(remapped, "\n"), "\n") {
+			if line != "" {
+				diagnostics = append(diagnostics, line)
+			}
+		}
+	}
+
+	count := 0
+	for _, d := range diagnostics {
+		if diagnosticPattern.MatchString(d) {
+			count++
+		}
+		fmt.Fprintln(out, cfg.colorize(d))
+	}
+	// Errors such as a missing go.mod or an import cycle have no position
+	if buildFailed && count == 0 {
+		return -1, fmt.Errorf("go build failed")
+	}
+	return count, nil
+}
+
+// packageDirs returns the directories of files, once each, as paths for
+// checkOnce: the whole package of each file is checked.
+func packageDirs(files []string) []string {
+	var dirs []string
+	seen := make(map[string]bool)
+	for _, file := range files {
+		dir := filepath.Dir(file)
+		if !filepath.IsAbs(dir) && dir != "." {
+			dir = "./" + dir
+		}
+		if !seen[dir] {
+			seen[dir] = true
+			dirs = append(dirs, dir)
+		}
+	}
+	return dirs
+}
+
+// typeCheck builds the packages with the overlay applied, discarding any
+// binaries into binDir, and returns the compiler output and whether the build
+// failed. A failed build is not an error; only failing to run the go command
+// is.
+func typeCheck(overlayFile, binDir string, pkgs []string) (string, bool, error) {
+	args := append([]string{"build", "-overlay=" + overlayFile, "-o", binDir}, pkgs...)
+	cmd := exec.Command("go", args...)
+	var buf bytes.Buffer
+	cmd.Stdout = &buf
+	cmd.Stderr = &buf
+
+	err := cmd.Run()
+	var exitErr *exec.ExitError
+	if err != nil && !errors.As(err, &exitErr) {
+		return "", false, fmt.Errorf("running go build: %w", err)
+	}
+	return buf.String(), err != nil, nil
+}
+
+// diagnosticText returns the message for a generation failure. Parser errors
+// already carry a "file:line:col:" prefix, so the wrapping added by
+// generateOverlayFile is dropped for them.
+func diagnosticText(file string, err error) string {
+	if inner := errors.Unwrap(err); inner != nil && strings.HasPrefix(inner.Error(), file+":") {
+		return inner.Error()
+	}
+	return err.Error()
+}
+
+// colorize highlights the position of a diagnostic line and dims package
+// headers ("# pkg") printed by the go command.
+func (cfg *checkConfig) colorize(line string) string {
+	if !cfg.color {
+		return line
+	}
+	if strings.HasPrefix(line, "# ") {
+		return cfg.paint(ansiDim, line)
+	}
+	if m := diagnosticPattern.FindStringSubmatchIndex(line); m != nil {
+		return cfg.paint(ansiCyan, line[:m[3]]) + line[m[3]:]
+	}
+	return line
+}
+
+// summary describes the number of diagnostics found.
+func (cfg *checkConfig) summary(count int) string {
+	switch count {
+	case 0:
+		return cfg.paint(ansiGreen, "Found 0 errors.")
+	case 1:
+		return cfg.paint(ansiBold+ansiRed, "Found 1 error.")
+	default:
+		return cfg.paint(ansiBold+ansiRed, fmt.Sprintf("Found %d errors.", count))
+	}
+}
+
+// paint wraps s in the given ANSI style when color is enabled.
+func (cfg *checkConfig) paint(style, s string) string {
+	if !cfg.color {
+		return s
+	}
+	return style + s + ansiReset
+}
+
+// findCheckFiles finds the files whose changes can affect a check: .gox
+// sources and hand-written .go files. Generated *_gox.go files are skipped.
+func findCheckFiles(paths []string) ([]string, error) {
+	return findFiles(paths, func(path string) bool {
+		return isGoxFile(path) || isMigratableFile(path)
+	})
+}
+
+// isTerminal reports whether f is connected to a terminal.
+func isTerminal(f *os.File) bool {
+	info, err := f.Stat()
+	if err != nil {
+		return false
+	}
+	return info.Mode()&os.ModeCharDevice != 0
+}
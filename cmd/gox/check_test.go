@@ -0,0 +1,100 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestDiagnosticText(t *testing.T) {
+	parseErr := errors.New("app.gox:4:9: unclosed element <div>")
+
+	tests := []struct {
+		name string
+		err  error
+		want string
+	}{
+		{
+			name: "parser error keeps its position",
+			err:  fmt.Errorf("%s: parsing: %w", "app.gox", parseErr),
+			want: "app.gox:4:9: unclosed element <div>",
+		},
+		{
+			name: "other errors are kept whole",
+			err:  fmt.Errorf("%s: reading file: %w", "app.gox", os.ErrNotExist),
+			want: "app.gox: reading file: file does not exist",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := diagnosticText("app.gox", tt.err); got != tt.want {
+				t.Errorf("diagnosticText() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestCheckColorize(t *testing.T) {
+	plain := &checkConfig{}
+	line := "/src/app.gox:3:5: undefined: x"
+	if got := plain.colorize(line); got != line {
+		t.Errorf("colorize without color = %q, want unchanged", got)
+	}
+
+	colored := &checkConfig{color: true}
+	want := ansiCyan + "/src/app.gox:3:5" + ansiReset + ": undefined: x"
+	if got := colored.colorize(line); got != want {
+		t.Errorf("colorize() = %q, want %q", got, want)
+	}
+	if got := colored.colorize("# example.com/app"); got != ansiDim+"# example.com/app"+ansiReset {
+		t.Errorf("colorize(header) = %q, want dimmed", got)
+	}
+}
+
+func TestFindCheckFiles(t *testing.T) {
+	dir := t.TempDir()
+	for _, name := range []string{"app.gox", "app_gox.go", "main.go", "main_test.go", "app_gox_test.go", "README.md"} {
+		if err := os.WriteFile(filepath.Join(dir, name), nil, 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	files, err := findCheckFiles([]string{dir})
+	if err != nil {
+		t.Fatalf("findCheckFiles error: %v", err)
+	}
+
+	var names []string
+	for _, f := range files {
+		names = append(names, filepath.Base(f))
+	}
+	want := []string{"app.gox", "main.go", "main_test.go"}
+	if !reflect.DeepEqual(names, want) {
+		t.Errorf("findCheckFiles() = %v, want %v", names, want)
+	}
+}
+
+func TestCheckFailsWithoutPosition(t *testing.T) {
+	if _, err := exec.LookPath("go"); err != nil {
+		t.Skip("go command not found")
+	}
+	// A package outside any module fails to build without a file position
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "main.go"), []byte("package main\n\nfunc main() {}\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	var out strings.Builder
+	count, err := checkOnce(&checkConfig{paths: []string{dir}}, &out)
+	if err == nil || count >= 0 {
+		t.Errorf("checkOnce = %d, %v; want a failed build\n%s", count, err, out.String())
+	}
+	if out.Len() == 0 {
+		t.Error("the output of go build should be printed")
+	}
+}
@@ -0,0 +1,419 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/germtb/gox/diag"
+)
+
+// completionFlag is a flag offered by shell completion.
+type completionFlag struct {
+	name string // Without the leading dash
+	arg  string // "" for boolean flags, "file" or "dir" for paths, else a value name
+	doc  string
+}
+
+// completionCommand is a subcommand offered by shell completion.
+type completionCommand struct {
+	name  string
+	doc   string
+	flags []completionFlag
+	args  string   // "gox" or "go": files with that extension, "dir", "words", or "" for none
+	words []string // Candidates when args is "words"
+}
+
+// goxCompletions are the gox subcommands. Keep in sync with main and printUsage.
+func goxCompletions() []completionCommand {
+	return []completionCommand{
+		{name: "init", doc: "Create a minimal gox project", args: "dir", flags: []completionFlag{
+			{"module", "path", "module path for a new go.mod"},
+		}},
+		{name: "generate", doc: "Generate .go files from .gox files", args: "gox", flags: []completionFlag{
+			{"o", "dir", "output directory"},
+			{"runtime", "pkg", "runtime package path"},
+			{"parallel", "n", "number of parallel workers"},
+			{"overlay", "", "output overlay JSON instead of writing files"},
+			{"overlay-file", "file", "write overlay JSON to file"},
+			{"watch", "", "regenerate files as they change"},
+			{"interval", "duration", "polling interval for -watch"},
+			{"check", "", "report missing or stale generated files"},
+			{"json", "", "print diagnostics as JSON lines"},
+			{"stdout", "", "print the generated code of a single file"},
+			{"map-stdout", "", "print the source map of a single file"},
+			{"n", "", "print the files that would be written"},
+			{"dry-run", "", "print the files that would be written"},
+			{"line-directives", "", "interleave //line directives naming the .gox files"},
+			{"stdin", "", "generate a single document from stdin"},
+			{"filename", "name", "name of the -stdin document"},
+			{"force", "", "overwrite generated files edited by hand"},
+			{"update-gitignore", "", "add generated-file patterns to the repository .gitignore"},
+			{"v", "", "print progress, timing and a summary"},
+			{"q", "", "print only errors"},
+			{"e", "", "print every error"},
+		}},
+		{name: "watch", doc: "Regenerate .gox files as they change", args: "gox", flags: []completionFlag{
+			{"o", "dir", "output directory"},
+			{"runtime", "pkg", "runtime package path"},
+			{"interval", "duration", "polling interval"},
+			{"v", "", "verbose output"},
+			{"q", "", "print only errors"},
+		}},
+		{name: "check", doc: "Parse, generate and type-check .gox files", args: "gox", flags: []completionFlag{
+			{"watch", "", "re-check as files change"},
+			{"interval", "duration", "polling interval for -watch"},
+			{"no-color", "", "disable colored output"},
+			{"runtime", "pkg", "runtime package path"},
+			{"staged", "", "check the packages of staged .gox files"},
+		}},
+		{name: "fmt", doc: "Format .gox files", args: "gox", flags: []completionFlag{
+			{"w", "", "write result to files"},
+			{"d", "", "display diffs"},
+			{"l", "", "list files that would be formatted"},
+			{"stdin", "", "format stdin to stdout"},
+			{"json", "", "print diagnostics as JSON lines"},
+			{"staged", "", "format the staged content of staged files"},
+			{"n", "", "print the files -w would overwrite"},
+			{"dry-run", "", "print the files -w would overwrite"},
+			{"r", "rules", "rewrite rules applied before formatting"},
+			{"parallel", "n", "number of parallel workers"},
+			{"v", "", "verbose output"},
+		}},
+		{name: "rewrite", doc: "Rename tags and attributes across .gox files", args: "gox", flags: []completionFlag{
+			{"w", "", "write result to files"},
+			{"l", "", "list files that would change"},
+		}},
+		{name: "fix", doc: "Update .gox files to the current gox runtime and syntax", args: "gox", flags: []completionFlag{
+			{"w", "", "write result to files"},
+			{"l", "", "list files that would change"},
+			{"r", "fixes", "comma-separated fixes to apply"},
+			{"list", "", "print the available fixes"},
+			{"runtime", "pkg", "runtime package path"},
+		}},
+		{name: "migrate", doc: "Convert gox.Element call trees in .go files to .gox files", args: "go", flags: []completionFlag{
+			{"w", "", "write .gox files and remove the converted .go files"},
+			{"l", "", "list files that would be converted"},
+			{"runtime", "package", "runtime package whose calls are converted"},
+		}},
+		{name: "doctor", doc: "Diagnose generated files", args: "gox", flags: []completionFlag{
+			{"o", "dir", "output directory"},
+			{"runtime", "pkg", "runtime package path"},
+		}},
+		{name: "list", doc: "List .gox files and their components", args: "gox", flags: []completionFlag{
+			{"json", "", "print one JSON object per file"},
+			{"o", "dir", "output directory"},
+			{"runtime", "pkg", "runtime package path"},
+		}},
+		{name: "parse", doc: "Print the syntax tree of .gox files", args: "gox", flags: []completionFlag{
+			{"json", "", "print the tree of each file as JSON"},
+			{"trivia", "", "keep the whitespace in tags in the tree"},
+			{"strict", "", "report intrinsic tags that are not element names"},
+		}},
+		{name: "graph", doc: "Print the component graph", args: "gox", flags: []completionFlag{
+			{"json", "", "print the graph as JSON"},
+			{"unused", "", "list the components no .gox file renders"},
+			{"runtime", "pkg", "runtime package path"},
+		}},
+		{name: "map", doc: "Translate positions between .gox files and generated code", args: "gox", flags: []completionFlag{
+			{"json", "", "print one JSON object per position"},
+			{"o", "dir", "output directory the files were generated to"},
+			{"map", "file", "source map to use"},
+		}},
+		{name: "explain", doc: "Explain a diagnostic code", args: "words", words: diagCodes()},
+		{name: "hook", doc: "Install a git pre-commit hook", args: "words", words: []string{"install"}, flags: []completionFlag{
+			{"f", "", "replace an existing pre-commit hook"},
+		}},
+		{name: "profile", doc: "Profile rendering a component", args: "words", words: []string{"render"}, flags: []completionFlag{
+			{"component", "name", "component to render, by name or as a JSX element"},
+			{"mem", "", "profile allocations instead of CPU time"},
+			{"benchtime", "duration", "how long to run the render loop"},
+			{"o", "file", "prefix of the profile files written"},
+		}},
+		{name: "daemon", doc: "Keep generated code warm for faster builds", flags: []completionFlag{
+			{"stop", "", "stop the running daemon"},
+			{"status", "", "print the state of the running daemon"},
+			{"flush", "", "drop every cached file"},
+			{"json", "", "print -status as JSON"},
+			{"v", "", "log each request"},
+			{"socket", "file", "socket path"},
+		}},
+		{name: "gen-stubs", doc: "Write generate.go files running gox generate", args: "dir", flags: []completionFlag{
+			{"go-run", "", "run gox with go run"},
+			{"n", "", "print the files that would be written"},
+		}},
+		{name: "env-overlay", doc: "Print a GOFLAGS setting with the .gox overlay", flags: []completionFlag{
+			{"o", "file", "overlay JSON path"},
+			{"shell", "name", "syntax of the printed command: sh, fish or powershell"},
+			{"unset", "", "remove the overlay from GOFLAGS"},
+			{"v", "", "verbose output"},
+		}},
+		{name: "completion", doc: "Print a shell completion script", args: "words", words: []string{"bash", "zsh", "fish"}},
+		{name: "lsp", doc: "Start LSP server", flags: []completionFlag{
+			{"gopls", "file", "gopls binary to run"},
+			{"gopls-args", "args", "flags for gopls"},
+			{"rpc.trace", "", "pass -rpc.trace to gopls"},
+			{"remote", "addr", "pass -remote to gopls"},
+		}},
+		{name: "version", doc: "Print version information"},
+		{name: "help", doc: "Show help"},
+	}
+}
+
+// goCompletions are the go commands most often run through gox, with their
+// common flags. Any other go command is still proxied, without completion.
+func goCompletions() []completionCommand {
+	build := []completionFlag{
+		{"race", "", "enable data race detection"},
+		{"tags", "tags", "build tags"},
+		{"ldflags", "flags", "linker flags"},
+		{"gcflags", "flags", "compiler flags"},
+		{"trimpath", "", "remove file system paths"},
+		{"mod", "mode", "module download mode"},
+		{"v", "", "print package names"},
+		{"x", "", "print commands"},
+	}
+	with := func(extra ...completionFlag) []completionFlag {
+		return append(append([]completionFlag(nil), build...), extra...)
+	}
+	return []completionCommand{
+		{name: "build", doc: "Compile packages", args: "go", flags: with(
+			completionFlag{"o", "file", "output file or directory"},
+			completionFlag{"cover", "", "enable coverage instrumentation"},
+		)},
+		{name: "run", doc: "Compile and run a program", args: "go", flags: with(
+			completionFlag{"exec", "file", "run the binary using this program"},
+		)},
+		{name: "test", doc: "Test packages", args: "go", flags: with(
+			completionFlag{"run", "regexp", "run only matching tests"},
+			completionFlag{"bench", "regexp", "run matching benchmarks"},
+			completionFlag{"count", "n", "run each test n times"},
+			completionFlag{"cover", "", "enable coverage analysis"},
+			completionFlag{"coverprofile", "file", "write a coverage profile"},
+			completionFlag{"failfast", "", "stop after the first failure"},
+			completionFlag{"short", "", "run fewer tests"},
+			completionFlag{"timeout", "duration", "panic after this long"},
+		)},
+		{name: "vet", doc: "Report likely mistakes, including gox analyzers", args: "go", flags: []completionFlag{
+			{"tags", "tags", "build tags"},
+		}},
+		{name: "install", doc: "Compile and install packages", args: "go", flags: with()},
+		{name: "mod", doc: "Module maintenance", args: "words", words: []string{"download", "edit", "graph", "init", "tidy", "vendor", "verify", "why"}},
+		{name: "get", doc: "Add dependencies"},
+		{name: "clean", doc: "Remove object files", args: "go"},
+		{name: "doc", doc: "Show documentation"},
+		{name: "env", doc: "Print Go environment"},
+	}
+}
+
+func diagCodes() []string {
+	var codes []string
+	for _, code := range diag.Codes() {
+		codes = append(codes, string(code))
+	}
+	return codes
+}
+
+// runCompletion prints the completion script for a shell to stdout.
+func runCompletion(args []string) error {
+	if len(args) != 1 {
+		return fmt.Errorf("usage: gox completion bash|zsh|fish")
+	}
+	return writeCompletion(os.Stdout, args[0])
+}
+
+func writeCompletion(w io.Writer, shell string) error {
+	commands := append(goxCompletions(), goCompletions()...)
+	var script string
+	switch shell {
+	case "bash":
+		script = bashCompletion(commands)
+	case "zsh":
+		script = zshCompletion(commands)
+	case "fish":
+		script = fishCompletion(commands)
+	default:
+		return fmt.Errorf("unsupported shell %q (want bash, zsh or fish)", shell)
+	}
+	_, err := io.WriteString(w, script)
+	return err
+}
+
+func bashCompletion(commands []completionCommand) string {
+	var b strings.Builder
+	b.WriteString(`# bash completion for gox
+# Load with: source <(gox completion bash)
+
+_gox() {
+    local cur prev cmd flags valueflags args words
+    cur="${COMP_WORDS[COMP_CWORD]}"
+    prev="${COMP_WORDS[COMP_CWORD-1]}"
+`)
+	var names []string
+	for _, c := range commands {
+		names = append(names, c.name)
+	}
+	fmt.Fprintf(&b, `
+    if [[ $COMP_CWORD -eq 1 ]]; then
+        COMPREPLY=($(compgen -W %q -- "$cur"))
+        return
+    fi
+
+    cmd="${COMP_WORDS[1]}"
+    case "$cmd" in
+`, strings.Join(names, " "))
+	for _, c := range commands {
+		var flags, valueFlags []string
+		for _, f := range c.flags {
+			flags = append(flags, "-"+f.name)
+			if f.arg != "" {
+				valueFlags = append(valueFlags, "-"+f.name)
+			}
+		}
+		fmt.Fprintf(&b, "    %s)\n        flags=%q; valueflags=%q; args=%q; words=%q ;;\n",
+			c.name, strings.Join(flags, " "), strings.Join(valueFlags, " "), c.args, strings.Join(c.words, " "))
+	}
+	b.WriteString(`    *)
+        args=go ;;
+    esac
+
+    if [[ " $valueflags " == *" $prev "* ]]; then
+        COMPREPLY=($(compgen -f -- "$cur"))
+        return
+    fi
+    if [[ $cur == -* ]]; then
+        COMPREPLY=($(compgen -W "$flags" -- "$cur"))
+        return
+    fi
+    case "$args" in
+    gox) COMPREPLY=($(compgen -d -- "$cur") $(compgen -f -X '!*.gox' -- "$cur")) ;;
+    go) COMPREPLY=($(compgen -d -- "$cur") $(compgen -f -X '!*.go' -- "$cur")) ;;
+    dir) COMPREPLY=($(compgen -d -- "$cur")) ;;
+    words) COMPREPLY=($(compgen -W "$words" -- "$cur")) ;;
+    esac
+}
+
+complete -o filenames -F _gox gox
+`)
+	return b.String()
+}
+
+func zshCompletion(commands []completionCommand) string {
+	var b strings.Builder
+	b.WriteString(`#compdef gox
+# zsh completion for gox
+# Load with: source <(gox completion zsh), or save as _gox in $fpath
+
+_gox() {
+    local -a commands
+    commands=(
+`)
+	for _, c := range commands {
+		fmt.Fprintf(&b, "        %s\n", zshQuote(c.name+":"+c.doc))
+	}
+	b.WriteString(`    )
+
+    if (( CURRENT == 2 )); then
+        _describe 'command' commands
+        return
+    fi
+
+    local cmd=$words[2]
+    shift words
+    (( CURRENT-- ))
+    case $cmd in
+`)
+	for _, c := range commands {
+		specs := make([]string, 0, len(c.flags)+1)
+		for _, f := range c.flags {
+			spec := "-" + f.name + "[" + f.doc + "]"
+			switch f.arg {
+			case "":
+			case "file":
+				spec += ":" + f.arg + ":_files"
+			case "dir":
+				spec += ":" + f.arg + ":_files -/"
+			default:
+				spec += ":" + f.arg + ":"
+			}
+			specs = append(specs, zshQuote(spec))
+		}
+		switch c.args {
+		case "gox":
+			specs = append(specs, zshQuote(`*:file:_files -g "*.gox"`))
+		case "go":
+			specs = append(specs, zshQuote(`*:file:_files -g "*.go"`))
+		case "dir":
+			specs = append(specs, zshQuote("*:directory:_files -/"))
+		case "words":
+			specs = append(specs, zshQuote("*:"+c.name+":("+strings.Join(c.words, " ")+")"))
+		}
+		if len(specs) == 0 {
+			continue
+		}
+		fmt.Fprintf(&b, "    %s)\n        _arguments %s ;;\n", c.name, strings.Join(specs, " "))
+	}
+	b.WriteString(`    *)
+        _files ;;
+    esac
+}
+
+if [[ $zsh_eval_context[-1] == loadautofunc ]]; then
+    _gox "$@"
+else
+    compdef _gox gox
+fi
+`)
+	return b.String()
+}
+
+func fishCompletion(commands []completionCommand) string {
+	var b strings.Builder
+	b.WriteString(`# fish completion for gox
+# Load with: gox completion fish | source
+
+complete -c gox -f
+`)
+	for _, c := range commands {
+		fmt.Fprintf(&b, "complete -c gox -n __fish_use_subcommand -a %s -d %s\n", c.name, fishQuote(c.doc))
+	}
+	for _, c := range commands {
+		cond := fishQuote("__fish_seen_subcommand_from " + c.name)
+		for _, f := range c.flags {
+			opt := "-o " + f.name
+			if len(f.name) == 1 {
+				opt = "-s " + f.name
+			}
+			switch f.arg {
+			case "":
+			case "file", "dir":
+				opt += " -rF"
+			default:
+				opt += " -r"
+			}
+			fmt.Fprintf(&b, "complete -c gox -n %s %s -d %s\n", cond, opt, fishQuote(f.doc))
+		}
+		switch c.args {
+		case "gox":
+			fmt.Fprintf(&b, "complete -c gox -n %s -a '(__fish_complete_suffix .gox)'\n", cond)
+		case "go":
+			fmt.Fprintf(&b, "complete -c gox -n %s -a '(__fish_complete_suffix .go)'\n", cond)
+		case "dir":
+			fmt.Fprintf(&b, "complete -c gox -n %s -a '(__fish_complete_directories)'\n", cond)
+		case "words":
+			fmt.Fprintf(&b, "complete -c gox -n %s -a %s\n", cond, fishQuote(strings.Join(c.words, " ")))
+		}
+	}
+	return b.String()
+}
+
+// zshQuote quotes s for zsh as a single-quoted word.
+func zshQuote(s string) string {
+	return "'" + strings.ReplaceAll(s, "'", `'\''`) + "'"
+}
+
+// fishQuote quotes s for fish as a single-quoted word.
+func fishQuote(s string) string {
+	return "'" + strings.NewReplacer(`\`, `\\`, "'", `\'`).Replace(s) + "'"
+}
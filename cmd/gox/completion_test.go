@@ -0,0 +1,43 @@
+package main
+
+import (
+	"bytes"
+	"os/exec"
+	"strings"
+	"testing"
+)
+
+func TestWriteCompletion(t *testing.T) {
+	for _, shell := range []string{"bash", "zsh", "fish"} {
+		t.Run(shell, func(t *testing.T) {
+			var buf bytes.Buffer
+			if err := writeCompletion(&buf, shell); err != nil {
+				t.Fatalf("writeCompletion: %v", err)
+			}
+			script := buf.String()
+			for _, want := range []string{"generate", "rewrite", "completion", "test", "overlay-file", "coverprofile", ".gox", "GOX0001"} {
+				if !strings.Contains(script, want) {
+					t.Errorf("%s script does not mention %q", shell, want)
+				}
+			}
+
+			// Syntax-check the script if the shell is installed
+			path, err := exec.LookPath(shell)
+			if err != nil {
+				return
+			}
+			cmd := exec.Command(path, "-n")
+			cmd.Stdin = &buf
+			if shell == "fish" {
+				cmd.Args = append(cmd.Args, "--no-config")
+			}
+			if out, err := cmd.CombinedOutput(); err != nil {
+				t.Errorf("%s -n: %v\n%s", shell, err, out)
+			}
+		})
+	}
+
+	if err := writeCompletion(&bytes.Buffer{}, "tcsh"); err == nil {
+		t.Error("expected an error for an unsupported shell")
+	}
+}
@@ -0,0 +1,46 @@
+package main
+
+import (
+	"go/build"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// goxBuildContext returns the build context go commands use: the GOOS and
+// GOARCH of the environment, and the tags of a -tags flag.
+func goxBuildContext(tags string) *build.Context {
+	ctxt := build.Default
+	ctxt.BuildTags = strings.FieldsFunc(tags, func(r rune) bool { return r == ',' || r == ' ' })
+	return &ctxt
+}
+
+// matchGoxFile reports whether a .gox file is part of the build in ctxt,
+// by its //go:build line and its _GOOS and _GOARCH file name suffixes, like
+// a .go file. Files whose header cannot be read are kept, so generating
+// them reports the problem.
+func matchGoxFile(ctxt *build.Context, path string) bool {
+	c := *ctxt
+	c.OpenFile = func(path string) (io.ReadCloser, error) {
+		return os.Open(strings.TrimSuffix(path, ".go") + ".gox")
+	}
+	dir, name := filepath.Split(path)
+	if dir == "" {
+		dir = "."
+	}
+	// go/build only matches Go files; the header of a .gox file is Go
+	ok, err := c.MatchFile(dir, strings.TrimSuffix(name, ".gox")+".go")
+	return ok || err != nil
+}
+
+// filterGoxFiles returns the files that are part of the build in ctxt.
+func filterGoxFiles(ctxt *build.Context, files []string) []string {
+	var matched []string
+	for _, f := range files {
+		if matchGoxFile(ctxt, f) {
+			matched = append(matched, f)
+		}
+	}
+	return matched
+}
@@ -0,0 +1,192 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"os"
+	"os/exec"
+	"path"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/germtb/gox/generator"
+	"github.com/germtb/gox/internal/atomicfile"
+)
+
+// coverBlockPattern matches a block line of a coverage profile:
+// "import/path/file.go:startLine.startCol,endLine.endCol numStmt count".
+var coverBlockPattern = regexp.MustCompile(`^(.+\.go):(\d+)\.(\d+),(\d+)\.(\d+)( .*)$`)
+
+// coverProfileFlag returns the file named by a -coverprofile flag in args, or
+// "" if there is none.
+func coverProfileFlag(args []string) string {
+	for i, arg := range args {
+		name, value, hasValue := strings.Cut(strings.TrimPrefix(arg, "-"), "=")
+		if name != "coverprofile" && name != "-coverprofile" {
+			continue
+		}
+		if hasValue {
+			return value
+		}
+		if i+1 < len(args) {
+			return args[i+1]
+		}
+	}
+	return ""
+}
+
+// remapCoverProfile rewrites the coverage profile at profilePath so blocks in
+// generated *_gox.go files refer to the .gox sources they came from. The
+// profile names files by import path, so the packages of the generated files
+// are resolved with go list, using the same overlay as the test run.
+func remapCoverProfile(profilePath, overlayFile string, sourceMaps map[string]*generator.SourceMap) error {
+	data, err := os.ReadFile(profilePath)
+	if err != nil {
+		return fmt.Errorf("reading coverage profile: %w", err)
+	}
+	if len(sourceMaps) == 0 {
+		return nil
+	}
+
+	dirs := make(map[string]bool)
+	for target := range sourceMaps {
+		dirs[filepath.Dir(target)] = true
+	}
+	importPaths, err := listImportPaths(overlayFile, dirs)
+	if err != nil {
+		return err
+	}
+
+	remapped := remapCoverage(string(data), importPaths, sourceMaps)
+	if err := atomicfile.WriteFile(profilePath, []byte(remapped), 0644); err != nil {
+		return fmt.Errorf("writing coverage profile: %w", err)
+	}
+	return nil
+}
+
+// listImportPaths maps the import path of each package directory in dirs to
+// the directory. Directories that are not Go packages are skipped.
+func listImportPaths(overlayFile string, dirs map[string]bool) (map[string]string, error) {
+	args := []string{"list", "-e", "-overlay=" + overlayFile, "-f", "{{.ImportPath}}\t{{.Dir}}"}
+	for dir := range dirs {
+		args = append(args, dir)
+	}
+
+	cmd := exec.Command("go", args...)
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	out, err := cmd.Output()
+	if err != nil {
+		return nil, fmt.Errorf("listing packages: %v: %s", err, strings.TrimSpace(stderr.String()))
+	}
+
+	importPaths := make(map[string]string)
+	scanner := bufio.NewScanner(bytes.NewReader(out))
+	for scanner.Scan() {
+		importPath, dir, ok := strings.Cut(scanner.Text(), "\t")
+		if ok && dir != "" {
+			importPaths[importPath] = dir
+		}
+	}
+	return importPaths, nil
+}
+
+// remapCoverage rewrites the blocks of a coverage profile that refer to
+// generated files. importPaths maps import paths to package directories, and
+// sourceMaps is keyed by the absolute path of each generated file. Blocks in
+// files without a source map are left as they are.
+func remapCoverage(profile string, importPaths map[string]string, sourceMaps map[string]*generator.SourceMap) string {
+	var out strings.Builder
+	sources := make(sourceLines)
+	for _, line := range splitLines(profile) {
+		text := strings.TrimSuffix(line, "\n")
+		m := coverBlockPattern.FindStringSubmatch(text)
+		if m == nil {
+			out.WriteString(line)
+			continue
+		}
+
+		dir, ok := importPaths[path.Dir(m[1])]
+		if !ok {
+			out.WriteString(line)
+			continue
+		}
+		sm := sourceMaps[filepath.Join(dir, path.Base(m[1]))]
+		if sm == nil {
+			out.WriteString(line)
+			continue
+		}
+
+		startLine, startCol, ok1 := coverPosition(sm, m[2], m[3])
+		endLine, endCol, ok2 := coverPosition(sm, m[4], m[5])
+		if !ok1 || !ok2 {
+			out.WriteString(line)
+			continue
+		}
+		// Mappings are sparse, so the end can land before the start
+		if endLine < startLine || endLine == startLine && endCol < startCol {
+			endLine, endCol = startLine, startCol
+		}
+		startCol = sources.byteColumn(sm.SourceFile, startLine, startCol)
+		endCol = sources.byteColumn(sm.SourceFile, endLine, endCol)
+
+		// The source is found relative to the generated file, which may be
+		// in a .goxoutdir output tree
+		rel := filepath.Base(sm.SourceFile)
+		if r, err := filepath.Rel(dir, sm.SourceFile); err == nil {
+			rel = r
+		}
+		file := path.Join(path.Dir(m[1]), filepath.ToSlash(rel))
+		fmt.Fprintf(&out, "%s:%d.%d,%d.%d%s", file, startLine, startCol, endLine, endCol, m[6])
+		if strings.HasSuffix(line, "\n") {
+			out.WriteString("\n")
+		}
+	}
+	return out.String()
+}
+
+// coverPosition maps a 1-indexed line and byte column of a generated file
+// to the .gox source.
+func coverPosition(sm *generator.SourceMap, line, col string) (int, int, bool) {
+	l, _ := strconv.Atoi(line)
+	c, _ := strconv.Atoi(col)
+	if l < 1 || c < 1 {
+		return 0, 0, false
+	}
+	tgtLine := uint32(l - 1)
+	pos, ok := sm.SourcePositionFromTarget(tgtLine, sm.TargetRuneColumn(tgtLine, uint32(c-1)))
+	if !ok {
+		return 0, 0, false
+	}
+	return int(pos.Line) + 1, int(pos.Column) + 1, true
+}
+
+// sourceLines caches the lines of .gox files by path.
+type sourceLines map[string][]string
+
+// byteColumn returns the byte column of the 1-indexed rune column col on a
+// line of the .gox file at path, since go tool cover counts bytes. The
+// column is returned as it is if the file cannot be read.
+func (s sourceLines) byteColumn(path string, line, col int) int {
+	lines, ok := s[path]
+	if !ok {
+		if data, err := os.ReadFile(path); err == nil {
+			lines = strings.Split(string(data), "\n")
+		}
+		s[path] = lines
+	}
+	if line < 1 || line > len(lines) {
+		return col
+	}
+	text := lines[line-1]
+	for i := range text {
+		if col == 1 {
+			return i + 1
+		}
+		col--
+	}
+	return len(text) + col
+}
@@ -0,0 +1,48 @@
+package main
+
+import "testing"
+
+func TestCoverProfileFlag(t *testing.T) {
+	tests := []struct {
+		args []string
+		want string
+	}{
+		{args: []string{"-v", "-coverprofile=c.out"}, want: "c.out"},
+		{args: []string{"-coverprofile", "c.out"}, want: "c.out"},
+		{args: []string{"--coverprofile=c.out"}, want: "c.out"},
+		{args: []string{"-cover", "-run", "TestX"}, want: ""},
+	}
+
+	for _, tt := range tests {
+		if got := coverProfileFlag(tt.args); got != tt.want {
+			t.Errorf("coverProfileFlag(%q) = %q, want %q", tt.args, got, tt.want)
+		}
+	}
+}
+
+func TestRemapCoverage(t *testing.T) {
+	maps := testSourceMaps()
+	importPaths := map[string]string{"example.com/app": "/src"}
+
+	profile := "mode: set\n" +
+		"example.com/app/app_gox.go:10.2,10.12 1 1\n" +
+		"example.com/app/main.go:5.13,7.2 1 0\n" +
+		"example.com/other/app_gox.go:10.2,10.12 1 1\n"
+	want := "mode: set\n" +
+		"example.com/app/app.gox:3.2,3.12 1 1\n" +
+		"example.com/app/main.go:5.13,7.2 1 0\n" +
+		"example.com/other/app_gox.go:10.2,10.12 1 1\n"
+
+	if got := remapCoverage(profile, importPaths, maps); got != want {
+		t.Errorf("remapCoverage:\ngot:\n%s\nwant:\n%s", got, want)
+	}
+
+	// A file generated into a .goxoutdir tree refers to its source outside it
+	maps["/src/gen/app_gox.go"] = maps["/src/app_gox.go"]
+	importPaths["example.com/app/gen"] = "/src/gen"
+	profile = "mode: set\nexample.com/app/gen/app_gox.go:10.2,10.12 1 1\n"
+	want = "mode: set\nexample.com/app/app.gox:3.2,3.12 1 1\n"
+	if got := remapCoverage(profile, importPaths, maps); got != want {
+		t.Errorf("remapCoverage in an output tree:\ngot:\n%s\nwant:\n%s", got, want)
+	}
+}
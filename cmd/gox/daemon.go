@@ -0,0 +1,578 @@
+package main
+
+import (
+	"bufio"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"runtime"
+	"sort"
+	"strings"
+	"sync"
+	"text/tabwriter"
+	"time"
+
+	"github.com/germtb/gox/ast"
+	"github.com/germtb/gox/diag"
+	"github.com/germtb/gox/generator"
+	"github.com/germtb/gox/parser"
+)
+
+// daemonRequest is a request to the daemon. Requests and responses are
+// exchanged as JSON values, one per line, over a unix socket that only the
+// user who started the daemon can connect to.
+type daemonRequest struct {
+	Op             string // "generate", "stats", "flush" or "stop"
+	Path           string // Absolute path of the .gox file to generate, in the project
+	RuntimePackage string
+	LineDirectives string // Name //line directives give the file, if any
+}
+
+type daemonResponse struct {
+	Output     []byte
+	SourceMap  json.RawMessage
+	Cached     bool             // Whether the result came from the cache
+	Error      string           // Parse or generate error
+	Diagnostic *diag.Diagnostic // The parse or generate error, if it is a coded diagnostic
+	Stats      *daemonStats     // For "stats"
+	Flushed    int              // Number of files dropped by "flush"
+}
+
+// daemonStats describes the state of a daemon, for operating it.
+type daemonStats struct {
+	Started    time.Time
+	Hits       int    // Requests answered from the cache
+	Misses     int    // Requests that generated a file
+	CacheBytes int    // Generated code and source maps held in memory
+	HeapBytes  uint64 // Heap in use by the daemon process
+	Files      []daemonFileStats
+}
+
+// daemonFileStats describes a cached file.
+type daemonFileStats struct {
+	Path      string
+	Bytes     int
+	Hits      int
+	Generated time.Time
+}
+
+// HitRate returns the fraction of requests answered from the cache.
+func (s *daemonStats) HitRate() float64 {
+	if s.Hits+s.Misses == 0 {
+		return 0
+	}
+	return float64(s.Hits) / float64(s.Hits+s.Misses)
+}
+
+// daemonEntry is a generated file, valid while the source keeps its stamp.
+type daemonEntry struct {
+	stamp      fileStamp
+	runtimePkg string
+	lineDirs   string
+	file       *ast.GoxFile
+	output     []byte
+	sourceMap  []byte
+	generated  time.Time
+	hits       int
+}
+
+// daemon keeps generated files in memory across builds.
+type daemon struct {
+	root    string // Project directory; only files in it are generated
+	verbose bool
+	started time.Time
+
+	mu      sync.Mutex
+	entries map[string]*daemonEntry
+	hits    int
+	misses  int
+}
+
+func newDaemon(root string, verbose bool) *daemon {
+	return &daemon{root: realPath(root), verbose: verbose, started: time.Now(), entries: make(map[string]*daemonEntry)}
+}
+
+// daemonSocketPath returns the socket of the daemon for the project in dir.
+// Sockets live in a directory private to the current user: gox under
+// $XDG_RUNTIME_DIR, or gox/daemon under the user cache directory.
+func daemonSocketPath(dir string) (string, error) {
+	abs, err := filepath.Abs(dir)
+	if err != nil {
+		return "", err
+	}
+	socketDir := os.Getenv("XDG_RUNTIME_DIR")
+	if socketDir != "" {
+		socketDir = filepath.Join(socketDir, "gox")
+	} else {
+		userCache, err := os.UserCacheDir()
+		if err != nil {
+			return "", err
+		}
+		socketDir = filepath.Join(userCache, "gox", "daemon")
+	}
+	sum := sha256.Sum256([]byte(abs))
+	return filepath.Join(socketDir, "gox-daemon-"+hex.EncodeToString(sum[:6])+".sock"), nil
+}
+
+// makeSocketDir creates dir, the directory of the default socket, so that
+// only the current user can enter it.
+func makeSocketDir(dir string) error {
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return err
+	}
+	info, err := os.Lstat(dir)
+	if err != nil {
+		return err
+	}
+	if !info.IsDir() || !ownedByUser(info) {
+		return fmt.Errorf("%s is not a directory of the current user", dir)
+	}
+	if info.Mode().Perm()&0077 != 0 {
+		return os.Chmod(dir, 0700)
+	}
+	return nil
+}
+
+// realPath returns the absolute path of path with symlinks resolved, as far
+// as they resolve.
+func realPath(path string) string {
+	if abs, err := filepath.Abs(path); err == nil {
+		path = abs
+	}
+	if real, err := filepath.EvalSymlinks(path); err == nil {
+		path = real
+	}
+	return path
+}
+
+// inProject reports whether path is root or a file under it, once symlinks
+// are resolved. root must be a realPath.
+func inProject(root, path string) bool {
+	rel, err := filepath.Rel(root, realPath(path))
+	return err == nil && rel != ".." && !strings.HasPrefix(rel, ".."+string(filepath.Separator))
+}
+
+// runDaemon runs the daemon for the current directory until it is
+// interrupted or stopped with -stop. -status and -flush operate a running
+// daemon instead.
+func runDaemon(args []string) error {
+	var stop, status, flush, asJSON, verbose bool
+	var socket string
+	fs := flag.NewFlagSet("daemon", flag.ExitOnError)
+	fs.BoolVar(&stop, "stop", false, "stop the running daemon")
+	fs.BoolVar(&status, "status", false, "print the cached files, hit rate and memory use of the running daemon")
+	fs.BoolVar(&flush, "flush", false, "drop every cached file from the running daemon")
+	fs.BoolVar(&asJSON, "json", false, "print -status as JSON")
+	fs.BoolVar(&verbose, "v", false, "log each request")
+	fs.StringVar(&socket, "socket", "", "socket path (default: derived from the current directory)")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if socket == "" {
+		var err error
+		if socket, err = daemonSocketPath("."); err != nil {
+			return fmt.Errorf("locating the daemon socket: %w", err)
+		}
+		if !stop && !status && !flush {
+			if err := makeSocketDir(filepath.Dir(socket)); err != nil {
+				return fmt.Errorf("creating the daemon socket directory: %w", err)
+			}
+		}
+	}
+
+	if stop || status || flush {
+		client := dialDaemon(socket)
+		if client == nil {
+			return fmt.Errorf("no daemon is running on %s", socket)
+		}
+		defer client.close()
+		switch {
+		case stop:
+			return client.stop()
+		case flush:
+			n, err := client.flush()
+			if err != nil {
+				return err
+			}
+			fmt.Printf("Flushed %d cached file(s)\n", n)
+			return nil
+		}
+		stats, err := client.stats()
+		if err != nil {
+			return err
+		}
+		if asJSON {
+			enc := json.NewEncoder(os.Stdout)
+			enc.SetIndent("", "  ")
+			return enc.Encode(stats)
+		}
+		printDaemonStats(os.Stdout, socket, stats)
+		return nil
+	}
+
+	ln, err := listenDaemon(socket)
+	if err != nil {
+		return err
+	}
+	fmt.Fprintf(os.Stderr, "gox daemon listening on %s (Ctrl+C to stop)\n", socket)
+
+	interrupt := make(chan os.Signal, 1)
+	signal.Notify(interrupt, os.Interrupt)
+	go func() {
+		<-interrupt
+		ln.Close()
+	}()
+
+	d := newDaemon(".", verbose)
+	d.serve(ln)
+	return nil
+}
+
+// listenDaemon listens on socket, replacing a socket left behind by a daemon
+// that did not shut down cleanly. The socket is readable and writable only
+// by the current user.
+func listenDaemon(socket string) (net.Listener, error) {
+	if client := dialDaemon(socket); client != nil {
+		client.close()
+		return nil, fmt.Errorf("a daemon is already running on %s", socket)
+	}
+	os.Remove(socket)
+	ln, err := net.Listen("unix", socket)
+	if err != nil {
+		return nil, fmt.Errorf("listening on %s: %w", socket, err)
+	}
+	if err := os.Chmod(socket, 0600); err != nil {
+		ln.Close()
+		return nil, fmt.Errorf("listening on %s: %w", socket, err)
+	}
+	return ln, nil
+}
+
+// serve handles connections until ln is closed or a client sends "stop".
+func (d *daemon) serve(ln net.Listener) {
+	defer ln.Close()
+	var wg sync.WaitGroup
+	defer wg.Wait()
+	for {
+		conn, err := ln.Accept()
+		if err != nil {
+			return
+		}
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			defer conn.Close()
+			if d.handle(conn) {
+				ln.Close()
+			}
+		}()
+	}
+}
+
+// handle answers the requests on conn. It reports whether the daemon was
+// asked to stop.
+func (d *daemon) handle(conn net.Conn) bool {
+	dec := json.NewDecoder(bufio.NewReader(conn))
+	enc := json.NewEncoder(conn)
+	for {
+		var req daemonRequest
+		if err := dec.Decode(&req); err != nil {
+			return false
+		}
+		switch req.Op {
+		case "stop":
+			enc.Encode(daemonResponse{})
+			return true
+		case "generate":
+			if err := enc.Encode(d.generate(req)); err != nil {
+				return false
+			}
+		case "stats":
+			if err := enc.Encode(daemonResponse{Stats: d.stats()}); err != nil {
+				return false
+			}
+		case "flush":
+			if err := enc.Encode(daemonResponse{Flushed: d.flush()}); err != nil {
+				return false
+			}
+		default:
+			enc.Encode(daemonResponse{Error: fmt.Sprintf("unknown op %q", req.Op)})
+		}
+	}
+}
+
+// generateErrorResponse returns the response to a request whose file does
+// not generate.
+func generateErrorResponse(err error) daemonResponse {
+	resp := daemonResponse{Error: fmt.Sprintf("generating: %v", err)}
+	resp.Diagnostic, _ = diag.As(err)
+	return resp
+}
+
+// generate returns the generated code for req.Path, from the cache if the
+// file has not changed since it was last generated. Files outside the
+// project are refused.
+func (d *daemon) generate(req daemonRequest) daemonResponse {
+	if !filepath.IsAbs(req.Path) || !inProject(d.root, req.Path) {
+		return daemonResponse{Error: fmt.Sprintf("%s is outside the project in %s", req.Path, d.root)}
+	}
+	info, err := os.Stat(req.Path)
+	if err != nil {
+		return daemonResponse{Error: fmt.Sprintf("reading file: %v", err)}
+	}
+	stamp := fileStamp{modTime: info.ModTime(), size: info.Size()}
+	// Options as in process; the schema may have changed since the file
+	// was cached
+	opts, err := generatorOptions(req.Path, req.RuntimePackage, req.LineDirectives)
+	if err != nil {
+		return daemonResponse{Error: err.Error()}
+	}
+	sch := opts.Schema
+
+	d.mu.Lock()
+	entry := d.entries[req.Path]
+	if entry != nil && entry.stamp == stamp && entry.runtimePkg == req.RuntimePackage && entry.lineDirs == req.LineDirectives {
+		entry.hits++
+		d.hits++
+		d.mu.Unlock()
+		if sch != nil && sch.Strict {
+			if errs := sch.Check(entry.file); len(errs) > 0 {
+				return generateErrorResponse(errs[0])
+			}
+		}
+		d.logf("cached %s", req.Path)
+		return daemonResponse{Output: entry.output, SourceMap: entry.sourceMap, Cached: true}
+	}
+	d.misses++
+	d.mu.Unlock()
+
+	src, err := os.ReadFile(req.Path)
+	if err != nil {
+		return daemonResponse{Error: fmt.Sprintf("reading file: %v", err)}
+	}
+	file, err := parser.Parse(req.Path, src)
+	if err != nil {
+		resp := daemonResponse{Error: fmt.Sprintf("parsing: %v", err)}
+		resp.Diagnostic, _ = diag.As(err)
+		return resp
+	}
+	output, sourceMap, err := generator.Generate(file, opts)
+	if err != nil {
+		return generateErrorResponse(err)
+	}
+	sourceMapData, err := json.Marshal(sourceMap)
+	if err != nil {
+		return daemonResponse{Error: fmt.Sprintf("serializing source map: %v", err)}
+	}
+
+	d.mu.Lock()
+	d.entries[req.Path] = &daemonEntry{
+		stamp:      stamp,
+		runtimePkg: req.RuntimePackage,
+		lineDirs:   req.LineDirectives,
+		file:       file,
+		output:     output,
+		sourceMap:  sourceMapData,
+		generated:  time.Now(),
+	}
+	d.mu.Unlock()
+	d.logf("generated %s", req.Path)
+	return daemonResponse{Output: output, SourceMap: sourceMapData}
+}
+
+// stats returns the state of the cache, with files sorted by path.
+func (d *daemon) stats() *daemonStats {
+	var mem runtime.MemStats
+	runtime.ReadMemStats(&mem)
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	stats := &daemonStats{Started: d.started, Hits: d.hits, Misses: d.misses, HeapBytes: mem.HeapAlloc}
+	for path, entry := range d.entries {
+		size := len(entry.output) + len(entry.sourceMap)
+		stats.CacheBytes += size
+		stats.Files = append(stats.Files, daemonFileStats{Path: path, Bytes: size, Hits: entry.hits, Generated: entry.generated})
+	}
+	sort.Slice(stats.Files, func(i, j int) bool { return stats.Files[i].Path < stats.Files[j].Path })
+	return stats
+}
+
+// flush drops every cached file and returns how many there were.
+func (d *daemon) flush() int {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	n := len(d.entries)
+	d.entries = make(map[string]*daemonEntry)
+	d.logf("flushed %d file(s)", n)
+	return n
+}
+
+// printDaemonStats prints stats for people.
+func printDaemonStats(w io.Writer, socket string, stats *daemonStats) {
+	fmt.Fprintf(w, "gox daemon on %s, up %s\n", socket, time.Since(stats.Started).Round(time.Second))
+	fmt.Fprintf(w, "cache: %d file(s), %s; %d hit(s), %d miss(es), %.1f%% hit rate\n",
+		len(stats.Files), formatBytes(uint64(stats.CacheBytes)), stats.Hits, stats.Misses, 100*stats.HitRate())
+	fmt.Fprintf(w, "heap:  %s\n", formatBytes(stats.HeapBytes))
+	if len(stats.Files) == 0 {
+		return
+	}
+	fmt.Fprintln(w)
+	wd, _ := os.Getwd()
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "FILE\tSIZE\tHITS\tGENERATED")
+	for _, f := range stats.Files {
+		path := f.Path
+		if rel, err := filepath.Rel(wd, path); err == nil && !strings.HasPrefix(rel, "..") {
+			path = rel
+		}
+		fmt.Fprintf(tw, "%s\t%s\t%d\t%s ago\n", path, formatBytes(uint64(f.Bytes)), f.Hits, time.Since(f.Generated).Round(time.Second))
+	}
+	tw.Flush()
+}
+
+// formatBytes formats n bytes with a binary unit.
+func formatBytes(n uint64) string {
+	const unit = 1024
+	if n < unit {
+		return fmt.Sprintf("%d B", n)
+	}
+	div, exp := uint64(unit), 0
+	for m := n / unit; m >= unit; m /= unit {
+		div *= unit
+		exp++
+	}
+	return fmt.Sprintf("%.1f %ciB", float64(n)/float64(div), "KMGTPE"[exp])
+}
+
+func (d *daemon) logf(format string, args ...any) {
+	if d.verbose {
+		fmt.Fprintf(os.Stderr, "gox daemon: "+format+"\n", args...)
+	}
+}
+
+// daemonClient is a connection to a running daemon.
+type daemonClient struct {
+	root string // Project directory of the daemon, if dialed for the project
+	conn net.Conn
+	dec  *json.Decoder
+	enc  *json.Encoder
+}
+
+// errDaemonUnavailable wraps failures to talk to the daemon, as opposed to
+// errors generating a file.
+var errDaemonUnavailable = errors.New("daemon unavailable")
+
+// dialDaemon connects to the daemon on socket, or returns nil if none is
+// running, the socket does not belong to the current user, or GOX_DAEMON
+// is "off".
+func dialDaemon(socket string) *daemonClient {
+	if os.Getenv("GOX_DAEMON") == "off" {
+		return nil
+	}
+	if info, err := os.Lstat(socket); err != nil || !ownedByUser(info) {
+		return nil
+	}
+	conn, err := net.DialTimeout("unix", socket, 200*time.Millisecond)
+	if err != nil {
+		return nil
+	}
+	return &daemonClient{conn: conn, dec: json.NewDecoder(bufio.NewReader(conn)), enc: json.NewEncoder(conn)}
+}
+
+// dialProjectDaemon connects to the daemon for the project in the current
+// directory, or returns nil as dialDaemon does.
+func dialProjectDaemon() *daemonClient {
+	socket, err := daemonSocketPath(".")
+	if err != nil {
+		return nil
+	}
+	c := dialDaemon(socket)
+	if c != nil {
+		c.root = realPath(".")
+	}
+	return c
+}
+
+// serves reports whether the daemon generates path: a daemon dialed for
+// the project only generates the files in it.
+func (c *daemonClient) serves(path string) bool {
+	return c.root == "" || inProject(c.root, path)
+}
+
+func (c *daemonClient) call(req daemonRequest) (daemonResponse, error) {
+	var resp daemonResponse
+	if err := c.enc.Encode(req); err != nil {
+		return resp, fmt.Errorf("%w: %v", errDaemonUnavailable, err)
+	}
+	if err := c.dec.Decode(&resp); err != nil {
+		return resp, fmt.Errorf("%w: %v", errDaemonUnavailable, err)
+	}
+	return resp, nil
+}
+
+// generate generates the .gox file at path. Errors that are not about the
+// file itself wrap errDaemonUnavailable.
+func (c *daemonClient) generate(path, runtimePkg, lineDirectives string) ([]byte, *generator.SourceMap, error) {
+	abs, err := filepath.Abs(path)
+	if err != nil {
+		return nil, nil, err
+	}
+	resp, err := c.call(daemonRequest{Op: "generate", Path: abs, RuntimePackage: runtimePkg, LineDirectives: lineDirectives})
+	if err != nil {
+		return nil, nil, err
+	}
+	if resp.Diagnostic != nil {
+		// Wrapped as generating in process would
+		if strings.HasPrefix(resp.Error, "generating: ") {
+			return nil, nil, fmt.Errorf("generating: %w", resp.Diagnostic)
+		}
+		return nil, nil, fmt.Errorf("parsing: %w", resp.Diagnostic)
+	}
+	if resp.Error != "" {
+		return nil, nil, errors.New(resp.Error)
+	}
+	sourceMap, err := generator.FromJSON(resp.SourceMap)
+	if err != nil {
+		return nil, nil, fmt.Errorf("%w: reading source map: %v", errDaemonUnavailable, err)
+	}
+	return resp.Output, sourceMap, nil
+}
+
+func (c *daemonClient) stats() (*daemonStats, error) {
+	resp, err := c.call(daemonRequest{Op: "stats"})
+	if err != nil {
+		return nil, err
+	}
+	if resp.Stats == nil {
+		return nil, fmt.Errorf("%w: the daemon does not report stats; restart it", errDaemonUnavailable)
+	}
+	return resp.Stats, nil
+}
+
+func (c *daemonClient) flush() (int, error) {
+	resp, err := c.call(daemonRequest{Op: "flush"})
+	if err != nil {
+		return 0, err
+	}
+	if resp.Error != "" {
+		return 0, errors.New(resp.Error)
+	}
+	return resp.Flushed, nil
+}
+
+func (c *daemonClient) stop() error {
+	_, err := c.call(daemonRequest{Op: "stop"})
+	return err
+}
+
+func (c *daemonClient) close() {
+	c.conn.Close()
+}
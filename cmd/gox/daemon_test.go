@@ -0,0 +1,222 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/germtb/gox/diag"
+)
+
+func TestDaemon(t *testing.T) {
+	dir := t.TempDir()
+	// Unix socket paths are short; t.TempDir() may be too long
+	socket := filepath.Join(os.TempDir(), "gox-daemon-test-"+filepath.Base(dir)+".sock")
+	ln, err := listenDaemon(socket)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info, err := os.Stat(socket); err != nil || info.Mode().Perm() != 0600 {
+		t.Errorf("socket mode = %v, %v; want -rw-------", info, err)
+	}
+	d := newDaemon(dir, false)
+	done := make(chan struct{})
+	go func() {
+		d.serve(ln)
+		close(done)
+	}()
+	defer os.Remove(socket)
+
+	if _, err := listenDaemon(socket); err == nil || !strings.Contains(err.Error(), "already running") {
+		t.Errorf("second listenDaemon error = %v, want already running", err)
+	}
+
+	path := filepath.Join(dir, "app.gox")
+	write := func(src string, modTime time.Time) {
+		if err := os.WriteFile(path, []byte(src), 0644); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.Chtimes(path, modTime, modTime); err != nil {
+			t.Fatal(err)
+		}
+	}
+	write("package ui\n\nfunc A() gox.VNode { return <a /> }\n", time.Unix(1000, 0))
+
+	client := dialDaemon(socket)
+	if client == nil {
+		t.Fatal("dialDaemon: no daemon")
+	}
+	defer client.close()
+
+	generate := func() (string, bool) {
+		t.Helper()
+		resp, err := client.call(daemonRequest{Op: "generate", Path: path})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if resp.Error != "" {
+			t.Fatal(resp.Error)
+		}
+		return string(resp.Output), resp.Cached
+	}
+	if out, cached := generate(); cached || !strings.Contains(out, `gox.Element("a", nil)`) {
+		t.Errorf("first generate: cached=%v, output:\n%s", cached, out)
+	}
+	if _, cached := generate(); !cached {
+		t.Error("second generate of an unchanged file should be cached")
+	}
+	write("package ui\n\nfunc A() gox.VNode { return <b /> }\n", time.Unix(2000, 0))
+	if out, cached := generate(); cached || !strings.Contains(out, `gox.Element("b", nil)`) {
+		t.Errorf("generate after a change: cached=%v, output:\n%s", cached, out)
+	}
+
+	// Files outside the project are refused
+	outside := filepath.Join(t.TempDir(), "other.gox")
+	os.WriteFile(outside, []byte("package ui\n"), 0644)
+	for _, p := range []string{outside, "app.gox"} {
+		if resp, err := client.call(daemonRequest{Op: "generate", Path: p}); err != nil || !strings.Contains(resp.Error, "outside the project") {
+			t.Errorf("generate %s = %q, %v; want it refused", p, resp.Error, err)
+		}
+	}
+	if link := filepath.Join(dir, "link.gox"); os.Symlink(outside, link) == nil {
+		if resp, _ := client.call(daemonRequest{Op: "generate", Path: link}); !strings.Contains(resp.Error, "outside the project") {
+			t.Errorf("generate through a symlink out of the project = %q, want it refused", resp.Error)
+		}
+		os.Remove(link)
+	}
+
+	// Errors in the file are reported as such, not as an unavailable daemon
+	write("package ui\n\nfunc A() gox.VNode { return <b> }\n", time.Unix(3000, 0))
+	_, sourceMap, err := client.generate(path, "", "")
+	if err == nil || !strings.HasPrefix(err.Error(), "parsing:") || sourceMap != nil {
+		t.Errorf("generate of a broken file = %v, %v; want a parse error", sourceMap, err)
+	}
+	if d, ok := diag.As(err); !ok || d.Code == "" || d.Line == 0 {
+		t.Errorf("parse error %v should keep its coded diagnostic", err)
+	}
+
+	// The broken file keeps its last good result cached
+	stats, err := client.stats()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if stats.Hits != 1 || stats.Misses != 3 || len(stats.Files) != 1 || stats.Files[0].Path != path || stats.CacheBytes == 0 || stats.HeapBytes == 0 {
+		t.Errorf("stats = %+v", stats)
+	}
+	var out strings.Builder
+	printDaemonStats(&out, socket, stats)
+	if !strings.Contains(out.String(), "1 hit(s), 3 miss(es), 25.0% hit rate") {
+		t.Errorf("printDaemonStats:\n%s", out.String())
+	}
+	if n, err := client.flush(); err != nil || n != 1 {
+		t.Errorf("flush = %d, %v; want 1 file flushed", n, err)
+	}
+	if stats, err := client.stats(); err != nil || len(stats.Files) != 0 {
+		t.Errorf("stats after flush = %+v, %v", stats, err)
+	}
+
+	if err := client.stop(); err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("daemon did not stop")
+	}
+	if dialDaemon(socket) != nil {
+		t.Error("dialDaemon after stop should find no daemon")
+	}
+}
+
+func TestDaemonSocketPath(t *testing.T) {
+	runtimeDir := t.TempDir()
+	t.Setenv("XDG_RUNTIME_DIR", runtimeDir)
+	socket, err := daemonSocketPath(".")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if dir := filepath.Dir(socket); dir != filepath.Join(runtimeDir, "gox") {
+		t.Errorf("socket directory = %s, want gox under XDG_RUNTIME_DIR", dir)
+	}
+	if other, _ := daemonSocketPath(".."); other == socket {
+		t.Error("projects should have their own sockets")
+	}
+
+	if err := makeSocketDir(filepath.Dir(socket)); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Chmod(filepath.Dir(socket), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := makeSocketDir(filepath.Dir(socket)); err != nil {
+		t.Fatal(err)
+	}
+	if info, err := os.Stat(filepath.Dir(socket)); err != nil || info.Mode().Perm() != 0700 {
+		t.Errorf("socket directory mode = %v, %v; want drwx------", info, err)
+	}
+
+	// The socket of another user is not trusted
+	if os.Getuid() != 0 {
+		t.Skip("changing the owner of the socket needs root")
+	}
+	ln, err := listenDaemon(socket)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ln.Close()
+	if err := os.Lchown(socket, 65534, 65534); err != nil {
+		t.Fatal(err)
+	}
+	if c := dialDaemon(socket); c != nil {
+		c.close()
+		t.Error("dialDaemon should not connect to a socket of another user")
+	}
+}
+
+func TestDaemonMatchesInProcess(t *testing.T) {
+	dir := t.TempDir()
+	socket := filepath.Join(os.TempDir(), "gox-daemon-test-"+filepath.Base(dir)+"-same.sock")
+	ln, err := listenDaemon(socket)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.Remove(socket)
+	go newDaemon(dir, false).serve(ln)
+	defer ln.Close()
+
+	path := filepath.Join(dir, "app.gox")
+	if err := os.WriteFile(path, []byte("package ui\n\nimport \"os\"\n\nfunc A() gox.VNode { return <a href={os.Args[0]} /> }\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	client := dialDaemon(socket)
+	if client == nil {
+		t.Fatal("dialDaemon: no daemon")
+	}
+	defer client.close()
+
+	for _, lineDirectives := range []bool{false, true} {
+		cfg := &generateConfig{overlay: true, lineDirectives: lineDirectives}
+		want, wantMap, err := generateFile(path, cfg)
+		if err != nil {
+			t.Fatal(err)
+		}
+		cfg.daemon = client
+		got, gotMap, err := cfg.generate(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if cfg.daemon == nil {
+			t.Fatal("the daemon was dropped")
+		}
+		if string(got) != string(want) {
+			t.Errorf("line directives %v: daemon output differs\ngot:\n%s\nwant:\n%s", lineDirectives, got, want)
+		}
+		gotJSON, _ := gotMap.ToJSON()
+		wantJSON, _ := wantMap.ToJSON()
+		if string(gotJSON) != string(wantJSON) {
+			t.Errorf("line directives %v: daemon source map differs", lineDirectives)
+		}
+	}
+}
@@ -0,0 +1,103 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"os"
+)
+
+// doctorCheck is a problem gox doctor looks for in a project.
+type doctorCheck struct {
+	name string
+	// run returns one finding per problem, each naming the file at fault
+	// and how to fix it.
+	run func(files []string, cfg *generateConfig) ([]string, error)
+}
+
+// doctorChecks are the checks gox doctor runs, in order.
+var doctorChecks = []doctorCheck{
+	{name: "generated files", run: checkGeneratedFiles},
+	{name: ".gitignore", run: checkGitignore},
+}
+
+// runDoctor runs the doctor command: look for problems with the generated
+// files and setup of a gox project, and explain how to fix them.
+func runDoctor(args []string) error {
+	cfg := &generateConfig{}
+
+	fs := flag.NewFlagSet("doctor", flag.ExitOnError)
+	fs.StringVar(&cfg.outputDir, "o", "", "output directory the files are generated to")
+	fs.StringVar(&cfg.runtimePkg, "runtime", "", "runtime package path")
+
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	cfg.paths = fs.Args()
+	if len(cfg.paths) == 0 {
+		cfg.paths = []string{"./..."}
+	}
+
+	files, err := findGoxFiles(cfg.paths)
+	if err != nil {
+		return fmt.Errorf("finding files: %w", err)
+	}
+	return doctor(os.Stdout, files, cfg)
+}
+
+// doctor runs every check on files, printing the findings to out. It fails
+// if there are any.
+func doctor(out io.Writer, files []string, cfg *generateConfig) error {
+	problems := 0
+	for _, check := range doctorChecks {
+		findings, err := check.run(files, cfg)
+		if err != nil {
+			return fmt.Errorf("%s: %w", check.name, err)
+		}
+		if len(findings) == 0 {
+			fmt.Fprintf(out, "ok    %s\n", check.name)
+			continue
+		}
+		fmt.Fprintf(out, "FAIL  %s\n", check.name)
+		for _, finding := range findings {
+			fmt.Fprintf(out, "      %s\n", finding)
+		}
+		problems += len(findings)
+	}
+	if problems > 0 {
+		return fmt.Errorf("%d problem(s) found", problems)
+	}
+	return nil
+}
+
+// checkGeneratedFiles reports generated files that are missing, edited by
+// hand, generated by another gox version or otherwise out of date.
+func checkGeneratedFiles(files []string, cfg *generateConfig) ([]string, error) {
+	var findings []string
+	for _, file := range files {
+		output, _, err := generateFile(file, cfg)
+		if err != nil {
+			findings = append(findings, fmt.Sprintf("%s: does not generate: %v", file, err))
+			continue
+		}
+		outputPath := getOutputPath(file, cfg.outputDir)
+		status, prov, err := outputStatus(outputPath, output)
+		if err != nil {
+			return nil, err
+		}
+		switch {
+		case status == listCurrent:
+		case status == listMissing:
+			findings = append(findings, fmt.Sprintf("%s: missing; run gox generate", outputPath))
+		case status == listEdited:
+			findings = append(findings, fmt.Sprintf("%s: edited by hand since gox generated it; move the edits into %s, then run gox generate -force", outputPath, file))
+		case prov == nil:
+			findings = append(findings, fmt.Sprintf("%s: generated by a gox version without provenance headers; run gox generate", outputPath))
+		case prov.Version != version:
+			findings = append(findings, fmt.Sprintf("%s: generated by gox %s, not %s; run gox generate", outputPath, prov.Version, version))
+		default:
+			findings = append(findings, fmt.Sprintf("%s: stale; run gox generate", outputPath))
+		}
+	}
+	return findings, nil
+}
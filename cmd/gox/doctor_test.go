@@ -0,0 +1,70 @@
+package main
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestDoctorGeneratedFiles(t *testing.T) {
+	dir := t.TempDir()
+	var files []string
+	for _, name := range []string{"current", "old", "edited", "unheaded", "missing"} {
+		path := filepath.Join(dir, name+".gox")
+		if err := os.WriteFile(path, []byte("package ui\n\nfunc A() gox.VNode { return <a /> }\n"), 0644); err != nil {
+			t.Fatal(err)
+		}
+		files = append(files, path)
+	}
+	// Outside a repository, the .gitignore next to the files is checked
+	if err := os.WriteFile(filepath.Join(dir, ".gitignore"), []byte("*_gox*.go\n*.map\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	cfg := &generateConfig{parallel: 1}
+	if err := processFiles(files[:4], cfg); err != nil {
+		t.Fatal(err)
+	}
+	if err := doctor(&bytes.Buffer{}, files[:4], cfg); err != nil {
+		t.Fatalf("doctor on fresh output: %v", err)
+	}
+
+	rewrite := func(name string, edit func(string) string) {
+		path := filepath.Join(dir, name+"_gox.go")
+		data, err := os.ReadFile(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(edit(string(data))), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	rewrite("old", func(s string) string {
+		return strings.Replace(s, "gox "+version+" from", "gox v0.1.0 from", 1)
+	})
+	rewrite("edited", func(s string) string { return s + "\nvar x = 1\n" })
+	rewrite("unheaded", func(s string) string {
+		_, body, _ := strings.Cut(s, "\n\n")
+		return body
+	})
+
+	var out bytes.Buffer
+	err := doctor(&out, files, cfg)
+	if err == nil || err.Error() != "4 problem(s) found" {
+		t.Errorf("doctor error = %v, want 4 problems", err)
+	}
+	for _, want := range []string{
+		"old_gox.go: generated by gox v0.1.0",
+		"edited_gox.go: edited by hand",
+		"unheaded_gox.go: generated by a gox version without provenance headers",
+		"missing_gox.go: missing",
+	} {
+		if !strings.Contains(out.String(), want) {
+			t.Errorf("doctor output lacks %q:\n%s", want, out.String())
+		}
+	}
+	if strings.Contains(out.String(), "current_gox.go") {
+		t.Errorf("doctor reported an up to date file:\n%s", out.String())
+	}
+}
@@ -0,0 +1,175 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+)
+
+// runEnvOverlay generates every .gox file of the project into a directory
+// that outlives the command, writes the overlay JSON for them to a stable
+// path, and prints a shell command adding it to GOFLAGS:
+//
+//	eval "$(gox env-overlay)"
+//
+// Plain go commands, and gopls and editors started from that shell, then
+// see the generated code without going through gox. The overlay is a
+// snapshot: it is run again after editing .gox files, and each run replaces
+// the files of the previous one.
+func runEnvOverlay(args []string) error {
+	var overlayFile, shell string
+	var unset, verbose bool
+	fs := flag.NewFlagSet("env-overlay", flag.ExitOnError)
+	fs.StringVar(&overlayFile, "o", "", "overlay JSON path (default: overlay.json in the module's gox cache directory)")
+	fs.StringVar(&shell, "shell", "sh", "syntax of the printed command: sh, fish or powershell")
+	fs.BoolVar(&unset, "unset", false, "print a command removing the overlay from GOFLAGS, and remove the generated files")
+	fs.BoolVar(&verbose, "v", false, "verbose output")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if fs.NArg() > 0 {
+		return fmt.Errorf("env-overlay takes no arguments; run it from the module directory")
+	}
+	if _, ok := envCommandFormats[shell]; !ok {
+		return fmt.Errorf("-shell must be sh, fish or powershell, not %q", shell)
+	}
+
+	// GOX_CACHE=off turns off reusing generated files, but the overlay
+	// still needs a directory that outlives the command
+	root := os.Getenv("GOX_CACHE")
+	if root == "off" {
+		root = ""
+	}
+	dir, err := moduleCacheDir(root, "env")
+	if err != nil {
+		return fmt.Errorf("creating overlay directory: %w", err)
+	}
+	if overlayFile == "" {
+		overlayFile = filepath.Join(dir, "overlay.json")
+	}
+	if overlayFile, err = filepath.Abs(overlayFile); err != nil {
+		return err
+	}
+
+	if unset {
+		goflags := goflagsWithOverlay(currentGoflags(), "")
+		if err := os.RemoveAll(dir); err != nil {
+			return fmt.Errorf("removing overlay directory: %w", err)
+		}
+		return writeEnvCommand(os.Stdout, shell, "GOFLAGS", goflags)
+	}
+
+	// GOFLAGS is split at spaces, with no way to quote them
+	if strings.ContainsAny(overlayFile, " \t\n") {
+		return fmt.Errorf("overlay path %q contains spaces, which GOFLAGS cannot hold; choose another with -o or GOX_CACHE", overlayFile)
+	}
+
+	files, err := findBuildGoxFiles()
+	if err != nil {
+		return fmt.Errorf("finding gox files: %w", err)
+	}
+
+	// Files are generated into a new directory, and the previous ones only
+	// removed once the overlay no longer names them, so a go command
+	// running meanwhile reads a complete overlay
+	filesDir, err := os.MkdirTemp(dir, "files-*")
+	if err != nil {
+		return fmt.Errorf("creating overlay directory: %w", err)
+	}
+	cfg := &generateConfig{
+		overlay:      true,
+		overlayFile:  overlayFile,
+		tempDir:      filesDir,
+		inMemoryMaps: true,
+		verbose:      verbose,
+		daemon:       dialProjectDaemon(),
+	}
+	defer func() {
+		if cfg.daemon != nil {
+			cfg.daemon.close()
+		}
+	}()
+	if err := processFilesOverlay(files, cfg); err != nil {
+		os.RemoveAll(filesDir)
+		return err
+	}
+	removeOtherFileDirs(dir, filesDir)
+
+	fmt.Fprintf(os.Stderr, "gox: overlay of %d .gox file(s) written to %s; run gox env-overlay again after editing them\n", len(files), overlayFile)
+	return writeEnvCommand(os.Stdout, shell, "GOFLAGS", goflagsWithOverlay(currentGoflags(), overlayFile))
+}
+
+// removeOtherFileDirs removes the generated-file directories of earlier
+// runs from dir, keeping keep.
+func removeOtherFileDirs(dir, keep string) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return
+	}
+	for _, e := range entries {
+		path := filepath.Join(dir, e.Name())
+		if e.IsDir() && strings.HasPrefix(e.Name(), "files-") && path != keep {
+			os.RemoveAll(path)
+		}
+	}
+}
+
+// currentGoflags returns the GOFLAGS go would use: the environment
+// variable, or else the value set with go env -w.
+func currentGoflags() string {
+	if value, ok := os.LookupEnv("GOFLAGS"); ok {
+		return value
+	}
+	out, err := exec.Command("go", "env", "GOFLAGS").Output()
+	if err != nil {
+		return ""
+	}
+	return strings.TrimSpace(string(out))
+}
+
+// goflagsWithOverlay returns goflags with any -overlay flag replaced by one
+// naming overlayFile, or removed if overlayFile is empty, so running
+// env-overlay again does not stack overlays.
+func goflagsWithOverlay(goflags, overlayFile string) string {
+	var kept []string
+	for _, f := range strings.Fields(goflags) {
+		name, _, _ := strings.Cut(strings.TrimPrefix(strings.TrimPrefix(f, "-"), "-"), "=")
+		if strings.HasPrefix(f, "-") && name == "overlay" {
+			continue
+		}
+		kept = append(kept, f)
+	}
+	if overlayFile != "" {
+		kept = append(kept, "-overlay="+overlayFile)
+	}
+	return strings.Join(kept, " ")
+}
+
+// envCommandFormats are the commands setting and unsetting an environment
+// variable in each shell -shell accepts. Values are single-quoted, with
+// quote escaping what single quotes do not protect.
+var envCommandFormats = map[string]struct {
+	set, unset string
+	quote      *strings.Replacer
+}{
+	"sh":         {set: "export %s=%s", unset: "unset %s", quote: strings.NewReplacer(`'`, `'\''`)},
+	"fish":       {set: "set -gx %s %s", unset: "set -e %s", quote: strings.NewReplacer(`'`, `\'`, `\`, `\\`)},
+	"powershell": {set: "$env:%s = %s", unset: "Remove-Item Env:%s -ErrorAction SilentlyContinue", quote: strings.NewReplacer(`'`, `''`)},
+}
+
+// writeEnvCommand writes the command setting name to value in shell, or
+// unsetting it if value is empty.
+func writeEnvCommand(w io.Writer, shell, name, value string) error {
+	format := envCommandFormats[shell]
+	var err error
+	if value == "" {
+		_, err = fmt.Fprintf(w, format.unset+"\n", name)
+	} else {
+		_, err = fmt.Fprintf(w, format.set+"\n", name, "'"+format.quote.Replace(value)+"'")
+	}
+	return err
+}
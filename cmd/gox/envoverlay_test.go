@@ -0,0 +1,49 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestGoflagsWithOverlay(t *testing.T) {
+	tests := []struct {
+		goflags, overlay, want string
+	}{
+		{"", "/c/overlay.json", "-overlay=/c/overlay.json"},
+		{"-mod=mod -race", "/c/overlay.json", "-mod=mod -race -overlay=/c/overlay.json"},
+		// Running env-overlay again replaces the overlay rather than adding one
+		{"-race -overlay=/old.json", "/c/overlay.json", "-race -overlay=/c/overlay.json"},
+		{"--overlay=/old.json  -tags=dev", "/c/overlay.json", "-tags=dev -overlay=/c/overlay.json"},
+		{"-race -overlay=/old.json", "", "-race"},
+		{"-overlay=/old.json", "", ""},
+		{"-overlayx=1", "", "-overlayx=1"},
+	}
+	for _, tt := range tests {
+		if got := goflagsWithOverlay(tt.goflags, tt.overlay); got != tt.want {
+			t.Errorf("goflagsWithOverlay(%q, %q) = %q, want %q", tt.goflags, tt.overlay, got, tt.want)
+		}
+	}
+}
+
+func TestWriteEnvCommand(t *testing.T) {
+	tests := []struct {
+		shell, value, want string
+	}{
+		{"sh", "-overlay=/c/o.json", "export GOFLAGS='-overlay=/c/o.json'\n"},
+		{"sh", "-overlay=/c/it's.json", `export GOFLAGS='-overlay=/c/it'\''s.json'` + "\n"},
+		{"sh", "", "unset GOFLAGS\n"},
+		{"fish", `-overlay=C:\it's.json`, `set -gx GOFLAGS '-overlay=C:\\it\'s.json'` + "\n"},
+		{"fish", "", "set -e GOFLAGS\n"},
+		{"powershell", `-overlay=C:\it's.json`, `$env:GOFLAGS = '-overlay=C:\it''s.json'` + "\n"},
+		{"powershell", "", "Remove-Item Env:GOFLAGS -ErrorAction SilentlyContinue\n"},
+	}
+	for _, tt := range tests {
+		var b strings.Builder
+		if err := writeEnvCommand(&b, tt.shell, "GOFLAGS", tt.value); err != nil {
+			t.Fatal(err)
+		}
+		if b.String() != tt.want {
+			t.Errorf("%s, %q:\ngot  %s\nwant %s", tt.shell, tt.value, b.String(), tt.want)
+		}
+	}
+}
@@ -0,0 +1,116 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/germtb/gox/diag"
+)
+
+// maxReportedErrors is how many errors gox generate prints before
+// summarizing the rest, unless -e asks for all of them.
+const maxReportedErrors = 10
+
+// fileError is an error generating a file.
+type fileError struct {
+	file string
+	err  error
+}
+
+// position returns the line and column of the error, or zeros if it has
+// none.
+func (e fileError) position() (int, int) {
+	if d, ok := diag.As(e.err); ok {
+		return d.Line, d.Column
+	}
+	return 0, 0
+}
+
+// sortFileErrors sorts errs by file, then position. Files are generated in
+// parallel, so errors arrive in no particular order.
+func sortFileErrors(errs []fileError) {
+	sort.SliceStable(errs, func(i, j int) bool {
+		if errs[i].file != errs[j].file {
+			return errs[i].file < errs[j].file
+		}
+		li, ci := errs[i].position()
+		lj, cj := errs[j].position()
+		if li != lj {
+			return li < lj
+		}
+		return ci < cj
+	})
+}
+
+// writeFileErrors prints errs, each with the source line it points at, the
+// range it covers underlined, and its hint. After limit errors, if limit is
+// positive, the rest are only counted.
+func writeFileErrors(w io.Writer, errs []fileError, limit int) {
+	sources := make(map[string][]string) // Lines of each file, read once
+	for i, e := range errs {
+		if limit > 0 && i == limit {
+			fmt.Fprintf(w, "... and %d more error(s); use -e to print all\n", len(errs)-limit)
+			return
+		}
+		d, ok := diag.As(e.err)
+		if !ok || d.Line == 0 {
+			fmt.Fprintf(w, "error: %s: %v\n", e.file, e.err)
+			continue
+		}
+		// The diagnostic already names its position
+		fmt.Fprintf(w, "error: %v\n", d)
+
+		file := d.File
+		if file == "" {
+			file = e.file
+		}
+		lines, ok := sources[file]
+		if !ok {
+			if src, err := os.ReadFile(file); err == nil {
+				lines = strings.Split(string(src), "\n")
+			}
+			sources[file] = lines
+		}
+		if d.Line <= len(lines) {
+			end := d.EndColumn
+			if d.EndLine > d.Line {
+				end = len(lines[d.Line-1]) + 1 // Underline to the end of the line
+			}
+			writeSnippet(w, lines[d.Line-1], d.Line, d.Column, end)
+		}
+		if d.Hint != "" {
+			fmt.Fprintf(w, "hint: %s\n", d.Hint)
+		}
+	}
+}
+
+// writeSnippet prints a source line, numbered, and a caret under the
+// 1-indexed rune column, followed by tildes up to endColumn, exclusive, if
+// it is further along. Tabs before the column are kept, so the caret lines
+// up however wide the terminal shows them.
+func writeSnippet(w io.Writer, line string, lineNum, column, endColumn int) {
+	line = strings.TrimSuffix(line, "\r")
+	gutter := fmt.Sprint(lineNum)
+	fmt.Fprintln(w, strings.TrimRight(" "+gutter+" | "+line, " "))
+
+	var caret strings.Builder
+	for i, r := range []rune(line) {
+		if i >= column-1 {
+			break
+		}
+		if r == '\t' {
+			caret.WriteByte('\t')
+		} else {
+			caret.WriteByte(' ')
+		}
+	}
+	underline := "^"
+	if width := min(endColumn, utf8.RuneCountInString(line)+1) - column; width > 1 {
+		underline += strings.Repeat("~", width-1)
+	}
+	fmt.Fprintf(w, " %s | %s%s\n", strings.Repeat(" ", len(gutter)), caret.String(), underline)
+}
@@ -0,0 +1,73 @@
+package main
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/germtb/gox/diag"
+)
+
+func TestSortFileErrors(t *testing.T) {
+	errs := []fileError{
+		{"b.gox", diag.New(diag.UnclosedElement, "b.gox", 6, 1, "unclosed")},
+		{"a.gox", diag.New(diag.UnexpectedToken, "a.gox", 9, 2, "late")},
+		{"b.gox", errors.New("reading file")},
+		{"a.gox", diag.New(diag.UnexpectedToken, "a.gox", 3, 7, "early, right")},
+		{"a.gox", diag.New(diag.UnexpectedToken, "a.gox", 3, 4, "early, left")},
+	}
+	sortFileErrors(errs)
+
+	var got []string
+	for _, e := range errs {
+		got = append(got, e.file+" "+e.err.Error())
+	}
+	want := []string{
+		"a.gox a.gox:3:4: early, left [GOX0001]",
+		"a.gox a.gox:3:7: early, right [GOX0001]",
+		"a.gox a.gox:9:2: late [GOX0001]",
+		"b.gox reading file",
+		"b.gox b.gox:6:1: unclosed [GOX0005]",
+	}
+	if strings.Join(got, "\n") != strings.Join(want, "\n") {
+		t.Errorf("sorted errors:\n%s\nwant:\n%s", strings.Join(got, "\n"), strings.Join(want, "\n"))
+	}
+}
+
+func TestWriteFileErrors(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "a.gox")
+	src := "package ui\n\nfunc A() gox.VNode {\n\treturn <div><span></div>\n}\n"
+	if err := os.WriteFile(file, []byte(src), 0644); err != nil {
+		t.Fatal(err)
+	}
+	mismatched := diag.New(diag.MismatchedClosingTag, file, 4, 22, "mismatched closing tag")
+	mismatched.EndLine, mismatched.EndColumn = 4, 25
+	mismatched.Hint = "did you forget to close <span>?"
+	errs := []fileError{
+		{file, mismatched},
+		{file, errors.New("runtime: no such package")},
+		{file, diag.New(diag.UnclosedElement, file, 6, 1, "unclosed element <div>")},
+	}
+
+	var b strings.Builder
+	writeFileErrors(&b, errs, 0)
+	want := "error: " + file + ":4:22: mismatched closing tag [GOX0004]\n" +
+		" 4 | \treturn <div><span></div>\n" +
+		"   | \t                    ^~~\n" +
+		"hint: did you forget to close <span>?\n" +
+		"error: " + file + ": runtime: no such package\n" +
+		"error: " + file + ":6:1: unclosed element <div> [GOX0005]\n" +
+		" 6 |\n" +
+		"   | ^\n"
+	if b.String() != want {
+		t.Errorf("writeFileErrors:\n%s\nwant:\n%s", b.String(), want)
+	}
+
+	b.Reset()
+	writeFileErrors(&b, errs, 1)
+	if !strings.HasSuffix(b.String(), "?\n... and 2 more error(s); use -e to print all\n") {
+		t.Errorf("writeFileErrors with a limit of 1:\n%s", b.String())
+	}
+}
@@ -0,0 +1,31 @@
+//go:build !plan9
+
+package main
+
+import (
+	"os"
+	"os/exec"
+	"syscall"
+)
+
+// terminationSignals are the signals gox catches to clean up before it exits.
+var terminationSignals = []os.Signal{os.Interrupt, syscall.SIGTERM}
+
+// exitSignal returns the number of the signal that killed a command, if one
+// did.
+func exitSignal(err *exec.ExitError) (int, bool) {
+	ws, ok := err.Sys().(syscall.WaitStatus)
+	if !ok || !ws.Signaled() {
+		return 0, false
+	}
+	return int(ws.Signal()), true
+}
+
+// signalStatus returns the exit status of a process ended by sig: 128+signal,
+// as in a shell.
+func signalStatus(sig os.Signal) int {
+	if s, ok := sig.(syscall.Signal); ok {
+		return 128 + int(s)
+	}
+	return 1
+}
@@ -0,0 +1,19 @@
+package main
+
+import (
+	"os"
+	"os/exec"
+)
+
+// terminationSignals are the signals gox catches to clean up before it exits.
+var terminationSignals = []os.Signal{os.Interrupt}
+
+// exitSignal reports no signals: Plan 9 notes have no numbers.
+func exitSignal(err *exec.ExitError) (int, bool) {
+	return 0, false
+}
+
+// signalStatus returns 1 for any note: Plan 9 notes have no numbers.
+func signalStatus(sig os.Signal) int {
+	return 1
+}
@@ -0,0 +1,128 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"strings"
+)
+
+// buildFailedStatus is the exit status of gox run and gox test when the
+// program or tests do not compile, so scripts can tell a build failure from
+// a program or tests that ran and failed.
+const buildFailedStatus = 2
+
+// exitStatus is an error that makes gox exit with a specific status. Whatever
+// explains it has already been printed, by gox or by the command it ran.
+type exitStatus int
+
+func (e exitStatus) Error() string {
+	return fmt.Sprintf("exit status %d", int(e))
+}
+
+// commandExitStatus converts the error of a command that ran and failed to
+// an exitStatus with the same status. A command killed by a signal gets
+// 128+signal, as in a shell. Other errors are returned unchanged.
+func commandExitStatus(err error) error {
+	var exitErr *exec.ExitError
+	if !errors.As(err, &exitErr) {
+		return err
+	}
+	if sig, ok := exitSignal(exitErr); ok {
+		return exitStatus(128 + sig)
+	}
+	if code := exitErr.ExitCode(); code > 0 {
+		return exitStatus(code)
+	}
+	return err
+}
+
+// buildAndRun implements gox run. Unlike go run, which reports every program
+// failure as exit status 1, it builds the program itself and runs it, so gox
+// exits with the program's own status. A build failure exits with
+// buildFailedStatus. Build errors and the program's stderr are written to
+// stderr for remapping. Signals caught by signals are forwarded to the build
+// and the program.
+func buildAndRun(parsed *goArgs, goPaths []string, overlayFile, tempDir string, stderr io.Writer, signals *childSignals) error {
+	execCmd, _ := parsed.takeValueFlag("exec")
+
+	binary := filepath.Join(tempDir, "gox-run")
+	if runtime.GOOS == "windows" {
+		binary += ".exe"
+	}
+	buildArgs := []string{"build", "-overlay=" + overlayFile, "-o", binary}
+	buildArgs = append(buildArgs, parsed.flags...)
+	buildArgs = append(buildArgs, goPaths...)
+	build := exec.Command("go", buildArgs...)
+	build.Stdout = os.Stdout
+	build.Stderr = stderr
+	if err := signals.run(build); err != nil {
+		var exitErr *exec.ExitError
+		var status exitStatus
+		switch {
+		case errors.As(err, &status):
+			return err
+		case errors.As(err, &exitErr):
+			if err := signals.interrupted(); err != nil {
+				return err
+			}
+			return exitStatus(buildFailedStatus)
+		}
+		return fmt.Errorf("building: %w", err)
+	}
+
+	args := append([]string{binary}, parsed.programArgs...)
+	if execCmd != "" {
+		// go run -exec "prog args" runs "prog args binary programArgs"
+		args = append(strings.Fields(execCmd), args...)
+	}
+	cmd := exec.Command(args[0], args[1:]...)
+	cmd.Stdin = os.Stdin
+	cmd.Stdout = os.Stdout
+	cmd.Stderr = stderr
+
+	// The program decides how to exit on a signal, and gox reports its
+	// status, like go run does
+	return commandExitStatus(signals.run(cmd))
+}
+
+// buildFailureWriter passes go test's output through while watching for the
+// "[build failed]" or "[setup failed]" that go test prints for packages
+// whose tests could not be built.
+type buildFailureWriter struct {
+	w      io.Writer
+	line   []byte // Incomplete last line
+	failed bool
+}
+
+func (w *buildFailureWriter) Write(p []byte) (int, error) {
+	w.line = append(w.line, p...)
+	for {
+		i := bytes.IndexByte(w.line, '\n')
+		if i < 0 {
+			break
+		}
+		w.check(w.line[:i])
+		w.line = w.line[i+1:]
+	}
+	return w.w.Write(p)
+}
+
+func (w *buildFailureWriter) check(line []byte) {
+	if bytes.HasPrefix(line, []byte("FAIL")) &&
+		(bytes.HasSuffix(line, []byte("[build failed]")) || bytes.HasSuffix(line, []byte("[setup failed]"))) {
+		w.failed = true
+	}
+}
+
+// buildFailed reports whether a build failure was seen, including on an
+// unterminated last line.
+func (w *buildFailureWriter) buildFailed() bool {
+	w.check(w.line)
+	return w.failed
+}
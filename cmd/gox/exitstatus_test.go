@@ -0,0 +1,95 @@
+package main
+
+import (
+	"errors"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestCommandExitStatus(t *testing.T) {
+	sh, err := exec.LookPath("sh")
+	if err != nil {
+		t.Skip("sh not installed")
+	}
+
+	err = commandExitStatus(exec.Command(sh, "-c", "exit 3").Run())
+	if status, ok := err.(exitStatus); !ok || status != 3 {
+		t.Errorf("exit 3: got %v, want exit status 3", err)
+	}
+	err = commandExitStatus(exec.Command(sh, "-c", "kill -TERM $$").Run())
+	if status, ok := err.(exitStatus); !ok || status != 128+15 {
+		t.Errorf("killed: got %v, want exit status 143", err)
+	}
+	if err := commandExitStatus(exec.Command(sh, "-c", "exit 0").Run()); err != nil {
+		t.Errorf("exit 0: got %v", err)
+	}
+	notFound := errors.New("not found")
+	if err := commandExitStatus(notFound); err != notFound {
+		t.Errorf("other errors: got %v", err)
+	}
+}
+
+func TestBuildAndRun(t *testing.T) {
+	dir := t.TempDir()
+	main := filepath.Join(dir, "main.go")
+	src := "package main\n\nimport \"os\"\n\nfunc main() { os.Exit(len(os.Args)) }\n"
+	if err := os.WriteFile(main, []byte(src), 0644); err != nil {
+		t.Fatal(err)
+	}
+	overlay := filepath.Join(dir, "overlay.json")
+	if err := os.WriteFile(overlay, []byte(`{"Replace":{}}`), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	// The program exits with its number of arguments, including its name
+	parsed, err := parseGoArgs("run", []string{main, "a", "b"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	signals := catchSignals()
+	defer signals.stop()
+	var stderr strings.Builder
+	err = buildAndRun(parsed, parsed.packages, overlay, dir, &stderr, signals)
+	if status, ok := err.(exitStatus); !ok || status != 3 {
+		t.Errorf("got %v, want exit status 3\n%s", err, stderr.String())
+	}
+
+	if err := os.WriteFile(main, []byte("package main\n\nfunc main() { undefined() }\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	stderr.Reset()
+	err = buildAndRun(parsed, parsed.packages, overlay, dir, &stderr, signals)
+	if status, ok := err.(exitStatus); !ok || status != buildFailedStatus {
+		t.Errorf("build failure: got %v, want exit status %d", err, buildFailedStatus)
+	}
+	if !strings.Contains(stderr.String(), "undefined: undefined") {
+		t.Errorf("build errors not written to stderr:\n%s", stderr.String())
+	}
+}
+
+func TestBuildFailureWriter(t *testing.T) {
+	for _, tt := range []struct {
+		output string
+		want   bool
+	}{
+		{"--- FAIL: TestX (0.00s)\nFAIL\nFAIL\tex\t0.002s\n", false},
+		{"FAIL\tex/bad [build failed]\nFAIL\n", true},
+		{"FAIL\tex/bad [setup failed]", true},
+	} {
+		var out strings.Builder
+		w := &buildFailureWriter{w: &out}
+		// Write in pieces that split lines
+		for _, piece := range strings.SplitAfter(tt.output, "\t") {
+			w.Write([]byte(piece))
+		}
+		if out.String() != tt.output {
+			t.Errorf("output changed: %q", out.String())
+		}
+		if got := w.buildFailed(); got != tt.want {
+			t.Errorf("buildFailed(%q) = %v, want %v", tt.output, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,33 @@
+package main
+
+import (
+	"fmt"
+
+	"github.com/germtb/gox/diag"
+)
+
+// runExplain prints the long-form explanation of a diagnostic code, or lists
+// every code when none is given.
+func runExplain(args []string) error {
+	if len(args) == 0 {
+		fmt.Println("Diagnostic codes (run \"gox explain <code>\" for details):")
+		fmt.Println()
+		for _, code := range diag.Codes() {
+			e, _ := diag.Explain(code)
+			fmt.Printf("  %s  %s\n", code, e.Title)
+		}
+		return nil
+	}
+
+	for i, arg := range args {
+		e, ok := diag.Explain(diag.Code(arg))
+		if !ok {
+			return fmt.Errorf("unknown diagnostic code %q (run \"gox explain\" to list codes)", arg)
+		}
+		if i > 0 {
+			fmt.Println()
+		}
+		fmt.Print(e)
+	}
+	return nil
+}
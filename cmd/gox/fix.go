@@ -0,0 +1,219 @@
+package main
+
+import (
+	"bytes"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"strings"
+
+	"github.com/germtb/gox/internal/atomicfile"
+	"github.com/germtb/gox/parser"
+	"github.com/germtb/gox/rewrite"
+)
+
+// goxFix adapts .gox sources to a change between gox releases, such as a
+// renamed runtime helper or a new attribute convention.
+type goxFix struct {
+	name string
+	doc  string
+	// fix records its edits on f and reports whether it made any. rt is
+	// the name f refers to the runtime package by.
+	fix func(f *rewrite.File, rt string) bool
+}
+
+// goxFixes are the fixes gox fix applies, in order.
+var goxFixes = []goxFix{
+	{
+		name: "textf",
+		doc:  "replace gox.Text(fmt.Sprintf(...)) with gox.Textf(...), which keeps VNode arguments structural",
+		fix:  fixTextf,
+	},
+}
+
+// fixConfig holds configuration for the fix command.
+type fixConfig struct {
+	write      bool // Write changes back to files
+	list       bool // List files that would change
+	fixes      []goxFix
+	runtimePkg string
+	paths      []string
+}
+
+// runFix applies the fixes to every .gox file in the given paths. Without
+// -w or -l it prints a unified diff of the changes. Either way, it reports
+// the fixes applied to each file on stderr.
+func runFix(args []string) error {
+	cfg := &fixConfig{}
+	var only string
+	var listFixes bool
+
+	fs := flag.NewFlagSet("fix", flag.ExitOnError)
+	fs.BoolVar(&cfg.write, "w", false, "write result to files instead of printing a diff")
+	fs.BoolVar(&cfg.list, "l", false, "list files that would change")
+	fs.StringVar(&only, "r", "", "comma-separated fixes to apply (default: all)")
+	fs.BoolVar(&listFixes, "list", false, "print the available fixes")
+	fs.StringVar(&cfg.runtimePkg, "runtime", "", "runtime package path for files without a .goxruntime file or //gox:runtime directive")
+
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if listFixes {
+		printFixes(os.Stdout)
+		return nil
+	}
+
+	fixes, err := selectFixes(only)
+	if err != nil {
+		return err
+	}
+	cfg.fixes = fixes
+
+	cfg.paths = fs.Args()
+	if len(cfg.paths) == 0 {
+		cfg.paths = []string{"./..."}
+	}
+
+	files, err := findGoxFiles(cfg.paths)
+	if err != nil {
+		return fmt.Errorf("finding files: %w", err)
+	}
+
+	var failed int
+	for _, path := range files {
+		if err := fixFile(path, cfg); err != nil {
+			fmt.Fprintf(os.Stderr, "error: %s: %v\n", path, err)
+			failed++
+		}
+	}
+	if failed > 0 {
+		return fmt.Errorf("%d file(s) failed", failed)
+	}
+	return nil
+}
+
+// printFixes prints the name and description of every fix.
+func printFixes(w io.Writer) {
+	for _, fix := range goxFixes {
+		fmt.Fprintf(w, "%-10s %s\n", fix.name, fix.doc)
+	}
+}
+
+// selectFixes returns the fixes named in a comma-separated list, or all of
+// them for an empty list.
+func selectFixes(names string) ([]goxFix, error) {
+	if names == "" {
+		return goxFixes, nil
+	}
+	var fixes []goxFix
+	for _, name := range strings.Split(names, ",") {
+		name = strings.TrimSpace(name)
+		found := false
+		for _, fix := range goxFixes {
+			if fix.name == name {
+				fixes = append(fixes, fix)
+				found = true
+			}
+		}
+		if !found {
+			return nil, fmt.Errorf("unknown fix %q; run gox fix -list for the available fixes", name)
+		}
+	}
+	return fixes, nil
+}
+
+// fixFile applies cfg.fixes to a single file and reports the result.
+func fixFile(path string, cfg *fixConfig) error {
+	src, err := os.ReadFile(path)
+	if err != nil {
+		return fmt.Errorf("reading file: %w", err)
+	}
+
+	gen := &generateConfig{runtimePkg: cfg.runtimePkg}
+	file, err := parser.Parse(path, src)
+	if err != nil {
+		return err
+	}
+	runtimePkg, err := gen.resolvedRuntime(file)
+	if err != nil {
+		return err
+	}
+
+	out, applied, err := fixSource(path, src, runtimePkg, cfg.fixes)
+	if err != nil {
+		return err
+	}
+	if len(applied) == 0 {
+		return nil
+	}
+
+	switch {
+	case cfg.list:
+		fmt.Println(path)
+	case cfg.write:
+		if err := atomicfile.WriteFile(path, out, 0644); err != nil {
+			return fmt.Errorf("writing file: %w", err)
+		}
+	default:
+		fmt.Print(unifiedDiff(path+".orig", path, src, out))
+	}
+	fmt.Fprintf(os.Stderr, "%s: fixed %s\n", path, strings.Join(applied, " "))
+	return nil
+}
+
+// fixSource applies fixes to a .gox source in order and returns the result
+// and the names of the fixes that changed it. Each fix sees the output of
+// the ones before it.
+func fixSource(file string, src []byte, runtimePkg string, fixes []goxFix) ([]byte, []string, error) {
+	var applied []string
+	for _, fix := range fixes {
+		f, err := rewrite.Parse(file, src)
+		if err != nil {
+			return nil, nil, err
+		}
+		rt, ok := f.ImportName(runtimePkg)
+		if !ok {
+			// The generator imports the runtime for files that do not
+			rt = path.Base(runtimePkg)
+		}
+		if !fix.fix(f, rt) {
+			continue
+		}
+		out := f.Bytes()
+		if bytes.Equal(out, src) {
+			continue
+		}
+		src = out
+		applied = append(applied, fix.name)
+	}
+	return src, applied, nil
+}
+
+// fixTextf replaces gox.Text(fmt.Sprintf(format, args...)) with
+// gox.Textf(format, args...), removing the fmt import if nothing else uses
+// it.
+func fixTextf(f *rewrite.File, rt string) bool {
+	fmtName, ok := f.ImportName("fmt")
+	if !ok {
+		return false
+	}
+	fixed := 0
+	f.EachCall(func(c *rewrite.Call) {
+		if c.Pkg != rt || c.Name != "Text" || len(c.Args) != 1 {
+			return
+		}
+		inner, ok := c.ArgCall(0)
+		if !ok || inner.Pkg != fmtName || inner.Name != "Sprintf" {
+			return
+		}
+		c.Rename("Textf")
+		inner.Unwrap()
+		fixed++
+	})
+	if fixed > 0 && f.Refs(fmtName) == fixed {
+		f.RemoveImport("fmt")
+	}
+	return fixed > 0
+}
@@ -0,0 +1,105 @@
+package main
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/germtb/gox/generator"
+)
+
+func TestFixTextf(t *testing.T) {
+	tests := []struct {
+		name string
+		src  string
+		want string
+	}{
+		{
+			name: "removes unused fmt",
+			src: `package ui
+
+import "fmt"
+
+func Count(n int) gox.VNode {
+	return <p>{gox.Text(fmt.Sprintf("%d items", n))}</p>
+}
+`,
+			want: `package ui
+
+func Count(n int) gox.VNode {
+	return <p>{gox.Textf("%d items", n)}</p>
+}
+`,
+		},
+		{
+			name: "keeps fmt in use",
+			src: `package ui
+
+import (
+	"fmt"
+
+	"github.com/germtb/gox"
+)
+
+func Count(n int) gox.VNode {
+	fmt.Println(n)
+	return gox.Text(fmt.Sprintf("%d", n))
+}
+`,
+			want: `package ui
+
+import (
+	"fmt"
+
+	"github.com/germtb/gox"
+)
+
+func Count(n int) gox.VNode {
+	fmt.Println(n)
+	return gox.Textf("%d", n)
+}
+`,
+		},
+		{
+			name: "other packages untouched",
+			src: `package ui
+
+import "fmt"
+
+func Count(n int) gox.VNode {
+	return <p>{other.Text(fmt.Sprintf("%d", n))}</p>
+}
+`,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			out, applied, err := fixSource("count.gox", []byte(tt.src), generator.DefaultRuntimePackage, goxFixes)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if tt.want == "" {
+				if len(applied) > 0 || string(out) != tt.src {
+					t.Errorf("fixSource changed the file (%v):\n%s", applied, out)
+				}
+				return
+			}
+			if !reflect.DeepEqual(applied, []string{"textf"}) {
+				t.Errorf("applied = %v, want [textf]", applied)
+			}
+			if string(out) != tt.want {
+				t.Errorf("got:\n%s\nwant:\n%s", out, tt.want)
+			}
+		})
+	}
+}
+
+func TestSelectFixes(t *testing.T) {
+	fixes, err := selectFixes(" textf ")
+	if err != nil || len(fixes) != 1 || fixes[0].name != "textf" {
+		t.Errorf("selectFixes(textf) = %v, %v", fixes, err)
+	}
+	if _, err := selectFixes("textf,nope"); err == nil || !strings.Contains(err.Error(), `"nope"`) {
+		t.Errorf("selectFixes with an unknown fix = %v, want an error naming it", err)
+	}
+}
@@ -0,0 +1,201 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"os/exec"
+	"path"
+	"path/filepath"
+	"strings"
+
+	"github.com/germtb/gox/generator"
+	"github.com/germtb/gox/internal/atomicfile"
+)
+
+// gitignorePatterns are the files generated with suffix, which an
+// application should not commit: the code and source maps of .gox files
+// and their tests.
+func gitignorePatterns(suffix string) []string {
+	test := strings.TrimSuffix(suffix, ".go") + "_test.go"
+	return []string{"*" + suffix, "*" + test, "*" + suffix + ".map", "*" + test + ".map"}
+}
+
+// projectGitignorePatterns returns the patterns covering the files generated
+// from files, for each suffix they are generated with. The default suffix
+// is covered without any files, as for a new project.
+func projectGitignorePatterns(files []string) []string {
+	var patterns []string
+	seen := make(map[string]bool)
+	add := func(suffix string) {
+		if !seen[suffix] {
+			seen[suffix] = true
+			patterns = append(patterns, gitignorePatterns(suffix)...)
+		}
+	}
+	for _, file := range files {
+		add(outputSuffix(filepath.Dir(file)))
+	}
+	if len(patterns) == 0 {
+		add(generator.DefaultSuffix)
+	}
+	return patterns
+}
+
+// repoGitignore returns the .gitignore at the root of the git repository
+// holding the first of files, or the working directory without any files.
+// Outside a repository, it is the .gitignore in that directory.
+func repoGitignore(files []string) (string, error) {
+	dir := "."
+	if len(files) > 0 {
+		dir = filepath.Dir(files[0])
+	}
+	dir, err := filepath.Abs(dir)
+	if err != nil {
+		return "", err
+	}
+	for d := dir; ; {
+		// .git is a file in worktrees and submodules
+		if _, err := os.Stat(filepath.Join(d, ".git")); err == nil {
+			return filepath.Join(d, ".gitignore"), nil
+		}
+		parent := filepath.Dir(d)
+		if parent == d {
+			return filepath.Join(dir, ".gitignore"), nil
+		}
+		d = parent
+	}
+}
+
+// missingGitignorePatterns returns the patterns that no line of the
+// .gitignore content data covers. A line covers a pattern it matches, so
+// *.map covers *_gox.go.map. Negated lines and comments are skipped.
+func missingGitignorePatterns(data []byte, patterns []string) []string {
+	var lines []string
+	scanner := bufio.NewScanner(strings.NewReader(string(data)))
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") || strings.HasPrefix(line, "!") {
+			continue
+		}
+		lines = append(lines, strings.TrimPrefix(strings.TrimPrefix(line, "/"), "**/"))
+	}
+
+	var missing []string
+	for _, pattern := range patterns {
+		covered := false
+		for _, line := range lines {
+			if ok, _ := path.Match(line, pattern); ok {
+				covered = true
+				break
+			}
+		}
+		if !covered {
+			missing = append(missing, pattern)
+		}
+	}
+	return missing
+}
+
+// ensureGitignore appends any missing generated-file patterns to the
+// .gitignore at path, creating it if needed. It reports whether the file
+// changed.
+func ensureGitignore(path string, patterns []string) (bool, error) {
+	data, err := os.ReadFile(path)
+	if err != nil && !os.IsNotExist(err) {
+		return false, fmt.Errorf("reading .gitignore: %w", err)
+	}
+	missing := missingGitignorePatterns(data, patterns)
+	if len(missing) == 0 {
+		return false, nil
+	}
+
+	var b strings.Builder
+	b.Write(data)
+	if len(data) > 0 && !strings.HasSuffix(string(data), "\n") {
+		b.WriteString("\n")
+	}
+	if len(data) > 0 {
+		b.WriteString("\n")
+	}
+	b.WriteString("# gox generated files\n")
+	for _, pattern := range missing {
+		b.WriteString(pattern + "\n")
+	}
+
+	if err := atomicfile.WriteFile(path, []byte(b.String()), 0644); err != nil {
+		return false, fmt.Errorf("writing .gitignore: %w", err)
+	}
+	return true, nil
+}
+
+// updateGitignore makes sure the repository .gitignore covers the files
+// generated from files, for gox generate -update-gitignore.
+func updateGitignore(files []string, cfg *generateConfig) error {
+	gitignore, err := repoGitignore(files)
+	if err != nil {
+		return err
+	}
+	patterns := projectGitignorePatterns(files)
+	if cfg.dryRun {
+		data, err := os.ReadFile(gitignore)
+		if err != nil && !os.IsNotExist(err) {
+			return fmt.Errorf("reading .gitignore: %w", err)
+		}
+		if missing := missingGitignorePatterns(data, patterns); len(missing) > 0 {
+			fmt.Printf("would add %s to %s\n", strings.Join(missing, " "), gitignore)
+		}
+		return nil
+	}
+	changed, err := ensureGitignore(gitignore, patterns)
+	if err != nil {
+		return err
+	}
+	switch {
+	case changed && !cfg.quiet:
+		fmt.Printf("updated %s\n", gitignore)
+	case cfg.verbose:
+		fmt.Printf("%s already ignores generated files\n", gitignore)
+	}
+	return nil
+}
+
+// checkGitignore reports generated-file patterns missing from the
+// repository .gitignore. Projects that commit their generated files, as
+// libraries should, are left alone.
+func checkGitignore(files []string, cfg *generateConfig) ([]string, error) {
+	gitignore, err := repoGitignore(files)
+	if err != nil {
+		return nil, err
+	}
+	if committedOutputs(filepath.Dir(gitignore), files, cfg) {
+		return nil, nil
+	}
+	data, err := os.ReadFile(gitignore)
+	if err != nil && !os.IsNotExist(err) {
+		return nil, fmt.Errorf("reading .gitignore: %w", err)
+	}
+	missing := missingGitignorePatterns(data, projectGitignorePatterns(files))
+	if len(missing) == 0 {
+		return nil, nil
+	}
+	return []string{fmt.Sprintf("%s: does not ignore %s; run gox generate -update-gitignore, or commit the generated files if this is a library", gitignore, strings.Join(missing, " "))}, nil
+}
+
+// committedOutputs reports whether git tracks any file generated from
+// files in the repository at root. Without git, nothing is tracked.
+func committedOutputs(root string, files []string, cfg *generateConfig) bool {
+	if len(files) == 0 {
+		return false
+	}
+	args := []string{"-C", root, "ls-files", "--"}
+	for _, file := range files {
+		output, err := filepath.Abs(getOutputPath(file, cfg.outputDir))
+		if err != nil {
+			return false
+		}
+		args = append(args, output)
+	}
+	out, err := exec.Command("git", args...).Output()
+	return err == nil && len(strings.TrimSpace(string(out))) > 0
+}
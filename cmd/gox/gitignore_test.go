@@ -0,0 +1,83 @@
+package main
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestMissingGitignorePatterns(t *testing.T) {
+	patterns := gitignorePatterns(".gen.go")
+	if want := []string{"*.gen.go", "*.gen_test.go", "*.gen.go.map", "*.gen_test.go.map"}; !reflect.DeepEqual(patterns, want) {
+		t.Fatalf("gitignorePatterns(.gen.go) = %v, want %v", patterns, want)
+	}
+
+	tests := []struct {
+		gitignore string
+		want      []string
+	}{
+		{"", patterns},
+		{"*.gen.go\n*.gen_test.go\n", []string{"*.gen.go.map", "*.gen_test.go.map"}},
+		// Broader lines cover the patterns they match
+		{"/*.gen.go\n**/*_test.go\n*.map\n", nil},
+		{"*.go\n", []string{"*.gen.go.map", "*.gen_test.go.map"}},
+		// Comments and negations do not
+		{"# *.gen.go\n!*.gen_test.go\n*.map\n", []string{"*.gen.go", "*.gen_test.go"}},
+	}
+	for _, tt := range tests {
+		got := missingGitignorePatterns([]byte(tt.gitignore), patterns)
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("missingGitignorePatterns(%q) = %v, want %v", tt.gitignore, got, tt.want)
+		}
+	}
+}
+
+func TestUpdateGitignore(t *testing.T) {
+	root := t.TempDir()
+	for name, content := range map[string]string{
+		".git/HEAD":         "ref: refs/heads/main\n",
+		".gitignore":        "bin/",
+		"app/go.mod":        "module example.com/app\n",
+		"app/a.gox":         "package app\n",
+		"app/ui/.goxsuffix": ".gen.go\n",
+		"app/ui/b.gox":      "package ui\n",
+	} {
+		path := filepath.Join(root, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	files, err := findGoxFiles([]string{filepath.Join(root, "app", "...")})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var out bytes.Buffer
+	if err := doctor(&out, files, &generateConfig{}); err == nil || !strings.Contains(out.String(), "FAIL  .gitignore") {
+		t.Errorf("doctor = %v, %q; want the .gitignore check to fail", err, out.String())
+	}
+
+	if err := updateGitignore(files, &generateConfig{}); err != nil {
+		t.Fatal(err)
+	}
+	data, err := os.ReadFile(filepath.Join(root, ".gitignore"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := "bin/\n\n# gox generated files\n*_gox.go\n*_gox_test.go\n*_gox.go.map\n*_gox_test.go.map\n" +
+		"*.gen.go\n*.gen_test.go\n*.gen.go.map\n*.gen_test.go.map\n"
+	if string(data) != want {
+		t.Errorf(".gitignore = %q, want %q", data, want)
+	}
+
+	findings, err := checkGitignore(files, &generateConfig{})
+	if err != nil || len(findings) > 0 {
+		t.Errorf("checkGitignore after updating = %v, %v; want no findings", findings, err)
+	}
+}
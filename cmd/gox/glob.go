@@ -0,0 +1,146 @@
+package main
+
+import (
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+)
+
+// isGlob reports whether a path argument is a glob pattern rather than a
+// file, directory or dir/... pattern.
+func isGlob(p string) bool {
+	return strings.ContainsAny(p, "*?[")
+}
+
+// expandBraces expands shell-style brace alternatives, so "ui/{a,b}.gox"
+// becomes "ui/a.gox" and "ui/b.gox". Braces nest, and braces without a
+// top-level comma are left as they are, like in bash.
+func expandBraces(pattern string) []string {
+	depth, open := 0, -1
+	var commas []int
+	for i := 0; i < len(pattern); i++ {
+		switch pattern[i] {
+		case '{':
+			if depth == 0 {
+				open, commas = i, nil
+			}
+			depth++
+		case ',':
+			if depth == 1 {
+				commas = append(commas, i)
+			}
+		case '}':
+			if depth == 0 {
+				continue
+			}
+			depth--
+			if depth > 0 || len(commas) == 0 {
+				continue
+			}
+			// Expand the first group, then whatever follows it
+			prefix, suffix := pattern[:open], pattern[i+1:]
+			var expanded []string
+			start := open + 1
+			for _, end := range append(commas, i) {
+				for _, alt := range expandBraces(pattern[start:end]) {
+					for _, rest := range expandBraces(suffix) {
+						expanded = append(expanded, prefix+alt+rest)
+					}
+				}
+				start = end + 1
+			}
+			return expanded
+		}
+	}
+	return []string{pattern}
+}
+
+// globFiles finds the files matching a glob pattern that are accepted by
+// match. "*", "?" and "[...]" match within a path element, as in
+// filepath.Match, and a "**" element matches any number of directories.
+// Directories skipped by ./... are skipped here too, unless the pattern
+// names them, as are paths excluded by ignore.
+func globFiles(pattern string, match func(name string) bool, ignore *ignoreFiles) ([]string, error) {
+	elems := strings.Split(filepath.ToSlash(filepath.Clean(pattern)), "/")
+
+	// Walk from the longest directory prefix without wildcards
+	static := 0
+	for static < len(elems)-1 && !isGlob(elems[static]) {
+		static++
+	}
+	root := filepath.FromSlash(strings.Join(elems[:static], "/"))
+	switch {
+	case static == 0:
+		root = "."
+	case root == "":
+		root = string(filepath.Separator)
+	}
+	recursive := false
+	for _, elem := range elems[static:] {
+		if elem == "**" {
+			recursive = true
+		}
+	}
+
+	var files []string
+	err := filepath.Walk(root, func(p string, info os.FileInfo, err error) error {
+		if err != nil {
+			if p == root && os.IsNotExist(err) {
+				return filepath.SkipDir
+			}
+			return err
+		}
+		if info.IsDir() && p == root {
+			return nil
+		}
+		rel := strings.Split(filepath.ToSlash(p), "/")
+		if info.IsDir() {
+			depth := len(rel) - 1
+			if skipDir(info.Name()) && !(depth < len(elems) && elems[depth] == info.Name()) {
+				return filepath.SkipDir
+			}
+			if !recursive && len(rel) >= len(elems) {
+				return filepath.SkipDir
+			}
+		} else if !match(p) || !matchElems(elems, rel) {
+			return nil
+		}
+		if ignored, err := ignore.ignored(p, info.IsDir()); err != nil || ignored {
+			if err == nil && info.IsDir() {
+				err = filepath.SkipDir
+			}
+			return err
+		}
+		if !info.IsDir() {
+			files = append(files, p)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return files, nil
+}
+
+// matchElems matches path elements against pattern elements.
+func matchElems(pattern, name []string) bool {
+	if len(pattern) == 0 {
+		return len(name) == 0
+	}
+	if pattern[0] == "**" {
+		for i := 0; i <= len(name); i++ {
+			if matchElems(pattern[1:], name[i:]) {
+				return true
+			}
+		}
+		return false
+	}
+	if len(name) == 0 {
+		return false
+	}
+	if ok, _ := path.Match(pattern[0], name[0]); !ok {
+		return false
+	}
+	return matchElems(pattern[1:], name[1:])
+}
@@ -0,0 +1,81 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestExpandBraces(t *testing.T) {
+	tests := []struct {
+		pattern string
+		want    []string
+	}{
+		{"ui/*.gox", []string{"ui/*.gox"}},
+		{"{ui,pages}/...", []string{"ui/...", "pages/..."}},
+		{"ui/{a,b}_{x,y}.gox", []string{"ui/a_x.gox", "ui/a_y.gox", "ui/b_x.gox", "ui/b_y.gox"}},
+		{"ui/{card,{list,grid}/item}.gox", []string{"ui/card.gox", "ui/list/item.gox", "ui/grid/item.gox"}},
+		{"ui/{a}.gox", []string{"ui/{a}.gox"}},
+		{"ui/{,old_}a.gox", []string{"ui/a.gox", "ui/old_a.gox"}},
+		{"ui/{a,b", []string{"ui/{a,b"}},
+	}
+	for _, tt := range tests {
+		if got := expandBraces(tt.pattern); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("expandBraces(%q) = %q, want %q", tt.pattern, got, tt.want)
+		}
+	}
+}
+
+func TestFindGoxFilesGlob(t *testing.T) {
+	dir := t.TempDir()
+	for _, name := range []string{
+		"app.gox",
+		"ui/button.gox",
+		"ui/button_gox.go",
+		"ui/cards/user_card.gox",
+		"ui/cards/deep/team_card.gox",
+		"ui/.cache/cached_card.gox",
+		"pages/home.gox",
+		"pages/about.gox",
+	} {
+		path := filepath.Join(dir, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, nil, 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	tests := []struct {
+		paths []string
+		want  []string
+	}{
+		{[]string{"./ui/**/*.gox"}, []string{"ui/button.gox", "ui/cards/deep/team_card.gox", "ui/cards/user_card.gox"}},
+		{[]string{"**/*_card.gox"}, []string{"ui/cards/deep/team_card.gox", "ui/cards/user_card.gox"}},
+		{[]string{"ui/*/*.gox"}, []string{"ui/cards/user_card.gox"}},
+		{[]string{"ui/**"}, []string{"ui/button.gox", "ui/cards/deep/team_card.gox", "ui/cards/user_card.gox"}},
+		{[]string{"ui/.cache/*.gox"}, []string{"ui/.cache/cached_card.gox"}},
+		{[]string{"pages/{home,about}.gox"}, []string{"pages/home.gox", "pages/about.gox"}},
+		{[]string{"{ui/cards,pages}/..."}, []string{"ui/cards/deep/team_card.gox", "ui/cards/user_card.gox", "pages/about.gox", "pages/home.gox"}},
+		{[]string{"*.gox", "{app,pages/home}.go?"}, []string{"app.gox", "pages/home.gox"}},
+		{[]string{"missing/**/*.gox"}, nil},
+	}
+	for _, tt := range tests {
+		var paths []string
+		for _, p := range tt.paths {
+			paths = append(paths, dir+"/"+p)
+		}
+		got, err := findGoxFiles(paths)
+		if err != nil {
+			t.Fatalf("findGoxFiles(%q): %v", tt.paths, err)
+		}
+		for i := range got {
+			got[i], _ = filepath.Rel(dir, got[i])
+			got[i] = filepath.ToSlash(got[i])
+		}
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("findGoxFiles(%q) = %q, want %q", tt.paths, got, tt.want)
+		}
+	}
+}
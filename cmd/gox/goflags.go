@@ -0,0 +1,232 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+)
+
+// goFlagSet lists the flags of a go command: those that take a value, where
+// "-flag value" consumes the next argument even if it starts with a dash,
+// and boolean flags, which only take a value written -flag=value.
+type goFlagSet struct {
+	values []string
+	bools  []string
+}
+
+// goBuildFlags are the build flags, shared by every command in goFlags.
+var goBuildFlags = goFlagSet{
+	values: []string{
+		"C", "p", "asmflags", "buildmode", "compiler", "covermode", "coverpkg",
+		"debug-actiongraph", "debug-runtime-trace", "debug-trace", "gccgoflags",
+		"gcflags", "installsuffix", "ldflags", "mod", "modfile", "overlay", "pgo",
+		"pkgdir", "tags", "toolexec",
+	},
+	bools: []string{
+		"a", "n", "x", "v", "asan", "buildvcs", "cover", "json", "linkshared",
+		"modcacherw", "msan", "race", "trimpath", "work",
+	},
+}
+
+// goFlags lists the flags of each go command that builds packages, besides
+// the build flags. Commands missing from the table are passed to go
+// verbatim, without an overlay.
+//
+// The table is complete for go test, which passes flags it does not know to
+// the test binary, so gox must tell them apart as go does. The other
+// commands treat an unknown flag as boolean unless it is written
+// -flag=value: go rejects unknown flags, except go vet, whose analyzer
+// flags, such as -printf.funcs, are not listed.
+var goFlags = map[string]goFlagSet{
+	"build":   {values: []string{"o"}},
+	"clean":   {bools: []string{"i", "r", "cache", "testcache", "modcache", "fuzzcache"}},
+	"install": {},
+	"run":     {values: []string{"exec"}},
+	"test": {
+		values: []string{
+			"o", "exec", "vet",
+			// Test binary flags, which may also be written -test.name
+			"bench", "benchtime", "blockprofile", "blockprofilerate", "count",
+			"coverprofile", "cpu", "cpuprofile", "fuzz", "fuzzcachedir",
+			"fuzzminimizetime", "fuzztime", "gocoverdir", "list", "memprofile",
+			"memprofilerate", "mutexprofile", "mutexprofilefraction", "outputdir",
+			"parallel", "run", "shuffle", "skip", "testlogfile", "timeout", "trace",
+		},
+		bools: []string{
+			"c", "i",
+			// Test binary flags
+			"benchmem", "failfast", "fullpath", "fuzzworker", "paniconexit0", "short",
+		},
+	},
+	"vet": {values: []string{"vettool"}},
+}
+
+// goxProxyFlags are gox's own flags among the go flags, removed with
+// takeFlag before go runs.
+var goxProxyFlags = []string{"gox-json"}
+
+// goArgs is a go command line split into its parts.
+type goArgs struct {
+	flags       []string // Flags with their values, in order
+	packages    []string // Package patterns, directories and files
+	programArgs []string // Arguments for the program (run) or test binary (test)
+}
+
+// parseGoArgs splits the arguments of a go command using goFlags. It
+// returns nil for commands not in the table.
+//
+// For go run, the first package, or the run of .go files it starts, ends
+// the go arguments: everything after it is passed to the program. For go
+// test, everything after -args is passed to the test binary. For both, "--"
+// ends the go arguments explicitly, and everything after it is passed on
+// verbatim.
+//
+// Like go test, gox test passes flags it does not know, such as a test's
+// own -update, to the test binary, and takes them, or a flag following the
+// packages, to end the package list: later arguments go to the test binary
+// too, except for go test flags.
+func parseGoArgs(goCmd string, args []string) (*goArgs, error) {
+	commandFlags, ok := goFlags[goCmd]
+	if !ok {
+		return nil, nil
+	}
+	known := make(map[string]bool) // Whether a known flag takes a value
+	for _, set := range []goFlagSet{goBuildFlags, commandFlags} {
+		for _, name := range set.values {
+			known[name] = true
+		}
+		for _, name := range set.bools {
+			known[name] = false
+		}
+	}
+	for _, name := range goxProxyFlags {
+		known[name] = false
+	}
+
+	parsed := &goArgs{}
+	packagesEnded := false // go test: later non-flags go to the test binary
+	afterTestFlag := false // go test: the last argument was an unknown flag without a value
+	for i := 0; i < len(args); i++ {
+		arg := args[i]
+
+		if arg == "--" || goCmd == "test" && (arg == "-args" || arg == "--args") {
+			if goCmd != "run" && goCmd != "test" {
+				return nil, fmt.Errorf("go %s does not take program arguments after %s", goCmd, arg)
+			}
+			parsed.programArgs = append(parsed.programArgs, args[i+1:]...)
+			break
+		}
+
+		if len(arg) > 1 && strings.HasPrefix(arg, "-") {
+			name, _, hasValue := strings.Cut(strings.TrimPrefix(strings.TrimPrefix(arg, "-"), "-"), "=")
+			if goCmd == "test" {
+				name = strings.TrimPrefix(name, "test.")
+			}
+			takesValue, isKnown := known[name]
+			packagesEnded = packagesEnded || len(parsed.packages) > 0
+			if goCmd == "test" && !isKnown {
+				parsed.programArgs = append(parsed.programArgs, arg)
+				packagesEnded = true
+				afterTestFlag = !hasValue
+				continue
+			}
+			afterTestFlag = false
+			parsed.flags = append(parsed.flags, arg)
+			if !hasValue && takesValue && i+1 < len(args) {
+				i++
+				parsed.flags = append(parsed.flags, args[i])
+			}
+			continue
+		}
+
+		if goCmd == "test" && packagesEnded {
+			if afterTestFlag {
+				// Possibly the value of the unknown flag: go test keeps
+				// looking for its own flags after it
+				parsed.programArgs = append(parsed.programArgs, arg)
+				afterTestFlag = false
+				continue
+			}
+			parsed.programArgs = append(parsed.programArgs, args[i:]...)
+			break
+		}
+
+		parsed.packages = append(parsed.packages, arg)
+		if goCmd == "run" {
+			// go run main.go util.go arg...
+			for strings.HasSuffix(arg, ".go") && i+1 < len(args) && strings.HasSuffix(args[i+1], ".go") {
+				i++
+				parsed.packages = append(parsed.packages, args[i])
+			}
+			rest := args[i+1:]
+			if len(rest) > 0 && rest[0] == "--" {
+				rest = rest[1:]
+			}
+			parsed.programArgs = append(parsed.programArgs, rest...)
+			break
+		}
+	}
+	return parsed, nil
+}
+
+// commandLine returns the arguments for go, after the command name, with
+// packages replaced by goPackages.
+func (a *goArgs) commandLine(goCmd string, goPackages []string) []string {
+	var cmdArgs []string
+	cmdArgs = append(cmdArgs, a.flags...)
+	cmdArgs = append(cmdArgs, goPackages...)
+	if len(a.programArgs) > 0 {
+		if goCmd == "test" {
+			cmdArgs = append(cmdArgs, "-args")
+		}
+		cmdArgs = append(cmdArgs, a.programArgs...)
+	}
+	return cmdArgs
+}
+
+// takeFlag removes a boolean flag from the flags and reports whether it was
+// there. It is for gox's own flags among the go flags.
+func (a *goArgs) takeFlag(name string) bool {
+	found := false
+	flags := a.flags[:0]
+	for _, f := range a.flags {
+		if f == "-"+name || f == "--"+name {
+			found = true
+			continue
+		}
+		flags = append(flags, f)
+	}
+	a.flags = flags
+	return found
+}
+
+// takeValueFlag removes a flag that takes a value, written "-name value" or
+// "-name=value", from the flags and returns its last value.
+func (a *goArgs) takeValueFlag(name string) (string, bool) {
+	value, found := "", false
+	flags := a.flags[:0]
+	for i := 0; i < len(a.flags); i++ {
+		f := a.flags[i]
+		flagName, v, hasValue := strings.Cut(strings.TrimPrefix(strings.TrimPrefix(f, "-"), "-"), "=")
+		if !strings.HasPrefix(f, "-") || flagName != name {
+			flags = append(flags, f)
+			continue
+		}
+		found = true
+		if hasValue {
+			value = v
+		} else if i+1 < len(a.flags) {
+			i++
+			value = a.flags[i]
+		}
+	}
+	a.flags = flags
+	return value, found
+}
+
+// flagValue returns the last value of a flag that takes a value, leaving the
+// flags unchanged.
+func (a *goArgs) flagValue(name string) string {
+	c := goArgs{flags: append([]string(nil), a.flags...)}
+	value, _ := c.takeValueFlag(name)
+	return value
+}
@@ -0,0 +1,101 @@
+package main
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestParseGoArgs(t *testing.T) {
+	tests := []struct {
+		cmd      string
+		args     string
+		flags    []string
+		packages []string
+		program  []string
+	}{
+		{"build", "-o app -race ./cmd/app", []string{"-o", "app", "-race"}, []string{"./cmd/app"}, nil},
+		{"build", "-cover ./...", []string{"-cover"}, []string{"./..."}, nil},
+		{"build", "-tags=dev -ldflags -s ./...", []string{"-tags=dev", "-ldflags", "-s"}, []string{"./..."}, nil},
+		{"test", "-count=2 -run Foo ./ui/...", []string{"-count=2", "-run", "Foo"}, []string{"./ui/..."}, nil},
+		{"test", "./... -v -test.run Foo", []string{"-v", "-test.run", "Foo"}, []string{"./..."}, nil},
+		{"test", "-v example.com/ui -args -update", []string{"-v"}, []string{"example.com/ui"}, []string{"-update"}},
+		{"test", ". -- -update x", nil, []string{"."}, []string{"-update", "x"}},
+		{"test", "-race -count 1 -timeout 30s -bench . -benchmem ./...", []string{"-race", "-count", "1", "-timeout", "30s", "-bench", ".", "-benchmem"}, []string{"./..."}, nil},
+		// Unknown flags go to the test binary, and end the package list
+		{"test", "./ui -update -run X", []string{"-run", "X"}, []string{"./ui"}, []string{"-update"}},
+		{"test", "./ui -golden testdata -v", []string{"-v"}, []string{"./ui"}, []string{"-golden", "testdata"}},
+		{"test", "-update ./ui", nil, nil, []string{"-update", "./ui"}},
+		{"test", "./a -v ./b x", []string{"-v"}, []string{"./a"}, []string{"./b", "x"}},
+		{"test", "-failfast -gox-json ./ui", []string{"-failfast", "-gox-json"}, []string{"./ui"}, nil},
+		{"vet", "-printf.funcs=Wrapf ./...", []string{"-printf.funcs=Wrapf"}, []string{"./..."}, nil},
+		{"run", "-race . -port 8080", []string{"-race"}, []string{"."}, []string{"-port", "8080"}},
+		{"run", "main.go util.go serve", nil, []string{"main.go", "util.go"}, []string{"serve"}},
+		{"run", ". -- -v", nil, []string{"."}, []string{"-v"}},
+		{"run", "-- -v", nil, nil, []string{"-v"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.cmd+" "+tt.args, func(t *testing.T) {
+			got, err := parseGoArgs(tt.cmd, strings.Fields(tt.args))
+			if err != nil {
+				t.Fatalf("parseGoArgs: %v", err)
+			}
+			if !reflect.DeepEqual(got.flags, tt.flags) {
+				t.Errorf("flags = %q, want %q", got.flags, tt.flags)
+			}
+			if !reflect.DeepEqual(got.packages, tt.packages) {
+				t.Errorf("packages = %q, want %q", got.packages, tt.packages)
+			}
+			if !reflect.DeepEqual(got.programArgs, tt.program) {
+				t.Errorf("programArgs = %q, want %q", got.programArgs, tt.program)
+			}
+		})
+	}
+}
+
+func TestParseGoArgsErrors(t *testing.T) {
+	if parsed, err := parseGoArgs("mod", []string{"tidy"}); parsed != nil || err != nil {
+		t.Errorf("parseGoArgs(mod) = %v, %v; want nil, nil", parsed, err)
+	}
+	if _, err := parseGoArgs("build", []string{".", "--", "x"}); err == nil {
+		t.Error("expected an error for -- with go build")
+	}
+}
+
+func TestGoArgsCommandLine(t *testing.T) {
+	parsed, err := parseGoArgs("test", strings.Fields("-v ./ui/app.gox -- -update"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	got := strings.Join(parsed.commandLine("test", goPackagePaths(parsed.packages)), " ")
+	if want := "-v ./ui -args -update"; got != want {
+		t.Errorf("commandLine = %q, want %q", got, want)
+	}
+
+	// Flags after an unknown flag still reach go, and the package stays
+	parsed, err = parseGoArgs("test", strings.Fields("./ui -update -count=1"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	got = strings.Join(parsed.commandLine("test", parsed.packages), " ")
+	if want := "-count=1 ./ui -args -update"; got != want {
+		t.Errorf("commandLine = %q, want %q", got, want)
+	}
+}
+
+func TestTakeValueFlag(t *testing.T) {
+	parsed, err := parseGoArgs("run", strings.Fields("-race -exec wrapper -tags dev . -exec x"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	value, ok := parsed.takeValueFlag("exec")
+	if !ok || value != "wrapper" {
+		t.Errorf("takeValueFlag = %q, %v, want wrapper, true", value, ok)
+	}
+	if want := []string{"-race", "-tags", "dev"}; !reflect.DeepEqual(parsed.flags, want) {
+		t.Errorf("flags = %q, want %q", parsed.flags, want)
+	}
+	if _, ok := parsed.takeValueFlag("exec"); ok {
+		t.Error("takeValueFlag found -exec twice")
+	}
+}
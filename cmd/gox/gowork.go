@@ -0,0 +1,158 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// findGoWork returns the go.work file that go commands run in dir use: the
+// one named by GOWORK, or the nearest go.work in dir or its parents. It
+// returns "" outside a workspace or with GOWORK=off.
+func findGoWork(dir string) (string, error) {
+	switch gowork := os.Getenv("GOWORK"); gowork {
+	case "off":
+		return "", nil
+	case "":
+	default:
+		return filepath.Abs(gowork)
+	}
+
+	dir, err := filepath.Abs(dir)
+	if err != nil {
+		return "", err
+	}
+	for {
+		name := filepath.Join(dir, "go.work")
+		if info, err := os.Stat(name); err == nil && !info.IsDir() {
+			return name, nil
+		}
+		parent := filepath.Dir(dir)
+		if parent == dir {
+			return "", nil
+		}
+		dir = parent
+	}
+}
+
+// workspaceModules returns the absolute directories of the modules listed by
+// the use directives of a go.work file.
+func workspaceModules(goWork string) ([]string, error) {
+	data, err := os.ReadFile(goWork)
+	if err != nil {
+		return nil, err
+	}
+	uses, err := parseGoWorkUses(data)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", goWork, err)
+	}
+	dirs := make([]string, 0, len(uses))
+	for _, use := range uses {
+		dir := filepath.FromSlash(use)
+		if !filepath.IsAbs(dir) {
+			dir = filepath.Join(filepath.Dir(goWork), dir)
+		}
+		dirs = append(dirs, filepath.Clean(dir))
+	}
+	return dirs, nil
+}
+
+// parseGoWorkUses returns the paths of the use directives in go.work data,
+// in the single-line form (use ./app) and the block form (use ( ... )).
+func parseGoWorkUses(data []byte) ([]string, error) {
+	var uses []string
+	inBlock := false
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	for lineNum := 1; scanner.Scan(); lineNum++ {
+		line := scanner.Text()
+		if i := strings.Index(line, "//"); i >= 0 {
+			line = line[:i]
+		}
+		line = strings.TrimSpace(line)
+		if line == "" {
+			continue
+		}
+
+		var arg string
+		switch {
+		case inBlock && line == ")":
+			inBlock = false
+			continue
+		case inBlock:
+			arg = line
+		case strings.HasPrefix(line, "use") && strings.TrimSpace(line[3:]) == "(":
+			inBlock = true
+			continue
+		case strings.HasPrefix(line, "use ") || strings.HasPrefix(line, "use\t"):
+			arg = strings.TrimSpace(line[3:])
+		default:
+			// go, toolchain, replace and godebug directives
+			continue
+		}
+
+		path := arg
+		if strings.HasPrefix(arg, `"`) || strings.HasPrefix(arg, "`") {
+			unquoted, err := strconv.Unquote(arg)
+			if err != nil {
+				return nil, fmt.Errorf("line %d: %w", lineNum, err)
+			}
+			path = unquoted
+		} else if strings.ContainsAny(arg, " \t") {
+			return nil, fmt.Errorf("line %d: malformed use directive", lineNum)
+		}
+		uses = append(uses, path)
+	}
+	return uses, scanner.Err()
+}
+
+// findBuildGoxFiles finds the .gox files that can be part of a build run in
+// the working directory: those under it and, inside a workspace, those of
+// every workspace module, so that packages of other modules the build
+// imports have their generated code.
+func findBuildGoxFiles() ([]string, error) {
+	files, err := findGoxFiles([]string{"./..."})
+	if err != nil {
+		return nil, err
+	}
+
+	goWork, err := findGoWork(".")
+	if err != nil || goWork == "" {
+		return files, err
+	}
+	modules, err := workspaceModules(goWork)
+	if err != nil {
+		return nil, err
+	}
+
+	seen := make(map[string]bool)
+	for _, f := range files {
+		if abs, err := filepath.Abs(f); err == nil {
+			seen[abs] = true
+		}
+	}
+	for _, dir := range modules {
+		if _, err := os.Stat(dir); err != nil {
+			// go reports missing modules itself
+			continue
+		}
+		moduleFiles, err := findGoxFiles([]string{filepath.ToSlash(dir) + "/..."})
+		if err != nil {
+			return nil, err
+		}
+		for _, f := range moduleFiles {
+			abs, err := filepath.Abs(f)
+			if err != nil {
+				return nil, err
+			}
+			if !seen[abs] {
+				seen[abs] = true
+				files = append(files, abs)
+			}
+		}
+	}
+	return files, nil
+}
@@ -0,0 +1,65 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestParseGoWorkUses(t *testing.T) {
+	data := []byte(`go 1.22
+
+toolchain go1.22.1
+
+use ./app // the server
+use (
+	./ui
+	"./shared lib"
+	// ./old
+)
+
+replace example.com/x => ./x
+`)
+	got, err := parseGoWorkUses(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []string{"./app", "./ui", "./shared lib"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("parseGoWorkUses = %q, want %q", got, want)
+	}
+
+	if _, err := parseGoWorkUses([]byte("use (\n\t./a ./b\n)\n")); err == nil {
+		t.Error("expected an error for two paths on one line")
+	}
+}
+
+func TestFindGoWork(t *testing.T) {
+	t.Setenv("GOWORK", "")
+	dir := t.TempDir()
+	goWork := filepath.Join(dir, "go.work")
+	if err := os.WriteFile(goWork, []byte("go 1.22\n\nuse ./app\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	app := filepath.Join(dir, "app", "cmd")
+	if err := os.MkdirAll(app, 0755); err != nil {
+		t.Fatal(err)
+	}
+
+	if got, err := findGoWork(app); err != nil || got != goWork {
+		t.Errorf("findGoWork = %q, %v; want %q", got, err, goWork)
+	}
+	modules, err := workspaceModules(goWork)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := []string{filepath.Join(dir, "app")}; !reflect.DeepEqual(modules, want) {
+		t.Errorf("workspaceModules = %q, want %q", modules, want)
+	}
+
+	t.Setenv("GOWORK", "off")
+	if got, err := findGoWork(app); err != nil || got != "" {
+		t.Errorf("findGoWork with GOWORK=off = %q, %v; want none", got, err)
+	}
+}
@@ -0,0 +1,388 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"go/ast"
+	goparser "go/parser"
+	"go/token"
+	"io"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode"
+
+	"github.com/germtb/gox/parser"
+)
+
+// componentGraph is the graph of which components render which, built from
+// .gox files by gox graph.
+type componentGraph struct {
+	Nodes []graphNode `json:"nodes"`
+	Edges []graphEdge `json:"edges"`
+}
+
+// graphNode is a component, or a function that renders components without
+// being one, such as main.
+type graphNode struct {
+	ID       string `json:"id"`      // Package path and name, e.g. example.com/app/ui.Card
+	Name     string `json:"name"`    // Name qualified by package name, e.g. ui.Card
+	Package  string `json:"package"` // Package path
+	File     string `json:"file,omitempty"`
+	Line     int    `json:"line,omitempty"`
+	Func     bool   `json:"func,omitempty"`     // Not a component
+	External bool   `json:"external,omitempty"` // Not defined in the .gox files analyzed
+}
+
+// graphEdge records that From renders To, Count times.
+type graphEdge struct {
+	From  string `json:"from"`
+	To    string `json:"to"`
+	Count int    `json:"count"`
+}
+
+// runGraph runs the graph command: print the component graph of .gox files
+// as DOT or JSON, or the components no .gox file renders.
+func runGraph(args []string) error {
+	cfg := &generateConfig{}
+	asJSON, unused := false, false
+
+	fs := flag.NewFlagSet("graph", flag.ExitOnError)
+	fs.BoolVar(&asJSON, "json", false, "print the graph as JSON instead of DOT")
+	fs.BoolVar(&unused, "unused", false, "list the components no .gox file renders")
+	fs.StringVar(&cfg.runtimePkg, "runtime", "", "runtime package path")
+
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	cfg.paths = fs.Args()
+	if len(cfg.paths) == 0 {
+		cfg.paths = []string{"./..."}
+	}
+
+	files, err := findGoxFiles(cfg.paths)
+	if err != nil {
+		return fmt.Errorf("finding files: %w", err)
+	}
+	graph, err := buildComponentGraph(files, cfg)
+	if err != nil {
+		return err
+	}
+
+	switch {
+	case unused:
+		for _, n := range graph.unused() {
+			fmt.Printf("%s:%d: %s is not rendered by any .gox file\n", n.File, n.Line, n.Name)
+		}
+		return nil
+	case asJSON:
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		return enc.Encode(graph)
+	default:
+		return graph.writeDOT(os.Stdout)
+	}
+}
+
+// buildComponentGraph generates files in memory and collects the components
+// they define and the components each function renders.
+func buildComponentGraph(files []string, cfg *generateConfig) (*componentGraph, error) {
+	nodes := make(map[string]*graphNode) // Components, by ID
+	funcs := make(map[string]*graphNode) // Every function, by ID
+	edges := make(map[[2]string]int)
+	modules := make(map[string]goModule)
+
+	for _, file := range files {
+		src, err := os.ReadFile(file)
+		if err != nil {
+			return nil, err
+		}
+		goxFile, err := parser.Parse(file, src)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", file, err)
+		}
+		output, sourceMap, err := generateFile(file, cfg)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", file, err)
+		}
+		runtimePkg, err := cfg.resolvedRuntime(goxFile)
+		if err != nil {
+			return nil, err
+		}
+		pkgPath, err := packagePath(filepath.Dir(file), modules)
+		if err != nil {
+			return nil, err
+		}
+
+		fset := token.NewFileSet()
+		f, err := goparser.ParseFile(fset, file, output, goparser.SkipObjectResolution)
+		if err != nil {
+			return nil, fmt.Errorf("%s: parsing generated code: %w", file, err)
+		}
+		pkgName := f.Name.Name
+
+		for _, c := range definedComponents(output, sourceMap, runtimePkg) {
+			id := pkgPath + "." + c.Name
+			nodes[id] = &graphNode{ID: id, Name: pkgName + "." + c.Name, Package: pkgPath, File: file, Line: c.Line}
+		}
+
+		imports := make(map[string]string) // Name in the file → package path
+		for _, imp := range f.Imports {
+			importPath, err := strconv.Unquote(imp.Path.Value)
+			if err != nil {
+				continue
+			}
+			name := path.Base(importPath)
+			if imp.Name != nil {
+				name = imp.Name.Name
+			}
+			imports[name] = importPath
+		}
+
+		for _, decl := range f.Decls {
+			fn, ok := decl.(*ast.FuncDecl)
+			if !ok || fn.Body == nil {
+				continue
+			}
+			from := pkgPath + "." + funcName(fn)
+			ast.Inspect(fn.Body, func(n ast.Node) bool {
+				call, ok := n.(*ast.CallExpr)
+				if !ok {
+					return true
+				}
+				qualifier, name, ok := componentCall(call)
+				if !ok {
+					return true
+				}
+				to := pkgPath + "." + name
+				if qualifier != "" {
+					importPath, ok := imports[qualifier]
+					if !ok {
+						return true
+					}
+					to = importPath + "." + name
+				}
+				edges[[2]string{from, to}]++
+				return true
+			})
+			line := fset.Position(fn.Name.Pos()).Line
+			if srcLine, ok := sourceMap.FindSourceLine(uint32(line - 1)); ok {
+				line = int(srcLine) + 1
+			}
+			funcs[from] = &graphNode{ID: from, Name: pkgName + "." + funcName(fn), Package: pkgPath, File: file, Line: line, Func: true}
+		}
+	}
+
+	// Functions that are not components, and components of packages not
+	// analyzed, are only nodes if they are part of an edge
+	for key := range edges {
+		for _, id := range key {
+			if _, ok := nodes[id]; ok {
+				continue
+			}
+			if fn, ok := funcs[id]; ok {
+				nodes[id] = fn
+				continue
+			}
+			pkgPath, name := splitNodeID(id)
+			nodes[id] = &graphNode{ID: id, Name: path.Base(pkgPath) + "." + name, Package: pkgPath, External: true}
+		}
+	}
+
+	graph := &componentGraph{}
+	for _, n := range nodes {
+		graph.Nodes = append(graph.Nodes, *n)
+	}
+	for key, count := range edges {
+		graph.Edges = append(graph.Edges, graphEdge{From: key[0], To: key[1], Count: count})
+	}
+	sort.Slice(graph.Nodes, func(i, j int) bool { return graph.Nodes[i].ID < graph.Nodes[j].ID })
+	sort.Slice(graph.Edges, func(i, j int) bool {
+		a, b := graph.Edges[i], graph.Edges[j]
+		return a.From < b.From || a.From == b.From && a.To < b.To
+	})
+	return graph, nil
+}
+
+// componentCall reports whether call renders a component, and which: the
+// generator turns <Card title="x" /> into Card(CardProps{Title: "x"}), with
+// type arguments on the props type for generic components. Components of
+// other packages are rendered the same way, by calling
+// ui.Card(ui.CardProps{...}).
+func componentCall(call *ast.CallExpr) (qualifier, name string, ok bool) {
+	if len(call.Args) == 0 {
+		return "", "", false
+	}
+	lit, ok := call.Args[0].(*ast.CompositeLit)
+	if !ok {
+		return "", "", false
+	}
+	propsType := unindex(lit.Type)
+
+	switch fun := unindex(call.Fun).(type) {
+	case *ast.Ident:
+		props, ok := propsType.(*ast.Ident)
+		if ok && props.Name == fun.Name+"Props" && unicode.IsUpper(rune(fun.Name[0])) {
+			return "", fun.Name, true
+		}
+	case *ast.SelectorExpr:
+		x, ok := fun.X.(*ast.Ident)
+		props, propsOK := propsType.(*ast.SelectorExpr)
+		if !ok || !propsOK || props.Sel.Name != fun.Sel.Name+"Props" || !unicode.IsUpper(rune(fun.Sel.Name[0])) {
+			return "", "", false
+		}
+		if px, ok := props.X.(*ast.Ident); ok && px.Name == x.Name {
+			return x.Name, fun.Sel.Name, true
+		}
+	}
+	return "", "", false
+}
+
+// unindex strips type arguments from an expression.
+func unindex(expr ast.Expr) ast.Expr {
+	switch e := expr.(type) {
+	case *ast.IndexExpr:
+		return e.X
+	case *ast.IndexListExpr:
+		return e.X
+	}
+	return expr
+}
+
+// funcName returns the name of a function, with the receiver type for
+// methods, as in List.Render.
+func funcName(fn *ast.FuncDecl) string {
+	if fn.Recv == nil || len(fn.Recv.List) == 0 {
+		return fn.Name.Name
+	}
+	recv := fn.Recv.List[0].Type
+	if star, ok := recv.(*ast.StarExpr); ok {
+		recv = star.X
+	}
+	if id, ok := unindex(recv).(*ast.Ident); ok {
+		return id.Name + "." + fn.Name.Name
+	}
+	return fn.Name.Name
+}
+
+// splitNodeID splits a node ID into its package path and name.
+func splitNodeID(id string) (pkgPath, name string) {
+	slash := strings.LastIndex(id, "/")
+	dot := strings.Index(id[slash+1:], ".")
+	if dot < 0 {
+		return "", id
+	}
+	return id[:slash+1+dot], id[slash+1+dot+1:]
+}
+
+// unused returns the components defined in the analyzed files that no
+// function in them renders. They may still be rendered from .go files or
+// other modules.
+func (g *componentGraph) unused() []graphNode {
+	rendered := make(map[string]bool)
+	for _, e := range g.Edges {
+		rendered[e.To] = true
+	}
+	var unused []graphNode
+	for _, n := range g.Nodes {
+		if !n.Func && !n.External && !rendered[n.ID] {
+			unused = append(unused, n)
+		}
+	}
+	return unused
+}
+
+// writeDOT writes the graph in Graphviz DOT format. Functions that are not
+// components are drawn as boxes, and components from other packages not
+// analyzed are dashed.
+func (g *componentGraph) writeDOT(w io.Writer) error {
+	bw := bufio.NewWriter(w)
+	fmt.Fprintln(bw, "digraph components {")
+	fmt.Fprintln(bw, "\trankdir=LR;")
+	fmt.Fprintln(bw, "\tnode [shape=ellipse];")
+	for _, n := range g.Nodes {
+		attrs := []string{"label=" + strconv.Quote(n.Name)}
+		if n.Func {
+			attrs = append(attrs, "shape=box")
+		}
+		if n.External {
+			attrs = append(attrs, "style=dashed")
+		}
+		fmt.Fprintf(bw, "\t%s [%s];\n", strconv.Quote(n.ID), strings.Join(attrs, ", "))
+	}
+	for _, e := range g.Edges {
+		if e.Count > 1 {
+			fmt.Fprintf(bw, "\t%s -> %s [label=%q];\n", strconv.Quote(e.From), strconv.Quote(e.To), strconv.Itoa(e.Count))
+			continue
+		}
+		fmt.Fprintf(bw, "\t%s -> %s;\n", strconv.Quote(e.From), strconv.Quote(e.To))
+	}
+	fmt.Fprintln(bw, "}")
+	return bw.Flush()
+}
+
+// goModule is a module root and its module path.
+type goModule struct {
+	dir, path string
+}
+
+// packagePath returns the import path of the package in dir, from the
+// nearest go.mod, or the slash-separated directory outside a module.
+// modules caches the module of each directory looked up.
+func packagePath(dir string, modules map[string]goModule) (string, error) {
+	abs, err := filepath.Abs(dir)
+	if err != nil {
+		return "", err
+	}
+	mod, ok := modules[abs]
+	if !ok {
+		mod = findModule(abs)
+		modules[abs] = mod
+	}
+	if mod.path == "" {
+		return filepath.ToSlash(filepath.Clean(dir)), nil
+	}
+	rel, err := filepath.Rel(mod.dir, abs)
+	if err != nil || rel == "." {
+		return mod.path, err
+	}
+	return mod.path + "/" + filepath.ToSlash(rel), nil
+}
+
+// findModule returns the module containing the absolute directory dir, or
+// the zero goModule outside any.
+func findModule(dir string) goModule {
+	for {
+		if data, err := os.ReadFile(filepath.Join(dir, "go.mod")); err == nil {
+			return goModule{dir: dir, path: modulePath(data)}
+		}
+		parent := filepath.Dir(dir)
+		if parent == dir {
+			return goModule{}
+		}
+		dir = parent
+	}
+}
+
+// modulePath returns the path in the module directive of go.mod data.
+func modulePath(data []byte) string {
+	for _, line := range strings.Split(string(data), "\n") {
+		line = strings.TrimSpace(line)
+		if rest, ok := strings.CutPrefix(line, "module"); ok && rest != "" && (rest[0] == ' ' || rest[0] == '\t') {
+			rest = strings.TrimSpace(rest)
+			if i := strings.Index(rest, "//"); i >= 0 {
+				rest = strings.TrimSpace(rest[:i])
+			}
+			if unquoted, err := strconv.Unquote(rest); err == nil {
+				return unquoted
+			}
+			return rest
+		}
+	}
+	return ""
+}
@@ -0,0 +1,95 @@
+package main
+
+import (
+	"bytes"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestComponentGraph(t *testing.T) {
+	dir := t.TempDir()
+	writeTree(t, dir, map[string]string{
+		"go.mod": "module example.com/app\n",
+		"ui/card.gox": `package ui
+
+type CardProps struct{ Title string }
+
+func Card(props CardProps) gox.VNode {
+	return <div>{props.Title}</div>
+}
+
+type UnusedProps struct{}
+
+func Unused(props UnusedProps) gox.VNode {
+	return <span />
+}
+`,
+		"pages/home.gox": `package pages
+
+import (
+	"example.com/app/ui"
+	icons "example.com/icons"
+)
+
+type HomeProps struct{}
+
+func Home(props HomeProps) gox.VNode {
+	return <main>
+		{ui.Card(ui.CardProps{Title: "a"})}
+		{ui.Card(ui.CardProps{Title: "b"})}
+		{icons.Star(icons.StarProps{})}
+	</main>
+}
+
+func render() gox.VNode {
+	return <Home />
+}
+`,
+	})
+	files := []string{filepath.Join(dir, "ui", "card.gox"), filepath.Join(dir, "pages", "home.gox")}
+	graph, err := buildComponentGraph(files, &generateConfig{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var nodes []string
+	for _, n := range graph.Nodes {
+		nodes = append(nodes, n.ID)
+	}
+	wantNodes := []string{
+		"example.com/app/pages.Home", "example.com/app/pages.render",
+		"example.com/app/ui.Card", "example.com/app/ui.Unused", "example.com/icons.Star",
+	}
+	if !reflect.DeepEqual(nodes, wantNodes) {
+		t.Errorf("nodes = %q, want %q", nodes, wantNodes)
+	}
+	wantEdges := []graphEdge{
+		{From: "example.com/app/pages.Home", To: "example.com/app/ui.Card", Count: 2},
+		{From: "example.com/app/pages.Home", To: "example.com/icons.Star", Count: 1},
+		{From: "example.com/app/pages.render", To: "example.com/app/pages.Home", Count: 1},
+	}
+	if !reflect.DeepEqual(graph.Edges, wantEdges) {
+		t.Errorf("edges = %+v, want %+v", graph.Edges, wantEdges)
+	}
+
+	unused := graph.unused()
+	if len(unused) != 1 || unused[0].Name != "ui.Unused" || unused[0].Line != 11 {
+		t.Errorf("unused = %+v, want ui.Unused on line 11", unused)
+	}
+
+	var dot bytes.Buffer
+	if err := graph.writeDOT(&dot); err != nil {
+		t.Fatal(err)
+	}
+	for _, want := range []string{
+		`"example.com/app/pages.render" [label="pages.render", shape=box];`,
+		`"example.com/icons.Star" [label="icons.Star", style=dashed];`,
+		`"example.com/app/pages.Home" -> "example.com/app/ui.Card" [label="2"];`,
+	} {
+		if !strings.Contains(dot.String(), want) {
+			t.Errorf("DOT output missing %s:\n%s", want, dot.String())
+		}
+	}
+}
@@ -0,0 +1,159 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+)
+
+// hookMarker identifies pre-commit hooks written by gox hook install, which
+// may be overwritten without -f.
+const hookMarker = "# gox pre-commit hook"
+
+// preCommitHook checks the formatting and types of the staged .gox files.
+const preCommitHook = `#!/bin/sh
+` + hookMarker + `, installed by "gox hook install".
+# Checks the formatting and types of the staged .gox files.
+
+files=$(git diff --cached --name-only --diff-filter=ACMR --relative -- '*.gox')
+[ -n "$files" ] || exit 0
+
+unformatted=$(printf '%s\n' "$files" | gox fmt -l -staged -) || exit 1
+if [ -n "$unformatted" ]; then
+	echo "gox: staged files are not formatted; run \"gox fmt -w\" and stage the result:" >&2
+	printf '%s\n' "$unformatted" | sed 's/^/	/' >&2
+	exit 1
+fi
+
+printf '%s\n' "$files" | gox check -staged -
+`
+
+// runHook runs the hook command.
+func runHook(args []string) error {
+	if len(args) == 0 || args[0] != "install" {
+		return fmt.Errorf("usage: gox hook install [-f]")
+	}
+
+	var force bool
+	fs := flag.NewFlagSet("hook install", flag.ExitOnError)
+	fs.BoolVar(&force, "f", false, "overwrite an existing pre-commit hook")
+	if err := fs.Parse(args[1:]); err != nil {
+		return err
+	}
+
+	path, err := installHook(".", force)
+	if err != nil {
+		return err
+	}
+	fmt.Printf("Installed pre-commit hook at %s\n", path)
+	return nil
+}
+
+// installHook writes the pre-commit hook of the git repository containing
+// dir, honoring core.hooksPath. A hook not written by gox is only replaced
+// with force.
+func installHook(dir string, force bool) (string, error) {
+	out, err := git(dir, "rev-parse", "--git-path", "hooks")
+	if err != nil {
+		return "", err
+	}
+	hooksDir := strings.TrimSpace(string(out))
+	if !filepath.IsAbs(hooksDir) {
+		hooksDir = filepath.Join(dir, hooksDir)
+	}
+	path := filepath.Join(hooksDir, "pre-commit")
+
+	if existing, err := os.ReadFile(path); err == nil && !force && !bytes.Contains(existing, []byte(hookMarker)) {
+		return "", fmt.Errorf("%s already exists; use -f to replace it", path)
+	}
+	if err := os.MkdirAll(hooksDir, 0755); err != nil {
+		return "", err
+	}
+	if err := os.WriteFile(path, []byte(preCommitHook), 0755); err != nil {
+		return "", fmt.Errorf("writing hook: %w", err)
+	}
+	// WriteFile keeps the mode of an existing file
+	if err := os.Chmod(path, 0755); err != nil {
+		return "", err
+	}
+	return path, nil
+}
+
+// stagedPaths returns the files a -staged command works on: the paths read
+// from stdin if args is "-", or else the .gox files staged in the git
+// repository containing dir. Staged files excluded by .goxignore are skipped.
+func stagedPaths(dir string, args []string, stdin io.Reader) ([]string, error) {
+	var paths []string
+	switch {
+	case len(args) == 1 && args[0] == "-":
+		scanner := bufio.NewScanner(stdin)
+		for scanner.Scan() {
+			if line := strings.TrimSpace(scanner.Text()); line != "" {
+				paths = append(paths, line)
+			}
+		}
+		if err := scanner.Err(); err != nil {
+			return nil, fmt.Errorf("reading paths from stdin: %w", err)
+		}
+	case len(args) == 0:
+		out, err := git(dir, "diff", "--cached", "--name-only", "--diff-filter=ACMR", "--relative", "-z")
+		if err != nil {
+			return nil, err
+		}
+		for _, name := range strings.Split(string(out), "\x00") {
+			if name != "" {
+				paths = append(paths, filepath.Join(dir, filepath.FromSlash(name)))
+			}
+		}
+	default:
+		return nil, fmt.Errorf("-staged takes no paths, or - to read them from stdin")
+	}
+
+	ignore := newIgnoreFiles()
+	var files []string
+	for _, path := range paths {
+		if !isGoxFile(path) {
+			continue
+		}
+		ignored, err := ignore.ignored(path, false)
+		if err != nil {
+			return nil, err
+		}
+		if !ignored {
+			files = append(files, path)
+		}
+	}
+	return files, nil
+}
+
+// stagedSource returns the staged content of path, which may differ from the
+// file on disk when only some changes are staged.
+func stagedSource(path string) ([]byte, error) {
+	dir, name := filepath.Split(path)
+	if dir == "" {
+		dir = "."
+	}
+	return git(dir, "show", ":./"+name)
+}
+
+// git runs a git command in dir and returns its output.
+func git(dir string, args ...string) ([]byte, error) {
+	cmd := exec.Command("git", args...)
+	cmd.Dir = dir
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	out, err := cmd.Output()
+	if err != nil {
+		if msg := strings.TrimSpace(stderr.String()); msg != "" {
+			return nil, fmt.Errorf("git %s: %s", args[0], msg)
+		}
+		return nil, fmt.Errorf("git %s: %w", args[0], err)
+	}
+	return out, nil
+}
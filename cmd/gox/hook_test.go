@@ -0,0 +1,111 @@
+package main
+
+import (
+	"os"
+	"os/exec"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// gitRepo creates a git repository with a go.mod for tests, skipping them if
+// git is not installed.
+func gitRepo(t *testing.T) string {
+	t.Helper()
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git not installed")
+	}
+	dir := t.TempDir()
+	if _, err := git(dir, "init", "-q"); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "go.mod"), []byte("module example.com/app\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	return dir
+}
+
+func TestInstallHook(t *testing.T) {
+	dir := gitRepo(t)
+	path, err := installHook(dir, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := filepath.Join(dir, ".git", "hooks", "pre-commit"); path != want {
+		t.Errorf("hook path = %s, want %s", path, want)
+	}
+	info, err := os.Stat(path)
+	if err != nil || info.Mode().Perm()&0100 == 0 {
+		t.Fatalf("hook is not executable: %v %v", info, err)
+	}
+	if out, err := exec.Command("sh", "-n", path).CombinedOutput(); err != nil {
+		t.Errorf("hook is not a valid shell script: %v\n%s", err, out)
+	}
+
+	// Reinstalling replaces our own hook, but not someone else's
+	if _, err := installHook(dir, false); err != nil {
+		t.Errorf("reinstalling: %v", err)
+	}
+	if err := os.WriteFile(path, []byte("#!/bin/sh\nmake lint\n"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := installHook(dir, false); err == nil || !strings.Contains(err.Error(), "-f") {
+		t.Errorf("installing over another hook = %v, want an error suggesting -f", err)
+	}
+	if _, err := installHook(dir, true); err != nil {
+		t.Errorf("installing with force: %v", err)
+	}
+}
+
+func TestStagedPaths(t *testing.T) {
+	dir := gitRepo(t)
+	write := func(name, content string) {
+		t.Helper()
+		path := filepath.Join(dir, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	write(".goxignore", "third_party/\n")
+	write("ui/button.gox", "package ui\n\nfunc B() gox.VNode { return <button></button> }\n")
+	write("ui/card.gox", "package ui\n")
+	write("ui/util.go", "package ui\n")
+	write("third_party/x.gox", "package x\n")
+	if _, err := git(dir, "add", "ui/button.gox", "ui/util.go", "third_party/x.gox"); err != nil {
+		t.Fatal(err)
+	}
+
+	got, err := stagedPaths(dir, nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := []string{filepath.Join(dir, "ui", "button.gox")}; !reflect.DeepEqual(got, want) {
+		t.Errorf("stagedPaths = %q, want %q", got, want)
+	}
+
+	got, err = stagedPaths(dir, []string{"-"}, strings.NewReader("a.gox\n\nb.go\n  c.gox  \n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := []string{"a.gox", "c.gox"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("stagedPaths from stdin = %q, want %q", got, want)
+	}
+
+	// The staged content is used, not the file on disk
+	write("ui/button.gox", "package ui\n")
+	src, err := stagedSource(filepath.Join(dir, "ui", "button.gox"))
+	if err != nil || !strings.Contains(string(src), "<button></button>") {
+		t.Errorf("stagedSource = %q, %v", src, err)
+	}
+}
+
+func TestPackageDirs(t *testing.T) {
+	got := packageDirs([]string{"ui/a.gox", "ui/b.gox", "app.gox", "/abs/pkg/c.gox"})
+	if want := []string{"./ui", ".", "/abs/pkg"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("packageDirs = %q, want %q", got, want)
+	}
+}
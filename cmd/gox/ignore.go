@@ -0,0 +1,143 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// ignoreFileName is the file listing paths that gox commands skip when they
+// expand directories, dir/... patterns and globs, in gitignore syntax.
+const ignoreFileName = ".goxignore"
+
+// ignoreRule is one pattern line of a .goxignore file.
+type ignoreRule struct {
+	elems    []string // Pattern path elements, matched with matchElems
+	negate   bool     // "!pattern" re-includes what earlier rules excluded
+	dirOnly  bool     // "pattern/" only matches directories
+	anchored bool     // Patterns containing a slash are relative to the file
+}
+
+// ignoreList is a parsed .goxignore file.
+type ignoreList struct {
+	root  string // Directory containing the file; patterns are relative to it
+	rules []ignoreRule
+}
+
+// parseIgnore parses a .goxignore file in dir.
+func parseIgnore(dir string, data []byte) *ignoreList {
+	list := &ignoreList{root: dir}
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	for scanner.Scan() {
+		line := strings.TrimRight(scanner.Text(), " \t\r")
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		var rule ignoreRule
+		if strings.HasPrefix(line, "!") {
+			rule.negate = true
+			line = line[1:]
+		} else if strings.HasPrefix(line, `\#`) || strings.HasPrefix(line, `\!`) {
+			line = line[1:]
+		}
+		if strings.HasSuffix(line, "/") {
+			rule.dirOnly = true
+			line = strings.TrimRight(line, "/")
+		}
+		rule.anchored = strings.Contains(line, "/")
+		line = strings.TrimPrefix(line, "/")
+		if line == "" {
+			continue
+		}
+		rule.elems = strings.Split(line, "/")
+		list.rules = append(list.rules, rule)
+	}
+	return list
+}
+
+// ignored reports whether the file or directory at the absolute path abs is
+// ignored. As with .gitignore, the last matching rule wins, and nothing
+// inside an ignored directory can be re-included.
+func (l *ignoreList) ignored(abs string, isDir bool) bool {
+	rel, err := filepath.Rel(l.root, abs)
+	if err != nil || rel == "." || strings.HasPrefix(rel, "..") {
+		return false
+	}
+	elems := strings.Split(filepath.ToSlash(rel), "/")
+	for i := 1; i < len(elems); i++ {
+		if l.match(elems[:i], true) {
+			return true
+		}
+	}
+	return l.match(elems, isDir)
+}
+
+func (l *ignoreList) match(elems []string, isDir bool) bool {
+	ignored := false
+	for _, rule := range l.rules {
+		if rule.dirOnly && !isDir {
+			continue
+		}
+		name := elems
+		if !rule.anchored {
+			// A pattern without a slash matches the name at any depth
+			name = elems[len(elems)-1:]
+		}
+		if matchElems(rule.elems, name) {
+			ignored = !rule.negate
+		}
+	}
+	return ignored
+}
+
+// ignoreFiles finds the .goxignore file that applies to each path: the
+// nearest one in the path's directory or its parents, up to the module root.
+// Lookups are cached by directory.
+type ignoreFiles struct {
+	lists map[string]*ignoreList // By absolute directory; nil if none applies
+}
+
+func newIgnoreFiles() *ignoreFiles {
+	return &ignoreFiles{lists: make(map[string]*ignoreList)}
+}
+
+// ignored reports whether path is excluded by a .goxignore file.
+func (f *ignoreFiles) ignored(path string, isDir bool) (bool, error) {
+	abs, err := filepath.Abs(path)
+	if err != nil {
+		return false, err
+	}
+	list, err := f.list(filepath.Dir(abs))
+	if err != nil || list == nil {
+		return false, err
+	}
+	return list.ignored(abs, isDir), nil
+}
+
+// list returns the .goxignore file that applies in the absolute directory dir.
+func (f *ignoreFiles) list(dir string) (*ignoreList, error) {
+	if list, ok := f.lists[dir]; ok {
+		return list, nil
+	}
+
+	var list *ignoreList
+	data, err := os.ReadFile(filepath.Join(dir, ignoreFileName))
+	switch {
+	case err == nil:
+		list = parseIgnore(dir, data)
+	case !os.IsNotExist(err):
+		return nil, fmt.Errorf("reading %s: %w", ignoreFileName, err)
+	default:
+		_, statErr := os.Stat(filepath.Join(dir, "go.mod"))
+		if parent := filepath.Dir(dir); statErr != nil && parent != dir {
+			if list, err = f.list(parent); err != nil {
+				return nil, err
+			}
+		}
+	}
+	f.lists[dir] = list
+	return list, nil
+}
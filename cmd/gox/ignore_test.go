@@ -0,0 +1,101 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestIgnoreList(t *testing.T) {
+	list := parseIgnore("/p", []byte(`# Vendored component libraries
+third_party/
+/fixtures
+*_draft.gox
+!keep_draft.gox
+ui/**/experimental
+\#literal.gox
+`))
+	tests := []struct {
+		path  string
+		isDir bool
+		want  bool
+	}{
+		{"/p/third_party", true, true},
+		{"/p/ui/third_party", true, true},
+		{"/p/third_party", false, false},
+		{"/p/third_party/lib/button.gox", false, true},
+		{"/p/fixtures", true, true},
+		{"/p/fixtures/a.gox", false, true},
+		{"/p/ui/fixtures/a.gox", false, false},
+		{"/p/ui/card_draft.gox", false, true},
+		{"/p/ui/keep_draft.gox", false, false},
+		{"/p/third_party/keep_draft.gox", false, true},
+		{"/p/ui/experimental/a.gox", false, true},
+		{"/p/ui/forms/experimental/a.gox", false, true},
+		{"/p/experimental/a.gox", false, false},
+		{"/p/#literal.gox", false, true},
+		{"/p/app.gox", false, false},
+		{"/other/fixtures/a.gox", false, false},
+	}
+	for _, tt := range tests {
+		if got := list.ignored(filepath.FromSlash(tt.path), tt.isDir); got != tt.want {
+			t.Errorf("ignored(%s, dir=%v) = %v, want %v", tt.path, tt.isDir, got, tt.want)
+		}
+	}
+}
+
+func TestFindGoxFilesIgnore(t *testing.T) {
+	dir := t.TempDir()
+	for name, content := range map[string]string{
+		"go.mod":                  "module example.com/app\n",
+		".goxignore":              "third_party/\n*_draft.gox\n",
+		"app.gox":                 "",
+		"app_draft.gox":           "",
+		"ui/button.gox":           "",
+		"ui/card_draft.gox":       "",
+		"third_party/lib/x.gox":   "",
+		"third_party/lib/y.gox":   "",
+		"ui/forms/.goxignore":     "*\n!*.gox\nlegacy.gox\n",
+		"ui/forms/input.gox":      "",
+		"ui/forms/legacy.gox":     "",
+		"ui/forms/form_draft.gox": "",
+	} {
+		path := filepath.Join(dir, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	tests := []struct {
+		paths []string
+		want  []string
+	}{
+		// The nearest .goxignore applies: ui/forms has its own
+		{[]string{"..."}, []string{"app.gox", "ui/button.gox", "ui/forms/form_draft.gox", "ui/forms/input.gox"}},
+		{[]string{"."}, []string{"app.gox"}},
+		{[]string{"**/*.gox"}, []string{"app.gox", "ui/button.gox", "ui/forms/form_draft.gox", "ui/forms/input.gox"}},
+		// Explicitly named files are never ignored
+		{[]string{"app_draft.gox", "third_party/lib/x.gox"}, []string{"app_draft.gox", "third_party/lib/x.gox"}},
+	}
+	for _, tt := range tests {
+		var paths []string
+		for _, p := range tt.paths {
+			paths = append(paths, filepath.Join(dir, p))
+		}
+		got, err := findGoxFiles(paths)
+		if err != nil {
+			t.Fatalf("findGoxFiles(%q): %v", tt.paths, err)
+		}
+		for i := range got {
+			got[i], _ = filepath.Rel(dir, got[i])
+			got[i] = filepath.ToSlash(got[i])
+		}
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("findGoxFiles(%q) = %q, want %q", tt.paths, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,164 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+
+	"github.com/germtb/gox/generator"
+)
+
+// runtimeModule is the module path of the gox runtime.
+const runtimeModule = "github.com/germtb/gox"
+
+const sampleComponent = `package main
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/germtb/gox"
+)
+
+// Greeting is a sample component. Edit it and run "gox run ." to see the result.
+func Greeting(name string) gox.VNode {
+	return <div class="greeting">
+		<h1>Hello, {name}!</h1>
+		<p>Edit hello.gox to get started.</p>
+	</div>
+}
+
+func main() {
+	gox.WalkTree(Greeting("gox"), gox.WalkFunc(func(node gox.VNode, depth int) bool {
+		indent := strings.Repeat("  ", depth)
+		if text, ok := node.GetTextContent(); ok {
+			fmt.Println(indent + text)
+			return false
+		}
+		if tag, ok := node.Type.(string); ok && !node.IsFragment() {
+			fmt.Println(indent + "<" + tag + ">")
+		}
+		return true
+	}))
+}
+`
+
+// initConfig holds configuration for the init command.
+type initConfig struct {
+	module string // Module path for a new go.mod
+	dir    string
+}
+
+// runInit scaffolds a minimal gox project.
+func runInit(args []string) error {
+	cfg := &initConfig{}
+
+	fs := flag.NewFlagSet("init", flag.ExitOnError)
+	fs.StringVar(&cfg.module, "module", "", "module path for go.mod (default: directory name)")
+
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	cfg.dir = "."
+	if fs.NArg() > 0 {
+		cfg.dir = fs.Arg(0)
+	}
+
+	if err := os.MkdirAll(cfg.dir, 0755); err != nil {
+		return fmt.Errorf("creating directory: %w", err)
+	}
+
+	// go.mod wiring
+	if _, err := os.Stat(filepath.Join(cfg.dir, "go.mod")); os.IsNotExist(err) {
+		module := cfg.module
+		if module == "" {
+			abs, err := filepath.Abs(cfg.dir)
+			if err != nil {
+				return fmt.Errorf("getting absolute path: %w", err)
+			}
+			module = filepath.Base(abs)
+		}
+		if err := runGoIn(cfg.dir, "mod", "init", module); err != nil {
+			return fmt.Errorf("go mod init: %w", err)
+		}
+	} else if cfg.module != "" {
+		fmt.Fprintf(os.Stderr, "go.mod already exists; ignoring -module\n")
+	}
+
+	created, err := scaffoldProject(cfg.dir)
+	if err != nil {
+		return err
+	}
+	for _, f := range created {
+		fmt.Printf("created %s\n", filepath.Join(cfg.dir, f))
+	}
+
+	if err := runGoIn(cfg.dir, "get", runtimeModule+"@"+runtimeVersion()); err != nil {
+		fmt.Fprintf(os.Stderr, "warning: could not add %s to go.mod: %v\n", runtimeModule, err)
+		fmt.Fprintf(os.Stderr, "run \"go get %s\" in %s to finish setup\n", runtimeModule, cfg.dir)
+	}
+
+	fmt.Printf("\nDone. Try:\n")
+	if cfg.dir != "." {
+		fmt.Printf("  cd %s\n", cfg.dir)
+	}
+	fmt.Printf("  gox run .\n")
+	return nil
+}
+
+// scaffoldProject writes the sample files into dir, leaving existing files
+// untouched, and makes sure .gitignore covers generated files. It returns the
+// names of the files it created or changed.
+func scaffoldProject(dir string) ([]string, error) {
+	var created []string
+
+	files := []struct {
+		name    string
+		content string
+	}{
+		{"hello.gox", sampleComponent},
+		{generateStubName, generateStub("main", "gox generate .")},
+	}
+	for _, f := range files {
+		path := filepath.Join(dir, f.name)
+		if _, err := os.Stat(path); err == nil {
+			fmt.Fprintf(os.Stderr, "skipping %s (already exists)\n", path)
+			continue
+		}
+		if err := os.WriteFile(path, []byte(f.content), 0644); err != nil {
+			return created, fmt.Errorf("writing %s: %w", f.name, err)
+		}
+		created = append(created, f.name)
+	}
+
+	changed, err := ensureGitignore(filepath.Join(dir, ".gitignore"), gitignorePatterns(generator.DefaultSuffix))
+	if err != nil {
+		return created, err
+	}
+	if changed {
+		created = append(created, ".gitignore")
+	}
+	return created, nil
+}
+
+// runtimeVersion returns the runtime version matching this gox binary, or
+// "latest" for development builds.
+func runtimeVersion() string {
+	if version == "dev" || version == "" {
+		return "latest"
+	}
+	return "v" + strings.TrimPrefix(version, "v")
+}
+
+// runGoIn runs a go command in dir, passing its output through.
+func runGoIn(dir string, args ...string) error {
+	cmd := exec.Command("go", args...)
+	cmd.Dir = dir
+	cmd.Stdout = os.Stdout
+	cmd.Stderr = os.Stderr
+	return cmd.Run()
+}
@@ -0,0 +1,68 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/germtb/gox/formatter"
+	"github.com/germtb/gox/generator"
+)
+
+func TestScaffoldProject(t *testing.T) {
+	dir := t.TempDir()
+	existing := filepath.Join(dir, "generate.go")
+	if err := os.WriteFile(existing, []byte("package main\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	created, err := scaffoldProject(dir)
+	if err != nil {
+		t.Fatalf("scaffoldProject error: %v", err)
+	}
+	if strings.Join(created, ",") != "hello.gox,.gitignore" {
+		t.Errorf("created = %v, want [hello.gox .gitignore]", created)
+	}
+
+	// Existing files are left alone
+	if data, _ := os.ReadFile(existing); string(data) != "package main\n" {
+		t.Errorf("generate.go was overwritten: %q", data)
+	}
+
+	// The sample is already formatted, so gox fmt leaves it untouched
+	src, err := os.ReadFile(filepath.Join(dir, "hello.gox"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	formatted, err := formatter.Source(src, nil)
+	if err != nil {
+		t.Fatalf("sample does not format: %v", err)
+	}
+	if string(formatted) != string(src) {
+		t.Errorf("sample is not gox fmt clean:\n%s", formatted)
+	}
+}
+
+func TestEnsureGitignore(t *testing.T) {
+	path := filepath.Join(t.TempDir(), ".gitignore")
+	if err := os.WriteFile(path, []byte("bin/\n*_gox.go"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	changed, err := ensureGitignore(path, gitignorePatterns(generator.DefaultSuffix))
+	if err != nil || !changed {
+		t.Fatalf("ensureGitignore() = %v, %v; want true, nil", changed, err)
+	}
+	data, _ := os.ReadFile(path)
+	want := "bin/\n*_gox.go\n\n# gox generated files\n*_gox_test.go\n*_gox.go.map\n*_gox_test.go.map\n"
+	if string(data) != want {
+		t.Errorf(".gitignore = %q, want %q", data, want)
+	}
+
+	// Running again is a no-op
+	changed, err = ensureGitignore(path, gitignorePatterns(generator.DefaultSuffix))
+	if err != nil || changed {
+		t.Errorf("second ensureGitignore() = %v, %v; want false, nil", changed, err)
+	}
+}
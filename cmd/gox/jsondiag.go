@@ -0,0 +1,117 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"io"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/germtb/gox/diag"
+	"github.com/germtb/gox/vet"
+)
+
+// jsonDiagnostic is a diagnostic printed by -json, as one JSON object per
+// line. Positions are 1-indexed and refer to .gox sources wherever a source
+// map covers them.
+type jsonDiagnostic struct {
+	File      string   `json:"file,omitempty"`
+	Line      int      `json:"line,omitempty"`
+	Column    int      `json:"column,omitempty"`
+	EndLine   int      `json:"endLine,omitempty"` // Exclusive end of the range, if known
+	EndColumn int      `json:"endColumn,omitempty"`
+	Severity  string   `json:"severity"`       // "error" or "warning"
+	Message   string   `json:"message"`        // Without the position or code
+	Hint      string   `json:"hint,omitempty"` // Suggested fix, if any
+	Fix       *jsonFix `json:"fix,omitempty"`  // Edit that fixes the problem, if known
+	Code      string   `json:"code,omitempty"` // GOX code, or the analyzer name for vet
+	Source    string   `json:"source"`         // "gox", "go" or "vet"
+}
+
+// jsonFix is the fix of a diagnostic printed by -json: text replaces the
+// range from line:column to endLine:endColumn, or is inserted at line:column
+// if the two are the same.
+type jsonFix struct {
+	Message   string `json:"message"`
+	Line      int    `json:"line"`
+	Column    int    `json:"column"`
+	EndLine   int    `json:"endLine"`
+	EndColumn int    `json:"endColumn"`
+	Text      string `json:"text"`
+}
+
+// errDiagnosticsReported marks errors whose diagnostics were already printed
+// as JSON, so main exits without printing them again.
+var errDiagnosticsReported = errors.New("diagnostics reported")
+
+// errorDiagnostic converts an error from generating or formatting file. Coded
+// diagnostics keep their code and position.
+func errorDiagnostic(file string, err error) jsonDiagnostic {
+	d := jsonDiagnostic{File: file, Severity: "error", Message: err.Error(), Source: "gox"}
+	if coded, ok := diag.As(err); ok {
+		if coded.File != "" {
+			d.File = coded.File
+		}
+		d.Line, d.Column = coded.Line, coded.Column
+		d.EndLine, d.EndColumn = coded.EndLine, coded.EndColumn
+		d.Message, d.Hint, d.Code = coded.Message, coded.Hint, string(coded.Code)
+		if fix := coded.Fix; fix != nil {
+			d.Fix = &jsonFix{fix.Message, fix.Line, fix.Column, fix.EndLine, fix.EndColumn, fix.Text}
+		}
+	}
+	return d
+}
+
+// vetDiagnostic converts a finding of a gox analyzer.
+func vetDiagnostic(d vet.Diagnostic) jsonDiagnostic {
+	return jsonDiagnostic{
+		File:     d.File,
+		Line:     d.Line,
+		Column:   d.Column,
+		Severity: "warning",
+		Message:  d.Message,
+		Code:     d.Analyzer,
+		Source:   "vet",
+	}
+}
+
+// goOutputPattern matches a "file:line[:col]: message" line of go output.
+var goOutputPattern = regexp.MustCompile(`^(\S+?):(\d+)(?::(\d+))?: (.*)$`)
+
+// goDiagnostics converts the (remapped) stderr of a go command. Package
+// headers ("# pkg") are dropped, and indented lines are continuation lines of
+// the previous diagnostic. Any other line is a diagnostic without a position.
+func goDiagnostics(output string) []jsonDiagnostic {
+	var diagnostics []jsonDiagnostic
+	scanner := bufio.NewScanner(strings.NewReader(output))
+	for scanner.Scan() {
+		line := scanner.Text()
+		switch {
+		case strings.TrimSpace(line) == "" || strings.HasPrefix(line, "# "):
+			continue
+		case (line[0] == ' ' || line[0] == '\t') && len(diagnostics) > 0:
+			last := &diagnostics[len(diagnostics)-1]
+			last.Message += "\n" + strings.TrimSpace(line)
+			continue
+		}
+
+		d := jsonDiagnostic{Severity: "error", Message: line, Source: "go"}
+		if m := goOutputPattern.FindStringSubmatch(line); m != nil {
+			d.File, d.Message = m[1], m[4]
+			d.Line, _ = strconv.Atoi(m[2])
+			d.Column, _ = strconv.Atoi(m[3])
+		}
+		diagnostics = append(diagnostics, d)
+	}
+	return diagnostics
+}
+
+// writeDiagnostics prints diagnostics as newline-delimited JSON.
+func writeDiagnostics(w io.Writer, diagnostics ...jsonDiagnostic) {
+	enc := json.NewEncoder(w)
+	for _, d := range diagnostics {
+		enc.Encode(d)
+	}
+}
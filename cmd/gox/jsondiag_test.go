@@ -0,0 +1,79 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/germtb/gox/parser"
+)
+
+func TestErrorDiagnostic(t *testing.T) {
+	_, err := parser.Parse("app.gox", []byte("package ui\n\nvar x = <div>\n"))
+	if err == nil {
+		t.Fatal("expected a parse error")
+	}
+	d := errorDiagnostic("app.gox", fmt.Errorf("parsing: %w", err))
+	if d.File != "app.gox" || d.Line == 0 || d.Code == "" || d.Severity != "error" || d.Source != "gox" {
+		t.Errorf("errorDiagnostic = %+v, want a coded error at a position", d)
+	}
+	if d.Hint == "" {
+		t.Errorf("errorDiagnostic = %+v, want the hint of the parse error", d)
+	}
+	if want := (jsonFix{"insert </div>", 3, 14, 3, 14, "</div>"}); d.Fix == nil || *d.Fix != want {
+		t.Errorf("errorDiagnostic fix = %+v, want %+v", d.Fix, want)
+	}
+	if strings.Contains(d.Message, d.Code) {
+		t.Errorf("message %q should not repeat the code", d.Message)
+	}
+
+	d = errorDiagnostic("app.gox", fmt.Errorf("reading file: denied"))
+	if want := (jsonDiagnostic{File: "app.gox", Severity: "error", Message: "reading file: denied", Source: "gox"}); d != want {
+		t.Errorf("errorDiagnostic = %+v, want %+v", d, want)
+	}
+}
+
+func TestGoDiagnostics(t *testing.T) {
+	output := "# example.com/app/ui\n" +
+		"ui/button.gox:15:5: undefined: foo\n" +
+		"ui/card.gox:3: cannot use x (variable of type int) as string value:\n" +
+		"\tneed string\n" +
+		"too many errors\n"
+	want := []jsonDiagnostic{
+		{File: "ui/button.gox", Line: 15, Column: 5, Severity: "error", Message: "undefined: foo", Source: "go"},
+		{File: "ui/card.gox", Line: 3, Severity: "error", Message: "cannot use x (variable of type int) as string value:\nneed string", Source: "go"},
+		{Severity: "error", Message: "too many errors", Source: "go"},
+	}
+	if got := goDiagnostics(output); !reflect.DeepEqual(got, want) {
+		t.Errorf("goDiagnostics =\n%+v\nwant\n%+v", got, want)
+	}
+}
+
+func TestWriteDiagnostics(t *testing.T) {
+	var buf bytes.Buffer
+	writeDiagnostics(&buf, goDiagnostics("a.gox:1:2: x\nb.gox:3:4: y\n")...)
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	if len(lines) != 2 {
+		t.Fatalf("got %d lines, want 2:\n%s", len(lines), buf.String())
+	}
+	var d jsonDiagnostic
+	if err := json.Unmarshal([]byte(lines[1]), &d); err != nil || d.File != "b.gox" || d.Column != 4 {
+		t.Errorf("second line = %s (%v)", lines[1], err)
+	}
+}
+
+func TestTakeFlag(t *testing.T) {
+	args, err := parseGoArgs("build", []string{"-gox-json", "-o", "app", "./..."})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !args.takeFlag("gox-json") || args.takeFlag("gox-json") {
+		t.Error("takeFlag should report the flag once")
+	}
+	if want := []string{"-o", "app"}; !reflect.DeepEqual(args.flags, want) {
+		t.Errorf("flags = %q, want %q", args.flags, want)
+	}
+}
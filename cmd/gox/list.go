@@ -0,0 +1,226 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"go/ast"
+	goparser "go/parser"
+	"go/token"
+	"io"
+	"os"
+	"path"
+	"strconv"
+	"strings"
+	"unicode"
+
+	"github.com/germtb/gox/generator"
+	"github.com/germtb/gox/parser"
+)
+
+// Statuses of the generated file of a listed .gox file.
+const (
+	listCurrent = "current" // Matches what gox generate would write
+	listStale   = "stale"   // Differs from what gox generate would write
+	listEdited  = "edited"  // Changed by hand since gox generated it
+	listMissing = "missing" // Not generated yet
+	listError   = "error"   // The .gox file does not generate
+)
+
+// listedFile describes a .gox file for gox list.
+type listedFile struct {
+	File       string            `json:"file"`
+	Output     string            `json:"output"`
+	Package    string            `json:"package,omitempty"`
+	Components []listedComponent `json:"components"`
+	Status     string            `json:"status"`
+	Error      string            `json:"error,omitempty"`
+}
+
+// listedComponent is a component defined by a .gox file.
+type listedComponent struct {
+	Name string `json:"name"`
+	Line int    `json:"line"` // In the .gox file
+}
+
+// runList runs the list command: describe every .gox file found, for build
+// tooling and editors.
+func runList(args []string) error {
+	cfg := &generateConfig{}
+	asJSON := false
+
+	fs := flag.NewFlagSet("list", flag.ExitOnError)
+	fs.BoolVar(&asJSON, "json", false, "print one JSON object per file")
+	fs.StringVar(&cfg.outputDir, "o", "", "output directory the files are generated to")
+	fs.StringVar(&cfg.runtimePkg, "runtime", "", "runtime package path")
+
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	cfg.paths = fs.Args()
+	if len(cfg.paths) == 0 {
+		cfg.paths = []string{"./..."}
+	}
+
+	files, err := findGoxFiles(cfg.paths)
+	if err != nil {
+		return fmt.Errorf("finding files: %w", err)
+	}
+
+	failed := 0
+	enc := json.NewEncoder(os.Stdout)
+	for _, file := range files {
+		listed, err := listFile(file, cfg)
+		if err != nil {
+			return err
+		}
+		if listed.Status == listError {
+			failed++
+		}
+		if asJSON {
+			if err := enc.Encode(listed); err != nil {
+				return err
+			}
+			continue
+		}
+		printListedFile(os.Stdout, listed)
+	}
+
+	if failed > 0 {
+		return fmt.Errorf("%d file(s) failed", failed)
+	}
+	return nil
+}
+
+// listFile generates a .gox file in memory to describe it. Errors in the
+// .gox file are reported in the result rather than returned.
+func listFile(file string, cfg *generateConfig) (listedFile, error) {
+	listed := listedFile{
+		File:       file,
+		Output:     getOutputPath(file, cfg.outputDir),
+		Components: []listedComponent{},
+	}
+
+	if name, _, err := goxPackageName(token.NewFileSet(), file); err == nil {
+		listed.Package = name
+	}
+	output, sourceMap, err := generateFile(file, cfg)
+	if err != nil {
+		listed.Status, listed.Error = listError, diagnosticText(file, err)
+		return listed, nil
+	}
+
+	src, err := os.ReadFile(file)
+	if err != nil {
+		return listed, err
+	}
+	goxFile, err := parser.Parse(file, src)
+	if err != nil {
+		return listed, err
+	}
+	runtimePkg, err := cfg.resolvedRuntime(goxFile)
+	if err != nil {
+		return listed, err
+	}
+	listed.Components = definedComponents(output, sourceMap, runtimePkg)
+
+	listed.Status, _, err = outputStatus(listed.Output, output)
+	return listed, err
+}
+
+// outputStatus compares the generated file at path with output, what gox
+// generate would write there now. It returns the file's status and the
+// provenance recorded in its header, if it has one.
+func outputStatus(path string, output []byte) (string, *generator.Provenance, error) {
+	existing, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return listMissing, nil, nil
+	}
+	if err != nil {
+		return "", nil, fmt.Errorf("reading %s: %w", path, err)
+	}
+	var prov *generator.Provenance
+	if p, ok := generator.ReadProvenance(existing); ok {
+		prov = &p
+	}
+	switch {
+	case bytes.Equal(existing, output):
+		return listCurrent, prov, nil
+	case prov != nil && prov.Edited(existing):
+		return listEdited, prov, nil
+	default:
+		return listStale, prov, nil
+	}
+}
+
+// definedComponents returns the components declared in generated code:
+// top-level functions with an upper-case name, usable as a tag, that return
+// a VNode of the runtime package. Lines are mapped back to the .gox file.
+func definedComponents(output []byte, sourceMap *generator.SourceMap, runtimePkg string) []listedComponent {
+	components := []listedComponent{}
+	fset := token.NewFileSet()
+	f, err := goparser.ParseFile(fset, "", output, goparser.SkipObjectResolution)
+	if err != nil {
+		return components
+	}
+
+	runtimeName := ""
+	for _, imp := range f.Imports {
+		if importPath, err := strconv.Unquote(imp.Path.Value); err == nil && importPath == runtimePkg {
+			runtimeName = path.Base(importPath)
+			if imp.Name != nil {
+				runtimeName = imp.Name.Name
+			}
+		}
+	}
+	if runtimeName == "" {
+		return components
+	}
+
+	for _, decl := range f.Decls {
+		fn, ok := decl.(*ast.FuncDecl)
+		if !ok || fn.Recv != nil || !unicode.IsUpper(rune(fn.Name.Name[0])) || !returnsRuntimeVNode(fn.Type, runtimeName) {
+			continue
+		}
+		line := fset.Position(fn.Name.Pos()).Line
+		if srcLine, ok := sourceMap.FindSourceLine(uint32(line - 1)); ok {
+			line = int(srcLine) + 1
+		}
+		components = append(components, listedComponent{Name: fn.Name.Name, Line: line})
+	}
+	return components
+}
+
+// returnsRuntimeVNode reports whether a function returns exactly a VNode of
+// the runtime package, imported as runtimeName.
+func returnsRuntimeVNode(ft *ast.FuncType, runtimeName string) bool {
+	if ft.Results == nil || len(ft.Results.List) != 1 || len(ft.Results.List[0].Names) > 1 {
+		return false
+	}
+	sel, ok := ft.Results.List[0].Type.(*ast.SelectorExpr)
+	if !ok || sel.Sel.Name != "VNode" {
+		return false
+	}
+	x, ok := sel.X.(*ast.Ident)
+	return ok && x.Name == runtimeName
+}
+
+// printListedFile prints a listed file as text.
+func printListedFile(w io.Writer, listed listedFile) {
+	fmt.Fprintln(w, listed.File)
+	fmt.Fprintf(w, "  output:     %s (%s)\n", listed.Output, listed.Status)
+	if listed.Package != "" {
+		fmt.Fprintf(w, "  package:    %s\n", listed.Package)
+	}
+	if len(listed.Components) > 0 {
+		names := make([]string, len(listed.Components))
+		for i, c := range listed.Components {
+			names[i] = c.Name
+		}
+		fmt.Fprintf(w, "  components: %s\n", strings.Join(names, ", "))
+	}
+	if listed.Error != "" {
+		fmt.Fprintf(w, "  error:      %s\n", listed.Error)
+	}
+}
@@ -0,0 +1,71 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestListFile(t *testing.T) {
+	dir := t.TempDir()
+	writeTree(t, dir, map[string]string{
+		"ui/card.gox": `package ui
+
+import g "github.com/germtb/gox"
+
+type CardProps struct {
+	Title string
+}
+
+func Card(props CardProps) g.VNode {
+	return <div>{props.Title}</div>
+}
+
+func helper() g.VNode {
+	return <span />
+}
+
+func Count() int { return 1 }
+`,
+		"ui/broken.gox": "package ui\n\nfunc Broken() gox.VNode {\n\treturn <div>\n}\n",
+	})
+	cfg := &generateConfig{}
+	card := filepath.Join(dir, "ui", "card.gox")
+
+	listed, err := listFile(card, cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := listedFile{
+		File:       card,
+		Output:     filepath.Join(dir, "ui", "card_gox.go"),
+		Package:    "ui",
+		Components: []listedComponent{{Name: "Card", Line: 9}},
+		Status:     listMissing,
+	}
+	if !reflect.DeepEqual(listed, want) {
+		t.Errorf("listFile = %+v, want %+v", listed, want)
+	}
+
+	output, _, err := generateFile(card, cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for content, status := range map[string]string{string(output): listCurrent, "package ui\n": listStale} {
+		if err := os.WriteFile(want.Output, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+		if listed, err := listFile(card, cfg); err != nil || listed.Status != status {
+			t.Errorf("status = %q, %v; want %q", listed.Status, err, status)
+		}
+	}
+
+	listed, err = listFile(filepath.Join(dir, "ui", "broken.gox"), cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if listed.Status != listError || listed.Error == "" || listed.Package != "ui" {
+		t.Errorf("broken file listed as %+v", listed)
+	}
+}
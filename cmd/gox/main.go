@@ -3,21 +3,23 @@
 package main
 
 import (
-	"bufio"
 	"bytes"
 	"encoding/json"
+	"errors"
 	"flag"
 	"fmt"
+	"io"
 	"os"
 	"os/exec"
 	"path/filepath"
-	"regexp"
-	"strconv"
 	"strings"
 	"sync"
+	"time"
 
+	"github.com/germtb/gox/ast"
 	"github.com/germtb/gox/formatter"
 	"github.com/germtb/gox/generator"
+	"github.com/germtb/gox/internal/atomicfile"
 	"github.com/germtb/gox/lsp"
 	"github.com/germtb/gox/parser"
 )
@@ -41,20 +43,107 @@ func main() {
 	switch cmd {
 	case "generate":
 		if err := runGenerate(os.Args[2:]); err != nil {
-			fmt.Fprintf(os.Stderr, "gox: %v\n", err)
-			os.Exit(1)
+			fail(err)
+		}
+		return
+	case "watch":
+		if err := runGenerate(append([]string{"-watch"}, os.Args[2:]...)); err != nil {
+			fail(err)
+		}
+		return
+	case "init":
+		if err := runInit(os.Args[2:]); err != nil {
+			fail(err)
+		}
+		return
+	case "check":
+		if err := runCheck(os.Args[2:]); err != nil {
+			fail(err)
 		}
 		return
 	case "fmt":
 		if err := runFormat(os.Args[2:]); err != nil {
-			fmt.Fprintf(os.Stderr, "gox: %v\n", err)
-			os.Exit(1)
+			fail(err)
+		}
+		return
+	case "rewrite":
+		if err := runRewrite(os.Args[2:]); err != nil {
+			fail(err)
+		}
+		return
+	case "fix":
+		if err := runFix(os.Args[2:]); err != nil {
+			fail(err)
+		}
+		return
+	case "migrate":
+		if err := runMigrate(os.Args[2:]); err != nil {
+			fail(err)
+		}
+		return
+	case "map":
+		if err := runMap(os.Args[2:]); err != nil {
+			fail(err)
+		}
+		return
+	case "doctor":
+		if err := runDoctor(os.Args[2:]); err != nil {
+			fail(err)
+		}
+		return
+	case "parse":
+		if err := runParse(os.Args[2:]); err != nil {
+			fail(err)
+		}
+		return
+	case "list":
+		if err := runList(os.Args[2:]); err != nil {
+			fail(err)
+		}
+		return
+	case "graph":
+		if err := runGraph(os.Args[2:]); err != nil {
+			fail(err)
+		}
+		return
+	case "explain":
+		if err := runExplain(os.Args[2:]); err != nil {
+			fail(err)
+		}
+		return
+	case "daemon":
+		if err := runDaemon(os.Args[2:]); err != nil {
+			fail(err)
+		}
+		return
+	case "hook":
+		if err := runHook(os.Args[2:]); err != nil {
+			fail(err)
+		}
+		return
+	case "profile":
+		if err := runProfile(os.Args[2:]); err != nil {
+			fail(err)
+		}
+		return
+	case "gen-stubs":
+		if err := runGenStubs(os.Args[2:]); err != nil {
+			fail(err)
+		}
+		return
+	case "env-overlay":
+		if err := runEnvOverlay(os.Args[2:]); err != nil {
+			fail(err)
+		}
+		return
+	case "completion":
+		if err := runCompletion(os.Args[2:]); err != nil {
+			fail(err)
 		}
 		return
 	case "lsp":
-		if err := runLSP(); err != nil {
-			fmt.Fprintf(os.Stderr, "gox: %v\n", err)
-			os.Exit(1)
+		if err := runLSP(os.Args[2:]); err != nil {
+			fail(err)
 		}
 		return
 	case "version":
@@ -71,9 +160,21 @@ func main() {
 
 	// All other commands are proxied to `go` with overlay
 	if err := runGoCommand(cmd, os.Args[2:]); err != nil {
+		fail(err)
+	}
+}
+
+// fail prints err, unless it only reports that diagnostics were printed as
+// JSON, and exits. An exitStatus sets the status and is not printed.
+func fail(err error) {
+	var status exitStatus
+	if errors.As(err, &status) {
+		os.Exit(int(status))
+	}
+	if !errors.Is(err, errDiagnosticsReported) {
 		fmt.Fprintf(os.Stderr, "gox: %v\n", err)
-		os.Exit(1)
 	}
+	os.Exit(1)
 }
 
 func printUsage() {
@@ -87,34 +188,196 @@ Go Commands (proxied with overlay):
   gox run ./...                   Run with automatic .gox compilation
   gox build -o app ./...          Build with automatic .gox compilation
   gox test ./...                  Test with automatic .gox compilation
-  gox vet ./...                   Vet with automatic .gox compilation
+  gox vet ./...                   Vet with automatic .gox compilation and gox analyzers
   (any go command works)
 
 Gox Commands:
+  init [dir]         Create a minimal gox project
   generate [path]    Generate .go files from .gox files
+  watch [path]       Regenerate .gox files as they change (generate -watch)
+  check [path]       Parse, generate and type-check .gox files
   fmt [path]         Format .gox files
+  rewrite <rules>    Rename tags and attributes across .gox files
+  fix [path]         Update .gox files to the current gox runtime and syntax
+  migrate [path]     Convert gox.Element call trees in .go files to .gox files
+  doctor [path]      Diagnose generated files edited by hand or by older gox versions
+  parse <file>       Print the syntax tree of .gox files, as an outline or JSON
+  list [path]        List .gox files with their package, components and generated file
+  graph [path]       Print which components render which, as DOT or JSON
+  map <file:line>    Translate a position between a .gox file and its generated code
+  explain [code]     Explain a diagnostic code such as GOX0005
+  daemon             Keep generated code warm for faster run/build/test
+  gen-stubs [path]   Write generate.go files so go generate ./... regenerates .gox files
+  env-overlay        Print a GOFLAGS setting that lets plain go and gopls see .gox files
+  hook install       Install a git pre-commit hook that checks staged .gox files
+  profile render     Profile rendering a component and write a flame graph
+  completion <shell> Print a completion script for bash, zsh or fish
   lsp                Start LSP server (for IDE integration)
   version            Print version information
   help               Show this help message
 
 Examples:
   gox run ./demo/                      Run a gox project
+  gox run . -- -v                      Run a gox project, passing -v to the program
   gox test ./...                       Test all packages
   gox build -o myapp ./cmd/myapp/      Build a gox project
-  gox vet ./...                        Run go vet on gox code
+  gox vet ./...                        Run go vet and the gox analyzers on gox code
+  gox profile render -component App ./cmd/app
+                                       Profile rendering App and write render.pprof and render.folded
 
 Format Examples:
   gox fmt .                            Format all .gox files in current directory
   gox fmt ./ui/...                     Format .gox files recursively in ui/
+  gox fmt './ui/**/*_card.gox'         Format the .gox files matching a glob
   gox fmt -w .                         Format and write changes to files
+  gox fmt -stdin < app.gox             Format stdin and write the result to stdout
+  gox fmt -json ./...                  Print syntax errors as JSON lines
+  gox fmt -l -staged                   List staged .gox files whose staged content is not formatted
+  gox fmt -w -n ./...                  Print the files -w would overwrite, without writing them
+  gox fmt -w -r 'box -> stack' ./...   Rename <box> to <stack> and format
+  gox fmt -l -parallel 16 ./...        Check formatting on 16 workers; output stays in file order
+
+Rewrite Examples:
+  gox rewrite 'box -> stack' ./...     Show a diff renaming <box> to <stack>
+  gox rewrite -w 'box.gap -> box.spacing; *.class -> *.className'
+
+Fix Examples:
+  gox fix ./...                        Show a diff of the fixes for older gox code, and which apply
+  gox fix -w -r textf ./...            Apply only the textf fix
+  gox fix -list                        Print the available fixes
+
+Migrate Examples:
+  gox migrate ./ui/...                 Show the .gox files the .go files of ui/ would become
+  gox migrate -w ./ui/...              Write them, removing the converted .go files
+
+Doctor Examples:
+  gox doctor ./...                     Report generated files that are missing, stale, edited by
+                                       hand or generated by another gox version
+
+List Examples:
+  gox list ./ui/...                    Show the package, components and generated file of each .gox file
+  gox list -json ./... | jq -r 'select(.status != "current") | .file'
+                                       Print the .gox files whose generated file is missing or stale
+
+Parse Examples:
+  gox parse ui/button.gox              Print an outline of the syntax tree, with ranges
+  gox parse -json ui/button.gox | jq '.nodes[] | select(.type == "JSXElement") | .tag'
+                                       Print the tags of the top-level elements
+
+Graph Examples:
+  gox graph ./... | dot -Tsvg > components.svg
+                                       Draw the component graph with Graphviz
+  gox graph -unused ./...              List the components no .gox file renders
+
+Map Examples:
+  gox map ui/button_gox.go:42:5        Print the .gox position of a generated position
+  gox map ui/button.gox:15             Print the generated line for a .gox line
+
+Gen-stubs Examples:
+  gox gen-stubs ./...                  Add generate.go to each package with .gox files
+  gox gen-stubs -go-run ./...          The same, for consumers without gox installed
+
+Env-overlay Examples:
+  eval "$(gox env-overlay)"            Let go build, go test and gopls in this shell see .gox files
+  gox env-overlay -shell fish | source
+  eval "$(gox env-overlay -unset)"     Go back to plain GOFLAGS
+
+Completion Examples:
+  source <(gox completion bash)        Enable completion in the current bash
+  gox completion zsh > "${fpath[1]}/_gox"
+  gox completion fish > ~/.config/fish/completions/gox.fish
 
 Generate Options:
   -o <dir>           Output directory (default: same as input)
-  -runtime <pkg>     Runtime package path (default: github.com/germtb/gox)
+  -runtime <pkg>     Runtime package path for files without a .goxruntime file
+                     or //gox:runtime directive (default: github.com/germtb/gox)
   -parallel <n>      Number of parallel workers (default: 4)
   -overlay           Output overlay JSON instead of writing files
+  -watch             Keep running and regenerate files as they change
+  -interval <dur>    Polling interval for -watch (default: 500ms)
+  -check             List generated files that are missing or stale and fail if any are (for CI)
+  -json              Print diagnostics to stderr as JSON lines (-gox-json for build, test, ...)
+  -stdout            Print the generated code of a single file to stdout instead of writing files
+  -map-stdout        Print the source map of a single file to stdout instead of writing files
+  -stdin             Generate a single document read from stdin, printing the code (or with
+                     -map-stdout the source map) to stdout
+  -filename <name>   Name of the -stdin document, for diagnostics, build constraints and
+                     .goxruntime lookup (default: <standard input>)
+  -n, -dry-run       Print the files that would be created, overwritten or rewritten, without writing them
+  -line-directives   Interleave //line directives, so go build, vet, coverage and debuggers
+                     report .gox lines without source maps
+  -force             Overwrite generated files that were edited by hand since gox generated them
+  -update-gitignore  Add the patterns of generated files and source maps to the repository
+                     .gitignore, creating it if needed
+  -v                 Print each file with its progress (N/M) and time as it finishes, and a
+                     summary of the files generated, already up to date and failed
+  -q                 Print only errors
+  -e                 Print every error; by default errors past the first 10 are only counted
+
+Fix Options:
+  -w                 Write the fixed files instead of printing a diff
+  -l                 List the files that would change
+  -r <fixes>         Comma-separated fixes to apply (default: all)
+  -list              Print the available fixes
+  -runtime <pkg>     Runtime package path, as for generate
+
+Doctor Options:
+  -o <dir>           Output directory the files are generated to (default: same as input)
+  -runtime <pkg>     Runtime package path, as for generate
+
+List Options:
+  -json              Print one JSON object per file
+  -o <dir>           Output directory the files are generated to (default: same as input)
+  -runtime <pkg>     Runtime package path, as for generate
+
+Graph Options:
+  -json              Print the graph as JSON instead of DOT
+  -unused            List the components no .gox file renders
+  -runtime <pkg>     Runtime package path, as for generate
+
+Daemon Options:
+  -stop              Stop the daemon running for the current directory
+  -status            Print the cached files, hit rate and memory use of the running daemon
+  -flush             Drop every cached file from the running daemon
+  -json              Print -status as JSON
+  -socket <path>     Socket path (default: derived from the current directory)
+  -v                 Log each request
+
+Gen-stubs Options:
+  -go-run            Run gox with go run at this gox version, so go generate does not
+                     need gox installed
+  -n                 Print the files that would be written, without writing them
+
+Env-overlay Options:
+  -o <file>          Overlay JSON path (default: overlay.json in the module's gox cache directory)
+  -shell <name>      Syntax of the printed command: sh (default), fish or powershell
+  -unset             Print a command removing the overlay from GOFLAGS, and remove the generated files
   -v                 Verbose output
 
+Init Options:
+  -module <path>     Module path for a new go.mod (default: directory name)
+
+Check Options:
+  -watch             Re-check whenever a .gox or .go file changes
+  -interval <dur>    Polling interval for -watch (default: 500ms)
+  -no-color          Disable colored output (also honors NO_COLOR)
+  -staged            Check the packages of staged .gox files (- reads the paths from stdin)
+
+Hook Options:
+  -f                 Replace an existing pre-commit hook not written by gox
+
+Profile Options:
+  -component <name>  Component to render, by name or as a JSX element (required)
+  -mem               Profile allocations instead of CPU time
+  -benchtime <t>     How long to run the render loop, as for go test (default: 2s)
+  -o <prefix>        Prefix of the .pprof and .folded files written (default: render)
+
+LSP Options:
+  -gopls <path>      gopls binary to run (default: gopls from PATH or a common install location)
+  -gopls-args <args> Space-separated flags for gopls, before its serve command
+  -rpc.trace         Pass -rpc.trace to gopls, logging the LSP traffic to the gox-lsp log
+  -remote <addr>     Pass -remote to gopls, e.g. auto to share a gopls daemon between editors
+
 Use "gox help" for more information.`)
 }
 
@@ -123,12 +386,28 @@ type generateConfig struct {
 	runtimePkg       string
 	parallel         int
 	verbose          bool
-	overlay          bool   // Output overlay JSON instead of files
-	overlayFile      string // Output overlay JSON to this file (default: stdout)
-	tempDir          string // Temp directory for overlay files (if empty, one is created)
+	overlay          bool          // Output overlay JSON instead of files
+	overlayFile      string        // Output overlay JSON to this file (default: stdout)
+	tempDir          string        // Temp directory for overlay files (if empty, one is created)
+	cache            *overlayCache // Reuses overlay files generated by earlier invocations, when set
 	paths            []string
 	inMemoryMaps     bool                            // Store source maps in memory instead of writing to disk
 	sourceMapsOutput map[string]*generator.SourceMap // Populated when inMemoryMaps is true
+	watch            bool                            // Keep running and regenerate changed files
+	watchInterval    time.Duration                   // Polling interval for watch mode
+	check            bool                            // Report out-of-date generated files instead of writing them
+	daemon           *daemonClient                   // Generates files when a daemon is running
+	json             bool                            // Print diagnostics as JSON lines
+	stdout           bool                            // Print the generated code of a single file to stdout
+	mapStdout        bool                            // Print the source map of a single file to stdout
+	dryRun           bool                            // Report the files that would be written instead of writing them
+	lineDirectives   bool                            // Interleave //line directives naming the .gox files
+	stdin            bool                            // Generate a single document from stdin to stdout
+	filename         string                          // Name of the document read with -stdin
+	force            bool                            // Overwrite generated files edited by hand
+	updateGitignore  bool                            // Add generated-file patterns to the repository .gitignore
+	quiet            bool                            // Print only errors
+	allErrors        bool                            // Print every error instead of the first maxReportedErrors
 }
 
 func runGenerate(args []string) error {
@@ -140,19 +419,82 @@ func runGenerate(args []string) error {
 	fs.StringVar(&cfg.outputDir, "o", "", "output directory")
 	fs.StringVar(&cfg.runtimePkg, "runtime", "", "runtime package path")
 	fs.IntVar(&cfg.parallel, "parallel", 4, "number of parallel workers")
-	fs.BoolVar(&cfg.verbose, "v", false, "verbose output")
+	fs.BoolVar(&cfg.verbose, "v", false, "verbose output: progress and timing of each file, and a summary")
+	fs.BoolVar(&cfg.quiet, "q", false, "print only errors")
+	fs.BoolVar(&cfg.allErrors, "e", false, "print every error, not only the first 10")
 	fs.BoolVar(&cfg.overlay, "overlay", false, "output go build overlay JSON (no files written to source dir)")
 	fs.StringVar(&cfg.overlayFile, "overlay-file", "", "write overlay JSON to file (default: stdout)")
+	fs.BoolVar(&cfg.watch, "watch", false, "watch for changes and regenerate changed files")
+	fs.DurationVar(&cfg.watchInterval, "interval", 500*time.Millisecond, "polling interval for -watch")
+	fs.BoolVar(&cfg.check, "check", false, "report generated files that are missing or out of date instead of writing them")
+	fs.BoolVar(&cfg.json, "json", false, "print diagnostics as JSON lines")
+	fs.BoolVar(&cfg.stdout, "stdout", false, "print the generated code of a single file to stdout instead of writing files")
+	fs.BoolVar(&cfg.mapStdout, "map-stdout", false, "print the source map of a single file to stdout instead of writing files")
+	fs.BoolVar(&cfg.dryRun, "n", false, "print the files that would be created or overwritten without writing them")
+	fs.BoolVar(&cfg.dryRun, "dry-run", false, "same as -n")
+	fs.BoolVar(&cfg.lineDirectives, "line-directives", false, "interleave //line directives so tools report .gox positions without source maps")
+	fs.BoolVar(&cfg.stdin, "stdin", false, "generate a single document from stdin and write the generated code to stdout")
+	fs.StringVar(&cfg.filename, "filename", "<standard input>", "name of the document read with -stdin, for diagnostics, build constraints and the runtime package")
+	fs.BoolVar(&cfg.force, "force", false, "overwrite generated files that were edited by hand")
+	fs.BoolVar(&cfg.updateGitignore, "update-gitignore", false, "add the patterns of generated files to the repository .gitignore")
 
 	if err := fs.Parse(args); err != nil {
 		return err
 	}
 
+	if cfg.stdin {
+		if fs.NArg() > 0 {
+			return fmt.Errorf("cannot use -stdin with file paths")
+		}
+		if cfg.check || cfg.watch || cfg.overlay || cfg.dryRun {
+			return fmt.Errorf("-stdin cannot be combined with -check, -watch, -overlay or -n")
+		}
+		src, err := io.ReadAll(os.Stdin)
+		if err != nil {
+			return fmt.Errorf("reading stdin: %w", err)
+		}
+		// The generated code, unless -map-stdout asks for the source map
+		cfg.stdout = !cfg.mapStdout
+		err = generateStdout(cfg.filename, src, cfg, os.Stdout)
+		if err != nil && cfg.json {
+			writeDiagnostics(os.Stderr, errorDiagnostic(cfg.filename, err))
+			return errDiagnosticsReported
+		}
+		return err
+	}
+
 	cfg.paths = fs.Args()
 	if len(cfg.paths) == 0 {
 		cfg.paths = []string{"."}
 	}
 
+	if cfg.quiet && cfg.verbose {
+		return fmt.Errorf("-q and -v cannot be combined")
+	}
+	if cfg.check && (cfg.watch || cfg.overlay) {
+		return fmt.Errorf("-check cannot be combined with -watch or -overlay")
+	}
+	if cfg.stdout || cfg.mapStdout {
+		if cfg.stdout && cfg.mapStdout {
+			return fmt.Errorf("-stdout and -map-stdout cannot be combined; run gox generate once for each")
+		}
+		if cfg.check || cfg.watch || cfg.overlay {
+			return fmt.Errorf("-stdout and -map-stdout cannot be combined with -check, -watch or -overlay")
+		}
+	}
+
+	if cfg.dryRun {
+		if cfg.check || cfg.watch || cfg.overlay || cfg.stdout || cfg.mapStdout {
+			return fmt.Errorf("-n cannot be combined with -check, -watch, -overlay, -stdout or -map-stdout")
+		}
+		// Report files in order
+		cfg.parallel = 1
+	}
+
+	if cfg.watch {
+		return watchGenerate(cfg)
+	}
+
 	// Find all .gox files
 	files, err := findGoxFiles(cfg.paths)
 	if err != nil {
@@ -160,17 +502,44 @@ func runGenerate(args []string) error {
 	}
 
 	if len(files) == 0 {
-		fmt.Println("No .gox files found")
+		if !cfg.json && !cfg.quiet {
+			fmt.Println("No .gox files found")
+		}
 		return nil
 	}
 
-	if cfg.verbose {
-		fmt.Printf("Found %d .gox file(s)\n", len(files))
+	if cfg.updateGitignore {
+		if err := updateGitignore(files, cfg); err != nil {
+			return err
+		}
 	}
 
 	// Process files
+	if cfg.stdout || cfg.mapStdout {
+		if len(files) != 1 {
+			return fmt.Errorf("-stdout and -map-stdout need a single .gox file, found %d", len(files))
+		}
+		src, err := os.ReadFile(files[0])
+		if err != nil {
+			return fmt.Errorf("reading file: %w", err)
+		}
+		err = generateStdout(files[0], src, cfg, os.Stdout)
+		if err != nil && cfg.json {
+			writeDiagnostics(os.Stderr, errorDiagnostic(files[0], err))
+			return errDiagnosticsReported
+		}
+		return err
+	}
+	if cfg.check {
+		return checkFiles(files, cfg, os.Stdout)
+	}
 	if cfg.overlay {
-		return processFilesOverlay(files, cfg)
+		err := processFilesOverlay(files, cfg)
+		if err != nil && cfg.json {
+			writeDiagnostics(os.Stderr, errorDiagnostic("", err))
+			return errDiagnosticsReported
+		}
+		return err
 	}
 	return processFiles(files, cfg)
 }
@@ -191,9 +560,51 @@ func skipDir(name string) bool {
 
 // findGoxFiles finds all .gox files in the given paths.
 func findGoxFiles(paths []string) ([]string, error) {
+	files, err := findFiles(paths, isGoxFile)
+	if err != nil {
+		return nil, err
+	}
+	if err := checkOutputLayouts(files); err != nil {
+		return nil, err
+	}
+	return files, nil
+}
+
+// isGoxFile reports whether name is a .gox source file.
+func isGoxFile(name string) bool {
+	return strings.HasSuffix(name, ".gox")
+}
+
+// findFiles finds all files accepted by match in the given paths. A path is a
+// file, a directory (searched non-recursively), a recursive dir/... pattern
+// or a glob such as ui/**/*.gox. Brace alternatives, as in {ui,pages}/...,
+// are expanded first. Files and directories excluded by a .goxignore file are
+// skipped, unless they are named explicitly. match is given the path of each
+// candidate file.
+func findFiles(paths []string, match func(name string) bool) ([]string, error) {
 	var files []string
+	ignore := newIgnoreFiles()
 
+	var expanded []string
 	for _, path := range paths {
+		expanded = append(expanded, expandBraces(path)...)
+	}
+	seen := make(map[string]bool)
+	for _, path := range expanded {
+		if isGlob(path) {
+			matches, err := globFiles(path, match, ignore)
+			if err != nil {
+				return nil, err
+			}
+			for _, m := range matches {
+				if !seen[m] {
+					seen[m] = true
+					files = append(files, m)
+				}
+			}
+			continue
+		}
+
 		// Handle recursive pattern ./...
 		if strings.HasSuffix(path, "/...") {
 			dir := strings.TrimSuffix(path, "/...")
@@ -204,10 +615,19 @@ func findGoxFiles(paths []string) ([]string, error) {
 				if err != nil {
 					return err
 				}
-				if info.IsDir() && p != dir && skipDir(info.Name()) {
+				if info.IsDir() && p == dir || !info.IsDir() && !match(p) {
+					return nil
+				}
+				if info.IsDir() && (skipDir(info.Name()) || isModuleRoot(p)) {
 					return filepath.SkipDir
 				}
-				if !info.IsDir() && strings.HasSuffix(p, ".gox") {
+				if ignored, err := ignore.ignored(p, info.IsDir()); err != nil || ignored {
+					if err == nil && info.IsDir() {
+						err = filepath.SkipDir
+					}
+					return err
+				}
+				if !info.IsDir() {
 					files = append(files, p)
 				}
 				return nil
@@ -225,17 +645,25 @@ func findGoxFiles(paths []string) ([]string, error) {
 		}
 
 		if info.IsDir() {
-			// Find all .gox files in directory (non-recursive)
+			// Find matching files in directory (non-recursive)
 			entries, err := os.ReadDir(path)
 			if err != nil {
 				return nil, fmt.Errorf("reading directory %s: %w", path, err)
 			}
 			for _, entry := range entries {
-				if !entry.IsDir() && strings.HasSuffix(entry.Name(), ".gox") {
-					files = append(files, filepath.Join(path, entry.Name()))
+				file := filepath.Join(path, entry.Name())
+				if entry.IsDir() || !match(file) {
+					continue
+				}
+				ignored, err := ignore.ignored(file, false)
+				if err != nil {
+					return nil, err
+				}
+				if !ignored {
+					files = append(files, file)
 				}
 			}
-		} else if strings.HasSuffix(path, ".gox") {
+		} else if match(path) {
 			files = append(files, path)
 		}
 	}
@@ -246,122 +674,364 @@ func findGoxFiles(paths []string) ([]string, error) {
 // processFiles generates Go code for all input files.
 func processFiles(files []string, cfg *generateConfig) error {
 	var wg sync.WaitGroup
-	errChan := make(chan error, len(files))
+	errChan := make(chan fileError, len(files))
 	semaphore := make(chan struct{}, cfg.parallel)
+	progress := newGenerateProgress(os.Stdout, len(files))
 
 	for _, file := range files {
 		wg.Add(1)
+		semaphore <- struct{}{} // Acquire, in order, so -parallel 1 is sequential
 		go func(f string) {
 			defer wg.Done()
-			semaphore <- struct{}{}        // Acquire
 			defer func() { <-semaphore }() // Release
 
-			if err := processFile(f, cfg); err != nil {
-				errChan <- fmt.Errorf("%s: %w", f, err)
+			start := time.Now()
+			changed, err := processFile(f, cfg)
+			if err != nil {
+				errChan <- fileError{f, err}
 			}
+			progress.fileDone(f, getOutputPath(f, cfg.outputDir), changed, err, time.Since(start), cfg.verbose)
 		}(file)
 	}
 
 	wg.Wait()
 	close(errChan)
+	if cfg.verbose {
+		progress.summary()
+	}
 
 	// Collect errors
-	var errs []error
+	var errs []fileError
 	for err := range errChan {
 		errs = append(errs, err)
 	}
 
 	if len(errs) > 0 {
-		for _, err := range errs {
-			fmt.Fprintf(os.Stderr, "error: %v\n", err)
+		sortFileErrors(errs)
+		if cfg.json {
+			for _, e := range errs {
+				writeDiagnostics(os.Stderr, errorDiagnostic(e.file, e.err))
+			}
+			return errDiagnosticsReported
 		}
+		limit := maxReportedErrors
+		if cfg.allErrors {
+			limit = 0
+		}
+		writeFileErrors(os.Stderr, errs, limit)
 		return fmt.Errorf("%d file(s) failed", len(errs))
 	}
 
 	return nil
 }
 
-// processFile generates Go code for a single .gox file.
-func processFile(inputPath string, cfg *generateConfig) error {
-	if cfg.verbose {
-		fmt.Printf("Processing %s\n", inputPath)
+// processFile generates Go code for a single .gox file. It reports whether
+// the generated file or its source map changed: files that are already up
+// to date are left alone, so their modification times do not change.
+func processFile(inputPath string, cfg *generateConfig) (bool, error) {
+	output, sourceMap, err := generateFile(inputPath, cfg)
+	if err != nil {
+		return false, err
 	}
 
+	// Determine output path
+	outputPath := getOutputPath(inputPath, cfg.outputDir)
+	if err := cfg.checkOverwrite(outputPath, inputPath); err != nil {
+		return false, err
+	}
+
+	// Set source map file paths
+	absInputPath, _ := filepath.Abs(inputPath)
+	absOutputPath, _ := filepath.Abs(outputPath)
+	sourceMap.SetFiles(absInputPath, absOutputPath)
+
+	sourceMapPath := outputPath + ".map"
+	sourceMapData, err := sourceMap.ToJSON()
+	if err != nil {
+		return false, fmt.Errorf("serializing source map: %w", err)
+	}
+	changed := !hasContent(outputPath, output) || !hasContent(sourceMapPath, sourceMapData)
+	if !changed && !cfg.dryRun {
+		return false, nil
+	}
+
+	// Write output file
+	if err := cfg.writeOutput(outputPath, output); err != nil {
+		return false, fmt.Errorf("writing file: %w", err)
+	}
+
+	// Write source map file
+	if err := cfg.writeOutput(sourceMapPath, sourceMapData); err != nil {
+		return false, fmt.Errorf("writing source map: %w", err)
+	}
+	return changed, nil
+}
+
+// writeOutput writes a generated file, or with -n prints what writing it
+// would do.
+func (cfg *generateConfig) writeOutput(path string, data []byte) error {
+	if cfg.dryRun {
+		fmt.Printf("%s %s\n", dryRunAction(path, data), path)
+		return nil
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return err
+	}
+	return atomicfile.WriteFile(path, data, 0644)
+}
+
+// checkOverwrite refuses to overwrite a generated file that was edited by
+// hand since gox generated it, unless -force is set, so the edits are not
+// silently lost.
+func (cfg *generateConfig) checkOverwrite(outputPath, inputPath string) error {
+	existing, err := os.ReadFile(outputPath)
+	if err != nil {
+		return nil
+	}
+	prov, ok := generator.ReadProvenance(existing)
+	switch {
+	case !ok:
+		// Generated by a gox version without provenance headers
+		return nil
+	case prov.Edited(existing) && !cfg.force:
+		return fmt.Errorf("%s was edited by hand since gox generated it; move the edits into %s, or use -force to overwrite them", outputPath, inputPath)
+	case prov.Version != version && cfg.verbose:
+		fmt.Printf("  replacing the output of gox %s\n", prov.Version)
+	}
+	return nil
+}
+
+// hasContent reports whether the file at path holds exactly data.
+func hasContent(path string, data []byte) bool {
+	existing, err := os.ReadFile(path)
+	return err == nil && bytes.Equal(existing, data)
+}
+
+// dryRunAction describes what writing data to path would do: "create" a new
+// file, "overwrite" a different one, or "rewrite" it with identical content.
+func dryRunAction(path string, data []byte) string {
+	existing, err := os.ReadFile(path)
+	switch {
+	case err != nil:
+		return "create"
+	case bytes.Equal(existing, data):
+		return "rewrite"
+	default:
+		return "overwrite"
+	}
+}
+
+// generateFile parses and generates a single .gox file.
+func generateFile(inputPath string, cfg *generateConfig) ([]byte, *generator.SourceMap, error) {
 	// Read input file
 	src, err := os.ReadFile(inputPath)
 	if err != nil {
-		return fmt.Errorf("reading file: %w", err)
+		return nil, nil, fmt.Errorf("reading file: %w", err)
 	}
+	return generateSource(inputPath, src, cfg)
+}
 
+// generateSource generates the source of the .gox file at inputPath, which
+// need not exist on disk.
+func generateSource(inputPath string, src []byte, cfg *generateConfig) ([]byte, *generator.SourceMap, error) {
 	// Parse
 	file, err := parser.Parse(inputPath, src)
 	if err != nil {
-		return fmt.Errorf("parsing: %w", err)
+		return nil, nil, fmt.Errorf("parsing: %w", err)
 	}
 
 	// Generate
-	opts := &generator.Options{}
-	if cfg.runtimePkg != "" {
-		opts.RuntimePackage = cfg.runtimePkg
+	runtimePkg, err := cfg.runtimeFor(inputPath)
+	if err != nil {
+		return nil, nil, err
+	}
+	lineDirectives, err := cfg.lineDirectivesFor(inputPath)
+	if err != nil {
+		return nil, nil, err
+	}
+	opts, err := generatorOptions(inputPath, runtimePkg, lineDirectives)
+	if err != nil {
+		return nil, nil, err
 	}
-
 	output, sourceMap, err := generator.Generate(file, opts)
 	if err != nil {
-		return fmt.Errorf("generating: %w", err)
+		return nil, nil, fmt.Errorf("generating: %w", err)
 	}
+	return output, sourceMap, nil
+}
 
-	// Determine output path
-	outputPath := getOutputPath(inputPath, cfg.outputDir)
+// generatorOptions returns the options the .gox file at inputPath is
+// generated with, in process or by the daemon: runtimePkg, the schema of its
+// directory, the gox version for the provenance header and, if not empty,
+// the name //line directives give the file.
+func generatorOptions(inputPath, runtimePkg, lineDirectives string) (*generator.Options, error) {
+	opts := &generator.Options{RuntimePackage: runtimePkg, Version: version, LineDirectives: lineDirectives}
+	var err error
+	if opts.Schema, err = generator.LookupSchema(filepath.Dir(inputPath)); err != nil {
+		return nil, err
+	}
+	return opts, nil
+}
 
-	// Set source map file paths
-	absInputPath, _ := filepath.Abs(inputPath)
-	absOutputPath, _ := filepath.Abs(outputPath)
-	sourceMap.SetFiles(absInputPath, absOutputPath)
+// lineDirectivesFor returns the name //line directives give the .gox file
+// at inputPath, or "" without -line-directives.
+func (cfg *generateConfig) lineDirectivesFor(inputPath string) (string, error) {
+	if !cfg.lineDirectives {
+		return "", nil
+	}
+	return cfg.lineDirectiveName(inputPath)
+}
 
-	// Write output file
-	if err := os.WriteFile(outputPath, output, 0644); err != nil {
-		return fmt.Errorf("writing file: %w", err)
+// lineDirectiveName returns how //line directives name a .gox file: relative
+// to the directory it is generated to, which the compiler resolves it
+// against, or absolute for overlay files, which are written elsewhere.
+func (cfg *generateConfig) lineDirectiveName(inputPath string) (string, error) {
+	absInput, err := filepath.Abs(inputPath)
+	if err != nil {
+		return "", err
 	}
+	if cfg.overlay {
+		return absInput, nil
+	}
+	absOutput, err := filepath.Abs(getOutputPath(inputPath, cfg.outputDir))
+	if err != nil {
+		return "", err
+	}
+	return filepath.Rel(filepath.Dir(absOutput), absInput)
+}
 
-	// Write source map file
-	sourceMapPath := outputPath + ".map"
+// runtimeFor returns the runtime package for a .gox file from the nearest
+// .goxruntime file, or else -runtime. A //gox:runtime directive in the file
+// itself is applied by the generator.
+func (cfg *generateConfig) runtimeFor(inputPath string) (string, error) {
+	pkg, err := generator.LookupRuntime(filepath.Dir(inputPath))
+	if err != nil || pkg != "" {
+		return pkg, err
+	}
+	return cfg.runtimePkg, nil
+}
+
+// resolvedRuntime returns the runtime package generated code for a .gox file
+// imports: that of its //gox:runtime directive, the nearest .goxruntime file
+// or -runtime, or else the default.
+func (cfg *generateConfig) resolvedRuntime(file *ast.GoxFile) (string, error) {
+	if pkg := generator.FileRuntime(file); pkg != "" {
+		return pkg, nil
+	}
+	pkg, err := cfg.runtimeFor(file.SourcePath)
+	if err != nil || pkg != "" {
+		return pkg, err
+	}
+	return generator.DefaultRuntimePackage, nil
+}
+
+// generate generates a single .gox file, through the daemon if one is
+// connected and the file is in its project. If the daemon stops answering,
+// it is dropped and files are generated in process.
+func (cfg *generateConfig) generate(inputPath string) ([]byte, *generator.SourceMap, error) {
+	if cfg.daemon != nil && cfg.daemon.serves(inputPath) {
+		runtimePkg, err := cfg.runtimeFor(inputPath)
+		if err != nil {
+			return nil, nil, err
+		}
+		lineDirectives, err := cfg.lineDirectivesFor(inputPath)
+		if err != nil {
+			return nil, nil, err
+		}
+		output, sourceMap, err := cfg.daemon.generate(inputPath, runtimePkg, lineDirectives)
+		if !errors.Is(err, errDaemonUnavailable) {
+			return output, sourceMap, err
+		}
+		fmt.Fprintf(os.Stderr, "gox: warning: %v; generating without it\n", err)
+		cfg.daemon.close()
+		cfg.daemon = nil
+	}
+	return generateFile(inputPath, cfg)
+}
+
+// generateStdout writes the generated code of src, the source of inputPath,
+// to out, or with -map-stdout its source map, without writing any files.
+// The source map refers to the output path the code would be written to.
+func generateStdout(inputPath string, src []byte, cfg *generateConfig, out io.Writer) error {
+	output, sourceMap, err := generateSource(inputPath, src, cfg)
+	if err != nil {
+		return err
+	}
+	if cfg.stdout {
+		_, err := out.Write(output)
+		return err
+	}
+
+	absInputPath, _ := filepath.Abs(inputPath)
+	absOutputPath, _ := filepath.Abs(getOutputPath(inputPath, cfg.outputDir))
+	sourceMap.SetFiles(absInputPath, absOutputPath)
 	sourceMapData, err := sourceMap.ToJSON()
 	if err != nil {
 		return fmt.Errorf("serializing source map: %w", err)
 	}
-	if err := os.WriteFile(sourceMapPath, sourceMapData, 0644); err != nil {
-		return fmt.Errorf("writing source map: %w", err)
-	}
+	_, err = out.Write(append(sourceMapData, '\n'))
+	return err
+}
 
-	if cfg.verbose {
-		fmt.Printf("  -> %s\n", outputPath)
-		fmt.Printf("  -> %s\n", sourceMapPath)
+// checkFiles regenerates files in memory and lists, on out, the generated
+// files on disk that are missing or differ from the result. It fails if any
+// are listed or any file fails to generate.
+func checkFiles(files []string, cfg *generateConfig, out io.Writer) error {
+	outdated, failed := 0, 0
+	for _, file := range files {
+		output, _, err := generateFile(file, cfg)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "error: %s: %v\n", file, err)
+			failed++
+			continue
+		}
+
+		outputPath := getOutputPath(file, cfg.outputDir)
+		status, prov, err := outputStatus(outputPath, output)
+		if err != nil {
+			return err
+		}
+		switch {
+		case status == listMissing:
+			fmt.Fprintf(out, "%s: missing (generate from %s)\n", outputPath, file)
+			outdated++
+		case status == listEdited:
+			fmt.Fprintf(out, "%s: edited by hand (move the edits into %s, or regenerate with -force)\n", outputPath, file)
+			outdated++
+		case status == listStale && prov != nil && prov.Version != version:
+			fmt.Fprintf(out, "%s: stale (generated by gox %s; regenerate from %s)\n", outputPath, prov.Version, file)
+			outdated++
+		case status == listStale:
+			fmt.Fprintf(out, "%s: stale (regenerate from %s)\n", outputPath, file)
+			outdated++
+		case cfg.verbose:
+			fmt.Fprintf(out, "%s: up to date\n", outputPath)
+		}
 	}
 
+	if failed > 0 {
+		return fmt.Errorf("%d file(s) failed", failed)
+	}
+	if outdated > 0 {
+		return fmt.Errorf("%d generated file(s) out of date; run \"gox generate\"", outdated)
+	}
 	return nil
 }
 
-// getOutputPath determines the output path for a .gox file.
-// Test files get special handling: foo_test.gox → foo_gox_test.go
-// so that Go's test runner recognizes them.
+// getOutputPath determines the output path for a .gox file, named with the
+// suffix configured for its directory. Test files get special handling:
+// foo_test.gox → foo_gox_test.go so that Go's test runner recognizes them.
+// The file goes to outputDir if set, else to the output tree configured for
+// its directory, which is the directory itself unless a .goxoutdir file
+// applies.
 func getOutputPath(inputPath, outputDir string) string {
-	baseName := strings.TrimSuffix(filepath.Base(inputPath), ".gox")
-
-	var outputName string
-	if strings.HasSuffix(baseName, "_test") {
-		// foo_test.gox → foo_gox_test.go
-		outputName = strings.TrimSuffix(baseName, "_test") + "_gox_test.go"
-	} else {
-		// foo.gox → foo_gox.go
-		outputName = baseName + "_gox.go"
-	}
+	suffix, tree, _ := lookupOutputLayout(filepath.Dir(inputPath))
+	outputName := generator.OutputName(filepath.Base(inputPath), suffix)
 
 	if outputDir != "" {
 		return filepath.Join(outputDir, outputName)
 	}
-
-	// Same directory as input
-	return filepath.Join(filepath.Dir(inputPath), outputName)
+	return filepath.Join(tree, outputName)
 }
 
 // Overlay represents the Go build overlay JSON format.
@@ -379,6 +1049,7 @@ func processFilesOverlay(files []string, cfg *generateConfig) error {
 		if err != nil {
 			return fmt.Errorf("creating temp dir: %w", err)
 		}
+		cfg.tempDir = tempDir
 	}
 
 	if cfg.verbose {
@@ -394,95 +1065,161 @@ func processFilesOverlay(files []string, cfg *generateConfig) error {
 		cfg.sourceMapsOutput = make(map[string]*generator.SourceMap)
 	}
 
-	cwd, _ := os.Getwd()
-
 	// Process each file
 	for _, inputPath := range files {
-		if cfg.verbose {
-			fmt.Fprintf(os.Stderr, "Processing %s\n", inputPath)
-		}
-
-		// Read and parse
-		src, err := os.ReadFile(inputPath)
+		targetPath, tempFile, err := generateOverlayFile(inputPath, cfg)
 		if err != nil {
-			return fmt.Errorf("%s: reading file: %w", inputPath, err)
+			return err
 		}
 
-		file, err := parser.Parse(inputPath, src)
-		if err != nil {
-			return fmt.Errorf("%s: parsing: %w", inputPath, err)
-		}
+		// Add to overlay mapping
+		overlay.Replace[targetPath] = tempFile
+	}
 
-		// Generate
-		opts := &generator.Options{}
-		if cfg.runtimePkg != "" {
-			opts.RuntimePackage = cfg.runtimePkg
-		}
+	return writeOverlay(overlay, cfg)
+}
 
-		output, sourceMap, err := generator.Generate(file, opts)
-		if err != nil {
-			return fmt.Errorf("%s: generating: %w", inputPath, err)
-		}
+// generateOverlayFile generates a single .gox file into cfg.tempDir and returns
+// the path the generated file stands in for and the temp file holding it.
+func generateOverlayFile(inputPath string, cfg *generateConfig) (targetPath, tempFile string, err error) {
+	if cfg.verbose {
+		fmt.Fprintf(os.Stderr, "Processing %s\n", inputPath)
+	}
 
-		// Write to temp file
-		absInput, err := filepath.Abs(inputPath)
+	absInput, err := filepath.Abs(inputPath)
+	if err != nil {
+		return "", "", fmt.Errorf("%s: getting absolute path: %w", inputPath, err)
+	}
+
+	// Target path (where the file would normally go)
+	targetPath = getOutputPath(absInput, "")
+
+	if cfg.cache != nil {
+		tempFile, sourceMap, err := cachedOverlayFile(inputPath, absInput, cfg)
 		if err != nil {
-			return fmt.Errorf("%s: getting absolute path: %w", inputPath, err)
+			return "", "", err
+		}
+		// The source map is on disk next to the cached file already
+		if cfg.inMemoryMaps {
+			cfg.sourceMapsOutput[targetPath] = sourceMap
+			cfg.sourceMapsOutput[tempFile] = sourceMap
 		}
+		if cfg.verbose {
+			fmt.Fprintf(os.Stderr, "  %s -> %s\n", targetPath, tempFile)
+		}
+		return targetPath, tempFile, nil
+	}
+
+	output, sourceMap, err := cfg.generate(inputPath)
+	if err != nil {
+		return "", "", fmt.Errorf("%s: %w", inputPath, err)
+	}
 
-		// Target path (where the file would normally go)
-		targetPath := getOutputPath(absInput, "")
+	// Set source map file paths
+	sourceMap.SetFiles(absInput, targetPath)
 
-		// Set source map file paths
-		sourceMap.SetFiles(absInput, targetPath)
+	// Temp file path - preserve directory structure to avoid collisions
+	// when multiple packages have .gox files with the same base name.
+	tempFile = overlayTempPath(cfg.tempDir, targetPath)
+	if err := os.MkdirAll(filepath.Dir(tempFile), 0755); err != nil {
+		return "", "", fmt.Errorf("%s: creating temp subdir: %w", inputPath, err)
+	}
+	if err := os.WriteFile(tempFile, output, 0644); err != nil {
+		return "", "", fmt.Errorf("%s: writing temp file: %w", inputPath, err)
+	}
 
-		// Temp file path - preserve directory structure to avoid collisions
-		// when multiple packages have .gox files with the same base name.
-		relTarget, relErr := filepath.Rel(cwd, targetPath)
-		if relErr != nil {
-			relTarget = filepath.Base(targetPath)
-		}
-		tempFile := filepath.Join(tempDir, relTarget)
-		if err := os.MkdirAll(filepath.Dir(tempFile), 0755); err != nil {
-			return fmt.Errorf("%s: creating temp subdir: %w", inputPath, err)
+	// Handle source maps: either in memory or on disk
+	if cfg.inMemoryMaps {
+		// Store in memory for error remapping
+		cfg.sourceMapsOutput[targetPath] = sourceMap
+		cfg.sourceMapsOutput[tempFile] = sourceMap
+	} else {
+		// Write source map to temp dir
+		sourceMapPath := tempFile + ".map"
+		sourceMapData, err := sourceMap.ToJSON()
+		if err != nil {
+			return "", "", fmt.Errorf("%s: serializing source map: %w", inputPath, err)
 		}
-		if err := os.WriteFile(tempFile, output, 0644); err != nil {
-			return fmt.Errorf("%s: writing temp file: %w", inputPath, err)
+		if err := os.WriteFile(sourceMapPath, sourceMapData, 0644); err != nil {
+			return "", "", fmt.Errorf("%s: writing source map: %w", inputPath, err)
 		}
+	}
 
-		// Handle source maps: either in memory or on disk
-		if cfg.inMemoryMaps {
-			// Store in memory for error remapping
-			cfg.sourceMapsOutput[targetPath] = sourceMap
-			cfg.sourceMapsOutput[tempFile] = sourceMap
-		} else {
-			// Write source map to temp dir
-			sourceMapPath := tempFile + ".map"
-			sourceMapData, err := sourceMap.ToJSON()
-			if err != nil {
-				return fmt.Errorf("%s: serializing source map: %w", inputPath, err)
-			}
-			if err := os.WriteFile(sourceMapPath, sourceMapData, 0644); err != nil {
-				return fmt.Errorf("%s: writing source map: %w", inputPath, err)
-			}
-		}
+	if cfg.verbose {
+		fmt.Fprintf(os.Stderr, "  %s -> %s\n", targetPath, tempFile)
+	}
 
-		// Add to overlay mapping
-		overlay.Replace[targetPath] = tempFile
+	return targetPath, tempFile, nil
+}
 
-		if cfg.verbose {
-			fmt.Fprintf(os.Stderr, "  %s -> %s\n", targetPath, tempFile)
+// cachedOverlayFile returns the generated file for a .gox file from
+// cfg.cache, generating and caching it on a miss, and its source map.
+func cachedOverlayFile(inputPath, absInput string, cfg *generateConfig) (string, *generator.SourceMap, error) {
+	src, err := os.ReadFile(inputPath)
+	if err != nil {
+		return "", nil, fmt.Errorf("%s: reading file: %w", inputPath, err)
+	}
+	runtimePkg, err := cfg.runtimeFor(inputPath)
+	if err != nil {
+		return "", nil, fmt.Errorf("%s: %w", inputPath, err)
+	}
+	key := cfg.cache.key(absInput, runtimePkg, src)
+	if file, sourceMap, ok := cfg.cache.get(key); ok {
+		return file, sourceMap, nil
+	}
+
+	output, sourceMap, err := cfg.generate(inputPath)
+	if err != nil {
+		return "", nil, fmt.Errorf("%s: %w", inputPath, err)
+	}
+	sourceMap.SetFiles(absInput, getOutputPath(absInput, ""))
+
+	// The file may have changed since it was read; cache only what src
+	// generates, and use the output either way
+	if current, err := os.ReadFile(inputPath); err == nil && bytes.Equal(current, src) {
+		if file, err := cfg.cache.put(key, output, sourceMap); err == nil {
+			return file, sourceMap, nil
 		}
 	}
+	file := overlayTempPath(cfg.tempDir, getOutputPath(absInput, ""))
+	if err := os.MkdirAll(filepath.Dir(file), 0755); err != nil {
+		return "", nil, fmt.Errorf("%s: creating temp subdir: %w", inputPath, err)
+	}
+	if err := os.WriteFile(file, output, 0644); err != nil {
+		return "", nil, fmt.Errorf("%s: writing temp file: %w", inputPath, err)
+	}
+	return file, sourceMap, nil
+}
 
-	// Output overlay JSON
+// overlayTempPath returns where the generated file for the absolute path
+// target is written in tempDir. Targets under the working directory keep
+// their relative path; others, including those on another Windows drive,
+// mirror their absolute path under "abs", with the volume name as a directory.
+func overlayTempPath(tempDir, target string) string {
+	cwd, _ := os.Getwd()
+	rel, err := filepath.Rel(cwd, target)
+	if err == nil && rel != ".." && !strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+		return filepath.Join(tempDir, rel)
+	}
+	volume := filepath.VolumeName(target)
+	volumeDir := strings.Map(func(r rune) rune {
+		if r == ':' || r == '\\' || r == '/' {
+			return '_'
+		}
+		return r
+	}, strings.Trim(volume, `\/`))
+	return filepath.Join(tempDir, "abs", volumeDir, target[len(volume):])
+}
+
+// writeOverlay writes the overlay JSON to cfg.overlayFile, or stdout if unset.
+func writeOverlay(overlay Overlay, cfg *generateConfig) error {
 	jsonBytes, err := json.MarshalIndent(overlay, "", "  ")
 	if err != nil {
 		return fmt.Errorf("marshaling overlay: %w", err)
 	}
 
 	if cfg.overlayFile != "" {
-		if err := os.WriteFile(cfg.overlayFile, jsonBytes, 0644); err != nil {
+		if err := atomicfile.WriteFile(cfg.overlayFile, jsonBytes, 0644); err != nil {
 			return fmt.Errorf("writing overlay file: %w", err)
 		}
 		if cfg.verbose {
@@ -495,9 +1232,19 @@ func processFilesOverlay(files []string, cfg *generateConfig) error {
 	return nil
 }
 
-// isPath checks if an argument looks like a file or directory path
+// isPath checks if an argument looks like a file or directory path. Windows
+// paths, such as `.\ui` and `C:\src\app`, are recognized on every platform,
+// since import paths never contain backslashes or drive letters.
 func isPath(arg string) bool {
-	if strings.HasPrefix(arg, "./") || strings.HasPrefix(arg, "/") || arg == "." {
+	if arg == "." || arg == ".." || filepath.IsAbs(arg) {
+		return true
+	}
+	for _, prefix := range []string{"./", "../", "/", `.\`, `..\`, `\`} {
+		if strings.HasPrefix(arg, prefix) {
+			return true
+		}
+	}
+	if len(arg) >= 3 && arg[1] == ':' && (arg[2] == '/' || arg[2] == '\\') {
 		return true
 	}
 	// .gox files are always treated as paths
@@ -507,81 +1254,102 @@ func isPath(arg string) bool {
 	return false
 }
 
-// runGoCommand runs go run or go build with automatic overlay generation.
-func runGoCommand(goCmd string, args []string) error {
-	// Extract paths and flags from args.
-	// For "go run", arguments after the package path are program arguments.
-	var paths []string
-	var goArgs []string
-	var programArgs []string
-	foundPath := false
-
-	for i := 0; i < len(args); i++ {
-		arg := args[i]
-
-		// Once we've found a path for "go run", everything else is a program arg
-		if foundPath && goCmd == "run" && !strings.HasPrefix(arg, "-") {
-			programArgs = append(programArgs, args[i:]...)
-			break
-		}
-
-		if strings.HasPrefix(arg, "-") {
-			goArgs = append(goArgs, arg)
-			// Check if this flag takes a value (common go build/run flags)
-			if arg == "-o" || arg == "-p" || arg == "-exec" ||
-				arg == "-ldflags" || arg == "-tags" || arg == "-mod" ||
-				arg == "-cover" || arg == "-coverprofile" {
-				if i+1 < len(args) && !strings.HasPrefix(args[i+1], "-") {
-					i++
-					goArgs = append(goArgs, args[i])
-				}
-			}
-		} else if isPath(arg) {
-			paths = append(paths, arg)
-			foundPath = true
-		} else {
-			// Could be a package path like "mypackage" or program args for go run
-			goArgs = append(goArgs, arg)
-		}
-	}
-
-	if len(paths) == 0 {
-		paths = []string{"."}
-	}
-
-	// Convert .gox file paths to directory paths for go run/build
+// goPackagePaths converts .gox file paths to the package directories that
+// contain them, so they can be passed to the go command. Other paths are
+// returned unchanged.
+func goPackagePaths(paths []string) []string {
 	var goPaths []string
 	for _, p := range paths {
 		if strings.HasSuffix(p, ".gox") {
 			dir := filepath.Dir(p)
 			// Ensure ./ prefix for relative paths (required by go run)
-			if !strings.HasPrefix(dir, "./") && !strings.HasPrefix(dir, "/") && dir != "." {
-				dir = "./" + dir
+			if !isPath(dir) {
+				dir = "." + string(filepath.Separator) + dir
 			}
 			goPaths = append(goPaths, dir)
 		} else {
 			goPaths = append(goPaths, p)
 		}
 	}
+	return goPaths
+}
 
-	// Find all .gox files recursively from the project root.
-	// We always scan ./... so that .gox files in dependency packages
-	// (e.g., cmd/status.gox imported by main.go) are included in the overlay,
-	// even when the build target is just ".".
-	goxFiles, err := findGoxFiles([]string{"./..."})
+// runGoCommand runs a go command with automatic overlay generation.
+func runGoCommand(goCmd string, args []string) error {
+	parsed, err := parseGoArgs(goCmd, args)
+	if err != nil {
+		return err
+	}
+	if parsed == nil {
+		// Not a command that builds packages, so there is nothing to overlay
+		return commandExitStatus(runGo(append([]string{goCmd}, args...)))
+	}
+
+	jsonOut := parsed.takeFlag("gox-json")
+
+	packages := parsed.packages
+	if len(packages) == 0 {
+		packages = []string{"."}
+	}
+	var paths []string
+	for _, p := range packages {
+		if isPath(p) {
+			paths = append(paths, p)
+		}
+	}
+	if len(paths) == 0 {
+		paths = []string{"."}
+	}
+
+	goPaths := goPackagePaths(packages)
+
+	// Find all .gox files recursively from the project root, and from the
+	// other modules of a go.work workspace. We always scan ./... so that .gox
+	// files in dependency packages (e.g., cmd/status.gox imported by main.go)
+	// are included in the overlay, even when the build target is just ".".
+	goxFiles, err := findBuildGoxFiles()
 	if err != nil {
 		return fmt.Errorf("finding gox files: %w", err)
 	}
+	// Files for other platforms or tags are left out, as go leaves out the
+	// files they would generate
+	ctxt := goxBuildContext(parsed.flagValue("tags"))
+	goxFiles = filterGoxFiles(ctxt, goxFiles)
+	if conflicts := packageConflicts(ctxt, goxFiles); len(conflicts) > 0 {
+		// Reported like the build errors go would print for them
+		for _, err := range conflicts {
+			if jsonOut {
+				writeDiagnostics(os.Stderr, errorDiagnostic("", err))
+			} else {
+				fmt.Fprintln(os.Stderr, err)
+			}
+		}
+		if goCmd == "run" || goCmd == "test" {
+			return exitStatus(buildFailedStatus)
+		}
+		return exitStatus(1)
+	}
 
-	// If no .gox files, just run go command directly
-	if len(goxFiles) == 0 {
-		cmd := exec.Command("go", append([]string{goCmd}, args...)...)
-		cmd.Stdout = os.Stdout
-		cmd.Stderr = os.Stderr
-		cmd.Stdin = os.Stdin
-		return cmd.Run()
+	// If no .gox files, just run go command directly. Run and test still go
+	// through gox, to report the program's exit status and build failures.
+	if len(goxFiles) == 0 && goCmd != "run" && goCmd != "test" {
+		return commandExitStatus(runGo(append([]string{goCmd}, parsed.commandLine(goCmd, parsed.packages)...)))
+	}
+
+	// gox analyzers run first: they also cover files that fail to generate
+	goxIssues := 0
+	if goCmd == "vet" {
+		goxIssues, err = runGoxVet(paths, os.Stderr, jsonOut)
+		if err != nil {
+			return err
+		}
 	}
 
+	// From here on gox creates files. Signals are caught, and forwarded to
+	// the go command or program, so that they are removed before gox exits.
+	signals := catchSignals()
+	defer signals.stop()
+
 	// Generate overlay to temp file
 	overlayFile, err := os.CreateTemp("", "gox-overlay-*.json")
 	if err != nil {
@@ -603,124 +1371,136 @@ func runGoCommand(goCmd string, args []string) error {
 		tempDir:      tempDir,
 		paths:        paths,
 		inMemoryMaps: true,
+		daemon:       dialProjectDaemon(),
+		cache:        openOverlayCache(),
 	}
-
-	if err := processFilesOverlay(goxFiles, cfg); err != nil {
-		return fmt.Errorf("generating overlay: %w", err)
-	}
-
-	// Write generated files to source directories for vet compatibility.
-	// go vet doesn't read files through the overlay, so the files must exist
-	// on disk. These are gitignored via *_gox.go / *_gox_test.go and cleaned up after the command.
-	overlayData, err := os.ReadFile(overlayFile.Name())
-	if err != nil {
-		return fmt.Errorf("reading overlay: %w", err)
-	}
-	var overlayJSON Overlay
-	if err := json.Unmarshal(overlayData, &overlayJSON); err != nil {
-		return fmt.Errorf("parsing overlay: %w", err)
-	}
-	var createdFiles []string
-	for targetPath, tempPath := range overlayJSON.Replace {
-		// Only track files we create, not ones that already exist on disk
-		// (e.g., from a previous "gox generate").
-		if _, statErr := os.Stat(targetPath); statErr == nil {
-			continue
-		}
-		content, readErr := os.ReadFile(tempPath)
-		if readErr != nil {
-			continue
-		}
-		if writeErr := os.WriteFile(targetPath, content, 0644); writeErr != nil {
-			continue
-		}
-		createdFiles = append(createdFiles, targetPath)
+	if cfg.cache != nil {
+		defer cfg.cache.trim()
 	}
 	defer func() {
-		for _, f := range createdFiles {
-			os.Remove(f)
+		if cfg.daemon != nil {
+			cfg.daemon.close()
 		}
 	}()
 
-	// Build go command with overlay
-	cmdArgs := []string{goCmd, "-overlay=" + overlayFile.Name()}
-	cmdArgs = append(cmdArgs, goArgs...)
-	cmdArgs = append(cmdArgs, goPaths...)
-	cmdArgs = append(cmdArgs, programArgs...)
+	if err := processFilesOverlay(goxFiles, cfg); err != nil {
+		if jsonOut {
+			writeDiagnostics(os.Stderr, errorDiagnostic("", err))
+			return errDiagnosticsReported
+		}
+		return fmt.Errorf("generating overlay: %w", err)
+	}
 
-	cmd := exec.Command("go", cmdArgs...)
-	cmd.Stdout = os.Stdout
-	cmd.Stdin = os.Stdin
+	if err := signals.interrupted(); err != nil {
+		return err
+	}
 
 	// Capture stderr for error remapping
 	var stderrBuf bytes.Buffer
-	cmd.Stderr = &stderrBuf
 
-	err = cmd.Run()
+	if goCmd == "run" {
+		err = buildAndRun(parsed, goPaths, overlayFile.Name(), tempDir, &stderrBuf, signals)
+	} else {
+		// Build go command with overlay. go vet, and the vet checks of go
+		// test, read generated files through it too, so the source tree is
+		// left alone.
+		cmdArgs := []string{goCmd, "-overlay=" + overlayFile.Name()}
+		cmdArgs = append(cmdArgs, parsed.commandLine(goCmd, goPaths)...)
+
+		cmd := exec.Command("go", cmdArgs...)
+		stdout := &buildFailureWriter{w: os.Stdout}
+		cmd.Stdout = stdout
+		cmd.Stdin = os.Stdin
+		cmd.Stderr = &stderrBuf
+
+		err = commandExitStatus(signals.run(cmd))
+		if goCmd == "test" && err != nil && stdout.buildFailed() {
+			err = exitStatus(buildFailedStatus)
+		}
+	}
 
 	// Remap and output errors using in-memory source maps
 	if stderrBuf.Len() > 0 {
 		remapped := remapErrors(stderrBuf.String(), cfg.sourceMapsOutput)
-		fmt.Fprint(os.Stderr, remapped)
+		if jsonOut {
+			writeDiagnostics(os.Stderr, goDiagnostics(remapped)...)
+		} else {
+			fmt.Fprint(os.Stderr, remapped)
+		}
+		if (goCmd == "vet" || goCmd == "test") && overlayIgnored(stderrBuf.String(), cfg.sourceMapsOutput) {
+			fmt.Fprintln(os.Stderr, "gox: this go's vet does not read generated files through -overlay; upgrade Go, or run gox generate first")
+		}
 	}
 
-	return err
-}
-
-// errorPattern matches Go compiler error format: file.go:line:col: message
-var errorPattern = regexp.MustCompile(`^(.+\.go):(\d+):(\d+):(.*)$`)
-
-// remapErrors takes go build/run stderr output and remaps _gox.go errors to .gox locations
-func remapErrors(stderr string, sourceMaps map[string]*generator.SourceMap) string {
-	var result strings.Builder
-	scanner := bufio.NewScanner(strings.NewReader(stderr))
+	if err == nil && goxIssues > 0 {
+		err = fmt.Errorf("vet: %d gox issue(s)", goxIssues)
+	}
+	if err != nil && jsonOut {
+		err = fmt.Errorf("%w: %w", errDiagnosticsReported, err)
+	}
 
-	for scanner.Scan() {
-		line := scanner.Text()
-		remapped := remapErrorLine(line, sourceMaps)
-		result.WriteString(remapped)
-		result.WriteString("\n")
+	// Point coverage of generated code back at the .gox sources
+	if profile := coverProfileFlag(parsed.flags); goCmd == "test" && profile != "" {
+		if _, statErr := os.Stat(profile); statErr == nil {
+			if remapErr := remapCoverProfile(profile, overlayFile.Name(), cfg.sourceMapsOutput); remapErr != nil {
+				fmt.Fprintf(os.Stderr, "gox: warning: %v\n", remapErr)
+			}
+		}
 	}
 
-	return result.String()
+	return err
 }
 
-// remapErrorLine remaps a single error line
-func remapErrorLine(line string, sourceMaps map[string]*generator.SourceMap) string {
-	matches := errorPattern.FindStringSubmatch(line)
-	if matches == nil {
-		return line
+// overlayIgnored reports whether go failed to open a generated file that
+// only exists in the overlay, as go vet does in Go releases whose vet reads
+// files from disk.
+func overlayIgnored(stderr string, sourceMaps map[string]*generator.SourceMap) bool {
+	for path := range sourceMaps {
+		if strings.Contains(stderr, "open "+path+":") {
+			return true
+		}
 	}
+	return false
+}
 
-	filePath := matches[1]
-	lineNum, _ := strconv.Atoi(matches[2])
-	colNum, _ := strconv.Atoi(matches[3])
-	message := matches[4]
+// runGo runs go with args, connected to the standard streams.
+func runGo(args []string) error {
+	cmd := exec.Command("go", args...)
+	cmd.Stdout = os.Stdout
+	cmd.Stderr = os.Stderr
+	cmd.Stdin = os.Stdin
+	return cmd.Run()
+}
 
-	// Check if this is a generated gox file (_gox.go or _gox_test.go)
-	if !strings.HasSuffix(filePath, "_gox.go") && !strings.HasSuffix(filePath, "_gox_test.go") {
-		return line
-	}
+// runLSP starts the LSP server.
+func runLSP(args []string) error {
+	opts := &lsp.Options{Version: version}
+	var goplsArgs, remote string
+	rpcTrace := false
+
+	fs := flag.NewFlagSet("lsp", flag.ExitOnError)
+	fs.StringVar(&opts.Gopls, "gopls", "", "gopls binary to run (default: gopls from PATH or a common install location)")
+	fs.StringVar(&goplsArgs, "gopls-args", "", "space-separated flags for gopls, before its serve command")
+	fs.BoolVar(&rpcTrace, "rpc.trace", false, "pass -rpc.trace to gopls, logging the LSP traffic")
+	fs.StringVar(&remote, "remote", "", "pass -remote to gopls, to share a gopls daemon (e.g. auto)")
+	fs.BoolVar(&opts.NormalizeSelfClosing, "self-closing", false, "format empty elements self-closing, as fmt -self-closing does")
+	fs.BoolVar(&opts.LineDirectives, "line-directives", false, "interleave //line directives in generated files, as generate -line-directives does")
 
-	// Look up source map in memory
-	sm, ok := sourceMaps[filePath]
-	if !ok {
-		return line // No mapping found, return original
+	if err := fs.Parse(args); err != nil {
+		return err
 	}
-
-	// Remap position (Go compiler uses 1-indexed, source map uses 0-indexed)
-	srcPos, ok := sm.SourcePositionFromTarget(uint32(lineNum-1), uint32(colNum-1))
-	if !ok {
-		return line // No mapping found
+	if fs.NArg() > 0 {
+		return fmt.Errorf("usage: gox lsp [-gopls path] [-gopls-args flags] [-rpc.trace] [-remote addr] [-line-directives] [-self-closing]")
+	}
+	opts.GoplsArgs = strings.Fields(goplsArgs)
+	if rpcTrace {
+		opts.GoplsArgs = append(opts.GoplsArgs, "-rpc.trace")
+	}
+	if remote != "" {
+		opts.GoplsArgs = append(opts.GoplsArgs, "-remote="+remote)
 	}
 
-	// Output remapped error with .gox file
-	return fmt.Sprintf("%s:%d:%d:%s", sm.SourceFile, srcPos.Line+1, srcPos.Column+1, message)
-}
-
-// runLSP starts the LSP server.
-func runLSP() error {
-	proxy, err := lsp.New()
+	proxy, err := lsp.New(opts)
 	if err != nil {
 		return err
 	}
@@ -730,26 +1510,93 @@ func runLSP() error {
 
 // formatConfig holds configuration for the format command.
 type formatConfig struct {
-	write   bool // Write result to file instead of stdout
-	diff    bool // Show diff instead of formatted output
-	list    bool // List files that would be formatted
-	verbose bool
-	paths   []string
+	write     bool // Write result to file instead of stdout
+	diff      bool // Show diff instead of formatted output
+	list      bool // List files that would be formatted
+	stdin     bool // Format stdin to stdout
+	verbose   bool
+	json      bool // Print diagnostics as JSON lines
+	staged    bool // Format the staged content of staged files
+	dryRun    bool // Report the files -w would overwrite instead of writing them
+	parallel  int  // Number of files formatted at once
+	selfClose bool // Write empty elements self-closing
+	paths     []string
+	rules     []rewriteRule // Applied before formatting (-r)
 }
 
 // runFormat runs the format command.
 func runFormat(args []string) error {
-	cfg := &formatConfig{}
+	cfg := &formatConfig{
+		parallel: 4,
+	}
 
 	fs := flag.NewFlagSet("fmt", flag.ExitOnError)
 	fs.BoolVar(&cfg.write, "w", false, "write result to file instead of stdout")
 	fs.BoolVar(&cfg.diff, "d", false, "display diff instead of formatted output")
 	fs.BoolVar(&cfg.list, "l", false, "list files that would be formatted")
+	fs.BoolVar(&cfg.stdin, "stdin", false, "format a single document from stdin and write it to stdout")
 	fs.BoolVar(&cfg.verbose, "v", false, "verbose output")
+	fs.BoolVar(&cfg.json, "json", false, "print diagnostics as JSON lines")
+	fs.BoolVar(&cfg.staged, "staged", false, "format the staged content of staged files (paths from stdin with -)")
+	fs.BoolVar(&cfg.dryRun, "n", false, "print the files -w would overwrite without writing them")
+	fs.BoolVar(&cfg.dryRun, "dry-run", false, "same as -n")
+	fs.IntVar(&cfg.parallel, "parallel", 4, "number of parallel workers")
+	fs.BoolVar(&cfg.selfClose, "self-closing", false, "write empty elements self-closing, as <div /> (not <script>, <textarea> and the like)")
+	var rules string
+	fs.StringVar(&rules, "r", "", "rewrite rules applied before formatting (e.g. 'box -> stack; *.class -> *.className')")
 
 	if err := fs.Parse(args); err != nil {
 		return err
 	}
+	if cfg.parallel < 1 {
+		return fmt.Errorf("-parallel must be at least 1")
+	}
+	if rules != "" {
+		parsed, err := parseRewriteRules(rules)
+		if err != nil {
+			return err
+		}
+		cfg.rules = parsed
+	}
+
+	if cfg.dryRun {
+		if cfg.stdin || cfg.staged {
+			return fmt.Errorf("cannot use -n with -stdin or -staged")
+		}
+		cfg.write = true
+	}
+
+	if cfg.staged {
+		if cfg.write || cfg.stdin {
+			return fmt.Errorf("cannot use -w or -stdin with -staged")
+		}
+		files, err := stagedPaths(".", fs.Args(), os.Stdin)
+		if err != nil {
+			return err
+		}
+		return formatFiles(files, cfg, os.Stdout)
+	}
+
+	if cfg.stdin {
+		if fs.NArg() > 0 {
+			return fmt.Errorf("cannot use -stdin with file paths")
+		}
+		if cfg.write {
+			return fmt.Errorf("cannot use -w with -stdin")
+		}
+		src, err := io.ReadAll(os.Stdin)
+		if err != nil {
+			return fmt.Errorf("reading stdin: %w", err)
+		}
+		if _, err := formatSource("<standard input>", src, cfg, os.Stdout); err != nil {
+			if cfg.json {
+				writeDiagnostics(os.Stderr, errorDiagnostic("<standard input>", err))
+				return errDiagnosticsReported
+			}
+			return fmt.Errorf("<standard input>:%w", err)
+		}
+		return nil
+	}
 
 	cfg.paths = fs.Args()
 	if len(cfg.paths) == 0 {
@@ -763,50 +1610,102 @@ func runFormat(args []string) error {
 	}
 
 	if len(files) == 0 {
-		fmt.Println("No .gox files found")
+		if !cfg.json {
+			fmt.Println("No .gox files found")
+		}
 		return nil
 	}
+	return formatFiles(files, cfg, os.Stdout)
+}
+
+// formatFiles formats files on cfg.parallel workers, reporting errors for
+// each file without stopping. The output of each file is buffered and
+// printed to out in the order of files, as soon as the files before it are
+// done, so it does not depend on scheduling.
+func formatFiles(files []string, cfg *formatConfig, out io.Writer) error {
+	type formatResult struct {
+		out     bytes.Buffer
+		changed bool
+		err     error
+		done    chan struct{}
+	}
+	results := make([]*formatResult, len(files))
+	for i := range results {
+		results[i] = &formatResult{done: make(chan struct{})}
+	}
+
+	go func() {
+		semaphore := make(chan struct{}, max(cfg.parallel, 1))
+		for i, file := range files {
+			semaphore <- struct{}{} // Acquire, in order, so -parallel 1 is sequential
+			go func(file string, r *formatResult) {
+				defer close(r.done)
+				defer func() { <-semaphore }() // Release
+				r.changed, r.err = formatFile(file, cfg, &r.out)
+			}(file, results[i])
+		}
+	}()
 
-	// Process each file
 	var hasChanges bool
-	for _, file := range files {
-		changed, err := formatFile(file, cfg)
-		if err != nil {
-			fmt.Fprintf(os.Stderr, "error: %s: %v\n", file, err)
+	for i, r := range results {
+		<-r.done
+		out.Write(r.out.Bytes())
+		if r.err != nil {
+			if cfg.json {
+				writeDiagnostics(os.Stderr, errorDiagnostic(files[i], r.err))
+			} else {
+				fmt.Fprintf(os.Stderr, "error: %s: %v\n", files[i], r.err)
+			}
 			continue
 		}
-		if changed {
+		if r.changed {
 			hasChanges = true
 		}
 	}
 
 	if cfg.list && !hasChanges {
 		if cfg.verbose {
-			fmt.Println("All files are properly formatted")
+			fmt.Fprintln(out, "All files are properly formatted")
 		}
 	}
 
 	return nil
 }
 
-// formatFile formats a single .gox file.
-func formatFile(path string, cfg *formatConfig) (bool, error) {
+// formatFile formats a single .gox file, printing to out.
+func formatFile(path string, cfg *formatConfig, out io.Writer) (bool, error) {
 	// Read source
-	src, err := os.ReadFile(path)
+	var src []byte
+	var err error
+	if cfg.staged {
+		src, err = stagedSource(path)
+	} else {
+		src, err = os.ReadFile(path)
+	}
 	if err != nil {
 		return false, fmt.Errorf("reading file: %w", err)
 	}
+	return formatSource(path, src, cfg, out)
+}
 
-	// Parse
-	file, err := parser.Parse(path, src)
-	if err != nil {
-		return false, fmt.Errorf("parsing: %w", err)
+// formatSource formats src and reports, writes or prints the result to out
+// according to cfg. path names the document in output and is written to
+// with -w.
+func formatSource(path string, src []byte, cfg *formatConfig, out io.Writer) (bool, error) {
+	// Rewrite, then parse and format
+	input := src
+	if len(cfg.rules) > 0 {
+		rewritten, err := rewriteSource(path, src, cfg.rules)
+		if err != nil {
+			return false, err
+		}
+		input = rewritten
 	}
-
-	// Format
-	formatted, err := formatter.Format(file, nil)
+	opts := formatter.DefaultOptions()
+	opts.NormalizeSelfClosing = cfg.selfClose
+	formatted, err := formatter.Source(input, opts)
 	if err != nil {
-		return false, fmt.Errorf("formatting: %w", err)
+		return false, err
 	}
 
 	// Check if changed
@@ -814,36 +1713,35 @@ func formatFile(path string, cfg *formatConfig) (bool, error) {
 
 	if cfg.list {
 		if changed {
-			fmt.Println(path)
+			fmt.Fprintln(out, path)
 		}
 		return changed, nil
 	}
 
 	if cfg.diff {
 		if changed {
-			// Simple diff output
-			fmt.Printf("--- %s (original)\n", path)
-			fmt.Printf("+++ %s (formatted)\n", path)
-			fmt.Println(string(formatted))
+			fmt.Fprint(out, unifiedDiff(path+".orig", path, src, formatted))
 		}
 		return changed, nil
 	}
 
 	if cfg.write {
-		if changed {
-			if err := os.WriteFile(path, formatted, 0644); err != nil {
+		if changed && cfg.dryRun {
+			fmt.Fprintf(out, "overwrite %s\n", path)
+		} else if changed {
+			if err := atomicfile.WriteFile(path, formatted, 0644); err != nil {
 				return false, fmt.Errorf("writing file: %w", err)
 			}
 			if cfg.verbose {
-				fmt.Printf("Formatted %s\n", path)
+				fmt.Fprintf(out, "Formatted %s\n", path)
 			}
 		} else if cfg.verbose {
-			fmt.Printf("Unchanged %s\n", path)
+			fmt.Fprintf(out, "Unchanged %s\n", path)
 		}
 		return changed, nil
 	}
 
 	// Default: output to stdout
-	fmt.Print(string(formatted))
+	out.Write(formatted)
 	return changed, nil
 }
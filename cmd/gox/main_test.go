@@ -0,0 +1,448 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/germtb/gox/generator"
+)
+
+func TestCheckFiles(t *testing.T) {
+	dir := t.TempDir()
+	write := func(name, content string) string {
+		path := filepath.Join(dir, name)
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+		return path
+	}
+	current := write("current.gox", "package ui\n\nfunc A() gox.VNode { return <a /> }\n")
+	stale := write("stale.gox", "package ui\n\nfunc B() gox.VNode { return <b /> }\n")
+	missing := write("missing_test.gox", "package ui\n\nfunc C() gox.VNode { return <i /> }\n")
+	files := []string{current, stale, missing}
+
+	cfg := &generateConfig{parallel: 1}
+	if err := processFiles(files[:2], cfg); err != nil {
+		t.Fatal(err)
+	}
+	var out bytes.Buffer
+	if err := checkFiles(files[:2], cfg, &out); err != nil || out.Len() != 0 {
+		t.Fatalf("checkFiles on fresh output = %v, %q; want nil, no output", err, out.String())
+	}
+
+	write("stale_gox.go", "package ui\n")
+	err := checkFiles(files, cfg, &out)
+	if err == nil || !strings.Contains(err.Error(), "2 generated file(s) out of date") {
+		t.Errorf("checkFiles error = %v, want 2 files out of date", err)
+	}
+	want := filepath.Join(dir, "stale_gox.go") + ": stale (regenerate from " + stale + ")\n" +
+		filepath.Join(dir, "missing_gox_test.go") + ": missing (generate from " + missing + ")\n"
+	if out.String() != want {
+		t.Errorf("checkFiles output:\n%s\nwant:\n%s", out.String(), want)
+	}
+}
+
+func TestGenerateStdout(t *testing.T) {
+	dir := t.TempDir()
+	input := filepath.Join(dir, "app.gox")
+	src := []byte("package ui\n\nfunc A() gox.VNode { return <a /> }\n")
+	if err := os.WriteFile(input, src, 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	var out bytes.Buffer
+	if err := generateStdout(input, src, &generateConfig{stdout: true}, &out); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(out.String(), `gox.Element("a", nil)`) {
+		t.Errorf("generated code:\n%s", out.String())
+	}
+
+	out.Reset()
+	if err := generateStdout(input, src, &generateConfig{mapStdout: true}, &out); err != nil {
+		t.Fatal(err)
+	}
+	sourceMap, err := generator.FromJSON(out.Bytes())
+	if err != nil {
+		t.Fatalf("source map: %v\n%s", err, out.String())
+	}
+	if want := filepath.Join(dir, "app_gox.go"); sourceMap.TargetFile != want || !sourceMap.HasMappings() {
+		t.Errorf("source map targets %s with mappings=%v, want %s", sourceMap.TargetFile, sourceMap.HasMappings(), want)
+	}
+
+	// A document from stdin is named by -filename, which need not exist
+	out.Reset()
+	if err := generateStdout(filepath.Join(dir, "view_linux.gox"), src, &generateConfig{stdout: true}, &out); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(out.String(), "\n//go:build linux\n") {
+		t.Errorf("generated code for view_linux.gox lacks its build constraint:\n%s", out.String())
+	}
+
+	entries, err := os.ReadDir(dir)
+	if err != nil || len(entries) != 1 {
+		t.Errorf("generateStdout wrote files: %v %v", entries, err)
+	}
+}
+
+func TestDryRun(t *testing.T) {
+	dir := t.TempDir()
+	input := filepath.Join(dir, "app.gox")
+	if err := os.WriteFile(input, []byte("package ui\n\nfunc A() gox.VNode { return <a /> }\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	output := filepath.Join(dir, "app_gox.go")
+
+	if err := processFiles([]string{input}, &generateConfig{parallel: 1, dryRun: true}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(output); !os.IsNotExist(err) {
+		t.Fatalf("dry run wrote %s", output)
+	}
+	if got := dryRunAction(output, nil); got != "create" {
+		t.Errorf("action for a missing file = %s, want create", got)
+	}
+
+	if err := processFiles([]string{input}, &generateConfig{parallel: 1}); err != nil {
+		t.Fatal(err)
+	}
+	data, err := os.ReadFile(output)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := dryRunAction(output, data); got != "rewrite" {
+		t.Errorf("action for identical content = %s, want rewrite", got)
+	}
+	if got := dryRunAction(output, []byte("package ui\n")); got != "overwrite" {
+		t.Errorf("action for different content = %s, want overwrite", got)
+	}
+}
+
+func TestGenerateSuffix(t *testing.T) {
+	dir := t.TempDir()
+	for name, content := range map[string]string{
+		"go.mod":         "module example.com/app\n",
+		".goxsuffix":     ".gen.go\n",
+		"app.gox":        "package ui\n\nfunc A() gox.VNode { return <a /> }\n",
+		"bad/.goxsuffix": "_gox\n",
+		"bad/b.gox":      "package bad\n",
+	} {
+		path := filepath.Join(dir, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	files, err := findGoxFiles([]string{dir})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := processFile(files[0], &generateConfig{}); err != nil {
+		t.Fatal(err)
+	}
+	output := filepath.Join(dir, "app.gen.go")
+	if _, err := os.Stat(output); err != nil {
+		t.Fatalf("generated file not named with the configured suffix: %v", err)
+	}
+	if !isGeneratedFile(output) || isGeneratedFile(filepath.Join(dir, "app_gox.go")) {
+		t.Errorf("isGeneratedFile does not follow the configured suffix")
+	}
+
+	if _, err := findGoxFiles([]string{filepath.Join(dir, "bad")}); err == nil || !strings.Contains(err.Error(), ".goxsuffix") {
+		t.Errorf("findGoxFiles with an invalid suffix = %v, want an error naming .goxsuffix", err)
+	}
+}
+
+func TestGenerateOutputDir(t *testing.T) {
+	dir := t.TempDir()
+	for name, content := range map[string]string{
+		"go.mod":         "module example.com/app\n",
+		"web/.goxoutdir": "gen\n",
+		"web/ui/app.gox": "package ui\n\nfunc A() gox.VNode { return <a /> }\n",
+		"bad/.goxoutdir": "/gen\n",
+		"bad/b.gox":      "package bad\n",
+	} {
+		path := filepath.Join(dir, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	input := filepath.Join(dir, "web", "ui", "app.gox")
+	if _, err := processFile(input, &generateConfig{}); err != nil {
+		t.Fatal(err)
+	}
+	output := filepath.Join(dir, "web", "gen", "ui", "app_gox.go")
+	for _, path := range []string{output, output + ".map"} {
+		if _, err := os.Stat(path); err != nil {
+			t.Errorf("not generated into the output tree: %v", err)
+		}
+	}
+	// -o still takes precedence
+	if got, want := getOutputPath(input, "out"), filepath.Join("out", "app_gox.go"); got != want {
+		t.Errorf("getOutputPath with -o = %q, want %q", got, want)
+	}
+
+	if err := checkOutputLayouts([]string{filepath.Join(dir, "bad", "b.gox")}); err == nil || !strings.Contains(err.Error(), ".goxoutdir") {
+		t.Errorf("checkOutputLayouts with an invalid output tree = %v, want an error naming .goxoutdir", err)
+	}
+}
+
+func TestGenerateEditedFile(t *testing.T) {
+	dir := t.TempDir()
+	input := filepath.Join(dir, "app.gox")
+	if err := os.WriteFile(input, []byte("package ui\n\nfunc A() gox.VNode { return <a /> }\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	output := filepath.Join(dir, "app_gox.go")
+	if _, err := processFile(input, &generateConfig{}); err != nil {
+		t.Fatal(err)
+	}
+	data, err := os.ReadFile(output)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Output of a gox version without headers is overwritten
+	_, body, _ := strings.Cut(string(data), "\n\n")
+	if err := os.WriteFile(output, []byte(body), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := processFile(input, &generateConfig{}); err != nil {
+		t.Fatalf("overwriting output without a header: %v", err)
+	}
+
+	// Hand edits are not
+	edited := strings.Replace(string(data), `"a"`, `"b"`, 1)
+	if err := os.WriteFile(output, []byte(edited), 0644); err != nil {
+		t.Fatal(err)
+	}
+	var out bytes.Buffer
+	if err := checkFiles([]string{input}, &generateConfig{}, &out); err == nil || !strings.Contains(out.String(), "edited by hand") {
+		t.Errorf("checkFiles = %v, %q; want the file reported as edited", err, out.String())
+	}
+	if _, err := processFile(input, &generateConfig{}); err == nil || !strings.Contains(err.Error(), "-force") {
+		t.Errorf("processFile on an edited file = %v, want an error suggesting -force", err)
+	}
+	if got, _ := os.ReadFile(output); string(got) != edited {
+		t.Error("edited file was overwritten")
+	}
+	if _, err := processFile(input, &generateConfig{force: true}); err != nil {
+		t.Fatal(err)
+	}
+	if got, _ := os.ReadFile(output); !bytes.Equal(got, data) {
+		t.Errorf("-force did not regenerate the file:\n%s", got)
+	}
+}
+
+func TestGenerateRuntimePerDirectory(t *testing.T) {
+	dir := t.TempDir()
+	files := map[string]string{
+		"go.mod":           "module example.com/app\n",
+		"web/page.gox":     "package web\n\nfunc Page() gox.VNode { return <p /> }\n",
+		"tui/.goxruntime":  "example.com/tui/gox\n",
+		"tui/list.gox":     "package tui\n\nfunc List() gox.VNode { return <list /> }\n",
+		"tui/override.gox": "//gox:runtime example.com/other\npackage tui\n\nfunc Other() gox.VNode { return <box /> }\n",
+	}
+	for name, content := range files {
+		path := filepath.Join(dir, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	cfg := &generateConfig{runtimePkg: "example.com/web/gox"}
+	for name, want := range map[string]string{
+		"web/page.gox":     "example.com/web/gox",
+		"tui/list.gox":     "example.com/tui/gox",
+		"tui/override.gox": "example.com/other",
+	} {
+		output, _, err := generateFile(filepath.Join(dir, filepath.FromSlash(name)), cfg)
+		if err != nil {
+			t.Fatalf("%s: %v", name, err)
+		}
+		if !strings.Contains(string(output), `import "`+want+`"`) {
+			t.Errorf("%s: want runtime %s, got:\n%s", name, want, output)
+		}
+	}
+}
+
+func TestIsPath(t *testing.T) {
+	for arg, want := range map[string]bool{
+		".":              true,
+		"./ui":           true,
+		"../ui":          true,
+		"/src/app":       true,
+		`.\ui`:           true,
+		`..\ui`:          true,
+		`C:\src\app`:     true,
+		"C:/src/app":     true,
+		`\\server\share`: true,
+		"app.gox":        true,
+		"example.com/ui": false,
+		"./...":          true,
+		"-v":             false,
+	} {
+		if got := isPath(arg); got != want {
+			t.Errorf("isPath(%q) = %v, want %v", arg, got, want)
+		}
+	}
+}
+
+func TestOverlayTempPath(t *testing.T) {
+	cwd, err := os.Getwd()
+	if err != nil {
+		t.Fatal(err)
+	}
+	tempDir := filepath.Join(os.TempDir(), "gox-overlay")
+
+	inside := filepath.Join(cwd, "ui", "app_gox.go")
+	if got, want := overlayTempPath(tempDir, inside), filepath.Join(tempDir, "ui", "app_gox.go"); got != want {
+		t.Errorf("overlayTempPath(%s) = %s, want %s", inside, got, want)
+	}
+
+	// Files outside the working directory must not escape tempDir
+	outside := filepath.Join(filepath.Dir(cwd), "other", "app_gox.go")
+	got := overlayTempPath(tempDir, outside)
+	if rel, err := filepath.Rel(tempDir, got); err != nil || strings.HasPrefix(rel, "..") {
+		t.Errorf("overlayTempPath(%s) = %s, outside %s", outside, got, tempDir)
+	}
+}
+
+func TestFilterGoxFiles(t *testing.T) {
+	dir := t.TempDir()
+	files := map[string]string{
+		"view.gox":         "package ui\n",
+		"view_linux.gox":   "package ui\n",
+		"view_windows.gox": "package ui\n",
+		"view_arm64.gox":   "package ui\n",
+		"fancy.gox":        "//go:build fancy\n\npackage ui\n",
+		"plain.gox":        "//go:build !fancy\n\npackage ui\n",
+	}
+	var paths []string
+	for name, content := range files {
+		path := filepath.Join(dir, name)
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+		paths = append(paths, path)
+	}
+	sort.Strings(paths)
+
+	ctxt := goxBuildContext("fancy")
+	ctxt.GOOS, ctxt.GOARCH = "linux", "amd64"
+	var got []string
+	for _, path := range filterGoxFiles(ctxt, paths) {
+		got = append(got, filepath.Base(path))
+	}
+	want := []string{"fancy.gox", "view.gox", "view_linux.gox"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("filterGoxFiles = %v, want %v", got, want)
+	}
+}
+
+func TestOverlayIgnored(t *testing.T) {
+	sourceMaps := map[string]*generator.SourceMap{"/src/ui/app_gox.go": generator.NewSourceMap()}
+	if !overlayIgnored("vet: open /src/ui/app_gox.go: no such file or directory\n", sourceMaps) {
+		t.Error("a generated file vet could not open was not detected")
+	}
+	if overlayIgnored("ui/app_gox.go:3:4: unreachable code\n", sourceMaps) {
+		t.Error("a vet finding was taken for an ignored overlay")
+	}
+}
+
+func TestFormatFilesParallelOrder(t *testing.T) {
+	dir := t.TempDir()
+	var files []string
+	var want strings.Builder
+	for i := 0; i < 40; i++ {
+		path := filepath.Join(dir, fmt.Sprintf("f%02d.gox", i))
+		src := "package ui\n\nfunc A() gox.VNode { return <a /> }\n"
+		if i%3 == 0 {
+			// Unformatted
+			src = "package ui\n\nfunc A() gox.VNode {   return <a   /> }\n"
+			want.WriteString(path + "\n")
+		}
+		if err := os.WriteFile(path, []byte(src), 0644); err != nil {
+			t.Fatal(err)
+		}
+		files = append(files, path)
+	}
+
+	for _, parallel := range []int{1, 8} {
+		var out bytes.Buffer
+		if err := formatFiles(files, &formatConfig{list: true, parallel: parallel}, &out); err != nil {
+			t.Fatal(err)
+		}
+		if out.String() != want.String() {
+			t.Errorf("-parallel %d listed:\n%s\nwant:\n%s", parallel, out.String(), want.String())
+		}
+	}
+}
+
+func TestProcessFilesProgress(t *testing.T) {
+	dir := t.TempDir()
+	var files []string
+	for _, name := range []string{"a", "b", "bad"} {
+		path := filepath.Join(dir, name+".gox")
+		src := "package ui\n\nfunc A() gox.VNode { return <a /> }\n"
+		if name == "bad" {
+			src = "package ui\n\nfunc A() gox.VNode { return <a> }\n"
+		}
+		if err := os.WriteFile(path, []byte(src), 0644); err != nil {
+			t.Fatal(err)
+		}
+		files = append(files, path)
+	}
+	if _, err := processFile(files[0], &generateConfig{}); err != nil {
+		t.Fatal(err)
+	}
+	output := filepath.Join(dir, "a_gox.go")
+	before, err := os.Stat(output)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Files that are up to date are not rewritten
+	changed, err := processFile(files[0], &generateConfig{})
+	if err != nil || changed {
+		t.Errorf("processFile on an up to date file = %v, %v; want false, nil", changed, err)
+	}
+	if after, _ := os.Stat(output); !after.ModTime().Equal(before.ModTime()) {
+		t.Error("up to date output was rewritten")
+	}
+
+	var out bytes.Buffer
+	progress := newGenerateProgress(&out, len(files))
+	for _, file := range files {
+		changed, err := processFile(file, &generateConfig{})
+		progress.fileDone(file, getOutputPath(file, ""), changed, err, time.Millisecond, true)
+	}
+	progress.summary()
+	for _, want := range []string{
+		"[1/3] " + files[0] + ": up to date (1ms)",
+		"[2/3] " + files[1] + " -> " + filepath.Join(dir, "b_gox.go") + " (1ms)",
+		"[3/3] " + files[2] + ": failed (1ms)",
+		"1 generated, 1 up to date, 1 failed (3 file(s) in ",
+	} {
+		if !strings.Contains(out.String(), want) {
+			t.Errorf("progress output lacks %q:\n%s", want, out.String())
+		}
+	}
+}
@@ -0,0 +1,151 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/germtb/gox/generator"
+)
+
+// mappedPosition is a position translated by gox map. Column is 0 for
+// line-only queries.
+type mappedPosition struct {
+	File   string `json:"file"`
+	Line   int    `json:"line"`
+	Column int    `json:"column,omitempty"`
+}
+
+// runMap runs the map command: translate positions in generated files to
+// their .gox sources, and positions in .gox files to the generated code,
+// using the source maps gox generate writes next to generated files.
+// Columns in generated files are in bytes, as Go tools report them, and
+// columns in .gox files in runes, as gox reports them.
+func runMap(args []string) error {
+	var outputDir, mapFile string
+	asJSON := false
+
+	fs := flag.NewFlagSet("map", flag.ExitOnError)
+	fs.BoolVar(&asJSON, "json", false, "print one JSON object per position")
+	fs.StringVar(&outputDir, "o", "", "output directory the files were generated to")
+	fs.StringVar(&mapFile, "map", "", "source map to use instead of the one next to the generated file")
+
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if fs.NArg() == 0 {
+		return fmt.Errorf("usage: gox map [-json] [-o dir] [-map file] file:line[:col] ...")
+	}
+
+	enc := json.NewEncoder(os.Stdout)
+	for _, arg := range fs.Args() {
+		pos, err := mapPosition(arg, outputDir, mapFile)
+		if err != nil {
+			return err
+		}
+		if asJSON {
+			if err := enc.Encode(pos); err != nil {
+				return err
+			}
+			continue
+		}
+		if pos.Column > 0 {
+			fmt.Printf("%s:%d:%d\n", pos.File, pos.Line, pos.Column)
+		} else {
+			fmt.Printf("%s:%d\n", pos.File, pos.Line)
+		}
+	}
+	return nil
+}
+
+// mapPosition translates a file:line[:col] reference. References to .gox
+// files are mapped to the generated file, any other to the .gox source.
+func mapPosition(ref, outputDir, mapFile string) (mappedPosition, error) {
+	file, line, col, err := parsePositionRef(ref)
+	if err != nil {
+		return mappedPosition{}, err
+	}
+
+	reverse := isGoxFile(file)
+	if mapFile == "" {
+		mapFile = file + ".map"
+		if reverse {
+			mapFile = getOutputPath(file, outputDir) + ".map"
+		}
+	}
+	data, err := os.ReadFile(mapFile)
+	if os.IsNotExist(err) {
+		return mappedPosition{}, fmt.Errorf("%s: no source map at %s; run gox generate first", file, mapFile)
+	}
+	if err != nil {
+		return mappedPosition{}, err
+	}
+	sm, err := generator.FromJSON(data)
+	if err != nil {
+		return mappedPosition{}, fmt.Errorf("%s: %w", mapFile, err)
+	}
+
+	var pos mappedPosition
+	var ok bool
+	if reverse {
+		pos, ok = mapToTarget(sm, line, col)
+	} else {
+		pos, ok = mapToSource(sm, line, col)
+	}
+	if !ok {
+		return mappedPosition{}, fmt.Errorf("%s: no mapping in %s", ref, mapFile)
+	}
+	return pos, nil
+}
+
+// mapToSource maps a 1-based position in generated code to the .gox source.
+func mapToSource(sm *generator.SourceMap, line, col int) (mappedPosition, bool) {
+	if col == 0 {
+		srcLine, ok := sm.FindSourceLine(uint32(line - 1))
+		return mappedPosition{File: sm.SourceFile, Line: int(srcLine) + 1}, ok
+	}
+	tgtLine := uint32(line - 1)
+	pos, ok := sm.SourcePositionFromTarget(tgtLine, sm.TargetRuneColumn(tgtLine, uint32(col-1)))
+	return mappedPosition{File: sm.SourceFile, Line: int(pos.Line) + 1, Column: int(pos.Column) + 1}, ok
+}
+
+// mapToTarget maps a 1-based position in a .gox file to generated code.
+func mapToTarget(sm *generator.SourceMap, line, col int) (mappedPosition, bool) {
+	if col == 0 {
+		tgtLine, ok := sm.FindTargetLine(uint32(line - 1))
+		return mappedPosition{File: sm.TargetFile, Line: int(tgtLine) + 1}, ok
+	}
+	pos, ok := sm.TargetPositionFromSource(uint32(line-1), uint32(col-1))
+	column := sm.TargetByteColumn(pos.Line, pos.Column)
+	return mappedPosition{File: sm.TargetFile, Line: int(pos.Line) + 1, Column: int(column) + 1}, ok
+}
+
+// parsePositionRef splits file:line[:col]. The file is split off at the
+// last colons, so Windows paths such as C:\src\app_gox.go:12 work.
+func parsePositionRef(ref string) (file string, line, col int, err error) {
+	var nums []int
+	rest := ref
+	for len(nums) < 2 {
+		i := strings.LastIndex(rest, ":")
+		if i < 0 {
+			break
+		}
+		n, err := strconv.Atoi(rest[i+1:])
+		if err != nil || n < 1 {
+			break
+		}
+		nums = append([]int{n}, nums...)
+		rest = rest[:i]
+	}
+	if len(nums) == 0 || rest == "" {
+		return "", 0, 0, fmt.Errorf("invalid position %q: expected file:line[:col]", ref)
+	}
+	line = nums[0]
+	if len(nums) == 2 {
+		col = nums[1]
+	}
+	return rest, line, col, nil
+}
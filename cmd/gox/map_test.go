@@ -0,0 +1,86 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/germtb/gox/generator"
+)
+
+func TestParsePositionRef(t *testing.T) {
+	tests := []struct {
+		ref       string
+		file      string
+		line, col int
+		wantErr   bool
+	}{
+		{ref: "ui/a_gox.go:12:5", file: "ui/a_gox.go", line: 12, col: 5},
+		{ref: "ui/a.gox:3", file: "ui/a.gox", line: 3},
+		{ref: `C:\src\a_gox.go:12:5`, file: `C:\src\a_gox.go`, line: 12, col: 5},
+		{ref: "a_gox.go", wantErr: true},
+		{ref: "a_gox.go:0", wantErr: true},
+		{ref: ":4", wantErr: true},
+	}
+	for _, tt := range tests {
+		file, line, col, err := parsePositionRef(tt.ref)
+		if tt.wantErr {
+			if err == nil {
+				t.Errorf("parsePositionRef(%q): expected error", tt.ref)
+			}
+			continue
+		}
+		if err != nil || file != tt.file || line != tt.line || col != tt.col {
+			t.Errorf("parsePositionRef(%q) = %q, %d, %d, %v; want %q, %d, %d", tt.ref, file, line, col, err, tt.file, tt.line, tt.col)
+		}
+	}
+}
+
+func TestMapPosition(t *testing.T) {
+	dir := t.TempDir()
+	source := filepath.Join(dir, "a.gox")
+	target := filepath.Join(dir, "a_gox.go")
+
+	sm := generator.NewSourceMap()
+	sm.SetFiles(source, target)
+	sm.AddMapping(4, 8, 6, 3)  // a.gox:5:9 <-> a_gox.go:7:4
+	sm.AddMapping(4, 2, 5, 10) // a.gox:5:3 <-> a_gox.go:6:11
+	data, err := sm.ToJSON()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(target+".map", data, 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		ref  string
+		want mappedPosition
+	}{
+		{ref: target + ":7:4", want: mappedPosition{File: source, Line: 5, Column: 9}},
+		{ref: target + ":7", want: mappedPosition{File: source, Line: 5}},
+		{ref: source + ":5:9", want: mappedPosition{File: target, Line: 7, Column: 4}},
+		// The first mapped column decides the line
+		{ref: source + ":5", want: mappedPosition{File: target, Line: 6}},
+	}
+	for _, tt := range tests {
+		got, err := mapPosition(tt.ref, "", "")
+		if err != nil {
+			t.Errorf("mapPosition(%q): %v", tt.ref, err)
+			continue
+		}
+		if got != tt.want {
+			t.Errorf("mapPosition(%q) = %+v, want %+v", tt.ref, got, tt.want)
+		}
+	}
+
+	if _, err := mapPosition(source+":40:1", "", ""); err == nil {
+		t.Error("expected an error for an unmapped line")
+	}
+	if _, err := mapPosition(filepath.Join(dir, "b_gox.go:1:1"), "", ""); err == nil {
+		t.Error("expected an error for a missing source map")
+	}
+	if _, err := mapPosition(filepath.Join(dir, "b_gox.go:7:4"), "", target+".map"); err != nil {
+		t.Errorf("mapPosition with -map: %v", err)
+	}
+}
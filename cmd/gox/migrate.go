@@ -0,0 +1,558 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"go/ast"
+	goparser "go/parser"
+	"go/token"
+	"os"
+	"path"
+	"strconv"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/germtb/gox/formatter"
+	"github.com/germtb/gox/generator"
+	"github.com/germtb/gox/internal/atomicfile"
+)
+
+// migrateConfig holds configuration for the migrate command.
+type migrateConfig struct {
+	write      bool // Write the .gox files and remove the .go files
+	list       bool // List files that would be converted
+	runtimePkg string
+	paths      []string
+}
+
+// runMigrate converts the VNode call trees of .go files in the given paths
+// to JSX. Without -w or -l it prints a diff from each .go file to the .gox
+// file it would become.
+func runMigrate(args []string) error {
+	cfg := &migrateConfig{}
+
+	fs := flag.NewFlagSet("migrate", flag.ExitOnError)
+	fs.BoolVar(&cfg.write, "w", false, "write .gox files and remove the converted .go files")
+	fs.BoolVar(&cfg.list, "l", false, "list files that would be converted")
+	fs.StringVar(&cfg.runtimePkg, "runtime", generator.DefaultRuntimePackage, "runtime package whose calls are converted")
+
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	cfg.paths = fs.Args()
+	if len(cfg.paths) == 0 {
+		cfg.paths = []string{"./..."}
+	}
+
+	files, err := findFiles(cfg.paths, isMigratableFile)
+	if err != nil {
+		return fmt.Errorf("finding files: %w", err)
+	}
+
+	var failed int
+	for _, file := range files {
+		if err := migrateFile(file, cfg); err != nil {
+			fmt.Fprintf(os.Stderr, "error: %s: %v\n", file, err)
+			failed++
+		}
+	}
+	if failed > 0 {
+		return fmt.Errorf("%d file(s) failed", failed)
+	}
+	return nil
+}
+
+// isMigratableFile reports whether path names a hand-written .go file.
+func isMigratableFile(path string) bool {
+	return strings.HasSuffix(path, ".go") && !isGeneratedFile(path)
+}
+
+// migratedPath returns the .gox file a .go file is converted to:
+// app.go -> app.gox, app_test.go -> app_test.gox.
+func migratedPath(file string) string {
+	return strings.TrimSuffix(file, ".go") + ".gox"
+}
+
+// migrateFile converts a single .go file and reports the result. Files
+// without anything to convert are left alone.
+func migrateFile(file string, cfg *migrateConfig) error {
+	src, err := os.ReadFile(file)
+	if err != nil {
+		return fmt.Errorf("reading file: %w", err)
+	}
+	out, err := migrateSource(file, src, cfg.runtimePkg)
+	if err != nil {
+		return err
+	}
+	if out == nil {
+		return nil
+	}
+
+	target := migratedPath(file)
+	switch {
+	case cfg.list:
+		fmt.Println(file)
+	case cfg.write:
+		if _, err := os.Stat(target); err == nil {
+			return fmt.Errorf("%s already exists", target)
+		}
+		if err := atomicfile.WriteFile(target, out, 0644); err != nil {
+			return fmt.Errorf("writing file: %w", err)
+		}
+		// The .go file would declare everything the .gox file generates again
+		if err := os.Remove(file); err != nil {
+			return err
+		}
+	default:
+		fmt.Print(unifiedDiff(file, target, src, out))
+	}
+	return nil
+}
+
+// migrateSource converts the VNode call trees of a .go source to JSX and
+// returns the formatted .gox source, or nil if there is nothing to convert.
+// Only trees that generate back to the same calls are converted: the rest,
+// such as elements with computed props, stay Go code, with any convertible
+// trees inside them converted.
+func migrateSource(file string, src []byte, runtimePkg string) ([]byte, error) {
+	fset := token.NewFileSet()
+	f, err := goparser.ParseFile(fset, file, src, goparser.ParseComments|goparser.SkipObjectResolution)
+	if err != nil {
+		return nil, err
+	}
+
+	m := &migrator{fset: fset, src: src, comments: f.Comments}
+	for _, imp := range f.Imports {
+		if importPath, err := strconv.Unquote(imp.Path.Value); err == nil && importPath == runtimePkg {
+			m.runtime = path.Base(importPath)
+			if imp.Name != nil {
+				m.runtime = imp.Name.Name
+			}
+		}
+	}
+	if m.runtime == "" || m.runtime == "_" || m.runtime == "." {
+		return nil, nil
+	}
+
+	// The whole file, with build constraints before the package clause
+	tf := fset.File(f.Pos())
+	converted, changed := m.convert(f, token.Pos(tf.Base()), token.Pos(tf.Base()+tf.Size()))
+	if !changed {
+		return nil, nil
+	}
+	out, err := formatter.Source([]byte(converted), nil)
+	if err != nil {
+		return nil, fmt.Errorf("converted source does not parse: %w", err)
+	}
+	return out, nil
+}
+
+// migrator converts VNode call trees of a parsed .go file to JSX.
+type migrator struct {
+	fset     *token.FileSet
+	src      []byte
+	comments []*ast.CommentGroup
+	runtime  string // Name the runtime package is imported as
+}
+
+// text returns the source between two positions.
+func (m *migrator) text(from, to token.Pos) string {
+	return string(m.src[m.fset.Position(from).Offset:m.fset.Position(to).Offset])
+}
+
+// convert returns the source of node between from and to, with every
+// outermost convertible call tree written as JSX, and whether any was.
+func (m *migrator) convert(node ast.Node, from, to token.Pos) (string, bool) {
+	var b strings.Builder
+	changed := false
+	ast.Inspect(node, func(n ast.Node) bool {
+		call, ok := n.(*ast.CallExpr)
+		if !ok || n == node {
+			return true
+		}
+		jsx, ok := m.jsx(call)
+		if !ok {
+			return true
+		}
+		b.WriteString(m.text(from, call.Pos()))
+		b.WriteString(jsx)
+		from, changed = call.End(), true
+		return false
+	})
+	b.WriteString(m.text(from, to))
+	return b.String(), changed
+}
+
+// expr returns the source of an expression with its call trees converted,
+// as JSX if it is one itself.
+func (m *migrator) expr(e ast.Expr) string {
+	if call, ok := e.(*ast.CallExpr); ok {
+		if jsx, ok := m.jsx(call); ok {
+			return jsx
+		}
+	}
+	s, _ := m.convert(e, e.Pos(), e.End())
+	return s
+}
+
+// runtimeCall reports whether call calls a function of the runtime package
+// and returns its name.
+func (m *migrator) runtimeCall(call *ast.CallExpr) (string, bool) {
+	sel, ok := call.Fun.(*ast.SelectorExpr)
+	if !ok {
+		return "", false
+	}
+	x, ok := sel.X.(*ast.Ident)
+	if !ok || x.Name != m.runtime {
+		return "", false
+	}
+	return sel.Sel.Name, true
+}
+
+// jsx returns a call tree written as JSX:
+//
+//	gox.Element("div", gox.Props{"class": "a"}, kids...)  <div class="a">...</div>
+//	gox.Fragment(kids...)                                 <>...</>
+//	gox.KeyedFragment(id, kids...)                        <Fragment key={id}>...</Fragment>
+//	Card(CardProps{Title: "x"}, kids...)                  <Card title="x">...</Card>
+//
+// It reports false if the call is not one of these, or cannot be written
+// as JSX exactly: props that are not a literal, spread children of a
+// fragment or component, or comments that JSX would drop.
+func (m *migrator) jsx(call *ast.CallExpr) (string, bool) {
+	var tag string
+	var attrs []string
+	var kept []ast.Node
+	children := call.Args
+
+	if name, ok := m.runtimeCall(call); ok {
+		switch name {
+		case "Element", "E":
+			if len(call.Args) < 2 {
+				return "", false
+			}
+			tag, ok = intrinsicTag(call.Args[0])
+			if !ok {
+				return "", false
+			}
+			attrs, kept, ok = m.props(call.Args[1])
+			if !ok {
+				return "", false
+			}
+			children = call.Args[2:]
+		case "Fragment":
+			if call.Ellipsis.IsValid() {
+				return "", false
+			}
+		case "KeyedFragment":
+			if len(call.Args) < 1 || call.Ellipsis.IsValid() {
+				return "", false
+			}
+			tag = "Fragment"
+			attrs = []string{"key={" + m.expr(call.Args[0]) + "}"}
+			kept = []ast.Node{call.Args[0]}
+			children = call.Args[1:]
+		default:
+			return "", false
+		}
+	} else if qualifier, name, ok := componentCall(call); ok && qualifier == "" && name != "Fragment" && !call.Ellipsis.IsValid() {
+		// <Fragment> is a fragment, so a component named Fragment stays a call
+		// Generic components, Card[T](CardProps[T]{...}), are left as calls
+		lit := call.Args[0].(*ast.CompositeLit)
+		if _, plain := lit.Type.(*ast.Ident); !plain {
+			return "", false
+		}
+		tag = name
+		attrs, kept, ok = m.fields(lit)
+		if !ok {
+			return "", false
+		}
+		children = call.Args[1:]
+	} else {
+		return "", false
+	}
+
+	// Spread element children are the children prop:
+	// gox.Element("ul", nil, items...) <ul children={items} />
+	if call.Ellipsis.IsValid() {
+		spread := children[len(children)-1]
+		children = children[:len(children)-1]
+		value := spread
+		if inner, ok := spread.(*ast.CallExpr); ok && len(inner.Args) == 1 {
+			if name, ok := m.runtimeCall(inner); ok && name == "Children" {
+				value = inner.Args[0]
+			}
+		}
+		if len(children) > 0 {
+			return "", false
+		}
+		attrs = append(attrs, "children={"+m.expr(value)+"}")
+		kept = append(kept, value)
+	}
+
+	body, ok := m.children(children)
+	if !ok {
+		return "", false
+	}
+	for _, c := range children {
+		kept = append(kept, c)
+	}
+	if m.dropsComments(call, kept) {
+		return "", false
+	}
+
+	open := tag
+	if len(attrs) > 0 {
+		open += " " + strings.Join(attrs, " ")
+	}
+	switch {
+	case tag == "" && body == "":
+		return "<></>", true
+	case tag == "":
+		return "<>" + body + "</>", true
+	case body == "":
+		return "<" + open + " />", true
+	}
+	return "<" + open + ">" + body + "</" + tag + ">", true
+}
+
+// intrinsicTag returns the tag of an element type that is a string literal
+// naming an intrinsic element: lower case, so JSX does not take it for a
+// component.
+func intrinsicTag(typ ast.Expr) (string, bool) {
+	lit, ok := typ.(*ast.BasicLit)
+	if !ok || lit.Kind != token.STRING {
+		return "", false
+	}
+	tag, err := strconv.Unquote(lit.Value)
+	if err != nil || !isRuleIdent(tag) {
+		return "", false
+	}
+	r, _ := utf8.DecodeRuneInString(tag)
+	return tag, unicode.IsLower(r)
+}
+
+// props returns the attributes of intrinsic element props: nil, a Props
+// literal with constant keys, or MergeProps of such literals and other
+// props, which are spread. It also returns the nodes whose source the
+// attributes keep.
+func (m *migrator) props(props ast.Expr) ([]string, []ast.Node, bool) {
+	if id, ok := props.(*ast.Ident); ok && id.Name == "nil" {
+		return nil, nil, true
+	}
+	if call, ok := props.(*ast.CallExpr); ok && !call.Ellipsis.IsValid() {
+		if fn, ok := m.runtimeCall(call); ok && fn == "MergeProps" {
+			var attrs []string
+			var kept []ast.Node
+			for _, arg := range call.Args {
+				a, k, ok := m.props(arg)
+				if !ok {
+					// gox.MergeProps(a, gox.Props{"x": "1"}) <div {...a} x="1" />
+					a, k = []string{"{..." + m.expr(arg) + "}"}, []ast.Node{arg}
+				}
+				attrs = append(attrs, a...)
+				kept = append(kept, k...)
+			}
+			return attrs, kept, true
+		}
+	}
+	lit, ok := props.(*ast.CompositeLit)
+	if !ok {
+		return nil, nil, false
+	}
+	sel, ok := lit.Type.(*ast.SelectorExpr)
+	if !ok || sel.Sel.Name != "Props" {
+		return nil, nil, false
+	}
+	if x, ok := sel.X.(*ast.Ident); !ok || x.Name != m.runtime {
+		return nil, nil, false
+	}
+
+	var attrs []string
+	var kept []ast.Node
+	for _, elt := range lit.Elts {
+		kv, ok := elt.(*ast.KeyValueExpr)
+		if !ok {
+			return nil, nil, false
+		}
+		key, ok := kv.Key.(*ast.BasicLit)
+		if !ok || key.Kind != token.STRING {
+			return nil, nil, false
+		}
+		name, err := strconv.Unquote(key.Value)
+		if err != nil || !isRuleIdent(name) || name == "children" {
+			return nil, nil, false
+		}
+		// "title": gox.Optional(title) is the optional attribute title?={title}
+		if call, ok := kv.Value.(*ast.CallExpr); ok && len(call.Args) == 1 && !call.Ellipsis.IsValid() {
+			if fn, ok := m.runtimeCall(call); ok && fn == "Optional" {
+				attrs = append(attrs, name+"?={"+m.expr(call.Args[0])+"}")
+				kept = append(kept, call.Args[0])
+				continue
+			}
+		}
+		attrs = append(attrs, m.attr(name, kv.Value))
+		kept = append(kept, kv.Value)
+	}
+	return attrs, kept, true
+}
+
+// fields returns the attributes of a component's props literal, whose
+// fields the generator names by capitalizing the attributes.
+func (m *migrator) fields(lit *ast.CompositeLit) ([]string, []ast.Node, bool) {
+	var attrs []string
+	var kept []ast.Node
+	for _, elt := range lit.Elts {
+		kv, ok := elt.(*ast.KeyValueExpr)
+		if !ok {
+			return nil, nil, false
+		}
+		key, ok := kv.Key.(*ast.Ident)
+		if !ok {
+			return nil, nil, false
+		}
+		attrs = append(attrs, m.attr(attrName(key.Name), kv.Value))
+		kept = append(kept, kv.Value)
+	}
+	return attrs, kept, true
+}
+
+// attrName returns the attribute for a props field: Title -> title, with
+// initialisms such as ID and URL kept as they are.
+func attrName(field string) string {
+	r, size := utf8.DecodeRuneInString(field)
+	if next, _ := utf8.DecodeRuneInString(field[size:]); unicode.IsUpper(next) {
+		return field
+	}
+	return string(unicode.ToLower(r)) + field[size:]
+}
+
+// attr returns an attribute: a bare name for true, a string attribute for
+// strings JSX can hold unescaped, and an expression otherwise.
+func (m *migrator) attr(name string, value ast.Expr) string {
+	if id, ok := value.(*ast.Ident); ok && id.Name == "true" {
+		return name
+	}
+	if s, ok := stringLiteral(value); ok && !strings.ContainsAny(s, "\"\\\n") {
+		return name + `="` + s + `"`
+	}
+	return name + "={" + m.expr(value) + "}"
+}
+
+// stringLiteral returns the value of a string literal.
+func stringLiteral(e ast.Expr) (string, bool) {
+	lit, ok := e.(*ast.BasicLit)
+	if !ok || lit.Kind != token.STRING {
+		return "", false
+	}
+	s, err := strconv.Unquote(lit.Value)
+	return s, err == nil
+}
+
+// Kinds of converted children, which decide how text is written.
+const (
+	childNode = iota // An element or fragment
+	childText        // Literal text
+	childExpr        // An expression
+)
+
+// children returns the JSX children for the children of a call. Literal
+// text is written as text unless it borders on other text or expressions,
+// which JSX would merge into a single gox.Textf call; it is then written as
+// a string expression.
+func (m *migrator) children(args []ast.Expr) (string, bool) {
+	kinds := make([]int, len(args))
+	parts := make([]string, len(args))
+	for i, arg := range args {
+		kind, part, ok := m.child(arg)
+		if !ok {
+			return "", false
+		}
+		kinds[i], parts[i] = kind, part
+	}
+
+	var b strings.Builder
+	for i, part := range parts {
+		if kinds[i] == childText {
+			bordered := i > 0 && kinds[i-1] != childNode || i < len(kinds)-1 && kinds[i+1] != childNode
+			if !bordered {
+				b.WriteString(part)
+				continue
+			}
+			lit := args[i].(*ast.CallExpr).Args[0]
+			part = "{" + m.text(lit.Pos(), lit.End()) + "}"
+		}
+		b.WriteString(part)
+	}
+	return b.String(), true
+}
+
+// child converts a single child:
+//
+//	gox.Element(...), Card(...)   <div>...</div>, <Card />
+//	gox.Text("Hello")             Hello
+//	gox.V(x)                      {x}
+//	gox.When(ok, node)            {ok && node}
+//	anything else                 {expr}
+func (m *migrator) child(arg ast.Expr) (int, string, bool) {
+	call, isCall := arg.(*ast.CallExpr)
+	if isCall {
+		if jsx, ok := m.jsx(call); ok {
+			return childNode, jsx, true
+		}
+	}
+
+	expr := ""
+	if name, ok := m.runtimeCall(call); isCall && ok && !call.Ellipsis.IsValid() {
+		switch {
+		case name == "Text" && len(call.Args) == 1:
+			if s, ok := stringLiteral(call.Args[0]); ok && isJSXText(s) {
+				return childText, s, true
+			}
+		case name == "V" && len(call.Args) == 1:
+			expr = m.expr(call.Args[0])
+		case name == "When" && len(call.Args) == 2:
+			// The generator splits at the first &&
+			if cond := m.expr(call.Args[0]); !strings.Contains(cond, "&&") {
+				return childExpr, "{" + cond + " && " + m.expr(call.Args[1]) + "}", true
+			}
+		}
+	}
+	if expr == "" {
+		expr = m.expr(arg)
+	}
+	// An expression child with && would be taken for a condition
+	if strings.Contains(expr, " && ") {
+		return 0, "", false
+	}
+	return childExpr, "{" + expr + "}", true
+}
+
+// isJSXText reports whether s can be written as JSX text and generates
+// gox.Text(s) back: it has no surrounding whitespace, runs of whitespace
+// that JSX would collapse, or characters that start tags and expressions.
+func isJSXText(s string) bool {
+	return s != "" && s == strings.TrimSpace(s) && !strings.ContainsAny(s, "<>{}\t\r\n") && !strings.Contains(s, "  ")
+}
+
+// dropsComments reports whether a comment within call lies outside every
+// kept node, so converting the call would drop it.
+func (m *migrator) dropsComments(call *ast.CallExpr, kept []ast.Node) bool {
+	for _, c := range m.comments {
+		if c.Pos() < call.Pos() || c.End() > call.End() {
+			continue
+		}
+		inside := false
+		for _, n := range kept {
+			if c.Pos() >= n.Pos() && c.End() <= n.End() {
+				inside = true
+				break
+			}
+		}
+		if !inside {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,151 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/germtb/gox/generator"
+	"github.com/germtb/gox/parser"
+)
+
+func TestMigrateSource(t *testing.T) {
+	const header = "package ui\n\nimport \"github.com/germtb/gox\"\n\n"
+	tests := []struct {
+		name string
+		src  string
+		want string // "" if nothing is converted
+	}{
+		{
+			name: "element tree",
+			src:  "func A(name string) gox.VNode {\n\treturn gox.Element(\"div\", gox.Props{\"class\": \"card\", \"id\": name, \"hidden\": true},\n\t\tgox.Element(\"h1\", nil, gox.Text(\"Hello\")),\n\t\tgox.V(name))\n}\n",
+			want: "func A(name string) gox.VNode {\n\treturn <div\n\t\tclass=\"card\"\n\t\tid={name}\n\t\thidden={true}>\n\t\t<h1>Hello</h1>\n\t\t{name}\n\t</div>\n}\n",
+		},
+		{
+			name: "fragment, component and condition",
+			src:  "func A(ok bool) gox.VNode {\n\treturn gox.Fragment(Card(CardProps{Title: \"x\", ID: 1}), gox.When(ok, gox.E(\"br\", nil)))\n}\n",
+			want: "func A(ok bool) gox.VNode {\n\treturn <>\n\t\t<Card title=\"x\" ID={1} />\n\t\t{ok && <br />}\n\t</>\n}\n",
+		},
+		{
+			name: "keyed fragment",
+			src:  "func A(id int) gox.VNode {\n\treturn gox.KeyedFragment(id, gox.E(\"dt\", nil), gox.E(\"dd\", nil))\n}\n",
+			want: "func A(id int) gox.VNode {\n\treturn <Fragment key={id}>\n\t\t<dt />\n\t\t<dd />\n\t</Fragment>\n}\n",
+		},
+		{
+			name: "optional attribute",
+			src:  "func A(title *string) gox.VNode {\n\treturn gox.Element(\"p\", gox.Props{\"title\": gox.Optional(title)})\n}\n",
+			want: "func A(title *string) gox.VNode {\n\treturn <p title?={title} />\n}\n",
+		},
+		{
+			name: "merged props",
+			src:  "func A(p gox.Props) gox.VNode {\n\treturn gox.Element(\"p\", gox.MergeProps(p, gox.Props{\"id\": \"x\"}))\n}\n",
+			want: "func A(p gox.Props) gox.VNode {\n\treturn <p {...p} id=\"x\" />\n}\n",
+		},
+		{
+			name: "a component named Fragment stays a call",
+			src:  "func A() gox.VNode {\n\treturn Fragment(FragmentProps{Title: \"x\"})\n}\n",
+		},
+		{
+			name: "text next to expressions stays a string",
+			src:  "func A(name string) gox.VNode {\n\treturn gox.Element(\"p\", nil, gox.Text(\"Hi\"), gox.V(name))\n}\n",
+			want: "func A(name string) gox.VNode {\n\treturn <p>{\"Hi\"}{name}</p>\n}\n",
+		},
+		{
+			name: "spread children",
+			src:  "func A(items []gox.VNode) gox.VNode {\n\treturn gox.Element(\"ul\", nil, items...)\n}\n",
+			want: "func A(items []gox.VNode) gox.VNode {\n\treturn <ul children={items} />\n}\n",
+		},
+		{
+			name: "trees inside other code",
+			src:  "func A(props gox.Props) gox.VNode {\n\treturn gox.Element(\"div\", props, gox.Element(\"hr\", nil))\n}\n",
+			want: "func A(props gox.Props) gox.VNode {\n\treturn gox.Element(\"div\", props, <hr />)\n}\n",
+		},
+		{
+			name: "component tags are not intrinsic",
+			src:  "func A() gox.VNode {\n\treturn gox.Element(\"Card\", nil)\n}\n",
+		},
+		{
+			name: "comments between arguments",
+			src:  "func A() gox.VNode {\n\treturn gox.Element(\"div\", nil, // wrapper\n\t\tgox.Text(\"x\"))\n}\n",
+		},
+		{
+			name: "escaped attribute strings become expressions",
+			src:  "func A() gox.VNode {\n\treturn gox.Element(\"a\", gox.Props{\"title\": \"say \\\"hi\\\"\"})\n}\n",
+			want: "func A() gox.VNode {\n\treturn <a title={\"say \\\"hi\\\"\"} />\n}\n",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := migrateSource("a.go", []byte(header+tt.src), generator.DefaultRuntimePackage)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if tt.want == "" {
+				if got != nil {
+					t.Fatalf("expected no conversion, got:\n%s", got)
+				}
+				return
+			}
+			if string(got) != header+tt.want {
+				t.Errorf("got:\n%s\nwant:\n%s", got, header+tt.want)
+			}
+			file, err := parser.Parse("a.gox", got)
+			if err != nil {
+				t.Fatalf("migrated file does not parse: %v", err)
+			}
+			if _, _, err := generator.Generate(file, nil); err != nil {
+				t.Errorf("migrated file does not generate: %v", err)
+			}
+		})
+	}
+}
+
+func TestMigrateSourceRuntime(t *testing.T) {
+	src := "package ui\n\nimport vdom \"example.com/vdom\"\n\nvar A = vdom.Element(\"hr\", nil)\n"
+	got, err := migrateSource("a.go", []byte(src), "example.com/vdom")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(got), "var A = <hr />") {
+		t.Errorf("got:\n%s", got)
+	}
+
+	// Calls of another package are left alone
+	if got, err := migrateSource("a.go", []byte(src), generator.DefaultRuntimePackage); err != nil || got != nil {
+		t.Errorf("got %q, %v; want no conversion", got, err)
+	}
+}
+
+func TestMigrateFileWrite(t *testing.T) {
+	dir := t.TempDir()
+	file := filepath.Join(dir, "a_test.go")
+	src := "package ui\n\nimport \"github.com/germtb/gox\"\n\nvar A = gox.Element(\"hr\", nil)\n"
+	if err := os.WriteFile(file, []byte(src), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	cfg := &migrateConfig{write: true, runtimePkg: generator.DefaultRuntimePackage}
+	if err := migrateFile(file, cfg); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(file); !os.IsNotExist(err) {
+		t.Errorf("%s was not removed", file)
+	}
+	got, err := os.ReadFile(filepath.Join(dir, "a_test.gox"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(got), "var A = <hr />") {
+		t.Errorf("got:\n%s", got)
+	}
+
+	// An existing .gox file is not overwritten
+	if err := os.WriteFile(file, []byte(src), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := migrateFile(file, cfg); err == nil {
+		t.Error("expected an error for an existing .gox file")
+	}
+}
@@ -0,0 +1,183 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/germtb/gox/generator"
+	"github.com/germtb/gox/internal/atomicfile"
+)
+
+// Cached files unused for cacheMaxAge are removed, at most once per
+// cacheTrimInterval.
+const (
+	cacheMaxAge       = 7 * 24 * time.Hour
+	cacheTrimInterval = 24 * time.Hour
+)
+
+// overlayCache keeps the generated files of the overlay between invocations,
+// so back-to-back gox test and gox build reuse them instead of regenerating
+// every .gox file. Files are keyed by a hash of everything the output depends
+// on, so a stale entry is never used, and written atomically, so concurrent
+// invocations can share the cache.
+type overlayCache struct {
+	dir   string // Per module
+	stamp string // Identifies the gox binary, whose generator made the files
+}
+
+// openOverlayCache returns the cache for the module containing the working
+// directory, in GOX_CACHE or else the user cache directory. It returns nil
+// if GOX_CACHE is "off" or there is no usable cache directory: gox then
+// generates into a temporary directory as before.
+func openOverlayCache() *overlayCache {
+	root := os.Getenv("GOX_CACHE")
+	if root == "off" {
+		return nil
+	}
+	dir, err := moduleCacheDir(root, "overlay")
+	if err != nil {
+		return nil
+	}
+
+	stamp, err := binaryStamp()
+	if err != nil {
+		return nil
+	}
+	return &overlayCache{dir: dir, stamp: stamp}
+}
+
+// moduleCacheDir creates and returns the kind directory of the module
+// containing the working directory under root, or under gox in the user
+// cache directory if root is empty.
+func moduleCacheDir(root, kind string) (string, error) {
+	if root == "" {
+		userCache, err := os.UserCacheDir()
+		if err != nil {
+			return "", err
+		}
+		root = filepath.Join(userCache, "gox")
+	}
+
+	cwd, err := os.Getwd()
+	if err != nil {
+		return "", err
+	}
+	moduleDir := cwd
+	if mod := findModule(cwd); mod.dir != "" {
+		moduleDir = mod.dir
+	}
+	sum := sha256.Sum256([]byte(moduleDir))
+	dir := filepath.Join(root, kind, hex.EncodeToString(sum[:8]))
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return "", err
+	}
+	return dir, nil
+}
+
+// binaryStamp identifies the running gox binary by its path, size and
+// modification time, which change whenever it is rebuilt.
+func binaryStamp() (string, error) {
+	exe, err := os.Executable()
+	if err != nil {
+		return "", err
+	}
+	info, err := os.Stat(exe)
+	if err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("%s %s %d %d", version, exe, info.Size(), info.ModTime().UnixNano()), nil
+}
+
+// key returns the cache key of a .gox file: its output depends on the gox
+// binary, the runtime package, the file's path (for its build constraints
+// and source map) and its content.
+func (c *overlayCache) key(absInput, runtimePkg string, src []byte) string {
+	h := sha256.New()
+	for _, part := range []string{c.stamp, runtimePkg, absInput} {
+		io.WriteString(h, part)
+		h.Write([]byte{0})
+	}
+	h.Write(src)
+	return hex.EncodeToString(h.Sum(nil))[:32]
+}
+
+// path returns the cached generated file for key. Its source map is next to
+// it, with a .map suffix, which is how errors in the file are remapped
+// once the overlay that used it is gone.
+func (c *overlayCache) path(key string) string {
+	return filepath.Join(c.dir, key+"_gox.go")
+}
+
+// get returns the cached generated file for key and its source map, marking
+// the entry as used.
+func (c *overlayCache) get(key string) (string, *generator.SourceMap, bool) {
+	file := c.path(key)
+	data, err := os.ReadFile(file + ".map")
+	if err != nil {
+		return "", nil, false
+	}
+	sourceMap, err := generator.FromJSON(data)
+	if err != nil {
+		return "", nil, false
+	}
+	if _, err := os.Stat(file); err != nil {
+		return "", nil, false
+	}
+	now := time.Now()
+	os.Chtimes(file, now, now)
+	return file, sourceMap, true
+}
+
+// put stores a generated file and its source map under key and returns the
+// path of the file. The map is written last, since get looks it up first.
+func (c *overlayCache) put(key string, output []byte, sourceMap *generator.SourceMap) (string, error) {
+	file := c.path(key)
+	sourceMapData, err := sourceMap.ToJSON()
+	if err != nil {
+		return "", fmt.Errorf("serializing source map: %w", err)
+	}
+	if err := atomicfile.WriteFile(file, output, 0644); err != nil {
+		return "", err
+	}
+	if err := atomicfile.WriteFile(file+".map", sourceMapData, 0644); err != nil {
+		return "", err
+	}
+	return file, nil
+}
+
+// trim removes the entries unused for cacheMaxAge, unless the cache was
+// trimmed within cacheTrimInterval.
+func (c *overlayCache) trim() {
+	marker := filepath.Join(c.dir, "trim.txt")
+	if info, err := os.Stat(marker); err == nil && time.Since(info.ModTime()) < cacheTrimInterval {
+		return
+	}
+	if err := os.WriteFile(marker, nil, 0644); err != nil {
+		return
+	}
+
+	entries, err := os.ReadDir(c.dir)
+	if err != nil {
+		return
+	}
+	for _, e := range entries {
+		// Maps go with their file, whose modification time get updates;
+		// anything else is a file or a temporary file left by a failed write
+		name := e.Name()
+		if strings.HasSuffix(name, ".map") || name == "trim.txt" {
+			continue
+		}
+		info, err := e.Info()
+		if err != nil || time.Since(info.ModTime()) < cacheMaxAge {
+			continue
+		}
+		os.Remove(filepath.Join(c.dir, name+".map"))
+		os.Remove(filepath.Join(c.dir, name))
+	}
+}
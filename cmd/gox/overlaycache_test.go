@@ -0,0 +1,100 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/germtb/gox/generator"
+)
+
+func TestOverlayCacheKey(t *testing.T) {
+	c := &overlayCache{dir: t.TempDir(), stamp: "gox 1"}
+	base := c.key("/src/a.gox", "github.com/germtb/gox", []byte("package a"))
+
+	for name, key := range map[string]string{
+		"content": c.key("/src/a.gox", "github.com/germtb/gox", []byte("package b")),
+		"path":    c.key("/src/b.gox", "github.com/germtb/gox", []byte("package a")),
+		"runtime": c.key("/src/a.gox", "example.com/vdom", []byte("package a")),
+		"binary":  (&overlayCache{stamp: "gox 2"}).key("/src/a.gox", "github.com/germtb/gox", []byte("package a")),
+	} {
+		if key == base {
+			t.Errorf("key does not change with the %s", name)
+		}
+	}
+	if again := c.key("/src/a.gox", "github.com/germtb/gox", []byte("package a")); again != base {
+		t.Errorf("key is not stable: %s, then %s", base, again)
+	}
+}
+
+func TestOverlayCacheGetPut(t *testing.T) {
+	c := &overlayCache{dir: t.TempDir(), stamp: "gox 1"}
+	key := c.key("/src/a.gox", "", []byte("package a"))
+	if _, _, ok := c.get(key); ok {
+		t.Fatal("get hit in an empty cache")
+	}
+
+	sm := generator.NewSourceMap()
+	sm.SetFiles("/src/a.gox", "/src/a_gox.go")
+	sm.AddMapping(2, 3, 4, 5)
+	file, err := c.put(key, []byte("package a\n"), sm)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !positionPattern.MatchString(file + ":1:1") {
+		t.Errorf("cached file %s is not remapped as a generated file", file)
+	}
+
+	got, gotMap, ok := c.get(key)
+	if !ok || got != file {
+		t.Fatalf("get = %q, %v; want %q", got, ok, file)
+	}
+	if data, err := os.ReadFile(got); err != nil || string(data) != "package a\n" {
+		t.Errorf("cached file = %q, %v", data, err)
+	}
+	if gotMap.SourceFile != "/src/a.gox" {
+		t.Errorf("SourceFile = %q, want /src/a.gox", gotMap.SourceFile)
+	}
+	if pos, ok := gotMap.SourcePositionFromTarget(4, 5); !ok || pos.Line != 2 || pos.Column != 3 {
+		t.Errorf("SourcePositionFromTarget(4, 5) = %+v, %v; want 2:3", pos, ok)
+	}
+}
+
+func TestOverlayCacheTrim(t *testing.T) {
+	c := &overlayCache{dir: t.TempDir(), stamp: "gox 1"}
+	sm := generator.NewSourceMap()
+	oldFile, err := c.put("old", []byte("package a\n"), sm)
+	if err != nil {
+		t.Fatal(err)
+	}
+	newFile, err := c.put("new", []byte("package a\n"), sm)
+	if err != nil {
+		t.Fatal(err)
+	}
+	past := time.Now().Add(-cacheMaxAge - time.Hour)
+	if err := os.Chtimes(oldFile, past, past); err != nil {
+		t.Fatal(err)
+	}
+
+	c.trim()
+	for _, f := range []string{oldFile, oldFile + ".map"} {
+		if _, err := os.Stat(f); !os.IsNotExist(err) {
+			t.Errorf("%s was not trimmed", filepath.Base(f))
+		}
+	}
+	for _, f := range []string{newFile, newFile + ".map"} {
+		if _, err := os.Stat(f); err != nil {
+			t.Errorf("%s was trimmed: %v", filepath.Base(f), err)
+		}
+	}
+
+	// Trimmed at most once per interval
+	if err := os.Chtimes(newFile, past, past); err != nil {
+		t.Fatal(err)
+	}
+	c.trim()
+	if _, err := os.Stat(newFile); err != nil {
+		t.Errorf("trimmed again within cacheTrimInterval: %v", err)
+	}
+}
@@ -0,0 +1,128 @@
+package main
+
+import (
+	"errors"
+	"go/build"
+	"go/parser"
+	"go/token"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/germtb/gox/diag"
+	"github.com/germtb/gox/generator"
+)
+
+// isModuleRoot reports whether dir holds a go.mod, starting a module of its
+// own. Recursive patterns do not cross into nested modules, as with go.
+func isModuleRoot(dir string) bool {
+	_, err := os.Stat(filepath.Join(dir, "go.mod"))
+	return err == nil
+}
+
+// isGeneratedFile reports whether path names a file generated from a .gox
+// file, using the suffix configured for its directory.
+func isGeneratedFile(path string) bool {
+	return generator.IsGeneratedName(filepath.Base(path), outputSuffix(filepath.Dir(path)))
+}
+
+// goxPackageName returns the package a .gox file declares and the position
+// of the name. Only the header is parsed, which is Go up to the package
+// clause.
+func goxPackageName(fset *token.FileSet, path string) (string, token.Position, error) {
+	src, err := os.ReadFile(path)
+	if err != nil {
+		return "", token.Position{}, err
+	}
+	f, err := parser.ParseFile(fset, path, src, parser.PackageClauseOnly)
+	if err != nil {
+		return "", token.Position{}, err
+	}
+	return f.Name.Name, fset.Position(f.Name.Pos()), nil
+}
+
+// packageConflicts reports the .gox files that declare a different package
+// than their directory: the package of its .go files in ctxt, or, without
+// any, of its first .gox file. Files ending in _test.gox may declare the
+// external test package. Generated files are not counted, since they may be
+// stale, and files that cannot be parsed are left for generation to report.
+// files should already be filtered by ctxt.
+func packageConflicts(ctxt *build.Context, files []string) []error {
+	byDir := make(map[string][]string)
+	var dirs []string
+	for _, f := range files {
+		dir := filepath.Dir(f)
+		if byDir[dir] == nil {
+			dirs = append(dirs, dir)
+		}
+		byDir[dir] = append(byDir[dir], f)
+	}
+
+	c := *ctxt
+	c.ReadDir = func(dir string) ([]fs.FileInfo, error) {
+		entries, err := os.ReadDir(dir)
+		if err != nil {
+			return nil, err
+		}
+		var infos []fs.FileInfo
+		for _, e := range entries {
+			if isGeneratedFile(filepath.Join(dir, e.Name())) {
+				continue
+			}
+			info, err := e.Info()
+			if err != nil {
+				return nil, err
+			}
+			infos = append(infos, info)
+		}
+		return infos, nil
+	}
+
+	var conflicts []error
+	fset := token.NewFileSet()
+	for _, dir := range dirs {
+		goxFiles := byDir[dir]
+		sort.Strings(goxFiles)
+
+		want, wantFrom := "", ""
+		pkg, err := c.ImportDir(dir, 0)
+		var noGo *build.NoGoError
+		switch {
+		case err == nil:
+			want = pkg.Name
+			wantFrom = filepath.Join(dir, firstOf(pkg.GoFiles, pkg.TestGoFiles, pkg.CgoFiles))
+		case errors.As(err, &noGo):
+		default:
+			// Conflicts between .go files are go's to report
+			continue
+		}
+
+		for _, f := range goxFiles {
+			name, pos, err := goxPackageName(fset, f)
+			if err != nil {
+				continue
+			}
+			if want == "" {
+				want, wantFrom = strings.TrimSuffix(name, "_test"), f
+			}
+			if name == want || name == want+"_test" && strings.HasSuffix(f, "_test.gox") {
+				continue
+			}
+			conflicts = append(conflicts, diag.New(diag.PackageMismatch, f, pos.Line, pos.Column,
+				"package %s; expected package %s, as in %s", name, want, filepath.Base(wantFrom)))
+		}
+	}
+	return conflicts
+}
+
+// firstOf returns the first name in the first non-empty list.
+func firstOf(lists ...[]string) string {
+	for _, list := range lists {
+		if len(list) > 0 {
+			return list[0]
+		}
+	}
+	return ""
+}
@@ -0,0 +1,99 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"testing"
+
+	"github.com/germtb/gox/diag"
+)
+
+// writeTree writes files, by slash-separated path, under dir.
+func writeTree(t *testing.T, dir string, files map[string]string) {
+	t.Helper()
+	for name, content := range files {
+		path := filepath.Join(dir, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func TestPackageConflicts(t *testing.T) {
+	dir := t.TempDir()
+	writeTree(t, dir, map[string]string{
+		"ui/list.go":           "package ui\n",
+		"ui/list_gox.go":       "package stale\n",
+		"ui/view.gox":          "package ui\n",
+		"ui/wrong.gox":         "// Package views is misplaced.\npackage views\n",
+		"ui/view_test.gox":     "package ui_test\n",
+		"ui/other.gox":         "package ui_test\n",
+		"pages/home.gox":       "package pages\n",
+		"pages/about.gox":      "package about\n",
+		"pages/ignored.go":     "//go:build ignore\n\npackage main\n",
+		"broken/syntax.gox":    "packag broken\n",
+		"conflicting/a.go":     "package a\n",
+		"conflicting/b.go":     "package b\n",
+		"conflicting/view.gox": "package c\n",
+	})
+	var files []string
+	for _, name := range []string{
+		"ui/view.gox", "ui/wrong.gox", "ui/view_test.gox", "ui/other.gox",
+		"pages/home.gox", "pages/about.gox", "broken/syntax.gox", "conflicting/view.gox",
+	} {
+		files = append(files, filepath.Join(dir, filepath.FromSlash(name)))
+	}
+
+	var got []string
+	for _, err := range packageConflicts(goxBuildContext(""), files) {
+		d, ok := diag.As(err)
+		if !ok || d.Code != diag.PackageMismatch {
+			t.Errorf("expected a %s diagnostic, got %v", diag.PackageMismatch, err)
+			continue
+		}
+		rel, _ := filepath.Rel(dir, d.File)
+		got = append(got, filepath.ToSlash(rel)+": "+d.Message)
+	}
+	sort.Strings(got)
+	want := []string{
+		"pages/home.gox: package pages; expected package about, as in about.gox",
+		"ui/other.gox: package ui_test; expected package ui, as in list.go",
+		"ui/wrong.gox: package views; expected package ui, as in list.go",
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got:\n%q\nwant:\n%q", got, want)
+	}
+}
+
+func TestFindGoxFilesStopsAtModules(t *testing.T) {
+	dir := t.TempDir()
+	writeTree(t, dir, map[string]string{
+		"go.mod":            "module example.com/app\n",
+		"app.gox":           "package app\n",
+		"ui/view.gox":       "package ui\n",
+		"tools/go.mod":      "module example.com/tools\n",
+		"tools/gen/gen.gox": "package gen\n",
+	})
+	files, err := findGoxFiles([]string{filepath.ToSlash(dir) + "/..."})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []string{filepath.Join(dir, "app.gox"), filepath.Join(dir, "ui", "view.gox")}
+	if !reflect.DeepEqual(files, want) {
+		t.Errorf("findGoxFiles(dir/...) = %q, want %q", files, want)
+	}
+
+	// A nested module is searched from its own root
+	files, err = findGoxFiles([]string{filepath.ToSlash(dir) + "/tools/..."})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := []string{filepath.Join(dir, "tools", "gen", "gen.gox")}; !reflect.DeepEqual(files, want) {
+		t.Errorf("findGoxFiles(dir/tools/...) = %q, want %q", files, want)
+	}
+}
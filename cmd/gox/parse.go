@@ -0,0 +1,178 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/germtb/gox/ast"
+	"github.com/germtb/gox/parser"
+)
+
+// runParse runs the parse command: print the syntax tree of .gox files, as
+// an outline or, with -json, as JSON for tools that cannot import the ast
+// package. With -trivia the tree is lossless, as from parser.Trivia, and
+// with -strict intrinsic tags that are not element names are errors.
+func runParse(args []string) error {
+	asJSON := false
+	trivia := false
+	strict := false
+
+	fs := flag.NewFlagSet("parse", flag.ExitOnError)
+	fs.BoolVar(&asJSON, "json", false, "print the tree of each file as one JSON object")
+	fs.BoolVar(&trivia, "trivia", false, "keep the whitespace in tags and the spelling of attributes in the tree")
+	fs.BoolVar(&strict, "strict", false, "report intrinsic tags that are not element names, such as <card_item>")
+
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if fs.NArg() == 0 {
+		return fmt.Errorf("usage: gox parse [-json] [-trivia] [-strict] file.gox ...")
+	}
+	opts := &parser.Options{StrictIntrinsics: strict}
+	if trivia {
+		opts.Mode = parser.Trivia
+	}
+
+	files, err := findGoxFiles(fs.Args())
+	if err != nil {
+		return fmt.Errorf("finding files: %w", err)
+	}
+
+	var errs []fileError
+	enc := json.NewEncoder(os.Stdout)
+	for _, file := range files {
+		src, err := os.ReadFile(file)
+		if err != nil {
+			return err
+		}
+		tree, err := parser.ParseWithOptions(file, src, opts)
+		if err != nil {
+			errs = append(errs, fileError{file, err})
+			continue
+		}
+		if asJSON {
+			if err := enc.Encode(tree); err != nil {
+				return err
+			}
+			continue
+		}
+		printTree(os.Stdout, tree)
+	}
+
+	if len(errs) > 0 {
+		if asJSON {
+			for _, e := range errs {
+				writeDiagnostics(os.Stderr, errorDiagnostic(e.file, e.err))
+			}
+			return errDiagnosticsReported
+		}
+		writeFileErrors(os.Stderr, errs, 0)
+		return fmt.Errorf("%d file(s) failed", len(errs))
+	}
+	return nil
+}
+
+// printTree prints an outline of the tree of file, one node per line,
+// indented by depth, with its range.
+func printTree(w io.Writer, file *ast.GoxFile) {
+	ast.Walk(&treePrinter{w: w}, file)
+}
+
+// treePrinter is the ast.Visitor of printTree.
+type treePrinter struct {
+	w     io.Writer
+	depth int
+}
+
+func (p *treePrinter) Visit(node ast.Node) ast.Visitor {
+	if node == nil {
+		p.depth--
+		return nil
+	}
+	fmt.Fprintf(p.w, "%s%s %s\n", strings.Repeat("  ", p.depth), formatRange(node.GetRange()), describeNode(node))
+	p.depth++
+	return p
+}
+
+// formatRange formats r as "line:col-line:col", or "-" if it is unset.
+func formatRange(r ast.Range) string {
+	if !r.IsValid() {
+		return "-"
+	}
+	return fmt.Sprintf("%d:%d-%d:%d", r.Start.Line, r.Start.Column, r.End.Line, r.End.Column)
+}
+
+// describeNode returns the type of node and a short summary of its source.
+func describeNode(node ast.Node) string {
+	switch n := node.(type) {
+	case *ast.GoxFile:
+		return fmt.Sprintf("GoxFile %s (package %s)", n.SourcePath, n.Package)
+	case *ast.GoCode:
+		return "GoCode " + quoteShort(n.Value)
+	case *ast.JSXElement:
+		tag := n.Tag
+		if n.TypeArgs != "" {
+			tag += "[" + n.TypeArgs + "]"
+		}
+		return "JSXElement <" + tag + ">"
+	case *ast.JSXFragment:
+		if n.Long {
+			return "JSXFragment <Fragment>"
+		}
+		return "JSXFragment <>"
+	case *ast.StringAttribute:
+		return "StringAttribute " + n.Key + "=" + strconv.Quote(n.Value)
+	case *ast.ExpressionAttribute:
+		if !n.ValueRange.IsValid() {
+			return "ExpressionAttribute " + n.Key
+		}
+		equals := "="
+		if n.Optional {
+			equals = "?="
+		}
+		return "ExpressionAttribute " + n.Key + equals + "{" + shorten(n.Expression) + "}"
+	case *ast.SpreadAttribute:
+		return "SpreadAttribute {..." + shorten(n.Expression) + "}"
+	case *ast.JSXText:
+		return "JSXText " + quoteShort(n.Value)
+	case *ast.JSXExpression:
+		return "JSXExpression {" + shorten(n.Expression) + "}"
+	case *ast.JSXComment:
+		return "JSXComment " + shorten(n.Text)
+	}
+	return fmt.Sprintf("%T", node)
+}
+
+// maxSummary is the number of bytes of source describeNode shows.
+const maxSummary = 40
+
+// shorten returns s on one line, cut to maxSummary bytes.
+func shorten(s string) string {
+	s, more := cutSummary(strings.Join(strings.Fields(s), " "))
+	return s + more
+}
+
+// quoteShort quotes s, cut to maxSummary bytes.
+func quoteShort(s string) string {
+	s, more := cutSummary(s)
+	return strconv.Quote(s) + more
+}
+
+// cutSummary cuts s to at most maxSummary bytes, at a rune boundary, and
+// returns "..." as more if it cut anything.
+func cutSummary(s string) (cut, more string) {
+	if len(s) <= maxSummary {
+		return s, ""
+	}
+	n := maxSummary
+	for n > 0 && !utf8.RuneStart(s[n]) {
+		n--
+	}
+	return s[:n], "..."
+}
@@ -0,0 +1,72 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"github.com/germtb/gox/ast"
+	"github.com/germtb/gox/parser"
+)
+
+const parseTestSource = "package ui\n\nvar x = <div id=\"a\" title={<b />}>hi {/* c */}{f(<i />)}</div>\n"
+
+func TestPrintTree(t *testing.T) {
+	file, err := parser.Parse("app.gox", []byte(parseTestSource))
+	if err != nil {
+		t.Fatalf("Parse error: %v", err)
+	}
+	var b bytes.Buffer
+	printTree(&b, file)
+
+	want := `1:1-4:1 GoxFile app.gox (package ui)
+  1:1-3:9 GoCode "package ui\n\nvar x = "
+  3:9-3:63 JSXElement <div>
+    3:14-3:20 StringAttribute id="a"
+    3:21-3:34 ExpressionAttribute title={<b />}
+      3:28-3:33 JSXElement <b>
+    3:35-3:38 JSXText "hi "
+    3:38-3:47 JSXComment /* c */
+    3:47-3:57 JSXExpression {f(<i />)}
+      3:48-3:50 GoCode "f("
+      3:50-3:55 JSXElement <i>
+      3:55-3:56 GoCode ")"
+  3:63-4:1 GoCode "\n"
+`
+	if b.String() != want {
+		t.Errorf("printTree:\n%s\nwant:\n%s", b.String(), want)
+	}
+}
+
+func TestParseJSONRoundTrip(t *testing.T) {
+	file, err := parser.Parse("app.gox", []byte(parseTestSource))
+	if err != nil {
+		t.Fatalf("Parse error: %v", err)
+	}
+	data, err := json.Marshal(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var decoded ast.GoxFile
+	if err := json.Unmarshal(data, &decoded); err != nil {
+		t.Fatalf("Unmarshal: %v", err)
+	}
+	var before, after bytes.Buffer
+	printTree(&before, file)
+	printTree(&after, &decoded)
+	if before.String() != after.String() {
+		t.Errorf("decoded tree:\n%s\nwant:\n%s", after.String(), before.String())
+	}
+}
+
+func TestShorten(t *testing.T) {
+	if got := shorten("a\n\t\tb"); got != "a b" {
+		t.Errorf("shorten = %q", got)
+	}
+	long := strings.Repeat("x", maxSummary-1) + "é"
+	if got := shorten(long); got != strings.Repeat("x", maxSummary-1)+"..." {
+		t.Errorf("shorten cut inside a rune: %q", got)
+	}
+}
@@ -0,0 +1,241 @@
+package main
+
+import (
+	"bytes"
+	"compress/gzip"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+)
+
+// pprofProfile is the part of a pprof profile (profile.proto) that gox
+// profile reads: samples and the functions of their call stacks.
+type pprofProfile struct {
+	SampleTypes []string // Names of the sample values, e.g. "cpu" or "alloc_space"
+	Samples     []pprofSample
+	Locations   map[uint64][]pprofLine // By location ID; inlined frames, innermost first
+	Functions   map[uint64]pprofFunction
+}
+
+// pprofSample is a call stack, leaf first, with one value per sample type.
+type pprofSample struct {
+	Locations []uint64
+	Values    []int64
+}
+
+// pprofLine is a frame of a location.
+type pprofLine struct {
+	Function uint64
+	Line     int64
+}
+
+// pprofFunction is a function referenced by frames.
+type pprofFunction struct {
+	Name      string
+	File      string
+	StartLine int64
+}
+
+// sampleIndex returns the index of the named sample value, or -1.
+func (p *pprofProfile) sampleIndex(name string) int {
+	for i, t := range p.SampleTypes {
+		if t == name {
+			return i
+		}
+	}
+	return -1
+}
+
+// stack returns the frames of a sample, leaf first.
+func (p *pprofProfile) stack(s pprofSample) []pprofLine {
+	var frames []pprofLine
+	for _, id := range s.Locations {
+		frames = append(frames, p.Locations[id]...)
+	}
+	return frames
+}
+
+// parsePprof decodes a pprof profile, gzipped or not.
+func parsePprof(data []byte) (*pprofProfile, error) {
+	if len(data) >= 2 && data[0] == 0x1f && data[1] == 0x8b {
+		zr, err := gzip.NewReader(bytes.NewReader(data))
+		if err != nil {
+			return nil, err
+		}
+		if data, err = io.ReadAll(zr); err != nil {
+			return nil, err
+		}
+	}
+
+	// Strings are indexes into the string table, which may come last
+	var strs []string
+	var sampleTypes []int64
+	type rawFunction struct {
+		id                  uint64
+		name, file, startAt int64
+	}
+	var functions []rawFunction
+	p := &pprofProfile{
+		Locations: make(map[uint64][]pprofLine),
+		Functions: make(map[uint64]pprofFunction),
+	}
+
+	err := protoFields(data, func(field, wire int, v uint64, b []byte) error {
+		switch field {
+		case 1: // sample_type
+			return protoFields(b, func(field, wire int, v uint64, b []byte) error {
+				if field == 1 {
+					sampleTypes = append(sampleTypes, int64(v))
+				}
+				return nil
+			})
+		case 2: // sample
+			var s pprofSample
+			err := protoFields(b, func(field, wire int, v uint64, b []byte) error {
+				switch field {
+				case 1:
+					ids, err := protoVarints(wire, v, b)
+					s.Locations = append(s.Locations, ids...)
+					return err
+				case 2:
+					values, err := protoVarints(wire, v, b)
+					for _, value := range values {
+						s.Values = append(s.Values, int64(value))
+					}
+					return err
+				}
+				return nil
+			})
+			p.Samples = append(p.Samples, s)
+			return err
+		case 4: // location
+			var id uint64
+			var lines []pprofLine
+			err := protoFields(b, func(field, wire int, v uint64, b []byte) error {
+				switch field {
+				case 1:
+					id = v
+				case 4:
+					lines = append(lines, pprofLine{})
+					return protoFields(b, func(field, wire int, v uint64, b []byte) error {
+						switch field {
+						case 1:
+							lines[len(lines)-1].Function = v
+						case 2:
+							lines[len(lines)-1].Line = int64(v)
+						}
+						return nil
+					})
+				}
+				return nil
+			})
+			p.Locations[id] = lines
+			return err
+		case 5: // function
+			var fn rawFunction
+			err := protoFields(b, func(field, wire int, v uint64, b []byte) error {
+				switch field {
+				case 1:
+					fn.id = v
+				case 2:
+					fn.name = int64(v)
+				case 4:
+					fn.file = int64(v)
+				case 5:
+					fn.startAt = int64(v)
+				}
+				return nil
+			})
+			functions = append(functions, fn)
+			return err
+		case 6: // string_table
+			strs = append(strs, string(b))
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("decoding profile: %w", err)
+	}
+
+	str := func(i int64) string {
+		if i < 0 || int(i) >= len(strs) {
+			return ""
+		}
+		return strs[i]
+	}
+	for _, t := range sampleTypes {
+		p.SampleTypes = append(p.SampleTypes, str(t))
+	}
+	for _, fn := range functions {
+		p.Functions[fn.id] = pprofFunction{Name: str(fn.name), File: str(fn.file), StartLine: fn.startAt}
+	}
+	return p, nil
+}
+
+var errTruncated = errors.New("truncated protobuf")
+
+// protoFields calls fn for each field of a protobuf message, with the value
+// of varint fields or the bytes of length-delimited ones. Fixed-size fields
+// are skipped, since profile.proto has none that gox reads.
+func protoFields(data []byte, fn func(field, wire int, v uint64, b []byte) error) error {
+	for len(data) > 0 {
+		key, n := binary.Uvarint(data)
+		if n <= 0 {
+			return errTruncated
+		}
+		data = data[n:]
+		field, wire := int(key>>3), int(key&7)
+		var v uint64
+		var b []byte
+		switch wire {
+		case 0:
+			if v, n = binary.Uvarint(data); n <= 0 {
+				return errTruncated
+			}
+			data = data[n:]
+		case 1:
+			if len(data) < 8 {
+				return errTruncated
+			}
+			data = data[8:]
+			continue
+		case 2:
+			size, n := binary.Uvarint(data)
+			if n <= 0 || uint64(len(data)-n) < size {
+				return errTruncated
+			}
+			b, data = data[n:n+int(size)], data[n+int(size):]
+		case 5:
+			if len(data) < 4 {
+				return errTruncated
+			}
+			data = data[4:]
+			continue
+		default:
+			return fmt.Errorf("unsupported wire type %d", wire)
+		}
+		if err := fn(field, wire, v, b); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// protoVarints returns the values of a repeated varint field, which may be
+// packed into one length-delimited field or repeated one value at a time.
+func protoVarints(wire int, v uint64, b []byte) ([]uint64, error) {
+	if wire == 0 {
+		return []uint64{v}, nil
+	}
+	var values []uint64
+	for len(b) > 0 {
+		value, n := binary.Uvarint(b)
+		if n <= 0 {
+			return nil, errTruncated
+		}
+		values = append(values, value)
+		b = b[n:]
+	}
+	return values, nil
+}
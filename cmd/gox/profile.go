@@ -0,0 +1,404 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"strings"
+	"text/tabwriter"
+	"time"
+	"unicode"
+
+	"github.com/germtb/gox/generator"
+	"github.com/germtb/gox/internal/atomicfile"
+	"github.com/germtb/gox/parser"
+)
+
+// profileConfig holds configuration for the profile render command.
+type profileConfig struct {
+	component string // Component name, or a JSX element to render
+	mem       bool   // Profile allocations instead of CPU time
+	benchtime string // Duration of the render loop, as for go test -benchtime
+	output    string // Prefix of the files written
+}
+
+// profileHarnessName is the test file added to the target package through
+// the overlay. It never exists on disk.
+const profileHarnessName = "gox_profile_gox_test.go"
+
+// profileHarness is the .gox source of the render loop, formatted with the
+// package name, the runtime package and the element to render.
+const profileHarness = `package %s
+
+import (
+	"context"
+	"testing"
+
+	%q
+)
+
+func BenchmarkGoxRender(b *testing.B) {
+	ctx := context.Background()
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		goxProfileVisit(ctx, %s)
+	}
+}
+
+// goxProfileVisit expands every component of the tree, like a renderer.
+func goxProfileVisit(ctx context.Context, node gox.VNode) {
+	for expanded := true; expanded; {
+		node, expanded = gox.Expand(ctx, node)
+	}
+	for _, child := range node.Children {
+		goxProfileVisit(ctx, child)
+	}
+}
+`
+
+// runProfile runs the profile command.
+func runProfile(args []string) error {
+	if len(args) == 0 || args[0] != "render" {
+		return fmt.Errorf("usage: gox profile render -component <name> [flags] [package]")
+	}
+
+	cfg := &profileConfig{}
+	fs := flag.NewFlagSet("profile render", flag.ExitOnError)
+	fs.StringVar(&cfg.component, "component", "", "component to render, by name or as a JSX element")
+	fs.BoolVar(&cfg.mem, "mem", false, "profile allocations instead of CPU time")
+	fs.StringVar(&cfg.benchtime, "benchtime", "2s", "how long to run the render loop")
+	fs.StringVar(&cfg.output, "o", "render", "prefix of the profile files written")
+	if err := fs.Parse(args[1:]); err != nil {
+		return err
+	}
+	if cfg.component == "" {
+		return fmt.Errorf("-component is required")
+	}
+	if fs.NArg() > 1 {
+		return fmt.Errorf("profile render takes a single package")
+	}
+	pkg := "."
+	if fs.NArg() == 1 {
+		pkg = fs.Arg(0)
+	}
+	return profileRender(pkg, cfg)
+}
+
+// profileRender builds pkg with the overlay and a benchmark that renders the
+// component, runs it under pprof, and writes the profile with frames of .gox
+// files named after their components.
+func profileRender(pkg string, cfg *profileConfig) error {
+	if !isPath(pkg) {
+		return fmt.Errorf("%s: profile render takes a package directory, such as ./cmd/app", pkg)
+	}
+	dir, err := filepath.Abs(pkg)
+	if err != nil {
+		return err
+	}
+
+	goxFiles, err := findBuildGoxFiles()
+	if err != nil {
+		return fmt.Errorf("finding gox files: %w", err)
+	}
+	goxFiles = filterGoxFiles(goxBuildContext(""), goxFiles)
+	tempDir, err := os.MkdirTemp("", "gox-profile-*")
+	if err != nil {
+		return fmt.Errorf("creating temp dir: %w", err)
+	}
+	defer os.RemoveAll(tempDir)
+
+	genCfg := &generateConfig{
+		overlay:      true,
+		overlayFile:  filepath.Join(tempDir, "overlay.json"),
+		tempDir:      tempDir,
+		inMemoryMaps: true,
+		cache:        openOverlayCache(),
+	}
+	if err := processFilesOverlay(goxFiles, genCfg); err != nil {
+		return fmt.Errorf("generating overlay: %w", err)
+	}
+	var overlay Overlay
+	data, err := os.ReadFile(genCfg.overlayFile)
+	if err != nil {
+		return fmt.Errorf("reading overlay: %w", err)
+	}
+	if err := json.Unmarshal(data, &overlay); err != nil {
+		return fmt.Errorf("parsing overlay: %w", err)
+	}
+
+	// The harness belongs to the target package, so it can render
+	// unexported components and components of main packages
+	out, err := exec.Command("go", "list", "-overlay="+genCfg.overlayFile, "-f", "{{.Name}}", pkg).CombinedOutput()
+	if err != nil {
+		return fmt.Errorf("go list %s: %s", pkg, strings.TrimSpace(string(out)))
+	}
+	runtimePkg, err := harnessRuntime(dir)
+	if err != nil {
+		return err
+	}
+	harness, err := renderHarness(strings.TrimSpace(string(out)), runtimePkg, cfg.component)
+	if err != nil {
+		return err
+	}
+	harnessFile := filepath.Join(tempDir, profileHarnessName)
+	if err := os.WriteFile(harnessFile, harness, 0644); err != nil {
+		return err
+	}
+	overlay.Replace[filepath.Join(dir, profileHarnessName)] = harnessFile
+	if err := writeOverlay(overlay, genCfg); err != nil {
+		return err
+	}
+
+	profilePath, err := filepath.Abs(cfg.output + ".pprof")
+	if err != nil {
+		return err
+	}
+	profileFlag := "-cpuprofile="
+	if cfg.mem {
+		profileFlag = "-memprofile="
+	}
+	cmd := exec.Command("go", "test", "-overlay="+genCfg.overlayFile,
+		"-run=^$", "-bench=^BenchmarkGoxRender$", "-benchtime="+cfg.benchtime,
+		"-o="+filepath.Join(tempDir, "render.test"), profileFlag+profilePath, pkg)
+	cmd.Stdout = os.Stdout
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	err = cmd.Run()
+	fmt.Fprint(os.Stderr, remapErrors(stderr.String(), genCfg.sourceMapsOutput))
+	if err != nil {
+		return fmt.Errorf("running render loop: %w", err)
+	}
+
+	data, err = os.ReadFile(profilePath)
+	if err != nil {
+		return fmt.Errorf("reading profile: %w", err)
+	}
+	prof, err := parsePprof(data)
+	if err != nil {
+		return err
+	}
+	sampleType := "cpu"
+	if cfg.mem {
+		sampleType = "alloc_space"
+	}
+	index := prof.sampleIndex(sampleType)
+	if index < 0 {
+		return fmt.Errorf("profile has no %s samples", sampleType)
+	}
+	names := newFrameNames(prof, genCfg.sourceMapsOutput)
+
+	foldedPath := cfg.output + ".folded"
+	var folded bytes.Buffer
+	writeFoldedStacks(&folded, prof, index, names)
+	if err := atomicfile.WriteFile(foldedPath, folded.Bytes(), 0644); err != nil {
+		return err
+	}
+
+	fmt.Println()
+	printComponentProfile(os.Stdout, prof, index, names, cfg.mem)
+	fmt.Printf("\nWrote %s (go tool pprof -http=: %s)\n", cfg.output+".pprof", cfg.output+".pprof")
+	fmt.Printf("Wrote %s (folded stacks for flamegraph.pl, speedscope or inferno)\n", foldedPath)
+	return nil
+}
+
+// harnessRuntime returns the runtime package of the harness added to the
+// package in dir, resolved as for the .gox files of the package: from the
+// nearest .goxruntime file, or else the default.
+func harnessRuntime(dir string) (string, error) {
+	pkg, err := (&generateConfig{}).runtimeFor(filepath.Join(dir, profileHarnessName))
+	if err != nil || pkg != "" {
+		return pkg, err
+	}
+	return generator.DefaultRuntimePackage, nil
+}
+
+// renderHarness returns the generated Go code of the benchmark rendering
+// component, which is a component name or a JSX element such as
+// `<App user={testUser} />`, with the given runtime package.
+func renderHarness(pkgName, runtimePkg, component string) ([]byte, error) {
+	element := strings.TrimSpace(component)
+	if !strings.HasPrefix(element, "<") {
+		element = "<" + element + " />"
+	}
+	src := fmt.Sprintf(profileHarness, pkgName, runtimePkg, element)
+	file, err := parser.Parse(profileHarnessName, []byte(src))
+	if err != nil {
+		return nil, fmt.Errorf("-component %s: %w", component, err)
+	}
+	code, _, err := generator.Generate(file, &generator.Options{RuntimePackage: runtimePkg})
+	if err != nil {
+		return nil, fmt.Errorf("-component %s: %w", component, err)
+	}
+	return code, nil
+}
+
+// frameNames names the frames of a profile for display. Functions generated
+// from .gox files are named after their components, e.g. "<App>" for
+// example.com/app/ui.App and "<App>.func1" for a closure inside it.
+type frameNames struct {
+	prof       *pprofProfile
+	sourceMaps map[string]*generator.SourceMap
+	names      map[uint64]string
+	components map[uint64]bool // Functions defined by a .gox file
+}
+
+func newFrameNames(prof *pprofProfile, sourceMaps map[string]*generator.SourceMap) *frameNames {
+	f := &frameNames{
+		prof:       prof,
+		sourceMaps: sourceMaps,
+		names:      make(map[uint64]string),
+		components: make(map[uint64]bool),
+	}
+	for id, fn := range prof.Functions {
+		f.names[id] = fn.Name
+		if lookupSourceMap(fn.File, sourceMaps) == nil {
+			continue
+		}
+		if name, ok := componentDisplayName(fn.Name); ok {
+			f.names[id] = name
+			f.components[id] = true
+		}
+	}
+	return f
+}
+
+// componentDisplayName returns the display name of a function defined in a
+// .gox file, if it is a component: an exported function, or a closure inside
+// one. Methods and unexported helpers keep their Go names.
+func componentDisplayName(funcName string) (string, bool) {
+	name := funcName[strings.LastIndex(funcName, "/")+1:]
+	_, name, ok := strings.Cut(name, ".")
+	if !ok || name == "" || !unicode.IsUpper([]rune(name)[0]) {
+		return "", false
+	}
+	component, rest, _ := strings.Cut(name, ".")
+	if rest != "" {
+		rest = "." + rest
+	}
+	return "<" + component + ">" + rest, true
+}
+
+// source returns the .gox position of a component's definition.
+func (f *frameNames) source(id uint64) string {
+	fn := f.prof.Functions[id]
+	sm := lookupSourceMap(fn.File, f.sourceMaps)
+	if sm == nil {
+		return ""
+	}
+	path := sm.SourceFile
+	if wd, err := os.Getwd(); err == nil {
+		if rel, err := filepath.Rel(wd, path); err == nil && !strings.HasPrefix(rel, "..") {
+			path = rel
+		}
+	}
+	if line, ok := sm.FindSourceLine(uint32(fn.StartLine - 1)); ok && fn.StartLine > 0 {
+		return fmt.Sprintf("%s:%d", path, line+1)
+	}
+	return path
+}
+
+// writeFoldedStacks writes the samples as folded stacks, one "root;...;leaf
+// value" line per distinct stack, the input format of flame graph tools.
+// Frames above the render loop are dropped.
+func writeFoldedStacks(w io.Writer, prof *pprofProfile, index int, names *frameNames) {
+	totals := make(map[string]int64)
+	for _, s := range prof.Samples {
+		if index >= len(s.Values) || s.Values[index] == 0 {
+			continue
+		}
+		var frames []string
+		for _, line := range prof.stack(s) {
+			name := names.names[line.Function]
+			frames = append(frames, strings.ReplaceAll(name, ";", ":"))
+			if strings.HasSuffix(name, ".BenchmarkGoxRender") {
+				break
+			}
+		}
+		for i, j := 0, len(frames)-1; i < j; i, j = i+1, j-1 {
+			frames[i], frames[j] = frames[j], frames[i]
+		}
+		totals[strings.Join(frames, ";")] += s.Values[index]
+	}
+	stacks := make([]string, 0, len(totals))
+	for stack := range totals {
+		stacks = append(stacks, stack)
+	}
+	sort.Strings(stacks)
+	for _, stack := range stacks {
+		fmt.Fprintf(w, "%s %d\n", stack, totals[stack])
+	}
+}
+
+// componentCost is the time or memory attributed to a component.
+type componentCost struct {
+	function uint64
+	self     int64 // Spent in the component's own code, including library calls
+	cum      int64 // Spent in the component and the components it renders
+}
+
+// componentCosts attributes each sample to the innermost component on its
+// stack (self) and to every component on it (cum), sorted by self.
+func componentCosts(prof *pprofProfile, index int, names *frameNames) []componentCost {
+	costs := make(map[uint64]*componentCost)
+	cost := func(id uint64) *componentCost {
+		if costs[id] == nil {
+			costs[id] = &componentCost{function: id}
+		}
+		return costs[id]
+	}
+	for _, s := range prof.Samples {
+		if index >= len(s.Values) {
+			continue
+		}
+		value := s.Values[index]
+		seen := make(map[uint64]bool)
+		for _, line := range prof.stack(s) {
+			if !names.components[line.Function] || seen[line.Function] {
+				continue
+			}
+			if len(seen) == 0 {
+				cost(line.Function).self += value
+			}
+			seen[line.Function] = true
+			cost(line.Function).cum += value
+		}
+	}
+	var sorted []componentCost
+	for _, c := range costs {
+		sorted = append(sorted, *c)
+	}
+	sort.Slice(sorted, func(i, j int) bool {
+		if sorted[i].self != sorted[j].self {
+			return sorted[i].self > sorted[j].self
+		}
+		return names.names[sorted[i].function] < names.names[sorted[j].function]
+	})
+	return sorted
+}
+
+// printComponentProfile prints the cost of each component in a table.
+func printComponentProfile(w io.Writer, prof *pprofProfile, index int, names *frameNames, mem bool) {
+	costs := componentCosts(prof, index, names)
+	if len(costs) == 0 {
+		fmt.Fprintln(w, "No samples in .gox components; try a longer -benchtime")
+		return
+	}
+	format := func(v int64) string {
+		if mem {
+			return formatBytes(uint64(v))
+		}
+		return time.Duration(v).Round(time.Microsecond).String()
+	}
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "COMPONENT\tSELF\tCUM\tSOURCE")
+	for _, c := range costs {
+		fmt.Fprintf(tw, "%s\t%s\t%s\t%s\n", names.names[c.function], format(c.self), format(c.cum), names.source(c.function))
+	}
+	tw.Flush()
+}
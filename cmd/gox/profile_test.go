@@ -0,0 +1,153 @@
+package main
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"runtime"
+	"runtime/pprof"
+	"strings"
+	"testing"
+
+	"github.com/germtb/gox/generator"
+)
+
+// sink keeps the allocation of TestParsePprof alive.
+var sink []byte
+
+func TestParsePprof(t *testing.T) {
+	defer func(rate int) { runtime.MemProfileRate = rate }(runtime.MemProfileRate)
+	runtime.MemProfileRate = 1
+	sink = make([]byte, 1<<16)
+	runtime.GC()
+
+	var buf bytes.Buffer
+	if err := pprof.Lookup("allocs").WriteTo(&buf, 0); err != nil {
+		t.Fatal(err)
+	}
+	prof, err := parsePprof(buf.Bytes())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if prof.sampleIndex("alloc_space") < 0 {
+		t.Fatalf("sample types %v lack alloc_space", prof.SampleTypes)
+	}
+	found := false
+	for _, s := range prof.Samples {
+		for _, line := range prof.stack(s) {
+			if strings.HasSuffix(prof.Functions[line.Function].Name, ".TestParsePprof") {
+				found = true
+			}
+		}
+	}
+	if !found {
+		t.Error("no sample in TestParsePprof")
+	}
+}
+
+func TestComponentDisplayName(t *testing.T) {
+	tests := []struct {
+		name string
+		want string
+	}{
+		{"example.com/app/ui.App", "<App>"},
+		{"example.com/app/ui.App.func1", "<App>.func1"},
+		{"main.Greeting", "<Greeting>"},
+		{"example.com/app/ui.helper", ""},
+		{"example.com/app/ui.(*Page).Render", ""},
+	}
+	for _, tt := range tests {
+		got, ok := componentDisplayName(tt.name)
+		if got != tt.want || ok != (tt.want != "") {
+			t.Errorf("componentDisplayName(%q) = %q, %v, want %q", tt.name, got, ok, tt.want)
+		}
+	}
+}
+
+func TestComponentProfile(t *testing.T) {
+	sm := generator.NewSourceMap()
+	sm.SetFiles("/src/ui/list.gox", "/src/ui/list_gox.go")
+	prof := &pprofProfile{
+		SampleTypes: []string{"samples", "cpu"},
+		Functions: map[uint64]pprofFunction{
+			1: {Name: "example.com/ui.BenchmarkGoxRender", File: "/src/ui/gox_profile_gox_test.go"},
+			2: {Name: "example.com/ui.List", File: "/src/ui/list_gox.go"},
+			3: {Name: "example.com/ui.Item", File: "/src/ui/list_gox.go"},
+			4: {Name: "fmt.Sprint", File: "/go/src/fmt/print.go"},
+			5: {Name: "testing.(*B).runN", File: "/go/src/testing/benchmark.go"},
+		},
+		Locations: map[uint64][]pprofLine{
+			1: {{Function: 1}}, 2: {{Function: 2}}, 3: {{Function: 3}}, 4: {{Function: 4}}, 5: {{Function: 5}},
+		},
+		Samples: []pprofSample{
+			{Locations: []uint64{4, 3, 2, 1, 5}, Values: []int64{3, 30}},
+			{Locations: []uint64{3, 2, 1, 5}, Values: []int64{1, 10}},
+			{Locations: []uint64{2, 1, 5}, Values: []int64{2, 20}},
+		},
+	}
+	names := newFrameNames(prof, map[string]*generator.SourceMap{"/src/ui/list_gox.go": sm})
+
+	var folded bytes.Buffer
+	writeFoldedStacks(&folded, prof, 1, names)
+	want := "example.com/ui.BenchmarkGoxRender;<List> 20\n" +
+		"example.com/ui.BenchmarkGoxRender;<List>;<Item> 10\n" +
+		"example.com/ui.BenchmarkGoxRender;<List>;<Item>;fmt.Sprint 30\n"
+	if folded.String() != want {
+		t.Errorf("folded stacks:\n%s\nwant:\n%s", folded.String(), want)
+	}
+
+	costs := componentCosts(prof, 1, names)
+	if len(costs) != 2 {
+		t.Fatalf("got %d component costs, want 2", len(costs))
+	}
+	if c := costs[0]; names.names[c.function] != "<Item>" || c.self != 40 || c.cum != 40 {
+		t.Errorf("first cost = %s %d/%d, want <Item> 40/40", names.names[c.function], c.self, c.cum)
+	}
+	if c := costs[1]; names.names[c.function] != "<List>" || c.self != 20 || c.cum != 60 {
+		t.Errorf("second cost = %s %d/%d, want <List> 20/60", names.names[c.function], c.self, c.cum)
+	}
+}
+
+func TestRenderHarness(t *testing.T) {
+	code, err := renderHarness("ui", generator.DefaultRuntimePackage, "List")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(code), "goxProfileVisit(ctx, List(ListProps{}))") {
+		t.Errorf("harness does not render List:\n%s", code)
+	}
+
+	code, err = renderHarness("ui", generator.DefaultRuntimePackage, `<Item N={3} />`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(code), "Item(ItemProps{N: 3})") {
+		t.Errorf("harness does not render the element:\n%s", code)
+	}
+}
+
+func TestHarnessRuntime(t *testing.T) {
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "go.mod"), []byte("module example.com/app\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if pkg, err := harnessRuntime(dir); err != nil || pkg != generator.DefaultRuntimePackage {
+		t.Errorf("harnessRuntime without .goxruntime = %q, %v; want the default", pkg, err)
+	}
+
+	// The harness imports the runtime the package's .gox files are generated with
+	if err := os.WriteFile(filepath.Join(dir, ".goxruntime"), []byte("example.com/tui/gox\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	pkg, err := harnessRuntime(dir)
+	if err != nil || pkg != "example.com/tui/gox" {
+		t.Fatalf("harnessRuntime = %q, %v; want example.com/tui/gox", pkg, err)
+	}
+	code, err := renderHarness("ui", pkg, "List")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(code), `"example.com/tui/gox"`) || strings.Contains(string(code), generator.DefaultRuntimePackage) {
+		t.Errorf("harness does not import only the configured runtime:\n%s", code)
+	}
+}
@@ -0,0 +1,72 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"sync"
+	"time"
+)
+
+// generateProgress counts the files of a gox generate run as they finish.
+// With -v it prints a line for each, numbered in the order they finish, and
+// a summary at the end.
+type generateProgress struct {
+	out   io.Writer
+	total int
+	start time.Time
+
+	mu        sync.Mutex
+	done      int
+	generated int
+	unchanged int
+	failed    int
+}
+
+func newGenerateProgress(out io.Writer, total int) *generateProgress {
+	return &generateProgress{out: out, total: total, start: time.Now()}
+}
+
+// fileDone records a finished file: its output changed, was already up to
+// date, or err failed it. verbose prints the file's progress line.
+func (p *generateProgress) fileDone(file, output string, changed bool, err error, elapsed time.Duration, verbose bool) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	p.done++
+	var status string
+	switch {
+	case err != nil:
+		p.failed++
+		status = file + ": failed"
+	case changed:
+		p.generated++
+		status = file + " -> " + output
+	default:
+		p.unchanged++
+		status = file + ": up to date"
+	}
+	if verbose {
+		fmt.Fprintf(p.out, "[%*d/%d] %s (%s)\n", len(fmt.Sprint(p.total)), p.done, p.total, status, roundDuration(elapsed))
+	}
+}
+
+// summary prints the number of files generated, already up to date and
+// failed, and how long the run took.
+func (p *generateProgress) summary() {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	fmt.Fprintf(p.out, "%d generated, %d up to date, %d failed (%d file(s) in %s)\n",
+		p.generated, p.unchanged, p.failed, p.total, roundDuration(time.Since(p.start)))
+}
+
+// roundDuration rounds d for display, to three significant digits or so.
+func roundDuration(d time.Duration) time.Duration {
+	switch {
+	case d >= time.Second:
+		return d.Round(10 * time.Millisecond)
+	case d >= time.Millisecond:
+		return d.Round(10 * time.Microsecond)
+	default:
+		return d.Round(time.Microsecond)
+	}
+}
@@ -0,0 +1,128 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/germtb/gox/generator"
+)
+
+// positionPattern matches a reference to a position in a Go file, e.g.
+// "./app_gox.go:12:5", "ui/button_gox_test.go:3" or on Windows
+// `C:\src\app_gox.go:12:5`. Such references appear at the start of compiler
+// errors, in indented continuation lines and in cross references like
+// "other declaration of x at app_gox.go:4:6". Generated files may be named
+// with any suffix, so only those with a source map are remapped.
+var positionPattern = regexp.MustCompile(`((?:[A-Za-z]:[\\/])?[^\s:()"']+\.go):(\d+)(?::(\d+))?`)
+
+// remapErrors takes go build/run stderr output and remaps _gox.go errors to .gox locations.
+// Every line of a multi-line error block is processed, so continuation lines and
+// notes that reference generated positions are remapped too. Text around the
+// positions (indentation, "have/want" details) is preserved as-is.
+func remapErrors(stderr string, sourceMaps map[string]*generator.SourceMap) string {
+	var result strings.Builder
+	scanner := bufio.NewScanner(strings.NewReader(stderr))
+
+	for scanner.Scan() {
+		line := scanner.Text()
+		remapped := remapErrorLine(line, sourceMaps)
+		result.WriteString(remapped)
+		result.WriteString("\n")
+	}
+
+	return result.String()
+}
+
+// remapErrorLine remaps every generated-file position referenced in a single line.
+func remapErrorLine(line string, sourceMaps map[string]*generator.SourceMap) string {
+	return positionPattern.ReplaceAllStringFunc(line, func(ref string) string {
+		matches := positionPattern.FindStringSubmatch(ref)
+		filePath := matches[1]
+		lineNum, _ := strconv.Atoi(matches[2])
+		sm := lookupSourceMap(filePath, sourceMaps)
+		if sm == nil || lineNum < 1 {
+			return ref // No mapping found, return original
+		}
+
+		// Line-only references (e.g. stack traces) map through the line table
+		if matches[3] == "" {
+			srcLine, ok := sm.FindSourceLine(uint32(lineNum - 1))
+			if !ok {
+				return ref
+			}
+			return fmt.Sprintf("%s:%d", sm.SourceFile, srcLine+1)
+		}
+
+		// Remap position (Go compiler uses 1-indexed byte columns, source map
+		// uses 0-indexed rune columns)
+		colNum, _ := strconv.Atoi(matches[3])
+		if colNum < 1 {
+			return ref
+		}
+		tgtLine := uint32(lineNum - 1)
+		srcPos, ok := sm.SourcePositionFromTarget(tgtLine, sm.TargetRuneColumn(tgtLine, uint32(colNum-1)))
+		if !ok {
+			return ref
+		}
+		return fmt.Sprintf("%s:%d:%d", sm.SourceFile, srcPos.Line+1, srcPos.Column+1)
+	})
+}
+
+// lookupSourceMap finds the source map for a generated file path as printed by
+// the go tool, which may be relative to the working directory.
+func lookupSourceMap(filePath string, sourceMaps map[string]*generator.SourceMap) *generator.SourceMap {
+	if sm, ok := sourceMaps[filePath]; ok {
+		return sm
+	}
+	abs, err := filepath.Abs(filePath)
+	if err != nil {
+		return nil
+	}
+	if sm, ok := sourceMaps[abs]; ok {
+		return sm
+	}
+	return staleOverlaySourceMap(abs, sourceMaps)
+}
+
+// overlayDirPattern matches the temp directory of a proxied command's overlay.
+var overlayDirPattern = regexp.MustCompile(`^gox-overlay-\d+$`)
+
+// staleOverlaySourceMap finds the source map for a generated file of an
+// earlier overlay. go caches vet results by content and replays them with
+// the paths of the run that produced them, which may be gone. Files of the
+// overlay cache still have their source map next to them; files in a
+// deleted temp directory are laid out like the targets they stood in for,
+// relative to the working directory.
+func staleOverlaySourceMap(abs string, sourceMaps map[string]*generator.SourceMap) *generator.SourceMap {
+	if data, err := os.ReadFile(abs + ".map"); err == nil {
+		if sm, err := generator.FromJSON(data); err == nil {
+			return sm
+		}
+	}
+
+	parts := strings.Split(filepath.ToSlash(abs), "/")
+	for i, part := range parts {
+		if !overlayDirPattern.MatchString(part) {
+			continue
+		}
+		rel := filepath.FromSlash(strings.Join(parts[i+1:], "/"))
+		if cwd, err := os.Getwd(); err == nil {
+			if sm, ok := sourceMaps[filepath.Join(cwd, rel)]; ok {
+				return sm
+			}
+		}
+		// Targets outside the working directory are under abs/, without
+		// their volume
+		if i+2 < len(parts) && parts[i+1] == "abs" {
+			if sm, ok := sourceMaps[string(filepath.Separator)+filepath.FromSlash(strings.Join(parts[i+2:], "/"))]; ok {
+				return sm
+			}
+		}
+	}
+	return nil
+}
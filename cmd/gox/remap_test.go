@@ -0,0 +1,150 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+
+	"github.com/germtb/gox/generator"
+)
+
+// testSourceMaps returns a source map for /src/app_gox.go whose line 10
+// (1-indexed) maps to line 3 of /src/app.gox.
+func testSourceMaps() map[string]*generator.SourceMap {
+	sm := generator.NewSourceMap()
+	sm.SetFiles("/src/app.gox", "/src/app_gox.go")
+	sm.AddExpression("x := foo()", generator.NewPosition(0, 2, 1), generator.NewPosition(0, 9, 1))
+	// The same map under a Windows path and a configured suffix
+	return map[string]*generator.SourceMap{"/src/app_gox.go": sm, `C:\src\app_gox.go`: sm, "/src/view.gen.go": sm}
+}
+
+func TestRemapErrorLine(t *testing.T) {
+	maps := testSourceMaps()
+
+	tests := []struct {
+		name     string
+		line     string
+		expected string
+	}{
+		{
+			name:     "leading position",
+			line:     "/src/app_gox.go:10:2: undefined: foo",
+			expected: "/src/app.gox:3:2: undefined: foo",
+		},
+		{
+			name:     "indented cross reference",
+			line:     "\t/src/app_gox.go:10:2: other declaration of x",
+			expected: "\t/src/app.gox:3:2: other declaration of x",
+		},
+		{
+			name:     "reference inside message",
+			line:     "main.go:4:2: x redeclared (see /src/app_gox.go:10:2)",
+			expected: "main.go:4:2: x redeclared (see /src/app.gox:3:2)",
+		},
+		{
+			name:     "line without column",
+			line:     "panic at /src/app_gox.go:10 +0x1d",
+			expected: "panic at /src/app.gox:3 +0x1d",
+		},
+		{
+			name:     "windows path",
+			line:     `C:\src\app_gox.go:10:2: undefined: foo`,
+			expected: "/src/app.gox:3:2: undefined: foo",
+		},
+		{
+			name:     "configured suffix",
+			line:     "/src/view.gen.go:10:2: undefined: foo",
+			expected: "/src/app.gox:3:2: undefined: foo",
+		},
+		{
+			name:     "hand-written file untouched",
+			line:     "/src/main.go:10:2: undefined: foo",
+			expected: "/src/main.go:10:2: undefined: foo",
+		},
+		{
+			name:     "continuation line untouched",
+			line:     "\t\thave (int)",
+			expected: "\t\thave (int)",
+		},
+		{
+			name:     "unknown generated file untouched",
+			line:     "/other/x_gox.go:1:1: oops",
+			expected: "/other/x_gox.go:1:1: oops",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := remapErrorLine(tt.line, maps); got != tt.expected {
+				t.Errorf("remapErrorLine(%q) = %q, want %q", tt.line, got, tt.expected)
+			}
+		})
+	}
+}
+
+func TestRemapErrorLineWideCharacters(t *testing.T) {
+	sm := generator.NewSourceMap()
+	sm.SetFiles("/src/app.gox", "/src/app_gox.go")
+	sm.AddExpression(`s := "héllo"; x`, generator.NewPosition(0, 2, 1), generator.NewPosition(0, 9, 1))
+	sm.WideLines = map[uint32]string{9: "\ts := \"héllo\"; x"}
+	maps := map[string]*generator.SourceMap{"/src/app_gox.go": sm}
+
+	// The compiler counts the two bytes of é; the .gox column counts it once
+	line := "/src/app_gox.go:10:17: undefined: x"
+	if got, want := remapErrorLine(line, maps), "/src/app.gox:3:16: undefined: x"; got != want {
+		t.Errorf("remapErrorLine(%q) = %q, want %q", line, got, want)
+	}
+}
+
+func TestRemapStaleOverlayPaths(t *testing.T) {
+	cwd, err := os.Getwd()
+	if err != nil {
+		t.Fatal(err)
+	}
+	maps := testSourceMaps()
+	sm := maps["/src/app_gox.go"]
+	maps[filepath.Join(cwd, "ui", "app_gox.go")] = sm
+
+	// A cached vet result from a deleted temp directory
+	line := filepath.Join("..", "gox-overlay-123", "ui", "app_gox.go") + ":10:2: unreachable code"
+	if got, want := remapErrorLine(line, maps), "/src/app.gox:3:2: unreachable code"; got != want {
+		t.Errorf("temp path: got %q, want %q", got, want)
+	}
+	if runtime.GOOS != "windows" {
+		line = "/tmp/gox-overlay-9/abs/src/app_gox.go:10:2: unreachable code"
+		if got, want := remapErrorLine(line, maps), "/src/app.gox:3:2: unreachable code"; got != want {
+			t.Errorf("temp path outside the working directory: got %q, want %q", got, want)
+		}
+	}
+
+	// A file of the overlay cache, with its source map next to it
+	cached := filepath.Join(t.TempDir(), "0123abcd_gox.go")
+	data, err := sm.ToJSON()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(cached+".map", data, 0644); err != nil {
+		t.Fatal(err)
+	}
+	if got, want := remapErrorLine(cached+":10:2: unreachable code", maps), "/src/app.gox:3:2: unreachable code"; got != want {
+		t.Errorf("cache path: got %q, want %q", got, want)
+	}
+}
+
+func TestRemapErrorsMultiLine(t *testing.T) {
+	stderr := "# example\n" +
+		"/src/app_gox.go:10:2: cannot use x (variable of type int) as string value\n" +
+		"\thave (int)\n" +
+		"\twant (string)\n"
+
+	got := remapErrors(stderr, testSourceMaps())
+
+	if !strings.Contains(got, "/src/app.gox:3:2: cannot use x") {
+		t.Errorf("Expected remapped header line, got:\n%s", got)
+	}
+	if !strings.Contains(got, "\thave (int)\n\twant (string)\n") {
+		t.Errorf("Expected continuation lines preserved, got:\n%s", got)
+	}
+}
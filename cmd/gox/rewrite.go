@@ -0,0 +1,195 @@
+package main
+
+import (
+	"bytes"
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/germtb/gox/internal/atomicfile"
+	"github.com/germtb/gox/rewrite"
+)
+
+// rewriteRule renames a tag, or an attribute on matching tags.
+//
+//	box -> stack             rename the tag box to stack
+//	box.gap -> box.spacing   rename the gap attribute of box elements
+//	*.class -> *.className   rename the class attribute on every element
+type rewriteRule struct {
+	tag     string // Tag to match, or "*" for any tag (attribute rules only)
+	attr    string // Attribute to rename; empty for tag rules
+	newTag  string
+	newAttr string
+}
+
+// rewriteConfig holds configuration for the rewrite command.
+type rewriteConfig struct {
+	write bool // Write changes back to files
+	list  bool // List files that would change
+	rules []rewriteRule
+	paths []string
+}
+
+// runRewrite applies rewrite rules to every .gox file in the given paths.
+// Without -w or -l it prints a unified diff of the changes.
+func runRewrite(args []string) error {
+	cfg := &rewriteConfig{}
+
+	fs := flag.NewFlagSet("rewrite", flag.ExitOnError)
+	fs.BoolVar(&cfg.write, "w", false, "write result to files instead of printing a diff")
+	fs.BoolVar(&cfg.list, "l", false, "list files that would change")
+
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if fs.NArg() == 0 {
+		return fmt.Errorf("usage: gox rewrite [-w] [-l] 'rule[; rule...]' [path ...]")
+	}
+
+	rules, err := parseRewriteRules(fs.Arg(0))
+	if err != nil {
+		return err
+	}
+	cfg.rules = rules
+
+	cfg.paths = fs.Args()[1:]
+	if len(cfg.paths) == 0 {
+		cfg.paths = []string{"./..."}
+	}
+
+	files, err := findGoxFiles(cfg.paths)
+	if err != nil {
+		return fmt.Errorf("finding files: %w", err)
+	}
+
+	var failed int
+	for _, path := range files {
+		if err := rewriteFile(path, cfg); err != nil {
+			fmt.Fprintf(os.Stderr, "error: %s: %v\n", path, err)
+			failed++
+		}
+	}
+	if failed > 0 {
+		return fmt.Errorf("%d file(s) failed", failed)
+	}
+	return nil
+}
+
+// rewriteFile applies cfg.rules to a single file and reports the result.
+func rewriteFile(path string, cfg *rewriteConfig) error {
+	src, err := os.ReadFile(path)
+	if err != nil {
+		return fmt.Errorf("reading file: %w", err)
+	}
+
+	out, err := rewriteSource(path, src, cfg.rules)
+	if err != nil {
+		return err
+	}
+	if bytes.Equal(src, out) {
+		return nil
+	}
+
+	switch {
+	case cfg.list:
+		fmt.Println(path)
+	case cfg.write:
+		if err := atomicfile.WriteFile(path, out, 0644); err != nil {
+			return fmt.Errorf("writing file: %w", err)
+		}
+	default:
+		fmt.Print(unifiedDiff(path+".orig", path, src, out))
+	}
+	return nil
+}
+
+// parseRewriteRules parses rules separated by semicolons.
+func parseRewriteRules(specs string) ([]rewriteRule, error) {
+	var rules []rewriteRule
+	for _, spec := range strings.Split(specs, ";") {
+		if strings.TrimSpace(spec) == "" {
+			continue
+		}
+		rule, err := parseRewriteRule(spec)
+		if err != nil {
+			return nil, err
+		}
+		rules = append(rules, rule)
+	}
+	return rules, nil
+}
+
+// parseRewriteRule parses a rule of the form "from -> to".
+func parseRewriteRule(spec string) (rewriteRule, error) {
+	from, to, ok := strings.Cut(spec, "->")
+	if !ok {
+		return rewriteRule{}, fmt.Errorf("invalid rule %q: expected 'from -> to'", spec)
+	}
+	from, to = strings.TrimSpace(from), strings.TrimSpace(to)
+
+	fromTag, fromAttr, fromHasAttr := strings.Cut(from, ".")
+	toTag, toAttr, toHasAttr := strings.Cut(to, ".")
+	if fromHasAttr != toHasAttr {
+		return rewriteRule{}, fmt.Errorf("invalid rule %q: both sides must name a tag, or both a tag.attribute", spec)
+	}
+
+	names := []string{fromTag, toTag}
+	if fromHasAttr {
+		names = append(names, fromAttr, toAttr)
+	}
+	for _, name := range names {
+		if name != "*" && !isRuleIdent(name) {
+			return rewriteRule{}, fmt.Errorf("invalid rule %q: %q is not a valid name", spec, name)
+		}
+	}
+
+	if !fromHasAttr {
+		if fromTag == "*" || toTag == "*" {
+			return rewriteRule{}, fmt.Errorf("invalid rule %q: tag renames cannot use '*'", spec)
+		}
+		return rewriteRule{tag: fromTag, newTag: toTag}, nil
+	}
+
+	if fromTag != toTag {
+		return rewriteRule{}, fmt.Errorf("invalid rule %q: attribute renames must keep the tag (%s.%s -> %s.%s)", spec, fromTag, fromAttr, fromTag, toAttr)
+	}
+	if fromAttr == "*" || toAttr == "*" {
+		return rewriteRule{}, fmt.Errorf("invalid rule %q: attribute names cannot use '*'", spec)
+	}
+	return rewriteRule{tag: fromTag, attr: fromAttr, newTag: fromTag, newAttr: toAttr}, nil
+}
+
+// isRuleIdent reports whether s can name a tag or attribute. Attributes may
+// contain dashes (data-id, aria-label) and namespaces (xlink:href).
+func isRuleIdent(s string) bool {
+	for i, r := range s {
+		switch {
+		case r == '_' || r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z':
+		case i > 0 && (r == '-' || r == ':' || r >= '0' && r <= '9'):
+		default:
+			return false
+		}
+	}
+	return s != ""
+}
+
+// rewriteSource applies rules to a .gox source and returns the result.
+func rewriteSource(path string, src []byte, rules []rewriteRule) ([]byte, error) {
+	f, err := rewrite.Parse(path, src)
+	if err != nil {
+		return nil, err
+	}
+
+	f.Each(rewrite.Any(), func(e *rewrite.Element) {
+		for _, rule := range rules {
+			switch {
+			case rule.attr == "" && rule.tag == e.Tag:
+				e.Rename(rule.newTag)
+			case rule.attr != "" && (rule.tag == "*" || rule.tag == e.Tag):
+				e.RenameAttr(rule.attr, rule.newAttr)
+			}
+		}
+	})
+	return f.Bytes(), nil
+}
@@ -0,0 +1,143 @@
+package main
+
+import (
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestParseRewriteRule(t *testing.T) {
+	tests := []struct {
+		spec    string
+		want    rewriteRule
+		wantErr bool
+	}{
+		{spec: "box -> stack", want: rewriteRule{tag: "box", newTag: "stack"}},
+		{spec: "box.gap->box.spacing", want: rewriteRule{tag: "box", attr: "gap", newTag: "box", newAttr: "spacing"}},
+		{spec: " *.class -> *.className ", want: rewriteRule{tag: "*", attr: "class", newTag: "*", newAttr: "className"}},
+		{spec: "*.data-id -> *.data-key", want: rewriteRule{tag: "*", attr: "data-id", newTag: "*", newAttr: "data-key"}},
+		{spec: "use.xlink:href -> use.href", want: rewriteRule{tag: "use", attr: "xlink:href", newTag: "use", newAttr: "href"}},
+		{spec: "box stack", wantErr: true},
+		{spec: "box -> stack.gap", wantErr: true},
+		{spec: "box.gap -> stack.gap", wantErr: true},
+		{spec: "* -> stack", wantErr: true},
+		{spec: "box.* -> box.x", wantErr: true},
+		{spec: "box -> 1box", wantErr: true},
+		{spec: "box. -> box.x", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.spec, func(t *testing.T) {
+			got, err := parseRewriteRule(tt.spec)
+			if tt.wantErr {
+				if err == nil {
+					t.Fatalf("expected error, got %+v", got)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if got != tt.want {
+				t.Errorf("got %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRewriteSource(t *testing.T) {
+	tests := []struct {
+		name  string
+		rules []string
+		src   string
+		want  string
+	}{
+		{
+			name:  "tag with closing tag",
+			rules: []string{"box -> stack"},
+			src:   "package ui\n\nfunc A() gox.VNode {\n\treturn <box><span>x</span></box>\n}\n",
+			want:  "package ui\n\nfunc A() gox.VNode {\n\treturn <stack><span>x</span></stack>\n}\n",
+		},
+		{
+			name:  "nested in expressions",
+			rules: []string{"box -> stack"},
+			src:   "package ui\n\nfunc A(xs []string) gox.VNode {\n\treturn <div icon={<box />}>\n\t\t{gox.Map(xs, func(s string) gox.VNode { return <box>{s}</box> })}\n\t</div>\n}\n",
+			want:  "package ui\n\nfunc A(xs []string) gox.VNode {\n\treturn <div icon={<stack />}>\n\t\t{gox.Map(xs, func(s string) gox.VNode { return <stack>{s}</stack> })}\n\t</div>\n}\n",
+		},
+		{
+			name:  "attributes matched on the original tag",
+			rules: []string{"box -> stack", "box.gap -> box.spacing", "*.class -> *.className"},
+			src:   "package ui\n\nfunc A() gox.VNode {\n\treturn <box gap={2} class=\"a\"><div gap={1} class=\"b\" /></box>\n}\n",
+			want:  "package ui\n\nfunc A() gox.VNode {\n\treturn <stack spacing={2} className=\"a\"><div gap={1} className=\"b\" /></stack>\n}\n",
+		},
+		{
+			name:  "comparisons and Go code untouched",
+			rules: []string{"box -> stack"},
+			src:   "package ui\n\n// box is a box\nfunc A(n int) gox.VNode {\n\tbox := 1\n\treturn <div>{n < box}</div>\n}\n",
+			want:  "package ui\n\n// box is a box\nfunc A(n int) gox.VNode {\n\tbox := 1\n\treturn <div>{n < box}</div>\n}\n",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var rules []rewriteRule
+			for _, spec := range tt.rules {
+				rule, err := parseRewriteRule(spec)
+				if err != nil {
+					t.Fatalf("parseRewriteRule(%q): %v", spec, err)
+				}
+				rules = append(rules, rule)
+			}
+
+			got, err := rewriteSource("test.gox", []byte(tt.src), rules)
+			if err != nil {
+				t.Fatalf("rewriteSource: %v", err)
+			}
+			if string(got) != tt.want {
+				t.Errorf("got:\n%s\nwant:\n%s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseRewriteRules(t *testing.T) {
+	rules, err := parseRewriteRules("box -> stack; ;*.class -> *.className;")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(rules) != 2 || rules[0].newTag != "stack" || rules[1].newAttr != "className" {
+		t.Errorf("got %+v", rules)
+	}
+	if _, err := parseRewriteRules("box -> stack; box stack"); err == nil {
+		t.Error("expected an error for an invalid rule")
+	}
+}
+
+func TestFormatSourceRewrite(t *testing.T) {
+	rules, err := parseRewriteRules("box -> stack; *.class -> *.className")
+	if err != nil {
+		t.Fatal(err)
+	}
+	path := filepath.Join(t.TempDir(), "a.gox")
+	src := "package ui\n\nfunc A() gox.VNode {\n\treturn <box   class=\"a\">x</box>\n}\n"
+	if err := os.WriteFile(path, []byte(src), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	changed, err := formatSource(path, []byte(src), &formatConfig{write: true, rules: rules}, io.Discard)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !changed {
+		t.Error("expected a change")
+	}
+	got, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := "package ui\n\nfunc A() gox.VNode {\n\treturn <stack className=\"a\">x</stack>\n}\n"
+	if string(got) != want {
+		t.Errorf("got:\n%s\nwant:\n%s", got, want)
+	}
+}
@@ -0,0 +1,91 @@
+package main
+
+import (
+	"os"
+	"os/exec"
+	"os/signal"
+	"sync"
+)
+
+// childSignals catches the signals that would end gox while it proxies a go
+// command, so that gox always gets to remove its temp files. Signals are forwarded to the command
+// running at the time, which decides how to exit, and remembered otherwise,
+// so that gox exits before starting the next one.
+type childSignals struct {
+	ch     chan os.Signal
+	mu     sync.Mutex
+	child  *os.Process // The running command, if any
+	caught os.Signal   // The first signal caught
+}
+
+// catchSignals starts catching terminationSignals until stop is called.
+func catchSignals() *childSignals {
+	s := &childSignals{ch: make(chan os.Signal, 1)}
+	signal.Notify(s.ch, terminationSignals...)
+	go s.forward()
+	return s
+}
+
+func (s *childSignals) forward() {
+	for sig := range s.ch {
+		s.mu.Lock()
+		if s.caught == nil {
+			s.caught = sig
+		}
+		if s.child != nil {
+			// A Ctrl-C in a terminal also reaches the command directly; a
+			// signal sent to gox alone, as by kill or a process manager,
+			// only through gox. Windows cannot send interrupts, only kill.
+			if err := s.child.Signal(sig); err != nil && sig != os.Interrupt {
+				s.child.Kill()
+			}
+		}
+		s.mu.Unlock()
+	}
+}
+
+// run runs cmd, forwarding signals to it. If a signal was caught before cmd
+// could start, it is not started and the status of a process ended by that
+// signal is returned.
+func (s *childSignals) run(cmd *exec.Cmd) error {
+	s.mu.Lock()
+	if err := s.interruptedLocked(); err != nil {
+		s.mu.Unlock()
+		return err
+	}
+	err := cmd.Start()
+	if err == nil {
+		s.child = cmd.Process
+	}
+	s.mu.Unlock()
+	if err != nil {
+		return err
+	}
+
+	err = cmd.Wait()
+	s.mu.Lock()
+	s.child = nil
+	s.mu.Unlock()
+	return err
+}
+
+// interrupted returns the exit status for the first signal caught, as an
+// error, or nil if none was.
+func (s *childSignals) interrupted() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.interruptedLocked()
+}
+
+func (s *childSignals) interruptedLocked() error {
+	if s.caught == nil {
+		return nil
+	}
+	return exitStatus(signalStatus(s.caught))
+}
+
+// stop restores the default handling of signals.
+func (s *childSignals) stop() {
+	signal.Stop(s.ch)
+	close(s.ch)
+}
@@ -0,0 +1,44 @@
+//go:build unix
+
+package main
+
+import (
+	"bufio"
+	"os/exec"
+	"syscall"
+	"testing"
+)
+
+func TestChildSignals(t *testing.T) {
+	signals := catchSignals()
+	defer signals.stop()
+
+	cmd := exec.Command("sh", "-c", `trap "exit 7" TERM; echo ready; while :; do sleep 0.1; done`)
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		t.Fatal(err)
+	}
+	done := make(chan error, 1)
+	go func() { done <- signals.run(cmd) }()
+
+	// A SIGTERM sent to gox alone reaches the command, and gox survives it
+	if _, err := bufio.NewReader(stdout).ReadString('\n'); err != nil {
+		t.Fatal(err)
+	}
+	if err := syscall.Kill(syscall.Getpid(), syscall.SIGTERM); err != nil {
+		t.Fatal(err)
+	}
+	if err := commandExitStatus(<-done); err != exitStatus(7) {
+		t.Errorf("command exited with %v, want exit status 7", err)
+	}
+
+	// Later commands are not started
+	want := exitStatus(128 + int(syscall.SIGTERM))
+	if err := signals.interrupted(); err != want {
+		t.Errorf("interrupted() = %v, want %v", err, want)
+	}
+	next := exec.Command("sh", "-c", "exit 0")
+	if err := signals.run(next); err != want || next.Process != nil {
+		t.Errorf("run after a signal = %v, started %v; want %v, not started", err, next.Process != nil, want)
+	}
+}
@@ -0,0 +1,175 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"flag"
+	"fmt"
+	"go/token"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// generateStubName is the file gen-stubs and init write the go:generate
+// directive to.
+const generateStubName = "generate.go"
+
+// generateStub returns the source of a generate.go file for package pkg,
+// whose go:generate directive runs command in the package directory.
+func generateStub(pkg, command string) string {
+	return "package " + pkg + `
+
+// Regenerate the code of this package's .gox files with "go generate ./..."
+// when not using gox run/build.
+//go:generate ` + command + "\n"
+}
+
+// stubsConfig holds configuration for the gen-stubs command.
+type stubsConfig struct {
+	goRun  bool // Run gox with go run instead of from PATH
+	dryRun bool // Print the files that would be written
+}
+
+// runGenStubs writes a generate.go file with a go:generate directive
+// running gox generate into each package with .gox files, so go generate
+// regenerates them for those who build with plain go. Packages whose .go
+// files already have such a directive are left alone, and so is an
+// existing generate.go without one.
+func runGenStubs(args []string) error {
+	cfg := &stubsConfig{}
+	fs := flag.NewFlagSet("gen-stubs", flag.ExitOnError)
+	fs.BoolVar(&cfg.goRun, "go-run", false, "run gox with go run at this gox version, so go generate does not need gox installed")
+	fs.BoolVar(&cfg.dryRun, "n", false, "print the files that would be written, without writing them")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	paths := fs.Args()
+	if len(paths) == 0 {
+		paths = []string{"./..."}
+	}
+
+	files, err := findGoxFiles(paths)
+	if err != nil {
+		return err
+	}
+	if len(files) == 0 {
+		fmt.Fprintln(os.Stderr, "No .gox files found")
+		return nil
+	}
+
+	command := "gox generate ."
+	if cfg.goRun {
+		command = "go run " + runtimeModule + "/cmd/gox@" + runtimeVersion() + " generate ."
+	}
+	byDir := make(map[string][]string)
+	for _, f := range files {
+		dir := filepath.Dir(f)
+		byDir[dir] = append(byDir[dir], f)
+	}
+	dirs := make([]string, 0, len(byDir))
+	for dir := range byDir {
+		dirs = append(dirs, dir)
+	}
+	sort.Strings(dirs)
+
+	for _, dir := range dirs {
+		created, err := writeGenerateStub(dir, byDir[dir], command, cfg.dryRun)
+		if err != nil {
+			return err
+		}
+		if created {
+			stub := filepath.Join(dir, generateStubName)
+			if cfg.dryRun {
+				fmt.Printf("would create %s\n", stub)
+			} else {
+				fmt.Printf("created %s\n", stub)
+			}
+		}
+	}
+	return nil
+}
+
+// writeGenerateStub writes the generate.go file of dir, whose .gox files are
+// goxFiles, and reports whether it did. dryRun only reports whether it would.
+func writeGenerateStub(dir string, goxFiles []string, command string, dryRun bool) (bool, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return false, err
+	}
+	stubExists := false
+	for _, e := range entries {
+		name := e.Name()
+		if e.IsDir() || !strings.HasSuffix(name, ".go") || isGeneratedFile(filepath.Join(dir, name)) {
+			continue
+		}
+		src, err := os.ReadFile(filepath.Join(dir, name))
+		if err != nil {
+			return false, err
+		}
+		if hasGoxGenerateDirective(src) {
+			return false, nil
+		}
+		stubExists = stubExists || name == generateStubName
+	}
+	stub := filepath.Join(dir, generateStubName)
+	if stubExists {
+		fmt.Fprintf(os.Stderr, "skipping %s (already exists without a gox go:generate directive)\n", stub)
+		return false, nil
+	}
+
+	pkg, err := stubPackage(goxFiles)
+	if err != nil {
+		return false, err
+	}
+	if dryRun {
+		return true, nil
+	}
+	if err := os.WriteFile(stub, []byte(generateStub(pkg, command)), 0644); err != nil {
+		return false, fmt.Errorf("writing %s: %w", stub, err)
+	}
+	return true, nil
+}
+
+// stubPackage returns the package of a directory's .gox files: that of its
+// first non-test file, or else of its test files without the _test suffix of
+// an external test package.
+func stubPackage(goxFiles []string) (string, error) {
+	fset := token.NewFileSet()
+	var testPkg string
+	for _, f := range goxFiles {
+		name, _, err := goxPackageName(fset, f)
+		if err != nil {
+			return "", fmt.Errorf("%s: %w", f, err)
+		}
+		if !strings.HasSuffix(f, "_test.gox") {
+			return name, nil
+		}
+		if testPkg == "" {
+			testPkg = strings.TrimSuffix(name, "_test")
+		}
+	}
+	return testPkg, nil
+}
+
+// hasGoxGenerateDirective reports whether src has a go:generate directive
+// running gox generate, from PATH or with go run.
+func hasGoxGenerateDirective(src []byte) bool {
+	scanner := bufio.NewScanner(bytes.NewReader(src))
+	for scanner.Scan() {
+		directive, ok := strings.CutPrefix(scanner.Text(), "//go:generate ")
+		if !ok {
+			continue
+		}
+		fields := strings.Fields(directive)
+		for i, field := range fields {
+			field, _, _ = strings.Cut(field, "@")
+			if path.Base(filepath.ToSlash(field)) == "gox" && i+1 < len(fields) && fields[i+1] == "generate" {
+				return true
+			}
+		}
+	}
+	return false
+}
@@ -0,0 +1,85 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestWriteGenerateStub(t *testing.T) {
+	dir := t.TempDir()
+	write := func(name, content string) string {
+		t.Helper()
+		path := filepath.Join(dir, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+		return path
+	}
+
+	// The stub takes the package of the non-test files
+	ui := []string{
+		write("ui/card_test.gox", "package ui_test\n"),
+		write("ui/card.gox", "package ui\n"),
+	}
+	write("ui/card_gox.go", "package ui\n\n//go:generate gox generate .\n")
+	created, err := writeGenerateStub(filepath.Join(dir, "ui"), ui, "gox generate .", false)
+	if err != nil || !created {
+		t.Fatalf("writeGenerateStub = %v, %v; want created", created, err)
+	}
+	data, err := os.ReadFile(filepath.Join(dir, "ui", "generate.go"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := generateStub("ui", "gox generate ."); string(data) != want {
+		t.Errorf("generate.go:\n%s\nwant:\n%s", data, want)
+	}
+
+	// Running again leaves the stub alone
+	if created, err := writeGenerateStub(filepath.Join(dir, "ui"), ui, "gox generate .", false); err != nil || created {
+		t.Errorf("second writeGenerateStub = %v, %v; want not created", created, err)
+	}
+
+	// As it does another file's directive, and a generate.go without one
+	tests := []struct {
+		name string
+		src  string
+	}{
+		{"doc.go", "package a\n\n//go:generate go run github.com/germtb/gox/cmd/gox@v0.9.0 generate .\n"},
+		{"generate.go", "package b\n\n//go:generate stringer -type=Kind\n"},
+	}
+	for _, tt := range tests {
+		pkg := filepath.Join(dir, tt.name[:len(tt.name)-len(".go")])
+		goxFile := write(filepath.Join(filepath.Base(pkg), "x.gox"), "package x\n")
+		write(filepath.Join(filepath.Base(pkg), tt.name), tt.src)
+		if created, err := writeGenerateStub(pkg, []string{goxFile}, "gox generate .", false); err != nil || created {
+			t.Errorf("%s: writeGenerateStub = %v, %v; want not created", tt.name, created, err)
+		}
+		if data, _ := os.ReadFile(filepath.Join(pkg, tt.name)); string(data) != tt.src {
+			t.Errorf("%s was changed: %q", tt.name, data)
+		}
+	}
+}
+
+func TestHasGoxGenerateDirective(t *testing.T) {
+	tests := []struct {
+		src  string
+		want bool
+	}{
+		{"//go:generate gox generate .", true},
+		{"//go:generate go run github.com/germtb/gox/cmd/gox generate ./...", true},
+		{"//go:generate go run github.com/germtb/gox/cmd/gox@latest generate .", true},
+		{"//go:generate go tool gox generate .", true},
+		{"//go:generate gox fmt -w .", false},
+		{"//go:generate stringer -type=gox", false},
+		{"// go:generate gox generate .", false},
+	}
+	for _, tt := range tests {
+		if got := hasGoxGenerateDirective([]byte("package a\n\n" + tt.src + "\n")); got != tt.want {
+			t.Errorf("hasGoxGenerateDirective(%q) = %v, want %v", tt.src, got, tt.want)
+		}
+	}
+}
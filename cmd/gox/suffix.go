@@ -0,0 +1,68 @@
+package main
+
+import (
+	"path/filepath"
+	"sync"
+
+	"github.com/germtb/gox/generator"
+)
+
+// layoutResult is the outcome of looking up where the files generated from
+// the .gox files of a directory go and how they are named.
+type layoutResult struct {
+	suffix string
+	dir    string
+	err    error
+}
+
+var (
+	layoutMu sync.Mutex
+	layouts  = make(map[string]layoutResult)
+)
+
+// lookupOutputLayout returns the suffix and the directory of the files
+// generated from the .gox files in dir, as set by the nearest .goxsuffix and
+// .goxoutdir files. Lookups are cached, since every generated path needs
+// one.
+func lookupOutputLayout(dir string) (suffix, outDir string, err error) {
+	key := dir
+	if abs, err := filepath.Abs(dir); err == nil {
+		key = abs
+	}
+	layoutMu.Lock()
+	defer layoutMu.Unlock()
+	r, ok := layouts[key]
+	if !ok {
+		r.suffix, r.err = generator.LookupSuffix(key)
+		var dirErr error
+		r.dir, dirErr = generator.LookupOutputDir(key)
+		if r.err == nil {
+			r.err = dirErr
+		}
+		layouts[key] = r
+	}
+	if r.dir == key {
+		// No output tree; keep relative paths relative
+		return r.suffix, dir, r.err
+	}
+	return r.suffix, r.dir, r.err
+}
+
+// outputSuffix returns the suffix of the files generated in dir, or the
+// default if its .goxsuffix file is invalid. checkOutputLayouts reports
+// such files before any output is named.
+func outputSuffix(dir string) string {
+	suffix, _, _ := lookupOutputLayout(dir)
+	return suffix
+}
+
+// checkOutputLayouts reports the first invalid .goxsuffix or .goxoutdir
+// file that applies to any of the .gox files.
+func checkOutputLayouts(files []string) error {
+	for _, file := range files {
+		if _, _, err := lookupOutputLayout(filepath.Dir(file)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,57 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"path/filepath"
+	"sort"
+
+	"github.com/germtb/gox/vet"
+)
+
+// runGoxVet runs the gox analyzers on the .gox files in paths, one package
+// directory at a time, and prints their diagnostics to out, as JSON lines if
+// asJSON is set. It returns the number of diagnostics.
+func runGoxVet(paths []string, out io.Writer, asJSON bool) (int, error) {
+	files, err := findGoxFiles(paths)
+	if err != nil {
+		return 0, fmt.Errorf("finding gox files: %w", err)
+	}
+
+	count := 0
+	for _, pkg := range groupByDir(files) {
+		diagnostics, err := vet.Run(pkg, vet.Analyzers, nil)
+		if err != nil {
+			return count, err
+		}
+		for _, d := range diagnostics {
+			if asJSON {
+				writeDiagnostics(out, vetDiagnostic(d))
+			} else {
+				fmt.Fprintln(out, d)
+			}
+		}
+		count += len(diagnostics)
+	}
+	return count, nil
+}
+
+// groupByDir groups files by directory, in directory order.
+func groupByDir(files []string) [][]string {
+	byDir := make(map[string][]string)
+	var dirs []string
+	for _, f := range files {
+		dir := filepath.Dir(f)
+		if _, ok := byDir[dir]; !ok {
+			dirs = append(dirs, dir)
+		}
+		byDir[dir] = append(byDir[dir], f)
+	}
+	sort.Strings(dirs)
+
+	groups := make([][]string, 0, len(dirs))
+	for _, dir := range dirs {
+		groups = append(groups, byDir[dir])
+	}
+	return groups
+}
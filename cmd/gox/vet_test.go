@@ -0,0 +1,15 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestGroupByDir(t *testing.T) {
+	files := []string{"ui/b.gox", "app.gox", "ui/a.gox", "cmd/main.gox"}
+	want := [][]string{{"app.gox"}, {"cmd/main.gox"}, {"ui/b.gox", "ui/a.gox"}}
+
+	if got := groupByDir(files); !reflect.DeepEqual(got, want) {
+		t.Errorf("groupByDir = %q, want %q", got, want)
+	}
+}
@@ -0,0 +1,155 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"os/signal"
+	"sort"
+	"time"
+)
+
+// fileStamp identifies a version of a file on disk.
+type fileStamp struct {
+	modTime time.Time
+	size    int64
+}
+
+// watchState tracks the files seen by the previous scan.
+type watchState struct {
+	stamps map[string]fileStamp
+	find   func(paths []string) ([]string, error) // Defaults to findGoxFiles
+}
+
+// scan finds the current files and reports which ones are new or modified
+// and which ones disappeared since the previous scan.
+func (w *watchState) scan(paths []string) (changed, removed []string, err error) {
+	find := w.find
+	if find == nil {
+		find = findGoxFiles
+	}
+	files, err := find(paths)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	current := make(map[string]fileStamp, len(files))
+	for _, f := range files {
+		info, err := os.Stat(f)
+		if err != nil {
+			continue // Removed between discovery and stat; picked up next scan
+		}
+		stamp := fileStamp{modTime: info.ModTime(), size: info.Size()}
+		current[f] = stamp
+		if prev, ok := w.stamps[f]; !ok || prev != stamp {
+			changed = append(changed, f)
+		}
+	}
+	for f := range w.stamps {
+		if _, ok := current[f]; !ok {
+			removed = append(removed, f)
+		}
+	}
+	sort.Strings(removed)
+
+	w.stamps = current
+	return changed, removed, nil
+}
+
+// watchGenerate regenerates .gox files whenever they change, polling every
+// cfg.watchInterval. Only changed files are regenerated. In overlay mode the
+// overlay JSON is rewritten after each batch so it always lists every file.
+// It runs until the process is interrupted or terminated.
+func watchGenerate(cfg *generateConfig) error {
+	interrupt := make(chan os.Signal, 1)
+	signal.Notify(interrupt, terminationSignals...)
+	defer signal.Stop(interrupt)
+	return watchGenerateUntil(cfg, interrupt)
+}
+
+// watchGenerateUntil is watchGenerate, returning once stop receives.
+func watchGenerateUntil(cfg *generateConfig, stop <-chan os.Signal) error {
+	state := &watchState{}
+	overlay := Overlay{Replace: make(map[string]string)}
+	targets := make(map[string]string) // .gox path -> overlay target path
+
+	if cfg.overlay && cfg.tempDir == "" {
+		tempDir, err := os.MkdirTemp("", "gox-overlay-*")
+		if err != nil {
+			return fmt.Errorf("creating temp dir: %w", err)
+		}
+		defer os.RemoveAll(tempDir)
+		cfg.tempDir = tempDir
+	}
+
+	if !cfg.quiet {
+		fmt.Fprintf(os.Stderr, "Watching %v for .gox changes (Ctrl+C to stop)\n", cfg.paths)
+	}
+
+	for {
+		changed, removed, err := state.scan(cfg.paths)
+		if err != nil {
+			return fmt.Errorf("finding files: %w", err)
+		}
+
+		if len(changed) > 0 || len(removed) > 0 {
+			for _, f := range changed {
+				if cfg.overlay {
+					targetPath, tempFile, err := generateOverlayFile(f, cfg)
+					if err != nil {
+						fmt.Fprintf(os.Stderr, "error: %v\n", err)
+						continue
+					}
+					overlay.Replace[targetPath] = tempFile
+					targets[f] = targetPath
+				} else if _, err := processFile(f, cfg); err != nil {
+					fmt.Fprintf(os.Stderr, "error: %s: %v\n", f, err)
+					continue
+				}
+				if !cfg.quiet {
+					fmt.Fprintf(os.Stderr, "generated %s\n", f)
+				}
+			}
+
+			for _, f := range removed {
+				if cfg.overlay {
+					if targetPath, ok := targets[f]; ok {
+						os.Remove(overlay.Replace[targetPath])
+						delete(overlay.Replace, targetPath)
+						delete(targets, f)
+					}
+				} else if err := cfg.removeOutput(f); err != nil {
+					fmt.Fprintf(os.Stderr, "error: %v\n", err)
+					continue
+				}
+				if !cfg.quiet {
+					fmt.Fprintf(os.Stderr, "removed %s\n", f)
+				}
+			}
+
+			if cfg.overlay {
+				if err := writeOverlay(overlay, cfg); err != nil {
+					return err
+				}
+			}
+		}
+
+		select {
+		case <-stop:
+			return nil
+		case <-time.After(cfg.watchInterval):
+		}
+	}
+}
+
+// removeOutput removes the generated file and source map of the removed
+// .gox file inputPath, unless the generated file was edited by hand, as
+// generate refuses to overwrite it.
+func (cfg *generateConfig) removeOutput(inputPath string) error {
+	outputPath := getOutputPath(inputPath, cfg.outputDir)
+	if err := cfg.checkOverwrite(outputPath, inputPath); err != nil {
+		return fmt.Errorf("keeping the output of removed %s: %w", inputPath, err)
+	}
+	os.Remove(outputPath)
+	os.Remove(outputPath + ".map")
+	return nil
+}
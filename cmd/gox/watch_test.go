@@ -0,0 +1,119 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestWatchStateScan(t *testing.T) {
+	dir := t.TempDir()
+	a := filepath.Join(dir, "a.gox")
+	b := filepath.Join(dir, "b.gox")
+	if err := os.WriteFile(a, []byte("package x\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	state := &watchState{}
+
+	changed, removed, err := state.scan([]string{dir})
+	if err != nil {
+		t.Fatalf("scan error: %v", err)
+	}
+	if len(changed) != 1 || changed[0] != a || len(removed) != 0 {
+		t.Fatalf("first scan: changed=%v removed=%v, want [%s] []", changed, removed, a)
+	}
+
+	// No changes
+	changed, removed, _ = state.scan([]string{dir})
+	if len(changed) != 0 || len(removed) != 0 {
+		t.Fatalf("idle scan: changed=%v removed=%v, want none", changed, removed)
+	}
+
+	// Modify a, add b
+	later := time.Now().Add(time.Second)
+	if err := os.WriteFile(a, []byte("package x\n\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	os.Chtimes(a, later, later)
+	if err := os.WriteFile(b, []byte("package x\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	changed, _, _ = state.scan([]string{dir})
+	if len(changed) != 2 {
+		t.Fatalf("after edit: changed=%v, want both files", changed)
+	}
+
+	// Remove a
+	os.Remove(a)
+	changed, removed, _ = state.scan([]string{dir})
+	if len(changed) != 0 || len(removed) != 1 || removed[0] != a {
+		t.Fatalf("after remove: changed=%v removed=%v, want [] [%s]", changed, removed, a)
+	}
+}
+
+func TestWatchRemoveOutput(t *testing.T) {
+	dir := t.TempDir()
+	input := filepath.Join(dir, "app.gox")
+	if err := os.WriteFile(input, []byte("package ui\n\nfunc A() gox.VNode { return <a /> }\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	output := filepath.Join(dir, "app_gox.go")
+	if _, err := processFile(input, &generateConfig{}); err != nil {
+		t.Fatal(err)
+	}
+	data, err := os.ReadFile(output)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Hand-edited output of a removed file is kept
+	edited := strings.Replace(string(data), `"a"`, `"b"`, 1)
+	if err := os.WriteFile(output, []byte(edited), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := (&generateConfig{}).removeOutput(input); err == nil || !strings.Contains(err.Error(), "edited by hand") {
+		t.Errorf("removeOutput on an edited file = %v, want an error naming the edits", err)
+	}
+	if got, _ := os.ReadFile(output); string(got) != edited {
+		t.Error("edited file was removed")
+	}
+
+	// Unedited output is removed
+	if err := os.WriteFile(output, data, 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := (&generateConfig{}).removeOutput(input); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(output); !os.IsNotExist(err) {
+		t.Errorf("output still exists after removeOutput: %v", err)
+	}
+}
+
+func TestWatchRemovesOverlayDir(t *testing.T) {
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "app.gox"), []byte("package ui\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	cfg := &generateConfig{
+		overlay:       true,
+		overlayFile:   filepath.Join(dir, "overlay.json"),
+		quiet:         true,
+		watchInterval: time.Millisecond,
+		paths:         []string{dir},
+	}
+	stop := make(chan os.Signal, 1)
+	stop <- os.Interrupt
+	if err := watchGenerateUntil(cfg, stop); err != nil {
+		t.Fatal(err)
+	}
+	if cfg.tempDir == "" {
+		t.Fatal("no overlay dir was created")
+	}
+	if _, err := os.Stat(cfg.tempDir); !os.IsNotExist(err) {
+		t.Errorf("overlay dir %s still exists after watch returned: %v", cfg.tempDir, err)
+	}
+}
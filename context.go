@@ -0,0 +1,86 @@
+package gox
+
+import "context"
+
+// ContextRenderer is a Renderer that honors a context: it should stop and
+// return ctx.Err() when ctx is canceled or its deadline passes, e.g. when
+// the client of a server-side render disconnects.
+type ContextRenderer interface {
+	Renderer
+	// RenderContext processes a VNode tree and produces output.
+	RenderContext(ctx context.Context, vnode VNode) error
+}
+
+// ContextRenderFunc is a function type that implements ContextRenderer.
+type ContextRenderFunc func(context.Context, VNode) error
+
+// Render implements the Renderer interface with context.Background().
+func (f ContextRenderFunc) Render(vnode VNode) error {
+	return f(context.Background(), vnode)
+}
+
+// RenderContext implements the ContextRenderer interface.
+func (f ContextRenderFunc) RenderContext(ctx context.Context, vnode VNode) error {
+	return f(ctx, vnode)
+}
+
+// ContextComponent is a component that receives the render context, for
+// cancellation and request-scoped values.
+type ContextComponent func(ctx context.Context, props Props) VNode
+
+// RenderContext is Render with a context. It returns ctx.Err() without
+// rendering if ctx is already done, and passes ctx to r if r is a
+// ContextRenderer.
+func RenderContext(ctx context.Context, r Renderer, root VNode) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	if u, ok := r.(UnsafeRenderer); !ok || !u.AllowsUnsafe() {
+		root = StripUnsafe(root)
+	}
+	return CallRenderer(ctx, r, root)
+}
+
+// CallRenderer calls r with ctx if r is a ContextRenderer, and without it
+// otherwise. Unlike RenderContext it renders vnode as is, so middleware
+// uses it to call next.
+func CallRenderer(ctx context.Context, r Renderer, vnode VNode) error {
+	if cr, ok := r.(ContextRenderer); ok {
+		return cr.RenderContext(ctx, vnode)
+	}
+	return r.Render(vnode)
+}
+
+// Expand calls the component of a component node and returns the tree it
+// renders, passing ctx to a ContextComponent. Other nodes are returned
+// unchanged, with false.
+func Expand(ctx context.Context, node VNode) (VNode, bool) {
+	switch c := node.Type.(type) {
+	case Component:
+		return c(node.Props), true
+	case ContextComponent:
+		return c(ctx, node.Props), true
+	}
+	return node, false
+}
+
+// WalkTreeContext is WalkTree with a context. It checks ctx before each node
+// and stops, returning ctx.Err(), once ctx is done.
+func WalkTreeContext(ctx context.Context, root VNode, walker Walker) error {
+	return walkNodeContext(ctx, root, walker, 0)
+}
+
+func walkNodeContext(ctx context.Context, node VNode, walker Walker, depth int) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	if !walker.Walk(node, depth) {
+		return nil
+	}
+	for _, child := range node.Children {
+		if err := walkNodeContext(ctx, child, walker, depth+1); err != nil {
+			return err
+		}
+	}
+	return nil
+}
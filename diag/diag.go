@@ -0,0 +1,123 @@
+// Package diag defines the coded diagnostics reported by gox tools.
+// Every diagnostic carries a stable code (GOX0001, ...) that "gox explain"
+// describes in detail and that editors can show alongside the message.
+package diag
+
+import (
+	"errors"
+	"fmt"
+)
+
+// Code identifies a kind of diagnostic. Codes are stable across releases:
+// a code is never reused for a different problem.
+type Code string
+
+// Parser diagnostics.
+const (
+	UnexpectedToken       Code = "GOX0001" // Token not valid at this position
+	ExpectedTagName       Code = "GOX0002" // '<' not followed by a tag name
+	MalformedTag          Code = "GOX0003" // Opening tag not closed with '>' or '/>'
+	MismatchedClosingTag  Code = "GOX0004" // </b> closing <a>
+	UnclosedElement       Code = "GOX0005" // Element never closed before end of file
+	SpreadAttribute       Code = "GOX0006" // {...props} in attribute position of a component
+	StandaloneAttrExpr    Code = "GOX0007" // {expr} in attribute position
+	InvalidAttributeValue Code = "GOX0008" // name= not followed by "string" or {expr}
+	InvalidTypeArguments  Code = "GOX0009" // Malformed [T] after a tag, or on an intrinsic element
+	VoidChildren          Code = "GOX0014" // Children of an element named by //gox:void
+	FragmentAttribute     Code = "GOX0016" // Attribute other than key on <Fragment>
+	IntrinsicTag          Code = "GOX0017" // Intrinsic tag that is not an element name, with strict intrinsics
+)
+
+// Lexer diagnostics, reported by the parser.
+const (
+	Unterminated Code = "GOX0015" // Attribute string or {expression} not closed before end of file
+)
+
+// Package diagnostics.
+const (
+	PackageMismatch Code = "GOX0010" // Package clause differs from the other files of the directory
+)
+
+// Generator diagnostics.
+const (
+	GoSyntax Code = "GOX0011" // Go code around or inside JSX does not parse
+)
+
+// Schema diagnostics.
+const (
+	UnknownElement   Code = "GOX0012" // Intrinsic tag the .goxschema does not declare
+	UnknownAttribute Code = "GOX0013" // Attribute the .goxschema does not allow on its element
+)
+
+// Diagnostic is a problem found in a .gox file. Line and Column are 1-indexed,
+// with Column in runes; a zero Line means the diagnostic has no position.
+// EndLine and EndColumn, if set, end the range of source it covers,
+// exclusive, such as the token it points at.
+type Diagnostic struct {
+	Code      Code
+	File      string
+	Line      int
+	Column    int
+	EndLine   int
+	EndColumn int
+	Message   string
+	Hint      string // Short suggestion for fixing the problem, if any
+	Fix       *Fix   // Edit that fixes the problem, if one is known
+}
+
+// Fix is an edit of the source that fixes the problem of a diagnostic: Text
+// replaces the source from Line:Column to EndLine:EndColumn, exclusive,
+// positions as those of a Diagnostic. The two are the same for an
+// insertion.
+type Fix struct {
+	Message   string // What the edit does, as "insert </div>"
+	Line      int
+	Column    int
+	EndLine   int
+	EndColumn int
+	Text      string
+}
+
+// New creates a Diagnostic with a formatted message.
+func New(code Code, file string, line, column int, format string, args ...any) *Diagnostic {
+	return &Diagnostic{
+		Code:    code,
+		File:    file,
+		Line:    line,
+		Column:  column,
+		Message: fmt.Sprintf(format, args...),
+	}
+}
+
+// Position returns "file:line:col", omitting the parts that are unknown.
+func (d *Diagnostic) Position() string {
+	if d.Line == 0 {
+		return d.File
+	}
+	pos := fmt.Sprintf("%d:%d", d.Line, d.Column)
+	if d.File != "" {
+		pos = d.File + ":" + pos
+	}
+	return pos
+}
+
+// Error formats the diagnostic as "file:line:col: message [CODE]".
+func (d *Diagnostic) Error() string {
+	msg := d.Message
+	if d.Code != "" {
+		msg += " [" + string(d.Code) + "]"
+	}
+	if pos := d.Position(); pos != "" {
+		return pos + ": " + msg
+	}
+	return msg
+}
+
+// As returns the Diagnostic in err's chain, if any.
+func As(err error) (*Diagnostic, bool) {
+	var d *Diagnostic
+	if errors.As(err, &d) {
+		return d, true
+	}
+	return nil, false
+}
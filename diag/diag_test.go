@@ -0,0 +1,78 @@
+package diag
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+)
+
+func TestDiagnosticError(t *testing.T) {
+	tests := []struct {
+		name string
+		d    *Diagnostic
+		want string
+	}{
+		{
+			name: "full position",
+			d:    New(UnclosedElement, "app.gox", 3, 9, "unclosed element <%s>", "div"),
+			want: "app.gox:3:9: unclosed element <div> [GOX0005]",
+		},
+		{
+			name: "no file",
+			d:    New(UnexpectedToken, "", 1, 2, "unexpected token"),
+			want: "1:2: unexpected token [GOX0001]",
+		},
+		{
+			name: "no position or code",
+			d:    &Diagnostic{Message: "something failed"},
+			want: "something failed",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.d.Error(); got != tt.want {
+				t.Errorf("Error() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestAs(t *testing.T) {
+	d := New(MalformedTag, "app.gox", 1, 1, "expected '>'")
+	wrapped := fmt.Errorf("parsing: %w", d)
+
+	got, ok := As(wrapped)
+	if !ok || got != d {
+		t.Errorf("As(wrapped) = %v, %v; want the diagnostic", got, ok)
+	}
+	if _, ok := As(fmt.Errorf("plain")); ok {
+		t.Error("As(plain error) should report false")
+	}
+}
+
+func TestEveryCodeIsExplained(t *testing.T) {
+	codes := []Code{
+		UnexpectedToken, ExpectedTagName, MalformedTag, MismatchedClosingTag,
+		UnclosedElement, SpreadAttribute, StandaloneAttrExpr, InvalidAttributeValue,
+		InvalidTypeArguments, PackageMismatch, GoSyntax, UnknownElement, UnknownAttribute, VoidChildren,
+		Unterminated, FragmentAttribute, IntrinsicTag,
+	}
+	for _, code := range codes {
+		e, ok := Explain(code)
+		if !ok {
+			t.Errorf("%s has no explanation", code)
+			continue
+		}
+		if e.Title == "" || !strings.Contains(e.Details, "example") {
+			t.Errorf("%s explanation is missing a title or an example", code)
+		}
+	}
+
+	if len(Codes()) != len(codes) {
+		t.Errorf("Codes() returned %d codes, want %d", len(Codes()), len(codes))
+	}
+	if _, ok := Explain("gox0005"); !ok {
+		t.Error("Explain should accept lowercase codes")
+	}
+}
@@ -0,0 +1,389 @@
+package diag
+
+import (
+	"sort"
+	"strings"
+)
+
+// Explanation is the long-form description of a diagnostic code.
+type Explanation struct {
+	Code    Code
+	Title   string
+	Details string // Several paragraphs, with examples indented by a tab
+}
+
+// String renders the explanation for display in a terminal.
+func (e Explanation) String() string {
+	return string(e.Code) + ": " + e.Title + "\n\n" + strings.TrimSpace(e.Details) + "\n"
+}
+
+// Explain returns the explanation for code, if it is known.
+func Explain(code Code) (Explanation, bool) {
+	e, ok := explanations[Code(strings.ToUpper(string(code)))]
+	return e, ok
+}
+
+// Codes returns every known code in order.
+func Codes() []Code {
+	codes := make([]Code, 0, len(explanations))
+	for code := range explanations {
+		codes = append(codes, code)
+	}
+	sort.Slice(codes, func(i, j int) bool { return codes[i] < codes[j] })
+	return codes
+}
+
+var explanations = map[Code]Explanation{}
+
+func register(e Explanation) {
+	explanations[e.Code] = e
+}
+
+func init() {
+	register(Explanation{
+		Code:  UnexpectedToken,
+		Title: "unexpected token",
+		Details: `
+The parser found a token that cannot appear at this position. This usually
+means a tag or expression was cut short, or that a character with special
+meaning in JSX ('<', '>', '{', '}') appears where plain text was intended.
+
+Erroneous example:
+
+	return <div>a > b</div>
+
+To use these characters as text, put them in a string expression:
+
+	return <div>{"a > b"}</div>
+`,
+	})
+
+	register(Explanation{
+		Code:  ExpectedTagName,
+		Title: "expected tag name",
+		Details: `
+A '<' that starts an element must be followed directly by a tag name:
+a lowercase name for intrinsic elements or a capitalized component name.
+
+Erroneous example:
+
+	return < div></div>
+
+Corrected:
+
+	return <div></div>
+
+Use <>...</> for a fragment without a tag.
+`,
+	})
+
+	register(Explanation{
+		Code:  MalformedTag,
+		Title: "malformed tag",
+		Details: `
+An opening tag must end with '>' (the element has children) or '/>' (the
+element is self-closing). Anything else after the attributes is an error.
+
+Erroneous example:
+
+	return <input value={v} / >
+
+Corrected:
+
+	return <input value={v} />
+`,
+	})
+
+	register(Explanation{
+		Code:  MismatchedClosingTag,
+		Title: "mismatched closing tag",
+		Details: `
+Every closing tag must name the element it closes. Elements nest strictly:
+the innermost open element has to be closed first. A closing tag of an
+element further out ends the ones inside it, and the editor offers to
+insert the missing closing tag.
+
+Erroneous example:
+
+	return <div><span>Hi</div></span>
+
+Corrected:
+
+	return <div><span>Hi</span></div>
+`,
+	})
+
+	register(Explanation{
+		Code:  UnclosedElement,
+		Title: "unclosed element",
+		Details: `
+The file ended while an element or fragment was still open, or a fragment's
+'</>' came before the closing tag of an element inside it. Every element
+needs either a matching closing tag or the self-closing '/>' form, and every
+fragment a '</>'.
+
+Erroneous example:
+
+	func App() gox.VNode {
+		return <div>
+	}
+
+Corrected:
+
+	func App() gox.VNode {
+		return <div></div>
+	}
+`,
+	})
+
+	register(Explanation{
+		Code:  SpreadAttribute,
+		Title: "spread attributes are not supported on components",
+		Details: `
+Intrinsic elements take spread attributes ({...props}) of gox.Props values,
+merged with the other attributes in order. Components take a typed props
+struct, which cannot be merged, so they do not.
+
+Erroneous example:
+
+	return <Button {...props} />
+
+Pass the attributes explicitly instead:
+
+	return <Button label={props.Label} onClick={props.OnClick} />
+`,
+	})
+
+	register(Explanation{
+		Code:  StandaloneAttrExpr,
+		Title: "standalone expression in attribute position",
+		Details: `
+Inside a tag, an expression must be the value of a named attribute. A bare
+{expr} between attributes has no name to bind to.
+
+Erroneous example:
+
+	return <div {attrs}></div>
+
+Corrected:
+
+	return <div class={attrs.Class}></div>
+`,
+	})
+
+	register(Explanation{
+		Code:  InvalidAttributeValue,
+		Title: "invalid attribute value",
+		Details: `
+After name= an attribute value must be a string, in double or single quotes
+or in backquotes, or an expression in braces. Unquoted values are not
+supported.
+An optional attribute, name?=, takes an expression only: a string is never
+nil.
+
+Erroneous example:
+
+	return <div class=box></div>
+
+Corrected:
+
+	return <div class="box"></div>
+	return <div class={className}></div>
+`,
+	})
+
+	register(Explanation{
+		Code:  InvalidTypeArguments,
+		Title: "invalid type arguments",
+		Details: `
+Generic components are instantiated with type arguments in brackets directly
+after the component name. Only components take type arguments, the brackets
+must be closed, and a closing tag that repeats them must repeat them exactly.
+
+Erroneous examples:
+
+	return <div[string]></div>
+	return <List[string] items={names}></List[int]>
+
+Corrected:
+
+	return <List[string] items={names} />
+	return <List[string] items={names}></List>
+`,
+	})
+
+	register(Explanation{
+		Code:  VoidChildren,
+		Title: "children of a void element",
+		Details: `
+A //gox:void directive before the package clause names the void elements of
+a file, which never have children, as HTML's <input> and <br>. Writing one
+with children, nested or in a children attribute, is an error, and gox fmt
+always writes them self-closing.
+
+Erroneous example:
+
+	//gox:void br input
+	package form
+
+	func Field() gox.VNode {
+		return <label>Name <input>name</input></label>
+	}
+
+Corrected:
+
+	//gox:void br input
+	package form
+
+	func Field() gox.VNode {
+		return <label>Name <input value="name" /></label>
+	}
+`,
+	})
+
+	register(Explanation{
+		Code:  Unterminated,
+		Title: "unterminated string or expression",
+		Details: `
+A quoted attribute value needs its closing quote, and a {expression}, in an
+attribute or among children, its closing brace. Otherwise the string or
+expression runs to the end of the file. The error points at where it starts.
+
+Erroneous examples:
+
+	return <input value="name />
+	return <p>{user.Name</p>
+
+Corrected:
+
+	return <input value="name" />
+	return <p>{user.Name}</p>
+`,
+	})
+
+	register(Explanation{
+		Code:  FragmentAttribute,
+		Title: "attribute on a fragment",
+		Details: `
+<Fragment>...</Fragment> is the long form of a fragment <>...</>, for giving
+it a key when it is an item of a list. A fragment renders no element of its
+own, so key is the only attribute it takes, and Fragment takes no type
+arguments.
+
+Erroneous example:
+
+	return <Fragment key={item.ID} class="item">
+		<dt>{item.Term}</dt>
+		<dd>{item.Definition}</dd>
+	</Fragment>
+
+Corrected:
+
+	return <Fragment key={item.ID}>
+		<dt class="item">{item.Term}</dt>
+		<dd class="item">{item.Definition}</dd>
+	</Fragment>
+`,
+	})
+
+	register(Explanation{
+		Code:  IntrinsicTag,
+		Title: "intrinsic tag is not an element name",
+		Details: `
+Tools that parse with strict intrinsics, such as "gox parse -strict", check
+that every intrinsic element is named as HTML, SVG and custom elements are:
+a lower case ASCII letter followed by ASCII letters, digits and hyphens. A
+tag such as <card_item> or <_row> is rendered as an element of that name,
+which is rarely what was meant; components start with an upper case
+letter.
+
+Erroneous example:
+
+	return <card_item title={title} />
+
+Corrected:
+
+	return <CardItem title={title} />
+`,
+	})
+
+	register(Explanation{
+		Code:  PackageMismatch,
+		Title: "package clause does not match the directory",
+		Details: `
+A .gox file becomes a .go file in the same directory, so it must declare the
+same package as the other .go and .gox files there. Files ending in _test.gox
+may also declare the external test package, with a _test suffix. Files
+excluded by build constraints are not compared.
+
+Erroneous example, next to list.go declaring package ui:
+
+	// ui/view.gox
+	package views
+
+Corrected:
+
+	// ui/view.gox
+	package ui
+`,
+	})
+
+	register(Explanation{
+		Code:  GoSyntax,
+		Title: "syntax error in Go code",
+		Details: `
+The Go code of a .gox file, around JSX and inside its braces, is parsed once
+the file is generated, and a syntax error is reported at its position in the
+.gox file rather than later, by the compiler, in the generated file.
+
+Erroneous example:
+
+	func Greeting(name string) gox.VNode {
+		return <p>Hello, {name +}</p>
+	}
+
+Corrected:
+
+	func Greeting(name string) gox.VNode {
+		return <p>Hello, {name + "!"}</p>
+	}
+`,
+	})
+
+	register(Explanation{
+		Code:  UnknownElement,
+		Title: "intrinsic element not in the schema",
+		Details: `
+A .goxschema file lists the intrinsic elements a renderer supports. With
+"strict": true, generating a file that uses any other lowercase tag fails;
+otherwise gox vet reports it. Components, with upper-case or qualified
+tags, are not checked.
+
+Erroneous example, with a schema declaring box, text and input:
+
+	return <box><span>Hello</span></box>
+
+Corrected:
+
+	return <box><text>Hello</text></box>
+`,
+	})
+
+	register(Explanation{
+		Code:  UnknownAttribute,
+		Title: "attribute not in the schema",
+		Details: `
+A .goxschema file lists the attributes each intrinsic element accepts, and
+those every element accepts. With "strict": true, generating a file that
+sets any other attribute fails; otherwise gox vet reports it.
+
+Erroneous example, with a schema allowing only direction and gap on box:
+
+	return <box align="center"></box>
+
+Corrected:
+
+	return <box direction="row"></box>
+`,
+	})
+}
@@ -1,12 +1,35 @@
 package gox
 
+import "reflect"
+
 // Element creates a VNode for an element (intrinsic or component).
 // typ can be a string (for intrinsic elements like "div", "span")
 // or a Component function.
+//
+// Children of an intrinsic element can also be passed as a "children" prop,
+// which is useful when composing elements programmatically. The prop is
+// converted with Children and used only if no children arguments are given:
+// nested children take precedence. Either way, "children" is removed from
+// the element's props, without modifying the props map passed in. A
+// component keeps its "children" prop, which is how it receives children.
+//
+// Props set to Omit are left out too, and so are false and nil props of
+// intrinsic elements, which have no attribute to render: <input
+// disabled={false} /> has no disabled prop.
 func Element(typ any, props Props, children ...VNode) VNode {
 	if props == nil {
 		props = Props{}
 	}
+	_, intrinsic := typ.(string)
+	if c, ok := props["children"]; ok && intrinsic && len(children) == 0 {
+		children = Children(c)
+	}
+	for k, v := range props {
+		if k == "children" && intrinsic || dropped(v, intrinsic) {
+			props = kept(props, intrinsic)
+			break
+		}
+	}
 	return VNode{
 		Type:     typ,
 		Props:    props,
@@ -14,7 +37,85 @@ func Element(typ any, props Props, children ...VNode) VNode {
 	}
 }
 
+// kept returns a copy of props without the props Element leaves out,
+// children among them on an intrinsic element.
+func kept(props Props, intrinsic bool) Props {
+	rest := make(Props, len(props))
+	for k, v := range props {
+		if !(k == "children" && intrinsic) && !dropped(v, intrinsic) {
+			rest[k] = v
+		}
+	}
+	return rest
+}
+
+// dropped reports whether Element leaves out a prop set to v.
+func dropped(v any, intrinsic bool) bool {
+	switch v {
+	case Omit:
+		return true
+	case false, nil:
+		return intrinsic
+	}
+	return false
+}
+
+// Omit is a prop value that leaves the prop out of an element, for setting
+// a prop conditionally, as in gox.Props{"title": title} with title set to
+// gox.Omit when there is none.
+var Omit any = omitted{}
+
+type omitted struct{}
+
+// Optional returns value, or Omit if value is nil or a nil pointer, map,
+// slice, function, channel or interface. An optional attribute,
+// name?={value}, generates it, so the prop is set only when there is a
+// value.
+func Optional(value any) any {
+	if value == nil {
+		return Omit
+	}
+	switch v := reflect.ValueOf(value); v.Kind() {
+	case reflect.Pointer, reflect.Map, reflect.Slice, reflect.Func, reflect.Chan, reflect.Interface:
+		if v.IsNil() {
+			return Omit
+		}
+	}
+	return value
+}
+
 // E is a shorthand alias for Element.
 func E(typ any, props Props, children ...VNode) VNode {
 	return Element(typ, props, children...)
 }
+
+// MergeProps returns a new Props with the props of each map in order, so a
+// prop set by a later map wins. <div {...a} id="x" {...b} /> generates
+// MergeProps(a, Props{"id": "x"}, b). Nil maps are skipped.
+func MergeProps(props ...Props) Props {
+	n := 0
+	for _, p := range props {
+		n += len(p)
+	}
+	merged := make(Props, n)
+	for _, p := range props {
+		for k, v := range p {
+			merged[k] = v
+		}
+	}
+	return merged
+}
+
+// Children converts the value of a children prop to a list of children.
+// A []VNode is returned as-is, nil yields no children, and any other value
+// is converted with V to a single child.
+func Children(value any) []VNode {
+	switch v := value.(type) {
+	case []VNode:
+		return v
+	case nil:
+		return nil
+	default:
+		return []VNode{V(v)}
+	}
+}
@@ -0,0 +1,245 @@
+package formatter
+
+import (
+	"fmt"
+	"go/format"
+	"go/scanner"
+	"go/token"
+	"strings"
+	"unicode"
+
+	"github.com/germtb/gox/ast"
+)
+
+// equivalent reports, as an error, the first difference between the source
+// AST and the AST of the formatted output. Differences the formatter makes on
+// purpose are ignored: layout of Go code and expressions, whitespace in text
+// that does not render, and empty elements written self-closing.
+func equivalent(src, formatted *ast.GoxFile) error {
+	if src.Package != formatted.Package {
+		return fmt.Errorf("package %s became %s", src.Package, formatted.Package)
+	}
+	if len(src.Nodes) != len(formatted.Nodes) {
+		return fmt.Errorf("%d top-level nodes became %d", len(src.Nodes), len(formatted.Nodes))
+	}
+	for i, node := range src.Nodes {
+		if err := equivalentNodes(node, formatted.Nodes[i]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func equivalentNodes(a, b ast.Node) error {
+	switch a := a.(type) {
+	case *ast.GoCode:
+		b, ok := b.(*ast.GoCode)
+		if !ok {
+			return mismatch(a, "Go code became %s", describe(b))
+		}
+		if !sameGo(normalizeGo(a.Value), normalizeGo(b.Value)) {
+			return mismatch(a, "Go code changed")
+		}
+		return nil
+	case *ast.JSXElement, *ast.JSXFragment:
+		child, ok := b.(ast.JSXChild)
+		if !ok {
+			return mismatch(a, "%s became %s", describe(a), describe(b))
+		}
+		return equivalentChildren(a, []ast.JSXChild{a.(ast.JSXChild)}, []ast.JSXChild{child})
+	}
+	return fmt.Errorf("unexpected node %T", a)
+}
+
+// equivalentChildren compares the children of parent, ignoring text that
+// renders nothing.
+func equivalentChildren(parent ast.Node, a, b []ast.JSXChild) error {
+	a, b = significant(a), significant(b)
+	if len(a) != len(b) {
+		return mismatch(parent, "%s has %d children after formatting, want %d", describe(parent), len(b), len(a))
+	}
+	for i := range a {
+		if err := equivalentChild(a[i], b[i]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func equivalentChild(a, b ast.JSXChild) error {
+	switch a := a.(type) {
+	case *ast.JSXText:
+		b, ok := b.(*ast.JSXText)
+		if !ok || a.Content() != b.Content() {
+			return mismatch(a, "text %q changed", strings.TrimSpace(a.Value))
+		}
+	case *ast.JSXExpression:
+		b, ok := b.(*ast.JSXExpression)
+		if !ok || !sameGo(a.Expression, b.Expression) {
+			return mismatch(a, "expression {%s} changed", strings.TrimSpace(a.Expression))
+		}
+	case *ast.JSXComment:
+		b, ok := b.(*ast.JSXComment)
+		if !ok || !sameGo(a.Text, b.Text) {
+			return mismatch(a, "comment {%s} changed", a.Text)
+		}
+	case *ast.JSXFragment:
+		b, ok := b.(*ast.JSXFragment)
+		if !ok {
+			return mismatch(a, "fragment became %s", describe(b))
+		}
+		if len(a.Attributes) != len(b.Attributes) {
+			return mismatch(a, "fragment has %d attributes after formatting, want %d", len(b.Attributes), len(a.Attributes))
+		}
+		for i, attr := range a.Attributes {
+			if err := equivalentAttributes(attr, b.Attributes[i]); err != nil {
+				return err
+			}
+		}
+		return equivalentChildren(a, a.Children, b.Children)
+	case *ast.JSXElement:
+		b, ok := b.(*ast.JSXElement)
+		if !ok || a.Tag != b.Tag || !sameGo(a.TypeArgs, b.TypeArgs) {
+			return mismatch(a, "%s became %s", describe(a), describe(b))
+		}
+		if len(a.Attributes) != len(b.Attributes) {
+			return mismatch(a, "%s has %d attributes after formatting, want %d", describe(a), len(b.Attributes), len(a.Attributes))
+		}
+		for i, attr := range a.Attributes {
+			if err := equivalentAttributes(attr, b.Attributes[i]); err != nil {
+				return err
+			}
+		}
+		return equivalentChildren(a, a.Children, b.Children)
+	}
+	return nil
+}
+
+func equivalentAttributes(a, b ast.Attribute) error {
+	switch a := a.(type) {
+	case *ast.SpreadAttribute:
+		if b, ok := b.(*ast.SpreadAttribute); ok && sameGo(a.Expression, b.Expression) {
+			return nil
+		}
+		return mismatch(a, "spread {...%s} changed", a.Expression)
+	case *ast.StringAttribute:
+		if b, ok := b.(*ast.StringAttribute); ok && a.Key == b.Key && a.Value == b.Value {
+			return nil
+		}
+		return mismatch(a, "attribute %s changed", a.Key)
+	case *ast.ExpressionAttribute:
+		b, ok := b.(*ast.ExpressionAttribute)
+		if !ok || a.Key != b.Key || a.Optional != b.Optional || (a.Parts == nil) != (b.Parts == nil) {
+			return mismatch(a, "attribute %s changed", a.Key)
+		}
+		if a.Parts == nil {
+			if !sameGo(a.Expression, b.Expression) {
+				return mismatch(a, "attribute %s changed", a.Key)
+			}
+			return nil
+		}
+		// JSX in the expression is compared like any other
+		if len(a.Parts) != len(b.Parts) {
+			return mismatch(a, "attribute %s changed", a.Key)
+		}
+		for i, part := range a.Parts {
+			if err := equivalentNodes(part, b.Parts[i]); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// significant returns children without text that renders nothing.
+func significant(children []ast.JSXChild) []ast.JSXChild {
+	var out []ast.JSXChild
+	for _, child := range children {
+		if text, ok := child.(*ast.JSXText); ok && text.Content() == "" {
+			continue
+		}
+		out = append(out, child)
+	}
+	return out
+}
+
+// collapseSpace trims s and replaces each run of whitespace with a space.
+func collapseSpace(s string) string {
+	return strings.Join(strings.FieldsFunc(s, unicode.IsSpace), " ")
+}
+
+// normalizeGo formats Go code the way formatGoCode does, so changes made by
+// gofmt, such as sorting imports, are not reported.
+func normalizeGo(code string) string {
+	if strings.HasPrefix(strings.TrimSpace(code), "package ") {
+		if formatted, err := format.Source([]byte(code)); err == nil {
+			return string(formatted)
+		}
+	}
+	return code
+}
+
+// sameGo reports whether two fragments of Go code have the same tokens.
+// Semicolons are ignored, since gofmt turns them into newlines, as is the
+// whitespace inside comments.
+func sameGo(a, b string) bool {
+	ta, tb := goTokens(a), goTokens(b)
+	if len(ta) != len(tb) {
+		return false
+	}
+	for i := range ta {
+		if ta[i] != tb[i] {
+			return false
+		}
+	}
+	return true
+}
+
+func goTokens(code string) []string {
+	var s scanner.Scanner
+	fset := token.NewFileSet()
+	src := []byte(code)
+	// Errors are ignored: fragments of Go code around JSX need not be
+	// complete, and scanning both sides the same way is enough.
+	s.Init(fset.AddFile("", -1, len(src)), src, nil, scanner.ScanComments)
+	var tokens []string
+	for {
+		_, tok, lit := s.Scan()
+		switch tok {
+		case token.EOF:
+			return tokens
+		case token.SEMICOLON:
+			continue
+		case token.COMMENT:
+			lit = collapseSpace(lit)
+		}
+		tokens = append(tokens, tok.String()+" "+lit)
+	}
+}
+
+func describe(node interface{ GetRange() ast.Range }) string {
+	switch n := node.(type) {
+	case *ast.JSXElement:
+		if n.TypeArgs != "" {
+			return "<" + n.Tag + "[" + strings.TrimSpace(n.TypeArgs) + "]>"
+		}
+		return "<" + n.Tag + ">"
+	case *ast.JSXFragment:
+		return "fragment"
+	case *ast.JSXText:
+		return "text"
+	case *ast.JSXExpression:
+		return "expression"
+	case *ast.JSXComment:
+		return "comment"
+	case *ast.GoCode:
+		return "Go code"
+	}
+	return fmt.Sprintf("%T", node)
+}
+
+// mismatch returns an error at the position of node in the source.
+func mismatch(node interface{ GetRange() ast.Range }, msg string, args ...any) error {
+	start := node.GetRange().Start
+	return fmt.Errorf("%d:%d: %s", start.Line, start.Column, fmt.Sprintf(msg, args...))
+}
@@ -3,11 +3,18 @@ package formatter
 
 import (
 	"bytes"
+	"errors"
+	"fmt"
 	"go/format"
+	goparser "go/parser"
+	"go/printer"
+	"go/token"
+	"io"
 	"strings"
 	"unicode"
 
 	"github.com/germtb/gox/ast"
+	"github.com/germtb/gox/parser"
 )
 
 // Options configures the formatter.
@@ -18,6 +25,11 @@ type Options struct {
 	UseTabs bool
 	// MaxLineLength is the target max line length before wrapping attributes.
 	MaxLineLength int
+	// NormalizeSelfClosing writes elements without children as self-closing,
+	// so <div></div> becomes <div />. Intrinsic elements that are written
+	// with a closing tag even when empty, such as <script> and <textarea>,
+	// are left alone.
+	NormalizeSelfClosing bool
 }
 
 // DefaultOptions returns sensible defaults.
@@ -29,11 +41,26 @@ func DefaultOptions() *Options {
 	}
 }
 
+// Validate reports whether the options are usable.
+func (o *Options) Validate() error {
+	if o.TabWidth < 0 {
+		return fmt.Errorf("invalid TabWidth %d: must not be negative", o.TabWidth)
+	}
+	if !o.UseTabs && o.TabWidth == 0 {
+		return errors.New("TabWidth must be positive when UseTabs is false")
+	}
+	if o.MaxLineLength < 0 {
+		return fmt.Errorf("invalid MaxLineLength %d: must not be negative", o.MaxLineLength)
+	}
+	return nil
+}
+
 // Formatter formats .gox files.
 type Formatter struct {
-	opts   *Options
-	buf    bytes.Buffer
-	indent int
+	opts          *Options
+	buf           bytes.Buffer
+	indent        int
+	preserveSpace bool // //gox:preserve-whitespace: JSX children are written as they are
 }
 
 // New creates a new Formatter.
@@ -50,10 +77,78 @@ func Format(file *ast.GoxFile, opts *Options) ([]byte, error) {
 	return f.Format(file)
 }
 
-// Format formats the AST back to source code.
+// Source formats .gox source code, parsing it first. It mirrors go/format.Source:
+// src is expected to be a complete .gox file and the result is the formatted
+// file. Parse errors are returned with line:column positions. Like gofmt, the
+// output is parsed again and compared to the source AST before it is
+// returned, so a formatter bug can never hand back source that no longer
+// parses or that means something else. A file whose first line ends in
+// \r\n is formatted with \r\n line endings throughout. A file with the
+// //gox:no-format directive is returned as it is, once it parses. A nil
+// opts uses DefaultOptions.
+func Source(src []byte, opts *Options) ([]byte, error) {
+	if opts == nil {
+		opts = DefaultOptions()
+	}
+	if err := opts.Validate(); err != nil {
+		return nil, err
+	}
+
+	orig := src
+	crlf := usesCRLF(src)
+	if crlf {
+		src = bytes.ReplaceAll(src, []byte("\r\n"), []byte("\n"))
+	}
+
+	file, err := parser.Parse("", src)
+	if err != nil {
+		return nil, err
+	}
+	if file.Directives.NoFormat {
+		return orig, nil
+	}
+
+	formatted, err := New(opts).Format(file)
+	if err != nil {
+		return nil, err
+	}
+
+	reparsed, err := parser.Parse("", formatted)
+	if err != nil {
+		return nil, fmt.Errorf("formatted output does not parse: %w", err)
+	}
+	if err := equivalent(file, reparsed); err != nil {
+		return nil, fmt.Errorf("formatted output differs from the source: %w", err)
+	}
+	if crlf {
+		formatted = bytes.ReplaceAll(formatted, []byte("\n"), []byte("\r\n"))
+	}
+	return formatted, nil
+}
+
+// usesCRLF reports whether the first line of src ends in \r\n.
+func usesCRLF(src []byte) bool {
+	i := bytes.IndexByte(src, '\n')
+	return i > 0 && src[i-1] == '\r'
+}
+
+// Fprint formats a parsed .gox file and writes the result to dst.
+func Fprint(dst io.Writer, file *ast.GoxFile, opts *Options) error {
+	formatted, err := Format(file, opts)
+	if err != nil {
+		return err
+	}
+	_, err = dst.Write(formatted)
+	return err
+}
+
+// Format formats the AST back to source code. In a file with the
+// //gox:preserve-whitespace directive, the text and children of elements are
+// kept on their lines as written, since their whitespace renders.
 func (f *Formatter) Format(file *ast.GoxFile) ([]byte, error) {
 	f.buf.Reset()
 	f.indent = 0
+	f.preserveSpace = file.Directives.PreserveWhitespace
 
 	for _, node := range file.Nodes {
 		f.formatNode(node)
@@ -158,6 +253,11 @@ func (f *Formatter) formatJSXElement(elem *ast.JSXElement, isChild bool) {
 	// Opening tag
 	f.buf.WriteString("<")
 	f.buf.WriteString(elem.Tag)
+	if elem.TypeArgs != "" {
+		f.buf.WriteString("[")
+		f.buf.WriteString(formatTypeArgs(elem.TypeArgs))
+		f.buf.WriteString("]")
+	}
 
 	// Attributes
 	if len(elem.Attributes) > 0 {
@@ -180,12 +280,25 @@ func (f *Formatter) formatJSXElement(elem *ast.JSXElement, isChild bool) {
 	}
 
 	// Self-closing or with children
-	if elem.SelfClosing {
+	if elem.SelfClosing || elem.Void || f.opts.NormalizeSelfClosing && len(elem.Children) == 0 && canSelfClose(elem.Tag) {
 		f.buf.WriteString(" />")
 	} else if len(elem.Children) == 0 {
 		f.buf.WriteString("></")
 		f.buf.WriteString(elem.Tag)
 		f.buf.WriteString(">")
+	} else if f.preserveSpace {
+		f.buf.WriteString(">")
+		f.formatJSXChildrenAsWritten(elem.Children)
+		f.buf.WriteString("</")
+		f.buf.WriteString(elem.Tag)
+		f.buf.WriteString(">")
+	} else if text, ok := elem.Children[0].(*ast.JSXText); ok && text.Raw {
+		// Raw content is written as is, indentation included
+		f.buf.WriteString(">")
+		f.buf.WriteString(text.Value)
+		f.buf.WriteString("</")
+		f.buf.WriteString(elem.Tag)
+		f.buf.WriteString(">")
 	} else if inline {
 		// Inline children - render on same line
 		f.buf.WriteString(">")
@@ -199,94 +312,111 @@ func (f *Formatter) formatJSXElement(elem *ast.JSXElement, isChild bool) {
 		// Multiline children
 		f.buf.WriteString(">")
 		f.indent++
-		for _, child := range elem.Children {
-			f.formatJSXChild(child)
-		}
+		glued := f.formatJSXChildren(elem.Children)
 		f.indent--
-		f.buf.WriteString("\n")
-		f.writeIndent()
+		if !glued {
+			f.buf.WriteString("\n")
+			f.writeIndent()
+		}
 		f.buf.WriteString("</")
 		f.buf.WriteString(elem.Tag)
 		f.buf.WriteString(">")
 	}
 }
 
-// formatJSXFragment formats a JSX fragment.
+// formatJSXFragment formats a JSX fragment, keeping the long form
+// <Fragment key={...}> when it is written so.
 func (f *Formatter) formatJSXFragment(frag *ast.JSXFragment, isChild bool) {
-	f.buf.WriteString("<>")
+	open, close := "<>", "</>"
+	if frag.Long {
+		f.buf.WriteString("<Fragment")
+		for _, attr := range frag.Attributes {
+			f.buf.WriteString(" ")
+			f.formatAttribute(attr)
+		}
+		open, close = ">", "</Fragment>"
+	}
+	f.buf.WriteString(open)
 
-	if len(frag.Children) > 0 {
+	if f.preserveSpace {
+		f.formatJSXChildrenAsWritten(frag.Children)
+	} else if len(frag.Children) > 0 {
 		f.indent++
-		for _, child := range frag.Children {
-			f.formatJSXChild(child)
-		}
+		glued := f.formatJSXChildren(frag.Children)
 		f.indent--
-		f.buf.WriteString("\n")
-		f.writeIndent()
+		if !glued {
+			f.buf.WriteString("\n")
+			f.writeIndent()
+		}
 	}
 
-	f.buf.WriteString("</>")
+	f.buf.WriteString(close)
 }
 
-// formatJSXChild formats a JSX child element (multiline).
-func (f *Formatter) formatJSXChild(child ast.JSXChild) {
-	switch c := child.(type) {
-	case *ast.JSXText:
-		trimmed := strings.TrimSpace(c.Value)
-		if trimmed != "" {
+// formatJSXChildren formats children one per line and reports whether the
+// last one ends in a space that renders, which the closing tag must follow
+// on the same line. Text next to such a space stays on the line of its
+// sibling or tag, since a line break there would drop the space, and so does
+// text written touching a sibling, as in <b>name</b>!.
+func (f *Formatter) formatJSXChildren(children []ast.JSXChild) bool {
+	first := true
+	glued := false // The next child continues the line
+	space := false // The line ends in a space that renders
+	for _, child := range children {
+		text, ok := child.(*ast.JSXText)
+		if !ok {
+			if !glued {
+				f.buf.WriteString("\n")
+				f.writeIndent()
+			}
+			f.formatJSXChildInline(child)
+			first, glued, space = false, false, false
+			continue
+		}
+		content := text.Content()
+		if content == "" {
+			continue
+		}
+		touches := !first && !isSpace(text.Value[0])
+		if !glued && !touches && !strings.HasPrefix(content, " ") {
 			f.buf.WriteString("\n")
 			f.writeIndent()
-			f.buf.WriteString(trimmed)
 		}
-	case *ast.JSXExpression:
-		f.buf.WriteString("\n")
-		f.writeIndent()
-		f.buf.WriteString("{")
-		f.buf.WriteString(strings.TrimSpace(c.Expression))
-		f.buf.WriteString("}")
-	case *ast.JSXElement:
-		f.buf.WriteString("\n")
-		f.writeIndent()
-		f.formatJSXElement(c, true)
-	case *ast.JSXFragment:
-		f.buf.WriteString("\n")
-		f.writeIndent()
-		f.formatJSXFragment(c, true)
+		f.buf.WriteString(content)
+		space = strings.HasSuffix(content, " ")
+		first, glued = false, space || !isSpace(text.Value[len(text.Value)-1])
 	}
+	return space
+}
+
+// formatJSXChildrenAsWritten formats children with the text between them
+// as written, for files whose whitespace is preserved.
+func (f *Formatter) formatJSXChildrenAsWritten(children []ast.JSXChild) {
+	for _, child := range children {
+		if text, ok := child.(*ast.JSXText); ok {
+			f.buf.WriteString(text.Value)
+			continue
+		}
+		f.formatJSXChildInline(child)
+	}
+}
+
+// isSpace reports whether c is whitespace to JSX text rules.
+func isSpace(c byte) bool {
+	return c == ' ' || c == '\t' || c == '\r' || c == '\n'
 }
 
 // formatJSXChildInline formats a JSX child inline (no newlines).
 func (f *Formatter) formatJSXChildInline(child ast.JSXChild) {
 	switch c := child.(type) {
 	case *ast.JSXText:
-		// Normalize whitespace: collapse multiple spaces/tabs/newlines to single space
-		// but preserve leading/trailing spaces if they exist
-		text := c.Value
-		// Replace any whitespace sequence with a single space
-		var result strings.Builder
-		inWhitespace := false
-		for _, r := range text {
-			if unicode.IsSpace(r) {
-				if !inWhitespace {
-					result.WriteByte(' ')
-					inWhitespace = true
-				}
-			} else {
-				result.WriteRune(r)
-				inWhitespace = false
-			}
-		}
-		normalized := result.String()
-		if normalized != "" && normalized != " " {
-			f.buf.WriteString(normalized)
-		} else if normalized == " " {
-			// Preserve single space between expressions
-			f.buf.WriteString(" ")
-		}
+		f.buf.WriteString(c.Content())
 	case *ast.JSXExpression:
 		f.buf.WriteString("{")
 		f.buf.WriteString(strings.TrimSpace(c.Expression))
 		f.buf.WriteString("}")
+	case *ast.JSXComment:
+		f.formatJSXComment(c)
 	case *ast.JSXElement:
 		f.formatJSXElement(c, true)
 	case *ast.JSXFragment:
@@ -294,22 +424,148 @@ func (f *Formatter) formatJSXChildInline(child ast.JSXChild) {
 	}
 }
 
+// formatJSXComment formats {/* comment */}. A closing brace after a line
+// comment goes on a line of its own, so the comment does not swallow it.
+func (f *Formatter) formatJSXComment(c *ast.JSXComment) {
+	f.buf.WriteString("{")
+	f.buf.WriteString(c.Text)
+	if endsInLineComment(c.Text) {
+		f.buf.WriteString("\n")
+		f.writeIndent()
+	}
+	f.buf.WriteString("}")
+}
+
+// endsInLineComment reports whether the last of the comments in text is a
+// // comment, which runs to the end of the line.
+func endsInLineComment(text string) bool {
+	lineComment := false
+	for {
+		text = strings.TrimLeft(text, " \t\r\n")
+		switch {
+		case strings.HasPrefix(text, "//"):
+			var found bool
+			_, text, found = strings.Cut(text, "\n")
+			if !found {
+				return true
+			}
+			lineComment = true
+		case strings.HasPrefix(text, "/*"):
+			end := strings.Index(text[2:], "*/")
+			if end < 0 {
+				return false
+			}
+			text = text[2+end+2:]
+			lineComment = false
+		default:
+			return lineComment && text == ""
+		}
+	}
+}
+
 // formatAttribute formats a single attribute.
 func (f *Formatter) formatAttribute(attr ast.Attribute) {
 	switch a := attr.(type) {
 	case *ast.StringAttribute:
+		quote := attributeQuote(a.Value)
+		if a.Raw {
+			quote = "`"
+		}
 		f.buf.WriteString(a.Key)
-		f.buf.WriteString("=\"")
+		f.buf.WriteString("=")
+		f.buf.WriteString(quote)
 		f.buf.WriteString(a.Value)
-		f.buf.WriteString("\"")
+		f.buf.WriteString(quote)
 	case *ast.ExpressionAttribute:
 		f.buf.WriteString(a.Key)
+		if a.Optional {
+			f.buf.WriteString("?")
+		}
 		f.buf.WriteString("={")
-		f.buf.WriteString(strings.TrimSpace(a.Expression))
+		if a.Parts != nil {
+			f.formatExpressionParts(a.Parts)
+		} else {
+			f.buf.WriteString(strings.TrimSpace(a.Expression))
+		}
+		f.buf.WriteString("}")
+	case *ast.SpreadAttribute:
+		f.buf.WriteString("{...")
+		f.buf.WriteString(a.Expression)
 		f.buf.WriteString("}")
 	}
 }
 
+// attributeQuote returns the quote to write a string attribute value in:
+// double quotes, unless the value holds a double quote that is not escaped,
+// as class='a "b"' can.
+func attributeQuote(value string) string {
+	for i := 0; i < len(value); i++ {
+		switch {
+		case value[i] == '\\' && i+1 < len(value) && value[i+1] == '"':
+			i++
+		case value[i] == '"':
+			return "'"
+		}
+	}
+	return `"`
+}
+
+// formatExpressionParts formats an expression holding JSX: the JSX is
+// formatted like any other, and the Go code around it kept as written.
+func (f *Formatter) formatExpressionParts(parts []ast.Node) {
+	for i, part := range parts {
+		switch p := part.(type) {
+		case *ast.GoCode:
+			code := p.Value
+			if i == 0 {
+				code = strings.TrimLeftFunc(code, unicode.IsSpace)
+			}
+			if i == len(parts)-1 {
+				code = strings.TrimRightFunc(code, unicode.IsSpace)
+			}
+			f.buf.WriteString(code)
+		case *ast.JSXElement:
+			f.formatJSXElement(p, true)
+		case *ast.JSXFragment:
+			f.formatJSXFragment(p, true)
+		}
+	}
+}
+
+// formatTypeArgs formats the type arguments of a generic component the way
+// gofmt would. Closing tags are printed without them.
+func formatTypeArgs(args string) string {
+	expr, err := goparser.ParseExpr("_[" + args + "]")
+	if err != nil {
+		return strings.TrimSpace(args)
+	}
+
+	var buf bytes.Buffer
+	if err := printer.Fprint(&buf, token.NewFileSet(), expr); err != nil {
+		return strings.TrimSpace(args)
+	}
+	formatted := buf.String()
+	return formatted[len("_[") : len(formatted)-len("]")]
+}
+
+// keepClosingTag lists the intrinsic elements NormalizeSelfClosing leaves
+// alone: HTML never treats them as self-closing, and an explicit closing tag
+// keeps their content, even when empty, visibly delimited.
+var keepClosingTag = map[string]bool{
+	"iframe":   true,
+	"script":   true,
+	"style":    true,
+	"template": true,
+	"textarea": true,
+	"title":    true,
+}
+
+// canSelfClose reports whether NormalizeSelfClosing may write an empty
+// element with tag as self-closing. Components always can.
+func canSelfClose(tag string) bool {
+	return !keepClosingTag[tag]
+}
+
 // shouldInline determines if an element should be formatted inline.
 func (f *Formatter) shouldInline(elem *ast.JSXElement) bool {
 	// Self-closing elements with few attributes
@@ -329,6 +585,10 @@ func (f *Formatter) shouldInline(elem *ast.JSXElement) bool {
 				totalLength += len(strings.TrimSpace(c.Value))
 			case *ast.JSXExpression:
 				totalLength += len(c.Expression) + 2 // {}
+			case *ast.JSXComment:
+				totalLength += len(c.Text) + 2 // {}
+				// Nothing may follow a line comment on its line
+				hasNestedElements = hasNestedElements || endsInLineComment(c.Text)
 			}
 		}
 		// If no nested elements and content is short, inline it
@@ -1,8 +1,11 @@
 package formatter
 
 import (
+	"bytes"
+	"strings"
 	"testing"
 
+	"github.com/germtb/gox/ast"
 	"github.com/germtb/gox/parser"
 )
 
@@ -155,6 +158,75 @@ func App() {
 }
 `,
 		},
+		{
+			name: "keyed fragment",
+			input: `package main
+
+func App() {
+	return <Fragment   key={item.ID}><dt>{item.Term}</dt>
+	<dd>{item.Definition}</dd></Fragment>
+}
+`,
+			expected: `package main
+
+func App() {
+	return <Fragment key={item.ID}>
+		<dt>{item.Term}</dt>
+		<dd>{item.Definition}</dd>
+	</Fragment>
+}
+`,
+		},
+		{
+			name: "optional attribute",
+			input: `package main
+
+func App() {
+	return <a   title?={ title }>x</a>
+}
+`,
+			expected: `package main
+
+func App() {
+	return <a title?={title}>x</a>
+}
+`,
+		},
+		{
+			name: "spread attributes",
+			input: `package main
+
+func App() {
+	return <input {... a} type="text" {...b} />
+}
+`,
+			expected: `package main
+
+func App() {
+	return <input {...a} type="text" {...b} />
+}
+`,
+		},
+		{
+			name: "single-quoted attributes",
+			input: `package main
+
+func App() {
+	return <div class='container' title='say "hi"' />
+}
+`,
+			expected: `package main
+
+func App() {
+	return <div class="container" title='say "hi"' />
+}
+`,
+		},
+		{
+			name:     "raw string attributes",
+			input:    "package main\n\nfunc App() {\n\treturn <p  label=`say \"hi\"\n  to 'all'` tooltip={`multi\nline`}>x</p>\n}\n",
+			expected: "package main\n\nfunc App() {\n\treturn <p label=`say \"hi\"\n  to 'all'` tooltip={`multi\nline`}>x</p>\n}\n",
+		},
 		{
 			name: "empty element",
 			input: `package main
@@ -168,6 +240,147 @@ func App() {
 func App() {
 	return <div></div>
 }
+`,
+		},
+		{
+			name: "generic component",
+			input: `package main
+
+func List[T any](props ListProps[T]) gox.VNode {
+	return <ul />
+}
+
+func App() {
+	return <List[ map[string]int ] items={m}><li /></List[map[string]int]>
+}
+`,
+			expected: `package main
+
+func List[T any](props ListProps[T]) gox.VNode {
+	return <ul />
+}
+
+func App() {
+	return <List[map[string]int] items={m}>
+		<li />
+	</List>
+}
+`,
+		},
+		{
+			name: "spaces that render stay on their line",
+			input: `package main
+
+func Greeting(name string) {
+	return <p>Hello <b>{name}</b>! Welcome   to <a href="/">the site</a> <i>today</i>.</p>
+}
+
+func Item() {
+	return <li> padded <b>item</b> </li>
+}
+`,
+			expected: `package main
+
+func Greeting(name string) {
+	return <p>
+		Hello <b>{name}</b>! Welcome to <a href="/">the site</a> <i>today</i>.
+	</p>
+}
+
+func Item() {
+	return <li> padded <b>item</b> </li>
+}
+`,
+		},
+		{
+			name: "raw elements keep their content",
+			input: `//gox:raw pre
+package main
+
+func Page() {
+	return <div><style>
+  p > b { color: red; }
+</style><pre>  x := {a}
+</pre></div>
+}
+`,
+			expected: `//gox:raw pre
+package main
+
+func Page() {
+	return <div>
+		<style>
+  p > b { color: red; }
+</style>
+		<pre>  x := {a}
+</pre>
+	</div>
+}
+`,
+		},
+		{
+			name: "dashed and namespaced attributes",
+			input: `package main
+
+func Icon() {
+	return <svg  aria-hidden  data-testid="icon"><use xlink:href="#a"/></svg>
+}
+`,
+			expected: `package main
+
+func Icon() {
+	return <svg aria-hidden={true} data-testid="icon">
+		<use xlink:href="#a" />
+	</svg>
+}
+`,
+		},
+		{
+			name: "comments",
+			input: `package main
+
+func App() {
+	return <div>{ /* inline */ }</div>
+}
+
+func List() {
+	return <ul>
+{/* items */}
+		<li>one</li>
+		{ // more later
+}
+	</ul>
+}
+`,
+			expected: `package main
+
+func App() {
+	return <div>{/* inline */}</div>
+}
+
+func List() {
+	return <ul>
+		{/* items */}
+		<li>one</li>
+		{// more later
+		}
+	</ul>
+}
+`,
+		},
+		{
+			name: "JSX in attribute expressions",
+			input: `package main
+
+func App() {
+	return <Card header={ <Title   text="hi"/> } footer={func() gox.VNode { return <p>bye</p> }} />
+}
+`,
+			expected: `package main
+
+func App() {
+	return <Card header={<Title text="hi" />} footer={func() gox.VNode { return <p>bye</p> }} />
+}
 `,
 		},
 	}
@@ -187,6 +400,14 @@ func App() {
 			if string(result) != tt.expected {
 				t.Errorf("Format mismatch:\nInput:\n%s\nExpected:\n%s\nGot:\n%s", tt.input, tt.expected, string(result))
 			}
+
+			reparsed, err := parser.Parse("test.gox", result)
+			if err != nil {
+				t.Fatalf("Parse error on formatted output: %v", err)
+			}
+			if err := equivalent(file, reparsed); err != nil {
+				t.Errorf("Formatted output is not equivalent to the input: %v", err)
+			}
 		})
 	}
 }
@@ -219,6 +440,283 @@ func App() {
 	})
 }
 
+func TestNormalizeSelfClosing(t *testing.T) {
+	input := `package main
+
+func App() {
+	return <head><div></div><title></title><script src="app.js"></script><Meta></Meta></head>
+}
+`
+	want := `package main
+
+func App() {
+	return <head>
+		<div />
+		<title></title>
+		<script src="app.js"></script>
+		<Meta />
+	</head>
+}
+`
+	opts := DefaultOptions()
+	opts.NormalizeSelfClosing = true
+	got, err := Source([]byte(input), opts)
+	if err != nil {
+		t.Fatalf("Source error: %v", err)
+	}
+	if string(got) != want {
+		t.Errorf("Expected empty elements self-closing, except those that keep their closing tag:\nExpected:\n%s\nGot:\n%s", want, got)
+	}
+
+	// Off by default: empty elements stay as written
+	if got, err = Source([]byte(input), nil); err != nil {
+		t.Fatalf("Source error: %v", err)
+	}
+	if want := "<div></div>\n\t\t<title></title>"; !strings.Contains(string(got), want) {
+		t.Errorf("Expected empty elements as written:\n%s", got)
+	}
+}
+
+func TestFormatVoidElements(t *testing.T) {
+	input := `//gox:void br input
+package main
+
+func App() {
+	return <p>a<br></br><input value="x">
+	</input><textarea></textarea></p>
+}
+`
+	got, err := Source([]byte(input), nil)
+	if err != nil {
+		t.Fatalf("Source error: %v", err)
+	}
+	for _, want := range []string{"<br />", `<input value="x" />`, "<textarea></textarea>"} {
+		if !strings.Contains(string(got), want) {
+			t.Errorf("Expected %s:\n%s", want, got)
+		}
+	}
+}
+
+func TestSource(t *testing.T) {
+	input := `package main
+
+func App() {
+	return <div><span>Hello</span></div>
+}
+`
+	want := `package main
+
+func App() {
+	return <div>
+		<span>Hello</span>
+	</div>
+}
+`
+	got, err := Source([]byte(input), nil)
+	if err != nil {
+		t.Fatalf("Source error: %v", err)
+	}
+	if string(got) != want {
+		t.Errorf("Source mismatch:\nExpected:\n%s\nGot:\n%s", want, got)
+	}
+
+	// Formatting is idempotent
+	again, err := Source(got, nil)
+	if err != nil {
+		t.Fatalf("Source error on formatted input: %v", err)
+	}
+	if !bytes.Equal(again, got) {
+		t.Errorf("Source is not idempotent:\nFirst:\n%s\nSecond:\n%s", got, again)
+	}
+}
+
+func TestSourceCRLF(t *testing.T) {
+	input := "package main\n\nfunc App() {\n\treturn <div title=\"a\nb\"><span>Hello</span>\n\t</div>\n}\n"
+	want, err := Source([]byte(input), nil)
+	if err != nil {
+		t.Fatalf("Source error: %v", err)
+	}
+
+	crlf := strings.ReplaceAll(input, "\n", "\r\n")
+	got, err := Source([]byte(crlf), nil)
+	if err != nil {
+		t.Fatalf("Source error with \\r\\n line breaks: %v", err)
+	}
+	if wantCRLF := strings.ReplaceAll(string(want), "\n", "\r\n"); string(got) != wantCRLF {
+		t.Errorf("Source mismatch:\nExpected:\n%q\nGot:\n%q", wantCRLF, got)
+	}
+}
+
+func TestSourceDirectives(t *testing.T) {
+	t.Run("no-format", func(t *testing.T) {
+		input := "//gox:no-format\npackage main\n\nfunc App() {\n\treturn <div><span>Hello</span></div>\n}\n"
+		for _, src := range []string{input, strings.ReplaceAll(input, "\n", "\r\n")} {
+			got, err := Source([]byte(src), nil)
+			if err != nil {
+				t.Fatalf("Source error: %v", err)
+			}
+			if string(got) != src {
+				t.Errorf("Expected the file unchanged, got:\n%q", got)
+			}
+		}
+
+		// It is still parsed
+		if _, err := Source([]byte("//gox:no-format\npackage main\n\nvar x = <div>\n"), nil); err == nil {
+			t.Error("Expected parse error, got nil")
+		}
+	})
+
+	t.Run("preserve-whitespace", func(t *testing.T) {
+		input := `//gox:preserve-whitespace
+package main
+
+func App() {
+	return <pre   class="code">
+  a  <b>{ x }</b>
+    c</pre>
+}
+`
+		want := `//gox:preserve-whitespace
+package main
+
+func App() {
+	return <pre class="code">
+  a  <b>{x}</b>
+    c</pre>
+}
+`
+		got, err := Source([]byte(input), nil)
+		if err != nil {
+			t.Fatalf("Source error: %v", err)
+		}
+		if string(got) != want {
+			t.Errorf("Source mismatch:\nExpected:\n%s\nGot:\n%s", want, got)
+		}
+	})
+}
+
+func TestSourceErrors(t *testing.T) {
+	t.Run("parse error has position", func(t *testing.T) {
+		_, err := Source([]byte("package main\n\nvar x = <div>\n"), nil)
+		if err == nil {
+			t.Fatal("Expected parse error, got nil")
+		}
+		if !strings.HasPrefix(err.Error(), "4:1: ") {
+			t.Errorf("Expected line:column prefix, got: %v", err)
+		}
+	})
+
+	t.Run("invalid options", func(t *testing.T) {
+		_, err := Source([]byte("package main\n"), &Options{TabWidth: -1, UseTabs: true})
+		if err == nil || !strings.Contains(err.Error(), "TabWidth") {
+			t.Errorf("Expected TabWidth validation error, got: %v", err)
+		}
+	})
+}
+
+func TestEquivalent(t *testing.T) {
+	parse := func(src string) *ast.GoxFile {
+		t.Helper()
+		file, err := parser.Parse("test.gox", []byte(src))
+		if err != nil {
+			t.Fatalf("Parse error: %v", err)
+		}
+		return file
+	}
+	src := `package main
+
+import (
+	"strings"
+	"fmt"
+)
+
+func App(name string) {
+	return <div class="app" onClick={func() { fmt.Println(name) }}>
+		Hello,   {strings.ToUpper(name)}!
+		<List[string] items={nil} />
+	</div>
+}
+`
+	tests := []struct {
+		name      string
+		formatted string
+		wantErr   string
+	}{
+		{"layout", `package main
+
+import (
+	"strings"
+	"fmt"
+)
+
+func App(name string) {
+	return <div
+		class="app"
+		onClick={func() {
+			fmt.Println(name)
+		}}
+	>Hello, {strings.ToUpper(name)}!<List[ string ] items={nil} /></div>
+}
+`, ""},
+		{"space", strings.Replace(src, "}!\n", "}! ", 1), `10:35: text "!" changed`},
+		{"text", strings.Replace(src, "Hello,", "Hi,", 1), `9:65: text "Hello," changed`},
+		{"attribute", strings.Replace(src, `"app"`, `"main"`, 1), "9:14: attribute class changed"},
+		{"expression", strings.Replace(src, "ToUpper", "ToLower", 1), "10:12: expression {strings.ToUpper(name)} changed"},
+		{"child", strings.Replace(src, "<List[string] items={nil} />", "", 1), "9:9: <div> has 3 children after formatting, want 4"},
+		{"tag", strings.Replace(src, "List[string]", "List[int]", 1), "11:3: <List[string]> became <List[int]>"},
+		{"go code", strings.Replace(src, "name string", "name any", 1), "1:1: Go code changed"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := equivalent(parse(src), parse(tt.formatted))
+			if tt.wantErr == "" && err != nil {
+				t.Errorf("equivalent: %v", err)
+			}
+			if tt.wantErr != "" && (err == nil || err.Error() != tt.wantErr) {
+				t.Errorf("equivalent = %v, want %s", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestFprint(t *testing.T) {
+	file, err := parser.Parse("test.gox", []byte("package main\n\nvar x = <div />\n"))
+	if err != nil {
+		t.Fatalf("Parse error: %v", err)
+	}
+
+	var buf bytes.Buffer
+	if err := Fprint(&buf, file, nil); err != nil {
+		t.Fatalf("Fprint error: %v", err)
+	}
+	if got := buf.String(); got != "package main\n\nvar x = <div />\n" {
+		t.Errorf("Fprint wrote %q", got)
+	}
+}
+
+func TestOptionsValidate(t *testing.T) {
+	tests := []struct {
+		name    string
+		opts    Options
+		wantErr bool
+	}{
+		{"defaults", *DefaultOptions(), false},
+		{"spaces", Options{TabWidth: 2, MaxLineLength: 80}, false},
+		{"negative tab width", Options{TabWidth: -1, UseTabs: true}, true},
+		{"spaces without width", Options{TabWidth: 0, UseTabs: false}, true},
+		{"negative line length", Options{TabWidth: 4, UseTabs: true, MaxLineLength: -1}, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.opts.Validate()
+			if (err != nil) != tt.wantErr {
+				t.Errorf("Validate() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
 func TestDefaultOptions(t *testing.T) {
 	opts := DefaultOptions()
 
@@ -277,3 +775,28 @@ func TestShouldInline(t *testing.T) {
 		})
 	}
 }
+
+func TestFormatRewrittenTree(t *testing.T) {
+	src := "package ui\n\nfunc App() gox.VNode {\n\treturn <Card title={<Button>x</Button>}>\n\t\t<Button />\n\t</Card>\n}\n"
+	file, err := parser.Parse("app.gox", []byte(src))
+	if err != nil {
+		t.Fatalf("Parse error: %v", err)
+	}
+	renamed := ast.Rewrite(file, func(n ast.Node) ast.Node {
+		if elem, ok := n.(*ast.JSXElement); ok && elem.Tag == "Button" {
+			c := *elem
+			c.Tag = "ui.Button"
+			return &c
+		}
+		return n
+	}).(*ast.GoxFile)
+
+	got, err := Format(renamed, nil)
+	if err != nil {
+		t.Fatalf("Format error: %v", err)
+	}
+	want := strings.ReplaceAll(src, "Button", "ui.Button")
+	if string(got) != want {
+		t.Errorf("got:\n%s\nwant:\n%s", got, want)
+	}
+}
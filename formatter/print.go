@@ -0,0 +1,154 @@
+package formatter
+
+import (
+	"bytes"
+
+	"github.com/germtb/gox/ast"
+)
+
+// Print writes file back as source with nothing formatted. A tree from a
+// lossless parse (parser.Trivia) is written byte for byte as it was parsed,
+// so that a tool can change some nodes of a file, as with ast.Rewrite, and
+// leave the rest as it is. A node without trivia, such as one a tool made,
+// is written with a single space before each attribute and before the /> of
+// a self-closing tag, and none elsewhere in its tags.
+func Print(file *ast.GoxFile) []byte {
+	var buf bytes.Buffer
+	for _, node := range file.Nodes {
+		printNode(&buf, node)
+	}
+	return buf.Bytes()
+}
+
+// printNode writes a top-level node or a JSX child.
+func printNode(buf *bytes.Buffer, node ast.Node) {
+	switch n := node.(type) {
+	case *ast.GoCode:
+		buf.WriteString(n.Value)
+	case *ast.JSXText:
+		buf.WriteString(n.Value)
+	case *ast.JSXExpression:
+		buf.WriteString("{")
+		buf.WriteString(n.Expression)
+		buf.WriteString("}")
+	case *ast.JSXComment:
+		buf.WriteString("{")
+		if n.Trivia != nil {
+			buf.WriteString(n.Trivia.Text)
+		} else {
+			buf.WriteString(n.Text)
+		}
+		buf.WriteString("}")
+	case *ast.JSXElement:
+		printTags(buf, n.Tag, n.TypeArgs, n.Attributes, n.Children, n.SelfClosing, n.Trivia)
+	case *ast.JSXFragment:
+		if n.Long {
+			printTags(buf, "Fragment", "", n.Attributes, n.Children, false, n.Trivia)
+			return
+		}
+		buf.WriteString("<>")
+		for _, child := range n.Children {
+			printNode(buf, child)
+		}
+		buf.WriteString("</>")
+	}
+}
+
+// printTags writes an element, or a fragment in the long form, with the
+// whitespace of trivia, if it has any.
+func printTags(buf *bytes.Buffer, tag, typeArgs string, attrs []ast.Attribute, children []ast.JSXChild, selfClosing bool, trivia *ast.Trivia) {
+	t := trivia
+	if t == nil {
+		t = &ast.Trivia{}
+		if selfClosing {
+			t.End = " "
+		}
+		if typeArgs != "" {
+			t.TypeArgs = "[" + typeArgs + "]"
+		}
+	}
+	buf.WriteString("<")
+	buf.WriteString(t.Tag)
+	buf.WriteString(tag)
+	buf.WriteString(t.TypeArgs)
+	for _, attr := range attrs {
+		printAttribute(buf, attr)
+	}
+	buf.WriteString(t.End)
+	if selfClosing {
+		buf.WriteString("/")
+		buf.WriteString(t.Slash)
+		buf.WriteString(">")
+		return
+	}
+	buf.WriteString(">")
+	buf.WriteString(t.Content)
+	for _, child := range children {
+		printNode(buf, child)
+	}
+	buf.WriteString("</")
+	buf.WriteString(t.CloseTag)
+	buf.WriteString(tag)
+	buf.WriteString(t.CloseTypeArgs)
+	buf.WriteString(t.CloseEnd)
+	buf.WriteString(">")
+}
+
+// printAttribute writes an attribute with the whitespace before it.
+func printAttribute(buf *bytes.Buffer, attr ast.Attribute) {
+	var t *ast.Trivia
+	switch a := attr.(type) {
+	case *ast.StringAttribute:
+		t = a.Trivia
+	case *ast.ExpressionAttribute:
+		t = a.Trivia
+	case *ast.SpreadAttribute:
+		t = a.Trivia
+	}
+	if t == nil {
+		t = &ast.Trivia{Leading: " "}
+	}
+	buf.WriteString(t.Leading)
+
+	switch a := attr.(type) {
+	case *ast.StringAttribute:
+		quote := t.Quote
+		if quote == "" {
+			quote = `"`
+			if a.Raw {
+				quote = "`"
+			}
+		}
+		buf.WriteString(a.Key)
+		buf.WriteString(t.Equals)
+		buf.WriteString("=")
+		buf.WriteString(t.Value)
+		buf.WriteString(quote)
+		buf.WriteString(a.Value)
+		buf.WriteString(quote)
+	case *ast.ExpressionAttribute:
+		buf.WriteString(a.Key)
+		if !a.EqualsRange.IsValid() && a.Expression == "true" {
+			return // Boolean shorthand
+		}
+		buf.WriteString(t.Equals)
+		if a.Optional {
+			buf.WriteString("?=")
+		} else {
+			buf.WriteString("=")
+		}
+		buf.WriteString(t.Value)
+		buf.WriteString("{")
+		buf.WriteString(a.Expression)
+		buf.WriteString("}")
+	case *ast.SpreadAttribute:
+		buf.WriteString("{")
+		if t.Text != "" {
+			buf.WriteString(t.Text)
+		} else {
+			buf.WriteString("...")
+			buf.WriteString(a.Expression)
+		}
+		buf.WriteString("}")
+	}
+}
@@ -0,0 +1,73 @@
+package formatter
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/germtb/gox/ast"
+	"github.com/germtb/gox/parser"
+)
+
+func TestPrintLossless(t *testing.T) {
+	sources := map[string]string{
+		"tags":         "package main\n\nvar x = < div  class = \"a\"\n\tid='b'   data-x=`c`\n\t{ ...props }  disabled\n>text</ div >\n",
+		"self-closing": "package main\n\nvar x = <List[ string ]  items={xs}/ >\n",
+		"generic":      "package main\n\nvar x = <List[string] items={xs}>{x}</List[string]>\n",
+		"optional":     "package main\n\nvar x = <img alt ?= { alt } />\n",
+		"comment":      "package main\n\nvar x = <p>{  /* note */  }</p>\n",
+		"fragments":    "package main\n\nvar x = <><Fragment  key={k} >a</Fragment ></>\n",
+		"void":         "//gox:void br\npackage main\n\nvar x = <p><br>\n\t</br></p>\n",
+		"crlf":         "package main\r\n\r\nvar x = <div\r\n\tclass=\"a\">\r\n\t{x}\r\n</div>\r\n",
+		"raw":          "package main\n\nvar x = <script>if (a < b) { f() }</script>\n",
+		"nested":       "package main\n\nvar x = <ul>{gox.Map(xs, func(x string) gox.VNode { return <li  key={x} >{x}</li> })}</ul>\n",
+	}
+	files, _ := filepath.Glob("../testdata/*.gox")
+	for _, name := range files {
+		data, err := os.ReadFile(name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		sources[name] = string(data)
+	}
+
+	for name, src := range sources {
+		t.Run(name, func(t *testing.T) {
+			file, err := parser.ParseFile("test.gox", []byte(src), parser.Trivia)
+			if err != nil {
+				t.Fatalf("Parse error: %v", err)
+			}
+			if got := string(Print(file)); got != src {
+				t.Errorf("Print mismatch:\nExpected:\n%q\nGot:\n%q", src, got)
+			}
+		})
+	}
+}
+
+func TestPrintWithoutTrivia(t *testing.T) {
+	src := "package main\n\nvar x = <List[string]  items={xs}\n\tlabel='a'/>\n"
+	file, err := parser.Parse("test.gox", []byte(src))
+	if err != nil {
+		t.Fatalf("Parse error: %v", err)
+	}
+	want := "package main\n\nvar x = <List[string] items={xs} label=\"a\" />\n"
+	if got := string(Print(file)); got != want {
+		t.Errorf("Print mismatch:\nExpected:\n%q\nGot:\n%q", want, got)
+	}
+
+	// Nodes a tool changes keep the trivia of the rest
+	file, err = parser.ParseFile("test.gox", []byte(src), parser.Trivia)
+	if err != nil {
+		t.Fatalf("Parse error: %v", err)
+	}
+	file = ast.Rewrite(file, func(n ast.Node) ast.Node {
+		if a, ok := n.(*ast.ExpressionAttribute); ok && a.Key == "items" {
+			return &ast.ExpressionAttribute{Key: "values", Expression: "ys", EqualsRange: a.EqualsRange}
+		}
+		return n
+	}).(*ast.GoxFile)
+	want = "package main\n\nvar x = <List[string] values={ys}\n\tlabel='a'/>\n"
+	if got := string(Print(file)); got != want {
+		t.Errorf("Print mismatch:\nExpected:\n%q\nGot:\n%q", want, got)
+	}
+}
@@ -0,0 +1,89 @@
+package generator
+
+import (
+	"go/build/constraint"
+	"path/filepath"
+	"strings"
+)
+
+// knownOS and knownArch are the GOOS and GOARCH values that make a file name
+// suffix a build constraint, as in go/build.
+var (
+	knownOS = map[string]bool{
+		"aix": true, "android": true, "darwin": true, "dragonfly": true,
+		"freebsd": true, "hurd": true, "illumos": true, "ios": true, "js": true,
+		"linux": true, "nacl": true, "netbsd": true, "openbsd": true,
+		"plan9": true, "solaris": true, "wasip1": true, "windows": true,
+		"zos": true,
+	}
+	knownArch = map[string]bool{
+		"386": true, "amd64": true, "amd64p32": true, "arm": true,
+		"armbe": true, "arm64": true, "arm64be": true, "loong64": true,
+		"mips": true, "mipsle": true, "mips64": true, "mips64le": true,
+		"mips64p32": true, "mips64p32le": true, "ppc": true, "ppc64": true,
+		"ppc64le": true, "riscv": true, "riscv64": true, "s390": true,
+		"s390x": true, "sparc": true, "sparc64": true, "wasm": true,
+	}
+)
+
+// NameConstraint returns the build constraint implied by the _GOOS, _GOARCH
+// or _GOOS_GOARCH suffix of a .gox file name, such as "linux && amd64" for
+// view_linux_amd64.gox, or nil if the name implies none. The generated file
+// is named view_linux_amd64_gox.go, which Go no longer recognizes, so the
+// generator writes the constraint into it.
+func NameConstraint(name string) constraint.Expr {
+	name, _, _ = strings.Cut(filepath.Base(name), ".")
+
+	// Like go/build, ignore everything before the first _, so that a file
+	// named linux.gox applies to every platform
+	i := strings.Index(name, "_")
+	if i < 0 {
+		return nil
+	}
+	parts := strings.Split(name[i:], "_")
+	if n := len(parts); n > 0 && parts[n-1] == "test" {
+		parts = parts[:n-1]
+	}
+
+	n := len(parts)
+	if n >= 2 && knownOS[parts[n-2]] && knownArch[parts[n-1]] {
+		return &constraint.AndExpr{
+			X: &constraint.TagExpr{Tag: parts[n-2]},
+			Y: &constraint.TagExpr{Tag: parts[n-1]},
+		}
+	}
+	if n >= 1 && (knownOS[parts[n-1]] || knownArch[parts[n-1]]) {
+		return &constraint.TagExpr{Tag: parts[n-1]}
+	}
+	return nil
+}
+
+// insertBuildConstraint adds the constraint implied by the file name to the
+// generated code: ANDed into the //go:build line the file already carries
+// over, or as a new //go:build line at the top.
+func insertBuildConstraint(src []byte, name string) []byte {
+	implied := NameConstraint(name)
+	if implied == nil {
+		return src
+	}
+
+	code := string(src)
+	offset := 0
+	for _, line := range strings.SplitAfter(code, "\n") {
+		trimmed := strings.TrimSpace(line)
+		if strings.HasPrefix(trimmed, "package ") {
+			break
+		}
+		if constraint.IsGoBuild(trimmed) {
+			expr, err := constraint.Parse(trimmed)
+			if err != nil {
+				// Leave it for the Go compiler to report
+				return src
+			}
+			combined := "//go:build " + (&constraint.AndExpr{X: expr, Y: implied}).String() + "\n"
+			return []byte(code[:offset] + combined + code[offset+len(line):])
+		}
+		offset += len(line)
+	}
+	return []byte("//go:build " + implied.String() + "\n\n" + code)
+}
@@ -0,0 +1,78 @@
+package generator
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/germtb/gox/parser"
+)
+
+func TestNameConstraint(t *testing.T) {
+	tests := []struct {
+		name string
+		want string
+	}{
+		{"view.gox", ""},
+		{"linux.gox", ""},
+		{"view_linux.gox", "linux"},
+		{"view_amd64.gox", "amd64"},
+		{"view_linux_amd64.gox", "linux && amd64"},
+		{"view_windows_test.gox", "windows"},
+		{"view_unix.gox", ""},
+		{"ui/view_darwin_arm64_test.gox", "darwin && arm64"},
+	}
+	for _, tt := range tests {
+		expr := NameConstraint(tt.name)
+		got := ""
+		if expr != nil {
+			got = expr.String()
+		}
+		if got != tt.want {
+			t.Errorf("NameConstraint(%q) = %q, want %q", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestGenerateBuildConstraint(t *testing.T) {
+	tests := []struct {
+		name string
+		file string
+		src  string
+		want string
+	}{
+		{"carried over", "view.gox", "//go:build linux && !arm\n\npackage ui\n", "//go:build linux && !arm\n\npackage ui"},
+		{"from file name", "view_windows.gox", "package ui\n", "//go:build windows\n\npackage ui"},
+		{"combined", "view_windows.gox", "// Package ui renders views.\n\n//go:build !arm\n\npackage ui\n", "// Package ui renders views.\n\n//go:build !arm && windows\n\npackage ui"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			file, err := parser.Parse(tt.file, []byte(tt.src+"\nfunc View() gox.VNode {\n\treturn <p />\n}\n"))
+			if err != nil {
+				t.Fatalf("Parse error: %v", err)
+			}
+			output, sm, err := Generate(file, nil)
+			if err != nil {
+				t.Fatalf("Generate error: %v", err)
+			}
+			if !strings.HasPrefix(string(output), tt.want) {
+				t.Errorf("got:\n%s\nwant it to start with:\n%s", output, tt.want)
+			}
+
+			// Mappings follow the inserted line
+			target := indexOf(strings.Split(string(output), "\n"), "\treturn gox.Element(\"p\", nil)")
+			want := uint32(strings.Count(tt.src, "\n") + 2)
+			if got, ok := sm.FindSourceLine(uint32(target)); !ok || got != want {
+				t.Errorf("return statement on line %d maps to line %d (ok=%v), want %d", target, got, ok, want)
+			}
+		})
+	}
+}
+
+func indexOf(lines []string, line string) int {
+	for i, l := range lines {
+		if l == line {
+			return i
+		}
+	}
+	return -1
+}
@@ -3,22 +3,32 @@ package generator
 
 import (
 	"bytes"
+	"errors"
 	"fmt"
 	"go/format"
+	"go/scanner"
 	"strings"
 	"unicode"
 
 	"github.com/germtb/gox/ast"
+	"github.com/germtb/gox/diag"
+	"github.com/germtb/gox/lexer"
 	"github.com/germtb/gox/parser"
+	"github.com/germtb/gox/schema"
 )
 
 // Generator transforms AST to Go code.
 type Generator struct {
-	buf         bytes.Buffer
-	indent      int
-	sourceMap   *SourceMap
-	runtimePkg  string
-	needsImport bool
+	buf            bytes.Buffer
+	indent         int
+	sourceMap      *SourceMap
+	runtimePkg     string
+	lineDirectives string
+	version        string
+	schema         *schema.Schema
+	needsImport    bool
+	rawElements    map[string]bool // Raw elements of the file, for JSX in expressions
+	preserveSpace  bool            // //gox:preserve-whitespace: JSX text is kept as written
 
 	// Position tracking for source maps
 	outLine uint32 // Current output line (0-indexed)
@@ -27,24 +37,50 @@ type Generator struct {
 
 // Options configures the generator.
 type Options struct {
-	// RuntimePackage is the import path for the gox package.
+	// RuntimePackage is the import path for the gox package. A
+	// //gox:runtime directive in the file takes precedence.
 	// Default: "github.com/germtb/gox"
 	RuntimePackage string
+
+	// LineDirectives, if set, is the name of the .gox file written in //line
+	// directives interleaved with the generated code, so that the compiler,
+	// vet, coverage and debuggers report .gox lines without a source map.
+	// Relative names are resolved against the directory of the generated
+	// file.
+	LineDirectives string
+
+	// Version, if set, is the gox version recorded in a provenance header
+	// at the top of the generated file. See ReadProvenance.
+	Version string
+
+	// Schema, if strict, makes generation fail on intrinsic elements and
+	// attributes it does not declare. See LookupSchema.
+	Schema *schema.Schema
 }
 
 // New creates a new Generator.
 func New(opts *Options) *Generator {
 	g := &Generator{
 		sourceMap:  NewSourceMap(),
-		runtimePkg: "github.com/germtb/gox",
+		runtimePkg: DefaultRuntimePackage,
 	}
 	if opts != nil && opts.RuntimePackage != "" {
 		g.runtimePkg = opts.RuntimePackage
 	}
+	if opts != nil {
+		g.lineDirectives = opts.LineDirectives
+		g.version = opts.Version
+		g.schema = opts.Schema
+	}
 	return g
 }
 
 // Generate transforms a GoxFile AST into Go source code.
+//
+// The Go code around and inside JSX is only checked once generated. If it
+// does not parse, the error is a diag.GoSyntax diagnostic at the position
+// in the .gox file, and the unformatted output and its source map are still
+// returned, for tools such as editors that work with incomplete code.
 func Generate(file *ast.GoxFile, opts *Options) ([]byte, *SourceMap, error) {
 	g := New(opts)
 	return g.Generate(file)
@@ -52,30 +88,78 @@ func Generate(file *ast.GoxFile, opts *Options) ([]byte, *SourceMap, error) {
 
 // Generate generates Go code from the AST.
 func (g *Generator) Generate(file *ast.GoxFile) ([]byte, *SourceMap, error) {
+	if pkg := FileRuntime(file); pkg != "" {
+		g.runtimePkg = pkg
+	}
+	g.preserveSpace = file.Directives.PreserveWhitespace
+	if g.schema != nil && g.schema.Strict {
+		if errs := g.schema.Check(file); len(errs) > 0 {
+			return nil, nil, errs[0]
+		}
+	}
+	g.rawElements = lexer.RawElements("")
+	if len(file.Nodes) > 0 {
+		if code, ok := file.Nodes[0].(*ast.GoCode); ok {
+			// Directives precede the package clause
+			g.rawElements = lexer.RawElements(code.Value)
+		}
+	}
+
 	// First pass: check if we need runtime import
-	g.needsImport = g.hasJSX(file)
+	g.needsImport = g.hasJSX(file) && !imports(file, g.runtimePkg)
 
 	// Generate all nodes
 	for _, node := range file.Nodes {
 		g.generateNode(node)
 	}
 
-	result := g.buf.Bytes()
+	raw := g.buf.Bytes()
+	result := raw
 
 	// Insert runtime import if needed
 	if g.needsImport {
-		result = g.insertRuntimeImport(result)
+		result = g.insertRuntimeImport(result, file.PackageRange)
 	}
 
-	// Format the generated code
+	// A _GOOS or _GOARCH suffix is lost in the generated file's name
+	result = insertBuildConstraint(result, file.SourcePath)
+
+	// Format the generated code, which also parses it
 	formatted, err := format.Source(result)
 	if err != nil {
-		// If formatting fails, return unformatted code with a comment
-		// This allows debugging malformed output
-		return result, g.sourceMap, nil
+		sourceMap := realignSourceMap(g.sourceMap, raw, result)
+		sourceMap.setWideLines(result)
+		return result, sourceMap, syntaxError(err, sourceMap, file.SourcePath)
 	}
 
-	return formatted, g.sourceMap, nil
+	// Mappings were recorded against the raw output; move them to where
+	// the import insertion and gofmt placed each character.
+	sourceMap := realignSourceMap(g.sourceMap, raw, formatted)
+	if g.lineDirectives != "" {
+		// After formatting, which would indent the directives
+		formatted, sourceMap = insertLineDirectives(formatted, sourceMap, g.lineDirectives)
+	}
+	if g.version != "" {
+		formatted, sourceMap = insertProvenance(formatted, sourceMap, g.version, file.SourcePath)
+	}
+	sourceMap.setWideLines(formatted)
+	return formatted, sourceMap, nil
+}
+
+// syntaxError returns the first Go syntax error err reports in the generated
+// code as a diagnostic at the matching position of the .gox file at path.
+func syntaxError(err error, sm *SourceMap, path string) error {
+	var list scanner.ErrorList
+	if !errors.As(err, &list) || len(list) == 0 {
+		return diag.New(diag.GoSyntax, path, 0, 0, "generated code does not parse: %v", err)
+	}
+	first := list[0]
+	line := uint32(first.Pos.Line - 1)
+	pos, ok := sm.SourcePositionFromTarget(line, sm.TargetRuneColumn(line, uint32(first.Pos.Column-1)))
+	if !ok {
+		return diag.New(diag.GoSyntax, path, 0, 0, "%s", first.Msg)
+	}
+	return diag.New(diag.GoSyntax, path, int(pos.Line)+1, int(pos.Column)+1, "%s", first.Msg)
 }
 
 // hasJSX checks if the file contains any JSX elements.
@@ -89,27 +173,31 @@ func (g *Generator) hasJSX(file *ast.GoxFile) bool {
 	return false
 }
 
-// insertRuntimeImport adds the runtime import after the package declaration.
-func (g *Generator) insertRuntimeImport(src []byte) []byte {
-	code := string(src)
-
-	// Check if runtime is already imported
-	if strings.Contains(code, g.runtimePkg) {
-		return src
+// imports reports whether file imports path.
+func imports(file *ast.GoxFile, path string) bool {
+	for _, imp := range file.Imports {
+		if imp.Path == path {
+			return true
+		}
 	}
+	return false
+}
 
-	// Find the package declaration
-	pkgIdx := strings.Index(code, "package ")
-	if pkgIdx == -1 {
+// insertRuntimeImport adds the runtime import after the package clause,
+// whose name is at pkg. The header of the file is output as written, so pkg
+// is also its position in src.
+func (g *Generator) insertRuntimeImport(src []byte, pkg ast.Range) []byte {
+	code := string(src)
+	if !pkg.IsValid() || pkg.End.Offset > len(code) {
 		return src
 	}
 
 	// Find the end of the package line
-	newlineIdx := strings.Index(code[pkgIdx:], "\n")
+	newlineIdx := strings.Index(code[pkg.End.Offset:], "\n")
 	if newlineIdx == -1 {
 		return src
 	}
-	insertPos := pkgIdx + newlineIdx + 1
+	insertPos := pkg.End.Offset + newlineIdx + 1
 
 	// Check if there's an existing import block
 	importIdx := strings.Index(code[insertPos:], "import ")
@@ -165,10 +253,9 @@ func (g *Generator) generateJSXElement(elem *ast.JSXElement) {
 		)
 	}
 
-	// Determine if it's an intrinsic element (lowercase) or component (uppercase)
-	isComponent := len(elem.Tag) > 0 && unicode.IsUpper(rune(elem.Tag[0]))
-
-	if isComponent {
+	// Determine if it's an intrinsic element (lowercase) or component
+	// (uppercase, or qualified)
+	if ast.IsComponentTag(elem.Tag) {
 		// Typed component: ComponentName(ComponentNameProps{...}, children...)
 		g.generateTypedComponent(elem)
 	} else {
@@ -179,8 +266,14 @@ func (g *Generator) generateJSXElement(elem *ast.JSXElement) {
 
 // generateTypedComponent generates code for a typed component.
 // Output: ComponentName(ComponentNameProps{Field: value, ...}, child1, child2, ...)
+// or, for <ComponentName[T]>, ComponentName(ComponentNameProps[T]{...}, ...)
 func (g *Generator) generateTypedComponent(elem *ast.JSXElement) {
-	propsType := elem.Tag + "Props"
+	propsType := componentPropsType(elem.Tag)
+	if elem.TypeArgs != "" {
+		// Props literals are never inferred, so instantiate the props type;
+		// the component's own type parameters are inferred from it
+		propsType += "[" + elem.TypeArgs + "]"
+	}
 
 	g.write(elem.Tag)
 	g.write("(")
@@ -194,6 +287,20 @@ func (g *Generator) generateTypedComponent(elem *ast.JSXElement) {
 	g.write(")")
 }
 
+// componentPropsType returns the props type of a component tag: CardProps
+// for Card, and ui.CardProps for ui.Card. Go types are qualified by a
+// package at most, so a component reached through fields, such as
+// widgets.forms.Input, takes its props type from the package the tag starts
+// with: widgets.InputProps.
+func componentPropsType(tag string) string {
+	i := strings.LastIndex(tag, ".")
+	if i < 0 {
+		return tag + "Props"
+	}
+	qualifier, _, _ := strings.Cut(tag, ".")
+	return qualifier + "." + tag[i+1:] + "Props"
+}
+
 // generateIntrinsicElement generates code for an intrinsic element.
 // Output: gox.Element("tag", gox.Props{...}, child1, child2, ...)
 func (g *Generator) generateIntrinsicElement(elem *ast.JSXElement) {
@@ -201,39 +308,182 @@ func (g *Generator) generateIntrinsicElement(elem *ast.JSXElement) {
 	g.write(fmt.Sprintf("%q", elem.Tag))
 	g.write(", ")
 
+	// A children={nodes} attribute supplies the children unless there are
+	// nested ones, which take precedence. Nested children leave the attribute
+	// in the props, where gox.Element drops it, so it is still evaluated.
+	// A spread may set children too, so with spreads the attribute stays a
+	// prop, merged in order, for gox.Element to use.
+	attrs := elem.Attributes
+	var childrenAttr *ast.ExpressionAttribute
+	if len(g.groupChildren(elem.Children)) == 0 && !hasSpread(attrs) {
+		attrs, childrenAttr = splitChildrenAttribute(attrs)
+	}
+
 	// Props
-	g.generateProps(elem.Attributes)
+	g.generateProps(attrs)
 
 	// Children
-	g.generateChildren(elem.Children)
+	if childrenAttr != nil {
+		// gox.Element("div", nil, gox.Children(nodes)...)
+		g.write(",\n")
+		g.writeIndent()
+		g.write("gox.Children(")
+		g.writeAttributeExpression(childrenAttr, strings.TrimSpace(childrenAttr.Expression))
+		g.write(")...")
+	} else {
+		g.generateChildren(elem.Children)
+	}
 
 	g.write(")")
 }
 
+// splitChildrenAttribute separates a children={...} expression attribute from
+// the other attributes.
+func splitChildrenAttribute(attrs []ast.Attribute) ([]ast.Attribute, *ast.ExpressionAttribute) {
+	for i, attr := range attrs {
+		if a, ok := attr.(*ast.ExpressionAttribute); ok && a.Key == "children" {
+			rest := make([]ast.Attribute, 0, len(attrs)-1)
+			rest = append(rest, attrs[:i]...)
+			return append(rest, attrs[i+1:]...), a
+		}
+	}
+	return attrs, nil
+}
+
 // generateChildren generates the children arguments for an element.
 func (g *Generator) generateChildren(children []ast.JSXChild) {
-	for _, child := range children {
-		// Skip whitespace-only text
-		if t, ok := child.(*ast.JSXText); ok {
-			if strings.TrimSpace(t.Value) == "" {
-				continue
+	for _, group := range g.groupChildren(children) {
+		g.write(",\n")
+		g.writeIndent()
+		g.generateChildGroup(group)
+	}
+}
+
+// groupChildren drops children that produce no output and groups runs of text
+// mixed with simple expressions, which are generated as one gox.Textf call.
+// Every other child is a group of its own.
+func (g *Generator) groupChildren(children []ast.JSXChild) [][]ast.JSXChild {
+	var groups [][]ast.JSXChild
+	for i := 0; i < len(children); {
+		j := i
+		for j < len(children) && g.isInterpolated(children[j]) {
+			j++
+		}
+		if j > i && g.collapsesToText(children[i:j]) {
+			groups = append(groups, children[i:j])
+			i = j
+			continue
+		}
+		if j == i {
+			j = i + 1
+		}
+		for _, child := range children[i:j] {
+			if !g.isBlankChild(child) {
+				groups = append(groups, []ast.JSXChild{child})
 			}
 		}
-		// Skip comment-only expressions
-		if e, ok := child.(*ast.JSXExpression); ok {
-			expr := strings.TrimSpace(e.Expression)
-			if expr == "" || isCommentOnly(expr) {
-				continue
+		i = j
+	}
+	return groups
+}
+
+// generateChildGroup generates a group produced by groupChildren.
+func (g *Generator) generateChildGroup(group []ast.JSXChild) {
+	if len(group) == 1 {
+		g.generateJSXChild(group[0])
+		return
+	}
+	g.generateTextRun(group)
+}
+
+// isBlankChild reports whether a child produces no output: text that renders
+// nothing, an empty expression, or a comment.
+func (g *Generator) isBlankChild(child ast.JSXChild) bool {
+	switch c := child.(type) {
+	case *ast.JSXText:
+		return g.text(c) == ""
+	case *ast.JSXExpression:
+		return strings.TrimSpace(c.Expression) == ""
+	case *ast.JSXComment:
+		return true
+	}
+	return false
+}
+
+// text returns the text t renders: its Content, or in a file with the
+// //gox:preserve-whitespace directive, the text as written, with \r\n line
+// breaks as \n.
+func (g *Generator) text(t *ast.JSXText) string {
+	if g.preserveSpace {
+		return strings.ReplaceAll(t.Value, "\r\n", "\n")
+	}
+	return t.Content()
+}
+
+// isInterpolated reports whether a child can be part of a gox.Textf run: text,
+// or an expression that contains no JSX and is not a conditional (&&).
+func (g *Generator) isInterpolated(child ast.JSXChild) bool {
+	switch c := child.(type) {
+	case *ast.JSXText:
+		return true
+	case *ast.JSXExpression:
+		expr := strings.TrimSpace(c.Expression)
+		if expr == "" || strings.Contains(expr, " && ") {
+			return false
+		}
+		return g.transformExpressionJSX(expr) == expr
+	}
+	return false
+}
+
+// collapsesToText reports whether a run of interpolated children is worth a
+// gox.Textf call: it needs at least one expression and some literal text.
+func (g *Generator) collapsesToText(run []ast.JSXChild) bool {
+	hasExpr, hasText := false, false
+	for _, child := range run {
+		switch c := child.(type) {
+		case *ast.JSXExpression:
+			hasExpr = true
+		case *ast.JSXText:
+			if g.text(c) != "" {
+				hasText = true
 			}
 		}
-		g.write(",\n")
-		g.writeIndent()
-		g.generateJSXChild(child)
 	}
+	return hasExpr && hasText
+}
+
+// generateTextRun generates text mixed with expressions as a single call:
+// Hello, {name}! -> gox.Textf("Hello, %v!", name)
+func (g *Generator) generateTextRun(run []ast.JSXChild) {
+	var format strings.Builder
+	for _, child := range run {
+		if t, ok := child.(*ast.JSXText); ok {
+			format.WriteString(strings.ReplaceAll(g.text(t), "%", "%%"))
+		} else {
+			format.WriteString("%v")
+		}
+	}
+
+	g.addRangeMapping(run[0].GetRange())
+	g.write(fmt.Sprintf("gox.Textf(%q", format.String()))
+	for _, child := range run {
+		e, ok := child.(*ast.JSXExpression)
+		if !ok {
+			continue
+		}
+		r := e.GetRange()
+		line, col := advancePosition(r.Start.Line, r.Start.Column+1, leadingSpace(e.Expression))
+		g.write(", ")
+		g.writeWithMapping(strings.TrimSpace(e.Expression), line, col)
+	}
+	g.write(")")
 }
 
 // generateTypedProps generates a typed props struct literal.
 // Output: PropsType{Field: value, ...}
+// An optional attribute, name?={value}, sets its field as name={value}
+// does: a nil value leaves the field as if the attribute were left out.
 func (g *Generator) generateTypedProps(attrs []ast.Attribute, propsType string) {
 	if len(attrs) == 0 {
 		g.write(propsType + "{}")
@@ -249,11 +499,13 @@ func (g *Generator) generateTypedProps(attrs []ast.Attribute, propsType string)
 		}
 		first = false
 
+		g.addRangeMapping(attr.GetRange())
 		switch a := attr.(type) {
 		case *ast.StringAttribute:
-			g.write(fmt.Sprintf("%s: %q", capitalize(a.Key), a.Value))
+			g.write(fmt.Sprintf("%s: %q", propsField(a.Key), normalizeNewlines(a.Value)))
 		case *ast.ExpressionAttribute:
-			g.write(fmt.Sprintf("%s: %s", capitalize(a.Key), a.Expression))
+			g.write(propsField(a.Key) + ": ")
+			g.writeAttributeExpression(a, strings.TrimSpace(a.Expression))
 		}
 	}
 
@@ -271,41 +523,88 @@ func (g *Generator) generateJSXFragment(frag *ast.JSXFragment) {
 		)
 	}
 
-	g.write("gox.Fragment(")
-
-	first := true
-	for _, child := range frag.Children {
-		// Skip whitespace-only text
-		if t, ok := child.(*ast.JSXText); ok {
-			if strings.TrimSpace(t.Value) == "" {
-				continue
-			}
+	groups := g.groupChildren(frag.Children)
+	if key := frag.Key(); key != nil {
+		// <Fragment key={...}> carries its key for keyed diffing
+		g.write("gox.KeyedFragment(")
+		g.addRangeMapping(key.GetRange())
+		switch a := key.(type) {
+		case *ast.StringAttribute:
+			g.write(fmt.Sprintf("%q", normalizeNewlines(a.Value)))
+		case *ast.ExpressionAttribute:
+			g.writeAttributeExpression(a, strings.TrimSpace(a.Expression))
 		}
-		// Skip comment-only expressions
-		if e, ok := child.(*ast.JSXExpression); ok {
-			expr := strings.TrimSpace(e.Expression)
-			if expr == "" || isCommentOnly(expr) {
-				continue
-			}
+		if len(groups) > 0 {
+			g.write(", ")
 		}
-		if !first {
+	} else {
+		g.write("gox.Fragment(")
+	}
+
+	for i, group := range groups {
+		if i > 0 {
 			g.write(",\n")
 			g.writeIndent()
 		}
-		first = false
-		g.generateJSXChild(child)
+		g.generateChildGroup(group)
 	}
 
 	g.write(")")
 }
 
-// generateProps generates the Props map for an element.
+// generateProps generates the Props map for an element. Spread attributes
+// are merged with the runs of attributes between them in the order they are
+// written, so that the last one to set a prop wins:
+// {...a} x="1" {...b} -> gox.MergeProps(a, gox.Props{"x": "1"}, b)
 func (g *Generator) generateProps(attrs []ast.Attribute) {
 	if len(attrs) == 0 {
 		g.write("nil")
 		return
 	}
+	if !hasSpread(attrs) {
+		g.generatePropsLiteral(attrs)
+		return
+	}
+
+	g.write("gox.MergeProps(")
+	for i := 0; i < len(attrs); {
+		if i > 0 {
+			g.write(", ")
+		}
+		if s, ok := attrs[i].(*ast.SpreadAttribute); ok {
+			g.addRangeMapping(s.Range)
+			g.writeWithMapping(s.Expression, s.ExpressionRange.Start.Line, s.ExpressionRange.Start.Column)
+			i++
+			continue
+		}
+		j := i
+		for j < len(attrs) && !isSpread(attrs[j]) {
+			j++
+		}
+		g.generatePropsLiteral(attrs[i:j])
+		i = j
+	}
+	g.write(")")
+}
+
+// hasSpread reports whether attrs holds a spread attribute.
+func hasSpread(attrs []ast.Attribute) bool {
+	for _, attr := range attrs {
+		if isSpread(attr) {
+			return true
+		}
+	}
+	return false
+}
 
+func isSpread(attr ast.Attribute) bool {
+	_, ok := attr.(*ast.SpreadAttribute)
+	return ok
+}
+
+// generatePropsLiteral generates a gox.Props literal of attributes without
+// spreads.
+func (g *Generator) generatePropsLiteral(attrs []ast.Attribute) {
 	g.write("gox.Props{")
 
 	first := true
@@ -315,11 +614,24 @@ func (g *Generator) generateProps(attrs []ast.Attribute) {
 		}
 		first = false
 
+		g.addRangeMapping(attr.GetRange())
 		switch a := attr.(type) {
 		case *ast.StringAttribute:
-			g.write(fmt.Sprintf("%q: %q", a.Key, a.Value))
+			g.write(fmt.Sprintf("%q: %q", a.Key, normalizeNewlines(a.Value)))
 		case *ast.ExpressionAttribute:
-			g.write(fmt.Sprintf("%q: %s", a.Key, wrapMapLiteral(a.Expression)))
+			g.write(fmt.Sprintf("%q: ", a.Key))
+			if a.Optional {
+				// name?={value}: gox.Element leaves out the prop if value is nil
+				g.write("gox.Optional(")
+			}
+			expr := strings.TrimSpace(a.Expression)
+			if wrapped := wrapMapLiteral(expr); wrapped != expr {
+				g.write(strings.TrimSuffix(wrapped, expr))
+			}
+			g.writeAttributeExpression(a, expr)
+			if a.Optional {
+				g.write(")")
+			}
 		}
 	}
 
@@ -330,30 +642,49 @@ func (g *Generator) generateProps(attrs []ast.Attribute) {
 func (g *Generator) generateJSXChild(child ast.JSXChild) {
 	switch c := child.(type) {
 	case *ast.JSXText:
-		text := strings.TrimSpace(c.Value)
+		text := g.text(c)
 		if text == "" {
-			return // Skip whitespace-only text
+			return // Skip text that renders nothing
 		}
+		r := c.GetRange()
+		line, col := advancePosition(r.Start.Line, r.Start.Column, leadingSpace(c.Value))
+		g.addMapping(line, col)
 		g.write(fmt.Sprintf("gox.Text(%q)", text))
 
 	case *ast.JSXExpression:
 		expr := strings.TrimSpace(c.Expression)
 
-		// Skip empty expressions or comment-only expressions
-		if expr == "" || isCommentOnly(expr) {
+		// Skip empty expressions
+		if expr == "" {
 			return
 		}
 
+		// Source position of the trimmed expression (just past the opening brace)
+		r := c.GetRange()
+		line, col := advancePosition(r.Start.Line, r.Start.Column+1, leadingSpace(c.Expression))
+		g.addMapping(line, col)
+
 		// Transform any JSX within the expression
 		transformed := g.transformExpressionJSX(expr)
+		verbatim := transformed == expr
 
 		// Check for conditional pattern: expr && <elem>
 		if idx := strings.Index(transformed, " && "); idx != -1 {
 			cond := strings.TrimSpace(transformed[:idx])
 			rest := strings.TrimSpace(transformed[idx+4:])
-			g.write(fmt.Sprintf("gox.When(%s, %s)", cond, rest))
-		} else {
+			g.write("gox.When(")
+			if verbatim {
+				g.writeWithMapping(cond, line, col)
+			} else {
+				g.write(cond)
+			}
+			g.write(", " + rest + ")")
+		} else if verbatim {
 			// Wrap expressions in gox.V() to convert any value to VNode
+			g.write("gox.V(")
+			g.writeWithMapping(expr, line, col)
+			g.write(")")
+		} else {
 			g.write(fmt.Sprintf("gox.V(%s)", transformed))
 		}
 
@@ -371,30 +702,13 @@ func (g *Generator) transformExpressionJSX(expr string) string {
 
 	// Keep transforming until no more JSX is found
 	for {
-		// Find JSX start: < followed by identifier
-		jsxStart := -1
-		for i := 0; i < len(result); i++ {
-			if result[i] == '<' && i+1 < len(result) {
-				next := result[i+1]
-				// Check for identifier start (letter or uppercase for component)
-				if (next >= 'a' && next <= 'z') || (next >= 'A' && next <= 'Z') {
-					jsxStart = i
-					break
-				}
-				// Check for fragment <>
-				if next == '>' {
-					jsxStart = i
-					break
-				}
-			}
-		}
-
+		jsxStart := lexer.FindJSX(result)
 		if jsxStart == -1 {
 			break // No more JSX
 		}
 
 		// Extract and transform the JSX portion
-		jsxEnd := g.findJSXEnd(result, jsxStart)
+		jsxEnd := lexer.FindJSXEnd(result, jsxStart, g.rawElements)
 		if jsxEnd == -1 {
 			break // Malformed JSX
 		}
@@ -408,66 +722,19 @@ func (g *Generator) transformExpressionJSX(expr string) string {
 	return result
 }
 
-// findJSXEnd finds the end of a JSX element starting at pos.
-func (g *Generator) findJSXEnd(s string, start int) int {
-	depth := 0
-	i := start
-
-	for i < len(s) {
-		if s[i] == '<' {
-			if i+1 < len(s) && s[i+1] == '/' {
-				// Closing tag
-				depth--
-				// Find the >
-				for i < len(s) && s[i] != '>' {
-					i++
-				}
-				if depth == 0 {
-					return i + 1
-				}
-			} else if i+1 < len(s) && s[i+1] == '>' {
-				// Fragment open <>
-				depth++
-				i += 2
-			} else {
-				// Opening tag
-				depth++
-				// Scan to find > or />
-				for i < len(s) {
-					if s[i] == '/' && i+1 < len(s) && s[i+1] == '>' {
-						// Self-closing
-						depth--
-						i += 2
-						if depth == 0 {
-							return i
-						}
-						break
-					}
-					if s[i] == '>' {
-						i++
-						break
-					}
-					i++
-				}
-			}
-		} else {
-			i++
-		}
-	}
-
-	return -1
-}
-
 // transformJSXString parses and transforms a JSX string to Go code.
 func (g *Generator) transformJSXString(jsx string) string {
-	// Parse the JSX using our parser
-	file, err := parser.Parse("<expr>", []byte(jsx))
+	// Parse the JSX using our parser, with the raw elements of the file
+	p := parser.New("<expr>", []byte(jsx))
+	p.SetRawElements(g.rawElements)
+	file, err := p.Parse()
 	if err != nil || len(file.Nodes) == 0 {
 		return jsx // Return unchanged if parsing fails
 	}
 
 	// Generate code for the parsed JSX
 	gen := New(&Options{RuntimePackage: g.runtimePkg})
+	gen.rawElements = g.rawElements
 	for _, node := range file.Nodes {
 		gen.generateNode(node)
 	}
@@ -475,29 +742,6 @@ func (g *Generator) transformJSXString(jsx string) string {
 	return gen.buf.String()
 }
 
-// isCommentOnly checks if a string contains only Go comments.
-func isCommentOnly(s string) bool {
-	s = strings.TrimSpace(s)
-	if s == "" {
-		return true
-	}
-
-	// Check for /* */ style comment
-	if strings.HasPrefix(s, "/*") && strings.HasSuffix(s, "*/") {
-		// Check if there's anything besides the comment
-		inner := strings.TrimPrefix(s, "/*")
-		inner = strings.TrimSuffix(inner, "*/")
-		return strings.TrimSpace(inner) == "" || !strings.Contains(inner, "*/")
-	}
-
-	// Check for // style comment
-	if strings.HasPrefix(s, "//") {
-		return true
-	}
-
-	return false
-}
-
 // wrapMapLiteral adds map[string]any prefix to bare map literals.
 // Converts {key: value} to map[string]any{key: value}
 func wrapMapLiteral(expr string) string {
@@ -517,6 +761,18 @@ func wrapMapLiteral(expr string) string {
 	return expr
 }
 
+// propsField returns the props struct field an attribute of a component
+// sets: the attribute capitalized, with each part of a dashed or namespaced
+// name capitalized and joined, as in aria-label -> AriaLabel. Intrinsic
+// elements keep attribute names as they are.
+func propsField(key string) string {
+	parts := strings.FieldsFunc(key, func(r rune) bool { return r == '-' || r == ':' })
+	for i, part := range parts {
+		parts[i] = capitalize(part)
+	}
+	return strings.Join(parts, "")
+}
+
 // capitalize converts the first letter of a string to uppercase.
 // Used to convert JSX attribute names to Go struct field names.
 // e.g., "onClick" -> "OnClick", "label" -> "Label"
@@ -532,6 +788,9 @@ func capitalize(s string) string {
 // Helper methods
 
 func (g *Generator) write(s string) {
+	// Go code from a file with \r\n line breaks is written with \n ones,
+	// so that positions in the output map back as the lexer counts them
+	s = normalizeNewlines(s)
 	g.buf.WriteString(s)
 	// Update position tracking
 	for _, r := range s {
@@ -547,6 +806,7 @@ func (g *Generator) write(s string) {
 // writeWithMapping writes a string and records source map mapping.
 // srcLine and srcCol are 1-indexed (from AST), converted to 0-indexed for source map.
 func (g *Generator) writeWithMapping(s string, srcLine, srcCol int) {
+	s = normalizeNewlines(s)
 	if srcLine > 0 && srcCol > 0 {
 		// Record mapping for each character
 		srcPos := NewPosition(0, uint32(srcLine-1), uint32(srcCol-1))
@@ -556,6 +816,85 @@ func (g *Generator) writeWithMapping(s string, srcLine, srcCol int) {
 	g.write(s)
 }
 
+// writeAttributeExpression writes an attribute's expression (or the trimmed
+// part of it) with character-level mappings back to the .gox source.
+func (g *Generator) writeAttributeExpression(a *ast.ExpressionAttribute, expr string) {
+	if a.Parts != nil {
+		g.generateExpressionParts(a.Parts)
+		return
+	}
+	r := a.ValueRange
+	if !r.IsValid() {
+		// Boolean shorthand: there is no expression in the source
+		g.write(expr)
+		return
+	}
+	// The expression starts after the opening brace, followed by any
+	// leading whitespace that was trimmed from expr.
+	skip := strings.Index(a.Expression, strings.TrimSpace(expr))
+	if skip < 0 {
+		skip = 0
+	}
+	line, col := advancePosition(r.Start.Line, r.Start.Column+1, a.Expression[:skip])
+	g.writeWithMapping(expr, line, col)
+}
+
+// generateExpressionParts generates an expression holding JSX from its parts:
+// Go code is written as is and the JSX generated in place, each mapped to
+// its position in the .gox source.
+func (g *Generator) generateExpressionParts(parts []ast.Node) {
+	for i, part := range parts {
+		code, ok := part.(*ast.GoCode)
+		if !ok {
+			g.generateNode(part)
+			continue
+		}
+		value := code.Value
+		r := code.GetRange()
+		if i == 0 {
+			trimmed := strings.TrimLeftFunc(value, unicode.IsSpace)
+			line, col := advancePosition(r.Start.Line, r.Start.Column, value[:len(value)-len(trimmed)])
+			r.Start.Line, r.Start.Column, value = line, col, trimmed
+		}
+		if i == len(parts)-1 {
+			value = strings.TrimRightFunc(value, unicode.IsSpace)
+		}
+		g.writeWithMapping(value, r.Start.Line, r.Start.Column)
+	}
+}
+
+// addMapping records a mapping from a 1-indexed source position to the
+// current output position.
+func (g *Generator) addMapping(srcLine, srcCol int) {
+	if srcLine > 0 && srcCol > 0 {
+		g.sourceMap.AddMapping(uint32(srcLine-1), uint32(srcCol-1), g.outLine, g.outCol)
+	}
+}
+
+// addRangeMapping records a mapping from the start of a source range to the
+// current output position.
+func (g *Generator) addRangeMapping(r ast.Range) {
+	g.addMapping(r.Start.Line, r.Start.Column)
+}
+
+// normalizeNewlines returns s with its \r\n line breaks as \n, so that
+// the output is the same whatever the line endings of its file.
+func normalizeNewlines(s string) string {
+	return strings.ReplaceAll(s, "\r\n", "\n")
+}
+
+// leadingSpace returns the leading whitespace of s.
+func leadingSpace(s string) string {
+	return s[:len(s)-len(strings.TrimLeftFunc(s, unicode.IsSpace))]
+}
+
+// advancePosition returns the 1-indexed line and rune column reached after
+// reading s starting at line:col.
+func advancePosition(line, col int, s string) (int, int) {
+	p := ast.Position{Line: line, Column: col}.Advance(s)
+	return p.Line, p.Column
+}
+
 func (g *Generator) writeIndent() {
 	for i := 0; i < g.indent; i++ {
 		g.write("\t")
@@ -4,6 +4,7 @@ import (
 	"strings"
 	"testing"
 
+	"github.com/germtb/gox/diag"
 	"github.com/germtb/gox/parser"
 )
 
@@ -81,6 +82,149 @@ func TestGenerateElementWithAttributes(t *testing.T) {
 	}
 }
 
+func TestGenerateDashedAndNamespacedAttributes(t *testing.T) {
+	src := `<svg data-testid="x" aria-label={l} xlink:href="#a"><Card data-id="1" aria-hidden /></svg>`
+
+	file, err := parser.Parse("test.gox", []byte(src))
+	if err != nil {
+		t.Fatalf("Parse error: %v", err)
+	}
+
+	output, _, err := Generate(file, nil)
+	if err != nil {
+		t.Fatalf("Generate error: %v", err)
+	}
+
+	code := string(output)
+
+	// Intrinsic elements keep the names as props keys
+	for _, want := range []string{`"data-testid": "x"`, `"aria-label": l`, `"xlink:href": "#a"`} {
+		if !strings.Contains(code, want) {
+			t.Errorf("Expected %s, got:\n%s", want, code)
+		}
+	}
+	// Components set the field named after each part
+	if !strings.Contains(code, `CardProps{DataId: "1", AriaHidden: true}`) {
+		t.Errorf("Expected CardProps fields DataId and AriaHidden, got:\n%s", code)
+	}
+}
+
+func TestGenerateRawStringAttributes(t *testing.T) {
+	src := "<p label=`say \"hi\"\n  to 'all'` tooltip={`multi\nline`} />"
+
+	file, err := parser.Parse("test.gox", []byte(src))
+	if err != nil {
+		t.Fatalf("Parse error: %v", err)
+	}
+
+	output, _, err := Generate(file, nil)
+	if err != nil {
+		t.Fatalf("Generate error: %v", err)
+	}
+
+	code := string(output)
+	for _, want := range []string{`"label": "say \"hi\"\n  to 'all'"`, "\"tooltip\": `multi\nline`"} {
+		if !strings.Contains(code, want) {
+			t.Errorf("Expected %s, got:\n%s", want, code)
+		}
+	}
+}
+
+func TestGenerateOptionalAttribute(t *testing.T) {
+	src := `<div><a title?={title} /><Link title?={title} /></div>`
+
+	file, err := parser.Parse("test.gox", []byte(src))
+	if err != nil {
+		t.Fatalf("Parse error: %v", err)
+	}
+
+	output, _, err := Generate(file, nil)
+	if err != nil {
+		t.Fatalf("Generate error: %v", err)
+	}
+
+	code := string(output)
+	if !strings.Contains(code, `gox.Props{"title": gox.Optional(title)}`) {
+		t.Errorf("Expected the prop to be wrapped in gox.Optional, got:\n%s", code)
+	}
+	// A typed props field is set as is
+	if !strings.Contains(code, `LinkProps{Title: title}`) {
+		t.Errorf("Expected LinkProps{Title: title}, got:\n%s", code)
+	}
+}
+
+func TestGenerateSpreadAttributes(t *testing.T) {
+	src := "package main\n\nfunc App(a, b gox.Props) gox.VNode {\n\treturn <box {...a} x=\"1\" { ...b } y={2} children={nil} />\n}\n"
+
+	file, err := parser.Parse("test.gox", []byte(src))
+	if err != nil {
+		t.Fatalf("Parse error: %v", err)
+	}
+
+	output, sm, err := Generate(file, nil)
+	if err != nil {
+		t.Fatalf("Generate error: %v", err)
+	}
+
+	// Later attributes win, so the order is kept, and children stays a prop
+	// since a spread may set it too
+	code := string(output)
+	want := `gox.Element("box", gox.MergeProps(a, gox.Props{"x": "1"}, b, gox.Props{"y": 2, "children": nil}))`
+	if !strings.Contains(code, want) {
+		t.Fatalf("Expected %s, got:\n%s", want, code)
+	}
+
+	// Each spread maps back to its expression
+	for _, tt := range []struct {
+		target string
+		srcCol uint32
+	}{
+		{"MergeProps(a", 17},
+		{", b,", 31},
+	} {
+		for i, line := range strings.Split(code, "\n") {
+			idx := strings.Index(line, tt.target)
+			if idx < 0 {
+				continue
+			}
+			col := uint32(idx + strings.IndexAny(tt.target, "ab"))
+			pos, ok := sm.SourcePositionFromTarget(uint32(i), col)
+			if !ok || pos.Line != 3 || pos.Column != tt.srcCol {
+				t.Errorf("%s: got source %d:%d, want 3:%d", tt.target, pos.Line, pos.Column, tt.srcCol)
+			}
+		}
+	}
+}
+
+func TestGenerateChildrenAttribute(t *testing.T) {
+	tests := []struct {
+		name string
+		src  string
+		want string
+	}{
+		{"as children", `<ul class="list" children={items} />`, `gox.Element("ul", gox.Props{"class": "list"},
+	gox.Children(items)...)`},
+		{"nested children win", `<ul children={items}><li>first</li></ul>`, `gox.Element("ul", gox.Props{"children": items},
+	gox.Element("li", nil,
+		gox.Text("first")))`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			file, err := parser.Parse("test.gox", []byte(tt.src))
+			if err != nil {
+				t.Fatalf("Parse error: %v", err)
+			}
+			output, _, err := Generate(file, nil)
+			if err != nil {
+				t.Fatalf("Generate error: %v", err)
+			}
+			if code := string(output); !strings.Contains(code, tt.want) {
+				t.Errorf("Expected %s, got:\n%s", tt.want, code)
+			}
+		})
+	}
+}
+
 func TestGenerateElementWithTextChild(t *testing.T) {
 	src := `<text>Hello World</text>`
 
@@ -122,6 +266,114 @@ func TestGenerateElementWithExpressionChild(t *testing.T) {
 	}
 }
 
+func TestGenerateSkipsComments(t *testing.T) {
+	src := "<ul>\n\t{/* items */}\n\t<li>one</li>\n\t{// more later\n\t}\n</ul>"
+
+	file, err := parser.Parse("test.gox", []byte(src))
+	if err != nil {
+		t.Fatalf("Parse error: %v", err)
+	}
+
+	output, _, err := Generate(file, nil)
+	if err != nil {
+		t.Fatalf("Generate error: %v", err)
+	}
+
+	code := string(output)
+	if strings.Contains(code, "items") || strings.Contains(code, "more later") {
+		t.Errorf("Expected comments to be left out, got:\n%s", code)
+	}
+	if !strings.Contains(code, `gox.Text("one")`) {
+		t.Errorf("Expected the <li> child, got:\n%s", code)
+	}
+}
+
+func TestGenerateTextInterpolation(t *testing.T) {
+	tests := []struct {
+		name     string
+		src      string
+		contains []string
+		excludes []string
+	}{
+		{
+			name:     "text and expression collapse",
+			src:      `<p>Hello, {name}!</p>`,
+			contains: []string{`gox.Textf("Hello, %v!", name)`},
+			excludes: []string{`gox.V(name)`},
+		},
+		{
+			name:     "percent signs are escaped",
+			src:      `<p>{pct}% done</p>`,
+			contains: []string{`gox.Textf("%v%% done", pct)`},
+		},
+		{
+			name: "line breaks follow JSX whitespace rules",
+			src: `<p>
+	Signed in as
+	{user.Name}
+</p>`,
+			contains: []string{`gox.Textf("Signed in as%v", user.Name)`},
+		},
+		{
+			name:     "spaces next to elements are kept",
+			src:      `<p>Hello <b>world</b>! Bye  now <i>x</i></p>`,
+			contains: []string{`gox.Text("Hello ")`, `gox.Text("! Bye now ")`},
+		},
+		{
+			name: "whitespace with line breaks is dropped",
+			src: `<p>
+	<b>a</b> <i>b</i>
+	<u>c</u>
+</p>`,
+			contains: []string{`gox.Text(" ")`},
+			excludes: []string{`gox.Text("\n`, `gox.Text(" \n`},
+		},
+		{
+			name:     "tags in strings and comparisons are not JSX",
+			src:      `<p title={fmt.Sprint("a, <b>")}>{"x, <i>y</i>"}{max(a, b)<c}</p>`,
+			contains: []string{`fmt.Sprint("a, <b>")`, `gox.V("x, <i>y</i>")`, `gox.V(max(a, b) < c)`},
+		},
+		{
+			name:     "lone expression stays a node",
+			src:      `<p>{name}</p>`,
+			contains: []string{`gox.V(name)`},
+			excludes: []string{`gox.Textf`},
+		},
+		{
+			name:     "JSX expressions break the run",
+			src:      `<p>Hi {show && <b>there</b>}</p>`,
+			contains: []string{`gox.Text("Hi ")`, `gox.When(show`},
+			excludes: []string{`gox.Textf`},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			file, err := parser.Parse("test.gox", []byte(tt.src))
+			if err != nil {
+				t.Fatalf("Parse error: %v", err)
+			}
+
+			output, _, err := Generate(file, nil)
+			if err != nil {
+				t.Fatalf("Generate error: %v", err)
+			}
+
+			code := string(output)
+			for _, want := range tt.contains {
+				if !strings.Contains(code, want) {
+					t.Errorf("Expected %s, got:\n%s", want, code)
+				}
+			}
+			for _, unwanted := range tt.excludes {
+				if strings.Contains(code, unwanted) {
+					t.Errorf("Did not expect %s, got:\n%s", unwanted, code)
+				}
+			}
+		})
+	}
+}
+
 func TestGenerateNestedElements(t *testing.T) {
 	src := `<box><text>Hi</text></box>`
 
@@ -166,6 +418,25 @@ func TestGenerateFragment(t *testing.T) {
 	}
 }
 
+func TestGenerateKeyedFragment(t *testing.T) {
+	src := `<ul>{gox.Map(items, func(item Item) gox.VNode { return <Fragment key={item.ID}><dt>{item.Term}</dt></Fragment> })}</ul>`
+
+	file, err := parser.Parse("test.gox", []byte(src))
+	if err != nil {
+		t.Fatalf("Parse error: %v", err)
+	}
+
+	output, _, err := Generate(file, nil)
+	if err != nil {
+		t.Fatalf("Generate error: %v", err)
+	}
+
+	code := string(output)
+	if !strings.Contains(code, `return gox.KeyedFragment(item.ID, gox.Element("dt", nil,`) {
+		t.Errorf("Expected a KeyedFragment call, got:\n%s", code)
+	}
+}
+
 func TestGenerateComponentElement(t *testing.T) {
 	src := `<MyComponent foo="bar" />`
 
@@ -191,6 +462,34 @@ func TestGenerateComponentElement(t *testing.T) {
 	}
 }
 
+func TestGenerateQualifiedComponentElement(t *testing.T) {
+	tests := []struct {
+		src      string
+		expected string
+	}{
+		{`<ui.Button label="x" />`, `ui.Button(ui.ButtonProps{Label: "x"})`},
+		{`<ui.List[string] items={xs} />`, `ui.List(ui.ListProps[string]{Items: xs})`},
+		// Go types are qualified by a package at most
+		{`<widgets.forms.Input />`, `widgets.forms.Input(widgets.InputProps{})`},
+	}
+
+	for _, tt := range tests {
+		file, err := parser.Parse("test.gox", []byte(tt.src))
+		if err != nil {
+			t.Fatalf("Parse error: %v", err)
+		}
+
+		output, _, err := Generate(file, nil)
+		if err != nil {
+			t.Fatalf("Generate error: %v", err)
+		}
+
+		if !strings.Contains(string(output), tt.expected) {
+			t.Errorf("Expected %s for %s, got:\n%s", tt.expected, tt.src, output)
+		}
+	}
+}
+
 func TestGenerateComponentWithChildren(t *testing.T) {
 	src := `<Button label="Click">Hello</Button>`
 
@@ -236,6 +535,31 @@ func TestGenerateComponentWithExpressionProp(t *testing.T) {
 	}
 }
 
+func TestGenerateJSXInExpressionProp(t *testing.T) {
+	src := `<Card header={<Title text={name} />} footer={func() gox.VNode { return <p>bye</p> }} />`
+
+	file, err := parser.Parse("test.gox", []byte(src))
+	if err != nil {
+		t.Fatalf("Parse error: %v", err)
+	}
+
+	output, _, err := Generate(file, nil)
+	if err != nil {
+		t.Fatalf("Generate error: %v", err)
+	}
+
+	code := string(output)
+	for _, want := range []string{
+		"Header: Title(TitleProps{Text: name})",
+		"Footer: func() gox.VNode {",
+		`return gox.Element("p"`,
+	} {
+		if !strings.Contains(code, want) {
+			t.Errorf("Expected %q, got:\n%s", want, code)
+		}
+	}
+}
+
 func TestGeneratePreservesGoCode(t *testing.T) {
 	src := `package main
 
@@ -272,6 +596,31 @@ func main() {
 	}
 }
 
+func TestGenerateRuntimeImportAfterPackageClause(t *testing.T) {
+	// Mentions of "package" in comments do not move the import
+	src := `// This package draws the app; see package ui.
+package main
+
+func App() gox.VNode {
+	return <div />
+}`
+
+	file, err := parser.Parse("test.gox", []byte(src))
+	if err != nil {
+		t.Fatalf("Parse error: %v", err)
+	}
+
+	output, _, err := Generate(file, nil)
+	if err != nil {
+		t.Fatalf("Generate error: %v", err)
+	}
+
+	want := "package main\n\nimport \"github.com/germtb/gox\"\n"
+	if !strings.Contains(string(output), want) {
+		t.Errorf("Expected the runtime import after the package clause, got:\n%s", output)
+	}
+}
+
 func TestGenerateComplexExample(t *testing.T) {
 	src := `package main
 
@@ -368,3 +717,191 @@ func App() {
 		t.Error("Expected to find at least one source position from target")
 	}
 }
+
+func TestGenerateSourceMapExpressionColumns(t *testing.T) {
+	src := `package main
+
+func App() {
+	return <box title={missingTitle} header={<b>{missingHeader}</b>}>
+		<text>{ missingBody }</text>
+		<text style = {missingStyle} />
+	</box>
+}`
+
+	file, err := parser.Parse("test.gox", []byte(src))
+	if err != nil {
+		t.Fatalf("Parse error: %v", err)
+	}
+
+	code, sm, err := Generate(file, nil)
+	if err != nil {
+		t.Fatalf("Generate error: %v", err)
+	}
+
+	tests := []struct {
+		ident   string
+		srcLine uint32
+		srcCol  uint32
+	}{
+		{"missingTitle", 3, 20},
+		{"missingHeader", 3, 46},
+		{"missingBody", 4, 10},
+		{"missingStyle", 5, 17},
+	}
+
+	lines := strings.Split(string(code), "\n")
+	for _, tt := range tests {
+		tgtLine, tgtCol := -1, -1
+		for i, line := range lines {
+			if idx := strings.Index(line, tt.ident); idx >= 0 {
+				tgtLine, tgtCol = i, idx
+				break
+			}
+		}
+		if tgtLine < 0 {
+			t.Fatalf("%s not found in generated code:\n%s", tt.ident, code)
+		}
+
+		pos, ok := sm.SourcePositionFromTarget(uint32(tgtLine), uint32(tgtCol))
+		if !ok {
+			t.Fatalf("%s: no source position for %d:%d", tt.ident, tgtLine, tgtCol)
+		}
+		if pos.Line != tt.srcLine || pos.Column != tt.srcCol {
+			t.Errorf("%s: got source %d:%d, want %d:%d", tt.ident, pos.Line, pos.Column, tt.srcLine, tt.srcCol)
+		}
+	}
+}
+
+func TestGenerateSourceMapRuneColumns(t *testing.T) {
+	src := "package main\n\nfunc App() {\n\treturn <p title=\"日本\">héllo 😀 {missingName} <b>{missingB}</b></p>\n}\n"
+
+	file, err := parser.Parse("test.gox", []byte(src))
+	if err != nil {
+		t.Fatalf("Parse error: %v", err)
+	}
+
+	code, sm, err := Generate(file, nil)
+	if err != nil {
+		t.Fatalf("Generate error: %v", err)
+	}
+
+	// Go tools report byte columns of the generated code; the map is in runes
+	lines := strings.Split(string(code), "\n")
+	for _, tt := range []struct {
+		ident  string
+		srcCol uint32
+	}{
+		{"missingName", 31},
+		{"missingB", 48},
+	} {
+		for i, line := range lines {
+			idx := strings.Index(line, tt.ident)
+			if idx < 0 {
+				continue
+			}
+			tgtLine := uint32(i)
+			pos, ok := sm.SourcePositionFromTarget(tgtLine, sm.TargetRuneColumn(tgtLine, uint32(idx)))
+			if !ok || pos.Line != 3 || pos.Column != tt.srcCol {
+				t.Errorf("%s: got source %d:%d, want 3:%d", tt.ident, pos.Line, pos.Column, tt.srcCol)
+			}
+		}
+	}
+}
+
+func TestGenerateGoSyntaxError(t *testing.T) {
+	src := `package main
+
+func Greeting(name string) gox.VNode {
+	return <p>Hello, {name +}</p>
+}
+`
+	file, err := parser.Parse("app.gox", []byte(src))
+	if err != nil {
+		t.Fatalf("Parse error: %v", err)
+	}
+
+	output, sourceMap, err := Generate(file, nil)
+	d, ok := diag.As(err)
+	if !ok || d.Code != diag.GoSyntax {
+		t.Fatalf("Expected a %s diagnostic, got %v", diag.GoSyntax, err)
+	}
+	// At the closing brace, where the operand is missing
+	if d.File != "app.gox" || d.Line != 4 || d.Column != 26 {
+		t.Errorf("Expected the error at app.gox:4:26, got %s", d.Position())
+	}
+	// The unformatted output is still returned
+	if !strings.Contains(string(output), "name +)") || sourceMap == nil {
+		t.Errorf("Expected the unformatted output and its source map, got:\n%s", output)
+	}
+}
+
+func TestGenerateCRLF(t *testing.T) {
+	src := "package main\r\n\r\nfunc Page() gox.VNode {\r\n\treturn <div title=\"a\r\nb\">\r\n\t\tHello\r\n\t\t<script>a\r\nb</script>\r\n\t</div>\r\n}\r\n"
+	file, err := parser.Parse("test.gox", []byte(src))
+	if err != nil {
+		t.Fatalf("Parse error: %v", err)
+	}
+
+	output, _, err := Generate(file, nil)
+	if err != nil {
+		t.Fatalf("Generate error: %v", err)
+	}
+
+	code := string(output)
+	if strings.Contains(code, "\r") {
+		t.Errorf("Expected no \\r in the output, got:\n%q", code)
+	}
+	for _, want := range []string{
+		`gox.Props{"title": "a\nb"}`,
+		`gox.Text("Hello")`,
+		`gox.Text("a\nb")`,
+	} {
+		if !strings.Contains(code, want) {
+			t.Errorf("Expected %s, got:\n%s", want, code)
+		}
+	}
+
+	// Go errors at a line break are where they are with \n line breaks
+	file, err = parser.Parse("test.gox", []byte("package main\r\n\r\nvar x = f(<b />\r\n)\r\n"))
+	if err != nil {
+		t.Fatalf("Parse error: %v", err)
+	}
+	_, _, err = Generate(file, nil)
+	if d, ok := diag.As(err); !ok || d.Line != 3 || d.Column != 16 {
+		t.Errorf("Expected a diagnostic at 3:16, got %v", err)
+	}
+}
+
+func TestGenerateRawElements(t *testing.T) {
+	src := `//gox:raw pre
+package main
+
+func Page(ok bool) gox.VNode {
+	return <div>
+		<pre>  a {b}
+  <i>c</i></pre>
+		{ok && <script>if (a <b) { run() }</script>}
+	</div>
+}
+`
+	file, err := parser.Parse("test.gox", []byte(src))
+	if err != nil {
+		t.Fatalf("Parse error: %v", err)
+	}
+
+	output, _, err := Generate(file, nil)
+	if err != nil {
+		t.Fatalf("Generate error: %v", err)
+	}
+
+	code := string(output)
+	for _, want := range []string{
+		`gox.Text("  a {b}\n  <i>c</i>")`,
+		`gox.When(ok, gox.Element("script", nil,`,
+		`gox.Text("if (a <b) { run() }")`,
+	} {
+		if !strings.Contains(code, want) {
+			t.Errorf("Expected %s, got:\n%s", want, code)
+		}
+	}
+}
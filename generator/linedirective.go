@@ -0,0 +1,102 @@
+package generator
+
+import (
+	"fmt"
+	"go/scanner"
+	"go/token"
+	"strings"
+)
+
+// insertLineDirectives interleaves //line directives with generated code, so
+// that the compiler and other tools report positions in the .gox file named
+// name. A directive is inserted wherever the .gox line a generated line maps
+// to does not follow from the previous directive. Directives only set lines:
+// columns in generated code do not match the .gox file. The source map is
+// returned with its generated lines moved past the inserted directives.
+func insertLineDirectives(src []byte, sm *SourceMap, name string) ([]byte, *SourceMap) {
+	lines := strings.SplitAfter(string(src), "\n")
+	start, inside := directiveLines(src)
+
+	var b strings.Builder
+	var inserted []uint32 // Lines a directive was inserted before
+	assumed := -1         // The .gox line the compiler assumes for line i
+	for i, line := range lines {
+		if i >= start && !inside[i] {
+			if srcLine, ok := sm.FindSourceLine(uint32(i)); ok && int(srcLine) != assumed {
+				inserted = append(inserted, uint32(i))
+				fmt.Fprintf(&b, "//line %s:%d\n", name, srcLine+1)
+				assumed = int(srcLine)
+			}
+		}
+		b.WriteString(line)
+		if assumed >= 0 {
+			assumed++
+		}
+	}
+	if len(inserted) == 0 {
+		return src, sm
+	}
+	return []byte(b.String()), shiftTargetLines(sm, inserted)
+}
+
+// directiveLines returns the first line a directive may precede, that of the
+// package clause, and the lines that start inside a raw string or a
+// comment, before which a directive would change the code.
+func directiveLines(src []byte) (int, map[int]bool) {
+	fset := token.NewFileSet()
+	file := fset.AddFile("", -1, len(src))
+	var s scanner.Scanner
+	s.Init(file, src, nil, scanner.ScanComments)
+
+	start := 0
+	inside := make(map[int]bool)
+	for {
+		pos, tok, lit := s.Scan()
+		if tok == token.EOF {
+			break
+		}
+		if tok == token.PACKAGE && start == 0 {
+			start = file.Line(pos) - 1
+		}
+		if !strings.Contains(lit, "\n") {
+			continue
+		}
+		first := file.Line(pos) - 1
+		for i := 1; i <= strings.Count(lit, "\n"); i++ {
+			inside[first+i] = true
+		}
+	}
+	return start, inside
+}
+
+// shiftTargetLines returns sm with its target lines moved down past lines
+// inserted before the given target lines.
+func shiftTargetLines(sm *SourceMap, inserted []uint32) *SourceMap {
+	shift := func(line uint32) uint32 {
+		n := uint32(0)
+		for _, at := range inserted {
+			if at <= line {
+				n++
+			}
+		}
+		return line + n
+	}
+
+	out := NewSourceMap()
+	out.SetFiles(sm.SourceFile, sm.TargetFile)
+	for srcLine, cols := range sm.SourceToTarget {
+		for srcCol, tgt := range cols {
+			out.addSourceMapping(srcLine, srcCol, shift(tgt.Line), tgt.Column)
+		}
+	}
+	for tgtLine, cols := range sm.TargetToSource {
+		newLine := shift(tgtLine)
+		if out.TargetToSource[newLine] == nil {
+			out.TargetToSource[newLine] = make(map[uint32]Position)
+		}
+		for tgtCol, src := range cols {
+			out.TargetToSource[newLine][tgtCol] = src
+		}
+	}
+	return out
+}
@@ -0,0 +1,67 @@
+package generator
+
+import (
+	"go/parser"
+	"go/token"
+	"strings"
+	"testing"
+
+	goxparser "github.com/germtb/gox/parser"
+)
+
+func TestGenerateLineDirectives(t *testing.T) {
+	src := "package ui\n\nfunc A(n int) gox.VNode {\n\treturn <div class=\"a\">\n\t\t<p>{n}</p>\n\t\t<span>{missing}</span>\n\t</div>\n}\n\nvar raw = `a\nb`\n"
+	file, err := goxparser.Parse("a.gox", []byte(src))
+	if err != nil {
+		t.Fatal(err)
+	}
+	output, sm, err := Generate(file, &Options{LineDirectives: "a.gox"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Go tools see the generated code at its .gox lines
+	fset := token.NewFileSet()
+	f, err := parser.ParseFile(fset, "a_gox.go", output, parser.ParseComments)
+	if err != nil {
+		t.Fatalf("generated code does not parse: %v\n%s", err, output)
+	}
+	idx := strings.Index(string(output), "missing")
+	if idx < 0 {
+		t.Fatalf("missing not in output:\n%s", output)
+	}
+	if pos := fset.Position(fset.File(f.Pos()).Pos(idx)); pos.Filename != "a.gox" || pos.Line != 6 {
+		t.Errorf("missing is at %s, want a.gox:6\n%s", pos, output)
+	}
+
+	// No directive lands inside the raw string
+	if !strings.Contains(string(output), "var raw = `a\nb`") {
+		t.Errorf("raw string changed:\n%s", output)
+	}
+
+	// The source map accounts for the inserted lines
+	lines := strings.Split(string(output), "\n")
+	for i, line := range lines {
+		if !strings.Contains(line, "missing") {
+			continue
+		}
+		srcLine, ok := sm.FindSourceLine(uint32(i))
+		if !ok || srcLine != 5 {
+			t.Errorf("FindSourceLine(%d) = %d, %v; want 5", i, srcLine, ok)
+		}
+	}
+}
+
+func TestGenerateWithoutLineDirectives(t *testing.T) {
+	file, err := goxparser.Parse("a.gox", []byte("package ui\n\nfunc A() gox.VNode {\n\treturn <div><p /></div>\n}\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	output, _, err := Generate(file, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Contains(string(output), "//line") {
+		t.Errorf("unexpected //line directive:\n%s", output)
+	}
+}
@@ -0,0 +1,56 @@
+package generator
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"path/filepath"
+	"regexp"
+)
+
+// Provenance is what the header of a generated file records about how it
+// was generated.
+type Provenance struct {
+	Version string // The gox version that generated the file
+	Source  string // The base name of the .gox file
+	Sum     string // Hex SHA-256 of the code below the header
+}
+
+// provenanceHeader matches the first line of a generated file. It follows
+// the Go convention for generated code, so tools such as linters and
+// GitHub's diff view recognize it.
+var provenanceHeader = regexp.MustCompile(`^// Code generated by gox (\S+) from (.+) \(sha256:([0-9a-f]{64})\) DO NOT EDIT\.$`)
+
+// insertProvenance prepends the provenance header and a blank line to src,
+// moving the source map down past them.
+func insertProvenance(src []byte, sm *SourceMap, version, sourcePath string) ([]byte, *SourceMap) {
+	sum := sha256.Sum256(src)
+	header := fmt.Sprintf("// Code generated by gox %s from %s (sha256:%s) DO NOT EDIT.\n\n",
+		version, filepath.Base(sourcePath), hex.EncodeToString(sum[:]))
+	return append([]byte(header), src...), shiftTargetLines(sm, []uint32{0, 0})
+}
+
+// ReadProvenance parses the provenance header of a generated file. It
+// reports false if src does not start with one, as in files generated by
+// gox versions that did not write it.
+func ReadProvenance(src []byte) (Provenance, bool) {
+	line, _, _ := bytes.Cut(src, []byte("\n"))
+	m := provenanceHeader.FindSubmatch(bytes.TrimSuffix(line, []byte("\r")))
+	if m == nil {
+		return Provenance{}, false
+	}
+	return Provenance{Version: string(m[1]), Source: string(m[2]), Sum: string(m[3])}, true
+}
+
+// Edited reports whether the code below the header of src, a generated
+// file with provenance p, was changed since it was generated.
+func (p Provenance) Edited(src []byte) bool {
+	_, body, _ := bytes.Cut(src, []byte("\n"))
+	// Line endings converted on checkout are not edits
+	body = bytes.ReplaceAll(body, []byte("\r\n"), []byte("\n"))
+	// Skip the blank line after the header
+	body = bytes.TrimPrefix(body, []byte("\n"))
+	sum := sha256.Sum256(body)
+	return hex.EncodeToString(sum[:]) != p.Sum
+}
@@ -0,0 +1,62 @@
+package generator
+
+import (
+	"strings"
+	"testing"
+
+	goxparser "github.com/germtb/gox/parser"
+)
+
+func TestGenerateProvenance(t *testing.T) {
+	src := "package ui\n\nfunc A(n int) gox.VNode {\n\treturn <p>{n}</p>\n}\n"
+	file, err := goxparser.Parse("ui/a.gox", []byte(src))
+	if err != nil {
+		t.Fatal(err)
+	}
+	output, sm, err := Generate(file, &Options{Version: "v1.2.0"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !strings.HasPrefix(string(output), "// Code generated by gox v1.2.0 from a.gox (sha256:") {
+		t.Fatalf("missing header:\n%s", output)
+	}
+	p, ok := ReadProvenance(output)
+	if !ok {
+		t.Fatalf("ReadProvenance failed:\n%s", output)
+	}
+	if p.Version != "v1.2.0" || p.Source != "a.gox" {
+		t.Errorf("ReadProvenance = %+v", p)
+	}
+	if p.Edited(output) {
+		t.Error("freshly generated file reported as edited")
+	}
+	if p.Edited([]byte(strings.ReplaceAll(string(output), "\n", "\r\n"))) {
+		t.Error("CRLF line endings reported as an edit")
+	}
+	if !p.Edited([]byte(strings.Replace(string(output), "V(n)", "V(n + 1)", 1))) {
+		t.Error("edit not detected")
+	}
+
+	// The source map accounts for the header
+	lines := strings.Split(string(output), "\n")
+	for i, line := range lines {
+		if strings.Contains(line, "V(n)") {
+			if srcLine, ok := sm.FindSourceLine(uint32(i)); !ok || srcLine != 3 {
+				t.Errorf("FindSourceLine(%d) = %d, %v; want 3", i, srcLine, ok)
+			}
+		}
+	}
+}
+
+func TestReadProvenanceWithoutHeader(t *testing.T) {
+	for _, src := range []string{
+		"package ui\n",
+		"// Code generated by stringer. DO NOT EDIT.\n\npackage ui\n",
+		"// Code generated by gox v1 from a.gox (sha256:abc) DO NOT EDIT.\n\npackage ui\n",
+	} {
+		if p, ok := ReadProvenance([]byte(src)); ok {
+			t.Errorf("ReadProvenance(%q) = %+v, want none", src, p)
+		}
+	}
+}
@@ -0,0 +1,92 @@
+package generator
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/germtb/gox/ast"
+	"github.com/germtb/gox/parser"
+)
+
+// DefaultRuntimePackage is the import path of the gox runtime used when
+// nothing else is configured.
+const DefaultRuntimePackage = "github.com/germtb/gox"
+
+// RuntimeDirective sets the runtime package of a single .gox file. It must
+// appear in a line comment before the package clause:
+//
+//	//gox:runtime example.com/tui/gox
+//	package views
+const RuntimeDirective = parser.RuntimeDirective
+
+// RuntimeFileName is the file that sets the runtime package for the .gox
+// files in its directory and subdirectories, up to the module root. It holds
+// a single import path; blank lines and lines starting with # are ignored.
+const RuntimeFileName = ".goxruntime"
+
+// FileRuntime returns the runtime package set by the file's //gox:runtime
+// directive, or "" if it has none.
+func FileRuntime(file *ast.GoxFile) string {
+	return file.Directives.Runtime
+}
+
+// LookupRuntime returns the runtime package set by the nearest .goxruntime
+// file in dir or its parents, stopping at the directory containing go.mod.
+// It returns "" if no file applies.
+func LookupRuntime(dir string) (string, error) {
+	name, data, err := lookupConfigFile(dir, RuntimeFileName)
+	if err != nil || name == "" {
+		return "", err
+	}
+	return parseConfigLine(name, data, "import path")
+}
+
+// lookupConfigFile returns the path and content of the nearest file called
+// base in dir or its parents, stopping at the directory containing go.mod.
+// It returns "" if there is none.
+func lookupConfigFile(dir, base string) (string, []byte, error) {
+	dir, err := filepath.Abs(dir)
+	if err != nil {
+		return "", nil, err
+	}
+	for {
+		name := filepath.Join(dir, base)
+		data, err := os.ReadFile(name)
+		if err == nil {
+			return name, data, nil
+		}
+		if !os.IsNotExist(err) {
+			return "", nil, fmt.Errorf("reading %s: %w", base, err)
+		}
+		parent := filepath.Dir(dir)
+		if _, err := os.Stat(filepath.Join(dir, "go.mod")); err == nil || parent == dir {
+			return "", nil, nil
+		}
+		dir = parent
+	}
+}
+
+// parseConfigLine returns the single value, described by what, in a
+// configuration file such as .goxruntime.
+func parseConfigLine(name string, data []byte, what string) (string, error) {
+	var value string
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		if value != "" || strings.ContainsAny(line, " \t") {
+			return "", fmt.Errorf("%s: want a single %s", name, what)
+		}
+		value = line
+	}
+	if value == "" {
+		return "", fmt.Errorf("%s: no %s", name, what)
+	}
+	return value, nil
+}
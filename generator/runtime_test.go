@@ -0,0 +1,123 @@
+package generator
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/germtb/gox/parser"
+)
+
+func TestFileRuntime(t *testing.T) {
+	tests := []struct {
+		name string
+		src  string
+		want string
+	}{
+		{"none", "package ui\n", ""},
+		{"directive", "//gox:runtime example.com/tui/gox\npackage ui\n", "example.com/tui/gox"},
+		{"after comments", "// Package ui renders the TUI.\n//gox:runtime example.com/tui/gox\n\npackage ui\n", "example.com/tui/gox"},
+		{"after package clause", "package ui\n\n//gox:runtime example.com/tui/gox\n", ""},
+		{"other directive", "//gox:runtimes example.com/tui/gox\npackage ui\n", ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			file, err := parser.Parse("test.gox", []byte(tt.src))
+			if err != nil {
+				t.Fatalf("Parse error: %v", err)
+			}
+			if got := FileRuntime(file); got != tt.want {
+				t.Errorf("FileRuntime = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestGenerateRuntimeDirective(t *testing.T) {
+	src := `//gox:runtime example.com/tui/gox
+package main
+
+func App() {
+	return <box></box>
+}`
+
+	file, err := parser.Parse("test.gox", []byte(src))
+	if err != nil {
+		t.Fatalf("Parse error: %v", err)
+	}
+
+	// The directive takes precedence over the configured runtime
+	output, _, err := Generate(file, &Options{RuntimePackage: "myapp/ui"})
+	if err != nil {
+		t.Fatalf("Generate error: %v", err)
+	}
+	code := string(output)
+	if !strings.Contains(code, `import "example.com/tui/gox"`) || strings.Contains(code, "myapp/ui") {
+		t.Errorf("Expected directive import, got:\n%s", code)
+	}
+}
+
+func TestGeneratePreserveWhitespace(t *testing.T) {
+	src := `//gox:preserve-whitespace
+package main
+
+func App() gox.VNode {
+	return <pre>
+  Hello,   {name}!
+</pre>
+}`
+
+	file, err := parser.Parse("test.gox", []byte(src))
+	if err != nil {
+		t.Fatalf("Parse error: %v", err)
+	}
+	output, _, err := Generate(file, nil)
+	if err != nil {
+		t.Fatalf("Generate error: %v", err)
+	}
+	if want := `gox.Textf("\n  Hello,   %v!\n", name)`; !strings.Contains(string(output), want) {
+		t.Errorf("Expected %s, got:\n%s", want, output)
+	}
+}
+
+func TestLookupRuntime(t *testing.T) {
+	root := t.TempDir()
+	write := func(name, content string) {
+		t.Helper()
+		path := filepath.Join(root, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	write(".goxruntime", "example.com/outside\n")
+	write("mod/go.mod", "module example.com/app\n")
+	write("mod/web/page.gox", "package web\n")
+	write("mod/tui/.goxruntime", "# Terminal components\n\nexample.com/tui/gox\n")
+	write("mod/tui/widgets/list.gox", "package widgets\n")
+	write("mod/bad/.goxruntime", "example.com/a example.com/b\n")
+
+	tests := []struct {
+		dir     string
+		want    string
+		wantErr bool
+	}{
+		// The search stops at the module root
+		{"mod/web", "", false},
+		{"mod/tui", "example.com/tui/gox", false},
+		{"mod/tui/widgets", "example.com/tui/gox", false},
+		{"mod/bad", "", true},
+	}
+	for _, tt := range tests {
+		got, err := LookupRuntime(filepath.Join(root, filepath.FromSlash(tt.dir)))
+		if (err != nil) != tt.wantErr {
+			t.Errorf("LookupRuntime(%s) error = %v, wantErr %v", tt.dir, err, tt.wantErr)
+		}
+		if got != tt.want {
+			t.Errorf("LookupRuntime(%s) = %q, want %q", tt.dir, got, tt.want)
+		}
+	}
+}
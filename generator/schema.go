@@ -0,0 +1,27 @@
+package generator
+
+import (
+	"fmt"
+
+	"github.com/germtb/gox/schema"
+)
+
+// SchemaFileName is the file that declares the intrinsic elements, and
+// their attributes, of the .gox files in its directory and subdirectories,
+// up to the module root. It holds a schema in JSON; see package schema.
+const SchemaFileName = ".goxschema"
+
+// LookupSchema returns the schema of the nearest .goxschema file in dir or
+// its parents, stopping at the directory containing go.mod, or nil if no
+// file applies.
+func LookupSchema(dir string) (*schema.Schema, error) {
+	name, data, err := lookupConfigFile(dir, SchemaFileName)
+	if err != nil || name == "" {
+		return nil, err
+	}
+	s, err := schema.Parse(data)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", name, err)
+	}
+	return s, nil
+}
@@ -0,0 +1,66 @@
+package generator
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/germtb/gox/diag"
+	"github.com/germtb/gox/parser"
+	"github.com/germtb/gox/schema"
+)
+
+func TestLookupSchema(t *testing.T) {
+	root := t.TempDir()
+	write := func(name, content string) {
+		t.Helper()
+		path := filepath.Join(root, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	write("mod/go.mod", "module example.com/app\n")
+	write("mod/web/page.gox", "package web\n")
+	write("mod/tui/.goxschema", `{"strict": true, "elements": {"box": ["gap"]}}`)
+	write("mod/tui/views/app.gox", "package views\n")
+	write("mod/bad/.goxschema", `{"elements": ["box"]}`)
+
+	s, err := LookupSchema(filepath.Join(root, "mod", "web"))
+	if s != nil || err != nil {
+		t.Errorf("LookupSchema(mod/web) = %v, %v; want no schema", s, err)
+	}
+	s, err = LookupSchema(filepath.Join(root, "mod", "tui", "views"))
+	if err != nil || s == nil || !s.Strict || len(s.Elements["box"]) != 1 {
+		t.Errorf("LookupSchema(mod/tui/views) = %+v, %v", s, err)
+	}
+	if _, err := LookupSchema(filepath.Join(root, "mod", "bad")); err == nil {
+		t.Error("LookupSchema(mod/bad) should fail")
+	}
+}
+
+func TestGenerateStrictSchema(t *testing.T) {
+	src := `package main
+
+func App() gox.VNode {
+	return <box gap={1}><span /></box>
+}`
+	file, err := parser.Parse("app.gox", []byte(src))
+	if err != nil {
+		t.Fatalf("Parse error: %v", err)
+	}
+
+	s := &schema.Schema{Elements: map[string][]string{"box": {"gap"}}}
+	if _, _, err := Generate(file, &Options{Schema: s}); err != nil {
+		t.Errorf("Generate without strict mode: %v", err)
+	}
+
+	s.Strict = true
+	_, _, err = Generate(file, &Options{Schema: s})
+	d, ok := diag.As(err)
+	if !ok || d.Code != diag.UnknownElement || d.Line != 4 || d.Column != 23 {
+		t.Errorf("Expected an unknown element diagnostic at 4:23, got %v", err)
+	}
+}
@@ -1,8 +1,11 @@
 package generator
 
 import (
+	"bytes"
 	"encoding/json"
+	"sort"
 	"strings"
+	"unicode"
 	"unicode/utf8"
 )
 
@@ -38,6 +41,11 @@ type SourceMap struct {
 
 	// TargetToSource maps .go positions to .gox positions
 	TargetToSource map[uint32]map[uint32]Position `json:"targetToSource"`
+
+	// WideLines holds the .go lines with multi-byte characters, by line, for
+	// converting between the rune columns of the map and the byte columns
+	// Go tools report
+	WideLines map[uint32]string `json:"wideLines,omitempty"`
 }
 
 // NewSourceMap creates a new SourceMap.
@@ -56,11 +64,7 @@ func (sm *SourceMap) SetFiles(source, target string) {
 
 // AddMapping adds a character-level mapping between source and target positions.
 func (sm *SourceMap) AddMapping(srcLine, srcCol uint32, tgtLine, tgtCol uint32) {
-	// Source to target
-	if _, ok := sm.SourceToTarget[srcLine]; !ok {
-		sm.SourceToTarget[srcLine] = make(map[uint32]Position)
-	}
-	sm.SourceToTarget[srcLine][srcCol] = NewPosition(0, tgtLine, tgtCol)
+	sm.addSourceMapping(srcLine, srcCol, tgtLine, tgtCol)
 
 	// Target to source
 	if _, ok := sm.TargetToSource[tgtLine]; !ok {
@@ -88,16 +92,10 @@ func (sm *SourceMap) AddExpression(value string, srcStart, tgtStart Position) {
 		}
 
 		// Map each character in the line
-		for _, r := range line {
+		for range line {
 			sm.AddMapping(srcLine, srcCol, tgtLine, tgtCol)
-
-			// Move forward by rune width
-			rlen := utf8.RuneLen(r)
-			if rlen < 0 {
-				rlen = 1
-			}
-			srcCol += uint32(rlen)
-			tgtCol += uint32(rlen)
+			srcCol++
+			tgtCol++
 		}
 
 		// Include newline character position
@@ -105,6 +103,14 @@ func (sm *SourceMap) AddExpression(value string, srcStart, tgtStart Position) {
 	}
 }
 
+// addSourceMapping records only the source-to-target direction of a mapping.
+func (sm *SourceMap) addSourceMapping(srcLine, srcCol uint32, tgtLine, tgtCol uint32) {
+	if _, ok := sm.SourceToTarget[srcLine]; !ok {
+		sm.SourceToTarget[srcLine] = make(map[uint32]Position)
+	}
+	sm.SourceToTarget[srcLine][srcCol] = NewPosition(0, tgtLine, tgtCol)
+}
+
 // TargetPositionFromSource looks up the target (.go) position from a source (.gox) position.
 // Returns the exact mapping if found, otherwise returns false.
 func (sm *SourceMap) TargetPositionFromSource(line, col uint32) (Position, bool) {
@@ -177,6 +183,51 @@ func (sm *SourceMap) SourcePositionFromTarget(line, col uint32) (Position, bool)
 	return Position{}, false
 }
 
+// TargetRuneColumn returns the rune column of the 0-indexed byte column col
+// on a target line, such as a column reported by the Go compiler, for
+// looking it up in the map.
+func (sm *SourceMap) TargetRuneColumn(line, col uint32) uint32 {
+	text, ok := sm.WideLines[line]
+	if !ok {
+		return col
+	}
+	if int(col) > len(text) {
+		return uint32(utf8.RuneCountInString(text)) + col - uint32(len(text))
+	}
+	return uint32(utf8.RuneCountInString(text[:col]))
+}
+
+// TargetByteColumn returns the byte column of the 0-indexed rune column col
+// on a target line, the inverse of TargetRuneColumn.
+func (sm *SourceMap) TargetByteColumn(line, col uint32) uint32 {
+	text, ok := sm.WideLines[line]
+	if !ok {
+		return col
+	}
+	for i := range text {
+		if col == 0 {
+			return uint32(i)
+		}
+		col--
+	}
+	return uint32(len(text)) + col
+}
+
+// setWideLines records the lines of target, the generated code sm maps
+// to, that hold multi-byte characters.
+func (sm *SourceMap) setWideLines(target []byte) {
+	sm.WideLines = nil
+	for i, line := range strings.Split(string(target), "\n") {
+		if strings.IndexFunc(line, func(r rune) bool { return r >= utf8.RuneSelf }) < 0 {
+			continue
+		}
+		if sm.WideLines == nil {
+			sm.WideLines = make(map[uint32]string)
+		}
+		sm.WideLines[uint32(i)] = line
+	}
+}
+
 // ToJSON serializes the source map to JSON.
 func (sm *SourceMap) ToJSON() ([]byte, error) {
 	return json.MarshalIndent(sm, "", "  ")
@@ -196,32 +247,222 @@ func (sm *SourceMap) HasMappings() bool {
 	return len(sm.SourceToTarget) > 0 || len(sm.TargetToSource) > 0
 }
 
-// FindTargetLine finds the target line for a given source line.
-// Returns the first target line found for any column on the source line.
+// FindTargetLine finds the target line for a given source line: the line
+// the first mapped column of the source line maps to.
 func (sm *SourceMap) FindTargetLine(srcLine uint32) (uint32, bool) {
-	lineMap, ok := sm.SourceToTarget[srcLine]
-	if !ok || len(lineMap) == 0 {
-		return 0, false
+	pos, ok := firstMapping(sm.SourceToTarget[srcLine])
+	return pos.Line, ok
+}
+
+// FindSourceLine finds the source line for a given target line: the line
+// the first mapped column of the target line maps to.
+func (sm *SourceMap) FindSourceLine(tgtLine uint32) (uint32, bool) {
+	pos, ok := firstMapping(sm.TargetToSource[tgtLine])
+	return pos.Line, ok
+}
+
+// firstMapping returns the position the lowest column of a line maps to,
+// so that line lookups do not depend on map iteration order.
+func firstMapping(lineMap map[uint32]Position) (Position, bool) {
+	var first Position
+	var firstCol uint32
+	found := false
+	for col, pos := range lineMap {
+		if !found || col < firstCol {
+			first, firstCol, found = pos, col, true
+		}
+	}
+	return first, found
+}
+
+// realignSourceMap moves the target positions of sm, recorded against the
+// generator's raw output, to the corresponding positions in the final output.
+// The final output differs from the raw output only by inserted lines (the
+// runtime import), a single import moved into the block that holds it, and
+// gofmt whitespace changes, so lines are matched by their non-whitespace
+// content and columns by walking non-whitespace characters.
+func realignSourceMap(sm *SourceMap, raw, final []byte) *SourceMap {
+	if bytes.Equal(raw, final) {
+		return sm
+	}
+
+	rawLines := strings.Split(string(raw), "\n")
+	finalLines := strings.Split(string(final), "\n")
+	lineMap := alignLines(rawLines, finalLines)
+
+	out := NewSourceMap()
+	out.SetFiles(sm.SourceFile, sm.TargetFile)
+	// Several raw columns can land on one final column, and the last one
+	// mapped wins, so go in order: the same input always gets the same map
+	for _, tgtLine := range sortedKeys(sm.TargetToSource) {
+		cols := sm.TargetToSource[tgtLine]
+		if int(tgtLine) >= len(rawLines) {
+			continue
+		}
+		newLine, ok := lineMap[int(tgtLine)]
+		if !ok {
+			continue
+		}
+		rawLine, finalLine := movedImport(rawLines[tgtLine], finalLines[newLine]), []rune(finalLines[newLine])
+		colMap := alignColumns(rawLine, finalLine)
+		newCol := func(tgtCol uint32) uint32 {
+			if int(tgtCol) < len(colMap) {
+				return uint32(colMap[tgtCol])
+			}
+			return uint32(len(finalLine))
+		}
+
+		// Whitespace columns share the final column of the next character, so
+		// map characters first and let whitespace fill only unclaimed columns
+		var spaces []uint32
+		for _, tgtCol := range sortedKeys(cols) {
+			src := cols[tgtCol]
+			if int(tgtCol) < len(rawLine) && isSpace(rawLine[tgtCol]) {
+				spaces = append(spaces, tgtCol)
+				continue
+			}
+			out.AddMapping(src.Line, src.Column, uint32(newLine), newCol(tgtCol))
+		}
+		for _, tgtCol := range spaces {
+			src := cols[tgtCol]
+			if _, taken := out.TargetToSource[uint32(newLine)][newCol(tgtCol)]; taken {
+				out.addSourceMapping(src.Line, src.Column, uint32(newLine), newCol(tgtCol))
+				continue
+			}
+			out.AddMapping(src.Line, src.Column, uint32(newLine), newCol(tgtCol))
+		}
 	}
+	return out
+}
 
-	// Return the target line from any mapping on this line
-	for _, pos := range lineMap {
-		return pos.Line, true
+// sortedKeys returns the keys of m in increasing order.
+func sortedKeys[V any](m map[uint32]V) []uint32 {
+	keys := make([]uint32, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
 	}
-	return 0, false
+	sort.Slice(keys, func(i, j int) bool { return keys[i] < keys[j] })
+	return keys
 }
 
-// FindSourceLine finds the source line for a given target line.
-// Returns the first source line found for any column on the target line.
-func (sm *SourceMap) FindSourceLine(tgtLine uint32) (uint32, bool) {
-	lineMap, ok := sm.TargetToSource[tgtLine]
-	if !ok || len(lineMap) == 0 {
-		return 0, false
+// movedImport returns the runes of raw, blanking its import keyword if raw is
+// a single import declaration that became the spec final in an import block,
+// so the columns of the spec line up and the keyword maps to its start.
+func movedImport(raw, final string) []rune {
+	runes := []rune(raw)
+	trimmed := strings.TrimLeftFunc(raw, unicode.IsSpace)
+	if !strings.HasPrefix(trimmed, "import") || stripSpace(trimmed[len("import"):]) != stripSpace(final) {
+		return runes
+	}
+	start := len([]rune(raw)) - len([]rune(trimmed))
+	for i := start; i < start+len("import"); i++ {
+		runes[i] = ' '
 	}
+	return runes
+}
+
+// alignLines matches each non-blank raw line to the final line with the same
+// non-whitespace content, searching near the expected position so inserted
+// lines and small reorderings (gofmt's import sorting) are tolerated.
+func alignLines(raw, final []string) map[int]int {
+	const window = 16
 
-	// Return the source line from any mapping on this line
-	for _, pos := range lineMap {
-		return pos.Line, true
+	finalNorm := make([]string, len(final))
+	for i, line := range final {
+		finalNorm[i] = stripSpace(line)
 	}
-	return 0, false
+	used := make([]bool, len(final))
+
+	lineMap := make(map[int]int, len(raw))
+	next := 0 // Expected final line for the next raw line
+	for i, line := range raw {
+		norm := stripSpace(line)
+		spec, single := strings.CutPrefix(norm, "import")
+		if norm == "" {
+			// Blank lines may be dropped by gofmt; map them relative to the cursor
+			if next < len(final) {
+				lineMap[i] = next
+			}
+			continue
+		}
+
+		found := -1
+		for d := 0; d <= window && found < 0; d++ {
+			for _, k := range []int{next + d, next - d} {
+				if k >= 0 && k < len(final) && !used[k] && finalNorm[k] == norm {
+					found = k
+					break
+				}
+			}
+		}
+		// A single import moved into the runtime import's block
+		for d := 0; single && d <= window && found < 0; d++ {
+			for _, k := range []int{next + d, next - d} {
+				if k >= 0 && k < len(final) && !used[k] && finalNorm[k] == spec {
+					found = k
+					break
+				}
+			}
+		}
+		if found < 0 {
+			// Content changed beyond whitespace; assume it stayed in place
+			if next < len(final) {
+				lineMap[i] = next
+				next++
+			}
+			continue
+		}
+
+		used[found] = true
+		lineMap[i] = found
+		next = found + 1
+	}
+	return lineMap
+}
+
+// alignColumns returns, for each rune column of raw (plus one past the end),
+// the corresponding rune column of final. The k-th non-whitespace rune of raw
+// maps to the k-th non-whitespace rune of final; whitespace columns map to the
+// next non-whitespace character.
+func alignColumns(raw, final []rune) []int {
+	cols := make([]int, len(raw)+1)
+	cols[len(raw)] = len(final)
+
+	j := 0
+	for i := 0; i < len(raw); i++ {
+		if isSpace(raw[i]) {
+			cols[i] = -1
+			continue
+		}
+		for j < len(final) && isSpace(final[j]) {
+			j++
+		}
+		cols[i] = j
+		if j < len(final) {
+			j++
+		}
+	}
+
+	// Whitespace takes the column of the next non-whitespace character
+	for i := len(raw) - 1; i >= 0; i-- {
+		if cols[i] < 0 {
+			cols[i] = cols[i+1]
+		}
+	}
+	return cols
+}
+
+// stripSpace removes all whitespace from s.
+func stripSpace(s string) string {
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		if !isSpace(rune(s[i])) {
+			b.WriteByte(s[i])
+		}
+	}
+	return b.String()
+}
+
+func isSpace(c rune) bool {
+	return c == ' ' || c == '\t' || c == '\r' || c == '\n'
 }
@@ -1,7 +1,10 @@
 package generator
 
 import (
+	"strings"
 	"testing"
+
+	"github.com/germtb/gox/parser"
 )
 
 func TestSourceMapBasicMapping(t *testing.T) {
@@ -137,6 +140,45 @@ func TestSourceMapJSON(t *testing.T) {
 	}
 }
 
+func TestSourceMapTargetColumns(t *testing.T) {
+	sm := NewSourceMap()
+	sm.setWideLines([]byte("package main\n\tgox.Text(\"héllo 😀\", x)\n"))
+	if len(sm.WideLines) != 1 {
+		t.Fatalf("Expected one wide line, got %v", sm.WideLines)
+	}
+
+	tests := []struct {
+		line, byteCol, runeCol uint32
+	}{
+		{0, 5, 5},   // ASCII line
+		{1, 11, 11}, // h
+		{1, 14, 13}, // l, after é
+		{1, 18, 17}, // 😀
+		{1, 22, 18}, // closing quote
+		{1, 30, 26}, // past the end
+	}
+	for _, tt := range tests {
+		if got := sm.TargetRuneColumn(tt.line, tt.byteCol); got != tt.runeCol {
+			t.Errorf("TargetRuneColumn(%d, %d) = %d, want %d", tt.line, tt.byteCol, got, tt.runeCol)
+		}
+		if got := sm.TargetByteColumn(tt.line, tt.runeCol); got != tt.byteCol {
+			t.Errorf("TargetByteColumn(%d, %d) = %d, want %d", tt.line, tt.runeCol, got, tt.byteCol)
+		}
+	}
+
+	data, err := sm.ToJSON()
+	if err != nil {
+		t.Fatalf("ToJSON error: %v", err)
+	}
+	decoded, err := FromJSON(data)
+	if err != nil {
+		t.Fatalf("FromJSON error: %v", err)
+	}
+	if got := decoded.TargetRuneColumn(1, 18); got != 17 {
+		t.Errorf("TargetRuneColumn after JSON = %d, want 17", got)
+	}
+}
+
 func TestSourceMapHasMappings(t *testing.T) {
 	sm := NewSourceMap()
 
@@ -150,3 +192,74 @@ func TestSourceMapHasMappings(t *testing.T) {
 		t.Error("Source map with mapping should have mappings")
 	}
 }
+
+func TestRealignSourceMapPrefersCharacters(t *testing.T) {
+	// gofmt re-aligns the field types, so the raw whitespace columns collapse
+	// onto the first character of the type
+	raw := []byte("type P struct {\n\tKey string\n\tSubtitle string\n}\n")
+	final := []byte("type P struct {\n\tKey      string\n\tSubtitle string\n}\n")
+
+	sm := NewSourceMap()
+	sm.AddExpression(string(raw), NewPosition(0, 0, 0), NewPosition(0, 0, 0))
+	out := realignSourceMap(sm, raw, final)
+
+	for _, tt := range []struct {
+		line, col, wantCol uint32
+	}{
+		{1, 1, 1},   // K of Key
+		{1, 10, 5},  // s of string after re-alignment
+		{2, 1, 1},   // S of Subtitle, not the tab before it
+		{2, 10, 10}, // s of string
+	} {
+		pos, ok := out.SourcePositionFromTarget(tt.line, tt.col)
+		if !ok || pos.Line != tt.line || pos.Column != tt.wantCol {
+			t.Errorf("target %d:%d maps to %d:%d (ok=%v), want %d:%d", tt.line, tt.col, pos.Line, pos.Column, ok, tt.line, tt.wantCol)
+		}
+	}
+}
+
+func TestRealignSourceMapDeterministic(t *testing.T) {
+	// gofmt drops the spaces of "a ,  b", so several raw columns land on
+	// one final column
+	raw := []byte("func f() { g(a ,  b);   return }\n")
+	final := []byte("func f() { g(a, b); return }\n")
+
+	sm := NewSourceMap()
+	sm.AddExpression(string(raw), NewPosition(0, 0, 0), NewPosition(0, 0, 0))
+	want, err := realignSourceMap(sm, raw, final).ToJSON()
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 20; i++ {
+		got, _ := realignSourceMap(sm, raw, final).ToJSON()
+		if string(got) != string(want) {
+			t.Fatalf("realignSourceMap gave different maps for the same input:\n%s\n%s", want, got)
+		}
+	}
+}
+
+func TestSourceMapMovedSingleImport(t *testing.T) {
+	// The runtime import turns the single import into a block, moving "os"
+	src := "package ui\n\nimport \"os\"\n\nfunc A() gox.VNode { return <a /> }\n"
+	file, err := parser.Parse("app.gox", []byte(src))
+	if err != nil {
+		t.Fatal(err)
+	}
+	out, sm, err := Generate(file, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	lines := strings.Split(string(out), "\n")
+	for i, line := range lines {
+		col := strings.Index(line, `"os"`)
+		if col < 0 {
+			continue
+		}
+		pos, ok := sm.SourcePositionFromTarget(uint32(i), uint32(col))
+		if !ok || pos.Line != 2 || pos.Column != 7 {
+			t.Errorf(`"os" at %d:%d maps to %d:%d (ok=%v), want 2:7`, i, col, pos.Line, pos.Column, ok)
+		}
+		return
+	}
+	t.Fatalf("no \"os\" import in:\n%s", out)
+}
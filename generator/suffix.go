@@ -0,0 +1,119 @@
+package generator
+
+import (
+	"fmt"
+	"path/filepath"
+	"strings"
+)
+
+// DefaultSuffix replaces the .gox extension in the names of generated files
+// when nothing else is configured: app.gox is generated to app_gox.go.
+const DefaultSuffix = "_gox.go"
+
+// SuffixFileName is the file that sets the suffix of the files generated
+// from the .gox files in its directory and subdirectories, up to the module
+// root, such as .gox.go for app.gox.go. It holds a single suffix; blank
+// lines and lines starting with # are ignored.
+const SuffixFileName = ".goxsuffix"
+
+// OutputDirFileName is the file that moves the files generated from the
+// .gox files in its directory and subdirectories, up to the module root,
+// into a separate tree: it holds a directory relative to its own, such as
+// gen, under which the layout of the .gox files is mirrored, so ui/app.gox
+// is generated to gen/ui/app_gox.go. Blank lines and lines starting with #
+// are ignored.
+const OutputDirFileName = ".goxoutdir"
+
+// OutputName returns the name of the file generated from a .gox file named
+// name, with the given suffix. Test files stay test files: with the default
+// suffix, app_test.gox is generated to app_gox_test.go.
+func OutputName(name, suffix string) string {
+	base := strings.TrimSuffix(name, ".gox")
+	stem := strings.TrimSuffix(suffix, ".go")
+	if test, ok := strings.CutSuffix(base, "_test"); ok {
+		return test + stem + "_test.go"
+	}
+	return base + stem + ".go"
+}
+
+// IsGeneratedName reports whether name is the name of a file generated with
+// the given suffix.
+func IsGeneratedName(name, suffix string) bool {
+	stem := strings.TrimSuffix(suffix, ".go")
+	return strings.HasSuffix(name, suffix) || strings.HasSuffix(name, stem+"_test.go")
+}
+
+// LookupSuffix returns the suffix set by the nearest .goxsuffix file in dir
+// or its parents, stopping at the directory containing go.mod, or
+// DefaultSuffix if no file applies.
+func LookupSuffix(dir string) (string, error) {
+	name, data, err := lookupConfigFile(dir, SuffixFileName)
+	if err != nil || name == "" {
+		return DefaultSuffix, err
+	}
+	suffix, err := parseConfigLine(name, data, "suffix")
+	if err != nil {
+		return DefaultSuffix, err
+	}
+	if err := ValidateSuffix(suffix); err != nil {
+		return DefaultSuffix, fmt.Errorf("%s: %w", name, err)
+	}
+	return suffix, nil
+}
+
+// ValidateSuffix reports whether suffix can name generated files: it must
+// end in .go, so go builds them, but not in _test.go, which would make
+// every generated file a test file, and must not name a directory.
+func ValidateSuffix(suffix string) error {
+	switch {
+	case !strings.HasSuffix(suffix, ".go") || suffix == ".go":
+		return fmt.Errorf("suffix %q: want text ending in .go, such as %s", suffix, DefaultSuffix)
+	case strings.HasSuffix(suffix, "_test.go"):
+		return fmt.Errorf("suffix %q: must not end in _test.go", suffix)
+	case strings.ContainsAny(suffix, `/\`) || suffix != filepath.Base(suffix):
+		return fmt.Errorf("suffix %q: must not contain a directory", suffix)
+	}
+	return nil
+}
+
+// LookupOutputDir returns the directory of the files generated from the
+// .gox files in dir: dir mirrored under the tree set by the nearest
+// .goxoutdir file in dir or its parents, stopping at the directory
+// containing go.mod, or dir itself if no file applies.
+func LookupOutputDir(dir string) (string, error) {
+	name, data, err := lookupConfigFile(dir, OutputDirFileName)
+	if err != nil || name == "" {
+		return dir, err
+	}
+	tree, err := parseConfigLine(name, data, "directory")
+	if err != nil {
+		return dir, err
+	}
+	if !filepath.IsLocal(tree) {
+		return dir, fmt.Errorf("%s: directory %q: want a relative path inside %s", name, tree, filepath.Dir(name))
+	}
+	abs, err := filepath.Abs(dir)
+	if err != nil {
+		return dir, err
+	}
+	root := filepath.Dir(name)
+	rel, err := filepath.Rel(root, abs)
+	if err != nil {
+		return dir, err
+	}
+	return filepath.Join(root, tree, rel), nil
+}
+
+// OutputPath returns the path of the file generated from the .gox file at
+// path, named with the suffix set for its directory and placed in the
+// output tree set for it. On an invalid configuration file it returns the
+// default path along with the error.
+func OutputPath(path string) (string, error) {
+	dir := filepath.Dir(path)
+	suffix, err := LookupSuffix(dir)
+	outDir, dirErr := LookupOutputDir(dir)
+	if err == nil {
+		err = dirErr
+	}
+	return filepath.Join(outDir, OutputName(filepath.Base(path), suffix)), err
+}
@@ -0,0 +1,123 @@
+package generator
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestOutputName(t *testing.T) {
+	tests := []struct {
+		name, suffix, want string
+	}{
+		{"app.gox", DefaultSuffix, "app_gox.go"},
+		{"app_test.gox", DefaultSuffix, "app_gox_test.go"},
+		{"app.gox", ".gen.go", "app.gen.go"},
+		{"app_test.gox", ".gen.go", "app.gen_test.go"},
+		{"view_linux.gox", "_view.go", "view_linux_view.go"},
+	}
+	for _, tt := range tests {
+		got := OutputName(tt.name, tt.suffix)
+		if got != tt.want {
+			t.Errorf("OutputName(%q, %q) = %q, want %q", tt.name, tt.suffix, got, tt.want)
+		}
+		if !IsGeneratedName(got, tt.suffix) {
+			t.Errorf("IsGeneratedName(%q, %q) = false", got, tt.suffix)
+		}
+	}
+	if IsGeneratedName("app.go", ".gen.go") || IsGeneratedName("app_test.go", ".gen.go") {
+		t.Error("IsGeneratedName reports hand-written files as generated")
+	}
+}
+
+func TestValidateSuffix(t *testing.T) {
+	for _, suffix := range []string{DefaultSuffix, ".gen.go", "gen.go"} {
+		if err := ValidateSuffix(suffix); err != nil {
+			t.Errorf("ValidateSuffix(%q) = %v", suffix, err)
+		}
+	}
+	for _, suffix := range []string{"", ".go", "_gox", "_gox_test.go", "gen/x.go", `gen\x.go`} {
+		if err := ValidateSuffix(suffix); err == nil {
+			t.Errorf("ValidateSuffix(%q) = nil, want an error", suffix)
+		}
+	}
+}
+
+func TestLookupSuffix(t *testing.T) {
+	root := t.TempDir()
+	write := func(name, content string) {
+		t.Helper()
+		path := filepath.Join(root, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	write(".goxsuffix", ".outside.go\n")
+	write("mod/go.mod", "module example.com/app\n")
+	write("mod/web/page.gox", "package web\n")
+	write("mod/ui/.goxsuffix", "# Generated views\n.gen.go\n")
+	write("mod/ui/widgets/list.gox", "package widgets\n")
+	write("mod/bad/.goxsuffix", "_gox_test.go\n")
+
+	tests := []struct {
+		dir     string
+		want    string
+		wantErr bool
+	}{
+		// The search stops at the module root
+		{"mod/web", DefaultSuffix, false},
+		{"mod/ui", ".gen.go", false},
+		{"mod/ui/widgets", ".gen.go", false},
+		{"mod/bad", DefaultSuffix, true},
+	}
+	for _, tt := range tests {
+		got, err := LookupSuffix(filepath.Join(root, filepath.FromSlash(tt.dir)))
+		if (err != nil) != tt.wantErr {
+			t.Errorf("LookupSuffix(%s) error = %v, wantErr %v", tt.dir, err, tt.wantErr)
+		}
+		if got != tt.want {
+			t.Errorf("LookupSuffix(%s) = %q, want %q", tt.dir, got, tt.want)
+		}
+	}
+}
+
+func TestOutputPath(t *testing.T) {
+	root := t.TempDir()
+	write := func(name, content string) {
+		t.Helper()
+		path := filepath.Join(root, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	write("go.mod", "module example.com/app\n")
+	write("web/.goxoutdir", "# Generated code\ngen\n")
+	write("web/ui/.goxsuffix", ".gen.go\n")
+	write("bad/.goxoutdir", "../outside\n")
+
+	tests := []struct {
+		file    string
+		want    string
+		wantErr bool
+	}{
+		{"app.gox", "app_gox.go", false},
+		{"web/page.gox", "web/gen/page_gox.go", false},
+		{"web/ui/list_test.gox", "web/gen/ui/list.gen_test.go", false},
+		{"bad/page.gox", "bad/page_gox.go", true},
+	}
+	for _, tt := range tests {
+		got, err := OutputPath(filepath.Join(root, filepath.FromSlash(tt.file)))
+		if (err != nil) != tt.wantErr {
+			t.Errorf("OutputPath(%s) error = %v, wantErr %v", tt.file, err, tt.wantErr)
+		}
+		if want := filepath.Join(root, filepath.FromSlash(tt.want)); got != want {
+			t.Errorf("OutputPath(%s) = %q, want %q", tt.file, got, want)
+		}
+	}
+}
@@ -59,6 +59,58 @@ func main() {
 }
 
 // TestTypedPropsTypeError verifies that wrong prop types cause compile errors
+// TestGenericComponentCompiles verifies that type arguments on a component tag
+// instantiate its props type and the call compiles
+func TestGenericComponentCompiles(t *testing.T) {
+	src := `package main
+
+import "github.com/germtb/gox"
+
+type ListProps[T any] struct {
+	Items  []T
+	Render func(T) gox.VNode
+}
+
+func List[T any](props ListProps[T], children ...gox.VNode) gox.VNode {
+	return <ul>{gox.Map(props.Items, props.Render)}</ul>
+}
+
+func label(n int) gox.VNode {
+	return <li>{n}</li>
+}
+
+func main() {
+	_ = <List[int] items={[]int{1, 2}} render={label} />
+	_ = <List[int] items={nil} render={label}><b /></List[int]>
+}
+`
+
+	file, err := parser.Parse("test.gox", []byte(src))
+	if err != nil {
+		t.Fatalf("Parse error: %v", err)
+	}
+
+	output, _, err := generator.Generate(file, nil)
+	if err != nil {
+		t.Fatalf("Generate error: %v", err)
+	}
+	if !strings.Contains(string(output), "List(ListProps[int]{") {
+		t.Errorf("Expected props type to be instantiated, got:\n%s", output)
+	}
+
+	tmpDir := t.TempDir()
+	goFile := filepath.Join(tmpDir, "main.go")
+	if err := os.WriteFile(goFile, output, 0644); err != nil {
+		t.Fatalf("Write error: %v", err)
+	}
+
+	cmd := exec.Command("go", "build", "-o", filepath.Join(tmpDir, "test"), goFile)
+	out, err := cmd.CombinedOutput()
+	if err != nil {
+		t.Errorf("Expected code to compile, but got error:\n%s\nGenerated code:\n%s", out, output)
+	}
+}
+
 func TestTypedPropsTypeError(t *testing.T) {
 	// Create code with intentionally wrong prop type (string instead of bool)
 	src := `package main
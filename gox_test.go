@@ -1,7 +1,10 @@
 package gox
 
 import (
+	"context"
+	"strings"
 	"testing"
+	"time"
 )
 
 func TestElement(t *testing.T) {
@@ -27,6 +30,98 @@ func TestElementWithChildren(t *testing.T) {
 	}
 }
 
+func TestElementChildrenProp(t *testing.T) {
+	props := Props{"class": "list", "children": []VNode{Text("a"), Text("b")}}
+
+	node := Element("ul", props)
+	if len(node.Children) != 2 {
+		t.Errorf("Children count = %d, want 2", len(node.Children))
+	}
+	if _, ok := node.Props["children"]; ok {
+		t.Error("children prop should be removed from the element's props")
+	}
+	if _, ok := props["children"]; !ok {
+		t.Error("Element should not modify the props passed in")
+	}
+
+	// Nested children take precedence over the prop
+	node = Element("ul", props, Text("c"))
+	if content, _ := node.Children[0].GetTextContent(); len(node.Children) != 1 || content != "c" {
+		t.Errorf("Children = %v, want the nested child only", node.Children)
+	}
+
+	node = Element("p", Props{"children": "hello"})
+	if content, _ := node.Children[0].GetTextContent(); content != "hello" {
+		t.Errorf("Children[0] = %q, want \"hello\"", content)
+	}
+	if node = Element("p", Props{"children": nil}); len(node.Children) != 0 {
+		t.Errorf("Children count = %d, want 0", len(node.Children))
+	}
+
+	// A component receives its children in its props
+	card := Component(func(props Props) VNode {
+		return Element("section", nil, Children(props["children"])...)
+	})
+	node = Element(card, Props{"children": Text("body")})
+	if len(node.Children) != 0 || node.Props["children"] == nil {
+		t.Errorf("component node = %+v, want the children prop kept", node)
+	}
+	if expanded, _ := Expand(context.Background(), node); len(expanded.Children) != 1 {
+		t.Errorf("expanded component = %+v, want the children of the prop", expanded)
+	}
+}
+
+func TestElementOmittedProps(t *testing.T) {
+	var title *string
+	props := Props{"class": "a", "disabled": false, "title": Optional(title), "value": nil, "id": Omit}
+
+	node := Element("input", props)
+	if len(node.Props) != 1 || node.Props["class"] != "a" {
+		t.Errorf("Props = %v, want only class", node.Props)
+	}
+	if len(props) != 5 {
+		t.Error("Element should not modify the props passed in")
+	}
+
+	// Components get false and nil props, but not omitted ones
+	node = Element(Component(func(Props) VNode { return Empty() }), props)
+	if _, ok := node.Props["disabled"]; !ok || len(node.Props) != 3 {
+		t.Errorf("Props = %v, want class, disabled and value", node.Props)
+	}
+}
+
+func TestMergeProps(t *testing.T) {
+	a := Props{"id": "a", "class": "x"}
+	merged := MergeProps(a, nil, Props{"id": "b"})
+
+	if merged["id"] != "b" || merged["class"] != "x" || len(merged) != 2 {
+		t.Errorf("MergeProps = %v, want the later id and class x", merged)
+	}
+	if a["id"] != "a" {
+		t.Error("MergeProps should not modify the props passed in")
+	}
+}
+
+func TestOptional(t *testing.T) {
+	var p *int
+	n := 1
+	for _, tt := range []struct {
+		value any
+		want  any
+	}{
+		{nil, Omit},
+		{p, Omit},
+		{[]string(nil), Omit},
+		{&n, &n},
+		{"", ""},
+		{false, false},
+	} {
+		if got := Optional(tt.value); got != tt.want {
+			t.Errorf("Optional(%#v) = %v, want %v", tt.value, got, tt.want)
+		}
+	}
+}
+
 func TestText(t *testing.T) {
 	node := Text("Hello, World!")
 
@@ -43,6 +138,48 @@ func TestText(t *testing.T) {
 	}
 }
 
+func TestTextf(t *testing.T) {
+	tests := []struct {
+		name   string
+		format string
+		args   []any
+		want   string
+	}{
+		{"values", "%s has %d items", []any{"cart", 3}, "cart has 3 items"},
+		{"text node argument", "Hello, %v!", []any{Text("Ada")}, "Hello, Ada!"},
+		{"nil and empty", "[%v][%v]", []any{nil, Empty()}, "[][]"},
+		{"escaped percent", "%v%%", []any{50}, "50%"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			node := Textf(tt.format, tt.args...)
+			content, ok := node.GetTextContent()
+			if !ok || content != tt.want {
+				t.Errorf("Textf(%q) = %q, want %q", tt.format, content, tt.want)
+			}
+		})
+	}
+}
+
+func TestTextfStructuralArgument(t *testing.T) {
+	link := Element("a", nil, Text("docs"))
+	node := Textf("See %v for %.1f%% more", link, 12.5)
+
+	if !node.IsFragment() || len(node.Children) != 3 {
+		t.Fatalf("Textf with element = %+v, want fragment with 3 children", node)
+	}
+	if content, _ := node.Children[0].GetTextContent(); content != "See " {
+		t.Errorf("first child = %q, want 'See '", content)
+	}
+	if node.Children[1].Type != "a" {
+		t.Errorf("second child type = %v, want 'a'", node.Children[1].Type)
+	}
+	if content, _ := node.Children[2].GetTextContent(); content != " for 12.5% more" {
+		t.Errorf("third child = %q, want ' for 12.5%% more'", content)
+	}
+}
+
 func TestFragment(t *testing.T) {
 	child1 := Text("A")
 	child2 := Text("B")
@@ -56,6 +193,20 @@ func TestFragment(t *testing.T) {
 	}
 }
 
+func TestKeyedFragment(t *testing.T) {
+	frag := KeyedFragment(7, Text("A"), Text("B"))
+
+	if !frag.IsFragment() {
+		t.Error("KeyedFragment should return true for IsFragment()")
+	}
+	if frag.Props["key"] != 7 {
+		t.Errorf("KeyedFragment key = %v, want 7", frag.Props["key"])
+	}
+	if len(frag.Children) != 2 {
+		t.Errorf("KeyedFragment children count = %d, want 2", len(frag.Children))
+	}
+}
+
 func TestWhen(t *testing.T) {
 	child := Text("Visible")
 
@@ -119,6 +270,61 @@ func TestMapIndex(t *testing.T) {
 	}
 }
 
+func TestCompact(t *testing.T) {
+	nodes := Compact(Text("A"), Empty(), When(false, Text("B")), Text("C"))
+
+	if len(nodes) != 2 {
+		t.Fatalf("Compact result count = %d, want 2", len(nodes))
+	}
+	for i, want := range []string{"A", "C"} {
+		content, _ := nodes[i].GetTextContent()
+		if content != want {
+			t.Errorf("Compact result[%d] = %q, want %q", i, content, want)
+		}
+	}
+
+	if nodes := Compact(Empty()); len(nodes) != 0 {
+		t.Errorf("Compact(Empty()) count = %d, want 0", len(nodes))
+	}
+}
+
+func TestJoin(t *testing.T) {
+	nodes := Join([]VNode{Text("A"), Text("B"), Text("C")}, Text(","))
+
+	var got string
+	for _, node := range nodes {
+		content, _ := node.GetTextContent()
+		got += content
+	}
+	if got != "A,B,C" {
+		t.Errorf("Join result = %q, want 'A,B,C'", got)
+	}
+
+	if nodes := Join([]VNode{Text("A")}, Text(",")); len(nodes) != 1 {
+		t.Errorf("Join of one node count = %d, want 1", len(nodes))
+	}
+	if nodes := Join(nil, Text(",")); len(nodes) != 0 {
+		t.Errorf("Join(nil) count = %d, want 0", len(nodes))
+	}
+}
+
+func TestWrap(t *testing.T) {
+	link := func(child VNode) VNode {
+		return Element("a", Props{"href": "/"}, child)
+	}
+	child := Text("Title")
+
+	result := Wrap(true, link, child)
+	if result.Type != "a" || len(result.Children) != 1 {
+		t.Errorf("Wrap(true, ...) = %+v, want <a> wrapping child", result)
+	}
+
+	result = Wrap(false, link, child)
+	if content, _ := result.GetTextContent(); content != "Title" {
+		t.Errorf("Wrap(false, ...) content = %q, want 'Title'", content)
+	}
+}
+
 func TestComponentElement(t *testing.T) {
 	var MyComponent Component = func(props Props) VNode {
 		return Element("div", props)
@@ -166,3 +372,233 @@ func TestEmpty(t *testing.T) {
 		t.Error("Empty() should return an empty VNode")
 	}
 }
+
+func TestUnsafe(t *testing.T) {
+	node := Unsafe("<b>bold</b>")
+	if !node.IsUnsafe() {
+		t.Error("Unsafe node should return true for IsUnsafe()")
+	}
+	if html, ok := node.GetUnsafeHTML(); !ok || html != "<b>bold</b>" {
+		t.Errorf("GetUnsafeHTML() = %q, %v; want %q, true", html, ok, "<b>bold</b>")
+	}
+	if _, ok := Text("<b>").GetUnsafeHTML(); ok {
+		t.Error("GetUnsafeHTML should return ok=false for a text node")
+	}
+}
+
+type unsafeRenderer struct {
+	allow    bool
+	rendered VNode
+}
+
+func (r *unsafeRenderer) Render(vnode VNode) error { r.rendered = vnode; return nil }
+func (r *unsafeRenderer) AllowsUnsafe() bool       { return r.allow }
+
+func TestRenderStripsUnsafe(t *testing.T) {
+	safe := Element("p", nil, Text("safe"))
+	tree := Element("div", nil, safe, Element("section", nil, Unsafe("<script></script>")))
+
+	var rendered VNode
+	if err := Render(RenderFunc(func(v VNode) error { rendered = v; return nil }), tree); err != nil {
+		t.Fatal(err)
+	}
+	if !rendered.Children[1].Children[0].IsEmpty() {
+		t.Error("Render should drop Unsafe nodes for renderers that do not opt in")
+	}
+	if !tree.Children[1].Children[0].IsUnsafe() {
+		t.Error("Render should not modify the tree passed in")
+	}
+	if &rendered.Children[0].Children[0] != &safe.Children[0] {
+		t.Error("subtrees without Unsafe nodes should be shared")
+	}
+
+	r := &unsafeRenderer{allow: false}
+	Render(r, tree)
+	if !r.rendered.Children[1].Children[0].IsEmpty() {
+		t.Error("Render should drop Unsafe nodes when AllowsUnsafe returns false")
+	}
+	r.allow = true
+	Render(r, tree)
+	if !r.rendered.Children[1].Children[0].IsUnsafe() {
+		t.Error("Render should keep Unsafe nodes for renderers that allow them")
+	}
+}
+
+func TestStripUnsafeComponents(t *testing.T) {
+	raw := Component(func(props Props) VNode {
+		return Element("div", nil, Unsafe("<script></script>"))
+	})
+	rawCtx := ContextComponent(func(ctx context.Context, props Props) VNode {
+		return Element(raw, nil)
+	})
+	for _, tree := range []VNode{Element(raw, nil), Element("main", nil, Element(rawCtx, nil))} {
+		stripped := StripUnsafe(tree)
+		var expand func(VNode) VNode
+		expand = func(node VNode) VNode {
+			for expanded := true; expanded; {
+				node, expanded = Expand(context.Background(), node)
+			}
+			children := make([]VNode, len(node.Children))
+			for i, child := range node.Children {
+				children[i] = expand(child)
+			}
+			node.Children = children
+			return node
+		}
+		WalkTree(expand(stripped), WalkFunc(func(node VNode, depth int) bool {
+			if node.IsUnsafe() {
+				t.Error("StripUnsafe should drop Unsafe nodes rendered by components")
+			}
+			return true
+		}))
+	}
+}
+
+func TestChain(t *testing.T) {
+	var calls []string
+	trace := func(name string) Middleware {
+		return func(next Renderer) Renderer {
+			return RenderFunc(func(v VNode) error {
+				calls = append(calls, name+" before")
+				err := next.Render(v)
+				calls = append(calls, name+" after")
+				return err
+			})
+		}
+	}
+	theme := func(next Renderer) Renderer {
+		return RenderFunc(func(v VNode) error {
+			return next.Render(Element("theme", Props{"name": "dark"}, v))
+		})
+	}
+
+	var rendered VNode
+	r := Chain(RenderFunc(func(v VNode) error {
+		calls = append(calls, "render")
+		rendered = v
+		return nil
+	}), trace("outer"), theme, trace("inner"))
+
+	if err := r.Render(Text("hi")); err != nil {
+		t.Fatal(err)
+	}
+	want := []string{"outer before", "inner before", "render", "inner after", "outer after"}
+	if strings.Join(calls, ", ") != strings.Join(want, ", ") {
+		t.Errorf("calls = %v, want %v", calls, want)
+	}
+	if rendered.Type != "theme" || !rendered.Children[0].IsText() {
+		t.Errorf("rendered = %v, want the tree wrapped in <theme>", rendered)
+	}
+}
+
+func TestChainKeepsUnsafeOptIn(t *testing.T) {
+	base := &unsafeRenderer{allow: true}
+	r := Chain(base, func(next Renderer) Renderer {
+		return RenderFunc(next.Render)
+	})
+	if err := Render(r, Unsafe("<b>")); err != nil {
+		t.Fatal(err)
+	}
+	if !base.rendered.IsUnsafe() {
+		t.Error("a chain should allow Unsafe nodes when its renderer does")
+	}
+
+	if r := Chain(base); r != Renderer(base) {
+		t.Error("Chain without middleware should return the renderer itself")
+	}
+}
+
+type ctxKey struct{}
+
+func TestRenderContext(t *testing.T) {
+	ctx := context.WithValue(context.Background(), ctxKey{}, "request-1")
+
+	var got any
+	base := ContextRenderFunc(func(ctx context.Context, v VNode) error {
+		got = ctx.Value(ctxKey{})
+		return nil
+	})
+	logged := 0
+	logging := func(next Renderer) Renderer {
+		return ContextRenderFunc(func(ctx context.Context, v VNode) error {
+			logged++
+			return CallRenderer(ctx, next, v)
+		})
+	}
+	if err := RenderContext(ctx, Chain(base, logging), Text("hi")); err != nil {
+		t.Fatal(err)
+	}
+	if got != "request-1" || logged != 1 {
+		t.Errorf("renderer saw %v after %d middleware call(s), want request-1 after 1", got, logged)
+	}
+
+	canceled, cancel := context.WithCancel(ctx)
+	cancel()
+	got = nil
+	if err := RenderContext(canceled, base, Text("hi")); err != context.Canceled {
+		t.Errorf("RenderContext error = %v, want context.Canceled", err)
+	}
+	if got != nil {
+		t.Error("RenderContext should not render with a canceled context")
+	}
+}
+
+func TestExpand(t *testing.T) {
+	ctx := context.WithValue(context.Background(), ctxKey{}, "dark")
+	themed := ContextComponent(func(ctx context.Context, props Props) VNode {
+		return Element("div", Props{"theme": ctx.Value(ctxKey{}), "title": props["title"]})
+	})
+	plain := Component(func(props Props) VNode {
+		return Text(props["title"].(string))
+	})
+
+	node := Element(themed, Props{"title": "hello"})
+	if !node.IsComponent() {
+		t.Error("ContextComponent element should return true for IsComponent()")
+	}
+	expanded, ok := Expand(ctx, node)
+	if !ok || expanded.Props["theme"] != "dark" || expanded.Props["title"] != "hello" {
+		t.Errorf("Expand(ContextComponent) = %v, %v", expanded, ok)
+	}
+	if expanded, ok := Expand(ctx, Element(plain, Props{"title": "hi"})); !ok || !expanded.IsText() {
+		t.Errorf("Expand(Component) = %v, %v", expanded, ok)
+	}
+	if _, ok := Expand(ctx, Element("div", nil)); ok {
+		t.Error("Expand should return false for intrinsic elements")
+	}
+}
+
+func TestWalkTreeContext(t *testing.T) {
+	tree := Element("root", nil, Element("a", nil), Element("b", nil), Element("c", nil))
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	var visited []string
+	err := WalkTreeContext(ctx, tree, WalkFunc(func(node VNode, depth int) bool {
+		visited = append(visited, node.Type.(string))
+		if node.Type == "a" {
+			cancel()
+		}
+		return true
+	}))
+	if err != context.Canceled {
+		t.Errorf("WalkTreeContext error = %v, want context.Canceled", err)
+	}
+	if strings.Join(visited, " ") != "root a" {
+		t.Errorf("visited = %v, want [root a]", visited)
+	}
+}
+
+func TestCached(t *testing.T) {
+	node := Cached("footer", time.Hour, Element("footer", nil))
+	key, ttl, ok := node.GetCacheControl()
+	if !node.IsCached() || !ok || key != "footer" || ttl != time.Hour {
+		t.Errorf("GetCacheControl() = %q, %v, %v; want footer, 1h, true", key, ttl, ok)
+	}
+	if len(node.Children) != 1 || node.Children[0].Type != "footer" {
+		t.Errorf("Children = %v, want the cached subtree", node.Children)
+	}
+	if _, _, ok := Fragment().GetCacheControl(); ok {
+		t.Error("GetCacheControl should return ok=false for a fragment")
+	}
+}
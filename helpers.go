@@ -1,6 +1,10 @@
 package gox
 
-import "fmt"
+import (
+	"fmt"
+	"strings"
+	"time"
+)
 
 // Text creates a text VNode.
 func Text(content string) VNode {
@@ -10,6 +14,122 @@ func Text(content string) VNode {
 	}
 }
 
+// Unsafe creates a node of raw HTML, such as a sanitized fragment from a
+// third party, to be rendered verbatim. Only renderers that opt in by
+// implementing UnsafeRenderer render it; Render drops it for any other
+// renderer. The caller is responsible for html being safe to embed.
+func Unsafe(html string) VNode {
+	return VNode{
+		Type:  UnsafeNodeType,
+		Props: Props{"html": html},
+	}
+}
+
+// Cached marks child as shared across renders: renderers that cache, such as
+// the HTML renderer with a cache configured, render it once per key and
+// reuse the output for ttl, or indefinitely if ttl is not positive. Use it
+// for expensive subtrees that do not depend on the request, such as nav bars
+// and footers. Renderers that do not cache render child like the children of
+// a fragment.
+func Cached(key string, ttl time.Duration, child VNode) VNode {
+	return VNode{
+		Type:     CachedNodeType,
+		Props:    Props{"key": key, "ttl": ttl},
+		Children: []VNode{child},
+	}
+}
+
+// Textf formats according to a format specifier and returns a text VNode.
+// Arguments are interpolated safely: nil and empty VNodes format as "" and
+// text VNodes as their content. Elements, components, fragments and []VNode
+// cannot be flattened into text, so if any argument is one of those Textf
+// returns a fragment with the formatted text around them instead.
+// The generator emits Textf for text mixed with expressions: Hello, {name}!
+func Textf(format string, args ...any) VNode {
+	values := make([]any, len(args))
+	for i, arg := range args {
+		value, ok := textValue(arg)
+		if !ok {
+			return textfFragment(format, args)
+		}
+		values[i] = value
+	}
+	return Text(fmt.Sprintf(format, values...))
+}
+
+// textValue returns the value a Textf argument formats as, or false if the
+// argument is structural and must stay a node.
+func textValue(arg any) (any, bool) {
+	switch v := arg.(type) {
+	case nil:
+		return "", true
+	case VNode:
+		if v.IsEmpty() {
+			return "", true
+		}
+		if content, ok := v.GetTextContent(); ok {
+			return content, true
+		}
+		return nil, false
+	case []VNode:
+		return nil, false
+	}
+	return arg, true
+}
+
+// textfFragment formats format piece by piece, turning structural arguments
+// into child nodes between runs of formatted text.
+func textfFragment(format string, args []any) VNode {
+	var children []VNode
+	var text strings.Builder
+	flush := func() {
+		if text.Len() > 0 {
+			children = append(children, Text(text.String()))
+			text.Reset()
+		}
+	}
+
+	argIndex := 0
+	for i := 0; i < len(format); i++ {
+		if format[i] != '%' {
+			text.WriteByte(format[i])
+			continue
+		}
+
+		// Find the verb, skipping flags, width and precision
+		j := i + 1
+		for j < len(format) && strings.IndexByte("+-# 0123456789.", format[j]) >= 0 {
+			j++
+		}
+		if j >= len(format) {
+			text.WriteString(format[i:])
+			break
+		}
+		spec := format[i : j+1]
+		i = j
+		if format[j] == '%' {
+			text.WriteByte('%')
+			continue
+		}
+		if argIndex >= len(args) {
+			text.WriteString(fmt.Sprintf(spec)) // Reports %!v(MISSING) like fmt
+			continue
+		}
+
+		arg := args[argIndex]
+		argIndex++
+		if value, ok := textValue(arg); ok {
+			text.WriteString(fmt.Sprintf(spec, value))
+			continue
+		}
+		flush()
+		children = append(children, V(arg))
+	}
+	flush()
+
+	return Fragment(children...)
+}
+
 // V converts an arbitrary value to a VNode.
 // If the value is already a VNode, it's returned as-is.
 // If it's a string, it's wrapped as a Text node.
@@ -44,6 +164,16 @@ func Fragment(children ...VNode) VNode {
 	}
 }
 
+// KeyedFragment is a Fragment with a key, as <Fragment key={id}> generates,
+// so that fragments in a list keep their identity across renders.
+func KeyedFragment(key any, children ...VNode) VNode {
+	return VNode{
+		Type:     FragmentNodeType,
+		Props:    Props{"key": key},
+		Children: children,
+	}
+}
+
 // When returns child if condition is true, else empty VNode.
 // Useful for conditional rendering: {gox.When(showExtra, <Extra />)}
 func When(condition bool, child VNode) VNode {
@@ -85,3 +215,40 @@ func MapIndex[T any](items []T, fn func(int, T) VNode) []VNode {
 func Spread(nodes []VNode) VNode {
 	return Fragment(nodes...)
 }
+
+// Compact returns the nodes that are not empty.
+// Useful with When: {gox.Compact(gox.When(a, <A />), gox.When(b, <B />))}
+func Compact(nodes ...VNode) []VNode {
+	result := make([]VNode, 0, len(nodes))
+	for _, node := range nodes {
+		if !node.IsEmpty() {
+			result = append(result, node)
+		}
+	}
+	return result
+}
+
+// Join returns nodes with sep inserted between each pair of adjacent nodes.
+// Useful for separated lists: {gox.Join(links, gox.Text(" | "))}
+func Join(nodes []VNode, sep VNode) []VNode {
+	if len(nodes) == 0 {
+		return nil
+	}
+	result := make([]VNode, 0, 2*len(nodes)-1)
+	for i, node := range nodes {
+		if i > 0 {
+			result = append(result, sep)
+		}
+		result = append(result, node)
+	}
+	return result
+}
+
+// Wrap returns wrapper(child) if condition is true, else child unchanged.
+// Useful for optional containers: {gox.Wrap(linked, func(c VNode) VNode { return <a href={url}>{c}</a> }, <Title />)}
+func Wrap(condition bool, wrapper func(VNode) VNode, child VNode) VNode {
+	if condition {
+		return wrapper(child)
+	}
+	return child
+}
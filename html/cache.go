@@ -0,0 +1,128 @@
+package html
+
+import (
+	"container/list"
+	"sync"
+	"time"
+)
+
+// DefaultCacheEntries is the number of outputs a cache from NewCache holds.
+const DefaultCacheEntries = 1024
+
+// Cache holds the rendered output of gox.Cached subtrees across renders. It
+// is safe for concurrent use. Share a Cache only between renderers with the
+// same options, since they affect the output.
+//
+// A cache holds a bounded number of outputs: when it is full, expired
+// outputs are dropped, then the least recently used, so keys that are never
+// read again do not accumulate.
+type Cache struct {
+	mu      sync.Mutex
+	max     int
+	entries map[string]*list.Element // Of *cacheEntry
+	lru     list.List                // Most recently used first
+	now     func() time.Time         // For tests
+}
+
+type cacheEntry struct {
+	key     string
+	html    []byte
+	expires time.Time // Zero if the entry never expires
+}
+
+// NewCache returns an empty cache of DefaultCacheEntries outputs.
+func NewCache() *Cache {
+	return NewCacheSize(DefaultCacheEntries)
+}
+
+// NewCacheSize returns an empty cache of at most max outputs, or of
+// DefaultCacheEntries if max is not positive.
+func NewCacheSize(max int) *Cache {
+	if max <= 0 {
+		max = DefaultCacheEntries
+	}
+	return &Cache{max: max, entries: make(map[string]*list.Element), now: time.Now}
+}
+
+// Len returns the number of outputs in the cache, expired ones included
+// until they are dropped.
+func (c *Cache) Len() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return len(c.entries)
+}
+
+// get returns the output cached under key, if it has not expired.
+func (c *Cache) get(key string) ([]byte, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	el, ok := c.entries[key]
+	if !ok {
+		return nil, false
+	}
+	e := el.Value.(*cacheEntry)
+	if c.expired(e) {
+		c.remove(el)
+		return nil, false
+	}
+	c.lru.MoveToFront(el)
+	return e.html, true
+}
+
+// put caches html under key for ttl, or indefinitely if ttl is not positive.
+func (c *Cache) put(key string, html []byte, ttl time.Duration) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	e := &cacheEntry{key: key, html: html}
+	if ttl > 0 {
+		e.expires = c.now().Add(ttl)
+	}
+	if el, ok := c.entries[key]; ok {
+		el.Value = e
+		c.lru.MoveToFront(el)
+		return
+	}
+	c.entries[key] = c.lru.PushFront(e)
+	if len(c.entries) <= c.max {
+		return
+	}
+	for el := c.lru.Back(); el != nil; {
+		prev := el.Prev()
+		if c.expired(el.Value.(*cacheEntry)) {
+			c.remove(el)
+		}
+		el = prev
+	}
+	for len(c.entries) > c.max {
+		c.remove(c.lru.Back())
+	}
+}
+
+// expired reports whether e has expired.
+func (c *Cache) expired(e *cacheEntry) bool {
+	return !e.expires.IsZero() && !c.now().Before(e.expires)
+}
+
+// remove drops el from the cache.
+func (c *Cache) remove(el *list.Element) {
+	c.lru.Remove(el)
+	delete(c.entries, el.Value.(*cacheEntry).key)
+}
+
+// Delete removes the output cached under key, so the next render renders
+// the subtree again.
+func (c *Cache) Delete(key string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if el, ok := c.entries[key]; ok {
+		c.remove(el)
+	}
+}
+
+// Clear removes every cached output.
+func (c *Cache) Clear() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.entries = make(map[string]*list.Element)
+	c.lru.Init()
+}
@@ -0,0 +1,348 @@
+// Package html renders gox trees to HTML, for server-side rendering.
+//
+// Intrinsic elements become HTML elements with their props as attributes,
+// text is escaped, except in <script> and <style>, and components are
+// expanded with gox.Expand. Independent
+// sibling subtrees are rendered concurrently by a bounded pool of workers and
+// stitched together in order, so the output does not depend on scheduling.
+package html
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"runtime"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+	"unicode"
+
+	"github.com/germtb/gox"
+)
+
+// Options configures a Renderer.
+type Options struct {
+	// AllowUnsafe renders gox.Unsafe nodes verbatim. Without it they are
+	// dropped.
+	AllowUnsafe bool
+
+	// Workers bounds the number of goroutines rendering sibling subtrees
+	// concurrently. Default: runtime.GOMAXPROCS(0)
+	Workers int
+
+	// Cache stores the output of gox.Cached subtrees, shared by every render
+	// that uses it. Without a cache they are rendered every time.
+	Cache *Cache
+
+	// Sequential renders the whole tree on the calling goroutine, so
+	// components run one at a time in document order. Useful for debugging
+	// components with side effects.
+	Sequential bool
+}
+
+// Renderer renders trees as HTML to a writer. It implements
+// gox.ContextRenderer and gox.UnsafeRenderer.
+//
+// A tree is rendered in memory and written in one piece, so nothing is
+// written if rendering fails. Components may run concurrently unless
+// Options.Sequential is set.
+type Renderer struct {
+	w    io.Writer
+	opts Options
+}
+
+// New returns a Renderer writing to w. opts may be nil.
+func New(w io.Writer, opts *Options) *Renderer {
+	r := &Renderer{w: w}
+	if opts != nil {
+		r.opts = *opts
+	}
+	if r.opts.Workers <= 0 {
+		r.opts.Workers = runtime.GOMAXPROCS(0)
+	}
+	return r
+}
+
+// Render renders vnode as HTML.
+func (r *Renderer) Render(vnode gox.VNode) error {
+	return r.RenderContext(context.Background(), vnode)
+}
+
+// RenderContext renders vnode as HTML, stopping with ctx.Err() once ctx is
+// done.
+func (r *Renderer) RenderContext(ctx context.Context, vnode gox.VNode) error {
+	s := &state{ctx: ctx, opts: &r.opts}
+	if !r.opts.Sequential && r.opts.Workers > 1 {
+		// The calling goroutine is a worker too
+		s.workers = make(chan struct{}, r.opts.Workers-1)
+	}
+
+	var buf bytes.Buffer
+	if err := s.render(&buf, vnode); err != nil {
+		return err
+	}
+	_, err := r.w.Write(buf.Bytes())
+	return err
+}
+
+// AllowsUnsafe implements gox.UnsafeRenderer.
+func (r *Renderer) AllowsUnsafe() bool {
+	return r.opts.AllowUnsafe
+}
+
+// RenderString renders vnode to a string. opts may be nil.
+func RenderString(ctx context.Context, vnode gox.VNode, opts *Options) (string, error) {
+	var sb strings.Builder
+	if err := gox.RenderContext(ctx, New(&sb, opts), vnode); err != nil {
+		return "", err
+	}
+	return sb.String(), nil
+}
+
+// voidElements have no closing tag and no children.
+var voidElements = map[string]bool{
+	"area": true, "base": true, "br": true, "col": true, "embed": true, "hr": true,
+	"img": true, "input": true, "link": true, "meta": true, "source": true,
+	"track": true, "wbr": true,
+}
+
+// rawTextElements hold text that HTML does not unescape, so their text
+// children are written verbatim.
+var rawTextElements = map[string]bool{"script": true, "style": true}
+
+// state is one render of a tree.
+type state struct {
+	ctx     context.Context
+	opts    *Options
+	workers chan struct{} // Tokens for extra goroutines; nil when sequential
+	rawText string        // Raw text element whose children are rendered, if any
+}
+
+func (s *state) render(buf *bytes.Buffer, node gox.VNode) error {
+	if err := s.ctx.Err(); err != nil {
+		return err
+	}
+	if node.IsEmpty() {
+		return nil
+	}
+	if content, ok := node.GetTextContent(); ok {
+		if s.rawText == "" {
+			buf.WriteString(escape(content))
+			return nil
+		}
+		if closesRawText(content, s.rawText) {
+			return fmt.Errorf("html: text of <%s> contains </%s", s.rawText, s.rawText)
+		}
+		buf.WriteString(content)
+		return nil
+	}
+	if html, ok := node.GetUnsafeHTML(); ok {
+		if s.opts.AllowUnsafe {
+			buf.WriteString(html)
+		}
+		return nil
+	}
+	if key, ttl, ok := node.GetCacheControl(); ok && s.opts.Cache != nil {
+		return s.renderCached(buf, node, key, ttl)
+	}
+	if node.IsFragment() || node.IsCached() {
+		return s.renderChildren(buf, node.Children)
+	}
+	if expanded, ok := gox.Expand(s.ctx, node); ok {
+		return s.render(buf, expanded)
+	}
+
+	tag, ok := node.Type.(string)
+	if !ok {
+		return fmt.Errorf("html: cannot render node of type %T", node.Type)
+	}
+	buf.WriteByte('<')
+	buf.WriteString(tag)
+	writeAttributes(buf, node.Props)
+	buf.WriteByte('>')
+	if voidElements[tag] {
+		return nil
+	}
+	inner := s
+	if rawTextElements[tag] || s.rawText != "" {
+		inner = &state{ctx: s.ctx, opts: s.opts, workers: s.workers}
+		if rawTextElements[tag] {
+			inner.rawText = tag
+		}
+	}
+	if err := inner.renderChildren(buf, node.Children); err != nil {
+		return err
+	}
+	buf.WriteString("</")
+	buf.WriteString(tag)
+	buf.WriteByte('>')
+	return nil
+}
+
+// closesRawText reports whether text contains the start of the closing tag
+// of the raw text element tag, which would end the element early.
+func closesRawText(text, tag string) bool {
+	for i := strings.Index(text, "</"); i >= 0; i = strings.Index(text, "</") {
+		text = text[i+2:]
+		if len(text) >= len(tag) && strings.EqualFold(text[:len(tag)], tag) {
+			return true
+		}
+	}
+	return false
+}
+
+// renderCached renders the children of a gox.Cached node from the cache,
+// rendering and caching them on a miss. Output is not cached if rendering
+// fails.
+func (s *state) renderCached(buf *bytes.Buffer, node gox.VNode, key string, ttl time.Duration) error {
+	if html, ok := s.opts.Cache.get(key); ok {
+		buf.Write(html)
+		return nil
+	}
+	var out bytes.Buffer
+	if err := s.renderChildren(&out, node.Children); err != nil {
+		return err
+	}
+	s.opts.Cache.put(key, out.Bytes(), ttl)
+	buf.Write(out.Bytes())
+	return nil
+}
+
+// renderChildren renders children in order. Element and component children
+// are handed to idle workers when there are any; the rest, and every child
+// when all workers are busy, are rendered by the calling goroutine. A panic
+// on a worker is returned as an error, as nothing up its stack recovers it.
+func (s *state) renderChildren(buf *bytes.Buffer, children []gox.VNode) error {
+	if s.workers == nil || len(children) < 2 {
+		for _, child := range children {
+			if err := s.render(buf, child); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+
+	type result struct {
+		buf bytes.Buffer
+		err error
+	}
+	results := make([]result, len(children))
+	var wg sync.WaitGroup
+	for i := range children {
+		child, res := children[i], &results[i]
+		if isSubtree(child) {
+			select {
+			case s.workers <- struct{}{}:
+				wg.Add(1)
+				go func() {
+					defer wg.Done()
+					defer func() { <-s.workers }()
+					defer func() {
+						if r := recover(); r != nil {
+							res.err = fmt.Errorf("html: panic rendering %s: %v", describe(child), r)
+						}
+					}()
+					res.err = s.render(&res.buf, child)
+				}()
+				continue
+			default:
+			}
+		}
+		res.err = s.render(&res.buf, child)
+	}
+	wg.Wait()
+
+	for i := range results {
+		if results[i].err != nil {
+			return results[i].err
+		}
+		buf.Write(results[i].buf.Bytes())
+	}
+	return nil
+}
+
+// describe names node for errors: its tag, or its component type.
+func describe(node gox.VNode) string {
+	if tag, ok := node.Type.(string); ok {
+		return "<" + tag + ">"
+	}
+	return fmt.Sprintf("%T", node.Type)
+}
+
+// isSubtree reports whether a node may be worth rendering on another
+// goroutine: text and empty nodes never are.
+func isSubtree(node gox.VNode) bool {
+	return node.IsComponent() || len(node.Children) > 0
+}
+
+// writeAttributes writes props as attributes, sorted by name. Strings,
+// numbers and fmt.Stringers are written as values, true as a bare attribute.
+// false, nil, functions and other values that have no HTML form are skipped,
+// as are the key prop and names that are not valid attribute names.
+func writeAttributes(buf *bytes.Buffer, props gox.Props) {
+	names := make([]string, 0, len(props))
+	for name := range props {
+		if name != "key" && validAttribute(name) {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		var value string
+		switch v := props[name].(type) {
+		case string:
+			value = v
+		case bool:
+			if v {
+				buf.WriteByte(' ')
+				buf.WriteString(name)
+			}
+			continue
+		case int:
+			value = strconv.Itoa(v)
+		case int8, int16, int32, int64, uint, uint8, uint16, uint32, uint64, float32, float64:
+			value = fmt.Sprint(v)
+		case fmt.Stringer:
+			value = v.String()
+		default:
+			continue
+		}
+		buf.WriteByte(' ')
+		buf.WriteString(name)
+		buf.WriteString(`="`)
+		buf.WriteString(escape(value))
+		buf.WriteByte('"')
+	}
+}
+
+// validAttribute reports whether name can be written as an attribute name:
+// it must be non-empty and free of whitespace, quotes, '>', '/', '=' and
+// control characters, any of which would end the name early.
+func validAttribute(name string) bool {
+	if name == "" {
+		return false
+	}
+	for _, r := range name {
+		if unicode.IsSpace(r) || unicode.IsControl(r) || strings.ContainsRune(`"'>/=`, r) {
+			return false
+		}
+	}
+	return true
+}
+
+var escaper = strings.NewReplacer(
+	`&`, "&amp;",
+	`'`, "&#39;",
+	`<`, "&lt;",
+	`>`, "&gt;",
+	`"`, "&#34;",
+)
+
+// escape escapes text and attribute values, like html.EscapeString.
+func escape(s string) string {
+	return escaper.Replace(s)
+}
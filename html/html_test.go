@@ -0,0 +1,288 @@
+package html
+
+import (
+	"context"
+	"errors"
+	"io"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/germtb/gox"
+	"github.com/germtb/gox/rendertest"
+)
+
+func TestRenderString(t *testing.T) {
+	greeting := gox.Component(func(props gox.Props) gox.VNode {
+		return gox.Element("h1", nil, gox.Textf("Hello, %s!", props["name"]))
+	})
+	raw := gox.Component(func(props gox.Props) gox.VNode {
+		return gox.Element("p", nil, gox.Unsafe("<b>raw</b>"))
+	})
+	css := gox.Component(func(props gox.Props) gox.VNode {
+		return gox.Text(`p > a { content: "&"; }`)
+	})
+	card := gox.Component(func(props gox.Props) gox.VNode {
+		return gox.Element("section", nil, gox.Children(props["children"])...)
+	})
+
+	tests := []struct {
+		name string
+		node gox.VNode
+		want string
+	}{
+		{"text is escaped", gox.Text(`<a href="x">&`), "&lt;a href=&#34;x&#34;&gt;&amp;"},
+		{"attributes", gox.Element("input", gox.Props{
+			"type": "checkbox", "checked": true, "disabled": false, "tabindex": 2,
+			"key": "k", "onClick": func() {}, "title": `"quoted"`,
+		}), `<input checked tabindex="2" title="&#34;quoted&#34;" type="checkbox">`},
+		{"invalid attribute names are skipped", gox.Element("a", gox.Props{
+			`x" onclick="alert(1)`: "v", "a b": "v", "a/b": "v", "a=b": "v", "a>": "v", "'": "v",
+			"a\nb": true, "": "v", "data-id": "7",
+		}), `<a data-id="7"></a>`},
+		{"raw text elements", gox.Element("head", nil,
+			gox.Element("script", nil, gox.Text(`if (a < b && c > "d") {}`)),
+			gox.Element("style", nil, gox.Element(css, nil)),
+			gox.Element("title", nil, gox.Text("a < b")),
+		), `<head><script>if (a < b && c > "d") {}</script><style>p > a { content: "&"; }</style><title>a &lt; b</title></head>`},
+		{"children", gox.Element("ul", gox.Props{"class": "list"},
+			gox.Element("li", nil, gox.Text("one")),
+			gox.Fragment(gox.Element("li", nil, gox.Text("two")), gox.Empty()),
+		), `<ul class="list"><li>one</li><li>two</li></ul>`},
+		{"components", gox.Element("div", nil, gox.Element(greeting, gox.Props{"name": "gox"})),
+			"<div><h1>Hello, gox!</h1></div>"},
+		{"component children prop", gox.Element(card, gox.Props{"children": gox.Text("body")}),
+			"<section>body</section>"},
+		{"unsafe is dropped", gox.Element("div", nil, gox.Unsafe("<b>raw</b>")), "<div></div>"},
+		{"unsafe from a component is dropped", gox.Element("div", nil, gox.Element(raw, nil)), "<div><p></p></div>"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := RenderString(context.Background(), tt.node, nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got != tt.want {
+				t.Errorf("got  %s\nwant %s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRenderUnsafe(t *testing.T) {
+	got, err := RenderString(context.Background(), gox.Element("div", nil, gox.Unsafe("<b>raw</b>")), &Options{AllowUnsafe: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := "<div><b>raw</b></div>"; got != want {
+		t.Errorf("got %s, want %s", got, want)
+	}
+}
+
+// page is a large tree of components that each do some work.
+func page(sections, rows int) gox.VNode {
+	section := gox.Component(func(props gox.Props) gox.VNode {
+		n := props["n"].(int)
+		items := make([]gox.VNode, rows)
+		for i := range items {
+			items[i] = gox.Element("tr", gox.Props{"class": "row-" + strconv.Itoa(i%2)},
+				gox.Element("td", nil, gox.Text(strconv.Itoa(n*rows+i))),
+				gox.Element("td", nil, gox.Text(strings.Repeat("<cell> ", 4))),
+			)
+		}
+		return gox.Element("section", gox.Props{"id": "s" + strconv.Itoa(n)},
+			gox.Element("table", nil, items...))
+	})
+	children := make([]gox.VNode, sections)
+	for i := range children {
+		children[i] = gox.Element(section, gox.Props{"n": i})
+	}
+	return gox.Element("main", nil, children...)
+}
+
+func TestParallelMatchesSequential(t *testing.T) {
+	tree := page(32, 20)
+	sequential, err := RenderString(context.Background(), tree, &Options{Sequential: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 5; i++ {
+		parallel, err := RenderString(context.Background(), tree, &Options{Workers: 8})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if parallel != sequential {
+			t.Fatal("parallel output differs from sequential output")
+		}
+	}
+}
+
+func TestSequentialOrder(t *testing.T) {
+	var mu sync.Mutex
+	var order []string
+	item := gox.Component(func(props gox.Props) gox.VNode {
+		mu.Lock()
+		order = append(order, props["id"].(string))
+		mu.Unlock()
+		return gox.Element("li", nil)
+	})
+	tree := gox.Element("ul", nil,
+		gox.Element(item, gox.Props{"id": "a"}),
+		gox.Element(item, gox.Props{"id": "b"}),
+		gox.Element(item, gox.Props{"id": "c"}),
+	)
+	if _, err := RenderString(context.Background(), tree, &Options{Sequential: true}); err != nil {
+		t.Fatal(err)
+	}
+	if got := strings.Join(order, ""); got != "abc" {
+		t.Errorf("components ran in order %q, want abc", got)
+	}
+}
+
+func TestRenderErrors(t *testing.T) {
+	var sb strings.Builder
+	tree := gox.Element("div", nil, gox.Element("p", nil, gox.Text("ok")), gox.Element(42, nil, gox.Text("bad")))
+	err := New(&sb, &Options{Workers: 4}).Render(tree)
+	if err == nil || !strings.Contains(err.Error(), "cannot render node of type int") {
+		t.Errorf("Render error = %v", err)
+	}
+	if sb.Len() != 0 {
+		t.Errorf("Render wrote %q after failing", sb.String())
+	}
+
+	for _, tree := range []gox.VNode{
+		gox.Element("script", nil, gox.Text(`let s = "</SCRIPT><b>"`)),
+		gox.Element("style", nil, gox.Text("a {}"), gox.Text("</style")),
+	} {
+		if _, err := RenderString(context.Background(), tree, nil); err == nil || !strings.Contains(err.Error(), "contains </") {
+			t.Errorf("RenderString(%v) error = %v, want a closing tag error", tree.Type, err)
+		}
+	}
+
+	// A panic on a worker is an error, not a crash
+	boom := gox.Component(func(props gox.Props) gox.VNode { panic("boom") })
+	tree = gox.Element("div", nil, gox.Element("p", nil, gox.Text("ok")), gox.Element(boom, nil), gox.Element(boom, nil))
+	for i := 0; i < 20; i++ {
+		_, err := RenderString(context.Background(), tree, &Options{Workers: 4})
+		if err == nil || !strings.Contains(err.Error(), "panic rendering gox.Component: boom") {
+			t.Fatalf("RenderString of a panicking component error = %v", err)
+		}
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if err := New(&sb, nil).RenderContext(ctx, page(2, 2)); !errors.Is(err, context.Canceled) {
+		t.Errorf("RenderContext error = %v, want context.Canceled", err)
+	}
+}
+
+func BenchmarkRender(b *testing.B) {
+	tree := page(64, 200)
+	for _, bm := range []struct {
+		name string
+		opts *Options
+	}{
+		{"sequential", &Options{Sequential: true}},
+		{"parallel", nil},
+	} {
+		b.Run(bm.name, func(b *testing.B) {
+			for i := 0; i < b.N; i++ {
+				if _, err := RenderString(context.Background(), tree, bm.opts); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
+
+func TestCached(t *testing.T) {
+	renders := 0
+	nav := gox.Component(func(props gox.Props) gox.VNode {
+		renders++
+		return gox.Element("nav", nil, gox.Text("menu "+strconv.Itoa(renders)))
+	})
+	body := func() gox.VNode {
+		return gox.Element("body", nil, gox.Cached("nav", time.Minute, gox.Element(nav, nil)))
+	}
+
+	now := time.Unix(0, 0)
+	cache := NewCache()
+	cache.now = func() time.Time { return now }
+	opts := &Options{Cache: cache, Sequential: true}
+	render := func() string {
+		t.Helper()
+		got, err := RenderString(context.Background(), body(), opts)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return got
+	}
+
+	if got, want := render(), "<body><nav>menu 1</nav></body>"; got != want {
+		t.Errorf("got %s, want %s", got, want)
+	}
+	now = now.Add(59 * time.Second)
+	if got := render(); renders != 1 || got != "<body><nav>menu 1</nav></body>" {
+		t.Errorf("render within the ttl rendered the subtree again: %s", got)
+	}
+	now = now.Add(time.Second)
+	if got := render(); renders != 2 || got != "<body><nav>menu 2</nav></body>" {
+		t.Errorf("render after the ttl = %s, want the subtree rendered again", got)
+	}
+	cache.Delete("nav")
+	if render(); renders != 3 {
+		t.Error("render after Delete should render the subtree again")
+	}
+
+	// Without a cache, Cached subtrees render every time
+	if got, err := RenderString(context.Background(), body(), &Options{Sequential: true}); err != nil || got != "<body><nav>menu 4</nav></body>" {
+		t.Errorf("uncached render = %s, %v", got, err)
+	}
+}
+
+func TestCacheBounded(t *testing.T) {
+	now := time.Unix(0, 0)
+	cache := NewCacheSize(3)
+	cache.now = func() time.Time { return now }
+
+	// Keys that are never read again do not accumulate
+	for i := 0; i < 100; i++ {
+		cache.put("request-"+strconv.Itoa(i), []byte("x"), time.Hour)
+		if cache.Len() > 3 {
+			t.Fatalf("cache holds %d outputs, want at most 3", cache.Len())
+		}
+	}
+
+	// Expired outputs go first, then the least recently used
+	cache.Clear()
+	cache.put("nav", []byte("nav"), 0)
+	cache.put("old", []byte("old"), time.Second)
+	cache.put("footer", []byte("footer"), 0)
+	now = now.Add(time.Minute)
+	cache.get("nav")
+	cache.put("sidebar", []byte("sidebar"), 0)
+	for _, key := range []string{"old", "footer", "sidebar", "nav"} {
+		if _, ok := cache.get(key); ok != (key != "old") {
+			t.Errorf("after the expired entry was dropped, %s cached = %v", key, ok)
+		}
+	}
+	cache.put("header", []byte("header"), 0)
+	if _, ok := cache.get("footer"); ok {
+		t.Error("the least recently used output should be evicted")
+	}
+	if cache.Len() != 3 {
+		t.Errorf("cache holds %d outputs, want 3", cache.Len())
+	}
+}
+
+func TestConformance(t *testing.T) {
+	rendertest.Run(t, func(w io.Writer) gox.Renderer { return New(w, nil) })
+	t.Run("sequential", func(t *testing.T) {
+		rendertest.Run(t, func(w io.Writer) gox.Renderer { return New(w, &Options{Sequential: true}) })
+	})
+	t.Run("unsafe", func(t *testing.T) {
+		rendertest.Run(t, func(w io.Writer) gox.Renderer { return New(w, &Options{AllowUnsafe: true}) })
+	})
+}
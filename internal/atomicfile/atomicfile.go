@@ -0,0 +1,65 @@
+// Package atomicfile writes files atomically, for the gox command and the
+// language server, which both write generated files into source trees.
+package atomicfile
+
+import (
+	"errors"
+	"math/rand"
+	"os"
+	"path/filepath"
+	"strconv"
+)
+
+// WriteFile writes data to path through a temporary file in the same
+// directory that is renamed over path once complete, so a crash or a
+// concurrent reader never sees a partially written file. An existing file
+// keeps its permissions, and a symlink keeps pointing at the file it links
+// to; a new file is created with perm, less the umask. The data is synced
+// to disk before the rename, so a crash cannot leave an empty file behind.
+func WriteFile(path string, data []byte, perm os.FileMode) (err error) {
+	if target, err := filepath.EvalSymlinks(path); err == nil {
+		path = target
+	}
+	existing, statErr := os.Stat(path)
+
+	f, err := createTemp(path, perm)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if err != nil {
+			f.Close()
+			os.Remove(f.Name())
+		}
+	}()
+
+	if _, err := f.Write(data); err != nil {
+		return err
+	}
+	if statErr == nil {
+		if err := f.Chmod(existing.Mode().Perm()); err != nil {
+			return err
+		}
+	}
+	if err := f.Sync(); err != nil {
+		return err
+	}
+	if err := f.Close(); err != nil {
+		return err
+	}
+	return os.Rename(f.Name(), path)
+}
+
+// createTemp creates a new temporary file next to path with perm, less the
+// umask. Unlike os.CreateTemp, which always uses 0600, the file is created
+// with the mode a new file at path would get.
+func createTemp(path string, perm os.FileMode) (*os.File, error) {
+	prefix := filepath.Join(filepath.Dir(path), "."+filepath.Base(path)+".")
+	for {
+		name := prefix + strconv.FormatUint(uint64(rand.Uint32()), 10) + ".tmp"
+		f, err := os.OpenFile(name, os.O_RDWR|os.O_CREATE|os.O_EXCL, perm)
+		if !errors.Is(err, os.ErrExist) {
+			return f, err
+		}
+	}
+}
@@ -0,0 +1,73 @@
+package atomicfile
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestWriteFile(t *testing.T) {
+	dir := t.TempDir()
+	read := func(path string) string {
+		t.Helper()
+		data, err := os.ReadFile(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return string(data)
+	}
+	mode := func(path string) os.FileMode {
+		t.Helper()
+		info, err := os.Stat(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return info.Mode().Perm()
+	}
+
+	// New files get the default permissions, less the umask
+	ref := filepath.Join(dir, "ref")
+	if err := os.WriteFile(ref, nil, 0664); err != nil {
+		t.Fatal(err)
+	}
+	want := mode(ref)
+	os.Remove(ref)
+	path := filepath.Join(dir, "app_gox.go")
+	if err := WriteFile(path, []byte("v1"), 0664); err != nil {
+		t.Fatal(err)
+	}
+	if got := read(path); got != "v1" || mode(path) != want {
+		t.Errorf("new file = %q, %v; want v1, %v", got, mode(path), want)
+	}
+
+	// Existing files keep theirs
+	if err := os.Chmod(path, 0600); err != nil {
+		t.Fatal(err)
+	}
+	if err := WriteFile(path, []byte("v2"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if got := read(path); got != "v2" || mode(path) != 0600 {
+		t.Errorf("overwritten file = %q, %v; want v2, -rw-------", got, mode(path))
+	}
+
+	// Symlinks are followed, not replaced
+	link := filepath.Join(dir, "link_gox.go")
+	if err := os.Symlink(path, link); err != nil {
+		t.Skipf("symlinks not supported: %v", err)
+	}
+	if err := WriteFile(link, []byte("v3"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if info, err := os.Lstat(link); err != nil || info.Mode()&os.ModeSymlink == 0 {
+		t.Errorf("symlink was replaced: %v %v", info, err)
+	}
+	if got := read(path); got != "v3" {
+		t.Errorf("symlink target = %q, want v3", got)
+	}
+
+	entries, err := os.ReadDir(dir)
+	if err != nil || len(entries) != 2 {
+		t.Errorf("temporary files left behind: %v %v", entries, err)
+	}
+}
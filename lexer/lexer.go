@@ -1,6 +1,12 @@
+// Package lexer splits gox source into tokens: runs of Go code, and the
+// tags, attributes, text and expressions of the JSX between them. It tracks
+// whether it is in Go code, a tag or element content itself, so callers see
+// a flat stream; Tokenize returns the tokens of a whole file.
 package lexer
 
 import (
+	"io"
+	"strings"
 	"unicode"
 	"unicode/utf8"
 )
@@ -13,28 +19,136 @@ type Lexer struct {
 	column int // current column number (1-indexed)
 
 	// Mode tracking
-	inJSX        bool // are we inside a JSX element?
-	jsxDepth     int  // nesting depth of JSX elements
-	braceDepth   int  // brace depth for expressions
-	inTag        bool // are we inside an opening tag (before >)?
-	inClosingTag bool // are we inside a closing tag (</tag>)?
-	sawSlash     bool // did we just see a / (for self-closing)?
-	needTagName  bool // is the next identifier a tag name?
+	inJSX        bool   // are we inside a JSX element?
+	jsxDepth     int    // nesting depth of JSX elements
+	braceDepth   int    // brace depth for expressions
+	inTag        bool   // are we inside an opening tag (before >)?
+	inClosingTag bool   // are we inside a closing tag (</tag>)?
+	sawSlash     bool   // did we just see a / (for self-closing)?
+	needTagName  bool   // is the next identifier a tag name?
+	afterTagName bool   // did the tag name just end (type arguments may follow)?
+	openTag      string // name of the opening tag being lexed
+	rawTag       string // raw element whose content comes next
+
+	rawElements map[string]bool
+
+	// Reading from an io.Reader; see NewReader
+	r     io.Reader
+	buf   strings.Builder // Holds input, which aliases it
+	base  int             // Offset of input in the source read
+	err   error           // Error that ended reading, io.EOF at the end
+	chunk []byte          // Scratch space for reads
 }
 
+// RawDirective, in the header of a .gox file, names more raw elements than
+// DefaultRawElements for the file:
+//
+//	//gox:raw pre code
+const RawDirective = "//gox:raw"
+
+// DefaultRawElements are the elements whose content is raw text in every
+// file: HTML's raw text elements. Raw content is lexed verbatim up to the
+// closing tag, with no expressions, nested elements or whitespace rules.
+var DefaultRawElements = []string{"script", "style"}
+
+// VoidDirective, in the header of a .gox file, names the void elements of
+// the file, which never have children, as HTML's <input> and <br>:
+//
+//	//gox:void br hr img input meta link
+//
+// There are none by default. The lexer does not use them; they are read
+// here with the other header directives.
+const VoidDirective = "//gox:void"
+
 // New creates a new Lexer for the given input.
 func New(input string) *Lexer {
 	return &Lexer{
-		input:  input,
-		pos:    0,
-		line:   1,
-		column: 1,
+		input:       input,
+		pos:         0,
+		line:        1,
+		column:      1,
+		rawElements: RawElements(input),
+	}
+}
+
+// NewRange creates a Lexer for input[start:end], a part of the file input
+// that starts at line:column, so that tokens have positions in the file.
+func NewRange(input string, start, end, line, column int) *Lexer {
+	l := New(input[:end])
+	l.pos, l.line, l.column = start, line, column
+	return l
+}
+
+// SetRawElements replaces the raw elements of the input, which New reads
+// from its directives, such as with those of the file a fragment was cut
+// from. It must be called before the first token.
+func (l *Lexer) SetRawElements(raw map[string]bool) {
+	l.rawElements = raw
+}
+
+// RawElements returns the raw elements of the file src: the defaults and
+// those its //gox:raw directives name before the package clause.
+func RawElements(src string) map[string]bool {
+	elements := make(map[string]bool)
+	for _, tag := range DefaultRawElements {
+		elements[tag] = true
+	}
+	directiveTags(src, RawDirective, elements)
+	return elements
+}
+
+// VoidElements returns the void elements of the file src: those its
+// //gox:void directives name before the package clause.
+func VoidElements(src string) map[string]bool {
+	elements := make(map[string]bool)
+	directiveTags(src, VoidDirective, elements)
+	return elements
+}
+
+// directiveTags adds to tags those that directive names in the header of
+// src, before the package clause.
+func directiveTags(src, directive string, tags map[string]bool) {
+	for _, line := range strings.Split(src, "\n") {
+		line = strings.TrimSpace(line)
+		if strings.HasPrefix(line, "package ") {
+			break
+		}
+		if rest, ok := strings.CutPrefix(line, directive); ok && (rest == "" || rest[0] == ' ' || rest[0] == '\t') {
+			for _, tag := range strings.Fields(rest) {
+				tags[tag] = true
+			}
+		}
+	}
+}
+
+// CloseElement ends the innermost element, which the input does not close,
+// as a parser recovering from a missing closing tag does: a closing tag
+// lexed after it ends the element around it, and the JSX ends if the one
+// closed was the outermost.
+func (l *Lexer) CloseElement() {
+	if l.jsxDepth == 0 {
+		return
+	}
+	l.jsxDepth--
+	if l.jsxDepth == 0 {
+		l.inJSX = false
 	}
 }
 
 // NextToken returns the next token from the input.
 func (l *Lexer) NextToken() Token {
-	if l.pos >= len(l.input) {
+	if l.r == nil {
+		return l.nextToken()
+	}
+	l.compact()
+	tok := l.nextToken()
+	tok.Offset += l.base
+	tok.End += l.base
+	return tok
+}
+
+func (l *Lexer) nextToken() Token {
+	if !l.more() {
 		return l.makeToken(TOKEN_EOF, "")
 	}
 
@@ -51,7 +165,7 @@ func (l *Lexer) lexGoCode() Token {
 	startLine := l.line
 	startColumn := l.column
 
-	for l.pos < len(l.input) {
+	for l.more() {
 		if l.isJSXStart() {
 			// Found JSX, return accumulated Go code first
 			if l.pos > start {
@@ -59,6 +173,7 @@ func (l *Lexer) lexGoCode() Token {
 					Type:   TOKEN_GO_CODE,
 					Value:  l.input[start:l.pos],
 					Offset: start,
+					End:    l.pos,
 					Line:   startLine,
 					Column: startColumn,
 				}
@@ -67,21 +182,7 @@ func (l *Lexer) lexGoCode() Token {
 			return l.lexJSXOpen()
 		}
 
-		// Handle strings and comments to avoid false JSX detection
-		ch := l.peek()
-		if ch == '"' {
-			l.lexGoString()
-		} else if ch == '\'' {
-			l.lexGoRune()
-		} else if ch == '`' {
-			l.lexGoRawString()
-		} else if ch == '/' && l.peekNext() == '/' {
-			l.lexGoLineComment()
-		} else if ch == '/' && l.peekNext() == '*' {
-			l.lexGoBlockComment()
-		} else {
-			l.advance()
-		}
+		l.skipGo()
 	}
 
 	// Return remaining Go code
@@ -90,6 +191,7 @@ func (l *Lexer) lexGoCode() Token {
 			Type:   TOKEN_GO_CODE,
 			Value:  l.input[start:l.pos],
 			Offset: start,
+			End:    l.pos,
 			Line:   startLine,
 			Column: startColumn,
 		}
@@ -98,46 +200,136 @@ func (l *Lexer) lexGoCode() Token {
 	return l.makeToken(TOKEN_EOF, "")
 }
 
+// skipGo advances past a literal, a comment, or a run of other Go code, so
+// that '<' in strings and comments is not taken for JSX.
+func (l *Lexer) skipGo() {
+	ch := l.peek()
+	if ch == '"' {
+		l.lexGoString()
+	} else if ch == '\'' {
+		l.lexGoRune()
+	} else if ch == '`' {
+		l.lexGoRawString()
+	} else if ch == '/' && l.peekNext() == '/' {
+		l.lexGoLineComment()
+	} else if ch == '/' && l.peekNext() == '*' {
+		l.lexGoBlockComment()
+	} else {
+		l.skipGoRun()
+	}
+}
+
+// FindJSX returns the offset of the first JSX element in the Go code src,
+// skipping literals and comments, or -1 if there is none.
+func FindJSX(src string) int {
+	l := New(src)
+	for l.more() {
+		if l.isJSXStart() {
+			return l.pos
+		}
+		l.skipGo()
+	}
+	return -1
+}
+
+// FindJSXEnd returns the offset just past the JSX element or fragment that
+// starts at offset start of src, or -1 if it is not closed. The elements in
+// raw have raw content.
+func FindJSXEnd(src string, start int, raw map[string]bool) int {
+	l := New(src[start:])
+	l.SetRawElements(raw)
+	for {
+		tok := l.NextToken()
+		if tok.Type == TOKEN_EOF || tok.Type == TOKEN_ERROR {
+			return -1
+		}
+		if !l.inJSX {
+			return start + l.pos
+		}
+	}
+}
+
 // isJSXStart checks if we're at the start of a JSX element.
-// JSX starts with < followed by an identifier or > (fragment).
 func (l *Lexer) isJSXStart() bool {
-	if l.peek() != '<' {
+	l.ensure(l.pos + 2 + utf8.UTFMax)
+	return IsJSXStart(l.input, l.pos)
+}
+
+// IsJSXStart reports whether JSX starts at offset i of the Go code src:
+// a '<' followed by an identifier or '>' (a fragment), where Go expects an
+// operand. After an operand on the same line, '<' is the less-than operator,
+// so a <b && c> d is a comparison, and after another '<' it ends a shift, as
+// in 1<<n. Channel operators, as in <-ch and chan<- int, are never JSX.
+func IsJSXStart(src string, i int) bool {
+	if i+1 >= len(src) || src[i] != '<' {
 		return false
 	}
-
-	// Look ahead past the <
-	nextPos := l.pos + 1
-	if nextPos >= len(l.input) {
+	if i > 0 && src[i-1] == '<' {
 		return false
 	}
 
-	next := rune(l.input[nextPos])
-
-	// Fragment: <>
-	if next == '>' {
-		return true
+	next, _ := utf8.DecodeRuneInString(src[i+1:])
+	switch {
+	case next == '>':
+		// Fragment: <>
+	case next == '/':
+		// Closing fragment: </>
+		if i+2 >= len(src) || src[i+2] != '>' {
+			return false
+		}
+	case !isIdentStart(next):
+		return false
 	}
+	return !followsOperand(src[:i])
+}
 
-	// Closing fragment: </>
-	if next == '/' {
-		if nextPos+1 < len(l.input) && l.input[nextPos+1] == '>' {
-			return true
+// followsOperand reports whether the Go code src ends in an operand on its
+// last line: a name other than a keyword, a literal, a closing bracket, or
+// ++ or --. A line break after them would end the statement, as would a
+// /* comment */ holding one; other comments after them are skipped.
+func followsOperand(src string) bool {
+	src = strings.TrimRight(src, " \t\r")
+	for strings.HasSuffix(src, "*/") {
+		open := strings.LastIndex(src, "/*")
+		if open < 0 || strings.Contains(src[open:], "\n") {
+			return false
 		}
+		src = strings.TrimRight(src[:open], " \t\r")
 	}
-
-	// Element: <identifier or <Identifier
-	if isIdentStart(next) {
+	if src == "" {
+		return false
+	}
+	switch src[len(src)-1] {
+	case '\n':
+		return false
+	case ')', ']', '}', '"', '\'', '`':
 		return true
+	case '+', '-':
+		return strings.HasSuffix(src, "++") || strings.HasSuffix(src, "--")
 	}
+	word := src[len(strings.TrimRightFunc(src, isIdentChar)):]
+	return word != "" && !goKeywords[word]
+}
 
-	return false
+// goKeywords are the Go keywords, which an operand may follow.
+var goKeywords = map[string]bool{
+	"break": true, "case": true, "chan": true, "const": true, "continue": true,
+	"default": true, "defer": true, "else": true, "fallthrough": true, "for": true,
+	"func": true, "go": true, "goto": true, "if": true, "import": true,
+	"interface": true, "map": true, "package": true, "range": true, "return": true,
+	"select": true, "struct": true, "switch": true, "type": true, "var": true,
 }
 
 // lexJSX handles lexing within JSX context.
 func (l *Lexer) lexJSX() Token {
+	if l.rawTag != "" {
+		if tok, ok := l.lexRawText(); ok {
+			return tok
+		}
+	}
 	l.skipWhitespaceInTag()
 
-	if l.pos >= len(l.input) {
+	if !l.more() {
 		return l.makeToken(TOKEN_EOF, "")
 	}
 
@@ -163,6 +355,9 @@ func (l *Lexer) lexJSX() Token {
 		l.advance()
 		wasClosingTag := l.inClosingTag
 		wasSelfClosing := l.sawSlash
+		if l.inTag && !wasClosingTag && !wasSelfClosing && l.rawElements[l.openTag] {
+			l.rawTag = l.openTag
+		}
 		l.inTag = false
 		l.inClosingTag = false
 		l.sawSlash = false
@@ -183,19 +378,36 @@ func (l *Lexer) lexJSX() Token {
 
 	// Inside tag: attributes
 	if l.inTag {
+		if ch == '[' && l.afterTagName {
+			l.afterTagName = false
+			return l.lexJSXTypeArgs()
+		}
+		l.afterTagName = false
+
 		if ch == '=' {
 			l.advance()
 			return l.makeToken(TOKEN_JSX_EQUALS, "=")
 		}
+		if ch == '?' && l.peekNext() == '=' {
+			// Optional attribute: name?={value}
+			l.advance()
+			l.advance()
+			return l.makeToken(TOKEN_JSX_EQUALS, "?=")
+		}
 
-		if ch == '"' {
+		if ch == '"' || ch == '\'' || ch == '`' {
 			return l.lexJSXString()
 		}
 
 		if isIdentStart(ch) {
 			if l.needTagName {
 				l.needTagName = false
-				return l.lexJSXIdentifier(TOKEN_JSX_TAG)
+				tok := l.lexJSXIdentifier(TOKEN_JSX_TAG)
+				l.afterTagName = l.peek() == '['
+				if !l.inClosingTag {
+					l.openTag = tok.Value
+				}
+				return tok
 			}
 			return l.lexJSXIdentifier(TOKEN_JSX_ATTR_NAME)
 		}
@@ -219,6 +431,7 @@ func (l *Lexer) lexJSXOpen() Token {
 			Type:   TOKEN_JSX_FRAG_OPEN,
 			Value:  "<>",
 			Offset: start,
+			End:    l.pos,
 			Line:   l.line,
 			Column: l.column - 2,
 		}
@@ -237,6 +450,7 @@ func (l *Lexer) lexJSXOpen() Token {
 				Type:   TOKEN_JSX_FRAG_CLOSE,
 				Value:  "</>",
 				Offset: start,
+				End:    l.pos,
 				Line:   l.line,
 				Column: l.column - 3,
 			}
@@ -249,6 +463,7 @@ func (l *Lexer) lexJSXOpen() Token {
 			Type:   TOKEN_JSX_OPEN,
 			Value:  "</",
 			Offset: start,
+			End:    l.pos,
 			Line:   l.line,
 			Column: l.column - 2,
 		}
@@ -262,19 +477,27 @@ func (l *Lexer) lexJSXOpen() Token {
 		Type:   TOKEN_JSX_OPEN,
 		Value:  "<",
 		Offset: start,
+		End:    l.pos,
 		Line:   l.line,
 		Column: l.column - 1,
 	}
 }
 
 // lexJSXIdentifier lexes a JSX identifier (tag name or attribute name).
+// Both may contain dashes, as in data-id. Tag names may be qualified with
+// dots, as in ui.Button, and attribute names with a namespace, as in
+// xlink:href.
 func (l *Lexer) lexJSXIdentifier(typ TokenType) Token {
 	start := l.pos
 	startLine := l.line
 	startColumn := l.column
 
-	for l.pos < len(l.input) {
+	for l.more() {
 		ch := l.peek()
+		if (ch == '.' && typ == TOKEN_JSX_TAG || ch == ':' && typ == TOKEN_JSX_ATTR_NAME) && isIdentStart(l.peekNext()) {
+			l.advance()
+			continue
+		}
 		if !isIdentChar(ch) && ch != '-' { // Allow hyphens in JSX identifiers
 			break
 		}
@@ -285,39 +508,83 @@ func (l *Lexer) lexJSXIdentifier(typ TokenType) Token {
 		Type:   typ,
 		Value:  l.input[start:l.pos],
 		Offset: start,
+		End:    l.pos,
+		Line:   startLine,
+		Column: startColumn,
+	}
+}
+
+// lexJSXTypeArgs lexes the type arguments of a generic component, as in
+// <List[string]>. Value is the text between the outer brackets.
+func (l *Lexer) lexJSXTypeArgs() Token {
+	start := l.pos
+	startLine := l.line
+	startColumn := l.column
+
+	depth := 0
+	for l.more() {
+		ch := l.peek()
+		l.advance()
+		if ch == '[' {
+			depth++
+		} else if ch == ']' {
+			depth--
+			if depth == 0 {
+				break
+			}
+		}
+	}
+
+	valueEnd := l.pos
+	if depth == 0 {
+		valueEnd-- // Exclude the closing bracket
+	}
+	return Token{
+		Type:   TOKEN_JSX_TYPE_ARGS,
+		Value:  l.input[start+1 : valueEnd],
+		Offset: start,
+		End:    l.pos,
 		Line:   startLine,
 		Column: startColumn,
 	}
 }
 
-// lexJSXString lexes a double-quoted string attribute value.
+// lexJSXString lexes a string attribute value, in double quotes or, as in
+// HTML, single quotes, or a raw string in backquotes, which has no escapes
+// and may span lines. Either way the value is the text between them.
 func (l *Lexer) lexJSXString() Token {
 	start := l.pos
 	startLine := l.line
 	startColumn := l.column
 
-	l.advance() // consume opening "
+	quote := l.peek()
+	l.advance() // consume opening quote
 
-	for l.pos < len(l.input) && l.peek() != '"' {
-		if l.peek() == '\\' && l.peekNext() == '"' {
+	for l.more() && l.peek() != quote {
+		if l.peek() == '\\' && l.peekNext() == quote && quote != '`' {
 			l.advance() // skip backslash
 		}
 		l.advance()
 	}
+	if !l.more() {
+		return l.errorToken(start, startLine, startColumn, "unterminated string")
+	}
 
-	l.advance() // consume closing "
+	l.advance() // consume closing quote
 
 	// Return value without quotes
 	return Token{
 		Type:   TOKEN_JSX_STRING,
 		Value:  l.input[start+1 : l.pos-1],
 		Offset: start,
+		End:    l.pos,
 		Line:   startLine,
 		Column: startColumn,
 	}
 }
 
-// lexJSXExpression lexes a JSX expression {expr}.
+// lexJSXExpression lexes a JSX expression {expr}, or a comment {/* ... */}
+// when the braces hold nothing but comments.
 func (l *Lexer) lexJSXExpression() Token {
 	start := l.pos
 	startLine := l.line
@@ -327,7 +594,7 @@ func (l *Lexer) lexJSXExpression() Token {
 	l.braceDepth = 1
 
 	exprStart := l.pos
-	for l.pos < len(l.input) && l.braceDepth > 0 {
+	for l.more() && l.braceDepth > 0 {
 		ch := l.peek()
 
 		if ch == '{' {
@@ -346,6 +613,12 @@ func (l *Lexer) lexJSXExpression() Token {
 		} else if ch == '`' {
 			l.lexGoRawString()
 			continue
+		} else if ch == '/' && l.peekNext() == '/' {
+			l.lexGoLineComment()
+			continue
+		} else if ch == '/' && l.peekNext() == '*' {
+			l.lexGoBlockComment()
+			continue
 		} else if ch == '<' && l.isJSXStart() {
 			// Nested JSX in expression - include it in the expression
 			l.lexNestedJSX()
@@ -354,15 +627,24 @@ func (l *Lexer) lexJSXExpression() Token {
 
 		l.advance()
 	}
+	if l.braceDepth > 0 {
+		l.braceDepth = 0
+		return l.errorToken(start, startLine, startColumn, "unterminated expression")
+	}
 
 	expr := l.input[exprStart:l.pos]
 	l.advance() // consume closing }
 	l.braceDepth = 0
 
+	typ := TOKEN_JSX_EXPR
+	if isCommentOnly(expr) {
+		typ = TOKEN_JSX_COMMENT
+	}
 	return Token{
-		Type:   TOKEN_JSX_EXPR,
+		Type:   typ,
 		Value:  expr,
 		Offset: start,
+		End:    l.pos,
 		Line:   startLine,
 		Column: startColumn,
 	}
@@ -372,7 +654,7 @@ func (l *Lexer) lexJSXExpression() Token {
 func (l *Lexer) lexNestedJSX() {
 	depth := 0
 
-	for l.pos < len(l.input) {
+	for l.more() {
 		ch := l.peek()
 
 		if ch == '<' {
@@ -386,7 +668,7 @@ func (l *Lexer) lexNestedJSX() {
 					depth--
 				} else {
 					// Closing tag
-					for l.pos < len(l.input) && l.peek() != '>' {
+					for l.more() && l.peek() != '>' {
 						l.advance()
 					}
 					if l.peek() == '>' {
@@ -403,10 +685,20 @@ func (l *Lexer) lexNestedJSX() {
 				// Opening tag
 				l.advance()
 				depth++
+				nameStart := l.pos
+				for l.more() && (isIdentChar(l.peek()) || l.peek() == '-' || l.peek() == '.') {
+					l.advance()
+				}
+				tag := l.input[nameStart:l.pos]
 				// Find the end of the tag
-				for l.pos < len(l.input) {
+				for l.more() {
 					if l.peek() == '>' {
 						l.advance()
+						if l.rawElements[tag] {
+							// Skip the content, which may hold tags and braces
+							l.rawTag = tag
+							l.lexRawText()
+						}
 						break
 					}
 					if l.peek() == '/' && l.peekNext() == '>' {
@@ -430,13 +722,57 @@ func (l *Lexer) lexNestedJSX() {
 	}
 }
 
+// lexRawText lexes the content of a raw element, up to its closing tag or
+// the end of input, as a single token. It reports false for an empty
+// element.
+func (l *Lexer) lexRawText() (Token, bool) {
+	start := l.pos
+	startLine := l.line
+	startColumn := l.column
+	closing := "</" + l.rawTag
+	l.rawTag = ""
+
+	end := -1
+	for i := start; end < 0; {
+		j := strings.Index(l.input[i:], closing)
+		if j < 0 {
+			// The closing tag may start in the part of the input not read yet
+			i = max(i, len(l.input)-len(closing)+1)
+			if !l.fill() {
+				end = len(l.input)
+			}
+			continue
+		}
+		i += j + len(closing)
+		// </scripts> does not close <script>
+		l.ensure(i + utf8.UTFMax)
+		if r, _ := utf8.DecodeRuneInString(l.input[i:]); i == len(l.input) || !isIdentChar(r) && r != '-' && r != '.' {
+			end = i - len(closing)
+		}
+	}
+	if end == start {
+		return Token{}, false
+	}
+	for l.pos < end {
+		l.advance()
+	}
+	return Token{
+		Type:   TOKEN_JSX_RAW_TEXT,
+		Value:  l.input[start:end],
+		Offset: start,
+		End:    end,
+		Line:   startLine,
+		Column: startColumn,
+	}, true
+}
+
 // lexJSXText lexes text content between JSX tags.
 func (l *Lexer) lexJSXText() Token {
 	start := l.pos
 	startLine := l.line
 	startColumn := l.column
 
-	for l.pos < len(l.input) {
+	for l.more() {
 		ch := l.peek()
 		if ch == '<' || ch == '{' || ch == '}' {
 			break
@@ -444,11 +780,18 @@ func (l *Lexer) lexJSXText() Token {
 		l.advance()
 	}
 
+	// A stray '}' outside any expression is plain text; consuming it
+	// guarantees progress on malformed input such as an unclosed element.
+	if l.pos == start && l.more() {
+		l.advance()
+	}
+
 	text := l.input[start:l.pos]
 	return Token{
 		Type:   TOKEN_JSX_TEXT,
 		Value:  text,
 		Offset: start,
+		End:    l.pos,
 		Line:   startLine,
 		Column: startColumn,
 	}
@@ -456,52 +799,97 @@ func (l *Lexer) lexJSXText() Token {
 
 // Helper functions
 
+// peek, peekNext and advance take an ASCII fast path; gox sources are
+// overwhelmingly ASCII and rune decoding dominated lexing time.
+
 func (l *Lexer) peek() rune {
-	if l.pos >= len(l.input) {
+	if pos := l.pos; pos < len(l.input) && l.input[pos] < utf8.RuneSelf {
+		return rune(l.input[pos])
+	}
+	return l.peekRune(l.pos)
+}
+
+// peekRune decodes the rune at pos, returning 0 at end of input.
+func (l *Lexer) peekRune(pos int) rune {
+	if !l.ensure(pos + 1) {
 		return 0
 	}
-	r, _ := utf8.DecodeRuneInString(l.input[l.pos:])
+	l.ensure(pos + utf8.UTFMax)
+	r, _ := utf8.DecodeRuneInString(l.input[pos:])
 	return r
 }
 
 func (l *Lexer) peekNext() rune {
 	if l.pos+1 >= len(l.input) {
-		return 0
+		l.ensure(l.pos + 2)
+		if l.pos+1 >= len(l.input) {
+			return 0
+		}
 	}
-	_, size := utf8.DecodeRuneInString(l.input[l.pos:])
-	r, _ := utf8.DecodeRuneInString(l.input[l.pos+size:])
-	return r
+	size := 1
+	if l.input[l.pos] >= utf8.RuneSelf {
+		l.ensure(l.pos + utf8.UTFMax)
+		_, size = utf8.DecodeRuneInString(l.input[l.pos:])
+	}
+	return l.peekRune(l.pos + size)
 }
 
 func (l *Lexer) peekAt(offset int) rune {
-	pos := l.pos + offset
-	if pos >= len(l.input) {
-		return 0
-	}
-	r, _ := utf8.DecodeRuneInString(l.input[pos:])
-	return r
+	return l.peekRune(l.pos + offset)
 }
 
 func (l *Lexer) advance() {
-	if l.pos >= len(l.input) {
+	if pos := l.pos; pos < len(l.input) && l.input[pos] < utf8.RuneSelf {
+		l.pos++
+		switch l.input[pos] {
+		case '\n':
+			l.line++
+			l.column = 1
+		case '\r':
+			// The \r of a \r\n line break takes no column, so that positions
+			// are the same as with \n line breaks
+			if !l.ensure(l.pos+1) || l.input[l.pos] != '\n' {
+				l.column++
+			}
+		default:
+			l.column++
+		}
 		return
 	}
+	l.advanceRune()
+}
 
-	r, size := utf8.DecodeRuneInString(l.input[l.pos:])
-	if r == '\n' {
-		l.line++
-		l.column = 1
-	} else {
-		l.column++
+// advanceRune is the slow path of advance for multi-byte runes.
+func (l *Lexer) advanceRune() {
+	l.ensure(l.pos + utf8.UTFMax)
+	if l.pos >= len(l.input) {
+		return
 	}
+	_, size := utf8.DecodeRuneInString(l.input[l.pos:])
+	l.column++
 	l.pos += size
 }
 
+// errorToken returns a TOKEN_ERROR for the construct starting at start,
+// which runs to the end of the input. Its Value is the message, and its
+// Offset:End covers the construct's first character.
+func (l *Lexer) errorToken(start, line, column int, msg string) Token {
+	return Token{
+		Type:   TOKEN_ERROR,
+		Value:  msg,
+		Offset: start,
+		End:    start + 1,
+		Line:   line,
+		Column: column,
+	}
+}
+
 func (l *Lexer) makeToken(typ TokenType, value string) Token {
 	return Token{
 		Type:   typ,
 		Value:  value,
 		Offset: l.pos - len(value),
+		End:    l.pos,
 		Line:   l.line,
 		Column: l.column - len(value),
 	}
@@ -511,7 +899,7 @@ func (l *Lexer) skipWhitespaceInTag() {
 	if !l.inTag {
 		return
 	}
-	for l.pos < len(l.input) {
+	for l.more() {
 		ch := l.peek()
 		if ch != ' ' && ch != '\t' && ch != '\n' && ch != '\r' {
 			break
@@ -520,10 +908,30 @@ func (l *Lexer) skipWhitespaceInTag() {
 	}
 }
 
+// skipGoRun advances past the current character and any following run of
+// plain ASCII Go code that cannot start JSX, a literal, or a comment.
+// This keeps long stretches of Go code out of the per-rune slow path.
+func (l *Lexer) skipGoRun() {
+	l.advance()
+	for l.more() {
+		c := l.input[l.pos]
+		if c >= utf8.RuneSelf || c == '<' || c == '"' || c == '\'' || c == '`' || c == '/' || c == '\r' {
+			return
+		}
+		l.pos++
+		if c == '\n' {
+			l.line++
+			l.column = 1
+		} else {
+			l.column++
+		}
+	}
+}
+
 // lexGoString skips over a Go string literal.
 func (l *Lexer) lexGoString() {
 	l.advance() // consume opening "
-	for l.pos < len(l.input) {
+	for l.more() {
 		ch := l.peek()
 		if ch == '"' {
 			l.advance()
@@ -539,7 +947,7 @@ func (l *Lexer) lexGoString() {
 // lexGoRune skips over a Go rune literal.
 func (l *Lexer) lexGoRune() {
 	l.advance() // consume opening '
-	for l.pos < len(l.input) {
+	for l.more() {
 		ch := l.peek()
 		if ch == '\'' {
 			l.advance()
@@ -555,7 +963,7 @@ func (l *Lexer) lexGoRune() {
 // lexGoRawString skips over a Go raw string literal.
 func (l *Lexer) lexGoRawString() {
 	l.advance() // consume opening `
-	for l.pos < len(l.input) {
+	for l.more() {
 		if l.peek() == '`' {
 			l.advance()
 			break
@@ -566,7 +974,7 @@ func (l *Lexer) lexGoRawString() {
 
 // lexGoLineComment skips over a Go line comment.
 func (l *Lexer) lexGoLineComment() {
-	for l.pos < len(l.input) && l.peek() != '\n' {
+	for l.more() && l.peek() != '\n' {
 		l.advance()
 	}
 }
@@ -575,7 +983,7 @@ func (l *Lexer) lexGoLineComment() {
 func (l *Lexer) lexGoBlockComment() {
 	l.advance() // consume /
 	l.advance() // consume *
-	for l.pos < len(l.input) {
+	for l.more() {
 		if l.peek() == '*' && l.peekNext() == '/' {
 			l.advance()
 			l.advance()
@@ -585,6 +993,30 @@ func (l *Lexer) lexGoBlockComment() {
 	}
 }
 
+// isCommentOnly reports whether s holds at least one Go comment and nothing
+// but comments and whitespace.
+func isCommentOnly(s string) bool {
+	sawComment := false
+	for {
+		s = strings.TrimLeft(s, " \t\r\n")
+		switch {
+		case s == "":
+			return sawComment
+		case strings.HasPrefix(s, "//"):
+			_, s, _ = strings.Cut(s, "\n")
+		case strings.HasPrefix(s, "/*"):
+			end := strings.Index(s[2:], "*/")
+			if end < 0 {
+				return false
+			}
+			s = s[2+end+2:]
+		default:
+			return false
+		}
+		sawComment = true
+	}
+}
+
 func isIdentStart(r rune) bool {
 	return unicode.IsLetter(r) || r == '_'
 }
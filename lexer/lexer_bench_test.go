@@ -0,0 +1,56 @@
+package lexer
+
+import (
+	"strings"
+	"testing"
+)
+
+// benchComponent is a representative component mixing Go code, attributes,
+// nested elements, expressions and text.
+const benchComponent = `
+type CardProps struct {
+	Title    string
+	Items    []string
+	Selected int
+}
+
+// Card renders a titled list.
+func Card(props CardProps, children ...gox.VNode) gox.VNode {
+	if props.Title == "" {
+		return gox.Empty()
+	}
+	return <box direction="column" padding={1} style={{"border": "rounded"}}>
+		<text bold>{props.Title}</text>
+		{gox.MapIndex(props.Items, func(i int, item string) gox.VNode {
+			return <text selected={i == props.Selected}>{item}</text>
+		})}
+		<>
+			<text>Footer with some longer static text content</text>
+			{children}
+		</>
+	</box>
+}
+`
+
+// largeSource returns a synthetic .gox file containing n components.
+func largeSource(n int) string {
+	var sb strings.Builder
+	sb.WriteString("package bench\n\nimport \"github.com/germtb/gox\"\n")
+	for i := 0; i < n; i++ {
+		sb.WriteString(benchComponent)
+	}
+	return sb.String()
+}
+
+func BenchmarkLexer(b *testing.B) {
+	src := largeSource(500)
+	b.SetBytes(int64(len(src)))
+	b.ReportAllocs()
+	b.ResetTimer()
+
+	for i := 0; i < b.N; i++ {
+		lex := New(src)
+		for tok := lex.NextToken(); tok.Type != TOKEN_EOF; tok = lex.NextToken() {
+		}
+	}
+}
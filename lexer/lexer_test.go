@@ -1,7 +1,10 @@
 package lexer
 
 import (
+	"strings"
 	"testing"
+
+	"github.com/germtb/gox/diag"
 )
 
 func TestTokenTypeString(t *testing.T) {
@@ -105,6 +108,65 @@ func TestLexElementWithStringAttribute(t *testing.T) {
 	}
 }
 
+func TestLexSingleQuotedAttributes(t *testing.T) {
+	tokens := collectTokens(New(`<p class='container' title='say "hi"' alt='it\'s'>`))
+
+	var values []string
+	for _, tok := range tokens {
+		if tok.Type == TOKEN_JSX_STRING {
+			values = append(values, tok.Value)
+		}
+	}
+	want := []string{"container", `say "hi"`, `it\'s`}
+	if strings.Join(values, "|") != strings.Join(want, "|") {
+		t.Errorf("Expected string values %q, got %q", want, values)
+	}
+	if tok := tokens[4]; tok.Offset != 9 || tok.End != 20 {
+		t.Errorf("Expected 'container' at 9-20, got %d-%d", tok.Offset, tok.End)
+	}
+}
+
+func TestLexRawStringAttribute(t *testing.T) {
+	tokens := collectTokens(New("<p label=`say \"hi\"\n\\'x'` id=\"a\">"))
+
+	assertTokenTypes(t, tokens, []TokenType{
+		TOKEN_JSX_OPEN, TOKEN_JSX_TAG,
+		TOKEN_JSX_ATTR_NAME, TOKEN_JSX_EQUALS, TOKEN_JSX_STRING,
+		TOKEN_JSX_ATTR_NAME, TOKEN_JSX_EQUALS, TOKEN_JSX_STRING,
+		TOKEN_JSX_CLOSE, TOKEN_EOF,
+	})
+	// No escapes, and the string may span lines
+	if want := "say \"hi\"\n\\'x'"; tokens[4].Value != want {
+		t.Errorf("Expected raw value %q, got %q", want, tokens[4].Value)
+	}
+	if id := tokens[5]; id.Line != 2 || id.Column != 7 {
+		t.Errorf("Expected id at 2:7, got %d:%d", id.Line, id.Column)
+	}
+}
+
+func TestLexDashedAndNamespacedAttributes(t *testing.T) {
+	input := `<svg data-testid="x" aria-label={l} xlink:href="#a" a: />`
+
+	tokens := collectTokens(New(input))
+
+	var names []string
+	for _, tok := range tokens {
+		if tok.Type == TOKEN_JSX_ATTR_NAME {
+			names = append(names, tok.Value)
+		}
+	}
+	// A colon not followed by a name is not part of one
+	want := []string{"data-testid", "aria-label", "xlink:href", "a"}
+	if len(names) != len(want) {
+		t.Fatalf("Expected attribute names %q, got %q", want, names)
+	}
+	for i := range want {
+		if names[i] != want[i] {
+			t.Errorf("Expected attribute name %q, got %q", want[i], names[i])
+		}
+	}
+}
+
 func TestLexElementWithExpressionAttribute(t *testing.T) {
 	input := `<box gap={1}>`
 
@@ -130,6 +192,23 @@ func TestLexElementWithExpressionAttribute(t *testing.T) {
 	}
 }
 
+func TestLexOptionalAttribute(t *testing.T) {
+	tokens := collectTokens(New(`<a title?={t}>`))
+
+	assertTokenTypes(t, tokens, []TokenType{
+		TOKEN_JSX_OPEN,      // <
+		TOKEN_JSX_TAG,       // a
+		TOKEN_JSX_ATTR_NAME, // title
+		TOKEN_JSX_EQUALS,    // ?=
+		TOKEN_JSX_EXPR,      // t
+		TOKEN_JSX_CLOSE,     // >
+		TOKEN_EOF,
+	})
+	if tokens[3].Value != "?=" {
+		t.Errorf("Expected '?=', got %q", tokens[3].Value)
+	}
+}
+
 func TestLexElementWithChildren(t *testing.T) {
 	input := `<box>Hello World</box>`
 
@@ -182,6 +261,60 @@ func TestLexElementWithExpressionChild(t *testing.T) {
 	}
 }
 
+func TestLexCommentChild(t *testing.T) {
+	tests := []struct {
+		input string
+		typ   TokenType
+		value string
+	}{
+		{`<p>{/* note */}</p>`, TOKEN_JSX_COMMENT, "/* note */"},
+		{"<p>{ // note\n}</p>", TOKEN_JSX_COMMENT, " // note\n"},
+		{`<p>{/* a */ /* b */}</p>`, TOKEN_JSX_COMMENT, "/* a */ /* b */"},
+		// A brace inside a comment does not end the braces
+		{`<p>{/* } */}</p>`, TOKEN_JSX_COMMENT, "/* } */"},
+		{`<p>{x /* first */}</p>`, TOKEN_JSX_EXPR, "x /* first */"},
+		{`<p>{}</p>`, TOKEN_JSX_EXPR, ""},
+	}
+	for _, tt := range tests {
+		tokens := collectTokens(New(tt.input))
+		assertTokenTypes(t, tokens, []TokenType{
+			TOKEN_JSX_OPEN, TOKEN_JSX_TAG, TOKEN_JSX_CLOSE,
+			tt.typ,
+			TOKEN_JSX_OPEN, TOKEN_JSX_TAG, TOKEN_JSX_CLOSE,
+			TOKEN_EOF,
+		})
+		if tokens[3].Value != tt.value {
+			t.Errorf("%s: value %q, want %q", tt.input, tokens[3].Value, tt.value)
+		}
+	}
+}
+
+func TestLexUnterminated(t *testing.T) {
+	tests := []struct {
+		input  string
+		types  []TokenType
+		value  string
+		offset int
+	}{
+		{`<a href="`, []TokenType{TOKEN_JSX_OPEN, TOKEN_JSX_TAG, TOKEN_JSX_ATTR_NAME, TOKEN_JSX_EQUALS, TOKEN_ERROR}, "unterminated string", 8},
+		{`<a href="/></a>`, []TokenType{TOKEN_JSX_OPEN, TOKEN_JSX_TAG, TOKEN_JSX_ATTR_NAME, TOKEN_JSX_EQUALS, TOKEN_ERROR}, "unterminated string", 8},
+		{`<a href='/"></a>`, []TokenType{TOKEN_JSX_OPEN, TOKEN_JSX_TAG, TOKEN_JSX_ATTR_NAME, TOKEN_JSX_EQUALS, TOKEN_ERROR}, "unterminated string", 8},
+		{`<p>{f(<b>x</b>)</p>`, []TokenType{TOKEN_JSX_OPEN, TOKEN_JSX_TAG, TOKEN_JSX_CLOSE, TOKEN_ERROR}, "unterminated expression", 3},
+	}
+	for _, tt := range tests {
+		lex := New(tt.input)
+		tokens := collectTokens(lex)
+		assertTokenTypes(t, tokens, tt.types)
+		tok := tokens[len(tokens)-1]
+		if tok.Value != tt.value || tok.Offset != tt.offset || tok.Len() != 1 {
+			t.Errorf("%s: error token %+v, want %q at offset %d", tt.input, tok, tt.value, tt.offset)
+		}
+		if tok := lex.NextToken(); tok.Type != TOKEN_EOF {
+			t.Errorf("%s: expected EOF after the error, got %v", tt.input, tok)
+		}
+	}
+}
+
 func TestLexFragment(t *testing.T) {
 	input := `<>Hello</>`
 
@@ -294,6 +427,238 @@ func foo() bool {
 	}
 }
 
+func TestLexComparisonsWithoutSpaces(t *testing.T) {
+	input := `package main
+
+func foo(a, b, c, d int, ch chan int, m map[int]int) bool {
+	v := <-ch
+	if a<b {
+	}
+	_ = m[a]<b || f()<d || a++<b || "x"<s
+	return a <b && c> d
+}
+`
+	tokens := collectTokens(New(input))
+
+	// After an operand, < is the less-than operator
+	assertTokenTypes(t, tokens, []TokenType{TOKEN_GO_CODE, TOKEN_EOF})
+}
+
+// trickyGo is Go code whose '<' is never JSX.
+var trickyGo = []string{
+	// Channels
+	"v := <-ch",
+	"ch <- v",
+	"ch<-v",
+	"go func() { ch<-1 }()",
+	"case <-ch:",
+	"case v := <-done:",
+	"var c chan<- int",
+	"type C <-chan int",
+	"func f() <-chan int { return nil }",
+	"func f() (<-chan int, error) { return nil, nil }",
+	"x := []<-chan int{}",
+	"f(<-ch)",
+	"x := !<-ch",
+	"v := <-time.After(d)",
+	"z := a < <-ch",
+	"x := a<-b",
+	// Comparisons
+	"x := a<b",
+	"if a<b && c>d {\n}",
+	"x := f()<g()",
+	"x := m[k]<v",
+	"x := s.x<y",
+	"for i := 0; i<n; i++ {\n}",
+	"x := 0x1F<y",
+	"x := 1e9<y",
+	"x := 'a'<r",
+	"x := a /* b */ <c",
+	"x := a /* b */ /* c */<d",
+	"x := a <\n\tb",
+	// Shifts
+	"x := 1<<n",
+	"x := 1 <<n",
+	"const big = 1<<iota",
+	"x <<= n",
+	"x<<=n",
+	// Generics
+	"func Less[T cmp.Ordered](a, b T) bool { return a<b }",
+	"func F() List[T] { return List[T]{} }",
+	"func F[K comparable, V any](m map[K]V) iter.Seq2[K, V] { return nil }",
+	"ok := List[T]{}.Len()<n",
+	"ok := T[int]{}.x<y",
+	"ok := Max[int](a, b)<c",
+	"func Recv[T any](ch <-chan T) T { return <-ch }",
+	"func Send[T any](ch chan<- T, v T) { ch<-v }",
+}
+
+func TestLexTrickyGo(t *testing.T) {
+	for _, src := range trickyGo {
+		tokens := collectTokens(New(src))
+		if len(tokens) != 2 || tokens[0].Type != TOKEN_GO_CODE || tokens[0].Value != src {
+			t.Errorf("%q lexed as %v, want Go code", src, tokens)
+		}
+		// JSX after it is still found
+		if got, want := FindJSX(src+"\nx := <b />"), len(src)+6; got != want {
+			t.Errorf("FindJSX after %q = %d, want %d", src, got, want)
+		}
+	}
+}
+
+func TestLexRawElements(t *testing.T) {
+	input := `//gox:raw pre
+package main
+
+var x = <div><script>if (a <b) { go() }</script><pre> {x} </pre><style></style><scripts>{y}</scripts></div>
+`
+	tokens := collectTokens(New(input))
+
+	var raw []string
+	for _, tok := range tokens {
+		if tok.Type == TOKEN_JSX_RAW_TEXT {
+			raw = append(raw, tok.Value)
+		}
+		if tok.Type == TOKEN_JSX_EXPR && tok.Value != "y" {
+			t.Errorf("Unexpected expression %q in raw content", tok.Value)
+		}
+	}
+	want := []string{"if (a <b) { go() }", " {x} "}
+	if len(raw) != len(want) {
+		t.Fatalf("Expected raw text %q, got %q", want, raw)
+	}
+	for i := range want {
+		if raw[i] != want[i] {
+			t.Errorf("Expected raw text %q, got %q", want[i], raw[i])
+		}
+	}
+}
+
+func TestVoidElements(t *testing.T) {
+	src := "// Form fields.\n//gox:void br\n//gox:void\tinput img\npackage form\n\n//gox:void hr\n"
+	got := VoidElements(src)
+	if len(got) != 3 || !got["br"] || !got["input"] || !got["img"] {
+		t.Errorf("VoidElements = %v, want br, input and img", got)
+	}
+	if len(VoidElements("package form\n")) != 0 {
+		t.Error("Expected no void elements by default")
+	}
+}
+
+func TestTokenize(t *testing.T) {
+	src := "package ui\n\nvar x = <a href=\"/\">{f(<b />)}</a>\n"
+	tokens, err := Tokenize(src)
+	if err != nil {
+		t.Fatalf("Tokenize: %v", err)
+	}
+	assertTokenTypes(t, tokens, []TokenType{
+		TOKEN_GO_CODE,
+		TOKEN_JSX_OPEN, TOKEN_JSX_TAG, TOKEN_JSX_ATTR_NAME, TOKEN_JSX_EQUALS, TOKEN_JSX_STRING, TOKEN_JSX_CLOSE,
+		TOKEN_JSX_EXPR,
+		TOKEN_JSX_OPEN, TOKEN_JSX_TAG, TOKEN_JSX_CLOSE,
+		TOKEN_GO_CODE,
+	})
+
+	// The expression splits into Go code and JSX
+	expr := tokens[7]
+	inner := collectTokens(NewRange(src, expr.Offset+1, expr.End-1, expr.Line, expr.Column+1))
+	assertTokenTypes(t, inner, []TokenType{
+		TOKEN_GO_CODE, TOKEN_JSX_OPEN, TOKEN_JSX_TAG, TOKEN_JSX_SLASH, TOKEN_JSX_CLOSE, TOKEN_GO_CODE, TOKEN_EOF,
+	})
+	if inner[1].Column != expr.Column+3 {
+		t.Errorf("nested <b> at column %d, want %d", inner[1].Column, expr.Column+3)
+	}
+
+	tokens, err = Tokenize("var x = <a href=\"/>\n")
+	d, ok := diag.As(err)
+	if !ok || d.Code != diag.Unterminated || d.Line != 1 || d.Column != 17 || len(tokens) != 5 {
+		t.Errorf("Tokenize = %d tokens, %v; want 5 and a %s diagnostic at 1:17", len(tokens), err, diag.Unterminated)
+	}
+}
+
+func TestTokenTypePredicates(t *testing.T) {
+	for typ := TOKEN_EOF; typ <= TOKEN_JSX_RAW_TEXT; typ++ {
+		kinds := 0
+		for _, is := range []bool{typ.IsPunctuation(), typ.IsName(), typ.IsText(), typ.IsGo()} {
+			if is {
+				kinds++
+			}
+		}
+		want := 1
+		if typ == TOKEN_EOF || typ == TOKEN_ERROR {
+			want = 0
+		}
+		if kinds != want {
+			t.Errorf("%v has %d kinds, want %d", typ, kinds, want)
+		}
+		if typ.IsJSX() != (typ != TOKEN_EOF && typ != TOKEN_ERROR && typ != TOKEN_GO_CODE) {
+			t.Errorf("%v: IsJSX = %v", typ, typ.IsJSX())
+		}
+	}
+}
+
+func TestFindJSXEnd(t *testing.T) {
+	raw := RawElements("")
+	tests := []struct {
+		src  string
+		want int
+	}{
+		{"<br /> + x", 6},
+		{"<>a</> + x", 6},
+		{"<a onClick={func() { if a > b {} }}>x</a>)", 41},
+		{"<script>a </b></script>)", 23},
+		{"<div>", -1},
+	}
+	for _, tt := range tests {
+		if got := FindJSXEnd(tt.src, 0, raw); got != tt.want {
+			t.Errorf("FindJSXEnd(%q) = %d, want %d", tt.src, got, tt.want)
+		}
+	}
+}
+
+func TestFindJSX(t *testing.T) {
+	tests := []struct {
+		src  string
+		want int
+	}{
+		{"<div />", 0},
+		{"return <div />", 7},
+		{"x := <>a</>", 5},
+		{"f(a, <b />)", 5},
+		{"cond && <b />", 8},
+		{"a <b && c> d", -1},
+		{"x.Len() <y", -1},
+		{"1 <b", -1},
+		{"<-ch", -1},
+		{`"<b>" + <i />`, 8},
+		{"// <b>\n<i />", 7},
+		// A line break after an operand ends the statement
+		{"x\n<b />", 2},
+		{"List[T]{} <b", -1},
+		// A comment holding a line break ends the statement too
+		{"x /*\n*/ <b />", 8},
+	}
+	for _, tt := range tests {
+		if got := FindJSX(tt.src); got != tt.want {
+			t.Errorf("FindJSX(%q) = %d, want %d", tt.src, got, tt.want)
+		}
+	}
+}
+
+func TestLexCRLFPositions(t *testing.T) {
+	src := "package ui\n\nvar x = <div\n\tid=\"a\">\n\thi {y}\n\t<script>a\n</script>\n</div>\n"
+	want := collectTokens(New(src))
+	got := collectTokens(New(strings.ReplaceAll(src, "\n", "\r\n")))
+	if len(got) != len(want) {
+		t.Fatalf("%d tokens with \\r\\n line breaks, want %d", len(got), len(want))
+	}
+	for i := range want {
+		if got[i].Type != want[i].Type || got[i].Line != want[i].Line || got[i].Column != want[i].Column {
+			t.Errorf("token %d = %v at %d:%d, want %v at %d:%d", i, got[i], got[i].Line, got[i].Column, want[i], want[i].Line, want[i].Column)
+		}
+	}
+}
+
 func TestLexComponentElement(t *testing.T) {
 	input := `<MyComponent foo="bar" />`
 
@@ -319,6 +684,70 @@ func TestLexComponentElement(t *testing.T) {
 	}
 }
 
+func TestLexGenericComponentElement(t *testing.T) {
+	input := `<Table[string, map[K]V] rows={r}></Table>`
+
+	lex := New(input)
+
+	tokens := collectTokens(lex)
+
+	expected := []TokenType{
+		TOKEN_JSX_OPEN,      // <
+		TOKEN_JSX_TAG,       // Table
+		TOKEN_JSX_TYPE_ARGS, // [string, map[K]V]
+		TOKEN_JSX_ATTR_NAME, // rows
+		TOKEN_JSX_EQUALS,    // =
+		TOKEN_JSX_EXPR,      // r
+		TOKEN_JSX_CLOSE,     // >
+		TOKEN_JSX_OPEN,      // </
+		TOKEN_JSX_TAG,       // Table
+		TOKEN_JSX_CLOSE,     // >
+		TOKEN_EOF,
+	}
+
+	assertTokenTypes(t, tokens, expected)
+
+	if tokens[2].Value != "string, map[K]V" {
+		t.Errorf("Expected type args 'string, map[K]V', got %q", tokens[2].Value)
+	}
+	if got := input[tokens[2].Offset:tokens[2].End]; got != "[string, map[K]V]" {
+		t.Errorf("Expected token source '[string, map[K]V]', got %q", got)
+	}
+}
+
+func TestLexQualifiedComponentElement(t *testing.T) {
+	input := `<ui.Button label="x"></ui.Button>. <p>a.b</p>`
+
+	tokens := collectTokens(New(input))
+
+	expected := []TokenType{
+		TOKEN_JSX_OPEN,      // <
+		TOKEN_JSX_TAG,       // ui.Button
+		TOKEN_JSX_ATTR_NAME, // label
+		TOKEN_JSX_EQUALS,    // =
+		TOKEN_JSX_STRING,    // "x"
+		TOKEN_JSX_CLOSE,     // >
+		TOKEN_JSX_OPEN,      // </
+		TOKEN_JSX_TAG,       // ui.Button
+		TOKEN_JSX_CLOSE,     // >
+		TOKEN_GO_CODE,       // .
+		TOKEN_JSX_OPEN,      // <
+		TOKEN_JSX_TAG,       // p
+		TOKEN_JSX_CLOSE,     // >
+		TOKEN_JSX_TEXT,      // a.b
+		TOKEN_JSX_OPEN,      // </
+		TOKEN_JSX_TAG,       // p
+		TOKEN_JSX_CLOSE,     // >
+		TOKEN_EOF,
+	}
+
+	assertTokenTypes(t, tokens, expected)
+
+	if tokens[1].Value != "ui.Button" || tokens[7].Value != "ui.Button" {
+		t.Errorf("Expected tags 'ui.Button', got %q and %q", tokens[1].Value, tokens[7].Value)
+	}
+}
+
 // Helper functions
 
 func collectTokens(lex *Lexer) []Token {
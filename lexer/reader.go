@@ -0,0 +1,114 @@
+package lexer
+
+import (
+	"io"
+	"strings"
+)
+
+// readSize is the number of bytes a Lexer reading from an io.Reader asks
+// for at a time.
+const readSize = 64 << 10
+
+// lookbehind is the number of bytes before a token that a Lexer reading
+// from an io.Reader keeps, for IsJSXStart to tell whether the Go code
+// before a '<' ends in an operand.
+const lookbehind = 4 << 10
+
+// NewReader creates a Lexer that reads its input from r as it lexes, so
+// that a large file need not be held in memory, or read first, in full. It
+// keeps the input from shortly before the current token, and as much after
+// it as the token needs; token Values share that memory. Offsets are in
+// the whole input, as for New.
+//
+// The header of the input, up to its package clause, is read first for
+// its raw element directives. Reading stops at the first error; Err
+// returns it once NextToken has returned TOKEN_EOF.
+func NewReader(r io.Reader) *Lexer {
+	l := &Lexer{line: 1, column: 1, r: r}
+	for !endsHeader(l.input) && l.fill() {
+	}
+	l.rawElements = RawElements(l.input)
+	return l
+}
+
+// Err returns the error, other than io.EOF, that ended reading the input
+// of a Lexer created with NewReader, if any.
+func (l *Lexer) Err() error {
+	if l.err == io.EOF {
+		return nil
+	}
+	return l.err
+}
+
+// endsHeader reports whether src holds a whole line starting with the
+// package clause, after which no directives are read.
+func endsHeader(src string) bool {
+	for {
+		line, rest, ok := strings.Cut(src, "\n")
+		if !ok {
+			return false
+		}
+		if strings.HasPrefix(strings.TrimSpace(line), "package ") {
+			return true
+		}
+		src = rest
+	}
+}
+
+// more reports whether input remains at pos, reading more if it can.
+func (l *Lexer) more() bool {
+	return l.pos < len(l.input) || l.fill()
+}
+
+// ensure reads until input holds n bytes or the reader ends, and reports
+// whether it holds n bytes.
+func (l *Lexer) ensure(n int) bool {
+	for len(l.input) < n {
+		if !l.fill() {
+			return false
+		}
+	}
+	return true
+}
+
+// fill appends the next read from the reader to input, and reports whether
+// it added anything. Offsets in input stay valid.
+func (l *Lexer) fill() bool {
+	if l.r == nil || l.err != nil {
+		return false
+	}
+	if l.chunk == nil {
+		l.chunk = make([]byte, readSize)
+	}
+	for {
+		n, err := l.r.Read(l.chunk)
+		if n > 0 {
+			l.buf.Write(l.chunk[:n])
+			l.input = l.buf.String()
+		}
+		if err != nil {
+			l.err = err
+		}
+		if n > 0 || err != nil {
+			return n > 0
+		}
+	}
+}
+
+// compact drops the input more than lookbehind bytes before pos, once
+// there is at least a read's worth of it, so that the memory held stays
+// proportional to the token being lexed. It is called between tokens: no
+// offset into input is kept across it but pos.
+func (l *Lexer) compact() {
+	drop := l.pos - lookbehind
+	if drop < readSize {
+		return
+	}
+	rest := l.input[drop:]
+	l.buf = strings.Builder{}
+	l.buf.Grow(len(rest) + readSize)
+	l.buf.WriteString(rest)
+	l.input = l.buf.String()
+	l.base += drop
+	l.pos -= drop
+}
@@ -0,0 +1,87 @@
+package lexer
+
+import (
+	"errors"
+	"io"
+	"strings"
+	"testing"
+	"testing/iotest"
+)
+
+// readAll returns the tokens of l up to and including TOKEN_EOF, going on
+// past errors as the parser does.
+func readAll(l *Lexer) []Token {
+	var tokens []Token
+	for {
+		tok := l.NextToken()
+		tokens = append(tokens, tok)
+		if tok.Type == TOKEN_EOF {
+			return tokens
+		}
+	}
+}
+
+func TestNewReader(t *testing.T) {
+	sources := []string{
+		"",
+		"package ui\n",
+		"//gox:raw pre\npackage ui\n\nvar x = <pre>{a} <b></pre>\nvar y = <script>if (a </scripts> b) {}</script>\n",
+		"package ui\n\nvar x = <div a=\"é\" b={f(<i>ü</i>)}>héllo {/* c */}<></></div> < y\n",
+		"package ui\n\nvar x = <List[string] items={s} />\n",
+		"package ui\n\nvar x = <div a=\"unterminated",
+		largeSource(200),
+	}
+	for _, src := range sources {
+		want := readAll(New(src))
+		readers := []struct {
+			name string
+			r    io.Reader
+		}{
+			{"one byte", iotest.OneByteReader(strings.NewReader(src))},
+			{"whole", strings.NewReader(src)},
+		}
+		for _, r := range readers {
+			l := NewReader(r.r)
+			got := readAll(l)
+			if len(got) != len(want) {
+				t.Errorf("%.30q, %s reads: %d tokens, want %d", src, r.name, len(got), len(want))
+				continue
+			}
+			for i := range want {
+				if got[i] != want[i] {
+					t.Errorf("%.30q, %s reads: token %d = %+v, want %+v", src, r.name, i, got[i], want[i])
+					break
+				}
+			}
+			if err := l.Err(); err != nil {
+				t.Errorf("Err() = %v", err)
+			}
+		}
+	}
+}
+
+func TestNewReaderBoundsMemory(t *testing.T) {
+	src := largeSource(500)
+	l := NewReader(strings.NewReader(src))
+	longest := 0
+	for tok := l.NextToken(); tok.Type != TOKEN_EOF; tok = l.NextToken() {
+		longest = max(longest, len(l.input))
+	}
+	if limit := 2*readSize + lookbehind; longest > limit {
+		t.Errorf("held %d bytes of a %d byte input, want at most %d", longest, len(src), limit)
+	}
+}
+
+func TestNewReaderError(t *testing.T) {
+	errRead := errors.New("disk on fire")
+	l := NewReader(iotest.TimeoutReader(iotest.OneByteReader(strings.NewReader("package ui\n"))))
+	readAll(l)
+	if l.Err() == nil {
+		t.Error("Expected the read error")
+	}
+
+	l = NewReader(iotest.ErrReader(errRead))
+	if tok := l.NextToken(); tok.Type != TOKEN_EOF || !errors.Is(l.Err(), errRead) {
+		t.Errorf("NextToken = %v, Err = %v; want EOF and %v", tok, l.Err(), errRead)
+	}
+}
@@ -18,7 +18,7 @@ const (
 	TOKEN_JSX_SLASH      // /
 	TOKEN_JSX_TAG        // element/component name
 	TOKEN_JSX_ATTR_NAME  // attribute name
-	TOKEN_JSX_EQUALS     // =
+	TOKEN_JSX_EQUALS     // = or ?=, after the name of an optional attribute
 	TOKEN_JSX_STRING     // "value"
 	TOKEN_JSX_LBRACE     // {
 	TOKEN_JSX_RBRACE     // }
@@ -26,6 +26,9 @@ const (
 	TOKEN_JSX_EXPR       // expression content inside {}
 	TOKEN_JSX_FRAG_OPEN  // <>
 	TOKEN_JSX_FRAG_CLOSE // </>
+	TOKEN_JSX_TYPE_ARGS  // [T] after a component name
+	TOKEN_JSX_COMMENT    // comments alone inside {}: {/* comment */}
+	TOKEN_JSX_RAW_TEXT   // content of a raw element, such as <script>
 )
 
 // String returns a string representation of the token type.
@@ -63,18 +66,75 @@ func (t TokenType) String() string {
 		return "JSX_FRAG_OPEN"
 	case TOKEN_JSX_FRAG_CLOSE:
 		return "JSX_FRAG_CLOSE"
+	case TOKEN_JSX_TYPE_ARGS:
+		return "JSX_TYPE_ARGS"
+	case TOKEN_JSX_COMMENT:
+		return "JSX_COMMENT"
+	case TOKEN_JSX_RAW_TEXT:
+		return "JSX_RAW_TEXT"
 	default:
 		return fmt.Sprintf("TOKEN(%d)", t)
 	}
 }
 
+// IsJSX reports whether t is a token of JSX, rather than Go code, the end
+// of the input or an error.
+func (t TokenType) IsJSX() bool {
+	return t >= TOKEN_JSX_OPEN && t <= TOKEN_JSX_RAW_TEXT
+}
+
+// IsPunctuation reports whether t is JSX punctuation: <, >, /, =, a brace,
+// <> or </>.
+func (t TokenType) IsPunctuation() bool {
+	switch t {
+	case TOKEN_JSX_OPEN, TOKEN_JSX_CLOSE, TOKEN_JSX_SLASH, TOKEN_JSX_EQUALS,
+		TOKEN_JSX_LBRACE, TOKEN_JSX_RBRACE, TOKEN_JSX_FRAG_OPEN, TOKEN_JSX_FRAG_CLOSE:
+		return true
+	}
+	return false
+}
+
+// IsName reports whether t names an element, in an opening or closing tag,
+// or an attribute.
+func (t TokenType) IsName() bool {
+	return t == TOKEN_JSX_TAG || t == TOKEN_JSX_ATTR_NAME
+}
+
+// IsText reports whether t's Value is literal text: an attribute string, or
+// the text content of an element.
+func (t TokenType) IsText() bool {
+	return t == TOKEN_JSX_STRING || t == TOKEN_JSX_TEXT || t == TOKEN_JSX_RAW_TEXT
+}
+
+// IsGo reports whether t's Value is Go source: code between JSX, an
+// {expression}, the type arguments of a component, or the comments of a
+// {/* comment */}.
+func (t TokenType) IsGo() bool {
+	switch t {
+	case TOKEN_GO_CODE, TOKEN_JSX_EXPR, TOKEN_JSX_TYPE_ARGS, TOKEN_JSX_COMMENT:
+		return true
+	}
+	return false
+}
+
 // Token represents a lexical token.
+//
+// Value is a slice of the lexer input, not a copy. For most tokens it spans
+// exactly Offset:End; string and expression tokens exclude their delimiters
+// from Value while Offset:End covers the full source text of the token. An
+// error token's Value is its message.
 type Token struct {
 	Type   TokenType
 	Value  string
-	Offset int
-	Line   int
-	Column int
+	Offset int // Byte offset of the first character of the token
+	End    int // Byte offset just past the last character of the token
+	Line   int // 1-indexed line of the first character
+	Column int // 1-indexed column of the first character, in runes
+}
+
+// Len returns the length in bytes of the token's source text.
+func (t Token) Len() int {
+	return t.End - t.Offset
 }
 
 // String returns a string representation of the token.
@@ -0,0 +1,31 @@
+package lexer
+
+import "github.com/germtb/gox/diag"
+
+// Tokenize returns the tokens of the gox file src, without the final
+// TOKEN_EOF, for tools such as syntax highlighters. The directives of src
+// set its raw elements, as for New.
+//
+// The Go code between JSX is a single TOKEN_GO_CODE token, and that of an
+// {expression} a single TOKEN_JSX_EXPR token, JSX nested in it included.
+// To split an expression further, lex it with NewRange: its Go code
+// starts at the token's Offset+1.
+//
+// If src holds an unterminated string or expression, Tokenize returns the
+// tokens before it and a *diag.Diagnostic with the code diag.Unterminated.
+func Tokenize(src string) ([]Token, error) {
+	l := New(src)
+	var tokens []Token
+	for {
+		tok := l.NextToken()
+		switch tok.Type {
+		case TOKEN_EOF:
+			return tokens, nil
+		case TOKEN_ERROR:
+			d := diag.New(diag.Unterminated, "", tok.Line, tok.Column, "%s", tok.Value)
+			d.EndLine, d.EndColumn = tok.Line, tok.Column+tok.Len()
+			return tokens, d
+		}
+		tokens = append(tokens, tok)
+	}
+}
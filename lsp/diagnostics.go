@@ -0,0 +1,173 @@
+package lsp
+
+import (
+	"encoding/json"
+
+	"github.com/germtb/gox/diag"
+)
+
+// DiagnosticSource is the source reported for diagnostics produced by gox
+// itself rather than gopls.
+const DiagnosticSource = "gox"
+
+// setParseError records the outcome of parsing a .gox file. Callers must hold p.mu.
+func (p *Proxy) setParseError(goxPath string, err error) {
+	if err == nil {
+		delete(p.parseErrors, goxPath)
+		return
+	}
+	d, ok := diag.As(err)
+	if !ok {
+		d = &diag.Diagnostic{Message: err.Error()}
+	}
+	p.parseErrors[goxPath] = d
+}
+
+// hasParseError reports whether the last parse of a .gox file failed.
+func (p *Proxy) hasParseError(goxPath string) bool {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+	return p.parseErrors[goxPath] != nil
+}
+
+// publishDiagnostics sends the diagnostics for a .gox file to the editor: the
+// last diagnostics gopls reported for it plus any gox parse error.
+func (p *Proxy) publishDiagnostics(goxPath string) {
+	p.mu.RLock()
+	diagnostics := p.mergeDiagnostics(goxPath, p.goplsDiags[goxPath])
+	p.mu.RUnlock()
+
+	notification, _ := json.Marshal(map[string]any{
+		"jsonrpc": "2.0",
+		"method":  "textDocument/publishDiagnostics",
+		"params": map[string]any{
+			"uri":         pathToURI(goxPath),
+			"diagnostics": diagnostics,
+		},
+	})
+	if err := p.writeToEditor(notification); err != nil {
+		p.log.Printf("Write error to editor: %v", err)
+	}
+}
+
+// interceptDiagnostics remembers the diagnostics gopls publishes for a .gox
+// file and adds the gox parse error, if any, so a gopls update never hides it.
+// params must already be rewritten to the .gox URI.
+func (p *Proxy) interceptDiagnostics(params map[string]any) {
+	uri, ok := params["uri"].(string)
+	if !ok || !isGoxURI(uri) {
+		return
+	}
+	goxPath := uriToPath(uri)
+	diagnostics, _ := params["diagnostics"].([]any)
+
+	p.mu.Lock()
+	p.goplsDiags[goxPath] = diagnostics
+	merged := p.mergeDiagnostics(goxPath, diagnostics)
+	p.mu.Unlock()
+
+	params["diagnostics"] = merged
+}
+
+// mergeDiagnostics appends the parse error for goxPath to diagnostics.
+// Callers must hold p.mu.
+func (p *Proxy) mergeDiagnostics(goxPath string, diagnostics []any) []any {
+	merged := append([]any{}, diagnostics...)
+	if d := p.parseErrors[goxPath]; d != nil {
+		merged = append(merged, lspDiagnostic(d, p.fileContents[goxPath]))
+	}
+	return merged
+}
+
+// lspDiagnostic converts a gox diagnostic in the .gox text to an LSP
+// Diagnostic. The code lets editors point users at "gox explain <code>".
+// Without an end, the range covers a single character; the hint, if any,
+// follows the message.
+func lspDiagnostic(d *diag.Diagnostic, text string) map[string]any {
+	line, col := 0, 0
+	if d.Line > 0 {
+		line = d.Line - 1
+	}
+	if d.Column > 0 {
+		col = d.Column - 1
+	}
+	endLine, endCol := line, col+1
+	if d.EndLine > 0 && d.EndColumn > 0 {
+		endLine, endCol = d.EndLine-1, d.EndColumn-1
+	}
+	char := utf16Column(lineText(text, line), col)
+	endChar := utf16Column(lineText(text, endLine), endCol)
+	message := d.Message
+	if d.Hint != "" {
+		message += "\n" + d.Hint
+	}
+	result := map[string]any{
+		"range": map[string]any{
+			"start": map[string]any{"line": line, "character": char},
+			"end":   map[string]any{"line": endLine, "character": endChar},
+		},
+		"severity": 1, // Error
+		"source":   DiagnosticSource,
+		"message":  message,
+	}
+	if d.Code != "" {
+		result["code"] = string(d.Code)
+	}
+	return result
+}
+
+// quickFix converts the fix of a gox diagnostic in the .gox text at uri to
+// an LSP CodeAction.
+func quickFix(uri string, d *diag.Diagnostic, text string) map[string]any {
+	fix := d.Fix
+	edit := map[string]any{
+		"range": map[string]any{
+			"start": lspPosition(text, fix.Line, fix.Column),
+			"end":   lspPosition(text, fix.EndLine, fix.EndColumn),
+		},
+		"newText": fix.Text,
+	}
+	return map[string]any{
+		"title":       fix.Message,
+		"kind":        "quickfix",
+		"diagnostics": []any{lspDiagnostic(d, text)},
+		"isPreferred": true,
+		"edit": map[string]any{
+			"changes": map[string]any{uri: []any{edit}},
+		},
+	}
+}
+
+// lspPosition converts a 1-indexed line and rune column in text to an LSP
+// Position.
+func lspPosition(text string, line, column int) map[string]any {
+	line, column = max(line-1, 0), max(column-1, 0)
+	return map[string]any{"line": line, "character": utf16Column(lineText(text, line), column)}
+}
+
+// linesMeet reports whether the lines of d meet those of rng, an LSP Range
+// from a request, or rng is missing.
+func linesMeet(d *diag.Diagnostic, rng any) bool {
+	r, ok := rng.(map[string]any)
+	if !ok {
+		return true
+	}
+	start, _ := r["start"].(map[string]any)
+	end, _ := r["end"].(map[string]any)
+	startLine, ok1 := start["line"].(float64)
+	endLine, ok2 := end["line"].(float64)
+	if !ok1 || !ok2 {
+		return true
+	}
+	first, last := d.Line-1, max(d.EndLine, d.Line)-1
+	if d.Fix != nil {
+		// The fix may be on lines before the diagnostic
+		first = min(first, d.Fix.Line-1)
+	}
+	return first <= int(endLine) && int(startLine) <= last
+}
+
+// isGoxURI reports whether uri names a .gox file.
+func isGoxURI(uri string) bool {
+	return len(uri) > 4 && uri[len(uri)-4:] == ".gox"
+}
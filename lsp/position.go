@@ -0,0 +1,98 @@
+package lsp
+
+import (
+	"strings"
+	"unicode/utf8"
+)
+
+// LSP positions count UTF-16 code units, while gox positions and source
+// maps count runes. The helpers here convert columns between the two on a
+// line of a document's text.
+
+// lineText returns the 0-indexed line of text, without its line break, or
+// "" past the end.
+func lineText(text string, line int) string {
+	for ; line > 0; line-- {
+		i := strings.IndexByte(text, '\n')
+		if i < 0 {
+			return ""
+		}
+		text = text[i+1:]
+	}
+	if i := strings.IndexByte(text, '\n'); i >= 0 {
+		text = text[:i]
+	}
+	return strings.TrimSuffix(text, "\r")
+}
+
+// utf16Column returns the UTF-16 column of the 0-indexed rune column col of
+// line. Columns past the end of line count one unit per rune.
+func utf16Column(line string, col int) int {
+	units := 0
+	for _, r := range line {
+		if col == 0 {
+			return units
+		}
+		units += utf16Len(r)
+		col--
+	}
+	return units + col
+}
+
+// runeColumn returns the rune column of the 0-indexed UTF-16 column units
+// of line, the inverse of utf16Column. A column inside a surrogate pair is
+// that of its rune.
+func runeColumn(line string, units int) int {
+	col := 0
+	for _, r := range line {
+		if units <= 0 {
+			return col
+		}
+		units -= utf16Len(r)
+		if units < 0 {
+			return col
+		}
+		col++
+	}
+	return col + units
+}
+
+// utf16Len returns the number of UTF-16 code units that encode r.
+func utf16Len(r rune) int {
+	if r >= 0x10000 && r <= utf8.MaxRune {
+		return 2
+	}
+	return 1
+}
+
+// rangeOffset returns the byte offset in text of pos, an LSP position as
+// decoded from JSON. A character past the end of its line is the end of the
+// line, and a line past the end of text is the end of text.
+func rangeOffset(text string, pos any) (int, bool) {
+	m, ok := pos.(map[string]any)
+	if !ok {
+		return 0, false
+	}
+	line, okLine := m["line"].(float64)
+	units, okChar := m["character"].(float64)
+	if !okLine || !okChar || line < 0 || units < 0 {
+		return 0, false
+	}
+	start := 0
+	for n := int(line); n > 0; n-- {
+		i := strings.IndexByte(text[start:], '\n')
+		if i < 0 {
+			return len(text), true
+		}
+		start += i + 1
+	}
+	rest := lineText(text[start:], 0)
+	col := runeColumn(rest, int(units))
+	for i := range rest {
+		if col == 0 {
+			return start + i, true
+		}
+		col--
+	}
+	return start + len(rest), true
+}
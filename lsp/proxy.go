@@ -14,9 +14,13 @@ import (
 	"strconv"
 	"strings"
 	"sync"
+	"unicode/utf8"
 
+	"github.com/germtb/gox/ast"
+	"github.com/germtb/gox/diag"
 	"github.com/germtb/gox/formatter"
 	"github.com/germtb/gox/generator"
+	"github.com/germtb/gox/internal/atomicfile"
 	"github.com/germtb/gox/parser"
 )
 
@@ -27,13 +31,54 @@ type Proxy struct {
 	goplsOut     io.ReadCloser
 	sourceMaps   map[string]*generator.SourceMap // .gox path -> source map
 	fileContents map[string]string               // .gox path -> current content
+	trees        map[string]*ast.GoxFile         // .gox path -> syntax tree of the content, if it parses
+	generated    map[string]string               // .gox path -> generated Go content
+	previews     map[string]bool                 // .gox paths with an open preview document
+	parseErrors  map[string]*diag.Diagnostic     // .gox path -> parse error from the last generation
+	goplsDiags   map[string][]any                // .gox path -> diagnostics last published by gopls
 	tempDir      string
+	goplsPath    string   // gopls binary or name to look up in PATH; found by findGopls if empty
+	goplsArgs    []string // Flags for gopls, before the serve command
+	version      string   // gox version recorded in the provenance header of generated files
+	lineDirs     bool     // Interleave //line directives in generated files
+	selfClose    bool     // Format empty elements self-closing
 	mu           sync.RWMutex
 	log          *log.Logger
+
+	editor   io.Writer  // Destination for messages to the editor (stdout)
+	editorMu sync.Mutex // Serializes writes to the editor
+}
+
+// PreviewScheme is the URI scheme of the read-only virtual documents that
+// show the generated Go code for a .gox file, e.g. gox-generated:///path/app.gox.
+const PreviewScheme = "gox-generated"
+
+// Options configures how the proxy runs gopls.
+type Options struct {
+	// Gopls is the gopls binary, or a name looked up in PATH.
+	// Default: gopls from PATH or a common install location.
+	Gopls string
+
+	// GoplsArgs are flags passed to gopls before the serve command, such
+	// as -rpc.trace or -remote=auto.
+	GoplsArgs []string
+
+	// Version is the gox version recorded in the provenance header of the
+	// files the proxy generates, as gox generate records it, so they are
+	// not reported as stale.
+	Version string
+
+	// LineDirectives interleaves //line directives naming the .gox files in
+	// the generated files, as gox generate -line-directives does.
+	LineDirectives bool
+
+	// NormalizeSelfClosing formats empty elements self-closing, as
+	// gox fmt -self-closing does.
+	NormalizeSelfClosing bool
 }
 
-// New creates a new LSP proxy.
-func New() (*Proxy, error) {
+// New creates a new LSP proxy. opts may be nil.
+func New(opts *Options) (*Proxy, error) {
 	tempDir, err := os.MkdirTemp("", "gox-lsp-*")
 	if err != nil {
 		return nil, fmt.Errorf("creating temp dir: %w", err)
@@ -45,38 +90,54 @@ func New() (*Proxy, error) {
 	if err != nil {
 		// Fall back to stderr
 		log.Printf("gox-lsp: couldn't create log file %s: %v, using stderr", logPath, err)
-		return &Proxy{
-			sourceMaps:   make(map[string]*generator.SourceMap),
-			fileContents: make(map[string]string),
-			tempDir:      tempDir,
-			log:          log.New(os.Stderr, "[gox-lsp] ", log.LstdFlags|log.Lshortfile),
-		}, nil
+		return newProxy(tempDir, log.New(os.Stderr, "[gox-lsp] ", log.LstdFlags|log.Lshortfile)).configure(opts), nil
 	}
 
 	logger := log.New(logFile, "[gox-lsp] ", log.LstdFlags|log.Lshortfile)
 	logger.Printf("Starting gox LSP proxy, temp dir: %s", tempDir)
 
+	return newProxy(tempDir, logger).configure(opts), nil
+}
+
+// configure applies opts, which may be nil, and returns p.
+func (p *Proxy) configure(opts *Options) *Proxy {
+	if opts != nil {
+		p.goplsPath = opts.Gopls
+		p.goplsArgs = opts.GoplsArgs
+		p.version = opts.Version
+		p.lineDirs = opts.LineDirectives
+		p.selfClose = opts.NormalizeSelfClosing
+	}
+	return p
+}
+
+// newProxy creates a Proxy with initialized caches.
+func newProxy(tempDir string, logger *log.Logger) *Proxy {
 	return &Proxy{
 		sourceMaps:   make(map[string]*generator.SourceMap),
 		fileContents: make(map[string]string),
+		trees:        make(map[string]*ast.GoxFile),
+		generated:    make(map[string]string),
+		previews:     make(map[string]bool),
+		parseErrors:  make(map[string]*diag.Diagnostic),
+		goplsDiags:   make(map[string][]any),
 		tempDir:      tempDir,
 		log:          logger,
-	}, nil
+		editor:       os.Stdout,
+	}
 }
 
 // Run starts the proxy, reading from stdin and writing to stdout.
 func (p *Proxy) Run() error {
-	// Find gopls - check common locations
-	goplsPath := findGopls()
-	if goplsPath == "" {
-		return fmt.Errorf("gopls not found. Install with: go install golang.org/x/tools/gopls@latest")
+	goplsPath, err := p.findGopls()
+	if err != nil {
+		return err
 	}
-
-	p.log.Printf("Found gopls at: %s", goplsPath)
+	args := p.goplsCommandLine()
+	p.log.Printf("Found gopls at: %s, running with %q", goplsPath, args)
 
 	// Start gopls
-	p.gopls = exec.Command(goplsPath, "serve")
-	var err error
+	p.gopls = exec.Command(goplsPath, args...)
 	p.goplsIn, err = p.gopls.StdinPipe()
 	if err != nil {
 		return fmt.Errorf("gopls stdin: %w", err)
@@ -102,7 +163,7 @@ func (p *Proxy) Run() error {
 	}()
 
 	go func() {
-		p.proxyFromGopls(os.Stdout)
+		p.proxyFromGopls()
 		done <- nil
 	}()
 
@@ -113,6 +174,28 @@ func (p *Proxy) Run() error {
 	return nil
 }
 
+// findGopls returns the configured gopls, or else looks for one.
+func (p *Proxy) findGopls() (string, error) {
+	if p.goplsPath == "" {
+		path := findGopls()
+		if path == "" {
+			return "", fmt.Errorf("gopls not found. Install with: go install golang.org/x/tools/gopls@latest, or pass -gopls")
+		}
+		return path, nil
+	}
+	path, err := exec.LookPath(p.goplsPath)
+	if err != nil {
+		return "", fmt.Errorf("gopls %s: %w", p.goplsPath, err)
+	}
+	return path, nil
+}
+
+// goplsCommandLine returns the arguments gopls runs with: the configured
+// flags, then the serve command.
+func (p *Proxy) goplsCommandLine() []string {
+	return append(append([]string(nil), p.goplsArgs...), "serve")
+}
+
 // findGopls looks for gopls in PATH and common locations.
 func findGopls() string {
 	// Try PATH first
@@ -156,7 +239,7 @@ func (p *Proxy) proxyToGopls(r io.Reader) {
 		// Check if we should handle this request ourselves
 		if response := p.handleRequestDirectly(msg); response != nil {
 			// Write response directly to editor (stdout)
-			if err := writeMessage(os.Stdout, response); err != nil {
+			if err := p.writeToEditor(response); err != nil {
 				p.log.Printf("Write error to editor: %v", err)
 			}
 			continue
@@ -175,7 +258,7 @@ func (p *Proxy) proxyToGopls(r io.Reader) {
 }
 
 // proxyFromGopls reads LSP messages from gopls and forwards to the editor.
-func (p *Proxy) proxyFromGopls(w io.Writer) {
+func (p *Proxy) proxyFromGopls() {
 	p.log.Printf("Started reading from gopls")
 	reader := bufio.NewReader(p.goplsOut)
 	for {
@@ -194,7 +277,7 @@ func (p *Proxy) proxyFromGopls(w io.Writer) {
 		rewritten := p.rewriteToGox(msg)
 
 		// Forward to editor
-		if err := writeMessage(w, rewritten); err != nil {
+		if err := p.writeToEditor(rewritten); err != nil {
 			p.log.Printf("Write error to editor: %v", err)
 			fmt.Fprintf(os.Stderr, "gox-lsp: editor write error: %v\n", err)
 			return
@@ -202,6 +285,14 @@ func (p *Proxy) proxyFromGopls(w io.Writer) {
 	}
 }
 
+// writeToEditor sends a message to the editor. Responses handled by the proxy,
+// notifications and forwarded gopls messages share the same stream.
+func (p *Proxy) writeToEditor(msg []byte) error {
+	p.editorMu.Lock()
+	defer p.editorMu.Unlock()
+	return writeMessage(p.editor, msg)
+}
+
 // rewriteToGo rewrites a message from editor, translating .gox to .go.
 func (p *Proxy) rewriteToGo(msg []byte) []byte {
 	var obj map[string]any
@@ -260,6 +351,12 @@ func (p *Proxy) rewriteToGox(msg []byte) []byte {
 	p.rewriteURIs(obj, false)
 	p.rewritePositions(obj)
 
+	if obj["method"] == "textDocument/publishDiagnostics" {
+		if params, ok := obj["params"].(map[string]any); ok {
+			p.interceptDiagnostics(params)
+		}
+	}
+
 	result, _ := json.Marshal(obj)
 	return result
 }
@@ -290,7 +387,10 @@ func (p *Proxy) handleDidOpen(msg map[string]any) {
 	p.mu.Unlock()
 
 	// Generate .go file and get the content
-	goContent := p.generateAndCache(uri, text)
+	goContent := p.generateAndCache(uri, text, nil)
+	if p.hasParseError(goxPath) {
+		p.publishDiagnostics(goxPath)
+	}
 	if goContent != "" {
 		// Replace the text content with generated Go code
 		textDoc["text"] = goContent
@@ -322,10 +422,14 @@ func (p *Proxy) handleDidChange(msg map[string]any) {
 
 	goxPath := uriToPath(uri)
 
-	// For simplicity, we only handle full sync (no range) or take the last change.
-	// The last change in contentChanges should contain the full text for full sync,
-	// or the final state after all incremental changes.
-	var text string
+	// Apply the changes in order: a change without a range replaces the
+	// text, and one with a range edits the text before it, which is then
+	// reparsed only around the edits
+	p.mu.RLock()
+	text, known := p.fileContents[goxPath]
+	p.mu.RUnlock()
+	var edits []parser.TextEdit
+	updated, replaced := false, false
 	for _, c := range changes {
 		change, ok := c.(map[string]any)
 		if !ok {
@@ -337,27 +441,44 @@ func (p *Proxy) handleDidChange(msg map[string]any) {
 			continue
 		}
 
-		// If there's no range, it's a full sync - use this text directly
-		if _, hasRange := change["range"].(map[string]any); !hasRange {
-			text = changeText
+		r, hasRange := change["range"].(map[string]any)
+		if !hasRange {
+			text, edits, known = changeText, nil, true
+			updated, replaced = true, true
+			continue
+		}
+		start, okStart := rangeOffset(text, r["start"])
+		end, okEnd := rangeOffset(text, r["end"])
+		if !known || !okStart || !okEnd || end < start {
+			p.log.Printf("Ignoring incremental change to unknown content of %s", goxPath)
+			return
 		}
-		// For incremental changes, we skip updating the cache since it's complex
-		// and can cause corruption. The formatting will read from disk.
+		text = text[:start] + changeText + text[end:]
+		edits = append(edits, parser.TextEdit{Start: start, End: end, Text: changeText})
+		updated = true
 	}
 
-	// Only update cache if we got a full sync
-	if text != "" {
+	if replaced {
+		edits = nil // Not edits of the content the tree is of
+	}
+
+	if updated {
 		p.mu.Lock()
 		p.fileContents[goxPath] = text
 		p.mu.Unlock()
 
-		goContent := p.generateAndCache(uri, text)
+		hadParseError := p.hasParseError(goxPath)
+		goContent := p.generateAndCache(uri, text, edits)
+		if hadParseError || p.hasParseError(goxPath) {
+			p.publishDiagnostics(goxPath)
+		}
 		if goContent != "" {
 			// Replace all changes with a single full-sync change containing generated Go code
 			params["contentChanges"] = []any{
 				map[string]any{"text": goContent},
 			}
 			p.log.Printf("Replaced didChange content with generated Go (%d bytes)", len(goContent))
+			p.notifyPreviewChanged(goxPath, goContent)
 		}
 	}
 }
@@ -377,14 +498,22 @@ func (p *Proxy) handleDidClose(msg map[string]any) {
 		return
 	}
 
+	goxPath := uriToPath(uri)
 	p.mu.Lock()
-	delete(p.sourceMaps, uriToPath(uri))
+	delete(p.sourceMaps, goxPath)
+	delete(p.trees, goxPath)
+	delete(p.generated, goxPath)
+	delete(p.previews, goxPath)
+	delete(p.parseErrors, goxPath)
+	delete(p.goplsDiags, goxPath)
 	p.mu.Unlock()
 }
 
 // generateAndCache parses .gox, generates .go, and caches the source map.
-// Returns the generated Go content, or empty string on error.
-func (p *Proxy) generateAndCache(uri, text string) string {
+// edits, if any, are those that made text of the content before, whose tree
+// is reparsed around them. Returns the generated Go content, or empty string
+// on error.
+func (p *Proxy) generateAndCache(uri, text string, edits []parser.TextEdit) string {
 	goxPath := uriToPath(uri)
 
 	// Lock during the entire generation to prevent races
@@ -394,14 +523,23 @@ func (p *Proxy) generateAndCache(uri, text string) string {
 	p.log.Printf("Generating .go for: %s (%d bytes)", goxPath, len(text))
 
 	// Parse
-	file, err := parser.Parse(goxPath, []byte(text))
+	var file *ast.GoxFile
+	var err error
+	if old := p.trees[goxPath]; old != nil && edits != nil {
+		file, err = parser.Reparse(old, edits, []byte(text))
+	} else {
+		file, err = parser.Parse(goxPath, []byte(text))
+	}
+	p.setParseError(goxPath, err)
 	if err != nil {
+		delete(p.trees, goxPath)
 		p.log.Printf("Parse error: %v", err)
 		return ""
 	}
+	p.trees[goxPath] = file
 
 	// Generate
-	output, sourceMap, err := generator.Generate(file, nil)
+	output, sourceMap, err := p.generate(file, goxPath)
 	if err != nil {
 		p.log.Printf("Generate error: %v", err)
 		return ""
@@ -415,28 +553,31 @@ func (p *Proxy) generateAndCache(uri, text string) string {
 		p.log.Printf("Mkdir error: %v", err)
 		return ""
 	}
-	if err := os.WriteFile(goPath, output, 0644); err != nil {
+	if err := atomicfile.WriteFile(goPath, output, 0644); err != nil {
 		p.log.Printf("Write error: %v", err)
 		return ""
 	}
 
 	p.log.Printf("Generated: %s -> %s (%d bytes)", goxPath, goPath, len(output))
 
-	// Cache source map
+	// Cache source map and output
 	p.sourceMaps[goxPath] = sourceMap
+	p.generated[goxPath] = string(output)
 
 	return string(output)
 }
 
-// goxToGoPath converts a .gox path to the generated .go path.
-// The .go file is placed next to the .gox file for same-package context.
+// goxToGoPath converts a .gox path to the generated .go path, named with the
+// suffix the .goxsuffix file of its directory sets and placed in the tree a
+// .goxoutdir file sets, as gox generate does. Without a .goxoutdir file the
+// .go file is placed next to the .gox file for same-package context.
 // Test files get special handling: foo_test.gox → foo_gox_test.go
 func (p *Proxy) goxToGoPath(goxPath string) string {
-	base := strings.TrimSuffix(goxPath, ".gox")
-	if strings.HasSuffix(base, "_test") {
-		return strings.TrimSuffix(base, "_test") + "_gox_test.go"
+	goPath, err := generator.OutputPath(goxPath)
+	if err != nil {
+		p.log.Printf("Output path error: %v", err)
 	}
-	return base + "_gox.go"
+	return goPath
 }
 
 // rewriteURIs rewrites file URIs in a message.
@@ -451,7 +592,7 @@ func (p *Proxy) rewriteURIs(obj any, toGo bool) {
 						goxPath := uriToPath(uri)
 						goPath := p.goxToGoPath(goxPath)
 						v[key] = pathToURI(goPath)
-					} else if !toGo && (strings.HasSuffix(uri, "_gox.go") || strings.HasSuffix(uri, "_gox_test.go")) {
+					} else if !toGo && strings.HasSuffix(uri, ".go") {
 						// Find original .gox file from source map
 						goPath := uriToPath(uri)
 						p.mu.RLock()
@@ -485,20 +626,21 @@ func (p *Proxy) translatePositionsToGo(obj any) {
 				goxPath := uriToPath(uri)
 				p.mu.RLock()
 				sm := p.sourceMaps[goxPath]
+				goxText, goText := p.fileContents[goxPath], p.generated[goxPath]
 				p.mu.RUnlock()
 
 				if sm != nil {
 					// Translate position field
 					if pos, ok := v["position"].(map[string]any); ok {
-						p.translatePositionToGoLine(pos, sm)
+						p.translatePositionToGo(pos, sm, goxText, goText)
 					}
 					// Translate range field
 					if rng, ok := v["range"].(map[string]any); ok {
 						if start, ok := rng["start"].(map[string]any); ok {
-							p.translatePositionToGoLine(start, sm)
+							p.translatePositionToGo(start, sm, goxText, goText)
 						}
 						if end, ok := rng["end"].(map[string]any); ok {
-							p.translatePositionToGoLine(end, sm)
+							p.translatePositionToGo(end, sm, goxText, goText)
 						}
 					}
 				}
@@ -516,9 +658,10 @@ func (p *Proxy) translatePositionsToGo(obj any) {
 	}
 }
 
-// translatePositionToGoLine translates a position using line-level mapping.
-// We find any mapping on the source line and use its target line, preserving relative column.
-func (p *Proxy) translatePositionToGoLine(pos map[string]any, sm *generator.SourceMap) {
+// translatePositionToGo translates a position in the .gox text goxText to
+// the generated goText. A position the source map has no column for keeps
+// its column on the target line of any mapping on its line.
+func (p *Proxy) translatePositionToGo(pos map[string]any, sm *generator.SourceMap, goxText, goText string) {
 	line, ok1 := pos["line"].(float64)
 	char, ok2 := pos["character"].(float64)
 	if !ok1 || !ok2 {
@@ -526,6 +669,13 @@ func (p *Proxy) translatePositionToGoLine(pos map[string]any, sm *generator.Sour
 	}
 
 	srcLine := uint32(line)
+	srcCol := uint32(runeColumn(lineText(goxText, int(line)), int(char)))
+	if tgt, found := sm.TargetPositionFromSource(srcLine, srcCol); found {
+		tgtChar := utf16Column(lineText(goText, int(tgt.Line)), int(tgt.Column))
+		p.log.Printf("Position translate: %d:%d -> %d:%d", srcLine, int(char), tgt.Line, tgtChar)
+		pos["line"], pos["character"] = float64(tgt.Line), float64(tgtChar)
+		return
+	}
 
 	// Find any mapping on this line to get the target line
 	targetLine, found := sm.FindTargetLine(srcLine)
@@ -544,20 +694,21 @@ func (p *Proxy) rewritePositions(obj any) {
 			goxPath := uriToPath(uri)
 			p.mu.RLock()
 			sm := p.sourceMaps[goxPath]
+			goxText, goText := p.fileContents[goxPath], p.generated[goxPath]
 			p.mu.RUnlock()
 
 			if sm != nil {
-				// Rewrite position using line mapping
+				// Rewrite position using the source map
 				if pos, ok := v["position"].(map[string]any); ok {
-					p.rewritePositionLine(pos, sm)
+					p.rewritePosition(pos, sm, goxText, goText)
 				}
-				// Rewrite range using line mapping
+				// Rewrite range using the source map
 				if rng, ok := v["range"].(map[string]any); ok {
 					if start, ok := rng["start"].(map[string]any); ok {
-						p.rewritePositionLine(start, sm)
+						p.rewritePosition(start, sm, goxText, goText)
 					}
 					if end, ok := rng["end"].(map[string]any); ok {
-						p.rewritePositionLine(end, sm)
+						p.rewritePosition(end, sm, goxText, goText)
 					}
 				}
 			}
@@ -574,8 +725,10 @@ func (p *Proxy) rewritePositions(obj any) {
 	}
 }
 
-// rewritePositionLine rewrites a position using line-level mapping from .go back to .gox.
-func (p *Proxy) rewritePositionLine(pos map[string]any, sm *generator.SourceMap) {
+// rewritePosition rewrites a position in the generated goText back to the
+// .gox text goxText, at the closest mapped character at or before it on
+// its line. Positions on lines without mappings are left alone.
+func (p *Proxy) rewritePosition(pos map[string]any, sm *generator.SourceMap, goxText, goText string) {
 	line, ok1 := pos["line"].(float64)
 	char, ok2 := pos["character"].(float64)
 	if !ok1 || !ok2 {
@@ -583,11 +736,17 @@ func (p *Proxy) rewritePositionLine(pos map[string]any, sm *generator.SourceMap)
 	}
 
 	tgtLine := uint32(line)
-	if srcLine, found := sm.FindSourceLine(tgtLine); found {
-		pos["line"] = float64(srcLine)
-		// Keep column as-is for now
-		p.log.Printf("Response line translate: %d -> %d (col %d)", tgtLine, srcLine, int(char))
+	if _, found := sm.FindSourceLine(tgtLine); !found {
+		return
 	}
+	tgtCol := uint32(runeColumn(lineText(goText, int(line)), int(char)))
+	src, found := sm.SourcePositionFromTarget(tgtLine, tgtCol)
+	if !found {
+		return
+	}
+	srcChar := utf16Column(lineText(goxText, int(src.Line)), int(src.Column))
+	p.log.Printf("Response position translate: %d:%d -> %d:%d", tgtLine, int(char), src.Line, srcChar)
+	pos["line"], pos["character"] = float64(src.Line), float64(srcChar)
 }
 
 // LSP message helpers
@@ -632,20 +791,6 @@ func writeMessage(w io.Writer, body []byte) error {
 	return err
 }
 
-func uriToPath(uri string) string {
-	if strings.HasPrefix(uri, "file://") {
-		return strings.TrimPrefix(uri, "file://")
-	}
-	return uri
-}
-
-func pathToURI(path string) string {
-	if !strings.HasPrefix(path, "file://") {
-		return "file://" + path
-	}
-	return path
-}
-
 // Close cleans up resources.
 func (p *Proxy) Close() error {
 	if p.gopls != nil && p.gopls.Process != nil {
@@ -677,6 +822,11 @@ func (p *Proxy) handleRequestDirectly(msg []byte) []byte {
 		return p.handleCodeAction(obj)
 	}
 
+	// Handle generated-code preview documents
+	if method == "gox/generatedDocument" || method == "workspace/textDocumentContent" {
+		return p.handleGeneratedDocument(obj)
+	}
+
 	return nil
 }
 
@@ -731,13 +881,9 @@ func (p *Proxy) handleFormatting(req map[string]any) []byte {
 	content := string(data)
 
 	// Parse and format
-	file, err := parser.Parse(goxPath, []byte(content))
-	if err != nil {
-		p.log.Printf("Parse error during formatting: %v", err)
-		return p.makeErrorResponse(id, -32603, "Parse error: "+err.Error())
-	}
-
-	formatted, err := formatter.Format(file, nil)
+	fmtOpts := formatter.DefaultOptions()
+	fmtOpts.NormalizeSelfClosing = p.selfClose
+	formatted, err := formatter.Source(data, fmtOpts)
 	if err != nil {
 		p.log.Printf("Format error: %v", err)
 		return p.makeErrorResponse(id, -32603, "Format error: "+err.Error())
@@ -752,7 +898,7 @@ func (p *Proxy) handleFormatting(req map[string]any) []byte {
 	// Count lines in original content
 	lines := strings.Split(content, "\n")
 	endLine := len(lines) - 1
-	endChar := len(lines[endLine])
+	endChar := utf16Column(lines[endLine], utf8.RuneCountInString(lines[endLine]))
 
 	// Return a single edit that replaces the entire file
 	edit := map[string]any{
@@ -793,7 +939,8 @@ func (p *Proxy) makeErrorResponse(id any, code int, message string) []byte {
 }
 
 // handleCodeAction handles textDocument/codeAction requests for .gox files.
-// For now, we return an empty array (no code actions available).
+// The fix of the parse error of the file, if it has one and its lines meet
+// those of the requested range, is returned as a quick fix.
 func (p *Proxy) handleCodeAction(req map[string]any) []byte {
 	id := req["id"]
 	params, ok := req["params"].(map[string]any)
@@ -820,8 +967,142 @@ func (p *Proxy) handleCodeAction(req map[string]any) []byte {
 		return nil // Let gopls handle non-.gox files
 	}
 
-	p.log.Printf("Handling codeAction for %s (returning empty)", uri)
+	p.log.Printf("Handling codeAction for %s", uri)
+
+	goxPath := uriToPath(uri)
+	p.mu.RLock()
+	d, text := p.parseErrors[goxPath], p.fileContents[goxPath]
+	p.mu.RUnlock()
+
+	actions := []any{}
+	if d != nil && d.Fix != nil && linesMeet(d, params["range"]) {
+		actions = append(actions, quickFix(uri, d, text))
+	}
+	return p.makeSuccessResponse(id, actions)
+}
+
+// handleGeneratedDocument serves the read-only preview of the generated Go code
+// for a .gox file. It accepts either the custom gox/generatedDocument request
+// (params.textDocument.uri is a .gox or preview URI) or the standard
+// workspace/textDocumentContent request for a gox-generated:// URI.
+// Once requested, the preview is kept in sync via gox/generatedDocumentChanged
+// notifications.
+func (p *Proxy) handleGeneratedDocument(req map[string]any) []byte {
+	id := req["id"]
+	params, ok := req["params"].(map[string]any)
+	if !ok {
+		return p.makeErrorResponse(id, -32602, "Invalid params")
+	}
+
+	uri, _ := params["uri"].(string)
+	if textDoc, ok := params["textDocument"].(map[string]any); ok {
+		uri, _ = textDoc["uri"].(string)
+	}
+
+	var goxPath string
+	switch {
+	case strings.HasPrefix(uri, PreviewScheme+":"):
+		goxPath = previewURIToPath(uri)
+	case strings.HasSuffix(uri, ".gox"):
+		goxPath = uriToPath(uri)
+	default:
+		if req["method"] == "workspace/textDocumentContent" {
+			return nil // Not ours, let gopls handle it
+		}
+		return p.makeErrorResponse(id, -32602, "Not a .gox document: "+uri)
+	}
+
+	content, err := p.generatedContent(goxPath)
+	if err != nil {
+		p.log.Printf("Generated preview error: %v", err)
+		return p.makeErrorResponse(id, -32603, err.Error())
+	}
+
+	p.mu.Lock()
+	p.previews[goxPath] = true
+	p.mu.Unlock()
+
+	p.log.Printf("Serving generated preview for %s (%d bytes)", goxPath, len(content))
+	return p.makeSuccessResponse(id, map[string]any{
+		"uri":  previewURI(goxPath),
+		"text": content,
+	})
+}
+
+// generatedContent returns the generated Go code for a .gox file, using the
+// cached output of the open buffer when available and falling back to disk.
+func (p *Proxy) generatedContent(goxPath string) (string, error) {
+	p.mu.RLock()
+	content, ok := p.generated[goxPath]
+	p.mu.RUnlock()
+	if ok {
+		return content, nil
+	}
+
+	data, err := os.ReadFile(goxPath)
+	if err != nil {
+		return "", fmt.Errorf("reading %s: %w", goxPath, err)
+	}
+	file, err := parser.Parse(goxPath, data)
+	if err != nil {
+		return "", fmt.Errorf("parse error: %w", err)
+	}
+	output, _, err := p.generate(file, goxPath)
+	if err != nil {
+		return "", fmt.Errorf("generate error: %w", err)
+	}
+	return string(output), nil
+}
 
-	// Return empty array - no code actions available yet
-	return p.makeSuccessResponse(id, []any{})
+// generate generates the Go code of a parsed .gox file. Go syntax errors are
+// not failures here: the output is used anyway, and gopls reports them
+// through the source map as it does any other error.
+func (p *Proxy) generate(file *ast.GoxFile, goxPath string) ([]byte, *generator.SourceMap, error) {
+	output, sourceMap, err := generator.Generate(file, p.generatorOptions(goxPath))
+	if d, ok := diag.As(err); ok && d.Code == diag.GoSyntax {
+		err = nil
+	}
+	return output, sourceMap, err
+}
+
+// generatorOptions returns the generator options for a .gox file, as gox
+// generate sets them: the runtime package of its directory, the version and,
+// if enabled, //line directives relative to the generated file. An
+// unreadable .goxruntime is logged and the default runtime is used.
+func (p *Proxy) generatorOptions(goxPath string) *generator.Options {
+	dir := filepath.Dir(goxPath)
+	pkg, err := generator.LookupRuntime(dir)
+	if err != nil {
+		p.log.Printf("Runtime lookup error: %v", err)
+	}
+	opts := &generator.Options{RuntimePackage: pkg, Version: p.version}
+	if p.lineDirs {
+		if rel, err := filepath.Rel(filepath.Dir(p.goxToGoPath(goxPath)), goxPath); err == nil {
+			opts.LineDirectives = rel
+		}
+	}
+	return opts
+}
+
+// notifyPreviewChanged tells the editor that the generated preview for a
+// .gox file has new content. Only files with an open preview are notified.
+func (p *Proxy) notifyPreviewChanged(goxPath, content string) {
+	p.mu.RLock()
+	open := p.previews[goxPath]
+	p.mu.RUnlock()
+	if !open {
+		return
+	}
+
+	notification, _ := json.Marshal(map[string]any{
+		"jsonrpc": "2.0",
+		"method":  "gox/generatedDocumentChanged",
+		"params": map[string]any{
+			"uri":  previewURI(goxPath),
+			"text": content,
+		},
+	})
+	if err := p.writeToEditor(notification); err != nil {
+		p.log.Printf("Write error to editor: %v", err)
+	}
 }
@@ -4,11 +4,18 @@ import (
 	"bufio"
 	"bytes"
 	"encoding/json"
+	"fmt"
 	"io"
 	"log"
+	"os"
+	"path/filepath"
+	"reflect"
 	"strings"
 	"testing"
+	"unicode/utf16"
 
+	"github.com/germtb/gox/ast"
+	"github.com/germtb/gox/diag"
 	"github.com/germtb/gox/generator"
 )
 
@@ -17,7 +24,31 @@ func testProxy() *Proxy {
 	return &Proxy{
 		sourceMaps:   make(map[string]*generator.SourceMap),
 		fileContents: make(map[string]string),
+		trees:        make(map[string]*ast.GoxFile),
+		generated:    make(map[string]string),
+		previews:     make(map[string]bool),
+		parseErrors:  make(map[string]*diag.Diagnostic),
+		goplsDiags:   make(map[string][]any),
 		log:          log.New(io.Discard, "", 0),
+		editor:       io.Discard,
+	}
+}
+
+func TestGoplsOptions(t *testing.T) {
+	p := testProxy().configure(&Options{Gopls: os.Args[0], GoplsArgs: []string{"-remote=auto", "-rpc.trace"}})
+	if got, want := p.goplsCommandLine(), []string{"-remote=auto", "-rpc.trace", "serve"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("goplsCommandLine() = %q, want %q", got, want)
+	}
+	if path, err := p.findGopls(); err != nil || path != os.Args[0] {
+		t.Errorf("findGopls() = %q, %v; want %q", path, err, os.Args[0])
+	}
+
+	p = testProxy().configure(&Options{Gopls: "/nonexistent/gopls"})
+	if _, err := p.findGopls(); err == nil {
+		t.Error("expected an error for a missing gopls")
+	}
+	if got := testProxy().configure(nil).goplsCommandLine(); !reflect.DeepEqual(got, []string{"serve"}) {
+		t.Errorf("default goplsCommandLine() = %q, want [serve]", got)
 	}
 }
 
@@ -28,6 +59,7 @@ func TestUriToPath(t *testing.T) {
 	}{
 		{"file:///path/to/file.gox", "/path/to/file.gox"},
 		{"file:///Users/test/app.gox", "/Users/test/app.gox"},
+		{"file:///Users/test/my%20app/app.gox", "/Users/test/my app/app.gox"},
 		{"/already/a/path.gox", "/already/a/path.gox"},
 	}
 
@@ -48,6 +80,7 @@ func TestPathToUri(t *testing.T) {
 	}{
 		{"/path/to/file.gox", "file:///path/to/file.gox"},
 		{"/Users/test/app.gox", "file:///Users/test/app.gox"},
+		{"/Users/test/my app/app.gox", "file:///Users/test/my%20app/app.gox"},
 		{"file:///already/uri.gox", "file:///already/uri.gox"},
 	}
 
@@ -61,6 +94,64 @@ func TestPathToUri(t *testing.T) {
 	}
 }
 
+func TestWindowsURIs(t *testing.T) {
+	tests := []struct {
+		uri  string
+		path string
+		back string // URI for path, if it differs from uri
+	}{
+		{"file:///C:/src/app.gox", `C:\src\app.gox`, ""},
+		// VS Code lower-cases drive letters and escapes the colon
+		{"file:///c%3A/src/app.gox", `C:\src\app.gox`, "file:///C:/src/app.gox"},
+		{"file:///C:/My%20Projects/app.gox", `C:\My Projects\app.gox`, ""},
+		{"file://server/share/app.gox", `\\server\share\app.gox`, ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.uri, func(t *testing.T) {
+			if got := fileURIToPath(tt.uri, true); got != tt.path {
+				t.Errorf("fileURIToPath(%q) = %q, want %q", tt.uri, got, tt.path)
+			}
+			want := tt.back
+			if want == "" {
+				want = tt.uri
+			}
+			if got := pathToFileURI(tt.path, true); got != want {
+				t.Errorf("pathToFileURI(%q) = %q, want %q", tt.path, got, want)
+			}
+		})
+	}
+}
+
+func TestPreviewURIs(t *testing.T) {
+	tests := []struct {
+		path    string
+		windows bool
+		uri     string
+	}{
+		{"/path/to/app.gox", false, "gox-generated:///path/to/app.gox"},
+		{"/src/my app/#1.gox", false, "gox-generated:///src/my%20app/%231.gox"},
+		{`C:\My Projects\app.gox`, true, "gox-generated:///C:/My%20Projects/app.gox"},
+		{`\\server\share\app.gox`, true, "gox-generated://server/share/app.gox"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.path, func(t *testing.T) {
+			uri := pathToPreviewURI(tt.path, tt.windows)
+			if uri != tt.uri {
+				t.Errorf("pathToPreviewURI(%q) = %q, want %q", tt.path, uri, tt.uri)
+			}
+			if got := previewURIToFilePath(uri, tt.windows); got != tt.path {
+				t.Errorf("previewURIToFilePath(%q) = %q, want %q", uri, got, tt.path)
+			}
+		})
+	}
+
+	// VS Code drops the empty authority and escapes the drive colon
+	if got := previewURIToFilePath("gox-generated:/c%3A/My%20Projects/app.gox", true); got != `C:\My Projects\app.gox` {
+		t.Errorf("previewURIToFilePath without authority = %q", got)
+	}
+}
+
 func TestReadMessage(t *testing.T) {
 	tests := []struct {
 		name     string
@@ -212,6 +303,18 @@ func TestGoxToGoPath(t *testing.T) {
 			}
 		})
 	}
+
+	// A .goxoutdir file moves the file into an output tree, as gox generate does
+	dir := t.TempDir()
+	for name, content := range map[string]string{"go.mod": "module example.com/app\n", ".goxoutdir": "gen\n"} {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	goxPath := filepath.Join(dir, "ui", "app.gox")
+	if got, want := p.goxToGoPath(goxPath), filepath.Join(dir, "gen", "ui", "app_gox.go"); got != want {
+		t.Errorf("goxToGoPath(%q) = %q, want %q", goxPath, got, want)
+	}
 }
 
 func TestRewriteURIs(t *testing.T) {
@@ -311,3 +414,381 @@ func TestHandleRequestDirectly(t *testing.T) {
 		}
 	})
 }
+
+func TestHandleGeneratedDocument(t *testing.T) {
+	p := testProxy()
+	p.generated["/path/to/app.gox"] = "package main\n"
+
+	t.Run("serves cached output for gox uri", func(t *testing.T) {
+		msg := []byte(`{"jsonrpc":"2.0","id":1,"method":"gox/generatedDocument","params":{"textDocument":{"uri":"file:///path/to/app.gox"}}}`)
+		result := p.handleRequestDirectly(msg)
+		if result == nil {
+			t.Fatal("Expected response for gox/generatedDocument")
+		}
+
+		var response map[string]any
+		if err := json.Unmarshal(result, &response); err != nil {
+			t.Fatalf("Failed to unmarshal: %v", err)
+		}
+
+		resultObj, ok := response["result"].(map[string]any)
+		if !ok {
+			t.Fatalf("Expected result to be map, got %v", response)
+		}
+		if resultObj["uri"] != "gox-generated:///path/to/app.gox" {
+			t.Errorf("Expected preview URI, got %v", resultObj["uri"])
+		}
+		if resultObj["text"] != "package main\n" {
+			t.Errorf("Expected generated text, got %v", resultObj["text"])
+		}
+		if !p.previews["/path/to/app.gox"] {
+			t.Error("Expected preview to be tracked")
+		}
+	})
+
+	t.Run("serves textDocumentContent for preview uri", func(t *testing.T) {
+		msg := []byte(`{"jsonrpc":"2.0","id":2,"method":"workspace/textDocumentContent","params":{"uri":"gox-generated:///path/to/app.gox"}}`)
+		result := p.handleRequestDirectly(msg)
+		if result == nil {
+			t.Fatal("Expected response for workspace/textDocumentContent")
+		}
+		if !strings.Contains(string(result), `"text":"package main\n"`) {
+			t.Errorf("Expected generated text in response, got %s", result)
+		}
+	})
+
+	t.Run("ignores textDocumentContent for other schemes", func(t *testing.T) {
+		msg := []byte(`{"jsonrpc":"2.0","id":3,"method":"workspace/textDocumentContent","params":{"uri":"other:///x"}}`)
+		if result := p.handleRequestDirectly(msg); result != nil {
+			t.Errorf("Expected nil for foreign scheme, got %s", result)
+		}
+	})
+}
+
+func TestGeneratedContentWithGoSyntaxError(t *testing.T) {
+	p := testProxy()
+	goxPath := filepath.Join(t.TempDir(), "app.gox")
+	src := "package main\n\nfunc App(name string) {\n\treturn <p>{name +}</p>\n}\n"
+	if err := os.WriteFile(goxPath, []byte(src), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	// gopls reports the error, so the generated code is still served
+	content, err := p.generatedContent(goxPath)
+	if err != nil {
+		t.Fatalf("generatedContent: %v", err)
+	}
+	if !strings.Contains(content, "name +") {
+		t.Errorf("Expected the generated code, got %q", content)
+	}
+}
+
+func TestNotifyPreviewChanged(t *testing.T) {
+	p := testProxy()
+	var buf bytes.Buffer
+	p.editor = &buf
+
+	p.notifyPreviewChanged("/path/to/app.gox", "package main\n")
+	if buf.Len() != 0 {
+		t.Fatalf("Expected no notification without open preview, got %q", buf.String())
+	}
+
+	p.previews["/path/to/app.gox"] = true
+	p.notifyPreviewChanged("/path/to/app.gox", "package main\n")
+	if !strings.Contains(buf.String(), `"method":"gox/generatedDocumentChanged"`) {
+		t.Errorf("Expected change notification, got %q", buf.String())
+	}
+}
+
+func TestDidCloseForgetsPreview(t *testing.T) {
+	p := testProxy()
+	p.generated["/path/to/app.gox"] = "package main\n"
+	p.previews["/path/to/app.gox"] = true
+
+	p.handleDidClose(map[string]any{
+		"params": map[string]any{
+			"textDocument": map[string]any{"uri": "file:///path/to/app.gox"},
+		},
+	})
+	if p.previews["/path/to/app.gox"] || p.generated["/path/to/app.gox"] != "" {
+		t.Errorf("Expected the preview and generated code dropped on close, got %v, %q", p.previews, p.generated)
+	}
+}
+
+func TestParseErrorDiagnostics(t *testing.T) {
+	p := testProxy()
+	var buf bytes.Buffer
+	p.editor = &buf
+	uri := "file:///path/to/app.gox"
+
+	open := map[string]any{
+		"params": map[string]any{
+			"textDocument": map[string]any{
+				"uri":  uri,
+				"text": "package main\n\nvar x = <div>\n",
+			},
+		},
+	}
+	p.handleDidOpen(open)
+
+	out := buf.String()
+	if !strings.Contains(out, `"method":"textDocument/publishDiagnostics"`) {
+		t.Fatalf("Expected diagnostics notification, got %q", out)
+	}
+	if !strings.Contains(out, `"code":"`+string(diag.UnclosedElement)+`"`) || !strings.Contains(out, `"source":"gox"`) {
+		t.Errorf("Expected coded gox diagnostic, got %q", out)
+	}
+
+	// gopls diagnostics for the file keep the parse error
+	params := map[string]any{"uri": uri, "diagnostics": []any{map[string]any{"message": "from gopls"}}}
+	p.interceptDiagnostics(params)
+	if got := len(params["diagnostics"].([]any)); got != 2 {
+		t.Errorf("Expected gopls and parse diagnostics, got %d", got)
+	}
+
+	// Fixing the file republishes without the parse error
+	buf.Reset()
+	change := map[string]any{
+		"params": map[string]any{
+			"textDocument":   map[string]any{"uri": uri},
+			"contentChanges": []any{map[string]any{"text": "package main\n\nvar x = <div></div>\n"}},
+		},
+	}
+	p.tempDir = t.TempDir()
+	p.handleDidChange(change)
+
+	out = buf.String()
+	if !strings.Contains(out, "from gopls") || strings.Contains(out, string(diag.UnclosedElement)) {
+		t.Errorf("Expected only gopls diagnostics after fix, got %q", out)
+	}
+}
+
+func TestCodeActionQuickFix(t *testing.T) {
+	p := testProxy()
+	p.editor = &bytes.Buffer{}
+	uri := "file:///path/to/app.gox"
+	p.handleDidOpen(map[string]any{
+		"params": map[string]any{
+			"textDocument": map[string]any{
+				"uri":  uri,
+				"text": "package main\n\nvar x = <div>\n\t<span>é</div>\n",
+			},
+		},
+	})
+
+	codeAction := func(line int) []any {
+		msg := fmt.Sprintf(`{"jsonrpc":"2.0","id":1,"method":"textDocument/codeAction","params":{"textDocument":{"uri":%q},"range":{"start":{"line":%d,"character":0},"end":{"line":%d,"character":0}}}}`, uri, line, line)
+		var response struct{ Result []any }
+		if err := json.Unmarshal(p.handleRequestDirectly([]byte(msg)), &response); err != nil {
+			t.Fatalf("Failed to unmarshal: %v", err)
+		}
+		return response.Result
+	}
+
+	actions := codeAction(3)
+	if len(actions) != 1 {
+		t.Fatalf("Expected one quick fix, got %v", actions)
+	}
+	action := actions[0].(map[string]any)
+	if action["title"] != "insert </span>" || action["kind"] != "quickfix" {
+		t.Errorf("Unexpected action %v", action)
+	}
+	edits := action["edit"].(map[string]any)["changes"].(map[string]any)[uri].([]any)
+	want := map[string]any{
+		"range": map[string]any{
+			"start": map[string]any{"line": float64(3), "character": float64(8)},
+			"end":   map[string]any{"line": float64(3), "character": float64(8)},
+		},
+		"newText": "</span>",
+	}
+	if !reflect.DeepEqual(edits, []any{want}) {
+		t.Errorf("edits = %v, want %v", edits, []any{want})
+	}
+
+	if actions := codeAction(0); len(actions) != 0 {
+		t.Errorf("Expected no quick fix away from the error, got %v", actions)
+	}
+}
+
+func TestLspDiagnosticRange(t *testing.T) {
+	d := diag.New(diag.MismatchedClosingTag, "app.gox", 3, 15, "mismatched closing tag")
+	got := lspDiagnostic(d, "")["range"].(map[string]any)["end"]
+	if want := (map[string]any{"line": 2, "character": 15}); !reflect.DeepEqual(got, want) {
+		t.Errorf("end without EndColumn = %v, want %v", got, want)
+	}
+
+	d.EndLine, d.EndColumn = 3, 19
+	d.Hint = "did you forget to close <div>?"
+	result := lspDiagnostic(d, "")
+	if got, want := result["range"].(map[string]any)["end"], (map[string]any{"line": 2, "character": 18}); !reflect.DeepEqual(got, want) {
+		t.Errorf("end = %v, want %v", got, want)
+	}
+	if got, want := result["message"], "mismatched closing tag\ndid you forget to close <div>?"; got != want {
+		t.Errorf("message = %q, want %q", got, want)
+	}
+}
+
+func TestUTF16Columns(t *testing.T) {
+	line := "a😀é"
+	tests := []struct {
+		runes, units int
+	}{
+		{0, 0},
+		{1, 1},
+		{2, 3}, // After the surrogate pair of 😀
+		{3, 4},
+		{5, 6}, // Past the end
+	}
+	for _, tt := range tests {
+		if got := utf16Column(line, tt.runes); got != tt.units {
+			t.Errorf("utf16Column(%q, %d) = %d, want %d", line, tt.runes, got, tt.units)
+		}
+		if got := runeColumn(line, tt.units); got != tt.runes {
+			t.Errorf("runeColumn(%q, %d) = %d, want %d", line, tt.units, got, tt.runes)
+		}
+	}
+	// Inside a surrogate pair is at its rune
+	if got := runeColumn(line, 2); got != 1 {
+		t.Errorf("runeColumn(%q, 2) = %d, want 1", line, got)
+	}
+
+	text := "one\r\ntwo\nthree"
+	for i, want := range []string{"one", "two", "three", ""} {
+		if got := lineText(text, i); got != want {
+			t.Errorf("lineText(%q, %d) = %q, want %q", text, i, got, want)
+		}
+	}
+}
+
+func TestTranslatePositionsUTF16(t *testing.T) {
+	p := testProxy()
+	uri := pathToURI(filepath.Join(t.TempDir(), "app.gox"))
+	p.handleDidOpen(map[string]any{
+		"params": map[string]any{
+			"textDocument": map[string]any{
+				"uri":  uri,
+				"text": "package main\n\nfunc App(name string) gox.VNode {\n\treturn <p>😀 é {name}</p>\n}\n",
+			},
+		},
+	})
+	goxPath := uriToPath(uri)
+	generated := p.generated[goxPath]
+	if generated == "" {
+		t.Fatal("Expected generated code")
+	}
+
+	// name, after the two UTF-16 units of 😀
+	pos := map[string]any{"line": float64(3), "character": float64(17)}
+	p.translatePositionsToGo(map[string]any{
+		"textDocument": map[string]any{"uri": uri},
+		"position":     pos,
+	})
+	line := strings.Split(generated, "\n")[int(pos["line"].(float64))]
+	idx := strings.Index(line, "name)")
+	if idx < 0 {
+		t.Fatalf("Expected position on the line of name, got %v in:\n%s", pos, generated)
+	}
+	if want := len(utf16.Encode([]rune(line[:idx]))); pos["character"] != float64(want) {
+		t.Errorf("Expected name at UTF-16 column %d of %q, got %v", want, line, pos["character"])
+	}
+
+	// And back
+	p.rewritePositions(map[string]any{"uri": uri, "position": pos})
+	if want := (map[string]any{"line": float64(3), "character": float64(17)}); !reflect.DeepEqual(pos, want) {
+		t.Errorf("Position back in .gox = %v, want %v", pos, want)
+	}
+}
+
+func TestIncrementalDidChange(t *testing.T) {
+	p := testProxy()
+	uri := pathToURI(filepath.Join(t.TempDir(), "app.gox"))
+	p.handleDidOpen(map[string]any{
+		"params": map[string]any{
+			"textDocument": map[string]any{
+				"uri":  uri,
+				"text": "package main\n\nfunc App() gox.VNode {\n\treturn <p>😀 hello</p>\n}\n\nfunc Other() gox.VNode {\n\treturn <b />\n}\n",
+			},
+		},
+	})
+	goxPath := uriToPath(uri)
+	old := p.trees[goxPath]
+	if old == nil {
+		t.Fatal("Expected a syntax tree after didOpen")
+	}
+
+	position := func(line, character int) map[string]any {
+		return map[string]any{"line": float64(line), "character": float64(character)}
+	}
+	change := map[string]any{
+		"params": map[string]any{
+			"textDocument": map[string]any{"uri": uri},
+			"contentChanges": []any{
+				// hello -> world, after the two UTF-16 units of 😀
+				map[string]any{"range": map[string]any{"start": position(3, 14), "end": position(3, 19)}, "text": "world"},
+				map[string]any{"range": map[string]any{"start": position(7, 9), "end": position(7, 10)}, "text": "i"},
+			},
+		},
+	}
+	p.handleDidChange(change)
+
+	want := "package main\n\nfunc App() gox.VNode {\n\treturn <p>😀 world</p>\n}\n\nfunc Other() gox.VNode {\n\treturn <i />\n}\n"
+	if got := p.fileContents[goxPath]; got != want {
+		t.Fatalf("content = %q, want %q", got, want)
+	}
+	if !strings.Contains(p.generated[goxPath], `"😀 world"`) || !strings.Contains(p.generated[goxPath], `gox.Element("i"`) {
+		t.Errorf("Expected generated code for the edited content, got:\n%s", p.generated[goxPath])
+	}
+	if file := p.trees[goxPath]; file == nil || file == old || file.Nodes[0] != old.Nodes[0] {
+		t.Errorf("Expected the tree reparsed from the old one")
+	}
+
+	// Generated Go replaces the changes, for gopls
+	changes := change["params"].(map[string]any)["contentChanges"].([]any)
+	if len(changes) != 1 || changes[0].(map[string]any)["range"] != nil {
+		t.Errorf("Expected one full change, got %v", changes)
+	}
+}
+
+func TestRangeOffset(t *testing.T) {
+	text := "ab\r\n😀c\nd"
+	tests := []struct {
+		line, character, want int
+	}{
+		{0, 0, 0},
+		{0, 2, 2},
+		{0, 9, 2}, // Past the end of the line, before \r\n
+		{1, 2, 8}, // After the surrogate pair of 😀
+		{1, 3, 9},
+		{2, 1, 11},
+		{5, 0, 11}, // Past the end
+	}
+	for _, tt := range tests {
+		got, ok := rangeOffset(text, map[string]any{"line": float64(tt.line), "character": float64(tt.character)})
+		if !ok || got != tt.want {
+			t.Errorf("rangeOffset(%d:%d) = %d, %v, want %d", tt.line, tt.character, got, ok, tt.want)
+		}
+	}
+}
+
+func TestGeneratedFileOptions(t *testing.T) {
+	dir := t.TempDir()
+	goxPath := filepath.Join(dir, "app.gox")
+	p := testProxy().configure(&Options{Version: "v1.2.3", LineDirectives: true})
+	if p.generateAndCache(pathToURI(goxPath), "package ui\n\nfunc A() gox.VNode { return <a /> }\n", nil) == "" {
+		t.Fatal("generateAndCache failed")
+	}
+	data, err := os.ReadFile(filepath.Join(dir, "app_gox.go"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if prov, ok := generator.ReadProvenance(data); !ok || prov.Version != "v1.2.3" || prov.Source != "app.gox" {
+		t.Errorf("provenance = %+v, %v; want version v1.2.3 from app.gox", prov, ok)
+	}
+	if !strings.Contains(string(data), "//line app.gox:") {
+		t.Errorf("generated file has no //line directives:\n%s", data)
+	}
+	entries, _ := os.ReadDir(dir)
+	if len(entries) != 1 {
+		t.Errorf("files left in the source directory: %v", entries)
+	}
+}
@@ -0,0 +1,117 @@
+package lsp
+
+import (
+	"net/url"
+	"path/filepath"
+	"strings"
+)
+
+// windowsPaths reports whether file paths use Windows syntax: drive letters,
+// UNC shares and backslash separators.
+const windowsPaths = filepath.Separator == '\\'
+
+// uriToPath converts a file URI from the editor or gopls to a file path.
+// Anything else is returned unchanged.
+func uriToPath(uri string) string {
+	return fileURIToPath(uri, windowsPaths)
+}
+
+// pathToURI converts a file path to a file URI. URIs are returned unchanged.
+func pathToURI(path string) string {
+	return pathToFileURI(path, windowsPaths)
+}
+
+// previewURI returns the preview URI of a .gox path: its file URI with the
+// PreviewScheme, e.g. gox-generated:///C:/src/my%20app.gox.
+func previewURI(goxPath string) string {
+	return pathToPreviewURI(goxPath, windowsPaths)
+}
+
+// previewURIToPath returns the .gox path of a preview URI.
+func previewURIToPath(uri string) string {
+	return previewURIToFilePath(uri, windowsPaths)
+}
+
+// pathToPreviewURI is pathToFileURI with the PreviewScheme.
+func pathToPreviewURI(path string, windows bool) string {
+	u, err := url.Parse(pathToFileURI(path, windows))
+	if err != nil {
+		return PreviewScheme + "://" + path
+	}
+	u.Scheme = PreviewScheme
+	return u.String()
+}
+
+// previewURIToFilePath is fileURIToPath for a preview URI. Editors may
+// drop the empty authority, as in gox-generated:/src/app.gox.
+func previewURIToFilePath(uri string, windows bool) string {
+	rest := strings.TrimPrefix(uri, PreviewScheme+":")
+	if !strings.HasPrefix(rest, "//") {
+		rest = "//" + rest
+	}
+	return fileURIToPath("file:"+rest, windows)
+}
+
+// fileURIToPath converts a file URI to a path, decoding percent-escapes. With
+// windows, "file:///c%3A/src/app.gox" becomes `C:\src\app.gox` and
+// "file://server/share/app.gox" becomes `\\server\share\app.gox`. Drive
+// letters are upper-cased, so the same file always has the same path no
+// matter how the editor spelled its URI.
+func fileURIToPath(uri string, windows bool) string {
+	if !strings.HasPrefix(uri, "file://") {
+		return uri
+	}
+	u, err := url.Parse(uri)
+	if err != nil {
+		return strings.TrimPrefix(uri, "file://")
+	}
+	path := u.Path
+	if !windows {
+		return path
+	}
+	if u.Host != "" && u.Host != "localhost" {
+		return `\\` + u.Host + strings.ReplaceAll(path, "/", `\`)
+	}
+	if hasDriveLetter(strings.TrimPrefix(path, "/")) {
+		path = upperDrive(strings.TrimPrefix(path, "/"))
+	}
+	return strings.ReplaceAll(path, "/", `\`)
+}
+
+// pathToFileURI converts a path to a file URI, percent-encoding characters
+// that are not allowed in URIs. With windows, backslashes are separators and
+// `C:\src\app.gox` becomes "file:///C:/src/app.gox".
+func pathToFileURI(path string, windows bool) string {
+	if strings.HasPrefix(path, "file://") {
+		return path
+	}
+	u := url.URL{Scheme: "file", Path: path}
+	if windows {
+		path = strings.ReplaceAll(path, `\`, "/")
+		switch {
+		case strings.HasPrefix(path, "//"):
+			// UNC path: \\server\share\dir
+			host, rest, _ := strings.Cut(path[2:], "/")
+			u.Host, u.Path = host, "/"+rest
+		case hasDriveLetter(path):
+			u.Path = "/" + upperDrive(path)
+		default:
+			u.Path = path
+		}
+	}
+	return u.String()
+}
+
+// hasDriveLetter reports whether path starts with a Windows drive, as in
+// "C:" or "c:/src".
+func hasDriveLetter(path string) bool {
+	if len(path) < 2 || path[1] != ':' || (len(path) > 2 && path[2] != '/' && path[2] != '\\') {
+		return false
+	}
+	c := path[0]
+	return 'a' <= c && c <= 'z' || 'A' <= c && c <= 'Z'
+}
+
+func upperDrive(path string) string {
+	return strings.ToUpper(path[:1]) + path[1:]
+}
@@ -0,0 +1,96 @@
+package parser
+
+import (
+	goast "go/ast"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/germtb/gox/ast"
+)
+
+// attachDoc sets the doc comment of node, if it is an element, from the Go
+// code prev just before it.
+func attachDoc(prev, node ast.Node) {
+	elem, ok := node.(*ast.JSXElement)
+	if !ok {
+		return
+	}
+	if code, ok := prev.(*ast.GoCode); ok {
+		elem.Doc = docComment(code.Value)
+	}
+}
+
+// docComment returns the text of the // comments on the lines just before
+// the end of code, if code ends at the start of a line but for indentation.
+// The comments end at a blank line or other code, as go/doc reads them.
+func docComment(code string) string {
+	end := strings.LastIndexByte(code, '\n')
+	if end < 0 || strings.TrimLeft(code[end+1:], " \t") != "" {
+		return ""
+	}
+	lines := strings.Split(code[:end], "\n")
+	start := len(lines)
+	for start > 0 && strings.HasPrefix(strings.TrimSpace(lines[start-1]), "//") {
+		start--
+	}
+	if start == len(lines) {
+		return ""
+	}
+	group := &goast.CommentGroup{}
+	for _, line := range lines[start:] {
+		group.List = append(group.List, &goast.Comment{Text: strings.TrimSpace(line)})
+	}
+	return group.Text()
+}
+
+// findComponents returns the top-level functions in the Go code of nodes
+// whose names can be used as tags, with their doc comments. Top-level
+// declarations start at the beginning of a line, as gofmt writes them.
+func findComponents(nodes []ast.Node) []ast.Component {
+	var components []ast.Component
+	for _, node := range nodes {
+		code, ok := node.(*ast.GoCode)
+		if !ok {
+			continue
+		}
+		line := code.Range.Start.Line
+		for i := 0; i < len(code.Value); i++ {
+			atLineStart := i > 0 && code.Value[i-1] == '\n' || i == 0 && code.Range.Start.Column == 1
+			if i > 0 && code.Value[i-1] == '\n' {
+				line++
+			}
+			if !atLineStart || !strings.HasPrefix(code.Value[i:], "func ") {
+				continue
+			}
+			name := componentName(code.Value[i+len("func "):])
+			if name == "" {
+				continue
+			}
+			start := ast.NewPosition(code.Range.Start.Offset+i+len("func "), line, len("func ")+1)
+			end := start.Advance(name)
+			components = append(components, ast.Component{
+				Name:  name,
+				Doc:   docComment(code.Value[:i]),
+				Range: ast.NewRange(start, end),
+			})
+		}
+	}
+	return components
+}
+
+// componentName returns the name of the function declared by decl, which
+// follows the func keyword, if it has no receiver and an upper-case name.
+func componentName(decl string) string {
+	r, _ := utf8.DecodeRuneInString(decl)
+	if !unicode.IsUpper(r) {
+		return ""
+	}
+	end := strings.IndexFunc(decl, func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r) && r != '_'
+	})
+	if end < 0 || (decl[end] != '(' && decl[end] != '[') {
+		return ""
+	}
+	return decl[:end]
+}
@@ -0,0 +1,97 @@
+package parser
+
+import (
+	goparser "go/parser"
+	"go/token"
+	"strconv"
+	"strings"
+
+	"github.com/germtb/gox/ast"
+)
+
+// The directives of ast.Directives. Like //gox:raw and //gox:void, they are
+// line comments before the package clause:
+//
+//	//gox:runtime example.com/tui/gox
+//	//gox:preserve-whitespace
+//	package views
+const (
+	RuntimeDirective            = "//gox:runtime"
+	PreserveWhitespaceDirective = "//gox:preserve-whitespace"
+	NoFormatDirective           = "//gox:no-format"
+)
+
+// parseHeader fills in the package, imports and directives of file from the
+// Go code it starts with. JSX cannot appear before the imports end, so they
+// are all in that first node. A header Go cannot parse is left for the
+// generated code to report; whatever parsed of it is kept.
+func parseHeader(file *ast.GoxFile) {
+	if len(file.Nodes) == 0 {
+		return
+	}
+	code, ok := file.Nodes[0].(*ast.GoCode)
+	if !ok {
+		return
+	}
+	file.Directives = parseDirectives(code.Value)
+
+	fset := token.NewFileSet()
+	f, _ := goparser.ParseFile(fset, file.SourcePath, code.Value, goparser.ImportsOnly)
+	if f == nil || f.Name == nil || !f.Name.Pos().IsValid() {
+		return
+	}
+	base := code.Range.Start
+	position := func(pos token.Pos) ast.Position {
+		return base.Advance(code.Value[:fset.Position(pos).Offset])
+	}
+
+	file.Package = f.Name.Name
+	file.PackageRange = ast.NewRange(position(f.Name.Pos()), position(f.Name.End()))
+	for _, spec := range f.Imports {
+		path, err := strconv.Unquote(spec.Path.Value)
+		if err != nil {
+			continue
+		}
+		imp := ast.Import{
+			Path:  path,
+			Range: ast.NewRange(position(spec.Pos()), position(spec.End())),
+		}
+		if spec.Name != nil {
+			imp.Alias = spec.Name.Name
+		}
+		file.Imports = append(file.Imports, imp)
+	}
+}
+
+// parseDirectives returns the directives in the lines of header before the
+// package clause. Unknown //gox: comments are ignored, and of repeated
+// //gox:runtime directives the first wins.
+func parseDirectives(header string) ast.Directives {
+	var d ast.Directives
+	for _, line := range strings.Split(header, "\n") {
+		line = strings.TrimSpace(line)
+		if strings.HasPrefix(line, "package ") {
+			break
+		}
+		if rest, ok := directive(line, RuntimeDirective); ok && d.Runtime == "" {
+			d.Runtime = strings.TrimSpace(rest)
+		}
+		if _, ok := directive(line, PreserveWhitespaceDirective); ok {
+			d.PreserveWhitespace = true
+		}
+		if _, ok := directive(line, NoFormatDirective); ok {
+			d.NoFormat = true
+		}
+	}
+	return d
+}
+
+// directive returns the rest of line after the directive name, if line is
+// that directive.
+func directive(line, name string) (string, bool) {
+	rest, ok := strings.CutPrefix(line, name)
+	if !ok || rest != "" && rest[0] != ' ' && rest[0] != '\t' {
+		return "", false
+	}
+	return rest, true
+}
@@ -2,36 +2,101 @@
 package parser
 
 import (
+	"errors"
 	"fmt"
+	"strings"
 
 	"github.com/germtb/gox/ast"
+	"github.com/germtb/gox/diag"
 	"github.com/germtb/gox/lexer"
 )
 
 // Parser parses gox source files.
 type Parser struct {
+	src      string
+	raw      map[string]bool // Raw elements set with SetRawElements
+	void     map[string]bool // Void elements, from the //gox:void directives
 	lex      *lexer.Lexer
 	tok      lexer.Token
 	peekTok  lexer.Token
 	hasPeek  bool
+	prevEnd  int      // End of the token before tok
+	open     []string // Tags of the elements being parsed, "" for fragments
 	errors   []error
 	filename string
+	opts     Options
+}
+
+// Mode sets what a parse keeps of the source, as for ParseFile.
+type Mode uint
+
+// Trivia makes a parse lossless: the nodes of the tree hold the whitespace
+// between their tokens, and how they spell what their fields normalize, in
+// an ast.Trivia, so that formatter.Print writes the tree back byte for
+// byte.
+const Trivia Mode = 1 << iota
+
+// Options tune a parse, for ParseWithOptions. The zero value parses as
+// Parse does.
+type Options struct {
+	// MaxErrors stops the parse at that many errors, returning the tree so
+	// far and an error that joins them, as errors.Join does. Zero parses
+	// the whole file and returns its first error, as Parse does.
+	MaxErrors int
+
+	// Mode sets what the tree keeps of the source, as for ParseFile.
+	Mode Mode
+
+	// DropComments leaves out the {/* comments */} among the children of
+	// elements, and the doc comments of elements and components, which
+	// tools that only read the structure of a file can do without. Trivia
+	// mode keeps them regardless.
+	DropComments bool
+
+	// StrictIntrinsics reports intrinsic elements whose tag is not an
+	// element name: a lower case ASCII letter followed by ASCII letters,
+	// digits and hyphens, as HTML, SVG and custom elements are named.
+	StrictIntrinsics bool
 }
 
 // New creates a new Parser.
 func New(filename string, src []byte) *Parser {
 	return &Parser{
+		src:      string(src),
 		lex:      lexer.New(string(src)),
+		void:     lexer.VoidElements(string(src)),
 		filename: filename,
 	}
 }
 
+// SetRawElements replaces the raw elements of the source, which are read
+// from its directives. See lexer.SetRawElements.
+func (p *Parser) SetRawElements(raw map[string]bool) {
+	p.raw = raw
+	p.lex.SetRawElements(raw)
+}
+
 // Parse parses a gox file and returns the AST.
 func Parse(filename string, src []byte) (*ast.GoxFile, error) {
 	p := New(filename, src)
 	return p.Parse()
 }
 
+// ParseFile parses a gox file as Parse does, keeping what mode sets.
+func ParseFile(filename string, src []byte, mode Mode) (*ast.GoxFile, error) {
+	return ParseWithOptions(filename, src, &Options{Mode: mode})
+}
+
+// ParseWithOptions parses a gox file as Parse does, tuned by opts. Nil
+// options are those of Parse.
+func ParseWithOptions(filename string, src []byte, opts *Options) (*ast.GoxFile, error) {
+	p := New(filename, src)
+	if opts != nil {
+		p.opts = *opts
+	}
+	return p.Parse()
+}
+
 // Parse parses the source and returns a GoxFile AST.
 func (p *Parser) Parse() (*ast.GoxFile, error) {
 	p.advance() // load first token
@@ -41,17 +106,53 @@ func (p *Parser) Parse() (*ast.GoxFile, error) {
 		Nodes:      []ast.Node{},
 	}
 
+	var prev ast.Node
 	for p.tok.Type != lexer.TOKEN_EOF {
 		node := p.parseNode()
 		if node != nil {
+			p.attachDoc(prev, node)
 			file.Nodes = append(file.Nodes, node)
+			prev = node
+		}
+	}
+	parseHeader(file)
+	file.Components = findComponents(file.Nodes)
+	if !p.comments() {
+		for i := range file.Components {
+			file.Components[i].Doc = ""
 		}
 	}
+	return file, p.err()
+}
 
-	if len(p.errors) > 0 {
-		return file, p.errors[0]
+// err returns the error of the parse: its first error, or all of them,
+// joined, if the parse stops at Options.MaxErrors.
+func (p *Parser) err() error {
+	switch {
+	case len(p.errors) == 0:
+		return nil
+	case p.opts.MaxErrors > 0 && len(p.errors) > 1:
+		return errors.Join(p.errors...)
+	}
+	return p.errors[0]
+}
+
+// full reports whether the parse has reached Options.MaxErrors, and stops.
+func (p *Parser) full() bool {
+	return p.opts.MaxErrors > 0 && len(p.errors) >= p.opts.MaxErrors
+}
+
+// comments reports whether the tree keeps comments.
+func (p *Parser) comments() bool {
+	return !p.opts.DropComments || p.opts.Mode&Trivia != 0
+}
+
+// attachDoc sets the doc comment of node, as the package function does,
+// if the tree keeps comments.
+func (p *Parser) attachDoc(prev, node ast.Node) {
+	if p.comments() {
+		attachDoc(prev, node)
 	}
-	return file, nil
 }
 
 // parseNode parses a single top-level node (Go code or JSX element).
@@ -64,7 +165,7 @@ func (p *Parser) parseNode() ast.Node {
 	case lexer.TOKEN_EOF:
 		return nil
 	default:
-		p.error("unexpected token: %v", p.tok)
+		p.error(diag.UnexpectedToken, "unexpected token: %v", p.tok)
 		p.advance()
 		return nil
 	}
@@ -87,38 +188,96 @@ func (p *Parser) parseJSXElement() ast.Node {
 	if p.tok.Type == lexer.TOKEN_JSX_FRAG_OPEN {
 		return p.parseJSXFragment(startRange)
 	}
+	elem := p.parseJSXTag(startRange)
+	if elem == nil {
+		return nil
+	}
+	if elem.Tag == fragmentTag {
+		return p.longFragment(elem)
+	}
+	return elem
+}
+
+// parseJSXTag parses an element with a tag name, <tag ...>...</tag> or
+// <tag ... />.
+func (p *Parser) parseJSXTag(startRange ast.Range) *ast.JSXElement {
 
 	// Parse opening tag: <tagname
 	if p.tok.Type != lexer.TOKEN_JSX_OPEN {
-		p.error("expected '<', got %v", p.tok)
+		p.error(diag.ExpectedTagName, "expected '<', got %v", p.tok)
 		return nil
 	}
 	p.advance() // consume <
 
 	// Get tag name
 	if p.tok.Type != lexer.TOKEN_JSX_TAG {
-		p.error("expected tag name, got %v", p.tok)
+		p.error(diag.ExpectedTagName, "expected tag name, got %v", p.tok)
 		return nil
 	}
+	var trivia *ast.Trivia
+	if p.opts.Mode&Trivia != 0 {
+		trivia = &ast.Trivia{Tag: p.gap()}
+	}
 	tagName := p.tok.Value
+	tagRange := p.tokenRange()
+	if p.opts.StrictIntrinsics && !ast.IsComponentTag(tagName) && !isElementName(tagName) {
+		d := p.error(diag.IntrinsicTag, "intrinsic element <%s> is not an element name", tagName)
+		d.Hint = "name elements with a lower case letter followed by ASCII letters, digits and -, and components with an upper case letter"
+	}
 	p.advance()
 
+	// Type arguments of a generic component: <List[string]>
+	if p.tok.Type == lexer.TOKEN_JSX_TYPE_ARGS && !ast.IsComponentTag(tagName) {
+		p.error(diag.InvalidTypeArguments, "type arguments on intrinsic element <%s>", tagName)
+	}
+	if trivia != nil && p.tok.Type == lexer.TOKEN_JSX_TYPE_ARGS {
+		trivia.TypeArgs = p.src[p.tok.Offset:p.tok.End]
+	}
+	typeArgs, _ := p.parseTypeArgs()
+
 	// Parse attributes
 	attrs := p.parseJSXAttributes()
+	if ast.IsComponentTag(tagName) && tagName != fragmentTag {
+		// Props structs cannot be merged like gox.Props maps
+		for _, attr := range attrs {
+			if s, ok := attr.(*ast.SpreadAttribute); ok {
+				d := p.errorAtRange(s.Range, diag.SpreadAttribute, "spread attributes are not supported on components: {...%s}", s.Expression)
+				d.Hint = "set each attribute explicitly, as in name={props.Name}"
+			}
+		}
+	}
 
 	// Check for self-closing or children
 	elem := &ast.JSXElement{
 		Tag:        tagName,
+		TagRange:   tagRange,
+		TypeArgs:   typeArgs,
 		Attributes: attrs,
 		Range:      startRange,
+		Void:       p.void[tagName] && !ast.IsComponentTag(tagName),
+		Trivia:     trivia,
+	}
+	if elem.Void {
+		for _, attr := range attrs {
+			if attributeKey(attr) == "children" {
+				d := p.errorAtRange(attr.GetRange(), diag.VoidChildren, "void element <%s> cannot have children", tagName)
+				d.Hint = voidHint(tagName)
+			}
+		}
 	}
 
+	if trivia != nil {
+		trivia.End = p.gap()
+	}
 	if p.tok.Type == lexer.TOKEN_JSX_SLASH {
 		// Self-closing: />
 		elem.SelfClosing = true
 		p.advance() // consume /
+		if trivia != nil {
+			trivia.Slash = p.gap()
+		}
 		if p.tok.Type != lexer.TOKEN_JSX_CLOSE {
-			p.error("expected '>' after '/', got %v", p.tok)
+			p.error(diag.MalformedTag, "expected '>' after '/', got %v", p.tok).Hint = "end a self-closing tag with />"
 		} else {
 			p.advance() // consume >
 		}
@@ -127,33 +286,104 @@ func (p *Parser) parseJSXElement() ast.Node {
 	}
 
 	if p.tok.Type != lexer.TOKEN_JSX_CLOSE {
-		p.error("expected '>' or '/>', got %v", p.tok)
+		p.error(diag.MalformedTag, "expected '>' or '/>', got %v", p.tok).Hint = fmt.Sprintf("end the opening tag of <%s> with >, or with /> if it has no children", tagName)
 		return elem
 	}
+	openEnd := p.tokenRange().End
 	p.advance() // consume >
 
 	// Parse children
+	p.open = append(p.open, tagName)
 	elem.Children = p.parseJSXChildren(tagName)
+	p.open = p.open[:len(p.open)-1]
+	if elem.Void {
+		p.checkVoidChildren(elem)
+	}
 
 	// Parse closing tag: </tagname>
-	if p.tok.Type == lexer.TOKEN_JSX_OPEN {
+	closing := "</" + tagName + ">"
+	forgot := fmt.Sprintf("did you forget to close <%s>, opened on line %d?", tagName, tagRange.Start.Line)
+	switch {
+	case p.closesOuter(tagName):
+		// The closing tag is that of an element around this one, which
+		// ends here as if it were closed
+		d := p.errorAt(p.peek(), diag.MismatchedClosingTag, "mismatched closing tag: expected </%s>, got </%s>", tagName, p.peek().Value)
+		d.Hint = forgot
+		p.closeBefore(d, closing)
+
+	case p.tok.Type == lexer.TOKEN_JSX_OPEN:
+		closeStart := p.tokenRange().Start
+		var mismatch *diag.Diagnostic
 		p.advance() // consume </
+		if trivia != nil {
+			trivia.CloseTag = p.gap()
+		}
 		if p.tok.Type == lexer.TOKEN_JSX_TAG {
 			closeTag := p.tok.Value
+			elem.CloseTagRange = p.tokenRange()
 			if closeTag != tagName {
-				p.error("mismatched closing tag: expected </%s>, got </%s>", tagName, closeTag)
+				mismatch = p.error(diag.MismatchedClosingTag, "mismatched closing tag: expected </%s>, got </%s>", tagName, closeTag)
+				mismatch.Hint = forgot
 			}
 			p.advance()
+			// The closing tag may omit the type arguments, or repeat them exactly
+			if p.tok.Type == lexer.TOKEN_JSX_TYPE_ARGS {
+				tok := p.tok
+				if trivia != nil {
+					trivia.CloseTypeArgs = p.src[tok.Offset:tok.End]
+				}
+				if closeArgs, _ := p.parseTypeArgs(); stripSpaces(closeArgs) != stripSpaces(typeArgs) {
+					p.errorAt(tok, diag.InvalidTypeArguments, "mismatched type arguments: expected </%s[%s]> or </%s>, got </%s[%s]>", tagName, typeArgs, tagName, tagName, closeArgs)
+				}
+			}
 		}
 		if p.tok.Type == lexer.TOKEN_JSX_CLOSE {
+			if trivia != nil {
+				trivia.CloseEnd = p.gap()
+			}
 			p.advance() // consume >
 		}
+		if mismatch != nil {
+			p.suggest(mismatch, ast.Range{Start: closeStart, End: p.prevPosition()}, closing)
+		}
+
+	default:
+		d := p.error(diag.UnclosedElement, "unclosed element <%s>", tagName)
+		d.Hint = fmt.Sprintf("%s End it with </%s>, or with /> if it has no children", forgot, tagName)
+		switch {
+		case p.tok.Type == lexer.TOKEN_JSX_FRAG_CLOSE && p.encloses(""):
+			p.closeBefore(d, closing)
+		case p.tok.Type == lexer.TOKEN_JSX_FRAG_CLOSE:
+			// A </> that closes no fragment closes the element
+			p.suggest(d, p.tokenRange(), closing)
+			p.advance()
+		default:
+			at := closePosition(openEnd, elem.Children)
+			p.suggest(d, ast.Range{Start: at, End: at}, closing)
+		}
 	}
 
 	elem.Range.End = p.prevPosition()
 	return elem
 }
 
+// parseTypeArgs consumes the type arguments after a tag name, if any, and
+// returns them without the brackets.
+func (p *Parser) parseTypeArgs() (string, bool) {
+	if p.tok.Type != lexer.TOKEN_JSX_TYPE_ARGS {
+		return "", false
+	}
+	args := strings.TrimSpace(p.tok.Value)
+	switch {
+	case p.tok.End-p.tok.Offset != len(p.tok.Value)+2:
+		p.error(diag.InvalidTypeArguments, "unclosed type arguments [%s", p.tok.Value)
+	case args == "":
+		p.error(diag.InvalidTypeArguments, "empty type arguments")
+	}
+	p.advance()
+	return args, true
+}
+
 // parseJSXFragment parses a JSX fragment <>...</>.
 func (p *Parser) parseJSXFragment(startRange ast.Range) *ast.JSXFragment {
 	frag := &ast.JSXFragment{
@@ -162,17 +392,74 @@ func (p *Parser) parseJSXFragment(startRange ast.Range) *ast.JSXFragment {
 	p.advance() // consume <>
 
 	// Parse children until </>
+	p.open = append(p.open, "")
 	frag.Children = p.parseJSXChildren("")
+	p.open = p.open[:len(p.open)-1]
 
 	// Consume </>
-	if p.tok.Type == lexer.TOKEN_JSX_FRAG_CLOSE {
+	forgot := fmt.Sprintf("did you forget to close the fragment opened on line %d?", startRange.Start.Line)
+	switch {
+	case p.tok.Type == lexer.TOKEN_JSX_FRAG_CLOSE:
 		p.advance()
+
+	case p.closesOuter(""):
+		d := p.errorAt(p.peek(), diag.MismatchedClosingTag, "mismatched closing tag: expected </>, got </%s>", p.peek().Value)
+		d.Hint = forgot
+		p.closeBefore(d, "</>")
+
+	case p.isClosingTag():
+		// A closing tag that closes no element closes the fragment
+		closeStart := p.tokenRange().Start
+		d := p.errorAt(p.peek(), diag.MismatchedClosingTag, "mismatched closing tag: expected </>, got </%s>", p.peek().Value)
+		d.Hint = forgot
+		p.advance() // consume </
+		for p.tok.Type == lexer.TOKEN_JSX_TAG || p.tok.Type == lexer.TOKEN_JSX_TYPE_ARGS {
+			p.advance()
+		}
+		if p.tok.Type == lexer.TOKEN_JSX_CLOSE {
+			p.advance() // consume >
+		}
+		p.suggest(d, ast.Range{Start: closeStart, End: p.prevPosition()}, "</>")
+
+	default:
+		d := p.error(diag.UnclosedElement, "unclosed fragment <>")
+		d.Hint = forgot + " End it with </>"
+		at := closePosition(startRange.End, frag.Children)
+		p.suggest(d, ast.Range{Start: at, End: at}, "</>")
 	}
 
 	frag.Range.End = p.prevPosition()
 	return frag
 }
 
+// fragmentTag is the tag of the long form of a fragment,
+// <Fragment key={...}>...</Fragment>.
+const fragmentTag = "Fragment"
+
+// longFragment turns an element parsed from <Fragment>...</Fragment> into
+// the fragment it stands for, reporting the attributes other than key.
+func (p *Parser) longFragment(elem *ast.JSXElement) *ast.JSXFragment {
+	if elem.TypeArgs != "" {
+		p.errorAtRange(elem.TagRange, diag.FragmentAttribute, "type arguments on <%s>", fragmentTag)
+	}
+	for _, attr := range elem.Attributes {
+		if key := attributeKey(attr); key != "key" {
+			if s, ok := attr.(*ast.SpreadAttribute); ok {
+				key = "{..." + s.Expression + "}"
+			}
+			d := p.errorAtRange(attr.GetRange(), diag.FragmentAttribute, "<%s> takes no attribute but key, got %s", fragmentTag, key)
+			d.Hint = "a fragment renders no element: set the attribute on the elements inside it"
+		}
+	}
+	return &ast.JSXFragment{
+		Range:      elem.Range,
+		Long:       true,
+		Attributes: elem.Attributes,
+		Children:   elem.Children,
+		Trivia:     elem.Trivia,
+	}
+}
+
 // parseJSXAttributes parses JSX attributes until we hit > or />.
 func (p *Parser) parseJSXAttributes() []ast.Attribute {
 	var attrs []ast.Attribute
@@ -189,21 +476,43 @@ func (p *Parser) parseJSXAttributes() []ast.Attribute {
 			}
 
 		case lexer.TOKEN_JSX_EXPR:
-			// Check for spread syntax which is not supported
-			if len(p.tok.Value) >= 3 && p.tok.Value[:3] == "..." {
-				p.error("spread attributes are not supported: {...%s}", p.tok.Value[3:])
+			if expr := strings.TrimSpace(p.tok.Value); strings.HasPrefix(expr, "...") {
+				attrs = append(attrs, p.spreadAttribute())
 			} else {
-				p.error("standalone expressions in attribute position are not supported: {%s}", p.tok.Value)
+				p.error(diag.StandaloneAttrExpr, "standalone expressions in attribute position are not supported: {%s}", p.tok.Value).Hint = fmt.Sprintf("name the attribute, as in value={%s}", p.tok.Value)
 			}
 			p.advance()
 
+		case lexer.TOKEN_JSX_COMMENT:
+			p.error(diag.StandaloneAttrExpr, "comments in attribute position are not supported: {%s}", strings.TrimSpace(p.tok.Value)).Hint = "move the comment above the element"
+			p.advance()
+
 		default:
-			p.error("unexpected token in attributes: %v", p.tok)
+			p.error(diag.UnexpectedToken, "unexpected token in attributes: %v", p.tok)
 			p.advance()
 		}
 	}
 }
 
+// spreadAttribute returns the spread attribute {...expr} of the current
+// token.
+func (p *Parser) spreadAttribute() *ast.SpreadAttribute {
+	r := p.tokenRange()
+	value := p.tok.Value
+	dots := strings.Index(value, "...") + len("...")
+	expr := strings.TrimSpace(value[dots:])
+	start := r.Start.Advance("{" + value[:dots+strings.Index(value[dots:], expr)])
+	attr := &ast.SpreadAttribute{
+		Expression:      expr,
+		Range:           r,
+		ExpressionRange: ast.Range{Start: start, End: start.Advance(expr)},
+	}
+	if p.opts.Mode&Trivia != 0 {
+		attr.Trivia = &ast.Trivia{Leading: p.gap(), Text: value}
+	}
+	return attr
+}
+
 // parseJSXAttribute parses a single JSX attribute.
 func (p *Parser) parseJSXAttribute() ast.Attribute {
 	if p.tok.Type != lexer.TOKEN_JSX_ATTR_NAME {
@@ -211,7 +520,11 @@ func (p *Parser) parseJSXAttribute() ast.Attribute {
 	}
 
 	name := p.tok.Value
-	startRange := p.tokenRange()
+	keyRange := p.tokenRange()
+	var trivia *ast.Trivia
+	if p.opts.Mode&Trivia != 0 {
+		trivia = &ast.Trivia{Leading: p.gap()}
+	}
 	p.advance()
 
 	// Check for = value
@@ -220,39 +533,106 @@ func (p *Parser) parseJSXAttribute() ast.Attribute {
 		return &ast.ExpressionAttribute{
 			Key:        name,
 			Expression: "true",
-			Range:      startRange,
+			Range:      keyRange,
+			KeyRange:   keyRange,
+			Trivia:     trivia,
 		}
 	}
-	p.advance() // consume =
+	equalsRange := p.tokenRange()
+	optional := p.tok.Value == "?="
+	if trivia != nil {
+		trivia.Equals = p.gap()
+	}
+	p.advance() // consume = or ?=
 
 	// Parse value
+	valueRange := p.tokenRange()
+	if trivia != nil {
+		trivia.Value = p.gap()
+	}
 	switch p.tok.Type {
 	case lexer.TOKEN_JSX_STRING:
+		if optional {
+			p.error(diag.InvalidAttributeValue, "optional attribute %s?= needs an expression, got a string", name).Hint = fmt.Sprintf("a string is never nil: write %s=%q", name, p.tok.Value)
+		}
 		attr := &ast.StringAttribute{
-			Key:   name,
-			Value: p.tok.Value,
-			Range: startRange,
+			Key:         name,
+			Value:       p.tok.Value,
+			Range:       ast.Range{Start: keyRange.Start, End: valueRange.End},
+			KeyRange:    keyRange,
+			EqualsRange: equalsRange,
+			ValueRange:  valueRange,
+			Raw:         p.src[p.tok.Offset] == '`',
+			Trivia:      trivia,
+		}
+		if trivia != nil {
+			trivia.Quote = p.src[p.tok.Offset : p.tok.Offset+1]
 		}
-		attr.Range.End = p.tokenRange().End
 		p.advance()
 		return attr
 
 	case lexer.TOKEN_JSX_EXPR:
 		attr := &ast.ExpressionAttribute{
-			Key:        name,
-			Expression: p.tok.Value,
-			Range:      startRange,
+			Key:         name,
+			Expression:  p.tok.Value,
+			Parts:       p.parseExpressionJSX(p.tok),
+			Range:       ast.Range{Start: keyRange.Start, End: valueRange.End},
+			KeyRange:    keyRange,
+			EqualsRange: equalsRange,
+			ValueRange:  valueRange,
+			Optional:    optional,
+			Trivia:      trivia,
 		}
-		attr.Range.End = p.tokenRange().End
 		p.advance()
 		return attr
 
 	default:
-		p.error("expected string or expression for attribute value, got %v", p.tok)
+		p.error(diag.InvalidAttributeValue, "expected string or expression for attribute value, got %v", p.tok).Hint = `quote the value, as in name="text", or wrap it in braces, as in name={value}`
 		return nil
 	}
 }
 
+// parseExpressionJSX splits the expression of tok into Go code and the JSX
+// it holds, parsed as at the top level, or returns nil if it holds no JSX.
+func (p *Parser) parseExpressionJSX(tok lexer.Token) []ast.Node {
+	if lexer.FindJSX(tok.Value) < 0 {
+		return nil
+	}
+	// The expression starts past the opening brace
+	start := tok.Offset + 1
+	sub := &Parser{
+		src:      p.src,
+		lex:      lexer.NewRange(p.src, start, start+len(tok.Value), tok.Line, tok.Column+1),
+		void:     p.void,
+		filename: p.filename,
+		opts:     p.opts,
+	}
+	if p.opts.MaxErrors > 0 {
+		sub.opts.MaxErrors -= len(p.errors)
+	}
+	if p.raw != nil {
+		sub.SetRawElements(p.raw)
+	}
+	sub.advance()
+	var parts []ast.Node
+	var prev ast.Node
+	for sub.tok.Type != lexer.TOKEN_EOF {
+		node := sub.parseNode()
+		if node == nil {
+			continue
+		}
+		sub.attachDoc(prev, node)
+		prev = node
+		// Spaces around the JSX are not part of the expression
+		if code, ok := node.(*ast.GoCode); ok && strings.TrimSpace(code.Value) == "" {
+			continue
+		}
+		parts = append(parts, node)
+	}
+	p.errors = append(p.errors, sub.errors...)
+	return parts
+}
+
 // parseJSXChildren parses children until closing tag or fragment close.
 func (p *Parser) parseJSXChildren(parentTag string) []ast.JSXChild {
 	var children []ast.JSXChild
@@ -272,11 +652,8 @@ func (p *Parser) parseJSXChildren(parentTag string) []ast.JSXChild {
 				return children
 			}
 			// Nested element
-			child := p.parseJSXElement()
-			if child != nil {
-				if elem, ok := child.(*ast.JSXElement); ok {
-					children = append(children, elem)
-				}
+			if child, ok := p.parseJSXElement().(ast.JSXChild); ok {
+				children = append(children, child)
 			}
 
 		case lexer.TOKEN_JSX_FRAG_OPEN:
@@ -288,9 +665,10 @@ func (p *Parser) parseJSXChildren(parentTag string) []ast.JSXChild {
 				}
 			}
 
-		case lexer.TOKEN_JSX_TEXT:
+		case lexer.TOKEN_JSX_TEXT, lexer.TOKEN_JSX_RAW_TEXT:
 			text := &ast.JSXText{
 				Value: p.tok.Value,
+				Raw:   p.tok.Type == lexer.TOKEN_JSX_RAW_TEXT,
 				Range: p.tokenRange(),
 			}
 			children = append(children, text)
@@ -299,18 +677,97 @@ func (p *Parser) parseJSXChildren(parentTag string) []ast.JSXChild {
 		case lexer.TOKEN_JSX_EXPR:
 			expr := &ast.JSXExpression{
 				Expression: p.tok.Value,
+				Parts:      p.parseExpressionJSX(p.tok),
 				Range:      p.tokenRange(),
 			}
 			children = append(children, expr)
 			p.advance()
 
+		case lexer.TOKEN_JSX_COMMENT:
+			if !p.comments() {
+				p.advance()
+				continue
+			}
+			comment := &ast.JSXComment{
+				Text:  strings.TrimSpace(p.tok.Value),
+				Range: p.tokenRange(),
+			}
+			if p.opts.Mode&Trivia != 0 {
+				comment.Trivia = &ast.Trivia{Text: p.tok.Value}
+			}
+			children = append(children, comment)
+			p.advance()
+
 		default:
-			p.error("unexpected token in children: %v", p.tok)
+			p.error(diag.UnexpectedToken, "unexpected token in children: %v", p.tok)
 			p.advance()
 		}
 	}
 }
 
+// closesOuter reports whether the current token starts the closing tag of
+// an element around the one being closed, tag, which is then left open.
+func (p *Parser) closesOuter(tag string) bool {
+	if !p.isClosingTag() || p.peek().Type != lexer.TOKEN_JSX_TAG {
+		return false
+	}
+	return p.peek().Value != tag && p.encloses(p.peek().Value)
+}
+
+// encloses reports whether an element named tag, or a fragment if tag is
+// "", is being parsed around the current one.
+func (p *Parser) encloses(tag string) bool {
+	for _, open := range p.open {
+		if open == tag {
+			return true
+		}
+	}
+	return false
+}
+
+// closeBefore closes the current element before the current token, where
+// its closing tag, closing, is missing: the lexer closes it too, and d
+// suggests inserting the tag.
+func (p *Parser) closeBefore(d *diag.Diagnostic, closing string) {
+	at := p.tokenRange().Start
+	p.suggest(d, ast.Range{Start: at, End: at}, closing)
+	p.lex.CloseElement()
+}
+
+// suggest sets the fix of d: text in place of the source r covers, or
+// inserted at r if it is empty.
+func (p *Parser) suggest(d *diag.Diagnostic, r ast.Range, text string) {
+	message := "insert " + text
+	if r.End.Offset > r.Start.Offset {
+		message = fmt.Sprintf("replace %s with %s", p.src[r.Start.Offset:r.End.Offset], text)
+	}
+	d.Fix = &diag.Fix{
+		Message:   message,
+		Line:      r.Start.Line,
+		Column:    r.Start.Column,
+		EndLine:   r.End.Line,
+		EndColumn: r.End.Column,
+		Text:      text,
+	}
+}
+
+// closePosition returns where to close an element left open at the end of
+// the file, end being the end of its opening tag: at the end of the line on
+// which its last child other than text ends, or its opening tag if it has
+// none.
+func closePosition(end ast.Position, children []ast.JSXChild) ast.Position {
+	text := ""
+	for _, child := range children {
+		if t, ok := child.(*ast.JSXText); ok {
+			text += t.Value
+			continue
+		}
+		end, text = child.GetRange().End, ""
+	}
+	line, _, _ := strings.Cut(text, "\n")
+	return end.Advance(strings.TrimRight(line, " \t\r"))
+}
+
 // isClosingTag checks if current position is a closing tag </
 func (p *Parser) isClosingTag() bool {
 	return p.tok.Type == lexer.TOKEN_JSX_OPEN && len(p.tok.Value) == 2 && p.tok.Value == "</"
@@ -319,41 +776,137 @@ func (p *Parser) isClosingTag() bool {
 // Helper methods
 
 func (p *Parser) advance() {
+	p.prevEnd = p.tok.End
 	if p.hasPeek {
 		p.tok = p.peekTok
 		p.hasPeek = false
 	} else {
-		p.tok = p.lex.NextToken()
+		p.tok = p.next()
 	}
 }
 
 func (p *Parser) peek() lexer.Token {
 	if !p.hasPeek {
-		p.peekTok = p.lex.NextToken()
+		p.peekTok = p.next()
 		p.hasPeek = true
 	}
 	return p.peekTok
 }
 
-func (p *Parser) error(format string, args ...any) {
-	msg := fmt.Sprintf(format, args...)
-	err := fmt.Errorf("%s:%d:%d: %s", p.filename, p.tok.Line, p.tok.Column, msg)
-	p.errors = append(p.errors, err)
+// next reads a token from the lexer. A lexical error is recorded, and read
+// as the end of the input, which the lexer has reached.
+func (p *Parser) next() lexer.Token {
+	if p.full() {
+		// The rest of the source reads as its end
+		end := p.rangeOf(p.tok).End
+		return lexer.Token{Type: lexer.TOKEN_EOF, Offset: end.Offset, End: end.Offset, Line: end.Line, Column: end.Column}
+	}
+	tok := p.lex.NextToken()
+	if tok.Type == lexer.TOKEN_ERROR {
+		p.errorAt(tok, diag.Unterminated, "%s", tok.Value)
+		tok = p.lex.NextToken()
+	}
+	return tok
+}
+
+// error records a diagnostic at the current token, and returns it so that
+// a hint can be added.
+func (p *Parser) error(code diag.Code, format string, args ...any) *diag.Diagnostic {
+	return p.errorAt(p.tok, code, format, args...)
+}
+
+// errorAt records a diagnostic at tok.
+func (p *Parser) errorAt(tok lexer.Token, code diag.Code, format string, args ...any) *diag.Diagnostic {
+	return p.errorAtRange(p.rangeOf(tok), code, format, args...)
+}
+
+// errorAtRange records a diagnostic covering r.
+func (p *Parser) errorAtRange(r ast.Range, code diag.Code, format string, args ...any) *diag.Diagnostic {
+	d := diag.New(code, p.filename, r.Start.Line, r.Start.Column, format, args...)
+	d.EndLine, d.EndColumn = r.End.Line, r.End.Column
+	if !p.full() {
+		p.errors = append(p.errors, d)
+	}
+	return d
+}
+
+// isElementName reports whether tag names an intrinsic element strictly: a
+// lower case ASCII letter followed by ASCII letters, digits and hyphens.
+func isElementName(tag string) bool {
+	if tag == "" || tag[0] < 'a' || tag[0] > 'z' {
+		return false
+	}
+	for i := 1; i < len(tag); i++ {
+		c := tag[i]
+		if !('a' <= c && c <= 'z' || 'A' <= c && c <= 'Z' || '0' <= c && c <= '9' || c == '-') {
+			return false
+		}
+	}
+	return true
+}
+
+// checkVoidChildren reports the first child of a void element, and drops
+// its children if they are only whitespace, which renders nothing.
+func (p *Parser) checkVoidChildren(elem *ast.JSXElement) {
+	var content strings.Builder
+	for _, child := range elem.Children {
+		if text, ok := child.(*ast.JSXText); ok && !text.Raw && text.Content() == "" {
+			content.WriteString(text.Value)
+			continue
+		}
+		d := p.errorAtRange(child.GetRange(), diag.VoidChildren, "void element <%s> cannot have children", elem.Tag)
+		d.Hint = voidHint(elem.Tag)
+		return
+	}
+	if elem.Trivia != nil {
+		elem.Trivia.Content = content.String()
+	}
+	elem.Children = nil
+}
+
+// attributeKey returns the key of attr.
+func attributeKey(attr ast.Attribute) string {
+	switch a := attr.(type) {
+	case *ast.StringAttribute:
+		return a.Key
+	case *ast.ExpressionAttribute:
+		return a.Key
+	}
+	return ""
+}
+
+// voidHint suggests how to write the void element tag.
+func voidHint(tag string) string {
+	return fmt.Sprintf("write <%s /> and set it up with attributes", tag)
+}
+
+// stripSpaces removes all whitespace from s, for comparing type arguments.
+func stripSpaces(s string) string {
+	return strings.Join(strings.Fields(s), "")
+}
+
+// gap returns the source between the token before the current one and it,
+// which in a tag is whitespace, for a lossless parse.
+func (p *Parser) gap() string {
+	if p.tok.Offset < p.prevEnd {
+		return ""
+	}
+	return p.src[p.prevEnd:p.tok.Offset]
 }
 
 func (p *Parser) tokenRange() ast.Range {
-	return ast.Range{
-		Start: ast.Position{
-			Offset: p.tok.Offset,
-			Line:   p.tok.Line,
-			Column: p.tok.Column,
-		},
-		End: ast.Position{
-			Offset: p.tok.Offset + len(p.tok.Value),
-			Line:   p.tok.Line,
-			Column: p.tok.Column + len(p.tok.Value),
-		},
+	return p.rangeOf(p.tok)
+}
+
+// rangeOf returns the range of tok's source text.
+func (p *Parser) rangeOf(tok lexer.Token) ast.Range {
+	start := ast.Position{
+		Offset: tok.Offset,
+		Line:   tok.Line,
+		Column: tok.Column,
 	}
+	// Strings and expressions may span lines
+	return ast.Range{Start: start, End: start.Advance(p.src[tok.Offset:tok.End])}
 }
 
 func (p *Parser) prevPosition() ast.Position {
@@ -0,0 +1,91 @@
+package parser
+
+import (
+	"strings"
+	"testing"
+)
+
+// benchComponent is a representative component mixing Go code, attributes,
+// nested elements, expressions and text.
+const benchComponent = `
+type CardProps struct {
+	Title    string
+	Items    []string
+	Selected int
+}
+
+// Card renders a titled list.
+func Card(props CardProps, children ...gox.VNode) gox.VNode {
+	if props.Title == "" {
+		return gox.Empty()
+	}
+	return <box direction="column" padding={1} style={{"border": "rounded"}}>
+		<text bold>{props.Title}</text>
+		{gox.MapIndex(props.Items, func(i int, item string) gox.VNode {
+			return <text selected={i == props.Selected}>{item}</text>
+		})}
+		<>
+			<text>Footer with some longer static text content</text>
+			{children}
+		</>
+	</box>
+}
+`
+
+// largeSource returns a synthetic .gox file containing n components.
+func largeSource(n int) []byte {
+	var sb strings.Builder
+	sb.WriteString("package bench\n\nimport \"github.com/germtb/gox\"\n")
+	for i := 0; i < n; i++ {
+		sb.WriteString(benchComponent)
+	}
+	return []byte(sb.String())
+}
+
+func BenchmarkParse(b *testing.B) {
+	src := largeSource(500)
+	b.SetBytes(int64(len(src)))
+	b.ReportAllocs()
+	b.ResetTimer()
+
+	for i := 0; i < b.N; i++ {
+		if _, err := Parse("bench.gox", src); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkParseSmall(b *testing.B) {
+	src := largeSource(1)
+	b.SetBytes(int64(len(src)))
+	b.ReportAllocs()
+	b.ResetTimer()
+
+	for i := 0; i < b.N; i++ {
+		if _, err := Parse("bench.gox", src); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+// BenchmarkReparse types a character in the middle of a large file, as an
+// editor's didChange does.
+func BenchmarkReparse(b *testing.B) {
+	src := largeSource(500)
+	old, err := Parse("bench.gox", src)
+	if err != nil {
+		b.Fatal(err)
+	}
+	at := len(src) / 2
+	edits := []TextEdit{{Start: at, End: at, Text: " "}}
+	newSrc := []byte(applyEdits(string(src), edits))
+	b.SetBytes(int64(len(newSrc)))
+	b.ReportAllocs()
+	b.ResetTimer()
+
+	for i := 0; i < b.N; i++ {
+		if _, err := Reparse(old, edits, newSrc); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
@@ -1,10 +1,13 @@
 package parser
 
 import (
+	"encoding/json"
+	"fmt"
 	"strings"
 	"testing"
 
 	"github.com/germtb/gox/ast"
+	"github.com/germtb/gox/diag"
 )
 
 func TestParseSimpleElement(t *testing.T) {
@@ -109,6 +112,178 @@ func TestParseElementWithExpressionAttribute(t *testing.T) {
 	}
 }
 
+func TestParseRawStringAttribute(t *testing.T) {
+	src := "<p label=`say \"hi\"\nnow` title=\"t\"></p>"
+
+	file, err := Parse("test.gox", []byte(src))
+	if err != nil {
+		t.Fatalf("Parse error: %v", err)
+	}
+
+	elem := file.Nodes[0].(*ast.JSXElement)
+	label := elem.Attributes[0].(*ast.StringAttribute)
+	if !label.Raw || label.Value != "say \"hi\"\nnow" {
+		t.Errorf("Expected a raw label attribute, got %#v", label)
+	}
+	if end := label.ValueRange.End; end.Line != 2 || end.Column != 5 {
+		t.Errorf("Expected the value to end at 2:5, got %d:%d", end.Line, end.Column)
+	}
+	if title := elem.Attributes[1].(*ast.StringAttribute); title.Raw {
+		t.Error("Expected title not to be raw")
+	}
+}
+
+func TestParseOptionalAttribute(t *testing.T) {
+	src := `<a title?={title} href={url}></a>`
+
+	file, err := Parse("test.gox", []byte(src))
+	if err != nil {
+		t.Fatalf("Parse error: %v", err)
+	}
+
+	elem := file.Nodes[0].(*ast.JSXElement)
+	title := elem.Attributes[0].(*ast.ExpressionAttribute)
+	if !title.Optional || title.Key != "title" || title.Expression != "title" {
+		t.Errorf("Expected optional attribute title, got %#v", title)
+	}
+	if r := title.EqualsRange; r.Start.Column != 9 || r.End.Column != 11 {
+		t.Errorf("Expected ?= at columns 9-11, got %d-%d", r.Start.Column, r.End.Column)
+	}
+	if href := elem.Attributes[1].(*ast.ExpressionAttribute); href.Optional {
+		t.Error("Expected href not to be optional")
+	}
+
+	_, err = Parse("test.gox", []byte(`<a title?="x"></a>`))
+	if d, ok := diag.As(err); !ok || d.Code != diag.InvalidAttributeValue {
+		t.Errorf("Expected a %s diagnostic for a string, got %v", diag.InvalidAttributeValue, err)
+	}
+}
+
+func TestParseJSXInExpressionAttribute(t *testing.T) {
+	src := `<Card header={<Title />} footer={func() gox.VNode { return <p>x</p> }}></Card>`
+
+	file, err := Parse("test.gox", []byte(src))
+	if err != nil {
+		t.Fatalf("Parse error: %v", err)
+	}
+
+	elem := file.Nodes[0].(*ast.JSXElement)
+	header := elem.Attributes[0].(*ast.ExpressionAttribute)
+	if len(header.Parts) != 1 {
+		t.Fatalf("Expected 1 part, got %d", len(header.Parts))
+	}
+	title, ok := header.Parts[0].(*ast.JSXElement)
+	if !ok || title.Tag != "Title" {
+		t.Fatalf("Expected Title element, got %#v", header.Parts[0])
+	}
+	if start := title.Range.Start; start.Line != 1 || start.Column != 15 || start.Offset != 14 {
+		t.Errorf("Expected Title at 1:15 (offset 14), got %d:%d (offset %d)", start.Line, start.Column, start.Offset)
+	}
+
+	footer := elem.Attributes[1].(*ast.ExpressionAttribute)
+	if len(footer.Parts) != 3 {
+		t.Fatalf("Expected 3 parts, got %d", len(footer.Parts))
+	}
+	if code, ok := footer.Parts[0].(*ast.GoCode); !ok || code.Value != "func() gox.VNode { return " {
+		t.Errorf("Expected Go code before the element, got %#v", footer.Parts[0])
+	}
+	if _, ok := footer.Parts[1].(*ast.JSXElement); !ok {
+		t.Errorf("Expected JSXElement, got %T", footer.Parts[1])
+	}
+	if code, ok := footer.Parts[2].(*ast.GoCode); !ok || code.Value != " }" {
+		t.Errorf("Expected Go code after the element, got %#v", footer.Parts[2])
+	}
+
+	// Attributes without JSX keep no parts
+	file, err = Parse("test.gox", []byte(`<box gap={a < b}></box>`))
+	if err != nil {
+		t.Fatalf("Parse error: %v", err)
+	}
+	if parts := file.Nodes[0].(*ast.JSXElement).Attributes[0].(*ast.ExpressionAttribute).Parts; parts != nil {
+		t.Errorf("Expected no parts, got %#v", parts)
+	}
+}
+
+func TestParseAttributeRanges(t *testing.T) {
+	src := "<box gap = {1} title=\"a\nb\" hidden></box>"
+
+	file, err := Parse("test.gox", []byte(src))
+	if err != nil {
+		t.Fatalf("Parse error: %v", err)
+	}
+
+	elem := file.Nodes[0].(*ast.JSXElement)
+	gap := elem.Attributes[0].(*ast.ExpressionAttribute)
+	title := elem.Attributes[1].(*ast.StringAttribute)
+	hidden := elem.Attributes[2].(*ast.ExpressionAttribute)
+
+	span := func(r ast.Range) string { return src[r.Start.Offset:r.End.Offset] }
+	tests := []struct {
+		name string
+		r    ast.Range
+		want string
+	}{
+		{"gap", gap.Range, "gap = {1}"},
+		{"gap key", gap.KeyRange, "gap"},
+		{"gap equals", gap.EqualsRange, "="},
+		{"gap value", gap.ValueRange, "{1}"},
+		{"title", title.Range, "title=\"a\nb\""},
+		{"title key", title.KeyRange, "title"},
+		{"title equals", title.EqualsRange, "="},
+		{"title value", title.ValueRange, "\"a\nb\""},
+		{"hidden", hidden.Range, "hidden"},
+		{"hidden key", hidden.KeyRange, "hidden"},
+	}
+	for _, tt := range tests {
+		if got := span(tt.r); got != tt.want {
+			t.Errorf("%s: got %q, want %q", tt.name, got, tt.want)
+		}
+	}
+
+	if start := gap.ValueRange.Start; start.Line != 1 || start.Column != 12 {
+		t.Errorf("Expected gap value at 1:12, got %d:%d", start.Line, start.Column)
+	}
+	// The value's end is on the line it ends on
+	if end := title.ValueRange.End; end.Line != 2 || end.Column != 3 {
+		t.Errorf("Expected title value to end at 2:3, got %d:%d", end.Line, end.Column)
+	}
+	if hidden.EqualsRange.IsValid() || hidden.ValueRange.IsValid() {
+		t.Errorf("Expected no equals or value range for boolean shorthand, got %v and %v", hidden.EqualsRange, hidden.ValueRange)
+	}
+}
+
+func TestParseRangesCountRunes(t *testing.T) {
+	src := "<p title=\"日本\">héllo 😀 {name}</p>"
+
+	file, err := Parse("test.gox", []byte(src))
+	if err != nil {
+		t.Fatalf("Parse error: %v", err)
+	}
+
+	elem := file.Nodes[0].(*ast.JSXElement)
+	title := elem.Attributes[0].(*ast.StringAttribute)
+	text := elem.Children[0].(*ast.JSXText)
+	expr := elem.Children[1].(*ast.JSXExpression)
+
+	tests := []struct {
+		name      string
+		pos       ast.Position
+		offset    int
+		line, col int
+	}{
+		{"title end", title.Range.End, 17, 1, 14},
+		{"text start", text.Range.Start, 18, 1, 15},
+		{"text end", text.Range.End, 30, 1, 23},
+		{"expression start", expr.Range.Start, 30, 1, 23},
+		{"element end", elem.Range.End, len(src), 1, 33},
+	}
+	for _, tt := range tests {
+		if tt.pos.Offset != tt.offset || tt.pos.Line != tt.line || tt.pos.Column != tt.col {
+			t.Errorf("%s at offset %d, %d:%d; want offset %d, %d:%d", tt.name, tt.pos.Offset, tt.pos.Line, tt.pos.Column, tt.offset, tt.line, tt.col)
+		}
+	}
+}
+
 func TestParseElementWithTextChild(t *testing.T) {
 	src := `<text>Hello World</text>`
 
@@ -133,6 +308,29 @@ func TestParseElementWithTextChild(t *testing.T) {
 	}
 }
 
+func TestParseRawElement(t *testing.T) {
+	src := `<script>
+	if (a < b) { run() }
+</script>`
+
+	file, err := Parse("test.gox", []byte(src))
+	if err != nil {
+		t.Fatalf("Parse error: %v", err)
+	}
+
+	elem := file.Nodes[0].(*ast.JSXElement)
+	if len(elem.Children) != 1 {
+		t.Fatalf("Expected 1 child, got %d", len(elem.Children))
+	}
+	text, ok := elem.Children[0].(*ast.JSXText)
+	if !ok || !text.Raw {
+		t.Fatalf("Expected raw JSXText, got %#v", elem.Children[0])
+	}
+	if want := "\n\tif (a < b) { run() }\n"; text.Content() != want {
+		t.Errorf("Expected content %q, got %q", want, text.Content())
+	}
+}
+
 func TestParseElementWithExpressionChild(t *testing.T) {
 	src := `<text>Hello {name}</text>`
 
@@ -164,6 +362,33 @@ func TestParseElementWithExpressionChild(t *testing.T) {
 	}
 }
 
+func TestParseCommentChild(t *testing.T) {
+	src := "<div>\n\t{/* a note */}\n\t<span />\n</div>"
+
+	file, err := Parse("test.gox", []byte(src))
+	if err != nil {
+		t.Fatalf("Parse error: %v", err)
+	}
+
+	elem := file.Nodes[0].(*ast.JSXElement)
+	comment, ok := elem.Children[1].(*ast.JSXComment)
+	if !ok {
+		t.Fatalf("Expected JSXComment, got %T", elem.Children[1])
+	}
+	if comment.Text != "/* a note */" {
+		t.Errorf("Expected comment '/* a note */', got %q", comment.Text)
+	}
+	if r := comment.Range; r.Start.Line != 2 || r.Start.Column != 2 || src[r.Start.Offset:r.End.Offset] != "{/* a note */}" {
+		t.Errorf("Comment range %+v covers %q", r, src[r.Start.Offset:r.End.Offset])
+	}
+
+	// Comments are not attributes
+	_, err = Parse("test.gox", []byte(`<div {/* note */} />`))
+	if err == nil || !strings.Contains(err.Error(), "comments in attribute position") {
+		t.Errorf("Expected a comment in attribute position to be rejected, got %v", err)
+	}
+}
+
 func TestParseNestedElements(t *testing.T) {
 	src := `<box><text>Hi</text></box>`
 
@@ -212,6 +437,212 @@ func TestParseFragment(t *testing.T) {
 	}
 }
 
+func TestParseKeyedFragment(t *testing.T) {
+	src := `<Fragment key={item.ID}><dt>{item.Term}</dt></Fragment>`
+
+	file, err := Parse("test.gox", []byte(src))
+	if err != nil {
+		t.Fatalf("Parse error: %v", err)
+	}
+
+	frag, ok := file.Nodes[0].(*ast.JSXFragment)
+	if !ok {
+		t.Fatalf("Expected JSXFragment, got %T", file.Nodes[0])
+	}
+	if !frag.Long || len(frag.Children) != 1 {
+		t.Errorf("Expected a long fragment with 1 child, got %#v", frag)
+	}
+	key, ok := frag.Key().(*ast.ExpressionAttribute)
+	if !ok || key.Expression != "item.ID" {
+		t.Errorf("Expected key={item.ID}, got %#v", frag.Key())
+	}
+	if frag.Range.End.Offset != len(src) {
+		t.Errorf("Expected the fragment to end at %d, got %d", len(src), frag.Range.End.Offset)
+	}
+
+	tests := []struct {
+		src  string
+		line int
+		col  int
+	}{
+		{`<Fragment key="a" class="item"><b /></Fragment>`, 1, 19},
+		{`<Fragment[int] key="a"><b /></Fragment>`, 1, 2},
+	}
+	for _, tt := range tests {
+		_, err := Parse("test.gox", []byte(tt.src))
+		d, ok := diag.As(err)
+		if !ok || d.Code != diag.FragmentAttribute || d.Line != tt.line || d.Column != tt.col {
+			t.Errorf("%q: expected a %s diagnostic at %d:%d, got %v", tt.src, diag.FragmentAttribute, tt.line, tt.col, err)
+		}
+	}
+}
+
+func TestParseHeader(t *testing.T) {
+	src := `// Package app renders the app.
+package app
+
+import (
+	"fmt"
+	ui "example.com/app/ui"
+)
+
+func App() gox.VNode {
+	return <ui.Card title={fmt.Sprint(1)} />
+}`
+
+	file, err := Parse("test.gox", []byte(src))
+	if err != nil {
+		t.Fatalf("Parse error: %v", err)
+	}
+
+	if file.Package != "app" {
+		t.Errorf("Expected package 'app', got %q", file.Package)
+	}
+	if r := file.PackageRange; r.Start.Line != 2 || r.Start.Column != 9 || src[r.Start.Offset:r.End.Offset] != "app" {
+		t.Errorf("Expected package name at 2:9, got %d:%d", r.Start.Line, r.Start.Column)
+	}
+
+	want := []ast.Import{
+		{Path: "fmt"},
+		{Alias: "ui", Path: "example.com/app/ui"},
+	}
+	if len(file.Imports) != len(want) {
+		t.Fatalf("Expected %d imports, got %#v", len(want), file.Imports)
+	}
+	for i, imp := range file.Imports {
+		if imp.Alias != want[i].Alias || imp.Path != want[i].Path {
+			t.Errorf("import %d: got %q %q, want %q %q", i, imp.Alias, imp.Path, want[i].Alias, want[i].Path)
+		}
+	}
+	if r := file.Imports[1].Range; r.Start.Line != 6 || src[r.Start.Offset:r.End.Offset] != `ui "example.com/app/ui"` {
+		t.Errorf("Expected the ui import on line 6, got line %d: %q", r.Start.Line, src[r.Start.Offset:r.End.Offset])
+	}
+
+	// A file without a package clause has none
+	file, _ = Parse("test.gox", []byte(`<div />`))
+	if file.Package != "" || file.Imports != nil {
+		t.Errorf("Expected no package or imports, got %q and %#v", file.Package, file.Imports)
+	}
+}
+
+func TestParseDirectives(t *testing.T) {
+	tests := []struct {
+		name string
+		src  string
+		want ast.Directives
+	}{
+		{"none", "package ui\n", ast.Directives{}},
+		{"all", "// Package ui renders the TUI.\n//gox:runtime myapp/ui\n//gox:preserve-whitespace\n//gox:no-format\npackage ui\n",
+			ast.Directives{Runtime: "myapp/ui", PreserveWhitespace: true, NoFormat: true}},
+		{"first runtime wins", "//gox:runtime myapp/ui\n//gox:runtime other/ui\npackage ui\n", ast.Directives{Runtime: "myapp/ui"}},
+		{"after package clause", "package ui\n\n//gox:no-format\n", ast.Directives{}},
+		{"other directive", "//gox:no-formatting\n//gox:raw pre\npackage ui\n", ast.Directives{}},
+		{"unparsable header", "//gox:no-format\npackage ui\n\nimport (\n", ast.Directives{NoFormat: true}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			file, _ := Parse("test.gox", []byte(tt.src))
+			if file.Directives != tt.want {
+				t.Errorf("Directives = %+v, want %+v", file.Directives, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseComponents(t *testing.T) {
+	src := `package app
+
+// Card shows a card.
+//
+// It has a title.
+func Card(props CardProps) gox.VNode {
+	return <div>{props.Title}</div>
+}
+
+//go:noinline
+func List[T any](props ListProps[T]) gox.VNode {
+	return <ul />
+}
+
+// helper is not a component.
+func helper() {}
+
+func (c Card) Render() gox.VNode { return nil }`
+
+	file, err := Parse("test.gox", []byte(src))
+	if err != nil {
+		t.Fatalf("Parse error: %v", err)
+	}
+
+	want := []ast.Component{
+		{Name: "Card", Doc: "Card shows a card.\n\nIt has a title.\n"},
+		{Name: "List", Doc: ""},
+	}
+	if len(file.Components) != len(want) {
+		t.Fatalf("Expected %d components, got %#v", len(want), file.Components)
+	}
+	for i, c := range file.Components {
+		if c.Name != want[i].Name || c.Doc != want[i].Doc {
+			t.Errorf("component %d: got %q with doc %q, want %q with doc %q", i, c.Name, c.Doc, want[i].Name, want[i].Doc)
+		}
+		if r := c.Range; src[r.Start.Offset:r.End.Offset] != c.Name || r.Start.Column != 6 {
+			t.Errorf("%s: range %v does not cover its name", c.Name, r)
+		}
+	}
+	if line := file.Components[1].Range.Start.Line; line != 11 {
+		t.Errorf("Expected List on line 11, got %d", line)
+	}
+}
+
+func TestParseElementDoc(t *testing.T) {
+	src := `package app
+
+func Page() gox.VNode {
+	header := (
+		// The page header,
+		// with the logo.
+		<Header />)
+	var footer gox.VNode = <Footer />
+
+	// Not the footer's.
+
+	return <Layout header={
+		// Shown on top
+		<Title />
+	}>{header}{footer}</Layout>
+}`
+
+	file, err := Parse("test.gox", []byte(src))
+	if err != nil {
+		t.Fatalf("Parse error: %v", err)
+	}
+
+	docs := map[string]string{}
+	for _, node := range file.Nodes {
+		if elem, ok := node.(*ast.JSXElement); ok {
+			docs[elem.Tag] = elem.Doc
+			for _, attr := range elem.Attributes {
+				for _, part := range attr.(*ast.ExpressionAttribute).Parts {
+					if nested, ok := part.(*ast.JSXElement); ok {
+						docs[nested.Tag] = nested.Doc
+					}
+				}
+			}
+		}
+	}
+	want := map[string]string{
+		"Header": "The page header,\nwith the logo.\n",
+		"Footer": "",
+		"Layout": "",
+		"Title":  "Shown on top\n",
+	}
+	for tag, doc := range want {
+		if got, ok := docs[tag]; !ok || got != doc {
+			t.Errorf("%s: got doc %q, want %q", tag, got, doc)
+		}
+	}
+}
+
 func TestParseGoCodeBeforeJSX(t *testing.T) {
 	src := `package main
 
@@ -244,19 +675,364 @@ func App() VNode {
 	}
 }
 
+func TestParseSpreadAttributes(t *testing.T) {
+	src := `<box {...a} x="1" { ...b } y={2} />`
+
+	file, err := Parse("test.gox", []byte(src))
+	if err != nil {
+		t.Fatalf("Parse error: %v", err)
+	}
+
+	elem := file.Nodes[0].(*ast.JSXElement)
+	var got []string
+	for _, attr := range elem.Attributes {
+		r := attr.GetRange()
+		switch a := attr.(type) {
+		case *ast.SpreadAttribute:
+			e := a.ExpressionRange
+			got = append(got, fmt.Sprintf("{...%s} %d-%d %d-%d", a.Expression, r.Start.Column, r.End.Column, e.Start.Column, e.End.Column))
+		default:
+			got = append(got, fmt.Sprintf("%s %d-%d", attributeKey(a), r.Start.Column, r.End.Column))
+		}
+	}
+	want := "{...a} 6-12 10-11, x 13-18, {...b} 19-27 24-25, y 28-33"
+	if s := strings.Join(got, ", "); s != want {
+		t.Errorf("Attributes = %s, want %s", s, want)
+	}
+}
+
 func TestParseSpreadAttributeError(t *testing.T) {
-	src := `<box {...props}></box>`
+	src := `<Box {...props}></Box>`
 
 	_, err := Parse("test.gox", []byte(src))
 	if err == nil {
-		t.Fatal("Expected error for spread attribute, got nil")
+		t.Fatal("Expected error for spread attribute on a component, got nil")
 	}
 
-	if !strings.Contains(err.Error(), "spread attributes are not supported") {
+	if !strings.Contains(err.Error(), "spread attributes are not supported on components") {
 		t.Errorf("Expected spread error message, got: %v", err)
 	}
 }
 
+func TestParseUnclosedElementError(t *testing.T) {
+	// The stray '}' must not stall the lexer while the element is unclosed
+	src := "func App() gox.VNode {\n\treturn <div>\n}\n"
+
+	_, err := Parse("test.gox", []byte(src))
+	if err == nil {
+		t.Fatal("Expected error for unclosed element, got nil")
+	}
+
+	if !strings.Contains(err.Error(), "unclosed element <div>") {
+		t.Errorf("Expected unclosed element error message, got: %v", err)
+	}
+	if d, ok := diag.As(err); !ok || d.Code != diag.UnclosedElement {
+		t.Errorf("Expected %s diagnostic, got: %#v", diag.UnclosedElement, err)
+	}
+}
+
+func TestParseMismatchedClosingTagError(t *testing.T) {
+	src := "var x = <div>\n\t<span>a</div>\n"
+
+	_, err := Parse("test.gox", []byte(src))
+	d, ok := diag.As(err)
+	if !ok || d.Code != diag.MismatchedClosingTag {
+		t.Fatalf("Expected %s diagnostic, got: %v", diag.MismatchedClosingTag, err)
+	}
+	if d.Line != 2 || d.Column != 11 || d.EndLine != 2 || d.EndColumn != 14 {
+		t.Errorf("Expected range 2:11-2:14, got %d:%d-%d:%d", d.Line, d.Column, d.EndLine, d.EndColumn)
+	}
+	if want := "did you forget to close <span>, opened on line 2?"; d.Hint != want {
+		t.Errorf("Hint = %q, want %q", d.Hint, want)
+	}
+}
+
+func TestParseClosingTagFixes(t *testing.T) {
+	tests := []struct {
+		name string
+		src  string
+		code diag.Code
+		fix  diag.Fix
+	}{
+		{
+			name: "closes outer element",
+			src:  "var x = <ul>\n\t<li>a</ul>\n",
+			code: diag.MismatchedClosingTag,
+			fix:  diag.Fix{Message: "insert </li>", Line: 2, Column: 7, EndLine: 2, EndColumn: 7, Text: "</li>"},
+		},
+		{
+			name: "closes nothing",
+			src:  "var x = <div>a</span>\n",
+			code: diag.MismatchedClosingTag,
+			fix:  diag.Fix{Message: "replace </span> with </div>", Line: 1, Column: 15, EndLine: 1, EndColumn: 22, Text: "</div>"},
+		},
+		{
+			name: "fragment close",
+			src:  "var x = <><b>a</>\n",
+			code: diag.UnclosedElement,
+			fix:  diag.Fix{Message: "insert </b>", Line: 1, Column: 15, EndLine: 1, EndColumn: 15, Text: "</b>"},
+		},
+		{
+			name: "fragment closed by element",
+			src:  "var x = <div><>a</div>\n",
+			code: diag.MismatchedClosingTag,
+			fix:  diag.Fix{Message: "insert </>", Line: 1, Column: 17, EndLine: 1, EndColumn: 17, Text: "</>"},
+		},
+		{
+			name: "element at end of file",
+			src:  "func App() gox.VNode {\n\treturn <div>\n\t\t<p>a</p> b\n}\n",
+			code: diag.UnclosedElement,
+			fix:  diag.Fix{Message: "insert </div>", Line: 3, Column: 13, EndLine: 3, EndColumn: 13, Text: "</div>"},
+		},
+		{
+			name: "fragment at end of file",
+			src:  "func App() gox.VNode {\n\treturn <>\n}\n",
+			code: diag.UnclosedElement,
+			fix:  diag.Fix{Message: "insert </>", Line: 2, Column: 11, EndLine: 2, EndColumn: 11, Text: "</>"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := Parse("test.gox", []byte(tt.src))
+			d, ok := diag.As(err)
+			if !ok || d.Code != tt.code {
+				t.Fatalf("Expected %s diagnostic, got: %v", tt.code, err)
+			}
+			if d.Fix == nil || *d.Fix != tt.fix {
+				t.Errorf("Fix = %+v, want %+v", d.Fix, tt.fix)
+			}
+		})
+	}
+}
+
+func TestParseRecoversFromMissingClosingTag(t *testing.T) {
+	// The <li> closes before </ul>, and the Go code after the element
+	// parses as Go code
+	src := "var x = <ul><li>a</ul>\nvar y = 1\n"
+	file, err := Parse("test.gox", []byte(src))
+	if err == nil {
+		t.Fatal("Expected error for the missing </li>, got nil")
+	}
+	if len(file.Nodes) != 3 {
+		t.Fatalf("Expected 3 nodes, got %d", len(file.Nodes))
+	}
+	ul := file.Nodes[1].(*ast.JSXElement)
+	if li := ul.Children[0].(*ast.JSXElement); li.Tag != "li" || li.Range.End.Offset != strings.Index(src, "</ul>") {
+		t.Errorf("Expected <li> ending at </ul>, got <%s> ending at %d", li.Tag, li.Range.End.Offset)
+	}
+	if code, ok := file.Nodes[2].(*ast.GoCode); !ok || code.Value != "\nvar y = 1\n" {
+		t.Errorf("Expected Go code after the element, got %#v", file.Nodes[2])
+	}
+}
+
+const optionsSource = `package app
+
+// Card shows a card.
+func Card() gox.VNode {
+	return <div>{/* body */}<p>a</p></div>
+}
+`
+
+func TestParseWithOptionsDefaults(t *testing.T) {
+	want, _ := Parse("test.gox", []byte(optionsSource))
+	wantJSON, _ := json.Marshal(want)
+	for _, opts := range []*Options{nil, {}, {MaxErrors: 10}} {
+		got, err := ParseWithOptions("test.gox", []byte(optionsSource), opts)
+		if err != nil {
+			t.Fatalf("ParseWithOptions error: %v", err)
+		}
+		gotJSON, _ := json.Marshal(got)
+		if string(gotJSON) != string(wantJSON) {
+			t.Errorf("ParseWithOptions(%+v) differs from Parse\ngot:  %s\nwant: %s", opts, gotJSON, wantJSON)
+		}
+	}
+}
+
+func TestParseWithoutComments(t *testing.T) {
+	file, err := ParseWithOptions("test.gox", []byte(optionsSource), &Options{DropComments: true})
+	if err != nil {
+		t.Fatalf("ParseWithOptions error: %v", err)
+	}
+	div := file.Nodes[1].(*ast.JSXElement)
+	if len(div.Children) != 1 || file.Components[0].Doc != "" {
+		t.Errorf("Expected comments dropped, got %d children, component doc %q", len(div.Children), file.Components[0].Doc)
+	}
+
+	// A lossless tree keeps them
+	file, err = ParseWithOptions("test.gox", []byte(optionsSource), &Options{DropComments: true, Mode: Trivia})
+	if err != nil {
+		t.Fatalf("ParseWithOptions error: %v", err)
+	}
+	if div := file.Nodes[1].(*ast.JSXElement); len(div.Children) != 2 || file.Components[0].Doc != "Card shows a card.\n" {
+		t.Errorf("Expected comments kept in Trivia mode, got %d children, component doc %q", len(div.Children), file.Components[0].Doc)
+	}
+}
+
+func TestParseMaxErrors(t *testing.T) {
+	src := "var x = <div {a} {b} {c} />\nvar y = 1\n"
+	count := func(err error) int {
+		if joined, ok := err.(interface{ Unwrap() []error }); ok {
+			return len(joined.Unwrap())
+		}
+		if err != nil {
+			return 1
+		}
+		return 0
+	}
+
+	_, first := Parse("test.gox", []byte(src))
+	for _, tt := range []struct{ max, want int }{{0, 1}, {1, 1}, {2, 2}, {10, 3}} {
+		_, err := ParseWithOptions("test.gox", []byte(src), &Options{MaxErrors: tt.max})
+		if got := count(err); got != tt.want {
+			t.Errorf("MaxErrors %d: got %d errors, want %d: %v", tt.max, got, tt.want, err)
+		}
+		if d, ok := diag.As(err); !ok || d.Error() != first.Error() {
+			t.Errorf("MaxErrors %d: first error %v, want %v", tt.max, d, first)
+		}
+	}
+
+	// The parse stops at the limit
+	file, _ := ParseWithOptions("test.gox", []byte(src), &Options{MaxErrors: 1})
+	if len(file.Nodes) != 2 {
+		t.Errorf("Expected the parse to stop in the element, got %d nodes", len(file.Nodes))
+	}
+}
+
+func TestParseStrictIntrinsics(t *testing.T) {
+	tests := []struct {
+		tag  string
+		want bool
+	}{
+		{"div", true},
+		{"clipPath", true},
+		{"my-element", true},
+		{"h1", true},
+		{"Card", true},
+		{"ui.Card", true},
+		{"card_item", false},
+		{"_row", false},
+		{"café", false},
+	}
+	for _, tt := range tests {
+		src := "var x = <" + tt.tag + " />\n"
+		_, err := ParseWithOptions("test.gox", []byte(src), &Options{StrictIntrinsics: true})
+		if tt.want && err != nil {
+			t.Errorf("<%s>: unexpected error %v", tt.tag, err)
+		}
+		if d, ok := diag.As(err); !tt.want && (!ok || d.Code != diag.IntrinsicTag) {
+			t.Errorf("<%s>: expected %s diagnostic, got %v", tt.tag, diag.IntrinsicTag, err)
+		}
+		if _, err := Parse("test.gox", []byte(src)); err != nil {
+			t.Errorf("<%s>: Parse error %v", tt.tag, err)
+		}
+	}
+}
+
+func TestParseUnterminatedError(t *testing.T) {
+	tests := []struct {
+		src  string
+		line int
+		col  int
+	}{
+		{"var x = <div a=\"b></div>\n", 1, 16},
+		{"var x = <div a=\"", 1, 16},
+		{"var x = <div>\n\t{a</div>\n", 2, 2},
+		{"var x = <div title={<b>{x</b>} />\n", 1, 24},
+	}
+	for _, tt := range tests {
+		_, err := Parse("test.gox", []byte(tt.src))
+		d, ok := diag.As(err)
+		if !ok || d.Code != diag.Unterminated || d.Line != tt.line || d.Column != tt.col {
+			t.Errorf("%q: expected a %s diagnostic at %d:%d, got %v", tt.src, diag.Unterminated, tt.line, tt.col, err)
+		}
+	}
+}
+
+func TestParseVoidElements(t *testing.T) {
+	src := "//gox:void br input\npackage form\n\nvar x = <p>a<br></br><input>\n\t</input><Input /></p>\n"
+
+	file, err := Parse("test.gox", []byte(src))
+	if err != nil {
+		t.Fatalf("Parse error: %v", err)
+	}
+	p := file.Nodes[1].(*ast.JSXElement)
+	for _, child := range p.Children {
+		elem, ok := child.(*ast.JSXElement)
+		if !ok {
+			continue
+		}
+		if want := elem.Tag != "Input"; elem.Void != want {
+			t.Errorf("<%s>: Void = %v, want %v", elem.Tag, elem.Void, want)
+		}
+		if len(elem.Children) != 0 {
+			t.Errorf("<%s>: expected whitespace children to be dropped, got %#v", elem.Tag, elem.Children)
+		}
+	}
+
+	tests := []struct {
+		src  string
+		line int
+		col  int
+	}{
+		{"//gox:void br\npackage form\n\nvar x = <br>text</br>\n", 4, 13},
+		{"//gox:void input\npackage form\n\nvar x = <input>\n\t{/* note */}\n</input>\n", 5, 2},
+		{"//gox:void input\npackage form\n\nvar x = <input type=\"text\" children={x} />\n", 4, 28},
+	}
+	for _, tt := range tests {
+		_, err := Parse("test.gox", []byte(tt.src))
+		d, ok := diag.As(err)
+		if !ok || d.Code != diag.VoidChildren || d.Line != tt.line || d.Column != tt.col {
+			t.Errorf("%q: expected a %s diagnostic at %d:%d, got %v", tt.src, diag.VoidChildren, tt.line, tt.col, err)
+		}
+	}
+}
+
+func TestParseGenericComponent(t *testing.T) {
+	src := `<List[ string ] items={names}><b>x</b></List[string]>`
+
+	file, err := Parse("test.gox", []byte(src))
+	if err != nil {
+		t.Fatalf("Parse error: %v", err)
+	}
+
+	elem := file.Nodes[0].(*ast.JSXElement)
+	if elem.Tag != "List" {
+		t.Errorf("Expected tag 'List', got %q", elem.Tag)
+	}
+	if elem.TypeArgs != "string" {
+		t.Errorf("Expected type args 'string', got %q", elem.TypeArgs)
+	}
+	if len(elem.Attributes) != 1 || len(elem.Children) != 1 {
+		t.Errorf("Expected 1 attribute and 1 child, got %d and %d", len(elem.Attributes), len(elem.Children))
+	}
+}
+
+func TestParseTypeArgumentErrors(t *testing.T) {
+	tests := []struct {
+		src  string
+		want string
+	}{
+		{`<div[string]></div>`, "type arguments on intrinsic element <div>"},
+		{`<List[string]></List[int]>`, "mismatched type arguments"},
+		{`<List[] />`, "empty type arguments"},
+		{`<List[string />`, "unclosed type arguments"},
+	}
+
+	for _, tt := range tests {
+		_, err := Parse("test.gox", []byte(tt.src))
+		if err == nil {
+			t.Errorf("%s: expected error, got nil", tt.src)
+			continue
+		}
+		if !strings.Contains(err.Error(), tt.want) {
+			t.Errorf("%s: expected %q, got: %v", tt.src, tt.want, err)
+		}
+		if d, ok := diag.As(err); !ok || d.Code != diag.InvalidTypeArguments {
+			t.Errorf("%s: expected %s diagnostic, got: %#v", tt.src, diag.InvalidTypeArguments, err)
+		}
+	}
+}
+
 func TestParseMultipleAttributes(t *testing.T) {
 	src := `<box direction="row" gap={1} wrap></box>`
 
@@ -0,0 +1,256 @@
+package parser
+
+import (
+	"maps"
+	"unicode/utf8"
+
+	"github.com/germtb/gox/ast"
+	"github.com/germtb/gox/lexer"
+)
+
+// TextEdit replaces the bytes from offset Start to End of a source with
+// Text, as a change of an editor's document.
+type TextEdit struct {
+	Start int    `json:"start"`
+	End   int    `json:"end"`
+	Text  string `json:"text"`
+}
+
+// Reparse returns the tree of newSrc, the source of oldFile with edits
+// applied in order, each to the result of those before it, as the content
+// changes of an LSP didChange notification. The result is that of
+// Parse(oldFile.SourcePath, newSrc), but only the top-level nodes from the
+// one the edits start in are lexed and parsed again, up to the first node
+// after the edits that starts where one of oldFile did; the nodes from
+// there on are those of oldFile, moved to their new positions.
+//
+// oldFile must be the tree Parse or Reparse returned, without an error, for
+// the source before the edits. It is not modified, and shares the nodes
+// before the edits with the result. Edits out of range, and edits to the
+// //gox:raw and //gox:void directives, which change how the whole file
+// parses, parse newSrc in full.
+func Reparse(oldFile *ast.GoxFile, edits []TextEdit, newSrc []byte) (*ast.GoxFile, error) {
+	old := oldFile.Nodes
+	prefix, suffix, oldLen, ok := unchanged(edits, len(newSrc))
+	if !ok || len(old) == 0 || old[len(old)-1].GetRange().End.Offset != oldLen {
+		return Parse(oldFile.SourcePath, newSrc)
+	}
+	src := string(newSrc)
+	i := 0 // First node the edits may change
+	for i < len(old) && old[i].GetRange().End.Offset+lookahead <= prefix {
+		i++
+	}
+	if i == 0 && !sameElements(old[0], src) {
+		return Parse(oldFile.SourcePath, newSrc)
+	}
+	start := old[i].GetRange().Start
+	delta := len(newSrc) - oldLen
+
+	p := &Parser{
+		src:      src,
+		lex:      lexer.NewRange(src, start.Offset, len(src), start.Line, start.Column),
+		void:     lexer.VoidElements(src),
+		filename: oldFile.SourcePath,
+	}
+	p.advance()
+
+	file := &ast.GoxFile{
+		Nodes:      append([]ast.Node{}, old[:i]...),
+		SourcePath: oldFile.SourcePath,
+	}
+	var prev ast.Node
+	if i > 0 {
+		// The header is unchanged
+		file.Package, file.PackageRange = oldFile.Package, oldFile.PackageRange
+		file.Imports, file.Directives = oldFile.Imports, oldFile.Directives
+		prev = old[i-1]
+	}
+	j := i // First node of oldFile that may follow
+	for p.tok.Type != lexer.TOKEN_EOF {
+		node := p.parseNode()
+		if node != nil {
+			attachDoc(prev, node)
+			file.Nodes = append(file.Nodes, node)
+			prev = node
+		}
+		if len(p.errors) > 0 {
+			continue // Parse to the end, for the errors of Parse
+		}
+
+		// The rest of the source is unchanged from b on
+		b := p.tok.Offset - delta
+		if b < oldLen-suffix {
+			continue
+		}
+		for j < len(old) && old[j].GetRange().Start.Offset < b {
+			j++
+		}
+		if j == len(old) || old[j].GetRange().Start.Offset != b || !sameStart(old[j], p.tok) {
+			continue
+		}
+		at := old[j].GetRange().Start
+		s := shift{
+			bytes:   delta,
+			lines:   p.tok.Line - at.Line,
+			line:    at.Line,
+			columns: p.tok.Column - at.Column,
+		}
+		for k, node := range old[j:] {
+			node = s.node(node)
+			if k == 0 {
+				node = withDoc(prev, node)
+			}
+			file.Nodes = append(file.Nodes, node)
+			prev = node
+		}
+		break
+	}
+	if i == 0 {
+		parseHeader(file)
+	}
+	file.Components = findComponents(file.Nodes)
+
+	if len(p.errors) > 0 {
+		return file, p.errors[0]
+	}
+	return file, nil
+}
+
+// lookahead is the number of bytes after the end of Go code that decide
+// where it ends: those lexer.IsJSXStart reads.
+const lookahead = 2 + utf8.UTFMax
+
+// unchanged returns the number of bytes at the start and at the end of a
+// source that edits leave as they are, and its length, given the length of
+// the result. It reports false if an edit is out of range.
+func unchanged(edits []TextEdit, length int) (prefix, suffix, oldLen int, ok bool) {
+	// The length of the source before each edit
+	lengths := make([]int, len(edits))
+	for k := len(edits) - 1; k >= 0; k-- {
+		e := edits[k]
+		length -= len(e.Text) - (e.End - e.Start)
+		lengths[k] = length
+	}
+	prefix, suffix = length, length
+	for k, e := range edits {
+		if e.Start < 0 || e.End < e.Start || e.End > lengths[k] {
+			return 0, 0, 0, false
+		}
+		prefix = min(prefix, e.Start)
+		suffix = min(suffix, lengths[k]-e.End)
+	}
+	// An edit inside text it replaces counts once
+	suffix = min(suffix, length-prefix)
+	return prefix, suffix, length, true
+}
+
+// sameElements reports whether the directives of src name the raw and void
+// elements that those of header, the first node of the old tree, did, so
+// that the nodes after it lex and parse as they did.
+func sameElements(header ast.Node, src string) bool {
+	var text string
+	if code, ok := header.(*ast.GoCode); ok {
+		text = code.Value
+	}
+	return maps.Equal(lexer.RawElements(text), lexer.RawElements(src)) &&
+		maps.Equal(lexer.VoidElements(text), lexer.VoidElements(src))
+}
+
+// sameStart reports whether node starts as the node whose first token is
+// tok: both are Go code, or both are JSX.
+func sameStart(node ast.Node, tok lexer.Token) bool {
+	_, code := node.(*ast.GoCode)
+	return code == (tok.Type == lexer.TOKEN_GO_CODE)
+}
+
+// withDoc returns node, an element kept from the old tree, with the doc
+// comment of the Go code prev before it, copying it if that changed.
+func withDoc(prev, node ast.Node) ast.Node {
+	elem, ok := node.(*ast.JSXElement)
+	if !ok {
+		return node
+	}
+	doc := ""
+	if code, ok := prev.(*ast.GoCode); ok {
+		doc = docComment(code.Value)
+	}
+	if elem.Doc == doc {
+		return node
+	}
+	c := *elem
+	c.Doc = doc
+	return &c
+}
+
+// shift moves the nodes after an edit: by bytes and lines, and on line,
+// where the first of them starts, by columns.
+type shift struct {
+	bytes, lines  int
+	line, columns int
+}
+
+// node returns a copy of node moved by s, or node itself if s is empty.
+func (s shift) node(node ast.Node) ast.Node {
+	if s == (shift{line: s.line}) {
+		return node
+	}
+	return ast.Rewrite(node, func(n ast.Node) ast.Node {
+		switch n := n.(type) {
+		case *ast.GoCode:
+			c := *n
+			c.Range = s.rng(c.Range)
+			return &c
+		case *ast.JSXElement:
+			c := *n
+			c.Range, c.TagRange, c.CloseTagRange = s.rng(c.Range), s.rng(c.TagRange), s.rng(c.CloseTagRange)
+			return &c
+		case *ast.JSXFragment:
+			c := *n
+			c.Range = s.rng(c.Range)
+			return &c
+		case *ast.StringAttribute:
+			c := *n
+			c.Range, c.KeyRange, c.EqualsRange, c.ValueRange = s.rng(c.Range), s.rng(c.KeyRange), s.rng(c.EqualsRange), s.rng(c.ValueRange)
+			return &c
+		case *ast.ExpressionAttribute:
+			c := *n
+			c.Range, c.KeyRange, c.EqualsRange, c.ValueRange = s.rng(c.Range), s.rng(c.KeyRange), s.rng(c.EqualsRange), s.rng(c.ValueRange)
+			return &c
+		case *ast.SpreadAttribute:
+			c := *n
+			c.Range, c.ExpressionRange = s.rng(c.Range), s.rng(c.ExpressionRange)
+			return &c
+		case *ast.JSXText:
+			c := *n
+			c.Range = s.rng(c.Range)
+			return &c
+		case *ast.JSXExpression:
+			c := *n
+			c.Range = s.rng(c.Range)
+			return &c
+		case *ast.JSXComment:
+			c := *n
+			c.Range = s.rng(c.Range)
+			return &c
+		}
+		return n
+	})
+}
+
+// rng returns r moved by s. An unset range stays unset.
+func (s shift) rng(r ast.Range) ast.Range {
+	return ast.NewRange(s.position(r.Start), s.position(r.End))
+}
+
+// position returns p moved by s.
+func (s shift) position(p ast.Position) ast.Position {
+	if !p.IsValid() {
+		return p
+	}
+	if p.Line == s.line {
+		p.Column += s.columns
+	}
+	p.Offset += s.bytes
+	p.Line += s.lines
+	return p
+}
@@ -0,0 +1,155 @@
+package parser
+
+import (
+	"encoding/json"
+	"fmt"
+	"math/rand"
+	"strings"
+	"testing"
+
+	"github.com/germtb/gox/ast"
+)
+
+// applyEdits applies edits to src in order, as Reparse expects them.
+func applyEdits(src string, edits []TextEdit) string {
+	for _, e := range edits {
+		src = src[:e.Start] + e.Text + src[e.End:]
+	}
+	return src
+}
+
+// checkReparse reports whether Reparse of the tree of src with edits gives
+// what Parse gives for the edited source.
+func checkReparse(t *testing.T, src string, edits []TextEdit) *ast.GoxFile {
+	t.Helper()
+	old, err := Parse("test.gox", []byte(src))
+	if err != nil {
+		t.Fatalf("Parse error: %v", err)
+	}
+	newSrc := applyEdits(src, edits)
+	got, gotErr := Reparse(old, edits, []byte(newSrc))
+	want, wantErr := Parse("test.gox", []byte(newSrc))
+	if fmt.Sprint(gotErr) != fmt.Sprint(wantErr) {
+		t.Errorf("Reparse error %v, want %v\nsource:\n%s", gotErr, wantErr, newSrc)
+	}
+	gotJSON, _ := json.Marshal(got)
+	wantJSON, _ := json.Marshal(want)
+	if string(gotJSON) != string(wantJSON) {
+		t.Errorf("Reparse differs from Parse\nsource:\n%s\ngot:  %s\nwant: %s", newSrc, gotJSON, wantJSON)
+	}
+	return got
+}
+
+const reparseSource = `//gox:void br
+package app
+
+// Title shows a title.
+func Title(props TitleProps) gox.VNode {
+	return <h1 class="title">{props.Text}</h1>
+}
+
+func List(items []string) gox.VNode {
+	return <ul>
+		{gox.Map(items, func(item string) gox.VNode {
+			return <li>{item}<br /></li>
+		})}
+	</ul>
+}
+
+// Footer ends the page.
+func Footer() gox.VNode {
+	return <>
+		<p>The end</p>
+	</>
+}
+`
+
+func TestReparse(t *testing.T) {
+	at := func(s string) int { return strings.Index(reparseSource, s) }
+	tests := []struct {
+		name  string
+		edits []TextEdit
+	}{
+		{"no edits", nil},
+		{"text", []TextEdit{{Start: at("The end"), End: at("The end") + 3, Text: "An"}}},
+		{"attribute", []TextEdit{{Start: at(`"title"`), End: at(`"title"`), Text: "\n\t\t"}}},
+		{"go code", []TextEdit{{Start: at("items []string"), End: at("items []string") + 5, Text: "values"}}},
+		{"lines added", []TextEdit{{Start: at("func List"), End: at("func List"), Text: "var x = 1\n\n"}}},
+		{"element added", []TextEdit{{Start: at("\n}\n\nfunc List"), End: at("\n}\n\nfunc List"), Text: "\n\t_ = <hr />"}}},
+		{"element removed", []TextEdit{{Start: at("<>"), End: at("</>") + 3, Text: "nil"}}},
+		{"doc comment", []TextEdit{{Start: at("func Footer"), End: at("func Footer"), Text: "//\n// More.\n"}}},
+		{"header", []TextEdit{{Start: at("package app"), End: at("package app"), Text: "//gox:runtime example.com/ui\n"}}},
+		{"void directive", []TextEdit{{Start: 0, End: len("//gox:void br"), Text: "//gox:void"}}},
+		{"unclosed", []TextEdit{{Start: at("</h1>"), End: at("</h1>") + 5, Text: ""}}},
+		{"several", []TextEdit{
+			{Start: at("The end"), End: at("The end"), Text: "Really "},
+			{Start: at("<h1"), End: at("<h1") + 3, Text: "<h2"},
+			{Start: at("</h1>"), End: at("</h1>") + 5, Text: "</h2>"},
+		}},
+		{"at the end", []TextEdit{{Start: len(reparseSource), End: len(reparseSource), Text: "\nvar _ = <Footer />\n"}}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			checkReparse(t, reparseSource, tt.edits)
+		})
+	}
+}
+
+func TestReparseKeepsNodes(t *testing.T) {
+	old, err := Parse("test.gox", []byte(reparseSource))
+	if err != nil {
+		t.Fatalf("Parse error: %v", err)
+	}
+	// An edit of the same length moves nothing
+	start := strings.Index(reparseSource, "items []string")
+	edits := []TextEdit{{Start: start, End: start + 5, Text: "lines"}}
+	file, err := Reparse(old, edits, []byte(applyEdits(reparseSource, edits)))
+	if err != nil {
+		t.Fatalf("Reparse error: %v", err)
+	}
+	if len(file.Nodes) != len(old.Nodes) {
+		t.Fatalf("Expected %d nodes, got %d", len(old.Nodes), len(file.Nodes))
+	}
+	reparsed := 0
+	for i, node := range file.Nodes {
+		if node != old.Nodes[i] {
+			reparsed++
+		}
+	}
+	if reparsed != 1 {
+		t.Errorf("Expected only the edited node parsed again, got %d", reparsed)
+	}
+}
+
+func TestReparseRandomEdits(t *testing.T) {
+	src := string(largeSource(3))
+	rng := rand.New(rand.NewSource(1))
+	inserts := []string{"", "x", " ", "\n", "<", ">", "{", "}", "\"", "<b>", "</b>", "é"}
+	for n := 0; n < 300; n++ {
+		var edits []TextEdit
+		doc := src
+		for k := rng.Intn(3) + 1; k > 0; k-- {
+			start := rng.Intn(len(doc) + 1)
+			end := min(start+rng.Intn(4), len(doc))
+			e := TextEdit{Start: start, End: end, Text: inserts[rng.Intn(len(inserts))]}
+			edits = append(edits, e)
+			doc = applyEdits(doc, []TextEdit{e})
+		}
+		checkReparse(t, src, edits)
+		if t.Failed() {
+			t.Fatalf("edits: %#v", edits)
+		}
+	}
+}
+
+func TestReparseOutOfRange(t *testing.T) {
+	old, err := Parse("test.gox", []byte("package app\n"))
+	if err != nil {
+		t.Fatalf("Parse error: %v", err)
+	}
+	// The edit does not fit the old source, which is parsed in full
+	file, err := Reparse(old, []TextEdit{{Start: 40, End: 50, Text: ""}}, []byte("package other\n"))
+	if err != nil || file.Package != "other" {
+		t.Errorf("Expected package other, got %q, %v", file.Package, err)
+	}
+}
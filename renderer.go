@@ -1,5 +1,7 @@
 package gox
 
+import "context"
+
 // Renderer is the interface for connecting VNode trees to actual implementations.
 // Users implement this interface to bridge gox output to their tree system
 // (e.g., TUI libraries, HTML DOM, custom tree structures).
@@ -16,6 +18,103 @@ func (f RenderFunc) Render(vnode VNode) error {
 	return f(vnode)
 }
 
+// Middleware wraps a Renderer to add cross-cutting behavior, such as
+// logging, metrics or dev-time checks, around every render. It may
+// transform the tree before calling next, handle next's error, or not call
+// next at all. Middleware that returns a ContextRenderFunc and calls next
+// with CallRenderer keeps the render context flowing through the chain.
+type Middleware func(next Renderer) Renderer
+
+// Chain wraps r with middleware. The first middleware is the outermost: it
+// sees each tree first and next's result last. The returned renderer allows
+// Unsafe nodes exactly when r does, whatever the middleware returns.
+//
+//	r := gox.Chain(htmlRenderer, logRenders, injectTheme(dark))
+func Chain(r Renderer, middleware ...Middleware) Renderer {
+	wrapped := r
+	for i := len(middleware) - 1; i >= 0; i-- {
+		wrapped = middleware[i](wrapped)
+	}
+	if u, ok := r.(UnsafeRenderer); ok && wrapped != r {
+		return chainedUnsafeRenderer{Renderer: wrapped, base: u}
+	}
+	return wrapped
+}
+
+// chainedUnsafeRenderer carries the opt-in of the renderer at the end of a
+// chain to the outside of the chain.
+type chainedUnsafeRenderer struct {
+	Renderer
+	base UnsafeRenderer
+}
+
+func (r chainedUnsafeRenderer) AllowsUnsafe() bool {
+	return r.base.AllowsUnsafe()
+}
+
+func (r chainedUnsafeRenderer) RenderContext(ctx context.Context, vnode VNode) error {
+	return CallRenderer(ctx, r.Renderer, vnode)
+}
+
+// UnsafeRenderer is implemented by renderers that opt in to rendering
+// Unsafe nodes verbatim, typically HTML renderers used for SSR.
+type UnsafeRenderer interface {
+	Renderer
+	// AllowsUnsafe reports whether the renderer renders Unsafe nodes.
+	AllowsUnsafe() bool
+}
+
+// Render renders root with r. Unsafe nodes are removed from the tree first,
+// unless r is an UnsafeRenderer that allows them, so renderers that do not
+// know about raw HTML never see it.
+func Render(r Renderer, root VNode) error {
+	return RenderContext(context.Background(), r, root)
+}
+
+// StripUnsafe returns root with every Unsafe node replaced by an empty node.
+// Components are wrapped so that the trees they render are stripped too
+// when they are expanded. Subtrees without Unsafe nodes or components are
+// shared with root, not copied.
+func StripUnsafe(root VNode) VNode {
+	stripped, _ := stripUnsafe(root)
+	return stripped
+}
+
+// stripUnsafe implements StripUnsafe, reporting whether node changed.
+func stripUnsafe(node VNode) (VNode, bool) {
+	if node.IsUnsafe() {
+		return Empty(), true
+	}
+	switch c := node.Type.(type) {
+	case Component:
+		node.Type = Component(func(props Props) VNode {
+			return StripUnsafe(c(props))
+		})
+		return node, true
+	case ContextComponent:
+		node.Type = ContextComponent(func(ctx context.Context, props Props) VNode {
+			return StripUnsafe(c(ctx, props))
+		})
+		return node, true
+	}
+	var children []VNode
+	for i, child := range node.Children {
+		stripped, changed := stripUnsafe(child)
+		if changed && children == nil {
+			children = make([]VNode, len(node.Children))
+			copy(children, node.Children)
+		}
+		if children != nil {
+			children[i] = stripped
+		}
+	}
+	if children == nil {
+		return node, false
+	}
+	node.Children = children
+	return node, true
+}
+
 // Walker provides a way to traverse VNode trees.
 type Walker interface {
 	// Walk is called for each node in the tree.
@@ -0,0 +1,175 @@
+package rendertest
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/germtb/gox"
+)
+
+// Recorder is a mock renderer that records the trees it is asked to render.
+// It implements gox.ContextRenderer and gox.UnsafeRenderer, and is safe for
+// concurrent use. The zero value is ready to use.
+//
+//	rec := &rendertest.Recorder{}
+//	handler := NewHandler(rec)
+//	...
+//	if got := rendertest.Dump(rec.Last()); got != `<h1>"Hello"</h1>` { ... }
+type Recorder struct {
+	// W, if set, receives the Dump of every tree rendered, so a Recorder can
+	// stand in for a writing renderer.
+	W io.Writer
+
+	// Err, if set, is returned by every render, after recording the tree.
+	Err error
+
+	// AllowUnsafe opts in to gox.Unsafe nodes; without it gox.Render removes
+	// them before the Recorder sees the tree.
+	AllowUnsafe bool
+
+	mu    sync.Mutex
+	trees []gox.VNode
+	ctxs  []context.Context
+}
+
+// Render records vnode.
+func (r *Recorder) Render(vnode gox.VNode) error {
+	return r.RenderContext(context.Background(), vnode)
+}
+
+// RenderContext records vnode and ctx. It returns ctx.Err() without
+// recording anything if ctx is done.
+func (r *Recorder) RenderContext(ctx context.Context, vnode gox.VNode) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	r.mu.Lock()
+	r.trees = append(r.trees, vnode)
+	r.ctxs = append(r.ctxs, ctx)
+	r.mu.Unlock()
+
+	if r.W != nil {
+		if _, err := io.WriteString(r.W, DumpContext(ctx, vnode)); err != nil {
+			return err
+		}
+	}
+	return r.Err
+}
+
+// AllowsUnsafe implements gox.UnsafeRenderer.
+func (r *Recorder) AllowsUnsafe() bool {
+	return r.AllowUnsafe
+}
+
+// Trees returns the trees rendered so far, in order.
+func (r *Recorder) Trees() []gox.VNode {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return append([]gox.VNode(nil), r.trees...)
+}
+
+// Last returns the last tree rendered, or an empty node if there is none.
+func (r *Recorder) Last() gox.VNode {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if len(r.trees) == 0 {
+		return gox.Empty()
+	}
+	return r.trees[len(r.trees)-1]
+}
+
+// LastContext returns the context of the last render, or nil if there is
+// none.
+func (r *Recorder) LastContext() context.Context {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if len(r.ctxs) == 0 {
+		return nil
+	}
+	return r.ctxs[len(r.ctxs)-1]
+}
+
+// Reset forgets the trees rendered so far.
+func (r *Recorder) Reset() {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.trees, r.ctxs = nil, nil
+}
+
+// Dump returns a canonical text form of node, the way every conforming
+// renderer sees it: components are expanded, fragments and cached subtrees
+// are flattened into their parent, empty nodes are dropped, and the key prop
+// is omitted. Elements are written as <tag name=value>children</tag> with
+// props sorted by name, text and string props as Go string literals, and
+// Unsafe nodes as unsafe("html").
+func Dump(node gox.VNode) string {
+	return DumpContext(context.Background(), node)
+}
+
+// DumpContext is Dump with the context passed to context components.
+func DumpContext(ctx context.Context, node gox.VNode) string {
+	var sb strings.Builder
+	dump(ctx, &sb, node)
+	return sb.String()
+}
+
+func dump(ctx context.Context, sb *strings.Builder, node gox.VNode) {
+	if node.IsEmpty() {
+		return
+	}
+	if content, ok := node.GetTextContent(); ok {
+		sb.WriteString(strconv.Quote(content))
+		return
+	}
+	if html, ok := node.GetUnsafeHTML(); ok {
+		fmt.Fprintf(sb, "unsafe(%s)", strconv.Quote(html))
+		return
+	}
+	if node.IsFragment() || node.IsCached() {
+		for _, child := range node.Children {
+			dump(ctx, sb, child)
+		}
+		return
+	}
+	if expanded, ok := gox.Expand(ctx, node); ok {
+		dump(ctx, sb, expanded)
+		return
+	}
+
+	tag := fmt.Sprint(node.Type)
+	sb.WriteString("<" + tag)
+	names := make([]string, 0, len(node.Props))
+	for name := range node.Props {
+		if name != "key" {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		sb.WriteString(" " + name + "=")
+		switch v := node.Props[name].(type) {
+		case string:
+			sb.WriteString(strconv.Quote(v))
+		case nil:
+			sb.WriteString("nil")
+		default:
+			if reflect.TypeOf(v).Kind() == reflect.Func {
+				// Addresses are not stable across builds
+				sb.WriteString("func")
+				continue
+			}
+			fmt.Fprint(sb, v)
+		}
+	}
+	sb.WriteString(">")
+	for _, child := range node.Children {
+		dump(ctx, sb, child)
+	}
+	sb.WriteString("</" + tag + ">")
+}
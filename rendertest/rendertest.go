@@ -0,0 +1,167 @@
+// Package rendertest checks that renderers follow the semantics of gox
+// trees, and provides a recording mock renderer for testing code that
+// renders.
+//
+// Run is a conformance suite for gox.Renderer implementations. Since every
+// renderer produces a different kind of output, the suite does not expect
+// any particular output: it renders pairs of trees that must look the same,
+// such as a fragment and its flattened children, or that must not, such as
+// text that looks like markup and the markup itself, and compares what the
+// renderer wrote.
+//
+//	func TestConformance(t *testing.T) {
+//		rendertest.Run(t, func(w io.Writer) gox.Renderer {
+//			return myrenderer.New(w)
+//		})
+//	}
+//
+// Renderers that do not write text can be tested by writing a
+// serialization of their result, such as the tree they built, to w.
+package rendertest
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"testing"
+
+	"github.com/germtb/gox"
+)
+
+// NewRenderer returns a renderer that writes its output to w. Run calls it
+// for every tree it renders.
+type NewRenderer func(w io.Writer) gox.Renderer
+
+// contextKey is the key of the context value the suite passes to context
+// components.
+type contextKey struct{}
+
+// Case is a check of the suite: Tree must render exactly like Want, or,
+// with Differ, must not.
+type Case struct {
+	Name   string
+	Tree   gox.VNode
+	Want   gox.VNode
+	Differ bool
+}
+
+// Cases returns the checks run by Run.
+func Cases() []Case {
+	item := func(label string) gox.VNode {
+		return gox.Element("li", nil, gox.Text(label))
+	}
+	keyed := func(key, label string) gox.VNode {
+		return gox.Element("li", gox.Props{"key": key}, gox.Text(label))
+	}
+	greeting := gox.Component(func(props gox.Props) gox.VNode {
+		return gox.Element("h1", nil, gox.Textf("Hello, %s!", props["name"]))
+	})
+	wrapper := gox.Component(func(props gox.Props) gox.VNode {
+		return gox.Element(greeting, props)
+	})
+	fromContext := gox.ContextComponent(func(ctx context.Context, props gox.Props) gox.VNode {
+		value, _ := ctx.Value(contextKey{}).(string)
+		return gox.Element("p", nil, gox.Text(value))
+	})
+
+	return []Case{
+		// Sanity checks: without them, a renderer writing nothing would pass
+		{Name: "different text differs", Tree: gox.Text("a"), Want: gox.Text("b"), Differ: true},
+		{Name: "different tags differ", Tree: gox.Element("ul", nil, item("a")), Want: gox.Element("ol", nil, item("a")), Differ: true},
+		{Name: "child order matters", Tree: gox.Element("ul", nil, item("a"), item("b")), Want: gox.Element("ul", nil, item("b"), item("a")), Differ: true},
+
+		// Text is content, never markup
+		{Name: "text is not markup", Tree: gox.Text("<b>bold</b>"), Want: gox.Element("b", nil, gox.Text("bold")), Differ: true},
+		{Name: "text with entities is not markup", Tree: gox.Text("a &amp; b"), Want: gox.Text("a & b"), Differ: true},
+		{Name: "text in elements is not markup", Tree: gox.Element("p", nil, gox.Text("<i>x</i>")), Want: gox.Element("p", nil, gox.Element("i", nil, gox.Text("x"))), Differ: true},
+		{Name: "Textf formats like Text", Tree: gox.Textf("%d items for %s", 3, "<you>"), Want: gox.Text("3 items for <you>")},
+
+		// Fragments are flattened into their parent
+		{Name: "fragment children", Tree: gox.Element("ul", nil, gox.Fragment(item("a"), item("b")), item("c")), Want: gox.Element("ul", nil, item("a"), item("b"), item("c"))},
+		{Name: "nested fragments", Tree: gox.Element("ul", nil, gox.Fragment(item("a"), gox.Fragment(item("b"), gox.Fragment(item("c"))))), Want: gox.Element("ul", nil, item("a"), item("b"), item("c"))},
+		{Name: "fragment root", Tree: gox.Fragment(gox.Element("h1", nil), gox.Element("p", nil)), Want: gox.Fragment(gox.Fragment(gox.Element("h1", nil)), gox.Element("p", nil))},
+		{Name: "Spread", Tree: gox.Element("ul", nil, gox.Spread([]gox.VNode{item("a"), item("b")})), Want: gox.Element("ul", nil, item("a"), item("b"))},
+		{Name: "Cached without a cache", Tree: gox.Element("div", nil, gox.Cached("nav", 0, item("a"))), Want: gox.Element("div", nil, item("a"))},
+
+		// Keys identify children; they are not rendered
+		{Name: "key is not rendered", Tree: gox.Element("ul", nil, keyed("1", "a"), keyed("2", "b")), Want: gox.Element("ul", nil, item("a"), item("b"))},
+		{Name: "key does not reorder", Tree: gox.Element("ul", nil, keyed("2", "a"), keyed("1", "b")), Want: gox.Element("ul", nil, item("a"), item("b"))},
+		{Name: "keyed fragment", Tree: gox.Element("dl", nil, gox.KeyedFragment("1", item("a"), item("b"))), Want: gox.Element("dl", nil, item("a"), item("b"))},
+		{Name: "keyed component", Tree: gox.Element(greeting, gox.Props{"key": "g", "name": "gox"}), Want: gox.Element(greeting, gox.Props{"name": "gox"})},
+
+		// Empty nodes render nothing
+		{Name: "empty root", Tree: gox.Empty(), Want: gox.Fragment()},
+		{Name: "empty children", Tree: gox.Element("div", nil, gox.Empty(), gox.Text("x"), gox.Empty()), Want: gox.Element("div", nil, gox.Text("x"))},
+		{Name: "When false", Tree: gox.Element("div", nil, gox.When(false, item("a")), item("b")), Want: gox.Element("div", nil, item("b"))},
+		{Name: "empty fragment child", Tree: gox.Element("div", nil, gox.Fragment(), item("a")), Want: gox.Element("div", nil, item("a"))},
+		{Name: "empty element is not empty", Tree: gox.Element("div", nil, gox.Element("br", nil)), Want: gox.Element("div", nil), Differ: true},
+
+		// Components render their expansion
+		{Name: "component", Tree: gox.Element(greeting, gox.Props{"name": "gox"}), Want: gox.Element("h1", nil, gox.Text("Hello, gox!"))},
+		{Name: "nested component", Tree: gox.Element("main", nil, gox.Element(wrapper, gox.Props{"name": "gox"})), Want: gox.Element("main", nil, gox.Element("h1", nil, gox.Text("Hello, gox!")))},
+		{Name: "context component", Tree: gox.Element(fromContext, nil), Want: gox.Element("p", nil, gox.Text("from context"))},
+
+		// Unsafe nodes are dropped for renderers that do not opt in
+		{Name: "unsafe", Tree: gox.Element("div", nil, gox.Unsafe("<b>raw</b>"), item("a")), Want: gox.Element("div", nil, item("a"))},
+	}
+}
+
+// Run runs the conformance suite against the renderers returned by
+// newRenderer, each case as a subtest. Trees are rendered with
+// gox.RenderContext, with a context that context components read a value
+// from.
+func Run(t *testing.T, newRenderer NewRenderer) {
+	t.Helper()
+	ctx := context.WithValue(context.Background(), contextKey{}, "from context")
+	unsafe := false
+	if u, ok := newRenderer(io.Discard).(gox.UnsafeRenderer); ok {
+		unsafe = u.AllowsUnsafe()
+	}
+
+	for _, c := range Cases() {
+		t.Run(c.Name, func(t *testing.T) {
+			if c.Name == "unsafe" && unsafe {
+				t.Skip("renderer allows unsafe nodes")
+			}
+			if c.Name == "context component" {
+				if _, ok := newRenderer(io.Discard).(gox.ContextRenderer); !ok {
+					t.Skip("renderer does not implement gox.ContextRenderer")
+				}
+			}
+			got, err := render(ctx, newRenderer, c.Tree)
+			if err != nil {
+				t.Fatalf("rendering tree: %v", err)
+			}
+			want, err := render(ctx, newRenderer, c.Want)
+			if err != nil {
+				t.Fatalf("rendering expected tree: %v", err)
+			}
+			switch {
+			case c.Differ && got == want:
+				t.Errorf("trees that differ rendered the same:\n%s", got)
+			case !c.Differ && got != want:
+				t.Errorf("got:\n%s\nwant:\n%s", got, want)
+			}
+		})
+	}
+
+	t.Run("canceled context", func(t *testing.T) {
+		r, ok := newRenderer(io.Discard).(gox.ContextRenderer)
+		if !ok {
+			t.Skip("renderer does not implement gox.ContextRenderer")
+		}
+		ctx, cancel := context.WithCancel(ctx)
+		cancel()
+		if err := r.RenderContext(ctx, gox.Element("div", nil, gox.Text("x"))); !errors.Is(err, context.Canceled) {
+			t.Errorf("RenderContext with a canceled context = %v, want context.Canceled", err)
+		}
+	})
+}
+
+// render renders tree with a new renderer and returns its output.
+func render(ctx context.Context, newRenderer NewRenderer, tree gox.VNode) (string, error) {
+	var buf bytes.Buffer
+	err := gox.RenderContext(ctx, newRenderer(&buf), tree)
+	return buf.String(), err
+}
@@ -0,0 +1,46 @@
+package rendertest
+
+import (
+	"context"
+	"errors"
+	"io"
+	"strings"
+	"testing"
+
+	"github.com/germtb/gox"
+)
+
+func TestRecorderConforms(t *testing.T) {
+	Run(t, func(w io.Writer) gox.Renderer { return &Recorder{W: w} })
+}
+
+func TestRecorder(t *testing.T) {
+	rec := &Recorder{Err: errors.New("boom")}
+	tree := gox.Element("div", gox.Props{"class": "card", "key": "k", "onClick": func() {}},
+		gox.Text("hi"), gox.Unsafe("<b>"))
+	if err := gox.Render(rec, tree); err == nil || err.Error() != "boom" {
+		t.Errorf("Render = %v, want boom", err)
+	}
+	if got, want := Dump(rec.Last()), `<div class="card" onClick=func>"hi"</div>`; got != want {
+		t.Errorf("Dump = %s, want %s", got, want)
+	}
+
+	rec = &Recorder{AllowUnsafe: true}
+	ctx := context.WithValue(context.Background(), contextKey{}, "v")
+	if err := gox.RenderContext(ctx, rec, tree); err != nil {
+		t.Fatal(err)
+	}
+	if got := Dump(rec.Last()); !strings.Contains(got, `unsafe("<b>")`) {
+		t.Errorf("Dump = %s, want the unsafe node", got)
+	}
+	if rec.LastContext().Value(contextKey{}) != "v" {
+		t.Error("LastContext is not the render context")
+	}
+	if len(rec.Trees()) != 1 {
+		t.Errorf("recorded %d trees, want 1", len(rec.Trees()))
+	}
+	rec.Reset()
+	if !rec.Last().IsEmpty() || rec.LastContext() != nil {
+		t.Error("Reset kept the recorded trees")
+	}
+}
@@ -0,0 +1,272 @@
+package rewrite
+
+import (
+	goast "go/ast"
+	goparser "go/parser"
+	"go/scanner"
+	"go/token"
+	"path"
+	"strconv"
+)
+
+// Call is a call of a function of an imported package, such as
+// gox.Text("hi"), found in the Go code of a file. Like Element, its methods
+// record edits and its fields keep describing the original source.
+type Call struct {
+	Pkg  string   // Name the package is referred to by
+	Name string   // Function name
+	Args []string // Source text of each argument
+
+	file  *File
+	calls []*Call // Calls in the same run of Go code
+	start int     // Offset of Pkg
+	name  int     // Offset of Name
+	open  int     // Offset of the opening parenthesis
+	close int     // Offset of the closing parenthesis
+	args  [][2]int
+}
+
+// EachCall calls fn for every call of the form pkg.Name(args) in the Go code
+// of the file, in source order: code around elements, and expressions in
+// attributes and children. Calls inside the arguments of another call are
+// visited after it. Go code is matched by its tokens, without type
+// information, so a local variable named like a package matches too.
+func (f *File) EachCall(fn func(*Call)) {
+	f.walk(f.nodes, 0, visitor{code: func(text string, offset int) {
+		for _, c := range f.findCalls(text, offset) {
+			fn(c)
+		}
+	}})
+}
+
+// ImportName returns the name the file refers to the package importPath by,
+// or false if the file does not import it by name.
+func (f *File) ImportName(importPath string) (string, bool) {
+	file, err := goparser.ParseFile(token.NewFileSet(), "", f.src, goparser.ImportsOnly)
+	if file == nil {
+		return "", false
+	}
+	_ = err // The header may parse even if the JSX after it does not
+	for _, imp := range file.Imports {
+		if p, err := strconv.Unquote(imp.Path.Value); err != nil || p != importPath {
+			continue
+		}
+		if imp.Name == nil {
+			return path.Base(importPath), true
+		}
+		if imp.Name.Name == "_" || imp.Name.Name == "." {
+			return "", false
+		}
+		return imp.Name.Name, true
+	}
+	return "", false
+}
+
+// Refs returns the number of references pkg.X to members of the package
+// named pkg in the Go code of the file.
+func (f *File) Refs(pkg string) int {
+	n := 0
+	f.walk(f.nodes, 0, visitor{code: func(text string, offset int) {
+		toks := scanTokens(text, offset)
+		for i := 0; i+1 < len(toks); i++ {
+			if toks[i].tok == token.IDENT && toks[i].lit == pkg && toks[i+1].tok == token.PERIOD &&
+				(i == 0 || toks[i-1].tok != token.PERIOD) {
+				n++
+			}
+		}
+	}})
+	return n
+}
+
+// RemoveImport removes the import of importPath, with its import
+// declaration if it was the only spec in it. It reports whether the file
+// imports the package.
+func (f *File) RemoveImport(importPath string) bool {
+	fset := token.NewFileSet()
+	file, _ := goparser.ParseFile(fset, "", f.src, goparser.ImportsOnly)
+	if file == nil {
+		return false
+	}
+	offset := func(pos token.Pos) int { return fset.Position(pos).Offset }
+	for _, decl := range file.Decls {
+		gen, ok := decl.(*goast.GenDecl)
+		if !ok || gen.Tok != token.IMPORT {
+			continue
+		}
+		for _, spec := range gen.Specs {
+			imp := spec.(*goast.ImportSpec)
+			if p, err := strconv.Unquote(imp.Path.Value); err != nil || p != importPath {
+				continue
+			}
+			if len(gen.Specs) == 1 {
+				f.removeLines(offset(gen.Pos()), offset(gen.End()))
+			} else {
+				f.removeLines(offset(imp.Pos()), offset(imp.End()))
+			}
+			return true
+		}
+	}
+	return false
+}
+
+// removeLines removes the source in [start, end), with the whole line when
+// nothing else is on it, and a blank line that would be left doubled.
+func (f *File) removeLines(start, end int) {
+	lineStart := start
+	for lineStart > 0 && (f.src[lineStart-1] == ' ' || f.src[lineStart-1] == '\t') {
+		lineStart--
+	}
+	lineEnd := end
+	for lineEnd < len(f.src) && (f.src[lineEnd] == ' ' || f.src[lineEnd] == '\t' || f.src[lineEnd] == '\r') {
+		lineEnd++
+	}
+	if (lineStart == 0 || f.src[lineStart-1] == '\n') && (lineEnd == len(f.src) || f.src[lineEnd] == '\n') {
+		start, end = lineStart, min(lineEnd+1, len(f.src))
+		// Between blank lines, one of them goes too
+		if start >= 2 && f.src[start-2] == '\n' && end < len(f.src) && f.src[end] == '\n' {
+			end++
+		}
+	}
+	f.edits = append(f.edits, Edit{Start: start, End: end})
+}
+
+// Text returns the call's original source text.
+func (c *Call) Text() string {
+	return string(c.file.src[c.start : c.close+1])
+}
+
+// Rename changes the name of the called function.
+func (c *Call) Rename(name string) {
+	c.file.edits = append(c.file.edits, Edit{Start: c.name, End: c.name + len(c.Name), Text: name})
+}
+
+// ArgCall returns the call that makes up argument i entirely, as
+// fmt.Sprintf(...) in gox.Text(fmt.Sprintf(...)).
+func (c *Call) ArgCall(i int) (*Call, bool) {
+	if i >= len(c.args) {
+		return nil, false
+	}
+	for _, arg := range c.calls {
+		if arg.start == c.args[i][0] && arg.close+1 == c.args[i][1] {
+			return arg, true
+		}
+	}
+	return nil, false
+}
+
+// Unwrap replaces the call with its arguments, removing pkg.Name( and the
+// closing parenthesis. It is meant for calls that are themselves the last
+// arguments of another call, such as fmt.Sprintf(format, args...) in
+// gox.Text(...), whose arguments the outer call takes instead.
+func (c *Call) Unwrap() {
+	c.file.edits = append(c.file.edits,
+		Edit{Start: c.start, End: c.open + 1},
+		Edit{Start: c.close, End: c.close + 1})
+}
+
+// scanTokens returns the tokens of a run of Go code that starts at offset,
+// without comments and the semicolons inserted at line ends.
+func scanTokens(text string, offset int) []callToken {
+	fset := token.NewFileSet()
+	tf := fset.AddFile("", -1, len(text))
+	var s scanner.Scanner
+	s.Init(tf, []byte(text), nil, 0)
+
+	var toks []callToken
+	for {
+		pos, tok, lit := s.Scan()
+		if tok == token.EOF {
+			return toks
+		}
+		if tok == token.SEMICOLON && lit == "\n" {
+			continue
+		}
+		start := tf.Offset(pos)
+		end := start + len(lit)
+		if lit == "" {
+			end = start + len(tok.String())
+		}
+		toks = append(toks, callToken{tok: tok, lit: lit, pos: offset + start, end: offset + end})
+	}
+}
+
+// callToken is a token of a run of Go code.
+type callToken struct {
+	tok token.Token
+	lit string
+	pos int // Offset within the file
+	end int
+}
+
+// findCalls returns the pkg.Name(...) calls in a run of Go code that starts
+// at offset. Calls whose parentheses do not balance within the run, such as
+// those with JSX arguments, are skipped.
+func (f *File) findCalls(text string, offset int) []*Call {
+	toks := scanTokens(text, offset)
+	var calls []*Call
+	for i := 0; i+3 < len(toks); i++ {
+		if toks[i].tok != token.IDENT || toks[i+1].tok != token.PERIOD ||
+			toks[i+2].tok != token.IDENT || toks[i+3].tok != token.LPAREN {
+			continue
+		}
+		if i > 0 && toks[i-1].tok == token.PERIOD {
+			continue // A method or field of a value, as in x.gox.Text()
+		}
+		c := &Call{
+			Pkg:   toks[i].lit,
+			Name:  toks[i+2].lit,
+			file:  f,
+			start: toks[i].pos,
+			name:  toks[i+2].pos,
+			open:  toks[i+3].pos,
+		}
+		if !c.scanArgs(toks[i+4:]) {
+			continue
+		}
+		calls = append(calls, c)
+	}
+	for _, c := range calls {
+		c.calls = calls
+	}
+	return calls
+}
+
+// scanArgs splits the tokens after the opening parenthesis into arguments,
+// up to the matching closing parenthesis. It reports whether there is one.
+func (c *Call) scanArgs(toks []callToken) bool {
+	depth := 0
+	argStart, argEnd := -1, -1
+	flush := func() {
+		if argStart >= 0 {
+			c.args = append(c.args, [2]int{argStart, argEnd})
+			c.Args = append(c.Args, string(c.file.src[argStart:argEnd]))
+		}
+		argStart = -1
+	}
+	for _, t := range toks {
+		switch t.tok {
+		case token.LPAREN, token.LBRACK, token.LBRACE:
+			depth++
+		case token.RPAREN, token.RBRACK, token.RBRACE:
+			if depth == 0 {
+				if t.tok != token.RPAREN {
+					return false
+				}
+				flush()
+				c.close = t.pos
+				return true
+			}
+			depth--
+		case token.COMMA:
+			if depth == 0 {
+				flush()
+				continue
+			}
+		}
+		if argStart < 0 {
+			argStart = t.pos
+		}
+		argEnd = t.end
+	}
+	return false
+}
@@ -0,0 +1,77 @@
+package rewrite
+
+import "github.com/germtb/gox/ast"
+
+// Matcher selects the elements a transform applies to.
+type Matcher func(*ast.JSXElement) bool
+
+// Any matches every element.
+func Any() Matcher {
+	return func(*ast.JSXElement) bool { return true }
+}
+
+// Tag matches elements with any of the given tags.
+func Tag(tags ...string) Matcher {
+	return func(e *ast.JSXElement) bool {
+		for _, tag := range tags {
+			if e.Tag == tag {
+				return true
+			}
+		}
+		return false
+	}
+}
+
+// HasAttr matches elements that set the attribute key.
+func HasAttr(key string) Matcher {
+	return func(e *ast.JSXElement) bool {
+		for _, attr := range e.Attributes {
+			if attributeKey(attr) == key {
+				return true
+			}
+		}
+		return false
+	}
+}
+
+// AttrValue matches elements whose attribute key is the string value, as in
+// key="value". Expression attributes never match.
+func AttrValue(key, value string) Matcher {
+	return func(e *ast.JSXElement) bool {
+		for _, attr := range e.Attributes {
+			if a, ok := attr.(*ast.StringAttribute); ok && a.Key == key {
+				return a.Value == value
+			}
+		}
+		return false
+	}
+}
+
+// And matches elements that match all of ms.
+func And(ms ...Matcher) Matcher {
+	return func(e *ast.JSXElement) bool {
+		for _, m := range ms {
+			if !m(e) {
+				return false
+			}
+		}
+		return true
+	}
+}
+
+// Or matches elements that match any of ms.
+func Or(ms ...Matcher) Matcher {
+	return func(e *ast.JSXElement) bool {
+		for _, m := range ms {
+			if m(e) {
+				return true
+			}
+		}
+		return false
+	}
+}
+
+// Not matches elements that m does not match.
+func Not(m Matcher) Matcher {
+	return func(e *ast.JSXElement) bool { return !m(e) }
+}
@@ -0,0 +1,300 @@
+// Package rewrite provides AST-based codemods for .gox files.
+//
+// A File is parsed once, transformed by visiting the JSX elements that match a
+// Matcher, and written back with minimal edits: only the text that a transform
+// touches changes, so formatting and comments elsewhere are preserved.
+//
+//	f, err := rewrite.Parse("app.gox", src)
+//	if err != nil {
+//		return err
+//	}
+//	f.Each(rewrite.Tag("box"), func(e *rewrite.Element) {
+//		e.Rename("stack")
+//		e.RenameAttr("gap", "spacing")
+//	})
+//	out := f.Bytes()
+package rewrite
+
+import (
+	"bytes"
+	"sort"
+	"strings"
+
+	"github.com/germtb/gox/ast"
+	"github.com/germtb/gox/formatter"
+	"github.com/germtb/gox/parser"
+)
+
+// Edit replaces the source bytes in [Start, End) with Text.
+type Edit struct {
+	Start, End int
+	Text       string
+}
+
+// File is a parsed .gox source and the edits made to it so far.
+type File struct {
+	src   []byte
+	nodes []ast.Node
+	edits []Edit
+}
+
+// Parse parses a .gox source for rewriting.
+func Parse(filename string, src []byte) (*File, error) {
+	file, err := parser.Parse(filename, src)
+	if err != nil {
+		return nil, err
+	}
+	return &File{src: src, nodes: file.Nodes}, nil
+}
+
+// Source returns the original source.
+func (f *File) Source() []byte {
+	return f.src
+}
+
+// Each calls fn for every element matching match, in source order. Elements
+// nested in Go expressions ({items.Map(...)}, attr={<icon />}) are visited
+// too. Transforms only record edits, so the elements passed to fn always
+// describe the original source.
+func (f *File) Each(match Matcher, fn func(*Element)) {
+	f.walk(f.nodes, 0, visitor{element: func(e *Element) {
+		if match == nil || match(e.JSXElement) {
+			fn(e)
+		}
+	}})
+}
+
+// Edits returns the edits recorded so far, sorted by position.
+func (f *File) Edits() []Edit {
+	edits := append([]Edit(nil), f.edits...)
+	sort.SliceStable(edits, func(i, j int) bool { return edits[i].Start < edits[j].Start })
+	return edits
+}
+
+// Changed reports whether any edits have been recorded.
+func (f *File) Changed() bool {
+	return len(f.edits) > 0
+}
+
+// Bytes returns the source with all edits applied. If the original source was
+// already formatted, the result is formatted too, since renames can change
+// line lengths.
+func (f *File) Bytes() []byte {
+	if !f.Changed() {
+		return f.src
+	}
+	out := Apply(f.src, f.edits)
+	if formatted, err := formatter.Source(f.src, nil); err == nil && bytes.Equal(formatted, f.src) {
+		if reformatted, err := formatter.Source(out, nil); err == nil {
+			out = reformatted
+		}
+	}
+	return out
+}
+
+// Apply returns src with edits applied. Edits that overlap an earlier edit
+// are dropped.
+func Apply(src []byte, edits []Edit) []byte {
+	edits = append([]Edit(nil), edits...)
+	sort.SliceStable(edits, func(i, j int) bool { return edits[i].Start < edits[j].Start })
+
+	var out bytes.Buffer
+	last := 0
+	for _, e := range edits {
+		if e.Start < last {
+			continue
+		}
+		out.Write(src[last:e.Start])
+		out.WriteString(e.Text)
+		last = e.End
+	}
+	out.Write(src[last:])
+	return out.Bytes()
+}
+
+// visitor receives what File.walk finds: JSX elements, and runs of Go code
+// with the offset they start at. Either may be nil.
+type visitor struct {
+	element func(*Element)
+	code    func(text string, offset int)
+}
+
+// walk visits every element and run of Go code in nodes. base is the offset
+// of the parsed text within the file, for JSX nested in expressions, which
+// is parsed separately.
+func (f *File) walk(nodes []ast.Node, base int, v visitor) {
+	for _, node := range nodes {
+		switch n := node.(type) {
+		case *ast.GoCode:
+			if v.code != nil {
+				v.code(n.Value, base+n.Range.Start.Offset)
+			}
+		case *ast.JSXElement:
+			f.walkElement(n, base, v)
+		case *ast.JSXFragment:
+			f.walkFragment(n, base, v)
+		}
+	}
+}
+
+func (f *File) walkElement(elem *ast.JSXElement, base int, v visitor) {
+	if v.element != nil {
+		v.element(&Element{JSXElement: elem, file: f, base: base})
+	}
+
+	f.walkAttributes(elem.Attributes, base, v)
+	f.walkChildren(elem.Children, base, v)
+}
+
+func (f *File) walkFragment(frag *ast.JSXFragment, base int, v visitor) {
+	f.walkAttributes(frag.Attributes, base, v)
+	f.walkChildren(frag.Children, base, v)
+}
+
+func (f *File) walkAttributes(attrs []ast.Attribute, base int, v visitor) {
+	for _, attr := range attrs {
+		switch a := attr.(type) {
+		case *ast.ExpressionAttribute:
+			if a.ValueRange.IsValid() {
+				f.walkExpr(a.Expression, base+a.ValueRange.Start.Offset+1, v)
+			}
+		case *ast.SpreadAttribute:
+			f.walkExpr(a.Expression, base+a.ExpressionRange.Start.Offset, v)
+		}
+	}
+}
+
+func (f *File) walkChildren(children []ast.JSXChild, base int, v visitor) {
+	for _, child := range children {
+		switch c := child.(type) {
+		case *ast.JSXElement:
+			f.walkElement(c, base, v)
+		case *ast.JSXFragment:
+			f.walkFragment(c, base, v)
+		case *ast.JSXExpression:
+			f.walkExpr(c.Expression, base+c.Range.Start.Offset+1, v)
+		}
+	}
+}
+
+// walkExpr visits the elements and Go code in a Go expression that starts
+// at offset.
+func (f *File) walkExpr(expr string, offset int, v visitor) {
+	if !strings.Contains(expr, "<") {
+		if v.code != nil {
+			v.code(expr, offset)
+		}
+		return
+	}
+	nested, err := parser.Parse("", []byte(expr))
+	if err != nil {
+		// Not JSX after all (e.g. a comparison)
+		if v.code != nil {
+			v.code(expr, offset)
+		}
+		return
+	}
+	f.walk(nested.Nodes, offset, v)
+}
+
+// Element is a JSX element visited by File.Each. Its methods record edits on
+// the file; the embedded AST node keeps describing the original source.
+type Element struct {
+	*ast.JSXElement
+	file *File
+	base int // Offset of the element's AST positions within the file
+}
+
+// Text returns the element's original source text.
+func (e *Element) Text() string {
+	return string(e.file.src[e.base+e.Range.Start.Offset : e.base+e.Range.End.Offset])
+}
+
+// Attr returns the attribute with the given key.
+func (e *Element) Attr(key string) (ast.Attribute, bool) {
+	for _, attr := range e.Attributes {
+		if attributeKey(attr) == key {
+			return attr, true
+		}
+	}
+	return nil, false
+}
+
+// Rename changes the element's tag, in both the opening and closing tag.
+func (e *Element) Rename(tag string) {
+	e.edit(e.TagRange.Start.Offset, e.TagRange.End.Offset, tag)
+	if e.CloseTagRange.IsValid() {
+		e.edit(e.CloseTagRange.Start.Offset, e.CloseTagRange.End.Offset, tag)
+	}
+}
+
+// RenameAttr renames the attribute key to newKey, keeping its value. It
+// reports whether the element has the attribute.
+func (e *Element) RenameAttr(key, newKey string) bool {
+	attr, ok := e.Attr(key)
+	if !ok {
+		return false
+	}
+	r := attributeKeyRange(attr)
+	e.edit(r.Start.Offset, r.End.Offset, newKey)
+	return true
+}
+
+// SetAttr sets the attribute key to value, given as source text: a quoted
+// string ("primary") or an expression in braces ({size}). An existing
+// attribute is replaced in place; otherwise the attribute is added after the
+// tag name.
+func (e *Element) SetAttr(key, value string) {
+	text := key + "=" + value
+	if attr, ok := e.Attr(key); ok {
+		r := attr.GetRange()
+		e.edit(r.Start.Offset, r.End.Offset, text)
+		return
+	}
+	e.edit(e.TagRange.End.Offset, e.TagRange.End.Offset, " "+text)
+}
+
+// RemoveAttr removes the attribute key along with the whitespace before it.
+// It reports whether the element had the attribute.
+func (e *Element) RemoveAttr(key string) bool {
+	attr, ok := e.Attr(key)
+	if !ok {
+		return false
+	}
+	r := attr.GetRange()
+	start := r.Start.Offset
+	for start > 0 && isSpace(e.file.src[e.base+start-1]) {
+		start--
+	}
+	e.edit(start, r.End.Offset, "")
+	return true
+}
+
+// edit records an edit at offsets relative to the element's AST positions.
+func (e *Element) edit(start, end int, text string) {
+	e.file.edits = append(e.file.edits, Edit{Start: e.base + start, End: e.base + end, Text: text})
+}
+
+func attributeKey(attr ast.Attribute) string {
+	switch a := attr.(type) {
+	case *ast.StringAttribute:
+		return a.Key
+	case *ast.ExpressionAttribute:
+		return a.Key
+	}
+	return ""
+}
+
+func attributeKeyRange(attr ast.Attribute) ast.Range {
+	switch a := attr.(type) {
+	case *ast.StringAttribute:
+		return a.KeyRange
+	case *ast.ExpressionAttribute:
+		return a.KeyRange
+	}
+	return ast.Range{}
+}
+
+func isSpace(c byte) bool {
+	return c == ' ' || c == '\t' || c == '\n' || c == '\r'
+}
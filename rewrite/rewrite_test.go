@@ -0,0 +1,218 @@
+package rewrite
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+const testSource = `package ui
+
+func Card(items []string) gox.VNode {
+	return <box gap={2} class="card" hidden>
+		<text class="title">Items</text>
+		{gox.Map(items, func(s string) gox.VNode { return <box key={s}>{s}</box> })}
+	</box>
+}
+`
+
+func rewriteString(t *testing.T, src string, fn func(f *File)) string {
+	t.Helper()
+	f, err := Parse("test.gox", []byte(src))
+	if err != nil {
+		t.Fatalf("Parse: %v", err)
+	}
+	fn(f)
+	return string(f.Bytes())
+}
+
+func TestEachVisitsNestedElements(t *testing.T) {
+	f, err := Parse("test.gox", []byte(testSource))
+	if err != nil {
+		t.Fatalf("Parse: %v", err)
+	}
+
+	var texts []string
+	f.Each(Tag("box"), func(e *Element) {
+		texts = append(texts, e.Text())
+	})
+	if len(texts) != 2 {
+		t.Fatalf("expected 2 box elements, got %d: %q", len(texts), texts)
+	}
+	if texts[1] != "<box key={s}>{s}</box>" {
+		t.Errorf("nested element text = %q", texts[1])
+	}
+}
+
+func TestElementTransforms(t *testing.T) {
+	tests := []struct {
+		name  string
+		match Matcher
+		fn    func(*Element)
+		want  string
+	}{
+		{
+			name:  "rename",
+			match: Tag("box"),
+			fn:    func(e *Element) { e.Rename("stack") },
+			want: `package ui
+
+func Card(items []string) gox.VNode {
+	return <stack gap={2} class="card" hidden>
+		<text class="title">Items</text>
+		{gox.Map(items, func(s string) gox.VNode { return <stack key={s}>{s}</stack> })}
+	</stack>
+}
+`,
+		},
+		{
+			name:  "rename attribute",
+			match: HasAttr("class"),
+			fn:    func(e *Element) { e.RenameAttr("class", "className") },
+			want: `package ui
+
+func Card(items []string) gox.VNode {
+	return <box gap={2} className="card" hidden>
+		<text className="title">Items</text>
+		{gox.Map(items, func(s string) gox.VNode { return <box key={s}>{s}</box> })}
+	</box>
+}
+`,
+		},
+		{
+			name:  "set and remove attributes",
+			match: And(Tag("box"), HasAttr("gap")),
+			fn: func(e *Element) {
+				e.SetAttr("gap", "{4}")
+				e.SetAttr("role", `"list"`)
+				e.RemoveAttr("hidden")
+			},
+			want: `package ui
+
+func Card(items []string) gox.VNode {
+	return <box role="list" gap={4} class="card">
+		<text class="title">Items</text>
+		{gox.Map(items, func(s string) gox.VNode { return <box key={s}>{s}</box> })}
+	</box>
+}
+`,
+		},
+		{
+			name:  "attribute value matcher",
+			match: AttrValue("class", "title"),
+			fn:    func(e *Element) { e.Rename("heading") },
+			want: `package ui
+
+func Card(items []string) gox.VNode {
+	return <box gap={2} class="card" hidden>
+		<heading class="title">Items</heading>
+		{gox.Map(items, func(s string) gox.VNode { return <box key={s}>{s}</box> })}
+	</box>
+}
+`,
+		},
+		{
+			name:  "no match leaves source untouched",
+			match: Or(Tag("missing"), Not(Any())),
+			fn:    func(e *Element) { e.Rename("x") },
+			want:  testSource,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := rewriteString(t, testSource, func(f *File) { f.Each(tt.match, tt.fn) })
+			if got != tt.want {
+				t.Errorf("got:\n%s\nwant:\n%s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestApply(t *testing.T) {
+	src := []byte("abcdef")
+	edits := []Edit{
+		{Start: 4, End: 5, Text: "E"},
+		{Start: 0, End: 1, Text: "A"},
+		{Start: 0, End: 2, Text: "overlap"},
+		{Start: 3, End: 3, Text: "+"},
+	}
+	if got := string(Apply(src, edits)); got != "Abc+dEf" {
+		t.Errorf("Apply = %q, want %q", got, "Abc+dEf")
+	}
+}
+
+const callSource = `package ui
+
+import (
+	"fmt"
+	g "github.com/germtb/gox"
+)
+
+func Count(n int, items []string) g.VNode {
+	label := g.Text(fmt.Sprintf("%d items", n))
+	return <box title={g.Text(fmt.Sprint(n))}>
+		{label}
+		{g.Map(items, func(s string) g.VNode { return <text>{g.Text(s)}</text> })}
+	</box>
+}
+`
+
+func TestEachCall(t *testing.T) {
+	f, err := Parse("test.gox", []byte(callSource))
+	if err != nil {
+		t.Fatalf("Parse: %v", err)
+	}
+	var calls []string
+	f.EachCall(func(c *Call) {
+		calls = append(calls, c.Pkg+"."+c.Name+"("+strings.Join(c.Args, ", ")+")")
+	})
+	want := []string{
+		`g.Text(fmt.Sprintf("%d items", n))`,
+		`fmt.Sprintf("%d items", n)`,
+		`g.Text(fmt.Sprint(n))`,
+		`fmt.Sprint(n)`,
+		`g.Text(s)`,
+	}
+	if !reflect.DeepEqual(calls, want) {
+		t.Errorf("calls = %q, want %q", calls, want)
+	}
+	if name, ok := f.ImportName("github.com/germtb/gox"); !ok || name != "g" {
+		t.Errorf("ImportName(gox) = %q, %v; want g", name, ok)
+	}
+	if n := f.Refs("fmt"); n != 2 {
+		t.Errorf("Refs(fmt) = %d, want 2", n)
+	}
+}
+
+func TestCallTransforms(t *testing.T) {
+	got := rewriteString(t, callSource, func(f *File) {
+		f.EachCall(func(c *Call) {
+			if c.Pkg != "g" || c.Name != "Text" {
+				return
+			}
+			if inner, ok := c.ArgCall(0); ok && inner.Pkg == "fmt" {
+				c.Rename("Textf")
+				inner.Unwrap()
+			}
+		})
+		f.RemoveImport("fmt")
+	})
+	want := `package ui
+
+import (
+	g "github.com/germtb/gox"
+)
+
+func Count(n int, items []string) g.VNode {
+	label := g.Textf("%d items", n)
+	return <box title={g.Textf(n)}>
+		{label}
+		{g.Map(items, func(s string) g.VNode { return <text>{g.Text(s)}</text> })}
+	</box>
+}
+`
+	if got != want {
+		t.Errorf("got:\n%s\nwant:\n%s", got, want)
+	}
+}
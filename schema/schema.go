@@ -0,0 +1,116 @@
+// Package schema describes the intrinsic elements a renderer supports, and
+// the attributes each accepts, so that gox reports unknown tags and
+// attributes in .gox files instead of passing them to the renderer.
+//
+// A schema is written as JSON, usually in a .goxschema file:
+//
+//	{
+//		"strict": true,
+//		"attributes": ["style"],
+//		"elements": {
+//			"box": ["direction", "gap"],
+//			"text": [],
+//			"input": ["value", "placeholder", "on*"]
+//		}
+//	}
+//
+// or built in Go as a Schema value.
+package schema
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/germtb/gox/ast"
+	"github.com/germtb/gox/diag"
+)
+
+// Schema declares the intrinsic elements and their attributes. An attribute
+// ending in * allows every attribute starting with the text before it, as
+// data-* does; a lone * allows any attribute. The key and children
+// attributes, which gox handles itself, are always allowed.
+type Schema struct {
+	// Elements maps each intrinsic tag to the attributes it accepts besides
+	// Attributes.
+	Elements map[string][]string `json:"elements"`
+
+	// Attributes are accepted by every element.
+	Attributes []string `json:"attributes,omitempty"`
+
+	// Strict makes unknown tags and attributes fail generation, rather than
+	// only being reported by gox vet.
+	Strict bool `json:"strict,omitempty"`
+}
+
+// Parse parses a schema from JSON.
+func Parse(data []byte) (*Schema, error) {
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.DisallowUnknownFields()
+	s := &Schema{}
+	if err := dec.Decode(s); err != nil {
+		return nil, err
+	}
+	if s.Elements == nil {
+		return nil, fmt.Errorf("no elements")
+	}
+	return s, nil
+}
+
+// Check returns a diagnostic for each intrinsic element of file, including
+// those nested in Go expressions, whose tag or attributes the schema does
+// not declare. Components are not checked.
+func (s *Schema) Check(file *ast.GoxFile) []error {
+	var errs []error
+	report := func(code diag.Code, r ast.Range, format string, args ...any) {
+		d := diag.New(code, file.SourcePath, r.Start.Line, r.Start.Column, format, args...)
+		d.EndLine, d.EndColumn = r.End.Line, r.End.Column
+		errs = append(errs, d)
+	}
+	ast.Inspect(file, func(n ast.Node) bool {
+		elem, ok := n.(*ast.JSXElement)
+		if !ok || ast.IsComponentTag(elem.Tag) {
+			return true
+		}
+		if _, known := s.Elements[elem.Tag]; !known {
+			report(diag.UnknownElement, elem.TagRange, "unknown element <%s>", elem.Tag)
+			return true
+		}
+		for _, attr := range elem.Attributes {
+			var key string
+			var r ast.Range
+			switch a := attr.(type) {
+			case *ast.StringAttribute:
+				key, r = a.Key, a.KeyRange
+			case *ast.ExpressionAttribute:
+				key, r = a.Key, a.KeyRange
+			case *ast.SpreadAttribute:
+				continue // Its props are only known at run time
+			}
+			if !s.Allows(elem.Tag, key) {
+				report(diag.UnknownAttribute, r, "unknown attribute %s on <%s>", key, elem.Tag)
+			}
+		}
+		return true
+	})
+	return errs
+}
+
+// Allows reports whether the schema accepts attribute attr on tag, which
+// must be a declared element.
+func (s *Schema) Allows(tag, attr string) bool {
+	if attr == "key" || attr == "children" {
+		return true
+	}
+	return matchAttribute(s.Elements[tag], attr) || matchAttribute(s.Attributes, attr)
+}
+
+func matchAttribute(patterns []string, attr string) bool {
+	for _, pattern := range patterns {
+		if prefix, ok := strings.CutSuffix(pattern, "*"); ok && strings.HasPrefix(attr, prefix) || pattern == attr {
+			return true
+		}
+	}
+	return false
+}
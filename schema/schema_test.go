@@ -0,0 +1,97 @@
+package schema
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/germtb/gox/diag"
+	"github.com/germtb/gox/parser"
+)
+
+const tuiSchema = `{
+	"attributes": ["style"],
+	"elements": {
+		"box": ["direction", "gap"],
+		"text": [],
+		"input": ["value", "on*"]
+	}
+}`
+
+func TestParse(t *testing.T) {
+	s, err := Parse([]byte(tuiSchema))
+	if err != nil {
+		t.Fatalf("Parse: %v", err)
+	}
+	if len(s.Elements) != 3 || s.Strict {
+		t.Errorf("got %+v", s)
+	}
+
+	for _, bad := range []string{`{}`, `{"elements": {}, "strickt": true}`, `{"elements": {"box": "gap"}}`} {
+		if _, err := Parse([]byte(bad)); err == nil {
+			t.Errorf("Parse(%s) should fail", bad)
+		}
+	}
+}
+
+func TestAllows(t *testing.T) {
+	s, err := Parse([]byte(tuiSchema))
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		tag, attr string
+		want      bool
+	}{
+		{"box", "gap", true},
+		{"box", "style", true},
+		{"box", "key", true},
+		{"box", "children", true},
+		{"box", "value", false},
+		{"input", "onChange", true},
+		{"input", "oninput", true},
+		{"text", "gap", false},
+	}
+	for _, tt := range tests {
+		if got := s.Allows(tt.tag, tt.attr); got != tt.want {
+			t.Errorf("Allows(%q, %q) = %v, want %v", tt.tag, tt.attr, got, tt.want)
+		}
+	}
+}
+
+func TestCheck(t *testing.T) {
+	s, err := Parse([]byte(tuiSchema))
+	if err != nil {
+		t.Fatal(err)
+	}
+	src := `package ui
+
+func App(items []string) gox.VNode {
+	return <box gap={1} align="center">
+		<span>Hello</span>
+		<Card title={<text color="red" />} />
+		{gox.Map(items, func(s string) gox.VNode { return <input value={s} onChange={save} size={3} /> })}
+	</box>
+}`
+	file, err := parser.Parse("app.gox", []byte(src))
+	if err != nil {
+		t.Fatalf("Parse error: %v", err)
+	}
+
+	var got []string
+	for _, err := range s.Check(file) {
+		d, ok := diag.As(err)
+		if !ok {
+			t.Fatalf("Expected a diagnostic, got %v", err)
+		}
+		got = append(got, d.Error())
+	}
+	want := []string{
+		"app.gox:4:22: unknown attribute align on <box> [GOX0013]",
+		"app.gox:5:4: unknown element <span> [GOX0012]",
+		"app.gox:6:22: unknown attribute color on <text> [GOX0013]",
+		"app.gox:7:86: unknown attribute size on <input> [GOX0013]",
+	}
+	if strings.Join(got, "\n") != strings.Join(want, "\n") {
+		t.Errorf("got:\n%s\nwant:\n%s", strings.Join(got, "\n"), strings.Join(want, "\n"))
+	}
+}
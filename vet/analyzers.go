@@ -0,0 +1,405 @@
+package vet
+
+import (
+	goast "go/ast"
+	"go/token"
+	"strconv"
+	"strings"
+	"unicode"
+
+	"github.com/germtb/gox/ast"
+	"github.com/germtb/gox/diag"
+	"github.com/germtb/gox/lexer"
+	"github.com/germtb/gox/rewrite"
+)
+
+// MissingKey reports elements returned from gox.Map and gox.MapIndex
+// callbacks without a key, which list items need to be told apart.
+var MissingKey = &Analyzer{
+	Name: "missingkey",
+	Doc:  "report list items built with gox.Map or gox.MapIndex that have no key prop",
+	Run:  runMissingKey,
+}
+
+// UnusedProps reports fields of a component's Props struct that the
+// component never reads.
+var UnusedProps = &Analyzer{
+	Name: "unusedprops",
+	Doc:  "report component Props fields that the component never reads",
+	Run:  runUnusedProps,
+}
+
+// ShadowedIntrinsic reports lowercase functions that look like components
+// but can never be used as one: a lowercase tag always creates an intrinsic
+// element.
+var ShadowedIntrinsic = &Analyzer{
+	Name: "shadow",
+	Doc:  "report lowercase component functions shadowed by intrinsic elements",
+	Run:  runShadowedIntrinsic,
+}
+
+// SpreadComponent reports spread attributes on typed components, which
+// cannot be compiled to a props struct literal.
+var SpreadComponent = &Analyzer{
+	Name: "spread",
+	Doc:  "report {...props} spread attributes on typed components",
+	Run:  runSpreadComponent,
+}
+
+// UnsafeHTML reports uses of gox.Unsafe, whose HTML is rendered verbatim
+// and must be reviewed for injection.
+var UnsafeHTML = &Analyzer{
+	Name: "unsafe",
+	Doc:  "report gox.Unsafe raw HTML nodes",
+	Run:  runUnsafeHTML,
+}
+
+// UnknownIntrinsic reports intrinsic elements and attributes that the
+// .goxschema of the package does not declare.
+var UnknownIntrinsic = &Analyzer{
+	Name: "schema",
+	Doc:  "report intrinsic elements and attributes not declared by the .goxschema",
+	Run:  runUnknownIntrinsic,
+}
+
+func runMissingKey(pass *Pass) {
+	props := propsStructs(pass)
+	for _, f := range pass.Files {
+		if f.Go == nil {
+			continue
+		}
+		rt := pass.RuntimeName(f.Go)
+		if rt == "" {
+			continue
+		}
+		goast.Inspect(f.Go, func(n goast.Node) bool {
+			call, ok := n.(*goast.CallExpr)
+			if !ok || len(call.Args) != 2 {
+				return true
+			}
+			helper := "Map"
+			if !isRuntimeCall(call, rt, helper) {
+				helper = "MapIndex"
+				if !isRuntimeCall(call, rt, helper) {
+					return true
+				}
+			}
+			fn, ok := call.Args[1].(*goast.FuncLit)
+			if !ok {
+				return true
+			}
+			for _, result := range returnedValues(fn.Body) {
+				if tag, keyed := elementKey(result, rt, props); tag != "" && !keyed {
+					pass.Reportf(result.Pos(), "<%s> returned from %s.%s callback has no key", tag, rt, helper)
+				}
+			}
+			return true
+		})
+	}
+}
+
+// returnedValues returns the values returned by single-result return
+// statements in body, not counting nested function literals.
+func returnedValues(body *goast.BlockStmt) []goast.Expr {
+	var results []goast.Expr
+	goast.Inspect(body, func(n goast.Node) bool {
+		switch n := n.(type) {
+		case *goast.FuncLit:
+			return false
+		case *goast.ReturnStmt:
+			if len(n.Results) == 1 {
+				results = append(results, n.Results[0])
+			}
+		}
+		return true
+	})
+	return results
+}
+
+// elementKey inspects an element constructor call. It returns the tag of the
+// element and whether it sets a key, or "" if expr is not an element, a
+// fragment or a typed component whose props have a Key field.
+func elementKey(expr goast.Expr, rt string, props map[string]*goast.StructType) (string, bool) {
+	call, ok := expr.(*goast.CallExpr)
+	if !ok || len(call.Args) == 0 {
+		return "", false
+	}
+
+	// Intrinsic element: gox.Element("li", gox.Props{"key": ...}, ...)
+	if isRuntimeCall(call, rt, "Element") && len(call.Args) >= 2 {
+		lit, ok := call.Args[0].(*goast.BasicLit)
+		if !ok || lit.Kind != token.STRING {
+			return "", false
+		}
+		tag, _ := strconv.Unquote(lit.Value)
+		switch p := call.Args[1].(type) {
+		case *goast.Ident:
+			return tag, p.Name != "nil"
+		case *goast.CompositeLit:
+			for _, elt := range p.Elts {
+				if kv, ok := elt.(*goast.KeyValueExpr); ok {
+					if k, ok := kv.Key.(*goast.BasicLit); ok && k.Value == `"key"` {
+						return tag, true
+					}
+				}
+			}
+			return tag, false
+		default:
+			return tag, true // Props built elsewhere; assume they may carry a key
+		}
+	}
+
+	// Fragment: gox.Fragment(...) from <>, gox.KeyedFragment(key, ...) from
+	// <Fragment key={...}>
+	if isRuntimeCall(call, rt, "Fragment") {
+		return "Fragment", false
+	}
+	if isRuntimeCall(call, rt, "KeyedFragment") {
+		return "Fragment", true
+	}
+
+	// Typed component: Item(ItemProps{Key: ...}, ...)
+	fun, ok := call.Fun.(*goast.Ident)
+	if !ok {
+		return "", false
+	}
+	lit, ok := call.Args[0].(*goast.CompositeLit)
+	if !ok || typeName(lit.Type) != fun.Name+"Props" || !hasField(props[fun.Name+"Props"], "Key") {
+		return "", false
+	}
+	for _, elt := range lit.Elts {
+		if kv, ok := elt.(*goast.KeyValueExpr); ok {
+			if k, ok := kv.Key.(*goast.Ident); ok && k.Name == "Key" {
+				return fun.Name, true
+			}
+		}
+	}
+	return fun.Name, false
+}
+
+func runUnusedProps(pass *Pass) {
+	props := propsStructs(pass)
+	for _, f := range pass.Files {
+		if f.Go == nil {
+			continue
+		}
+		for _, decl := range f.Go.Decls {
+			fn, ok := decl.(*goast.FuncDecl)
+			if !ok || fn.Recv != nil || fn.Body == nil || len(fn.Type.Params.List) == 0 {
+				continue
+			}
+			first := fn.Type.Params.List[0]
+			name := typeName(first.Type)
+			st := props[name]
+			if name != fn.Name.Name+"Props" || st == nil || len(first.Names) != 1 || first.Names[0].Name == "_" {
+				continue
+			}
+
+			used, escapes := fieldUses(fn.Body, first.Names[0].Name)
+			if escapes {
+				continue // Props passed on as a whole; any field may be read elsewhere
+			}
+			for _, field := range st.Fields.List {
+				for _, id := range field.Names {
+					// Key is read by the parent list, not the component
+					if id.Name != "Key" && !used[id.Name] {
+						pass.Reportf(id.Pos(), "%s.%s is never used by %s", name, id.Name, fn.Name.Name)
+					}
+				}
+			}
+		}
+	}
+}
+
+// fieldUses returns the fields selected from param in body, and whether
+// param is also used other than by selecting a field.
+func fieldUses(body *goast.BlockStmt, param string) (map[string]bool, bool) {
+	used := make(map[string]bool)
+	escapes := false
+	goast.Inspect(body, func(n goast.Node) bool {
+		switch n := n.(type) {
+		case *goast.SelectorExpr:
+			if x, ok := n.X.(*goast.Ident); ok && x.Name == param {
+				used[n.Sel.Name] = true
+				return false
+			}
+		case *goast.Ident:
+			if n.Name == param {
+				escapes = true
+			}
+		}
+		return true
+	})
+	return used, escapes
+}
+
+// htmlElements are common intrinsic element names, which a lowercase
+// component is likely to collide with even if the package never uses them.
+var htmlElements = map[string]bool{
+	"a": true, "article": true, "aside": true, "b": true, "body": true, "button": true,
+	"canvas": true, "code": true, "dialog": true, "div": true, "footer": true, "form": true,
+	"h1": true, "h2": true, "h3": true, "head": true, "header": true, "html": true,
+	"i": true, "img": true, "input": true, "label": true, "li": true, "link": true,
+	"main": true, "nav": true, "ol": true, "option": true, "p": true, "pre": true,
+	"section": true, "select": true, "span": true, "strong": true, "table": true,
+	"tbody": true, "td": true, "textarea": true, "th": true, "thead": true, "title": true,
+	"tr": true, "ul": true, "video": true,
+}
+
+func runShadowedIntrinsic(pass *Pass) {
+	// Tags used anywhere in the package, including inside expressions
+	usedTags := make(map[string]bool)
+	for _, f := range pass.Files {
+		if f.Gox == nil {
+			continue
+		}
+		rf, err := rewrite.Parse(f.Path, f.Src)
+		if err != nil {
+			continue
+		}
+		rf.Each(rewrite.Any(), func(e *rewrite.Element) {
+			usedTags[e.Tag] = true
+		})
+	}
+
+	for _, f := range pass.Files {
+		if f.Go == nil {
+			continue
+		}
+		rt := pass.RuntimeName(f.Go)
+		for _, decl := range f.Go.Decls {
+			fn, ok := decl.(*goast.FuncDecl)
+			if !ok || fn.Recv != nil || !returnsVNode(fn.Type, rt) {
+				continue
+			}
+			name := fn.Name.Name
+			if name == "main" || !unicode.IsLower(rune(name[0])) || !usedTags[name] && !htmlElements[name] {
+				continue
+			}
+			pass.Reportf(fn.Name.Pos(), "func %s is shadowed by the intrinsic <%s> element, which never calls it; name it %s to use it as a component",
+				name, name, strings.ToUpper(name[:1])+name[1:])
+		}
+	}
+}
+
+// returnsVNode reports whether a function type returns exactly a runtime VNode.
+func returnsVNode(ft *goast.FuncType, rt string) bool {
+	if rt == "" || ft.Results == nil || len(ft.Results.List) != 1 || len(ft.Results.List[0].Names) > 1 {
+		return false
+	}
+	sel, ok := ft.Results.List[0].Type.(*goast.SelectorExpr)
+	if !ok || sel.Sel.Name != "VNode" {
+		return false
+	}
+	x, ok := sel.X.(*goast.Ident)
+	return ok && x.Name == rt
+}
+
+func runSpreadComponent(pass *Pass) {
+	// The parser rejects spreads on components, so work on tokens; this also
+	// covers files that do not parse
+	for _, f := range pass.Files {
+		lex := lexer.New(string(f.Src))
+		component := ""
+		for tok := lex.NextToken(); tok.Type != lexer.TOKEN_EOF; tok = lex.NextToken() {
+			switch tok.Type {
+			case lexer.TOKEN_JSX_TAG:
+				component = ""
+				// <Fragment> is a fragment, not a component
+				if ast.IsComponentTag(tok.Value) && tok.Value != "Fragment" {
+					component = tok.Value
+				}
+			case lexer.TOKEN_JSX_CLOSE, lexer.TOKEN_JSX_SLASH:
+				component = ""
+			case lexer.TOKEN_JSX_EXPR:
+				if component != "" && strings.HasPrefix(tok.Value, "...") {
+					pass.ReportAt(f, tok.Line, tok.Column, "spread attribute {%s} on <%s>: set the fields of %sProps explicitly",
+						tok.Value, component, component)
+				}
+			}
+		}
+	}
+}
+
+func runUnsafeHTML(pass *Pass) {
+	for _, f := range pass.Files {
+		if f.Go == nil {
+			continue
+		}
+		rt := pass.RuntimeName(f.Go)
+		if rt == "" {
+			continue
+		}
+		goast.Inspect(f.Go, func(n goast.Node) bool {
+			if call, ok := n.(*goast.CallExpr); ok && isRuntimeCall(call, rt, "Unsafe") {
+				pass.Reportf(call.Pos(), "%s.Unsafe renders HTML verbatim; make sure it is sanitized", rt)
+			}
+			return true
+		})
+	}
+}
+
+// propsStructs returns the struct types declared in the package whose names
+// end in "Props".
+func propsStructs(pass *Pass) map[string]*goast.StructType {
+	props := make(map[string]*goast.StructType)
+	for _, f := range pass.Files {
+		if f.Go == nil {
+			continue
+		}
+		for _, decl := range f.Go.Decls {
+			gen, ok := decl.(*goast.GenDecl)
+			if !ok || gen.Tok != token.TYPE {
+				continue
+			}
+			for _, spec := range gen.Specs {
+				ts := spec.(*goast.TypeSpec)
+				if st, ok := ts.Type.(*goast.StructType); ok && strings.HasSuffix(ts.Name.Name, "Props") {
+					props[ts.Name.Name] = st
+				}
+			}
+		}
+	}
+	return props
+}
+
+// typeName returns the name of a named type expression, without type
+// arguments, or "" for other types.
+func typeName(expr goast.Expr) string {
+	switch t := expr.(type) {
+	case *goast.Ident:
+		return t.Name
+	case *goast.IndexExpr:
+		return typeName(t.X)
+	case *goast.IndexListExpr:
+		return typeName(t.X)
+	}
+	return ""
+}
+
+func hasField(st *goast.StructType, name string) bool {
+	if st == nil {
+		return false
+	}
+	for _, field := range st.Fields.List {
+		for _, id := range field.Names {
+			if id.Name == name {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func runUnknownIntrinsic(pass *Pass) {
+	for _, f := range pass.Files {
+		if f.Gox == nil || f.Schema == nil {
+			continue
+		}
+		for _, err := range f.Schema.Check(f.Gox) {
+			if d, ok := diag.As(err); ok {
+				pass.ReportAt(f, d.Line, d.Column, "%s", d.Message)
+			}
+		}
+	}
+}
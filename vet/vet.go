@@ -0,0 +1,225 @@
+// Package vet provides gox-specific static analyzers.
+//
+// Analyzers inspect the code generated for a package's .gox files with
+// go/ast, so they see exactly what the compiler sees, and report positions
+// remapped to the .gox sources through the generator's source maps. Analyzers
+// that need the original syntax also have the gox AST and source.
+package vet
+
+import (
+	"fmt"
+	goast "go/ast"
+	goparser "go/parser"
+	"go/token"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strconv"
+
+	"github.com/germtb/gox/ast"
+	"github.com/germtb/gox/generator"
+	"github.com/germtb/gox/parser"
+	"github.com/germtb/gox/schema"
+)
+
+// DefaultRuntimePackage is the import path of the gox runtime.
+const DefaultRuntimePackage = "github.com/germtb/gox"
+
+// Analyzer is a single check.
+type Analyzer struct {
+	Name string // Short identifier printed with each diagnostic
+	Doc  string // One-line description
+	Run  func(*Pass)
+}
+
+// File is a .gox file of the package being analyzed.
+type File struct {
+	Path      string
+	Src       []byte
+	Gox       *ast.GoxFile         // nil if the file does not parse
+	Go        *goast.File          // Generated code; nil if the file does not parse
+	SourceMap *generator.SourceMap // Maps Go positions back to Path
+	Runtime   string               // Import path of the gox runtime the file uses
+	Schema    *schema.Schema       // Schema of the intrinsic elements; nil if no .goxschema applies
+}
+
+// Pass is one analyzer's view of a package.
+type Pass struct {
+	Analyzer *Analyzer
+	Fset     *token.FileSet
+	Files    []*File
+	Runtime  string // Import path of the gox runtime, unless a file sets its own
+
+	byGoFile    map[string]*File
+	diagnostics *[]Diagnostic
+}
+
+// Diagnostic is a finding, positioned in a .gox source.
+type Diagnostic struct {
+	Analyzer string
+	File     string
+	Line     int // 1-indexed
+	Column   int // 1-indexed, in runes
+	Message  string
+}
+
+// String formats the diagnostic as "file:line:col: message [analyzer]".
+func (d Diagnostic) String() string {
+	return fmt.Sprintf("%s:%d:%d: %s [%s]", d.File, d.Line, d.Column, d.Message, d.Analyzer)
+}
+
+// Options configures Run.
+type Options struct {
+	// RuntimePackage is the import path of the gox runtime for files without
+	// a //gox:runtime directive or .goxruntime file.
+	// Default: "github.com/germtb/gox"
+	RuntimePackage string
+}
+
+// Analyzers are all available analyzers, in the order they run.
+var Analyzers = []*Analyzer{MissingKey, UnusedProps, ShadowedIntrinsic, SpreadComponent, UnsafeHTML, UnknownIntrinsic}
+
+// Run analyzes the .gox files of one package and returns the diagnostics,
+// sorted by position. Files that do not parse are still passed to analyzers
+// with their source, but without syntax trees.
+func Run(paths []string, analyzers []*Analyzer, opts *Options) ([]Diagnostic, error) {
+	if opts == nil {
+		opts = &Options{}
+	}
+	runtimePkg := opts.RuntimePackage
+	if runtimePkg == "" {
+		runtimePkg = DefaultRuntimePackage
+	}
+
+	dirRuntimes := make(map[string]string)
+	dirSchemas := make(map[string]*schema.Schema)
+	fset := token.NewFileSet()
+	byGoFile := make(map[string]*File)
+	var files []*File
+	for _, name := range paths {
+		src, err := os.ReadFile(name)
+		if err != nil {
+			return nil, fmt.Errorf("reading %s: %w", name, err)
+		}
+		dir := filepath.Dir(name)
+		dirRuntime, ok := dirRuntimes[dir]
+		if !ok {
+			if dirRuntime, err = generator.LookupRuntime(dir); err != nil {
+				return nil, err
+			}
+			if dirRuntime == "" {
+				dirRuntime = runtimePkg
+			}
+			dirRuntimes[dir] = dirRuntime
+		}
+		dirSchema, ok := dirSchemas[dir]
+		if !ok {
+			if dirSchema, err = generator.LookupSchema(dir); err != nil {
+				return nil, err
+			}
+			dirSchemas[dir] = dirSchema
+		}
+		f := &File{Path: name, Src: src, Runtime: dirRuntime, Schema: dirSchema}
+		files = append(files, f)
+
+		goxFile, err := parser.Parse(name, src)
+		if err != nil {
+			continue
+		}
+		if pkg := generator.FileRuntime(goxFile); pkg != "" {
+			f.Runtime = pkg
+		}
+		code, sm, err := generator.Generate(goxFile, &generator.Options{RuntimePackage: f.Runtime})
+		if err != nil {
+			continue
+		}
+		goName := name + ".go"
+		goFile, err := goparser.ParseFile(fset, goName, code, 0)
+		if err != nil {
+			continue
+		}
+		f.Gox, f.Go, f.SourceMap = goxFile, goFile, sm
+		byGoFile[goName] = f
+	}
+
+	var diagnostics []Diagnostic
+	for _, a := range analyzers {
+		a.Run(&Pass{
+			Analyzer:    a,
+			Fset:        fset,
+			Files:       files,
+			Runtime:     runtimePkg,
+			byGoFile:    byGoFile,
+			diagnostics: &diagnostics,
+		})
+	}
+
+	sort.SliceStable(diagnostics, func(i, j int) bool {
+		a, b := diagnostics[i], diagnostics[j]
+		if a.File != b.File {
+			return a.File < b.File
+		}
+		if a.Line != b.Line {
+			return a.Line < b.Line
+		}
+		return a.Column < b.Column
+	})
+	return diagnostics, nil
+}
+
+// Reportf reports a diagnostic at a position in generated code.
+func (p *Pass) Reportf(pos token.Pos, format string, args ...any) {
+	position := p.Fset.Position(pos)
+	f, ok := p.byGoFile[position.Filename]
+	if !ok {
+		return
+	}
+	line, col := position.Line, position.Column
+	tgtLine := uint32(line - 1)
+	if src, ok := f.SourceMap.SourcePositionFromTarget(tgtLine, f.SourceMap.TargetRuneColumn(tgtLine, uint32(col-1))); ok {
+		line, col = int(src.Line)+1, int(src.Column)+1
+	}
+	p.ReportAt(f, line, col, format, args...)
+}
+
+// ReportAt reports a diagnostic at a 1-indexed line and column of a .gox file.
+func (p *Pass) ReportAt(f *File, line, col int, format string, args ...any) {
+	*p.diagnostics = append(*p.diagnostics, Diagnostic{
+		Analyzer: p.Analyzer.Name,
+		File:     f.Path,
+		Line:     line,
+		Column:   col,
+		Message:  fmt.Sprintf(format, args...),
+	})
+}
+
+// RuntimeName returns the name f uses for the gox runtime package, or "" if
+// f does not import it.
+func (p *Pass) RuntimeName(f *goast.File) string {
+	runtime := p.Runtime
+	if file, ok := p.byGoFile[p.Fset.File(f.Pos()).Name()]; ok {
+		runtime = file.Runtime
+	}
+	for _, imp := range f.Imports {
+		importPath, err := strconv.Unquote(imp.Path.Value)
+		if err != nil || importPath != runtime {
+			continue
+		}
+		if imp.Name != nil {
+			return imp.Name.Name
+		}
+		return path.Base(importPath)
+	}
+	return ""
+}
+
+// isRuntimeCall reports whether call is runtime.name(...).
+func isRuntimeCall(call *goast.CallExpr, runtime, name string) bool {
+	sel, ok := call.Fun.(*goast.SelectorExpr)
+	if !ok || sel.Sel.Name != name {
+		return false
+	}
+	x, ok := sel.X.(*goast.Ident)
+	return ok && x.Name == runtime
+}
@@ -0,0 +1,219 @@
+package vet
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// runSource analyzes a single .gox source with one analyzer and returns the
+// diagnostics as strings, with the file name stripped.
+func runSource(t *testing.T, a *Analyzer, src string) []string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "app.gox")
+	if err := os.WriteFile(path, []byte(src), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	diagnostics, err := Run([]string{path}, []*Analyzer{a}, nil)
+	if err != nil {
+		t.Fatalf("Run: %v", err)
+	}
+	var out []string
+	for _, d := range diagnostics {
+		out = append(out, strings.TrimPrefix(d.String(), path+":"))
+	}
+	return out
+}
+
+func assertDiagnostics(t *testing.T, got, want []string) {
+	t.Helper()
+	if strings.Join(got, "\n") != strings.Join(want, "\n") {
+		t.Errorf("diagnostics:\ngot:\n%s\nwant:\n%s", strings.Join(got, "\n"), strings.Join(want, "\n"))
+	}
+}
+
+func TestMissingKey(t *testing.T) {
+	src := `package ui
+
+import "github.com/germtb/gox"
+
+type RowProps struct {
+	Key  string
+	Name string
+}
+
+func Row(props RowProps) gox.VNode {
+	return <tr>{props.Name}</tr>
+}
+
+func List(names []string) gox.VNode {
+	return <ul>
+		{gox.Map(names, func(n string) gox.VNode { return <li>{n}</li> })}
+		{gox.Map(names, func(n string) gox.VNode { return <li key={n}>{n}</li> })}
+		{gox.MapIndex(names, func(i int, n string) gox.VNode {
+			return <Row name={n} />
+		})}
+		{gox.Map(names, func(n string) gox.VNode { return <Row key={n} name={n} /> })}
+		{gox.Map(names, func(n string) gox.VNode { return <><Row name={n} /></> })}
+		{gox.Map(names, func(n string) gox.VNode { return <Fragment key={n}><Row name={n} /></Fragment> })}
+	</ul>
+}
+`
+	assertDiagnostics(t, runSource(t, MissingKey, src), []string{
+		"16:4: <li> returned from gox.Map callback has no key [missingkey]",
+		"18:4: <Row> returned from gox.MapIndex callback has no key [missingkey]",
+		"22:4: <Fragment> returned from gox.Map callback has no key [missingkey]",
+	})
+}
+
+func TestUnusedProps(t *testing.T) {
+	src := `package ui
+
+import "github.com/germtb/gox"
+
+type CardProps struct {
+	Key      string
+	Title    string
+	Subtitle string
+}
+
+func Card(props CardProps) gox.VNode {
+	return <div>{props.Title}</div>
+}
+
+type BadgeProps struct {
+	Label string
+}
+
+func Badge(props BadgeProps) gox.VNode {
+	return Card(CardProps{Title: props.Label})
+}
+
+type PanelProps struct {
+	Body string
+}
+
+func Panel(props PanelProps) gox.VNode {
+	return render(props)
+}
+`
+	assertDiagnostics(t, runSource(t, UnusedProps, src), []string{
+		"8:2: CardProps.Subtitle is never used by Card [unusedprops]",
+	})
+}
+
+func TestShadowedIntrinsic(t *testing.T) {
+	src := `package ui
+
+import "github.com/germtb/gox"
+
+func card() gox.VNode {
+	return <div class="card" />
+}
+
+func button() gox.VNode {
+	return <span />
+}
+
+func helper() gox.VNode {
+	return <card />
+}
+
+func label() string {
+	return "not a component"
+}
+`
+	assertDiagnostics(t, runSource(t, ShadowedIntrinsic, src), []string{
+		"5:6: func card is shadowed by the intrinsic <card> element, which never calls it; name it Card to use it as a component [shadow]",
+		"9:6: func button is shadowed by the intrinsic <button> element, which never calls it; name it Button to use it as a component [shadow]",
+	})
+}
+
+func TestSpreadComponent(t *testing.T) {
+	src := `package ui
+
+func App(props ButtonProps) gox.VNode {
+	return <div {...attrs}>
+		<Button {...props} />
+	</div>
+}
+`
+	assertDiagnostics(t, runSource(t, SpreadComponent, src), []string{
+		"5:11: spread attribute {...props} on <Button>: set the fields of ButtonProps explicitly [spread]",
+	})
+}
+
+func TestUnsafeHTML(t *testing.T) {
+	src := `package ui
+
+import "github.com/germtb/gox"
+
+func Article(body string) gox.VNode {
+	return <article>
+		{gox.Unsafe(body)}
+	</article>
+}
+
+func Embed(html string) gox.VNode {
+	return gox.Unsafe(html)
+}
+`
+	assertDiagnostics(t, runSource(t, UnsafeHTML, src), []string{
+		"7:4: gox.Unsafe renders HTML verbatim; make sure it is sanitized [unsafe]",
+		"12:9: gox.Unsafe renders HTML verbatim; make sure it is sanitized [unsafe]",
+	})
+}
+
+func TestRuntimeDirective(t *testing.T) {
+	// Calls are recognized through the runtime the file sets
+	src := `//gox:runtime example.com/tui/gox
+package ui
+
+import "example.com/tui/gox"
+
+func Embed(html string) gox.VNode {
+	return gox.Unsafe(html)
+}
+`
+	assertDiagnostics(t, runSource(t, UnsafeHTML, src), []string{
+		"7:9: gox.Unsafe renders HTML verbatim; make sure it is sanitized [unsafe]",
+	})
+}
+
+func TestUnknownIntrinsic(t *testing.T) {
+	dir := t.TempDir()
+	schema := `{"attributes": ["style"], "elements": {"box": ["gap"], "text": []}}`
+	if err := os.WriteFile(filepath.Join(dir, ".goxschema"), []byte(schema), 0644); err != nil {
+		t.Fatal(err)
+	}
+	src := `package ui
+
+import "github.com/germtb/gox"
+
+func App() gox.VNode {
+	return <box gap={1} align="end">
+		<text style="bold">Hi</text>
+		<span />
+	</box>
+}
+`
+	path := filepath.Join(dir, "app.gox")
+	if err := os.WriteFile(path, []byte(src), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	diagnostics, err := Run([]string{path}, []*Analyzer{UnknownIntrinsic}, nil)
+	if err != nil {
+		t.Fatalf("Run: %v", err)
+	}
+	var got []string
+	for _, d := range diagnostics {
+		got = append(got, strings.TrimPrefix(d.String(), path+":"))
+	}
+	assertDiagnostics(t, got, []string{
+		"6:22: unknown attribute align on <box> [schema]",
+		"8:4: unknown element <span> [schema]",
+	})
+}
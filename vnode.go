@@ -1,9 +1,11 @@
 // Package gox provides JSX-like syntax for Go and the core types for virtual DOM trees.
 package gox
 
+import "time"
+
 // VNode is the core tree node type.
 type VNode struct {
-	Type     any // string for intrinsic elements, Component for components
+	Type     any // string for intrinsic elements, Component or ContextComponent for components
 	Props    Props
 	Children []VNode
 }
@@ -18,6 +20,8 @@ type Component func(props Props) VNode
 const (
 	TextNodeType     = "__text__"
 	FragmentNodeType = "__fragment__"
+	UnsafeNodeType   = "__unsafe__"
+	CachedNodeType   = "__cached__"
 )
 
 // IsText returns true if this VNode is a text node.
@@ -32,10 +36,25 @@ func (v VNode) IsFragment() bool {
 	return ok && s == FragmentNodeType
 }
 
+// IsUnsafe returns true if this VNode is raw HTML created with Unsafe.
+func (v VNode) IsUnsafe() bool {
+	s, ok := v.Type.(string)
+	return ok && s == UnsafeNodeType
+}
+
+// IsCached returns true if this VNode is a subtree marked with Cached.
+func (v VNode) IsCached() bool {
+	s, ok := v.Type.(string)
+	return ok && s == CachedNodeType
+}
+
 // IsComponent returns true if this VNode represents a component.
 func (v VNode) IsComponent() bool {
-	_, ok := v.Type.(Component)
-	return ok
+	switch v.Type.(type) {
+	case Component, ContextComponent:
+		return true
+	}
+	return false
 }
 
 // GetTextContent returns the text content if this is a text node.
@@ -49,6 +68,28 @@ func (v VNode) GetTextContent() (string, bool) {
 	return "", false
 }
 
+// GetUnsafeHTML returns the raw HTML if this is an Unsafe node.
+func (v VNode) GetUnsafeHTML() (string, bool) {
+	if !v.IsUnsafe() {
+		return "", false
+	}
+	if html, ok := v.Props["html"].(string); ok {
+		return html, true
+	}
+	return "", false
+}
+
+// GetCacheControl returns the cache key and time to live if this is a
+// Cached node.
+func (v VNode) GetCacheControl() (key string, ttl time.Duration, ok bool) {
+	if !v.IsCached() {
+		return "", 0, false
+	}
+	key, ok = v.Props["key"].(string)
+	ttl, _ = v.Props["ttl"].(time.Duration)
+	return key, ttl, ok
+}
+
 // Empty returns an empty VNode.
 func Empty() VNode {
 	return VNode{}
@@ -5,45 +5,90 @@ exports.deactivate = deactivate;
 const vscode_1 = require("vscode");
 const node_1 = require("vscode-languageclient/node");
 let client;
+// Scheme of the read-only documents showing generated Go for a .gox file.
+const previewScheme = 'gox-generated';
+class GeneratedDocumentProvider {
+    constructor() {
+        this.contents = new Map();
+        this.changeEmitter = new vscode_1.EventEmitter();
+        this.onDidChange = this.changeEmitter.event;
+    }
+    update(uri, text) {
+        const parsed = vscode_1.Uri.parse(uri);
+        this.contents.set(parsed.toString(), text);
+        this.changeEmitter.fire(parsed);
+    }
+    async provideTextDocumentContent(uri) {
+        const key = uri.toString();
+        const cached = this.contents.get(key);
+        if (cached !== undefined) {
+            return cached;
+        }
+        const result = await client.sendRequest('gox/generatedDocument', { textDocument: { uri: vscode_1.Uri.file(uri.path).toString() } });
+        this.contents.set(key, result.text);
+        return result.text;
+    }
+}
 async function activate(context) {
-    // Check if any .gox files exist in the workspace
-    const goxFiles = await vscode_1.workspace.findFiles('**/*.gox', '**/node_modules/**', 1);
-    const hasGoxFiles = goxFiles.length > 0;
     // Get the gox executable path from settings
     const config = vscode_1.workspace.getConfiguration('gox');
     const goxPath = config.get('lsp.path') || 'gox';
+    const lspArgs = ['lsp'];
+    const goplsPath = config.get('lsp.gopls');
+    if (goplsPath) {
+        lspArgs.push('-gopls', goplsPath);
+    }
+    const goplsArgs = config.get('lsp.goplsArgs') || [];
+    if (goplsArgs.length > 0) {
+        lspArgs.push('-gopls-args', goplsArgs.join(' '));
+    }
+    // Check if the Go extension is installed and active
+    const goExtension = vscode_1.extensions.getExtension('golang.go');
+    const goExtensionActive = goExtension?.isActive ?? false;
     // Server options - run gox lsp
     const serverOptions = {
         command: goxPath,
-        args: ['lsp'],
+        args: lspArgs,
     };
-    // Document selector - if gox files exist, handle both .go and .gox
-    // This enables seamless navigation between Go and Gox files
-    const documentSelector = hasGoxFiles
-        ? [
+    // If Go extension is active, only handle .gox files to avoid conflicts
+    // Otherwise, handle both .gox and .go files
+    const documentSelector = goExtensionActive
+        ? [{ scheme: 'file', language: 'gox' }]
+        : [
             { scheme: 'file', language: 'gox' },
             { scheme: 'file', language: 'go' },
-        ]
-        : [{ scheme: 'file', language: 'gox' }];
-    // Client options
+        ];
+    const filePattern = goExtensionActive ? '**/*.gox' : '**/*.{gox,go}';
     const clientOptions = {
         documentSelector,
         synchronize: {
-            fileEvents: hasGoxFiles
-                ? vscode_1.workspace.createFileSystemWatcher('**/*.{gox,go}')
-                : vscode_1.workspace.createFileSystemWatcher('**/*.gox'),
+            fileEvents: vscode_1.workspace.createFileSystemWatcher(filePattern),
         },
     };
     // Create and start the client
     client = new node_1.LanguageClient('goxLanguageServer', 'Gox Language Server', serverOptions, clientOptions);
-    // Log activation mode
-    if (hasGoxFiles) {
-        console.log('Gox LSP activated for both .go and .gox files');
+    if (goExtensionActive) {
+        console.log('Gox LSP activated for .gox files only (Go extension detected)');
     }
     else {
-        console.log('Gox LSP activated for .gox files only');
+        console.log('Gox LSP activated for .gox and .go files');
     }
-    client.start();
+    // Generated-code preview
+    const previewProvider = new GeneratedDocumentProvider();
+    context.subscriptions.push(vscode_1.workspace.registerTextDocumentContentProvider(previewScheme, previewProvider), vscode_1.commands.registerCommand('gox.showGeneratedCode', async () => {
+        const editor = vscode_1.window.activeTextEditor;
+        if (!editor || !editor.document.fileName.endsWith('.gox')) {
+            vscode_1.window.showInformationMessage('Open a .gox file to preview its generated Go code');
+            return;
+        }
+        const uri = vscode_1.Uri.from({ scheme: previewScheme, path: editor.document.uri.fsPath });
+        const doc = await vscode_1.workspace.openTextDocument(uri);
+        await vscode_1.window.showTextDocument(doc, { preview: true, viewColumn: vscode_1.ViewColumn.Beside });
+    }));
+    await client.start();
+    client.onNotification('gox/generatedDocumentChanged', (params) => {
+        previewProvider.update(params.uri, params.text);
+    });
 }
 function deactivate() {
     if (!client) {
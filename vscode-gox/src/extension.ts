@@ -1,4 +1,14 @@
-import { workspace, ExtensionContext, commands, extensions } from 'vscode';
+import {
+  workspace,
+  window,
+  ExtensionContext,
+  commands,
+  extensions,
+  EventEmitter,
+  TextDocumentContentProvider,
+  Uri,
+  ViewColumn,
+} from 'vscode';
 import {
   LanguageClient,
   LanguageClientOptions,
@@ -7,10 +17,48 @@ import {
 
 let client: LanguageClient;
 
+// Scheme of the read-only documents showing generated Go for a .gox file.
+const previewScheme = 'gox-generated';
+
+class GeneratedDocumentProvider implements TextDocumentContentProvider {
+  private contents = new Map<string, string>();
+  private changeEmitter = new EventEmitter<Uri>();
+  readonly onDidChange = this.changeEmitter.event;
+
+  update(uri: string, text: string) {
+    const parsed = Uri.parse(uri);
+    this.contents.set(parsed.toString(), text);
+    this.changeEmitter.fire(parsed);
+  }
+
+  async provideTextDocumentContent(uri: Uri): Promise<string> {
+    const key = uri.toString();
+    const cached = this.contents.get(key);
+    if (cached !== undefined) {
+      return cached;
+    }
+    const result = await client.sendRequest<{ uri: string; text: string }>(
+      'gox/generatedDocument',
+      { textDocument: { uri: Uri.file(uri.path).toString() } }
+    );
+    this.contents.set(key, result.text);
+    return result.text;
+  }
+}
+
 export async function activate(context: ExtensionContext) {
   // Get the gox executable path from settings
   const config = workspace.getConfiguration('gox');
   const goxPath = config.get<string>('lsp.path') || 'gox';
+  const lspArgs = ['lsp'];
+  const goplsPath = config.get<string>('lsp.gopls');
+  if (goplsPath) {
+    lspArgs.push('-gopls', goplsPath);
+  }
+  const goplsArgs = config.get<string[]>('lsp.goplsArgs') || [];
+  if (goplsArgs.length > 0) {
+    lspArgs.push('-gopls-args', goplsArgs.join(' '));
+  }
 
   // Check if the Go extension is installed and active
   const goExtension = extensions.getExtension('golang.go');
@@ -19,7 +67,7 @@ export async function activate(context: ExtensionContext) {
   // Server options - run gox lsp
   const serverOptions: ServerOptions = {
     command: goxPath,
-    args: ['lsp'],
+    args: lspArgs,
   };
 
   // If Go extension is active, only handle .gox files to avoid conflicts
@@ -54,7 +102,30 @@ export async function activate(context: ExtensionContext) {
     console.log('Gox LSP activated for .gox and .go files');
   }
 
-  client.start();
+  // Generated-code preview
+  const previewProvider = new GeneratedDocumentProvider();
+  context.subscriptions.push(
+    workspace.registerTextDocumentContentProvider(previewScheme, previewProvider),
+    commands.registerCommand('gox.showGeneratedCode', async () => {
+      const editor = window.activeTextEditor;
+      if (!editor || !editor.document.fileName.endsWith('.gox')) {
+        window.showInformationMessage('Open a .gox file to preview its generated Go code');
+        return;
+      }
+      const uri = Uri.from({ scheme: previewScheme, path: editor.document.uri.fsPath });
+      const doc = await workspace.openTextDocument(uri);
+      await window.showTextDocument(doc, { preview: true, viewColumn: ViewColumn.Beside });
+    })
+  );
+
+  await client.start();
+
+  client.onNotification(
+    'gox/generatedDocumentChanged',
+    (params: { uri: string; text: string }) => {
+      previewProvider.update(params.uri, params.text);
+    }
+  );
 }
 
 export function deactivate(): Thenable<void> | undefined {